@@ -1,16 +1,25 @@
 package main
 
 import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/nzions/sharedgolibs/pkg/binarycleaner"
+	"github.com/nzions/sharedgolibs/pkg/buildinfo"
 )
 
-const version = "1.0.0"
+const version = "1.2.0"
 
 func main() {
 	var (
@@ -18,8 +27,13 @@ func main() {
 		dryRun      = flag.Bool("dry-run", false, "Show what would be removed without actually removing files")
 		verbose     = flag.Bool("verbose", false, "Enable verbose output")
 		recursive   = flag.Bool("recursive", false, "Search subdirectories recursively")
+		format      = flag.String("format", "text", "Output format: text, json, or csv")
+		jsonOutput  = flag.Bool("json", false, "Output a JSON report (same as -format=json)")
+		watch       = flag.Bool("watch", false, "Run as a daemon, removing new binaries after the grace period")
+		grace       = flag.Duration("grace", binarycleaner.DefaultGracePeriod, "With -watch, how long a new binary is kept after its last change")
 		help        = flag.Bool("help", false, "Show help information")
 		versionFlag = flag.Bool("version", false, "Show version information")
+		keysFlag    = flag.Bool("keys", false, "Show build information as key=value lines")
 	)
 
 	flag.Parse()
@@ -29,11 +43,23 @@ func main() {
 		return
 	}
 
+	if *keysFlag {
+		fmt.Print(buildInfo().Keys())
+		return
+	}
+
 	if *versionFlag {
 		showVersion()
 		return
 	}
 
+	if *jsonOutput {
+		*format = "json"
+	}
+	if *format != "text" && *format != "json" && *format != "csv" {
+		log.Fatalf("Unknown format %q: expected text, json, or csv", *format)
+	}
+
 	// Convert directory to absolute path
 	absDir, err := filepath.Abs(*directory)
 	if err != nil {
@@ -54,6 +80,16 @@ func main() {
 
 	cleaner := binarycleaner.New(config)
 
+	// Watch mode runs until interrupted; extra arguments are more directories
+	if *watch {
+		os.Exit(watchDirectories(cleaner, append([]string{absDir}, flag.Args()...), *grace, *format, *dryRun))
+	}
+
+	// Structured formats print only the report, for CI logs and audits
+	if *format != "text" {
+		os.Exit(writeReport(cleaner, *format))
+	}
+
 	if *dryRun {
 		fmt.Println("=== DRY RUN MODE - No files will be removed ===")
 	}
@@ -75,6 +111,79 @@ func main() {
 	}
 }
 
+// writeReport cleans and writes the report in format, returning the exit
+// code: 1 if any removal failed
+func writeReport(cleaner *binarycleaner.BinaryCleaner, format string) int {
+	report, err := cleaner.CleanReport()
+	if err != nil {
+		log.Printf("Error during cleanup: %v", err)
+		return 1
+	}
+
+	if format == "csv" {
+		err = report.WriteCSV(os.Stdout)
+	} else {
+		err = report.WriteJSON(os.Stdout)
+	}
+	if err != nil {
+		log.Printf("Error writing report: %v", err)
+		return 1
+	}
+
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// watchDirectories runs the watch daemon until interrupted, printing each
+// result as it happens: a line of text, a JSON object, or a CSV row
+func watchDirectories(cleaner *binarycleaner.BinaryCleaner, directories []string, grace time.Duration, format string, dryRun bool) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	encoder := json.NewEncoder(os.Stdout)
+	writer := csv.NewWriter(os.Stdout)
+	if format == "csv" {
+		writer.Write([]string{"time", "path", "type", "size", "action", "error"})
+		writer.Flush()
+	}
+
+	onResult := func(result binarycleaner.FileResult) {
+		switch format {
+		case "json":
+			encoder.Encode(struct {
+				Time time.Time `json:"time"`
+				binarycleaner.FileResult
+			}{time.Now().UTC(), result})
+		case "csv":
+			writer.Write([]string{time.Now().UTC().Format(time.RFC3339), result.Path, result.Type.String(),
+				strconv.FormatInt(result.Size, 10), string(result.Action), result.Error})
+			writer.Flush()
+		default:
+			line := fmt.Sprintf("%s %s (%s, %d bytes)", result.Action, result.Path, result.Type, result.Size)
+			if result.Error != "" {
+				line += ": " + result.Error
+			}
+			log.Println(line)
+		}
+	}
+
+	if format == "text" {
+		log.Printf("Watching %s (grace period %s, dry run %t)", strings.Join(directories, ", "), grace, dryRun)
+	}
+	err := cleaner.Watch(ctx, binarycleaner.WatchOptions{
+		Directories: directories,
+		GracePeriod: grace,
+		OnResult:    onResult,
+	})
+	if err != nil {
+		log.Printf("Error watching directories: %v", err)
+		return 1
+	}
+	return 0
+}
+
 func showHelp() {
 	fmt.Printf("Binary Cleaner v%s\n\n", version)
 	fmt.Println("A tool to find and remove Mach-O and ELF binary files from directories.")
@@ -90,8 +199,22 @@ func showHelp() {
 	fmt.Println("        Search subdirectories recursively")
 	fmt.Println("  -verbose")
 	fmt.Println("        Enable verbose output")
+	fmt.Println("  -format string")
+	fmt.Println("        Output format: text, json, or csv (default \"text\")")
+	fmt.Println("        json and csv print a per-file report (path, type, size, action, error)")
+	fmt.Println("        and exit 1 if any removal failed")
+	fmt.Println("  -json")
+	fmt.Println("        Same as -format=json")
+	fmt.Println("  -watch")
+	fmt.Println("        Run as a daemon: remove binaries that appear in -dir (and any directories")
+	fmt.Println("        given as arguments) once unchanged for the grace period; with -dry-run,")
+	fmt.Println("        only log them. Results are printed as they happen in -format")
+	fmt.Println("  -grace duration")
+	fmt.Println("        With -watch, how long a new binary is kept after its last change (default 30s)")
 	fmt.Println("  -version")
 	fmt.Println("        Show version information")
+	fmt.Println("  -keys")
+	fmt.Println("        Show build information as key=value lines")
 	fmt.Println("  -help")
 	fmt.Println("        Show this help message")
 	fmt.Println()
@@ -102,6 +225,10 @@ func showHelp() {
 	fmt.Printf("  %s --dir ./build --recursive\n\n", os.Args[0])
 	fmt.Println("  # Clean specific directory with verbose output")
 	fmt.Printf("  %s --dir /tmp --verbose\n\n", os.Args[0])
+	fmt.Println("  # Keep the repo root free of stray `go build` output")
+	fmt.Printf("  %s --watch --grace 1m\n\n", os.Args[0])
+	fmt.Println("  # Prune a build agent and keep an audit log")
+	fmt.Printf("  %s --dir ./build --recursive --format=csv > cleanup.csv\n\n", os.Args[0])
 	fmt.Println("SUPPORTED BINARY FORMATS:")
 	fmt.Println("  • Mach-O binaries (macOS): 32-bit, 64-bit, Universal/Fat")
 	fmt.Println("  • ELF binaries (Linux/Unix): All variants")
@@ -114,8 +241,11 @@ func showHelp() {
 	fmt.Println()
 }
 
+// buildInfo describes this binary for -version and -keys
+func buildInfo() buildinfo.Info {
+	return buildinfo.New("binarycleaner", version).WithPackage("binarycleaner", binarycleaner.Version)
+}
+
 func showVersion() {
-	fmt.Printf("Binary Cleaner v%s\n", version)
-	fmt.Printf("Package Version: %s\n", binarycleaner.Version)
-	fmt.Println("Built with Go")
+	fmt.Println(buildInfo())
 }
@@ -0,0 +1,330 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nzions/sharedgolibs/pkg/buildinfo"
+	"github.com/nzions/sharedgolibs/pkg/ca"
+	"github.com/nzions/sharedgolibs/pkg/ca/certinfo"
+)
+
+const version = "1.2.0"
+
+func main() {
+	if len(os.Args) < 2 {
+		showHelp()
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "inspect":
+		os.Exit(runInspect(os.Args[2:]))
+	case "backup":
+		os.Exit(runBackup(os.Args[2:]))
+	case "restore":
+		os.Exit(runRestore(os.Args[2:]))
+	case "doctor":
+		os.Exit(runDoctor(os.Args[2:]))
+	case "-version", "--version", "version":
+		showVersion()
+	case "-keys", "--keys", "keys":
+		fmt.Print(buildInfo().Keys())
+	case "-help", "--help", "-h", "help":
+		showHelp()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", os.Args[1])
+		showHelp()
+		os.Exit(2)
+	}
+}
+
+// runInspect implements `ca inspect [-root ca.crt] [-json] file.crt...`
+func runInspect(args []string) int {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	rootFile := fs.String("root", "", "Root CA certificate (PEM) to verify the chain against")
+	jsonOutput := fs.Bool("json", false, "Output in JSON format")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: ca inspect [-root ca.crt] [-json] file.crt [file.crt...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	var opts []certinfo.Option
+	if *rootFile != "" {
+		rootPEM, err := os.ReadFile(*rootFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading root certificate: %v\n", err)
+			return 1
+		}
+		opts = append(opts, certinfo.WithRootPEM(rootPEM))
+	}
+
+	exitCode := 0
+	summaries := make([]*certinfo.Summary, 0, fs.NArg())
+	for _, file := range fs.Args() {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", file, err)
+			exitCode = 1
+			continue
+		}
+
+		summary, err := certinfo.ParseAndDescribe(data, opts...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error inspecting %s: %v\n", file, err)
+			exitCode = 1
+			continue
+		}
+
+		// Expired certificates and broken chains are failures for scripting
+		if summary.Expired || (summary.Chain != nil && !summary.Chain.Valid) {
+			exitCode = 1
+		}
+		summaries = append(summaries, summary)
+
+		if !*jsonOutput {
+			if fs.NArg() > 1 {
+				fmt.Printf("=== %s ===\n", file)
+			}
+			fmt.Print(summary.String())
+			if fs.NArg() > 1 {
+				fmt.Println()
+			}
+		}
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		var err error
+		if fs.NArg() == 1 && len(summaries) == 1 {
+			err = encoder.Encode(summaries[0])
+		} else {
+			err = encoder.Encode(summaries)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+			return 1
+		}
+	}
+
+	return exitCode
+}
+
+// openPersistedCA opens the CA in a persistence directory. passphraseFile
+// holds the key encryption passphrase for encrypted directories.
+func openPersistedCA(dir, passphraseFile string, mustExist bool) (*ca.CA, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("-dir is required")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ca-cert.pem")); mustExist && err != nil {
+		return nil, fmt.Errorf("no CA in %s: %w", dir, err)
+	}
+
+	config := ca.DefaultCAConfig()
+	config.PersistDir = dir
+	if passphraseFile != "" {
+		passphrase, err := os.ReadFile(passphraseFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading passphrase: %w", err)
+		}
+		config.KeyPassphrase = strings.TrimRight(string(passphrase), "\r\n")
+	}
+	return ca.NewCA(config)
+}
+
+// runBackup implements `ca backup -dir persistdir [-o file]`
+func runBackup(args []string) int {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	dir := fs.String("dir", "", "CA persistence directory to back up")
+	output := fs.String("o", "", "Output file (default ca-backup-<time>.tar.gz)")
+	passphraseFile := fs.String("passphrase-file", "", "File holding the key encryption passphrase")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: ca backup -dir persistdir [-o file] [-passphrase-file file]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	authority, err := openPersistedCA(*dir, *passphraseFile, true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening CA: %v\n", err)
+		return 1
+	}
+	defer authority.Close()
+
+	path := *output
+	if path == "" {
+		path = "ca-backup-" + time.Now().UTC().Format("20060102-150405") + ".tar.gz"
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating backup: %v\n", err)
+		return 1
+	}
+	if err := authority.Backup(file); err != nil {
+		file.Close()
+		os.Remove(path)
+		fmt.Fprintf(os.Stderr, "Error writing backup: %v\n", err)
+		return 1
+	}
+	if err := file.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing backup: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Wrote %s (%d certificates)\n", path, authority.GetCertificateCount())
+	return 0
+}
+
+// runRestore implements `ca restore -dir persistdir file`
+func runRestore(args []string) int {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	dir := fs.String("dir", "", "CA persistence directory to restore into (created if missing)")
+	passphraseFile := fs.String("passphrase-file", "", "File holding the key encryption passphrase")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: ca restore -dir persistdir [-passphrase-file file] backup.tar.gz")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading backup: %v\n", err)
+		return 1
+	}
+	defer file.Close()
+
+	// Opening a missing directory generates a throwaway root; don't leave it
+	// behind if the restore fails
+	_, statErr := os.Stat(*dir)
+	created := os.IsNotExist(statErr)
+
+	authority, err := openPersistedCA(*dir, *passphraseFile, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening CA: %v\n", err)
+		return 1
+	}
+	defer authority.Close()
+
+	if err := authority.Restore(file); err != nil {
+		if created {
+			os.RemoveAll(*dir)
+		}
+		fmt.Fprintf(os.Stderr, "Error restoring backup: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Restored %s into %s (%d certificates)\n", fs.Arg(0), *dir, authority.GetCertificateCount())
+	return 0
+}
+
+// doctorIcons mark each check's status in `ca doctor` output
+var doctorIcons = map[string]string{
+	ca.HealthOK:      "✅",
+	ca.DiagnosisWarn: "⚠️ ",
+	ca.HealthFail:    "❌",
+	ca.HealthSkipped: "⏭️ ",
+}
+
+// runDoctor implements `ca doctor [-json]`
+func runDoctor(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Output in JSON format")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: ca doctor [-json]")
+		fmt.Fprintln(os.Stderr, "Checks the CA server in SGL_CA using SGL_CA_API_KEY or SGL_CA_TOKEN.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	diagnosis := ca.Diagnose(context.Background())
+	exitCode := 0
+	if !diagnosis.OK() {
+		exitCode = 1
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(diagnosis); err != nil {
+			fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+			return 1
+		}
+		return exitCode
+	}
+
+	fmt.Printf("Checking SGL_CA=%s\n\n", diagnosis.URL)
+	for _, check := range diagnosis.Checks {
+		if check.Status == ca.HealthSkipped {
+			fmt.Printf("%s %-12s skipped\n", doctorIcons[check.Status], check.Name)
+			continue
+		}
+		fmt.Printf("%s %-12s %s\n", doctorIcons[check.Status], check.Name, check.Detail)
+		if check.Fix != "" {
+			fmt.Printf("   %-12s → %s\n", "", check.Fix)
+		}
+	}
+	fmt.Println()
+	if exitCode != 0 {
+		fmt.Println("The CA is not usable from here yet; fix the ❌ checks above.")
+	} else {
+		fmt.Println("The CA is ready to use.")
+	}
+	return exitCode
+}
+
+func showHelp() {
+	fmt.Printf("CA Tool v%s\n\n", version)
+	fmt.Println("Certificate utilities for the SharedGoLibs CA.")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  ca <command> [options]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  inspect     Describe certificate files (subject, SANs, key, fingerprints, expiry, chain)")
+	fmt.Println("  backup      Write a CA persistence directory to a tar.gz backup")
+	fmt.Println("  restore     Restore a tar.gz backup into a CA persistence directory")
+	fmt.Println("  doctor      Check SGL_CA: URL, connectivity, credentials, clock skew, and trust store")
+	fmt.Println("  version     Show version information (also --version)")
+	fmt.Println("  keys        Show build information as key=value lines (also --keys)")
+	fmt.Println("  help        Show this help")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  ca inspect service.crt")
+	fmt.Println("  ca inspect -root ca-cert.pem service.crt")
+	fmt.Println("  ca inspect -json *.crt")
+	fmt.Println("  ca backup -dir /data/ca -o ca-backup.tar.gz")
+	fmt.Println("  ca restore -dir ~/.local/share/ca ca-backup.tar.gz")
+	fmt.Println("  SGL_CA=http://localhost:8090 ca doctor")
+	fmt.Println()
+	fmt.Println("Exit Codes:")
+	fmt.Println("  0  All certificates valid")
+	fmt.Println("  1  Unreadable, expired, or failed chain verification; backup or restore failed;")
+	fmt.Println("     doctor found a failing check")
+	fmt.Println("  2  Usage error")
+}
+
+// buildInfo describes this binary for version and keys
+func buildInfo() buildinfo.Info {
+	return buildinfo.New("ca", version).WithPackage("ca", ca.Version)
+}
+
+func showVersion() {
+	fmt.Println(buildInfo())
+}
@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: CC0-1.0
+
+// certsidecar keeps a service certificate, key, and CA on disk fresh for
+// processes that can't link the Go CA library (nginx, Envoy, databases). It
+// subscribes to the CA server's GET /sds stream and rewrites the files on
+// every renewal or root rotation, then signals the process or runs a command.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/nzions/sharedgolibs/pkg/buildinfo"
+	"github.com/nzions/sharedgolibs/pkg/ca"
+)
+
+const version = "1.0.0"
+
+// errWritten ends the stream after the first certificate with -once
+var errWritten = errors.New("certificate written")
+
+func main() {
+	caURL := flag.String("ca", os.Getenv("SGL_CA"), "CA server URL (default $SGL_CA)")
+	service := flag.String("service", "", "Service name to request a certificate for (required)")
+	sans := flag.String("sans", "", "Comma-separated SANs: hostnames, IPs, URIs, or emails (required)")
+	validityDays := flag.Int("validity-days", 0, "Certificate validity in days (0 = CA default)")
+	dir := flag.String("dir", ".", "Directory to write the files to")
+	certFile := flag.String("cert", ca.DefaultCertFileName, "Certificate file name")
+	keyFile := flag.String("key", ca.DefaultKeyFileName, "Private key file name")
+	chainFile := flag.String("chain", ca.DefaultChainFileName, `CA certificate file name ("-" to skip)`)
+	fullChainFile := flag.String("fullchain", "", "Also write the certificate followed by the CA to this file")
+	owner := flag.String("owner", "", `Owner of the files as "user", "user:group", or "uid:gid"`)
+	pidFile := flag.String("pid-file", "", "Signal the process in this PID file after each update")
+	signalName := flag.String("signal", "HUP", "Signal sent with -pid-file: "+signalNames)
+	command := flag.String("exec", "", `Command to run after each update, e.g. "nginx -s reload"`)
+	once := flag.Bool("once", false, "Write the first certificate and exit (e.g. in an init container)")
+	showVersion := flag.Bool("version", false, "Show version information")
+	showKeys := flag.Bool("keys", false, "Show build information as key=value lines")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "certsidecar v%s - keep certificate files fresh from the SharedGoLibs CA\n\n", version)
+		fmt.Fprintln(os.Stderr, "Usage: certsidecar -service name -sans a,b [-dir path] [-pid-file file | -exec cmd] [-once]")
+		flag.PrintDefaults()
+		fmt.Fprintln(os.Stderr, "\nSGL_CA_API_KEY or SGL_CA_TOKEN authenticate to the CA server.")
+	}
+	flag.Parse()
+
+	info := buildinfo.New("certsidecar", version).WithPackage("ca", ca.Version)
+	switch {
+	case *showVersion:
+		fmt.Println(info)
+		return
+	case *showKeys:
+		fmt.Print(info.Keys())
+		return
+	}
+
+	if *service == "" || *sans == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	sig, ok := signals[strings.ToUpper(strings.TrimPrefix(*signalName, "SIG"))]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown signal %q\n", *signalName)
+		os.Exit(2)
+	}
+	if *caURL != "" {
+		os.Setenv("SGL_CA", *caURL)
+	}
+
+	opts := &ca.WriteCertOptions{
+		CertFile:      *certFile,
+		KeyFile:       *keyFile,
+		ChainFile:     *chainFile,
+		FullChainFile: *fullChainFile,
+		Owner:         *owner,
+		PIDFile:       *pidFile,
+		Signal:        sig,
+		Command:       strings.Fields(*command),
+	}
+	req := ca.CertRequestV2{ServiceName: *service, SANs: strings.Split(*sans, ","), ValidityDays: *validityDays}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	err := ca.WatchCertificate(ctx, req, func(update *ca.SecretUpdate) error {
+		files, err := ca.WriteCertFiles(update.CertResponse(), *dir, opts)
+		switch {
+		case errors.Is(err, ca.ErrNotifyFailed):
+			// The files are in place; the next update notifies again
+			log.Printf("Wrote %s (serial %s) but notification failed: %v", files.CertFile, update.VersionInfo, err)
+		case err != nil:
+			return err
+		default:
+			log.Printf("Wrote %s (serial %s, renews %s)", files.CertFile, update.VersionInfo, update.RenewAt.Format("2006-01-02 15:04:05"))
+		}
+		if *once {
+			return errWritten
+		}
+		return nil
+	})
+	if errors.Is(err, errWritten) || errors.Is(err, context.Canceled) {
+		return
+	}
+	log.Printf("Error: %v", err)
+	os.Exit(1)
+}
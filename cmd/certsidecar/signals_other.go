@@ -0,0 +1,18 @@
+//go:build !unix
+
+// SPDX-License-Identifier: CC0-1.0
+
+package main
+
+import "syscall"
+
+// signals are the names accepted by -signal; this platform has no SIGUSR1
+// or SIGUSR2
+var signals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"TERM": syscall.SIGTERM,
+	"INT":  syscall.SIGINT,
+}
+
+// signalNames lists the signals in the -signal help
+const signalNames = "HUP, TERM, or INT"
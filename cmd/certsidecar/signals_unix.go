@@ -0,0 +1,19 @@
+//go:build unix
+
+// SPDX-License-Identifier: CC0-1.0
+
+package main
+
+import "syscall"
+
+// signals are the names accepted by -signal
+var signals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"TERM": syscall.SIGTERM,
+	"INT":  syscall.SIGINT,
+}
+
+// signalNames lists the signals in the -signal help
+const signalNames = "HUP, USR1, USR2, TERM, or INT"
@@ -12,10 +12,12 @@ import (
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
+	"github.com/nzions/sharedgolibs/pkg/buildinfo"
+	"github.com/nzions/sharedgolibs/pkg/dockerutil"
 	"github.com/nzions/sharedgolibs/pkg/util"
 )
 
-const version = "1.2.0"
+const version = "1.5.0"
 
 // ContainerInfo represents comprehensive information about a Docker container
 type ContainerInfo struct {
@@ -28,10 +30,15 @@ type ContainerInfo struct {
 	Keys          string            `json:"keys"`
 	HasCurl       bool              `json:"has_curl"`
 	HasWget       bool              `json:"has_wget"`
+	CAURL         string            `json:"ca_url,omitempty"`
+	CAReachable   bool              `json:"ca_reachable"`
+	TrustsDevCA   bool              `json:"trusts_dev_ca"`
+	CAProbeError  string            `json:"ca_probe_error,omitempty"`
 	ID            string            `json:"id"`
 	Image         string            `json:"image"`
 	Status        string            `json:"status"`
 	Networks      map[string]string `json:"networks"`
+	Probes        []ProbeResult     `json:"probes,omitempty"`
 }
 
 func main() {
@@ -41,6 +48,8 @@ func main() {
 		versionFlag  = flag.Bool("version", false, "Show version information")
 		versionsFlag = flag.Bool("versions", false, "Show versions table (name, healthy, version)")
 		quiet        = flag.Bool("quiet", false, "Suppress progress output")
+		keysFlag     = flag.Bool("keys", false, "Show build information as key=value lines")
+		probesFile   = flag.String("probes", util.MustGetEnv("ENVINFO_PROBES", ""), "YAML file of custom per-container probes (default $ENVINFO_PROBES)")
 	)
 	flag.Parse()
 
@@ -49,11 +58,26 @@ func main() {
 		return
 	}
 
+	if *keysFlag {
+		fmt.Print(buildInfo().Keys())
+		return
+	}
+
 	if *versionFlag {
 		showVersion()
 		return
 	}
 
+	// Load custom probes before touching any container, so a bad file fails fast
+	var probes []ProbeConfig
+	if *probesFile != "" {
+		var err error
+		if probes, err = loadProbes(*probesFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid probes file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Print current environment manager environment
 	currentEnv := util.MustGetEnv("ENVMGR_ENV", "default")
 	if !*jsonOutput && !*versionsFlag {
@@ -66,14 +90,13 @@ func main() {
 		if *jsonOutput {
 			result := map[string]interface{}{
 				"envmgr_env": currentEnv,
-				"error":      fmt.Sprintf("Docker not available: %v", err),
+				"error":      err.Error(),
 				"containers": []ContainerInfo{},
 			}
 			json.NewEncoder(os.Stdout).Encode(result)
-		} else if *versionsFlag {
-			fmt.Printf("Docker not available: %v\n", err)
 		} else {
-			fmt.Printf("Docker not available: %v\n", err)
+			fmt.Println("Docker not available:")
+			fmt.Print(dockerutil.Diagnose(context.Background()))
 		}
 		return
 	}
@@ -141,7 +164,7 @@ func main() {
 			info, err = getContainerInfoForVersions(dockerClient, c)
 		} else {
 			// For full output, get all information
-			info, err = getContainerInfo(dockerClient, c)
+			info, err = getContainerInfo(dockerClient, c, probes)
 		}
 
 		if err != nil {
@@ -182,6 +205,9 @@ func showHelp() {
 	fmt.Println("  -versions       Show versions table (name, healthy, version)")
 	fmt.Println("  -quiet          Suppress progress output")
 	fmt.Println("  -version        Show version information")
+	fmt.Println("  -keys           Show build information as key=value lines")
+	fmt.Println("  -probes=FILE    Run the custom per-container probes of a YAML file")
+	fmt.Println("                  (default $ENVINFO_PROBES)")
 	fmt.Println("  -help           Show this help message")
 	fmt.Println()
 	fmt.Println("This tool shows:")
@@ -193,48 +219,44 @@ func showHelp() {
 	fmt.Println("    * Output from --version (if supported)")
 	fmt.Println("    * Output from --keys (if supported)")
 	fmt.Println("    * Whether curl or wget are available")
+	fmt.Println("    * Whether the CA server (SGL_CA) is reachable and its CA is trusted")
+	fmt.Println("    * Results of custom probes (-probes)")
+	fmt.Println()
+	fmt.Println("Probes file:")
+	fmt.Println("  probes:")
+	fmt.Println("    - name: migration-status")
+	fmt.Println("      containers: [\"api\", \"worker-*\"]   # Names or compose services; all if omitted")
+	fmt.Println("      command: [\"/app/migrate\", \"status\"]")
+	fmt.Println("      parser: regex                      # text (default), json, keys, or regex")
+	fmt.Println("      pattern: 'version (\\d+)'")
+	fmt.Println("    - name: feature-flags")
+	fmt.Println("      http: http://localhost:8080/debug/flags # Fetched inside the container")
+	fmt.Println("      parser: json")
+	fmt.Println("      timeout: 10s                       # Default 5s")
 	fmt.Println()
 	fmt.Println("Source: https://github.com/nzions/sharedgolibs")
 }
 
+// buildInfo describes this binary for -version and -keys
+func buildInfo() buildinfo.Info {
+	return buildinfo.New("envinfo", version).
+		WithPackage("util", util.Version).
+		WithPackage("dockerutil", dockerutil.Version)
+}
+
 func showVersion() {
-	fmt.Printf("envinfo version %s\n", version)
-	fmt.Printf("util package version %s\n", util.Version)
+	fmt.Println(buildInfo())
 }
 
 func initializeDockerClient() (*client.Client, error) {
-	// Try standard Docker client from environment first
-	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err == nil {
-		// Test Docker connectivity
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		_, err = dockerClient.Ping(ctx)
-		if err == nil {
-			return dockerClient, nil
-		}
-	}
-
-	// If standard Docker isn't available, try Colima's default socket location
-	homeDir, err := os.UserHomeDir()
-	if err == nil {
-		colimaSocketPath := fmt.Sprintf("unix://%s/.colima/default/docker.sock", homeDir)
-		dockerClient, err = client.NewClientWithOpts(
-			client.WithHost(colimaSocketPath),
-			client.WithAPIVersionNegotiation(),
-		)
-		if err == nil {
-			// Test Colima Docker connectivity
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancel()
-			_, err = dockerClient.Ping(ctx)
-			if err == nil {
-				return dockerClient, nil
-			}
-		}
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	return nil, fmt.Errorf("docker not available")
+	det, err := dockerutil.DetectClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return det.Client, nil
 }
 
 func getRunningContainers(dockerClient *client.Client) ([]container.Summary, error) {
@@ -278,7 +300,7 @@ func getContainerInfoForVersions(dockerClient *client.Client, c container.Summar
 	return info, nil
 }
 
-func getContainerInfo(dockerClient *client.Client, c container.Summary) (ContainerInfo, error) {
+func getContainerInfo(dockerClient *client.Client, c container.Summary, probes []ProbeConfig) (ContainerInfo, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -346,9 +368,97 @@ func getContainerInfo(dockerClient *client.Client, c container.Summary) (Contain
 	wgetCheck := execInContainer(dockerClient, c.ID, []string{"sh", "-c", "command -v wget >/dev/null 2>&1 && echo 'yes' || echo 'no'"})
 	info.HasWget = strings.TrimSpace(wgetCheck) == "yes"
 
+	// Check the container can reach the CA server and trusts its certificate
+	probeCATrust(dockerClient, c.ID, inspectResult, &info)
+
+	// Run the team's own checks
+	info.Probes = runProbes(dockerClient, c, info.Name, probes)
+
 	return info, nil
 }
 
+// caTrustProbe runs inside a container: it fetches the CA certificate from
+// $1 with curl or wget and checks that the certificate is in the system trust
+// store. Over https, a verified fetch proves trust end to end; a fetch that
+// only succeeds unverified means the CA is reachable but not trusted. It
+// prints one line: "reachable=yes|no trusted=yes|no [error]".
+const caTrustProbe = `url="$1"
+fetch() {
+	if command -v curl >/dev/null 2>&1; then
+		set -- -fsS --max-time 3 "$@"
+		[ -n "$SGL_CA_API_KEY" ] && set -- "$@" -H "X-API-Key: $SGL_CA_API_KEY"
+		[ -n "$SGL_CA_TOKEN" ] && set -- "$@" -H "Authorization: Bearer $SGL_CA_TOKEN"
+		curl "$@" "$url/ca" 2>&1
+	else
+		[ "$1" = "-k" ] && set -- --no-check-certificate
+		set -- -q -O- -T 3 "$@"
+		[ -n "$SGL_CA_API_KEY" ] && set -- "$@" --header="X-API-Key: $SGL_CA_API_KEY"
+		[ -n "$SGL_CA_TOKEN" ] && set -- "$@" --header="Authorization: Bearer $SGL_CA_TOKEN"
+		wget "$@" "$url/ca" 2>&1
+	fi
+}
+if pem=$(fetch); then
+	verified=yes
+elif pem=$(fetch -k); then
+	verified=no
+else
+	echo "reachable=no trusted=no $(echo "$pem" | tail -n 1)"
+	exit 0
+fi
+case "$pem" in *"BEGIN CERTIFICATE"*) ;; *)
+	echo "reachable=yes trusted=no CA server did not return a certificate"
+	exit 0;;
+esac
+case "$url" in https://*)
+	if [ "$verified" = yes ]; then echo "reachable=yes trusted=yes"; else echo "reachable=yes trusted=no certificate verification failed"; fi
+	exit 0;;
+esac
+line=$(echo "$pem" | sed -n 3p)
+for bundle in /etc/ssl/certs/ca-certificates.crt /etc/pki/tls/certs/ca-bundle.crt /etc/ssl/cert.pem /etc/ssl/ca-bundle.pem; do
+	if grep -qsF "$line" "$bundle"; then echo "reachable=yes trusted=yes"; exit 0; fi
+done
+echo "reachable=yes trusted=no CA certificate not in the system trust store"`
+
+// probeCATrust fills in the CA fields of info. The CA server is the
+// container's own SGL_CA, falling back to the one envinfo was given.
+func probeCATrust(dockerClient *client.Client, containerID string, inspectResult container.InspectResponse, info *ContainerInfo) {
+	info.CAURL = util.MustGetEnv("SGL_CA", "")
+	if inspectResult.Config != nil {
+		for _, env := range inspectResult.Config.Env {
+			if value, ok := strings.CutPrefix(env, "SGL_CA="); ok && value != "" {
+				info.CAURL = value
+			}
+		}
+	}
+	info.CAURL = strings.TrimRight(info.CAURL, "/")
+
+	switch {
+	case info.CAURL == "":
+		info.CAProbeError = "SGL_CA not set"
+		return
+	case !info.HasCurl && !info.HasWget:
+		info.CAProbeError = "no curl or wget to probe with"
+		return
+	}
+
+	output := execInContainer(dockerClient, containerID, []string{"sh", "-c", caTrustProbe, "sh", info.CAURL})
+	info.CAReachable, info.TrustsDevCA, info.CAProbeError = parseCATrustProbe(output)
+}
+
+// parseCATrustProbe reads the line printed by caTrustProbe
+func parseCATrustProbe(output string) (reachable, trusted bool, probeErr string) {
+	fields := strings.SplitN(strings.TrimSpace(output), " ", 3)
+	if len(fields) < 2 || !strings.HasPrefix(fields[0], "reachable=") || !strings.HasPrefix(fields[1], "trusted=") {
+		return false, false, "probe failed: " + output
+	}
+	reachable = fields[0] == "reachable=yes"
+	trusted = fields[1] == "trusted=yes"
+	if len(fields) == 3 {
+		probeErr = fields[2]
+	}
+	return reachable, trusted, probeErr
+}
+
 func execInContainer(dockerClient *client.Client, containerID string, cmd []string) string {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -636,9 +746,34 @@ func printContainerInfo(containers []ContainerInfo) {
 		fmt.Printf("  Keys Command: %s\n", container.Keys)
 		fmt.Printf("  Has curl: %t\n", container.HasCurl)
 		fmt.Printf("  Has wget: %t\n", container.HasWget)
+		printCATrust(container)
+		printProbes(container)
 
 		if i < len(containers)-1 {
 			fmt.Println()
 		}
 	}
 }
+
+func printCATrust(container ContainerInfo) {
+	switch {
+	case container.CAURL == "" || (!container.HasCurl && !container.HasWget):
+		fmt.Printf("  Trusts dev CA: unknown (%s)\n", container.CAProbeError)
+	case container.TrustsDevCA:
+		fmt.Printf("  Trusts dev CA: yes (%s)\n", container.CAURL)
+	case !container.CAReachable:
+		fmt.Printf("  Trusts dev CA: no - cannot reach %s: %s\n", container.CAURL, container.CAProbeError)
+	default:
+		fmt.Printf("  Trusts dev CA: no - %s\n", container.CAProbeError)
+	}
+}
+
+func printProbes(container ContainerInfo) {
+	for _, probe := range container.Probes {
+		if probe.Error != "" {
+			fmt.Printf("  Probe %s: error - %s\n", probe.Name, probe.Error)
+		} else {
+			fmt.Printf("  Probe %s: %s\n", probe.Name, formatProbeValue(probe.Value))
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultProbeTimeout bounds a probe without its own timeout
+const defaultProbeTimeout = 5 * time.Second
+
+// ProbeConfig is a team-defined check run in each matching container: a
+// command, or an HTTP GET made from inside the container with curl or
+// wget, whose output a parser turns into the reported value.
+type ProbeConfig struct {
+	Name       string        `yaml:"name"`
+	Containers []string      `yaml:"containers,omitempty"` // Container or compose service names (path.Match syntax); all if empty
+	Command    []string      `yaml:"command,omitempty"`
+	HTTP       string        `yaml:"http,omitempty"`
+	Parser     string        `yaml:"parser,omitempty"`  // text (default), json, keys, or regex
+	Pattern    string        `yaml:"pattern,omitempty"` // For the regex parser
+	Timeout    time.Duration `yaml:"timeout,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// ProbesFile is the YAML file of -probes, e.g.
+//
+//	probes:
+//	  - name: migration-status
+//	    containers: ["api", "worker-*"]
+//	    command: ["/app/migrate", "status"]
+//	    parser: regex
+//	    pattern: 'version (\d+)'
+//	  - name: feature-flags
+//	    http: http://localhost:8080/debug/flags
+//	    parser: json
+type ProbesFile struct {
+	Probes []ProbeConfig `yaml:"probes"`
+}
+
+// ProbeResult is the outcome of a probe in one container
+type ProbeResult struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value,omitempty"`
+	Error string      `json:"error,omitempty"`
+}
+
+// probeParsers turn a probe's output into its value
+var probeParsers = map[string]func(probe ProbeConfig, output string) (interface{}, error){
+	"text":  parseProbeText,
+	"json":  parseProbeJSON,
+	"keys":  parseProbeKeys,
+	"regex": parseProbeRegex,
+}
+
+// loadProbes reads a probes file, rejecting unknown fields and probes that
+// can't run so mistakes surface before any container is probed
+func loadProbes(file string) ([]ProbeConfig, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var probes ProbesFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&probes); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+
+	seen := make(map[string]bool)
+	for i := range probes.Probes {
+		probe := &probes.Probes[i]
+		switch {
+		case probe.Name == "":
+			return nil, fmt.Errorf("probe %d has no name", i+1)
+		case seen[probe.Name]:
+			return nil, fmt.Errorf("probe %q is defined more than once", probe.Name)
+		case (len(probe.Command) == 0) == (probe.HTTP == ""):
+			return nil, fmt.Errorf("probe %q needs either a command or an http URL", probe.Name)
+		}
+		seen[probe.Name] = true
+
+		if probe.Parser == "" {
+			probe.Parser = "text"
+		}
+		if _, ok := probeParsers[probe.Parser]; !ok {
+			return nil, fmt.Errorf("probe %q: unknown parser %q (use text, json, keys, or regex)", probe.Name, probe.Parser)
+		}
+		if probe.Parser == "regex" {
+			if probe.pattern, err = regexp.Compile(probe.Pattern); err != nil || probe.Pattern == "" {
+				return nil, fmt.Errorf("probe %q: invalid pattern %q", probe.Name, probe.Pattern)
+			}
+		}
+		for _, pattern := range probe.Containers {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("probe %q: invalid container pattern %q", probe.Name, pattern)
+			}
+		}
+		if probe.Timeout <= 0 {
+			probe.Timeout = defaultProbeTimeout
+		}
+	}
+	return probes.Probes, nil
+}
+
+// matches reports whether a probe runs in a container, by its name or its
+// compose service
+func (p ProbeConfig) matches(name string, labels map[string]string) bool {
+	if len(p.Containers) == 0 {
+		return true
+	}
+	for _, pattern := range p.Containers {
+		for _, candidate := range []string{name, labels["com.docker.compose.service"]} {
+			if matched, _ := path.Match(pattern, candidate); matched && candidate != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// httpProbe runs inside a container: it fetches $1 with curl or wget and
+// fails on an HTTP error status
+const httpProbe = `if command -v curl >/dev/null 2>&1; then
+	curl -fsS "$1"
+elif command -v wget >/dev/null 2>&1; then
+	wget -q -O- "$1"
+else
+	echo "no curl or wget to probe with" >&2
+	exit 127
+fi`
+
+// runProbes runs every probe that matches the container
+func runProbes(dockerClient *client.Client, c container.Summary, name string, probes []ProbeConfig) []ProbeResult {
+	var results []ProbeResult
+	for _, probe := range probes {
+		if probe.matches(name, c.Labels) {
+			results = append(results, runProbe(dockerClient, c.ID, probe))
+		}
+	}
+	return results
+}
+
+// runProbe runs one probe and parses its output
+func runProbe(dockerClient *client.Client, containerID string, probe ProbeConfig) ProbeResult {
+	result := ProbeResult{Name: probe.Name}
+
+	cmd := probe.Command
+	if probe.HTTP != "" {
+		cmd = []string{"sh", "-c", httpProbe, "sh", probe.HTTP}
+	}
+	output, exitCode, err := execProbe(dockerClient, containerID, cmd, probe.Timeout)
+	switch {
+	case err != nil:
+		result.Error = err.Error()
+	case exitCode != 0:
+		result.Error = fmt.Sprintf("exit status %d", exitCode)
+		if line := lastLine(output); line != "" {
+			result.Error += ": " + line
+		}
+	default:
+		result.Value, err = probeParsers[probe.Parser](probe, output)
+		if err != nil {
+			result.Error = err.Error()
+		}
+	}
+	return result
+}
+
+// execProbe runs a command in a container and returns its combined output
+// and exit code
+func execProbe(dockerClient *client.Client, containerID string, cmd []string, timeout time.Duration) (string, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	execResp, err := dockerClient.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return "", 0, fmt.Errorf("exec create error: %w", err)
+	}
+
+	attachResp, err := dockerClient.ContainerExecAttach(ctx, execResp.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return "", 0, fmt.Errorf("exec attach error: %w", err)
+	}
+	defer attachResp.Close()
+
+	var output bytes.Buffer
+	if _, err := stdcopy.StdCopy(&output, &output, attachResp.Reader); err != nil {
+		if ctx.Err() != nil {
+			return "", 0, fmt.Errorf("timed out after %v", timeout)
+		}
+		return "", 0, fmt.Errorf("exec read error: %w", err)
+	}
+
+	inspect, err := dockerClient.ContainerExecInspect(ctx, execResp.ID)
+	if err != nil {
+		return "", 0, fmt.Errorf("exec inspect error: %w", err)
+	}
+	return strings.TrimSpace(output.String()), inspect.ExitCode, nil
+}
+
+// lastLine returns the last non-empty line of output
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+func parseProbeText(_ ProbeConfig, output string) (interface{}, error) {
+	return output, nil
+}
+
+func parseProbeJSON(_ ProbeConfig, output string) (interface{}, error) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(output), &value); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return value, nil
+}
+
+// parseProbeKeys reads key=value lines, like the output of -keys
+func parseProbeKeys(_ ProbeConfig, output string) (interface{}, error) {
+	keys := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok && key != "" {
+			keys[key] = value
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no key=value lines in output")
+	}
+	return keys, nil
+}
+
+// parseProbeRegex returns the first capture group of the pattern, or the
+// whole match if it has none
+func parseProbeRegex(probe ProbeConfig, output string) (interface{}, error) {
+	match := probe.pattern.FindStringSubmatch(output)
+	switch {
+	case match == nil:
+		return nil, fmt.Errorf("output does not match %q", probe.Pattern)
+	case len(match) > 1:
+		return match[1], nil
+	default:
+		return match[0], nil
+	}
+}
+
+// formatProbeValue renders a value on one line for the text output
+func formatProbeValue(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case map[string]string:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		pairs := make([]string, 0, len(keys))
+		for _, key := range keys {
+			pairs = append(pairs, key+"="+value[key])
+		}
+		return strings.Join(pairs, " ")
+	default:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+		return string(data)
+	}
+}
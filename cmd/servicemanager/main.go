@@ -1,23 +1,53 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/nzions/sharedgolibs/pkg/buildinfo"
+	"github.com/nzions/sharedgolibs/pkg/ca"
 	"github.com/nzions/sharedgolibs/pkg/servicemanager"
+	"gopkg.in/yaml.v3"
 )
 
-const version = "3.1.0"
+const version = "3.18.0"
+
+// Exit codes let Makefiles and CI pipelines gate on environment readiness
+// without parsing text output.
+const (
+	exitOK            = 0 // all expected services healthy
+	exitMissing       = 1 // one or more expected services missing
+	exitImageMismatch = 2 // expected services running with the wrong image
+	exitInternalError = 3 // discovery, kill, or configuration failure
+	exitAssertFailed  = 4 // -assert found differences from the expectation file
+	exitStaleAutoport = 5 // -check-autoport found autoport.go out of date
+)
+
+// out receives all normal (non-error) output; -quiet swaps it for io.Discard.
+var out io.Writer = os.Stdout
+
+// warnings receives non-fatal problems; -quiet swaps it for io.Discard too.
+var warnings io.Writer = os.Stderr
 
 func main() {
 	var (
 		kill        = flag.Bool("k", false, "Kill services listening on monitored ports")
 		killPort    = flag.Int("kill-port", 0, "Kill service on specific port")
+		force       = flag.Bool("force", false, "With -k, -kill-port, or -reconcile, also change services on protected ports")
+		protectFile = flag.String("protect-file", servicemanager.DefaultProtectedPortsPath(), "YAML file of ports never killed without -force")
 		check       = flag.Bool("check", false, "Check status of all services")
 		port        = flag.Int("port", 0, "Check specific port")
 		expected    = flag.Bool("expected", false, "Show only expected services")
@@ -25,12 +55,34 @@ func main() {
 		docker      = flag.Bool("docker", false, "Show only Docker services")
 		local       = flag.Bool("local", false, "Show only local process services")
 		missing     = flag.Bool("missing", false, "Show missing expected services")
-		status      = flag.Bool("status", false, "Show comprehensive service status")
+		status      = flag.Bool("status", false, "Show comprehensive service status, including container resource usage")
+		reconcile   = flag.Bool("reconcile", false, "Kill unexpected, recreate wrong-image, and start missing expected services")
+		dryRun      = flag.Bool("dry-run", false, "With -reconcile, only show the changes that would be made")
+		history     = flag.Bool("history", false, "Show uptime history, restarts, and crash loops (with -port for one port)")
+		tui         = flag.Bool("tui", false, "Interactive terminal UI with a live service table")
+		interval    = flag.Duration("interval", 5*time.Second, "Refresh interval for -tui")
+		assert      = flag.String("assert", "", "Verify the running environment against an expectation YAML file")
+		snapshot    = flag.String("snapshot", "", "Write the running environment as an expectation YAML file ('-' for stdout)")
+		diffEnv     = flag.String("diff-env", "", "Diff the environment of the Docker containers on two ports (e.g., '8080,8085')")
+		topology    = flag.String("topology", "", "Print the service network topology as json, dot, or mermaid")
+		connections = flag.Bool("connections", false, "Show which services talk to which from established TCP connections (with -topology, add them to the graph)")
+		caCert      = flag.String("ca-cert", "", "CA certificate (PEM) that TLS services' certificates must chain to (default: fetched from $SGL_CA)")
 		jsonOutput  = flag.Bool("json", false, "Output in JSON format")
+		schema      = flag.Bool("schema", false, "Print the JSON Schema of the -json and HTTP API output")
+		serve       = flag.String("serve", "", "Serve discovery results as a JSON HTTP API on this address (e.g., 'localhost:9180')")
+		columns     = flag.String("columns", defaultTableColumns, "Comma-separated service table columns")
+		sortBy      = flag.String("sort", sortPort, "Sort the service table by port, name, or uptime")
+		color       = flag.String("color", "auto", "Color the service table: auto (when a terminal), always, or never")
+		quiet       = flag.Bool("quiet", false, "Suppress output; report result via exit code only")
 		portRange   = flag.String("range", "", "Port range to scan (e.g., '3000-4000')")
+		hostAddress = flag.String("host", "", "Check ports on this Docker host instead of localhost ('auto' for DOCKER_HOST's host)")
 		generate    = flag.String("generate", "", "Generate autoport config from docker-compose.yml")
+		checkAuto   = flag.String("check-autoport", "", "Fail if the autoport config is out of date with docker-compose.yml")
+		importCfg   = flag.String("import-config", "", "Use the monitored ports, known services, port range, and protected ports of a config YAML file")
+		exportCfg   = flag.String("export-config", "", "Write the configuration as a YAML file ('-' for stdout) for -import-config")
 		help        = flag.Bool("help", false, "Show help")
 		versionFlag = flag.Bool("version", false, "Show version information")
+		keysFlag    = flag.Bool("keys", false, "Show build information as key=value lines")
 	)
 	flag.Parse()
 
@@ -39,74 +91,293 @@ func main() {
 		return
 	}
 
+	if *keysFlag {
+		fmt.Print(buildInfo().Keys())
+		return
+	}
+
 	if *versionFlag {
 		showVersion()
 		return
 	}
 
+	if *quiet {
+		out = io.Discard
+		warnings = io.Discard
+	}
+
+	os.Exit(run(runOptions{
+		kill:       *kill,
+		killPort:   *killPort,
+		force:      *force,
+		protect:    *protectFile,
+		check:      *check,
+		port:       *port,
+		expected:   *expected,
+		unexpected: *unexpected,
+		docker:     *docker,
+		local:      *local,
+		missing:    *missing,
+		status:     *status,
+		history:    *history,
+		tui:        *tui,
+		interval:   *interval,
+		caCert:     *caCert,
+		assert:     *assert,
+		snapshot:   *snapshot,
+		diffEnv:    *diffEnv,
+		topology:   *topology,
+		conns:      *connections,
+		reconcile:  *reconcile,
+		dryRun:     *dryRun,
+		jsonOutput: *jsonOutput,
+		schema:     *schema,
+		serve:      *serve,
+		columns:    *columns,
+		sortBy:     *sortBy,
+		color:      *color,
+		portRange:  *portRange,
+		host:       *hostAddress,
+		generate:   *generate,
+		checkAuto:  *checkAuto,
+		importCfg:  *importCfg,
+		exportCfg:  *exportCfg,
+	}))
+}
+
+// runOptions carries the parsed command line into run.
+type runOptions struct {
+	kill, check, expected, unexpected, docker, local bool
+	missing, status, history, jsonOutput             bool
+	reconcile, dryRun, tui, force, conns, schema     bool
+	killPort, port                                   int
+	portRange, generate, caCert, host, protect       string
+	assert, snapshot, diffEnv, topology              string
+	importCfg, exportCfg, checkAuto, serve           string
+	columns, sortBy, color                           string
+	interval                                         time.Duration
+}
+
+// run executes the selected mode and returns the process exit code.
+func run(opts runOptions) int {
+	// The schema describes the output; no discovery is needed
+	if opts.schema {
+		out.Write(servicemanager.JSONSchema())
+		return exitOK
+	}
+
+	// Every run records what it discovers so -history can spot flapping services
+	managerOptions := []servicemanager.ManagerOption{
+		servicemanager.WithHistoryFile(servicemanager.DefaultHistoryPath()),
+	}
+
+	table, err := parseTableOptions(opts.columns, opts.sortBy, opts.color)
+	if err != nil {
+		return internalError("Invalid table options: %v", err)
+	}
+
 	// Create service manager with custom port range if specified
-	var sm *servicemanager.ServiceManager
-	if *portRange != "" {
-		start, end, err := parsePortRange(*portRange)
+	if opts.portRange != "" {
+		start, end, err := parsePortRange(opts.portRange)
 		if err != nil {
-			log.Fatalf("Invalid port range: %v", err)
+			return internalError("Invalid port range: %v", err)
 		}
-		sm = servicemanager.New(servicemanager.WithPortRange(start, end))
-	} else {
-		sm = servicemanager.New()
+		managerOptions = append(managerOptions, servicemanager.WithPortRange(start, end))
+	}
+	// Remote engines and WSL2 publish ports on the Docker host, not localhost
+	if opts.host != "" {
+		managerOptions = append(managerOptions, servicemanager.WithDockerHostAddress(opts.host))
+	}
+	// Kills skip SSH and the ports listed in the protected ports file
+	protected, err := servicemanager.LoadProtectedPorts(opts.protect)
+	if err != nil {
+		return internalError("Invalid protected ports file: %v", err)
+	}
+	managerOptions = append(managerOptions, servicemanager.WithProtectedPorts(protected...))
+	// TLS services' certificates are checked against the development CA in
+	// the modes that report them
+	if reportsCertificates(opts) {
+		roots, err := loadCARoots(opts.caCert)
+		if err != nil {
+			return internalError("Failed to load CA certificate: %v", err)
+		}
+		if roots != nil {
+			managerOptions = append(managerOptions, servicemanager.WithCARoots(roots))
+		}
+	}
+	// The comprehensive status includes containers' resource usage
+	if opts.status {
+		managerOptions = append(managerOptions, servicemanager.WithStats())
+	}
+	// The topology includes sampled connections on request
+	if opts.conns && opts.topology != "" {
+		managerOptions = append(managerOptions, servicemanager.WithConnections())
+	}
+	sm := servicemanager.New(managerOptions...)
+	defer func() {
+		if err := sm.HistoryError(); err != nil {
+			warn("Warning: service history not recorded: %v", err)
+		}
+	}()
+
+	// A shared config replaces the built-in defaults; -range still wins
+	if opts.importCfg != "" {
+		if err := importConfig(sm, opts.importCfg); err != nil {
+			return internalError("Failed to import config: %v", err)
+		}
+		if opts.portRange != "" {
+			start, end, _ := parsePortRange(opts.portRange)
+			sm.SetPortRange(start, end)
+		}
+	}
+
+	// Handle writing the configuration for other machines
+	if opts.exportCfg != "" {
+		return exportConfig(sm, opts.exportCfg)
 	}
 
 	// Handle autoport generation
-	if *generate != "" {
-		err := sm.GenerateAutoPortConfig(*generate, "pkg/autoport/autoport.go")
+	if opts.generate != "" {
+		err := sm.GenerateAutoPortConfig(opts.generate, "pkg/autoport/autoport.go")
 		if err != nil {
-			log.Fatalf("Failed to generate autoport config: %v", err)
+			return internalError("Failed to generate autoport config: %v", err)
 		}
-		fmt.Println("Autoport configuration generated successfully")
-		return
+		fmt.Fprintln(out, "Autoport configuration generated successfully")
+		return exitOK
+	}
+
+	// Handle checking the generated autoport config is up to date
+	if opts.checkAuto != "" {
+		return checkAutoportDrift(sm, opts.checkAuto, opts.jsonOutput)
+	}
+
+	// Handle serving the HTTP API until interrupted
+	if opts.serve != "" {
+		return serveAPI(sm, opts.serve)
+	}
+
+	// Handle the interactive terminal UI
+	if opts.tui {
+		if opts.interval <= 0 {
+			return internalError("Invalid -interval: %v", opts.interval)
+		}
+		return runTUI(sm, opts.interval)
+	}
+
+	// Handle environment tests against an expectation file
+	if opts.assert != "" {
+		return assertEnvironment(sm, opts.assert, opts.jsonOutput)
+	}
+
+	// Handle writing an expectation file from the running environment
+	if opts.snapshot != "" {
+		return snapshotEnvironment(sm, opts.snapshot)
+	}
+
+	// Handle comparing two containers' environments
+	if opts.diffEnv != "" {
+		return diffEnvironment(sm, opts.diffEnv, opts.jsonOutput)
+	}
+
+	// Handle drawing the network topology
+	if opts.topology != "" {
+		return showTopology(sm, opts.topology, opts.jsonOutput)
+	}
+
+	// Handle mapping which service talks to which
+	if opts.conns {
+		return showConnections(sm, opts.jsonOutput)
+	}
+
+	// Handle reconciliation against the expected services
+	if opts.reconcile {
+		return reconcileServices(sm, opts.dryRun, opts.force, opts.jsonOutput)
+	}
+
+	// Handle history (optionally for a single port)
+	if opts.history {
+		return showHistory(sm, opts.port, opts.jsonOutput)
 	}
 
 	// Handle specific port checking
-	if *port > 0 {
-		checkSpecificPort(sm, *port, *jsonOutput)
-		return
+	if opts.port > 0 {
+		return checkSpecificPort(sm, opts.port, opts.jsonOutput)
 	}
 
 	// Handle specific port killing
-	if *killPort > 0 {
-		killSpecificPort(sm, *killPort)
-		return
+	if opts.killPort > 0 {
+		return killSpecificPort(sm, opts.killPort, opts.force)
 	}
 
 	// Handle service discovery with filters
-	if *expected || *unexpected || *docker || *local {
-		showFilteredServices(sm, *expected, *unexpected, *docker, *local, *jsonOutput)
-		return
+	if opts.expected || opts.unexpected || opts.docker || opts.local {
+		return showFilteredServices(sm, opts.expected, opts.unexpected, opts.docker, opts.local, opts.jsonOutput, table)
 	}
 
 	// Handle missing services
-	if *missing {
-		showMissingServices(sm, *jsonOutput)
-		return
+	if opts.missing {
+		return showMissingServices(sm, opts.jsonOutput)
 	}
 
 	// Handle comprehensive status
-	if *status {
-		showServiceStatus(sm, *jsonOutput)
-		return
+	if opts.status {
+		return showServiceStatus(sm, opts.jsonOutput, table)
+	}
+
+	// Handle kill services
+	if opts.kill && !opts.check {
+		return killAllServices(sm, opts.force)
 	}
 
 	// Handle check (default behavior)
-	if *check || (!*kill && flag.NFlag() == 0) {
-		showAllServices(sm, *jsonOutput)
-		return
+	return showAllServices(sm, opts.jsonOutput, table)
+}
+
+// reportsCertificates reports whether the mode run selects shows services'
+// certificate status, following run's order of precedence
+func reportsCertificates(opts runOptions) bool {
+	switch {
+	case opts.exportCfg != "", opts.generate != "", opts.checkAuto != "":
+		return false
+	case opts.serve != "", opts.tui:
+		return true
+	case opts.assert != "", opts.snapshot != "", opts.diffEnv != "", opts.topology != "",
+		opts.conns, opts.reconcile, opts.history, opts.port > 0, opts.killPort > 0:
+		return false
+	case opts.expected, opts.unexpected, opts.docker, opts.local:
+		return true
+	case opts.missing:
+		return false
+	case opts.status:
+		return true
+	case opts.kill && !opts.check:
+		return false
+	default:
+		return true
 	}
+}
 
-	// Handle kill services
-	if *kill {
-		killAllServices(sm)
-		return
+// warn reports a non-fatal problem on stderr, unless -quiet
+func warn(format string, args ...interface{}) {
+	fmt.Fprintf(warnings, format+"\n", args...)
+}
+
+// internalError reports err on stderr (even in quiet mode) and returns exitInternalError.
+func internalError(format string, args ...interface{}) int {
+	log.Printf(format, args...)
+	return exitInternalError
+}
+
+// statusExitCode maps a service status onto the documented exit codes.
+// Missing services take precedence over image mismatches.
+func statusExitCode(status *servicemanager.ServiceStatus) int {
+	if len(status.Missing) > 0 {
+		return exitMissing
 	}
+	if status.ImageMismatch > 0 {
+		return exitImageMismatch
+	}
+	return exitOK
 }
 
 func showHelp() {
@@ -123,34 +394,99 @@ func showHelp() {
 	fmt.Println("  -docker         Show only Docker container services")
 	fmt.Println("  -local          Show only local process services")
 	fmt.Println("  -missing        Show missing expected services")
-	fmt.Println("  -status         Show comprehensive service status")
+	fmt.Println("  -status         Show comprehensive service status, with containers' CPU, memory,")
+	fmt.Println("                  and restart counts")
+	fmt.Println("  -history        Show uptime history, restarts, and crash loops")
+	fmt.Println("  -tui            Interactive terminal UI: live table with health colors;")
+	fmt.Println("                  x kill, r restart, l logs, o open health URL, q quit")
+	fmt.Println("  -interval=D     Refresh interval for -tui (default 5s)")
+	fmt.Println("  -diff-env=A,B   Diff the allowlisted environment of the containers on ports A and B")
+	fmt.Println("  -topology=FMT   Print containers, networks, aliases, and dependencies as json, dot, or mermaid")
+	fmt.Println("  -connections    Show which services talk to which from established TCP connections;")
+	fmt.Println("                  with -topology, draw them as bold edges")
+	fmt.Println("  -serve=ADDR     Serve services, status, and missing services as a JSON HTTP API under")
+	fmt.Printf("                  %s (see package servicemanager/client)\n", servicemanager.APIPrefix)
 	fmt.Println()
 	fmt.Println("Service Control:")
 	fmt.Println("  -k              Kill services listening on monitored ports")
 	fmt.Println("  -kill-port=N    Kill service on specific port N")
+	fmt.Println("  -force          With -k, -kill-port, or -reconcile, also change services on protected ports")
+	fmt.Println("  -reconcile      Kill unexpected, recreate wrong-image, and start missing expected services")
+	fmt.Println("  -dry-run        With -reconcile, only show the changes that would be made")
+	fmt.Println()
+	fmt.Println("Environment Tests:")
+	fmt.Println("  -snapshot=FILE  Write the running environment as an expectation YAML file ('-' for stdout)")
+	fmt.Println("  -assert=FILE    Verify services, ports, images, and health against an expectation file;")
+	fmt.Println("                  prints a diff and exits 4 on mismatch")
 	fmt.Println()
 	fmt.Println("Configuration:")
 	fmt.Println("  -range=START-END Port range to scan (e.g., '3000-4000')")
+	fmt.Println("  -host=ADDR       Check ports, health, and certificates on a Docker host instead of")
+	fmt.Println("                   localhost; 'auto' uses the host of a tcp:// or ssh:// DOCKER_HOST")
+	fmt.Println("  -ca-cert=FILE    CA certificate that TLS services must chain to (default: from $SGL_CA)")
+	fmt.Println("  -protect-file=FILE YAML list of ports never killed without -force (SSH, port 22,")
+	fmt.Printf("                   is always protected; default: %s)\n", servicemanager.DefaultProtectedPortsPath())
 	fmt.Println("  -generate=FILE   Generate autoport config from docker-compose.yml")
+	fmt.Println("  -check-autoport=FILE List services, ports, and images of docker-compose.yml that differ")
+	fmt.Println("                   from the autoport config; exits 5 when it needs regenerating")
+	fmt.Println("  -import-config=FILE Use the monitored ports, known services, and port range of a")
+	fmt.Println("                   shared config instead of the defaults; its protected ports are added")
+	fmt.Println("  -export-config=FILE Write the configuration as YAML for -import-config ('-' for stdout)")
 	fmt.Println()
 	fmt.Println("Output:")
 	fmt.Println("  -json           Output in JSON format")
+	fmt.Printf("  -schema         Print the JSON Schema (version %d) of -json and HTTP API output\n", servicemanager.SchemaVersion)
+	fmt.Printf("  -columns=LIST   Service table columns (default %s); available:\n", defaultTableColumns)
+	fmt.Printf("                  %s. -port=N shows every detail\n", columnNames())
+	fmt.Println("  -sort=ORDER     Sort the service table by port (default), name, or uptime (newest first)")
+	fmt.Println("  -color=MODE     Color table rows by health: auto (default; off when not a terminal")
+	fmt.Println("                  or NO_COLOR is set), always, or never")
+	fmt.Println("  -quiet          Suppress output; report result via exit code only")
 	fmt.Println("  -version        Show version information")
+	fmt.Println("  -keys           Show build information as key=value lines")
 	fmt.Println("  -help           Show this help message")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  servicemanager                    # Check all services")
 	fmt.Println("  servicemanager -port=8080         # Check port 8080")
+	fmt.Println("  servicemanager -sort=uptime -columns=port,name,uptime,image # What restarted last?")
 	fmt.Println("  servicemanager -expected -json    # Expected services as JSON")
-	fmt.Println("  servicemanager -k                 # Kill all monitored services")
+	fmt.Println("  servicemanager -k                 # Kill all monitored services except protected ones")
+	fmt.Println("  servicemanager -kill-port=5432 -force # Kill a protected database anyway")
 	fmt.Println("  servicemanager -missing           # Show missing services")
 	fmt.Println("  servicemanager -range=3000-4000   # Scan ports 3000-4000")
+	fmt.Println("  servicemanager -host=auto         # Services of a remote DOCKER_HOST")
 	fmt.Println("  servicemanager -generate=docker-compose.yml  # Generate autoport config")
+	fmt.Println("  servicemanager -check-autoport=docker-compose.yml # Fail CI if autoport.go is stale")
+	fmt.Println("  servicemanager -export-config=team.yaml # Share this machine's configuration")
+	fmt.Println("  servicemanager -import-config=team.yaml -check # Check against the team's configuration")
+	fmt.Println("  servicemanager -status -quiet     # Gate CI on environment readiness")
+	fmt.Println("  servicemanager -history -port=8080 # Has port 8080 been flapping?")
+	fmt.Println("  servicemanager -reconcile -dry-run # Show what -reconcile would change")
+	fmt.Println("  servicemanager -tui -interval=2s  # Watch and manage services interactively")
+	fmt.Println("  servicemanager -snapshot=env.yaml # Save the current stack as an expectation")
+	fmt.Println("  servicemanager -assert=env.yaml   # Fail CI if the stack drifted")
+	fmt.Println("  servicemanager -diff-env=8080,8085 # Why does one service see a different SGL_CA?")
+	fmt.Println("  servicemanager -topology=dot | dot -Tsvg > stack.svg # Draw the dev stack")
+	fmt.Println("  servicemanager -connections       # Is the backend hitting the emulator or prod?")
+	fmt.Println("  servicemanager -serve=localhost:9180 # Feed a dashboard")
+	fmt.Println()
+	fmt.Println("Exit Codes:")
+	fmt.Println("  0  All expected services healthy")
+	fmt.Println("  1  One or more expected services missing (or -port not listening)")
+	fmt.Println("  2  Expected services running with mismatched images")
+	fmt.Println("  3  Internal error (discovery, kill, reconcile, or configuration failure)")
+	fmt.Println("  4  -assert found differences from the expectation file")
+	fmt.Println("  5  -check-autoport found autoport.go out of date")
+}
+
+// buildInfo describes this binary for -version and -keys
+func buildInfo() buildinfo.Info {
+	return buildinfo.New("servicemanager", version).WithPackage("servicemanager", servicemanager.Version)
 }
 
 func showVersion() {
-	fmt.Printf("servicemanager version %s\n", version)
-	fmt.Printf("servicemanager package version %s\n", servicemanager.Version)
+	fmt.Println(buildInfo())
 	fmt.Println()
 
 	// Create service manager to show Docker info
@@ -170,6 +506,36 @@ func showVersion() {
 	fmt.Printf("  Monitored Ports: %d\n", len(monitoredPorts))
 }
 
+// loadCARoots reads the CA certificate from file or, without one, fetches the
+// bundle from the CA server in $SGL_CA. It returns nil when neither is
+// available, so certificates are described without an issuer check.
+func loadCARoots(file string) (*x509.CertPool, error) {
+	var bundle []byte
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		bundle = data
+	} else if os.Getenv("SGL_CA") != "" {
+		data, err := ca.FetchCABundle()
+		if err != nil {
+			// Best effort: the CA server may simply not be running
+			warn("Warning: CA bundle unavailable, skipping certificate issuer checks: %v", err)
+			return nil, nil
+		}
+		bundle = data
+	} else {
+		return nil, nil
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("no PEM certificates found")
+	}
+	return roots, nil
+}
+
 func parsePortRange(rangeStr string) (int, int, error) {
 	parts := strings.Split(rangeStr, "-")
 	if len(parts) != 2 {
@@ -193,7 +559,7 @@ func parsePortRange(rangeStr string) (int, int, error) {
 	return start, end, nil
 }
 
-func checkSpecificPort(sm *servicemanager.ServiceManager, port int, jsonOutput bool) {
+func checkSpecificPort(sm *servicemanager.ServiceManager, port int, jsonOutput bool) int {
 	service, err := sm.CheckPort(port)
 	if err != nil {
 		if jsonOutput {
@@ -202,31 +568,40 @@ func checkSpecificPort(sm *servicemanager.ServiceManager, port int, jsonOutput b
 				"error":     err.Error(),
 				"listening": false,
 			}
-			json.NewEncoder(os.Stdout).Encode(result)
+			json.NewEncoder(out).Encode(result)
 		} else {
-			fmt.Printf("Port %d: %v\n", port, err)
+			fmt.Fprintf(out, "Port %d: %v\n", port, err)
 		}
-		return
+		return exitMissing
 	}
 
 	if jsonOutput {
-		json.NewEncoder(os.Stdout).Encode(service)
+		json.NewEncoder(out).Encode(service)
 	} else {
 		printServiceInfo(*service)
 	}
+	return exitOK
 }
 
-func killSpecificPort(sm *servicemanager.ServiceManager, port int) {
-	fmt.Printf("Killing service on port %d...\n", port)
-	err := sm.KillServiceOnPort(port)
+func killSpecificPort(sm *servicemanager.ServiceManager, port int, force bool) int {
+	fmt.Fprintf(out, "Killing service on port %d...\n", port)
+	kill := sm.KillServiceOnPort
+	if force {
+		kill = sm.ForceKillServiceOnPort
+	}
+	err := kill(port)
+	if errors.Is(err, servicemanager.ErrProtectedPort) {
+		reason, _ := sm.IsProtectedPort(port)
+		return internalError("Refusing to kill the service on protected port %d (%s); use -force to kill it anyway", port, reason)
+	}
 	if err != nil {
-		fmt.Printf("Failed to kill service on port %d: %v\n", port, err)
-		os.Exit(1)
+		return internalError("Failed to kill service on port %d: %v", port, err)
 	}
-	fmt.Printf("Service on port %d killed successfully\n", port)
+	fmt.Fprintf(out, "Service on port %d killed successfully\n", port)
+	return exitOK
 }
 
-func showFilteredServices(sm *servicemanager.ServiceManager, expected, unexpected, docker, local bool, jsonOutput bool) {
+func showFilteredServices(sm *servicemanager.ServiceManager, expected, unexpected, docker, local bool, jsonOutput bool, table tableOptions) int {
 	var services []servicemanager.ServiceInfo
 	var err error
 
@@ -241,15 +616,15 @@ func showFilteredServices(sm *servicemanager.ServiceManager, expected, unexpecte
 	}
 
 	if err != nil {
-		log.Fatalf("Failed to discover services: %v", err)
+		return internalError("Failed to discover services: %v", err)
 	}
 
 	if jsonOutput {
-		json.NewEncoder(os.Stdout).Encode(services)
+		json.NewEncoder(out).Encode(services)
 	} else {
 		if len(services) == 0 {
-			fmt.Println("No services found matching the filter criteria")
-			return
+			fmt.Fprintln(out, "No services found matching the filter criteria")
+			return exitOK
 		}
 
 		var filterType string
@@ -263,124 +638,472 @@ func showFilteredServices(sm *servicemanager.ServiceManager, expected, unexpecte
 			filterType = "Local"
 		}
 
-		fmt.Printf("%s Services (%d found):\n", filterType, len(services))
-		for _, service := range services {
-			printServiceInfo(service)
-		}
+		fmt.Fprintf(out, "%s Services (%d found):\n", filterType, len(services))
+		printServiceTable(sm, services, table)
 	}
+	return exitOK
 }
 
-func showMissingServices(sm *servicemanager.ServiceManager, jsonOutput bool) {
+func showMissingServices(sm *servicemanager.ServiceManager, jsonOutput bool) int {
 	missing := sm.GetMissingServices()
 
 	if jsonOutput {
-		json.NewEncoder(os.Stdout).Encode(missing)
+		json.NewEncoder(out).Encode(missing)
 	} else {
 		if len(missing) == 0 {
-			fmt.Println("All expected services are running")
-			return
+			fmt.Fprintln(out, "All expected services are running")
+			return exitOK
 		}
 
-		fmt.Printf("Missing Services (%d):\n", len(missing))
+		fmt.Fprintf(out, "Missing Services (%d):\n", len(missing))
 		for _, service := range missing {
-			fmt.Printf("  Port %d: %s\n", service.ExternalPort, service.Name)
+			fmt.Fprintf(out, "  Port %d: %s\n", service.ExternalPort, service.Name)
 			if service.Image != "" {
-				fmt.Printf("    Image: %s\n", service.Image)
+				fmt.Fprintf(out, "    Image: %s\n", service.Image)
 			}
 			if service.HealthPath != "" {
-				fmt.Printf("    Health: %s\n", service.HealthPath)
+				fmt.Fprintf(out, "    Health: %s\n", service.HealthPath)
 			}
 		}
 	}
+
+	if len(missing) > 0 {
+		return exitMissing
+	}
+	return exitOK
 }
 
-func showServiceStatus(sm *servicemanager.ServiceManager, jsonOutput bool) {
+func showServiceStatus(sm *servicemanager.ServiceManager, jsonOutput bool, table tableOptions) int {
 	status, err := sm.GetServiceStatus()
 	if err != nil {
-		log.Fatalf("Failed to get service status: %v", err)
+		return internalError("Failed to get service status: %v", err)
 	}
 
 	if jsonOutput {
-		json.NewEncoder(os.Stdout).Encode(status)
+		json.NewEncoder(out).Encode(status)
 	} else {
-		fmt.Printf("Service Status Summary:\n")
-		fmt.Printf("  Total Services: %d\n", status.Total)
-		fmt.Printf("  Listening: %d\n", status.Listening)
-		fmt.Printf("  Expected: %d\n", status.Expected)
-		fmt.Printf("  Unexpected: %d\n", status.Unexpected)
-		fmt.Printf("  Missing: %d\n", len(status.Missing))
+		fmt.Fprintf(out, "Service Status Summary:\n")
+		fmt.Fprintf(out, "  Total Services: %d\n", status.Total)
+		fmt.Fprintf(out, "  Listening: %d\n", status.Listening)
+		fmt.Fprintf(out, "  Expected: %d\n", status.Expected)
+		fmt.Fprintf(out, "  Unexpected: %d\n", status.Unexpected)
+		fmt.Fprintf(out, "  Missing: %d\n", len(status.Missing))
 
 		if status.Expected > 0 {
-			fmt.Printf("  Image Matches: %d\n", status.ImageMatch)
-			fmt.Printf("  Image Mismatches: %d\n", status.ImageMismatch)
+			fmt.Fprintf(out, "  Image Matches: %d\n", status.ImageMatch)
+			fmt.Fprintf(out, "  Image Mismatches: %d\n", status.ImageMismatch)
+		}
+		if status.CertProblems > 0 {
+			fmt.Fprintf(out, "  Certificate Problems: %d\n", status.CertProblems)
 		}
 
-		fmt.Println()
+		fmt.Fprintln(out)
 
 		if len(status.Running) > 0 {
-			fmt.Printf("Running Services:\n")
-			for _, service := range status.Running {
-				printServiceInfo(service)
-			}
+			fmt.Fprintf(out, "Running Services:\n")
+			printServiceTable(sm, status.Running, table)
 		}
 
 		if len(status.Missing) > 0 {
-			fmt.Printf("\nMissing Services:\n")
+			fmt.Fprintf(out, "\nMissing Services:\n")
 			for _, service := range status.Missing {
-				fmt.Printf("  Port %d: %s (%s)\n", service.ExternalPort, service.Name, service.Image)
+				fmt.Fprintf(out, "  Port %d: %s (%s)\n", service.ExternalPort, service.Name, service.Image)
 			}
 		}
 	}
+
+	return statusExitCode(status)
 }
 
-func showAllServices(sm *servicemanager.ServiceManager, jsonOutput bool) {
-	services, err := sm.DiscoverAllServices()
+func showAllServices(sm *servicemanager.ServiceManager, jsonOutput bool, table tableOptions) int {
+	status, err := sm.GetServiceStatus()
 	if err != nil {
-		log.Fatalf("Failed to discover services: %v", err)
+		return internalError("Failed to discover services: %v", err)
 	}
+	services := status.Running
 
 	if jsonOutput {
-		json.NewEncoder(os.Stdout).Encode(services)
+		json.NewEncoder(out).Encode(services)
 	} else {
-		fmt.Printf("Service Manager %s\n", version)
+		fmt.Fprintf(out, "Service Manager %s\n", version)
 
 		if sm.IsDockerAvailable() {
-			fmt.Printf("Docker: Available (%s)\n", sm.GetDockerSocketPath())
+			fmt.Fprintf(out, "Docker: Available (%s)\n", sm.GetDockerSocketPath())
 		} else {
-			fmt.Println("Docker: Not Available")
+			fmt.Fprintln(out, "Docker: Not Available")
 		}
 
 		portRange := sm.GetPortRange()
-		fmt.Printf("Port Range: %d-%d\n", portRange.Start, portRange.End)
-		fmt.Println()
+		fmt.Fprintf(out, "Port Range: %d-%d\n", portRange.Start, portRange.End)
+		fmt.Fprintln(out)
 
 		if len(services) == 0 {
-			fmt.Println("No services found")
-			return
+			fmt.Fprintln(out, "No services found")
+		} else {
+			fmt.Fprintf(out, "Discovered Services (%d):\n", len(services))
+			printServiceTable(sm, services, table)
+		}
+	}
+
+	return statusExitCode(status)
+}
+
+func reconcileServices(sm *servicemanager.ServiceManager, dryRun, force, jsonOutput bool) int {
+	policy := servicemanager.DefaultReconcilePolicy()
+	policy.DryRun = dryRun
+	policy.Force = force
+
+	report, err := sm.Reconcile(policy)
+	if err != nil {
+		return internalError("Failed to reconcile services: %v", err)
+	}
+
+	if jsonOutput {
+		json.NewEncoder(out).Encode(report)
+	} else if len(report.Changes) == 0 {
+		fmt.Fprintln(out, "All expected services match the configuration")
+	} else {
+		if report.DryRun {
+			fmt.Fprintf(out, "Planned Changes (%d, dry run):\n", len(report.Changes))
+		} else {
+			fmt.Fprintf(out, "Changes (%d):\n", len(report.Changes))
 		}
+		for _, change := range report.Changes {
+			result := ""
+			if change.Error != "" {
+				result = " [FAILED]"
+			} else if change.Applied {
+				result = " [DONE]"
+			}
 
-		fmt.Printf("Discovered Services (%d):\n", len(services))
-		for _, service := range services {
-			printServiceInfo(service)
+			fmt.Fprintf(out, "  %-7s Port %d: %s%s\n", change.Action, change.Port, change.Service, result)
+			if change.Current != "" {
+				fmt.Fprintf(out, "    Current: %s\n", change.Current)
+			}
+			if change.Image != "" {
+				fmt.Fprintf(out, "    Image: %s\n", change.Image)
+			}
+			fmt.Fprintf(out, "    Reason: %s\n", change.Reason)
+			if change.Error != "" {
+				fmt.Fprintf(out, "    Error: %s\n", change.Error)
+			}
 		}
 	}
+
+	if failed := report.Failed(); failed > 0 {
+		return internalError("%d reconcile change(s) failed", failed)
+	}
+	return exitOK
 }
 
-func killAllServices(sm *servicemanager.ServiceManager) {
-	fmt.Println("Killing all monitored services...")
+func assertEnvironment(sm *servicemanager.ServiceManager, file string, jsonOutput bool) int {
+	expectation, err := servicemanager.LoadExpectation(file)
+	if err != nil {
+		return internalError("Failed to load expectation file: %v", err)
+	}
+
+	report, err := sm.Assert(context.Background(), expectation)
+	if err != nil {
+		return internalError("Failed to assert environment: %v", err)
+	}
 
-	errors := sm.KillAllServices()
-	if len(errors) > 0 {
-		fmt.Printf("Errors occurred while killing services:\n")
-		for _, err := range errors {
-			fmt.Printf("  - %v\n", err)
+	if jsonOutput {
+		json.NewEncoder(out).Encode(report)
+	} else if report.Passed() {
+		fmt.Fprintf(out, "Environment matches %s (%d services)\n", file, report.Checked)
+	} else {
+		fmt.Fprintf(out, "Environment differs from %s (%d differences):\n", file, len(report.Mismatches))
+		fmt.Fprintln(out, "--- expected")
+		fmt.Fprintln(out, "+++ actual")
+		for _, m := range report.Mismatches {
+			name := ""
+			if m.Service != "" {
+				name = " (" + m.Service + ")"
+			}
+			fmt.Fprintf(out, "@@ Port %d%s: %s\n", m.Port, name, m.Field)
+			fmt.Fprintf(out, "- %s\n", m.Expected)
+			fmt.Fprintf(out, "+ %s\n", m.Actual)
+		}
+	}
+
+	if !report.Passed() {
+		return exitAssertFailed
+	}
+	return exitOK
+}
+
+func checkAutoportDrift(sm *servicemanager.ServiceManager, file string, jsonOutput bool) int {
+	drift, err := sm.CheckAutoportDrift(file)
+	if err != nil {
+		return internalError("Failed to check autoport config: %v", err)
+	}
+
+	if jsonOutput {
+		json.NewEncoder(out).Encode(drift)
+	} else if !drift.Stale() {
+		fmt.Fprintf(out, "Autoport configuration matches %s\n", file)
+	} else {
+		fmt.Fprintf(out, "Autoport configuration is out of date with %s:\n", file)
+		for _, name := range drift.Added {
+			fmt.Fprintf(out, "+ %s: added\n", name)
+		}
+		for _, name := range drift.Removed {
+			fmt.Fprintf(out, "- %s: removed\n", name)
+		}
+		for _, field := range drift.Changed {
+			fmt.Fprintf(out, "~ %s: %s %s -> %s\n", field.Service, field.Field, field.Generated, field.Compose)
+		}
+		fmt.Fprintf(out, "Run: servicemanager -generate=%s\n", file)
+	}
+
+	if drift.Stale() {
+		return exitStaleAutoport
+	}
+	return exitOK
+}
+
+func snapshotEnvironment(sm *servicemanager.ServiceManager, file string) int {
+	expectation, err := sm.Snapshot(context.Background())
+	if err != nil {
+		return internalError("Failed to snapshot environment: %v", err)
+	}
+
+	data, err := yaml.Marshal(expectation)
+	if err != nil {
+		return internalError("Failed to encode snapshot: %v", err)
+	}
+
+	if file == "-" {
+		out.Write(data)
+		return exitOK
+	}
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return internalError("Failed to write snapshot: %v", err)
+	}
+	fmt.Fprintf(out, "Wrote %d services to %s\n", len(expectation.Services), file)
+	return exitOK
+}
+
+func importConfig(sm *servicemanager.ServiceManager, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return sm.ImportConfig(f)
+}
+
+func exportConfig(sm *servicemanager.ServiceManager, file string) int {
+	var buf bytes.Buffer
+	if err := sm.ExportConfig(&buf); err != nil {
+		return internalError("Failed to export config: %v", err)
+	}
+
+	if file == "-" {
+		out.Write(buf.Bytes())
+		return exitOK
+	}
+	if err := os.WriteFile(file, buf.Bytes(), 0644); err != nil {
+		return internalError("Failed to write config: %v", err)
+	}
+	config := sm.Config()
+	fmt.Fprintf(out, "Wrote %d monitored ports and %d known services to %s\n", len(config.MonitoredPorts), len(config.KnownServices), file)
+	return exitOK
+}
+
+func diffEnvironment(sm *servicemanager.ServiceManager, ports string, jsonOutput bool) int {
+	portA, portB, err := parsePortPair(ports)
+	if err != nil {
+		return internalError("Invalid -diff-env: %v", err)
+	}
+
+	diff, err := sm.DiffEnv(portA, portB)
+	if err != nil {
+		return internalError("Failed to diff environments: %v", err)
+	}
+
+	if jsonOutput {
+		json.NewEncoder(out).Encode(diff)
+		return exitOK
+	}
+
+	fmt.Fprintf(out, "--- %s (port %d)\n", diff.ServiceA, diff.PortA)
+	fmt.Fprintf(out, "+++ %s (port %d)\n", diff.ServiceB, diff.PortB)
+	for _, d := range diff.Differences {
+		if d.InA {
+			fmt.Fprintf(out, "- %s=%s\n", d.Name, d.A)
+		}
+		if d.InB {
+			fmt.Fprintf(out, "+ %s=%s\n", d.Name, d.B)
+		}
+	}
+	fmt.Fprintf(out, "%d different, %d the same\n", len(diff.Differences), diff.Same)
+	return exitOK
+}
+
+// parsePortPair parses "A,B" for -diff-env
+func parsePortPair(value string) (int, int, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("expected two ports, e.g. '8080,8085'")
+	}
+
+	portA, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port: %v", err)
+	}
+	portB, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port: %v", err)
+	}
+	return portA, portB, nil
+}
+
+func showTopology(sm *servicemanager.ServiceManager, format string, jsonOutput bool) int {
+	topology, err := sm.GetNetworkTopology()
+	if err != nil {
+		return internalError("Failed to build network topology: %v", err)
+	}
+
+	if jsonOutput {
+		format = "json"
+	}
+	switch format {
+	case "json":
+		json.NewEncoder(out).Encode(topology)
+	case "dot":
+		fmt.Fprint(out, topology.DOT())
+	case "mermaid":
+		fmt.Fprint(out, topology.Mermaid())
+	default:
+		return internalError("Unknown topology format %q (use json, dot, or mermaid)", format)
+	}
+	return exitOK
+}
+
+func showConnections(sm *servicemanager.ServiceManager, jsonOutput bool) int {
+	edges, err := sm.GetConnectionMap()
+	if err != nil {
+		return internalError("Failed to map connections: %v", err)
+	}
+
+	if jsonOutput {
+		json.NewEncoder(out).Encode(edges)
+		return exitOK
+	}
+	if len(edges) == 0 {
+		fmt.Fprintln(out, "No connections from services observed")
+		return exitOK
+	}
+	for _, edge := range edges {
+		if edge.Detail != "" {
+			fmt.Fprintf(out, "%s -> %s (%s)\n", edge.From, edge.To, edge.Detail)
+		} else {
+			fmt.Fprintf(out, "%s -> %s (outside the stack)\n", edge.From, edge.To)
+		}
+	}
+	return exitOK
+}
+
+// serveAPI serves sm.Handler until the process is stopped
+func serveAPI(sm *servicemanager.ServiceManager, addr string) int {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           sm.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("Serving the servicemanager API (schema version %d) on http://%s%s/", servicemanager.SchemaVersion, addr, servicemanager.APIPrefix)
+	if err := server.ListenAndServe(); err != nil {
+		return internalError("Failed to serve the API: %v", err)
+	}
+	return exitOK
+}
+
+func showHistory(sm *servicemanager.ServiceManager, port int, jsonOutput bool) int {
+	// Observe the current state first so the history is up to date
+	if _, err := sm.DiscoverAllServices(); err != nil {
+		return internalError("Failed to discover services: %v", err)
+	}
+
+	var history []servicemanager.ServiceHistory
+	if port > 0 {
+		h, err := sm.GetServiceHistory(port)
+		if err != nil {
+			if jsonOutput {
+				json.NewEncoder(out).Encode(map[string]interface{}{"port": port, "error": err.Error()})
+			} else {
+				fmt.Fprintf(out, "Port %d: %v\n", port, err)
+			}
+			return exitMissing
 		}
-		os.Exit(1)
+		history = append(history, *h)
 	} else {
-		fmt.Println("All services killed successfully")
+		var err error
+		if history, err = sm.GetAllServiceHistory(); err != nil {
+			return internalError("Failed to read service history: %v", err)
+		}
+	}
+
+	if jsonOutput {
+		json.NewEncoder(out).Encode(history)
+		return exitOK
+	}
+
+	if len(history) == 0 {
+		fmt.Fprintln(out, "No service history recorded yet")
+		return exitOK
 	}
+
+	fmt.Fprintf(out, "Service History (%d ports):\n", len(history))
+	for _, h := range history {
+		state := "●"
+		if !h.Up {
+			state = "○"
+		}
+		flapping := ""
+		if h.CrashLooping {
+			flapping = " [CRASH LOOP]"
+		}
+
+		fmt.Fprintf(out, "  %s Port %d: %s (%s)%s\n", state, h.Port, h.Name, h.Type, flapping)
+		fmt.Fprintf(out, "    First Seen: %s\n", h.FirstSeen.Local().Format("2006-01-02 15:04:05"))
+		fmt.Fprintf(out, "    Last Seen: %s\n", h.LastSeen.Local().Format("2006-01-02 15:04:05"))
+		fmt.Fprintf(out, "    Observations: %d\n", h.Observations)
+		fmt.Fprintf(out, "    Restarts: %d\n", h.Restarts)
+		if h.CrashLoops > 0 {
+			fmt.Fprintf(out, "    Crash Loops Detected: %d\n", h.CrashLoops)
+		}
+	}
+	return exitOK
+}
+
+func killAllServices(sm *servicemanager.ServiceManager, force bool) int {
+	fmt.Fprintln(out, "Killing all monitored services...")
+
+	kill := sm.KillAllServices
+	if force {
+		kill = sm.ForceKillAllServices
+	}
+	var failures []error
+	for _, err := range kill() {
+		if errors.Is(err, servicemanager.ErrProtectedPort) {
+			fmt.Fprintf(out, "⚠️  %v\n", err)
+			continue
+		}
+		failures = append(failures, err)
+	}
+	if len(failures) > 0 {
+		for _, err := range failures {
+			log.Printf("  - %v", err)
+		}
+		return internalError("Errors occurred while killing %d service(s)", len(failures))
+	}
+
+	fmt.Fprintln(out, "All services killed successfully")
+	return exitOK
 }
 
+// printServiceInfo prints every detail of one service, for -port
 func printServiceInfo(service servicemanager.ServiceInfo) {
 	status := "●"
 	if !service.IsListening {
@@ -397,37 +1120,86 @@ func printServiceInfo(service servicemanager.ServiceInfo) {
 		expected = " [UNEXPECTED]"
 	}
 
-	fmt.Printf("  %s Port %d: %s (%s)%s\n", status, service.ExternalPort, service.Name, service.Type, expected)
+	if service.CertStatus != nil && service.CertStatus.Problem() != "" {
+		expected += " [CERT: " + service.CertStatus.Problem() + "]"
+	}
+
+	fmt.Fprintf(out, "  %s Port %d: %s (%s)%s\n", status, service.ExternalPort, service.Name, service.Type, expected)
 
 	if service.Type == servicemanager.ServiceTypeDockerContainer {
 		if service.ContainerID != "" {
-			fmt.Printf("    Container: %s\n", service.ContainerID)
+			fmt.Fprintf(out, "    Container: %s\n", service.ContainerID)
 		}
 		if service.Image != "" {
-			fmt.Printf("    Image: %s\n", service.Image)
+			fmt.Fprintf(out, "    Image: %s\n", service.Image)
 		}
 		if service.ExpectedImage != "" && service.Image != service.ExpectedImage {
-			fmt.Printf("    Expected Image: %s\n", service.ExpectedImage)
+			fmt.Fprintf(out, "    Expected Image: %s\n", service.ExpectedImage)
 		}
 		if service.Uptime != "" {
-			fmt.Printf("    Uptime: %s\n", service.Uptime)
+			fmt.Fprintf(out, "    Uptime: %s\n", service.Uptime)
+		}
+		if service.Stats != nil {
+			fmt.Fprintf(out, "    Resources: %s\n", service.Stats)
 		}
 	} else if service.Type == servicemanager.ServiceTypeLocalProcess {
 		if service.PID != "" {
-			fmt.Printf("    PID: %s\n", service.PID)
+			fmt.Fprintf(out, "    PID: %s\n", service.PID)
 		}
 		if service.Command != "" {
-			fmt.Printf("    Command: %s\n", service.Command)
+			fmt.Fprintf(out, "    Command: %s\n", service.Command)
+		}
+	}
+
+	// Who started an unexpected service, and what it probably is
+	if service.ComposeProject != "" {
+		fmt.Fprintf(out, "    Compose: %s/%s\n", service.ComposeProject, service.ComposeService)
+	}
+	if service.User != "" {
+		fmt.Fprintf(out, "    User: %s\n", service.User)
+	}
+	if service.ParentPID != "" {
+		fmt.Fprintf(out, "    Parent: %s (%s)\n", service.ParentPID, service.ParentCommand)
+	}
+	if service.WorkingDir != "" {
+		fmt.Fprintf(out, "    Directory: %s\n", service.WorkingDir)
+	}
+	if service.ProbableIdentity != "" {
+		fmt.Fprintf(out, "    Probably: %s\n", service.ProbableIdentity)
+	}
+	if service.Env != nil {
+		names := make([]string, 0, len(service.Env))
+		for name := range service.Env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintln(out, "    Env:")
+		for _, name := range names {
+			fmt.Fprintf(out, "      %s=%s\n", name, service.Env[name])
 		}
 	}
 
 	if service.Description != "" && service.Description != service.Name {
-		fmt.Printf("    Description: %s\n", service.Description)
+		fmt.Fprintf(out, "    Description: %s\n", service.Description)
 	}
 
 	if service.HealthURL != "" {
-		fmt.Printf("    Health: %s\n", service.HealthURL)
+		fmt.Fprintf(out, "    Health: %s\n", service.HealthURL)
+	}
+
+	if cert := service.CertStatus; cert != nil {
+		if cert.Error != "" {
+			fmt.Fprintf(out, "    Certificate: TLS handshake failed: %s\n", cert.Error)
+		} else {
+			issuer := cert.Issuer
+			if cert.IssuedByCA != nil && *cert.IssuedByCA {
+				issuer += " (CA)"
+			}
+			fmt.Fprintf(out, "    Certificate: %s, issued by %s, expires %s (%d days)\n",
+				cert.Subject, issuer, cert.NotAfter.Local().Format("2006-01-02"), cert.DaysToExpiry)
+			fmt.Fprintf(out, "    SANs: %s\n", strings.Join(cert.SANs, ", "))
+		}
 	}
 
-	fmt.Printf("    Status: %s\n", service.Status)
+	fmt.Fprintf(out, "    Status: %s\n", service.Status)
 }
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/nzions/sharedgolibs/pkg/servicemanager"
+	"golang.org/x/term"
+)
+
+// serviceRow is a discovered service with its latest health probe
+type serviceRow struct {
+	service servicemanager.ServiceInfo
+	health  servicemanager.HealthResult
+}
+
+// serviceRows pairs services with their health, probing health URLs
+// concurrently when probe is set
+func serviceRows(sm *servicemanager.ServiceManager, services []servicemanager.ServiceInfo, probe bool) []serviceRow {
+	rows := make([]serviceRow, len(services))
+	var wg sync.WaitGroup
+	for i, service := range services {
+		rows[i].service = service
+		if !probe {
+			continue
+		}
+		wg.Add(1)
+		go func(row *serviceRow) {
+			defer wg.Done()
+			row.health = sm.CheckHealth(context.Background(), row.service)
+		}(&rows[i])
+	}
+	wg.Wait()
+	return rows
+}
+
+// statusText summarizes whether the service is expected, and what is
+// wrong with it
+func (row serviceRow) statusText() string {
+	service := row.service
+	status := "expected"
+	switch {
+	case !service.IsExpected:
+		status = "unexpected"
+		if service.ProbableIdentity != "" {
+			status += ": " + service.ProbableIdentity
+		}
+	case service.Type == servicemanager.ServiceTypeDockerContainer && !service.ImageMatches:
+		status = "image mismatch: " + service.Image
+	}
+	if row.health.State == servicemanager.HealthUnhealthy && row.health.Error != "" {
+		status += " (" + row.health.Error + ")"
+	}
+	if service.CertStatus != nil && service.CertStatus.Problem() != "" {
+		status += " [cert: " + service.CertStatus.Problem() + "]"
+	}
+	return status
+}
+
+// tableColumn is a column of the service table
+type tableColumn struct {
+	name   string // As in -columns
+	header string
+	value  func(row serviceRow) string
+}
+
+// tableColumns are the columns -columns selects from, in help order
+var tableColumns = []tableColumn{
+	{"port", "PORT", func(row serviceRow) string { return fmt.Sprint(row.service.ExternalPort) }},
+	{"name", "NAME", func(row serviceRow) string { return row.service.Name }},
+	{"type", "TYPE", func(row serviceRow) string { return string(row.service.Type) }},
+	{"health", "HEALTH", func(row serviceRow) string { return string(row.health.State) }},
+	{"uptime", "UPTIME", func(row serviceRow) string { return row.service.Uptime }},
+	{"pid", "PID", func(row serviceRow) string { return row.service.PID }},
+	{"image", "IMAGE", func(row serviceRow) string { return row.service.Image }},
+	{"container", "CONTAINER", func(row serviceRow) string { return row.service.ContainerID }},
+	{"compose", "COMPOSE", func(row serviceRow) string {
+		if row.service.ComposeProject == "" {
+			return ""
+		}
+		return row.service.ComposeProject + "/" + row.service.ComposeService
+	}},
+	{"resources", "RESOURCES", func(row serviceRow) string {
+		if row.service.Stats == nil {
+			return ""
+		}
+		return row.service.Stats.String()
+	}},
+	{"status", "STATUS", serviceRow.statusText},
+}
+
+// defaultTableColumns is the -columns default
+const defaultTableColumns = "port,name,type,health,uptime,status"
+
+// Orders of -sort
+const (
+	sortPort   = "port"
+	sortName   = "name"
+	sortUptime = "uptime" // Most recently started first
+)
+
+// tableOptions are the -columns, -sort, and -color settings
+type tableOptions struct {
+	columns []tableColumn
+	sortBy  string
+	color   bool
+}
+
+// parseTableOptions validates -columns, -sort, and -color
+func parseTableOptions(columns, sortBy, color string) (tableOptions, error) {
+	var opts tableOptions
+	for _, name := range strings.Split(columns, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		column, ok := findColumn(name)
+		if !ok {
+			return opts, fmt.Errorf("unknown column %q (available: %s)", name, columnNames())
+		}
+		opts.columns = append(opts.columns, column)
+	}
+	if len(opts.columns) == 0 {
+		return opts, fmt.Errorf("no columns selected (available: %s)", columnNames())
+	}
+
+	switch sortBy {
+	case sortPort, sortName, sortUptime:
+		opts.sortBy = sortBy
+	default:
+		return opts, fmt.Errorf("unknown sort order %q (expected port, name, or uptime)", sortBy)
+	}
+
+	switch color {
+	case "auto":
+		file, ok := out.(*os.File)
+		opts.color = ok && term.IsTerminal(int(file.Fd())) && os.Getenv("NO_COLOR") == ""
+	case "always":
+		opts.color = true
+	case "never":
+		opts.color = false
+	default:
+		return opts, fmt.Errorf("unknown color mode %q (expected auto, always, or never)", color)
+	}
+	return opts, nil
+}
+
+// findColumn looks up a column by its -columns name
+func findColumn(name string) (tableColumn, bool) {
+	for _, column := range tableColumns {
+		if column.name == name {
+			return column, true
+		}
+	}
+	return tableColumn{}, false
+}
+
+// columnNames lists the -columns names
+func columnNames() string {
+	names := make([]string, len(tableColumns))
+	for i, column := range tableColumns {
+		names[i] = column.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// hasColumn reports whether the table shows the named column
+func (opts tableOptions) hasColumn(name string) bool {
+	for _, column := range opts.columns {
+		if column.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sortRows orders rows by port, name, or uptime. Services without a start
+// time sort after those with one when ordering by uptime.
+func sortRows(rows []serviceRow, sortBy string) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i].service, rows[j].service
+		switch sortBy {
+		case sortName:
+			if !strings.EqualFold(a.Name, b.Name) {
+				return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+			}
+		case sortUptime:
+			if (a.StartedAt == nil) != (b.StartedAt == nil) {
+				return a.StartedAt != nil
+			}
+			if a.StartedAt != nil && !a.StartedAt.Equal(*b.StartedAt) {
+				return a.StartedAt.After(*b.StartedAt)
+			}
+		}
+		return a.ExternalPort < b.ExternalPort
+	})
+}
+
+// printServiceTable prints services one row each, with the health probed
+// only when the health column is shown. Colored rows follow the TUI.
+func printServiceTable(sm *servicemanager.ServiceManager, services []servicemanager.ServiceInfo, opts tableOptions) {
+	rows := serviceRows(sm, services, opts.hasColumn("health"))
+	sortRows(rows, opts.sortBy)
+
+	cells := make([][]string, len(rows)+1)
+	widths := make([]int, len(opts.columns))
+	for i, column := range opts.columns {
+		cells[0] = append(cells[0], column.header)
+		widths[i] = utf8.RuneCountInString(column.header)
+	}
+	for r, row := range rows {
+		for i, column := range opts.columns {
+			value := column.value(row)
+			if value == "" {
+				value = "-"
+			}
+			cells[r+1] = append(cells[r+1], value)
+			widths[i] = max(widths[i], utf8.RuneCountInString(value))
+		}
+	}
+
+	for r, line := range cells {
+		var b strings.Builder
+		b.WriteString("  ")
+		for i, cell := range line {
+			if i == len(line)-1 {
+				b.WriteString(cell) // No trailing padding
+				break
+			}
+			b.WriteString(cell + strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)+2))
+		}
+
+		text := b.String()
+		switch {
+		case !opts.color:
+		case r == 0:
+			text = ansiBold + text + ansiReset
+		default:
+			text = colorForRow(rows[r-1]) + text + ansiReset
+		}
+		fmt.Fprintln(out, text)
+	}
+}
@@ -0,0 +1,506 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nzions/sharedgolibs/pkg/autoport"
+	"github.com/nzions/sharedgolibs/pkg/servicemanager"
+	"golang.org/x/term"
+)
+
+// The TUI follows the model/update/view split of bubbletea: input, timers,
+// and finished background work arrive as messages, update changes the model
+// and may return a command to run in the background, and view renders the
+// whole screen from the model.
+
+// ANSI escape sequences used by the TUI
+const (
+	ansiReset      = "\033[0m"
+	ansiBold       = "\033[1m"
+	ansiDim        = "\033[2m"
+	ansiReverse    = "\033[7m"
+	ansiRed        = "\033[31m"
+	ansiGreen      = "\033[32m"
+	ansiYellow     = "\033[33m"
+	ansiMagenta    = "\033[35m"
+	ansiCyan       = "\033[36m"
+	ansiClear      = "\033[H\033[2J"
+	ansiAltScreen  = "\033[?1049h"
+	ansiMainScreen = "\033[?1049l"
+	ansiHideCursor = "\033[?25l"
+	ansiShowCursor = "\033[?25h"
+)
+
+// tuiLogLines is how much container output the logs view fetches
+const tuiLogLines = 200
+
+// tuiMsg is an event for the TUI model
+type tuiMsg interface{}
+
+// tuiCmd is background work that reports back with a message
+type tuiCmd func() tuiMsg
+
+type (
+	keyMsg     string // A key press, e.g. "q", "up", "enter", "esc"
+	tickMsg    struct{}
+	refreshMsg struct {
+		rows    []serviceRow
+		missing []autoport.ServiceConfig
+		err     error
+	}
+	actionMsg struct {
+		text string
+		err  error
+	}
+	logsMsg struct {
+		title string
+		text  string
+		err   error
+	}
+)
+
+type tuiMode int
+
+const (
+	modeTable tuiMode = iota
+	modeConfirmKill
+	modeLogs
+)
+
+// tuiModel is the complete state of the TUI
+type tuiModel struct {
+	sm         *servicemanager.ServiceManager
+	interval   time.Duration
+	rows       []serviceRow
+	missing    []autoport.ServiceConfig
+	cursor     int
+	mode       tuiMode
+	refreshing bool
+	refreshed  time.Time
+	message    string
+	isError    bool
+	logsTitle  string
+	logs       []string
+	logsOffset int
+	width      int
+	height     int
+	quit       bool
+}
+
+// runTUI runs the interactive terminal UI until the user quits
+func runTUI(sm *servicemanager.ServiceManager, interval time.Duration) int {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return internalError("-tui requires an interactive terminal")
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return internalError("Failed to enter raw mode: %v", err)
+	}
+	fmt.Print(ansiAltScreen + ansiHideCursor)
+	defer func() {
+		fmt.Print(ansiShowCursor + ansiMainScreen)
+		term.Restore(fd, state)
+	}()
+
+	messages := make(chan tuiMsg, 16)
+	go readKeys(os.Stdin, messages)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			messages <- tickMsg{}
+		}
+	}()
+
+	model := &tuiModel{sm: sm, interval: interval, refreshing: true}
+	start := func(cmd tuiCmd) {
+		if cmd != nil {
+			go func() { messages <- cmd() }()
+		}
+	}
+	start(model.refresh)
+
+	for !model.quit {
+		model.width, model.height = 80, 24
+		if width, height, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+			model.width, model.height = width, height
+		}
+		// Raw mode disables output newline translation
+		fmt.Print(ansiClear + strings.ReplaceAll(model.view(), "\n", "\r\n"))
+
+		start(model.update(<-messages))
+	}
+	return exitOK
+}
+
+// readKeys turns terminal input into key messages
+func readKeys(input *os.File, messages chan<- tuiMsg) {
+	buf := make([]byte, 16)
+	for {
+		n, err := input.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, key := range parseKeys(buf[:n]) {
+			messages <- keyMsg(key)
+		}
+	}
+}
+
+// parseKeys names the keys in one read from a raw terminal. Arrow keys
+// arrive as escape sequences; a lone escape is the Esc key.
+func parseKeys(input []byte) []string {
+	var keys []string
+	for i := 0; i < len(input); i++ {
+		switch b := input[i]; {
+		case b == 0x1b && i+2 < len(input) && input[i+1] == '[':
+			switch input[i+2] {
+			case 'A':
+				keys = append(keys, "up")
+			case 'B':
+				keys = append(keys, "down")
+			}
+			i += 2
+		case b == 0x1b:
+			keys = append(keys, "esc")
+		case b == 3:
+			keys = append(keys, "ctrl+c")
+		case b == '\r' || b == '\n':
+			keys = append(keys, "enter")
+		default:
+			keys = append(keys, string(b))
+		}
+	}
+	return keys
+}
+
+// update applies a message to the model and returns any background work
+func (m *tuiModel) update(msg tuiMsg) tuiCmd {
+	switch msg := msg.(type) {
+	case keyMsg:
+		return m.handleKey(string(msg))
+
+	case tickMsg:
+		if m.refreshing || m.mode == modeLogs {
+			return nil
+		}
+		m.refreshing = true
+		return m.refresh
+
+	case refreshMsg:
+		m.refreshing = false
+		if msg.err != nil {
+			m.setMessage(msg.err.Error(), true)
+			return nil
+		}
+		// Keep the cursor on the same port across refreshes
+		port := m.selectedPort()
+		m.rows, m.missing, m.refreshed = msg.rows, msg.missing, time.Now()
+		m.cursor = 0
+		for i, row := range m.rows {
+			if row.service.ExternalPort == port {
+				m.cursor = i
+			}
+		}
+
+	case actionMsg:
+		if msg.err != nil {
+			m.setMessage(msg.err.Error(), true)
+		} else {
+			m.setMessage(msg.text, false)
+		}
+		if m.refreshing {
+			return nil
+		}
+		m.refreshing = true
+		return m.refresh
+
+	case logsMsg:
+		if msg.err != nil {
+			m.mode = modeTable
+			m.setMessage(msg.err.Error(), true)
+			return nil
+		}
+		m.logsTitle = msg.title
+		m.logs = strings.Split(msg.text, "\n")
+		m.logsOffset = max(0, len(m.logs)-m.logsHeight())
+	}
+	return nil
+}
+
+// handleKey applies a key press for the current mode
+func (m *tuiModel) handleKey(key string) tuiCmd {
+	if key == "ctrl+c" {
+		m.quit = true
+		return nil
+	}
+
+	switch m.mode {
+	case modeConfirmKill:
+		m.mode = modeTable
+		if key != "y" {
+			m.setMessage("Kill cancelled", false)
+			return nil
+		}
+		port := m.selectedPort()
+		m.setMessage(fmt.Sprintf("Killing service on port %d...", port), false)
+		return func() tuiMsg {
+			if err := m.sm.KillServiceOnPort(port); err != nil {
+				return actionMsg{err: fmt.Errorf("kill port %d: %w", port, err)}
+			}
+			return actionMsg{text: fmt.Sprintf("Killed service on port %d", port)}
+		}
+
+	case modeLogs:
+		switch key {
+		case "up", "k":
+			m.logsOffset = max(0, m.logsOffset-1)
+		case "down", "j":
+			m.logsOffset = min(max(0, len(m.logs)-m.logsHeight()), m.logsOffset+1)
+		case "esc", "q", "l":
+			m.mode = modeTable
+		}
+		return nil
+	}
+
+	row := m.selected()
+	switch key {
+	case "q":
+		m.quit = true
+	case "up", "k":
+		m.cursor = max(0, m.cursor-1)
+	case "down", "j":
+		m.cursor = min(max(0, len(m.rows)-1), m.cursor+1)
+	case " ":
+		if !m.refreshing {
+			m.refreshing = true
+			return m.refresh
+		}
+	case "x":
+		if row == nil {
+			return nil
+		}
+		// Protected ports can only be killed from the command line with -force
+		if reason, protected := m.sm.IsProtectedPort(row.service.ExternalPort); protected {
+			m.setMessage(fmt.Sprintf("Port %d is protected (%s); use -kill-port=%d -force", row.service.ExternalPort, reason, row.service.ExternalPort), true)
+			return nil
+		}
+		m.mode = modeConfirmKill
+	case "r":
+		if row == nil {
+			return nil
+		}
+		port := row.service.ExternalPort
+		m.setMessage(fmt.Sprintf("Restarting %s...", row.service.Name), false)
+		return func() tuiMsg {
+			if err := m.sm.RestartService(port); err != nil {
+				return actionMsg{err: fmt.Errorf("restart port %d: %w", port, err)}
+			}
+			return actionMsg{text: fmt.Sprintf("Restarted service on port %d", port)}
+		}
+	case "l", "enter":
+		if row == nil {
+			return nil
+		}
+		service := row.service
+		m.mode = modeLogs
+		m.logs, m.logsTitle = nil, "Loading logs..."
+		return func() tuiMsg {
+			text, err := m.sm.ServiceLogs(service.ExternalPort, tuiLogLines)
+			return logsMsg{title: fmt.Sprintf("Logs: %s (port %d)", service.Name, service.ExternalPort), text: text, err: err}
+		}
+	case "o":
+		if row == nil {
+			return nil
+		}
+		healthURL := servicemanager.ResolveHealthURL(row.service)
+		if healthURL == "" {
+			m.setMessage(fmt.Sprintf("%s has no health URL", row.service.Name), true)
+			return nil
+		}
+		if err := openURL(healthURL); err != nil {
+			m.setMessage(fmt.Sprintf("open %s: %v", healthURL, err), true)
+		} else {
+			m.setMessage("Opened "+healthURL, false)
+		}
+	}
+	return nil
+}
+
+// refresh discovers services and probes their health URLs concurrently
+func (m *tuiModel) refresh() tuiMsg {
+	status, err := m.sm.GetServiceStatus()
+	if err != nil {
+		return refreshMsg{err: fmt.Errorf("discover services: %w", err)}
+	}
+
+	rows := serviceRows(m.sm, status.Running, true)
+	sortRows(rows, sortPort)
+	missing := status.Missing
+	sort.Slice(missing, func(i, j int) bool { return missing[i].ExternalPort < missing[j].ExternalPort })
+	return refreshMsg{rows: rows, missing: missing}
+}
+
+func (m *tuiModel) setMessage(text string, isError bool) {
+	m.message, m.isError = text, isError
+}
+
+// selected returns the row under the cursor, or nil when there are none
+func (m *tuiModel) selected() *serviceRow {
+	if m.cursor < 0 || m.cursor >= len(m.rows) {
+		return nil
+	}
+	return &m.rows[m.cursor]
+}
+
+func (m *tuiModel) selectedPort() int {
+	if row := m.selected(); row != nil {
+		return row.service.ExternalPort
+	}
+	return 0
+}
+
+// logsHeight is the number of log lines that fit below the title
+func (m *tuiModel) logsHeight() int {
+	return max(1, m.height-3)
+}
+
+// view renders the current screen
+func (m *tuiModel) view() string {
+	if m.mode == modeLogs {
+		return m.logsView()
+	}
+
+	var b strings.Builder
+	refreshed := "never"
+	if !m.refreshed.IsZero() {
+		refreshed = m.refreshed.Format("15:04:05")
+	}
+	spinner := ""
+	if m.refreshing {
+		spinner = ansiDim + " (refreshing)" + ansiReset
+	}
+	fmt.Fprintf(&b, "%sService Manager %s%s  %d running, %d missing  updated %s every %s%s\n\n",
+		ansiBold, version, ansiReset, len(m.rows), len(m.missing), refreshed, m.interval, spinner)
+
+	header := fmt.Sprintf("  %-6s %-28s %-7s %-10s %-14s %s", "PORT", "NAME", "TYPE", "HEALTH", "UPTIME/PID", "STATUS")
+	b.WriteString(ansiBold + truncate(header, m.width) + ansiReset + "\n")
+
+	// Leave room for the header, missing services, message, and help
+	visible := max(1, m.height-7-len(m.missing))
+	first := 0
+	if m.cursor >= visible {
+		first = m.cursor - visible + 1
+	}
+	for i := first; i < len(m.rows) && i < first+visible; i++ {
+		line := m.rowLine(m.rows[i])
+		if i == m.cursor {
+			line = ansiReverse + truncate(line, m.width) + ansiReset
+		} else {
+			line = truncate(line, m.width)
+		}
+		b.WriteString(colorForRow(m.rows[i]) + line + ansiReset + "\n")
+	}
+	if len(m.rows) == 0 {
+		b.WriteString(ansiDim + "  No services found" + ansiReset + "\n")
+	}
+	for _, missing := range m.missing {
+		line := fmt.Sprintf("○ %-6d %-28s %-7s %-10s %-14s %s", missing.ExternalPort, missing.Name, "-", "-", "-", "missing")
+		b.WriteString(ansiRed + truncate(line, m.width) + ansiReset + "\n")
+	}
+
+	b.WriteString("\n")
+	switch {
+	case m.mode == modeConfirmKill:
+		if row := m.selected(); row != nil {
+			fmt.Fprintf(&b, "%sKill %s on port %d? (y/n)%s\n", ansiYellow+ansiBold, row.service.Name, row.service.ExternalPort, ansiReset)
+		}
+	case m.isError:
+		b.WriteString(ansiRed + truncate(m.message, m.width) + ansiReset + "\n")
+	default:
+		b.WriteString(truncate(m.message, m.width) + "\n")
+	}
+	b.WriteString(ansiDim + truncate("↑/↓ select  x kill  r restart  l logs  o open health URL  space refresh  q quit", m.width) + ansiReset)
+	return b.String()
+}
+
+// rowLine formats one service row without colors
+func (m *tuiModel) rowLine(row serviceRow) string {
+	service := row.service
+	marker := "●"
+	if !service.IsListening {
+		marker = "○"
+	}
+
+	detail := service.Uptime
+	if service.Type == servicemanager.ServiceTypeLocalProcess && service.PID != "" {
+		detail = "pid " + service.PID
+	}
+
+	return fmt.Sprintf("%s %-6d %-28s %-7s %-10s %-14s %s", marker, service.ExternalPort, truncate(service.Name, 28), service.Type, row.health.State, truncate(detail, 14), row.statusText())
+}
+
+// colorForRow picks the row color from health, falling back to expectation
+func colorForRow(row serviceRow) string {
+	switch {
+	case !row.service.IsListening:
+		return ansiDim
+	case row.health.State == servicemanager.HealthUnhealthy:
+		return ansiRed
+	case row.service.CertStatus != nil && row.service.CertStatus.Problem() != "":
+		return ansiYellow
+	case !row.service.IsExpected:
+		return ansiMagenta
+	case row.service.Type == servicemanager.ServiceTypeDockerContainer && !row.service.ImageMatches:
+		return ansiYellow
+	case row.health.State == servicemanager.HealthHealthy:
+		return ansiGreen
+	default:
+		return ansiCyan
+	}
+}
+
+// logsView renders the scrollable logs screen
+func (m *tuiModel) logsView() string {
+	var b strings.Builder
+	b.WriteString(ansiBold + truncate(m.logsTitle, m.width) + ansiReset + "\n\n")
+	end := min(len(m.logs), m.logsOffset+m.logsHeight())
+	for _, line := range m.logs[min(m.logsOffset, end):end] {
+		b.WriteString(truncate(line, m.width) + "\n")
+	}
+	b.WriteString(ansiDim + truncate("↑/↓ scroll  esc back", m.width) + ansiReset)
+	return b.String()
+}
+
+// truncate shortens s to width runes, marking the cut with an ellipsis
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if width <= 0 || len(runes) <= width {
+		return s
+	}
+	if width == 1 {
+		return "…"
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// openURL opens a URL in the default browser
+func openURL(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
@@ -2,18 +2,22 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/nzions/sharedgolibs/pkg/buildinfo"
 	"github.com/nzions/sharedgolibs/pkg/testicle"
 )
 
 const (
-	version = "v1.0.0"
+	version = "v1.23.0"
 )
 
 type Config struct {
@@ -22,16 +26,51 @@ type Config struct {
 	Dir          string
 	ConfigFile   string
 	Version      bool
+	Keys         bool
 	NoVet        bool
 	NoBuildCheck bool
 	Validate     bool
+	List         bool
+	Reporter     string
+	Diff         string
+	Monitor      bool
+	LeakCheck    bool
+	Isolate      bool
+	IsolatePorts int
+	CI           bool
+	Warm         bool
+	CacheDir     string
+	ArtifactsDir string
+	TraceFile    string
+	HTMLReport   string
+	Filter       string
+	Profile      string
+	Budget       time.Duration
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "init" {
+		runInit(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "clean" {
+		runClean(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "lsp" {
+		runLSP(os.Args[2:])
+		return
+	}
+
 	config := parseFlags()
 
+	info := buildinfo.New("testicle", version).WithPackage("testicle", testicle.Version)
+	if config.Keys {
+		fmt.Print(info.Keys())
+		os.Exit(0)
+	}
 	if config.Version {
-		fmt.Printf("testicle %s\n", version)
+		fmt.Println(info)
 		os.Exit(0)
 	}
 
@@ -57,9 +96,40 @@ func main() {
 		NoVet:        config.NoVet,
 		NoBuildCheck: config.NoBuildCheck,
 		Validate:     config.Validate,
+		Reporter:     config.Reporter,
+		Diff:         config.Diff,
+
+		MonitorResources: config.Monitor,
+		LeakCheck:        config.LeakCheck,
+		Isolate:          config.Isolate,
+		IsolatePorts:     config.IsolatePorts,
+		CI:               config.CI,
+		WarmBuild:        config.Warm,
+		CacheDir:         config.CacheDir,
+		ArtifactsDir:     config.ArtifactsDir,
+		TraceFile:        config.TraceFile,
+		HTMLReportFile:   config.HTMLReport,
+		Filter:           config.Filter,
+		Profile:          config.Profile,
+		Budget:           config.Budget,
 	})
 	if err != nil {
-		log.Fatalf("Failed to initialize testicle: %v", err)
+		log.Printf("Failed to initialize testicle: %v", err)
+		os.Exit(testicle.ExitCodeError)
+	}
+
+	if config.List {
+		tree, err := runner.Discover(ctx)
+		if err != nil {
+			log.Printf("❌ Test discovery failed: %v", err)
+			os.Exit(testicle.ExitCodeError)
+		}
+		// The filter was validated by NewRunner
+		if filter, _ := testicle.ParseFilter(config.Filter); filter != nil {
+			tree, _ = filter.Apply(tree)
+		}
+		printTree(tree)
+		os.Exit(0)
 	}
 
 	// Run testicle
@@ -68,7 +138,11 @@ func main() {
 			fmt.Println("✅ Testicle stopped gracefully")
 			os.Exit(0)
 		}
-		log.Fatalf("❌ Testicle failed: %v", err)
+		// The summary has already reported failed tests
+		if !errors.Is(err, testicle.ErrTestsFailed) {
+			log.Printf("❌ Testicle failed: %v", err)
+		}
+		os.Exit(testicle.ExitCode(err))
 	}
 }
 
@@ -81,6 +155,23 @@ func parseFlags() *Config {
 	flag.StringVar(&config.Dir, "dir", getDefaultTestDir(), "Test directory")
 	flag.StringVar(&config.ConfigFile, "config", "testicle.yaml", "Configuration file location")
 	flag.BoolVar(&config.Version, "version", false, "Show version information")
+	flag.BoolVar(&config.Keys, "keys", false, "Show build information as key=value lines")
+	flag.StringVar(&config.Reporter, "reporter", testicle.ReporterDefault, "Output format: default or json-stream (newline-delimited JSON on stdout)")
+	flag.StringVar(&config.Diff, "diff", testicle.DiffInline, "Layout of expected/actual diffs for failed assertions: inline or side-by-side")
+	flag.BoolVar(&config.Monitor, "monitor", false, "Sample CPU, memory, and open files of test processes and flag memory spikes (Linux)")
+	flag.BoolVar(&config.LeakCheck, "leak-check", false, "Report ports left listening by processes started during each package's tests (Linux)")
+	flag.BoolVar(&config.Isolate, "isolate", false, "Give each package its own temp dir and leased ports (TESTICLE_TMP, TESTICLE_PORT_0..n-1)")
+	flag.IntVar(&config.IsolatePorts, "isolate-ports", testicle.DefaultIsolationPorts, "Number of ports leased per package with --isolate")
+	flag.BoolVar(&config.CI, "ci", false, "CI mode: no UI, validate first, GitHub Actions annotations, exit code by failure kind")
+	flag.BoolVar(&config.Warm, "warm", false, "Cache compiled test binaries and re-run them while sources are unchanged")
+	flag.StringVar(&config.CacheDir, "cache-dir", "", "Directory for cached test binaries (default: user cache dir)")
+	flag.StringVar(&config.ArtifactsDir, "artifacts-dir", "", "Keep a report of every run here, pruned by the retention policy (default: off)")
+	flag.StringVar(&config.TraceFile, "trace", "", "Write each run's timeline as Chrome trace JSON for Perfetto or chrome://tracing")
+	flag.StringVar(&config.HTMLReport, "html-report", "", "Write each run's report as one self-contained HTML file with its output and timeline")
+	flag.StringVar(&config.Filter, "filter", "", "Run only the selected tests, e.g. 'pkg:./pkg/ca test:~Transport -test:~Legacy'")
+	flag.StringVar(&config.Profile, "profile", "", "Use a profile from the config file's profiles, e.g. pre-commit")
+	flag.DurationVar(&config.Budget, "budget", 0, "Cap the total run time; remaining packages are not run (overrides the profile)")
+	flag.BoolVar(&config.List, "list", false, "List discovered packages, tests, and subtests without running them")
 
 	// Validation flags
 	flag.BoolVar(&config.NoVet, "no-vet", false, "Skip go vet validation")
@@ -89,13 +180,33 @@ func parseFlags() *Config {
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "🧪 Testicle %s - A Playwright-inspired test runner for Go\n\n", version)
-		fmt.Fprintf(os.Stderr, "Usage: testicle [flags]\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: testicle [flags]\n")
+		fmt.Fprintf(os.Stderr, "       testicle init [--dir <path>] [--force]  Write a starter testicle.yaml\n")
+		fmt.Fprintf(os.Stderr, "       testicle clean [--all] [--cache]       Prune saved run artifacts\n")
+		fmt.Fprintf(os.Stderr, "       testicle lsp [--dir <path>]            Serve test status to editors on stdin/stdout\n\n")
 		fmt.Fprintf(os.Stderr, "Core Flags:\n")
 		fmt.Fprintf(os.Stderr, "  --debug         Enable debug output for troubleshooting\n")
 		fmt.Fprintf(os.Stderr, "  --daemon, -d    Watch mode - auto-run tests on file changes\n")
 		fmt.Fprintf(os.Stderr, "  --dir <path>    Test directory (default: %s)\n", getDefaultTestDir())
 		fmt.Fprintf(os.Stderr, "  --config <file> Configuration file location (default: testicle.yaml)\n")
-		fmt.Fprintf(os.Stderr, "  --version       Show version information\n\n")
+		fmt.Fprintf(os.Stderr, "  --reporter <r>  Output format: default, json-stream (NDJSON on stdout for editors)\n")
+		fmt.Fprintf(os.Stderr, "  --diff <layout> Diffs of failed assertions: inline (default) or side-by-side\n")
+		fmt.Fprintf(os.Stderr, "  --filter <expr> Run only the selected tests (pkg:<path>, test:<name>, ~regexp, -exclude)\n")
+		fmt.Fprintf(os.Stderr, "  --profile <p>   Use a profile from the config file's profiles (e.g. pre-commit)\n")
+		fmt.Fprintf(os.Stderr, "  --budget <d>    Cap the total run time; fastest and last-failed packages run first\n")
+		fmt.Fprintf(os.Stderr, "  --list          List discovered tests and subtests without running them\n")
+		fmt.Fprintf(os.Stderr, "  --ci            No UI, vet and build check first, GitHub Actions annotations for failures\n")
+		fmt.Fprintf(os.Stderr, "  --monitor       Track CPU, memory, and open files of test processes (Linux)\n")
+		fmt.Fprintf(os.Stderr, "  --leak-check    Report ports left listening by processes tests started (Linux)\n")
+		fmt.Fprintf(os.Stderr, "  --isolate       Give each package its own temp dir and ports (TESTICLE_TMP, TESTICLE_PORT_0..n-1)\n")
+		fmt.Fprintf(os.Stderr, "  --isolate-ports <n> Ports leased per package with --isolate (default: %d)\n", testicle.DefaultIsolationPorts)
+		fmt.Fprintf(os.Stderr, "  --warm          Cache test binaries (go test -c) and re-run them while sources are unchanged\n")
+		fmt.Fprintf(os.Stderr, "  --cache-dir <d> Directory for cached test binaries (default: user cache dir)\n")
+		fmt.Fprintf(os.Stderr, "  --artifacts-dir <d> Keep a report of every run in <d>, pruned by the retention policy\n")
+		fmt.Fprintf(os.Stderr, "  --trace <file>  Write each run's timeline as Chrome trace JSON (Perfetto, chrome://tracing)\n")
+		fmt.Fprintf(os.Stderr, "  --html-report <file> Write each run's report as one HTML file with output, timeline, and trace\n")
+		fmt.Fprintf(os.Stderr, "  --version       Show version information\n")
+		fmt.Fprintf(os.Stderr, "  --keys          Show build information as key=value lines\n\n")
 		fmt.Fprintf(os.Stderr, "Validation Flags:\n")
 		fmt.Fprintf(os.Stderr, "  --validate      Run validation only (no test execution)\n")
 		fmt.Fprintf(os.Stderr, "  --no-vet        Skip go vet validation\n")
@@ -103,10 +214,28 @@ func parseFlags() *Config {
 		fmt.Fprintf(os.Stderr, "Examples:\n")
 		fmt.Fprintf(os.Stderr, "  testicle                           # Run tests once with validation\n")
 		fmt.Fprintf(os.Stderr, "  testicle --daemon                  # Watch mode\n")
+		fmt.Fprintf(os.Stderr, "  testicle --daemon --warm           # Watch mode re-running cached test binaries\n")
+		fmt.Fprintf(os.Stderr, "  testicle --list                    # Show the test tree\n")
+		fmt.Fprintf(os.Stderr, "  testicle --filter 'pkg:./pkg/ca test:~Transport' # Run a subset of the suite\n")
+		fmt.Fprintf(os.Stderr, "  testicle --ci                      # CI run with annotations and exit codes\n")
+		fmt.Fprintf(os.Stderr, "  testicle --profile pre-commit      # Run within the profile's time budget\n")
+		fmt.Fprintf(os.Stderr, "  testicle --trace trace.json        # Timeline to open in ui.perfetto.dev\n")
+		fmt.Fprintf(os.Stderr, "  testicle --ci --html-report report.html # One file to attach to the CI run\n")
+		fmt.Fprintf(os.Stderr, "  testicle --reporter=json-stream    # Structured output for editor integrations\n")
 		fmt.Fprintf(os.Stderr, "  testicle --validate                # Run validation only\n")
 		fmt.Fprintf(os.Stderr, "  testicle --no-vet --no-build-check # Skip all validation\n")
 		fmt.Fprintf(os.Stderr, "  testicle --debug --dir ./my-tests  # Debug mode with custom directory\n")
-		fmt.Fprintf(os.Stderr, "  testicle --config custom.yaml      # Use custom configuration\n\n")
+		fmt.Fprintf(os.Stderr, "  testicle --config custom.yaml      # Use custom configuration\n")
+		fmt.Fprintf(os.Stderr, "  testicle init                      # Generate a commented testicle.yaml\n")
+		fmt.Fprintf(os.Stderr, "  testicle clean --all               # Remove every saved run\n")
+		fmt.Fprintf(os.Stderr, "  testicle lsp                       # Editor protocol server for inline test status\n\n")
+		fmt.Fprintf(os.Stderr, "Exit Codes:\n")
+		fmt.Fprintf(os.Stderr, "  %d  All tests passed\n", testicle.ExitCodeOK)
+		fmt.Fprintf(os.Stderr, "  %d  One or more tests failed\n", testicle.ExitCodeTestsFailed)
+		fmt.Fprintf(os.Stderr, "  %d  Tests did not compile\n", testicle.ExitCodeBuildFailed)
+		fmt.Fprintf(os.Stderr, "  %d  go vet reported issues\n", testicle.ExitCodeVetFailed)
+		fmt.Fprintf(os.Stderr, "  %d  testicle could not run (configuration or discovery error)\n", testicle.ExitCodeError)
+		fmt.Fprintf(os.Stderr, "  %d  Tests passed, but slow_tests with action: fail flagged slow tests\n\n", testicle.ExitCodeSlowTests)
 		fmt.Fprintf(os.Stderr, "For complete documentation, see: https://github.com/nzions/sharedgolibs/tree/master/pkg/testicle/doc\n")
 	}
 
@@ -114,6 +243,155 @@ func parseFlags() *Config {
 	return config
 }
 
+// runInit handles `testicle init`: it scans the test directory and writes a
+// commented starter testicle.yaml
+func runInit(args []string) {
+	flags := flag.NewFlagSet("init", flag.ExitOnError)
+	dir := flags.String("dir", getDefaultTestDir(), "Directory to scan and write testicle.yaml into")
+	force := flags.Bool("force", false, "Overwrite an existing testicle.yaml")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: testicle init [--dir <path>] [--force]\n\n")
+		fmt.Fprintf(os.Stderr, "Scans the Go packages and non-Go test suites under --dir and writes a\n")
+		fmt.Fprintf(os.Stderr, "commented starter %s there.\n\n", testicle.DefaultConfigFile)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	path, err := testicle.InitConfig(context.Background(), *dir, *force)
+	if err != nil {
+		log.Fatalf("❌ testicle init failed: %v", err)
+	}
+	fmt.Printf("✅ Wrote %s\n", path)
+}
+
+// runClean handles `testicle clean`: it applies the retention policy to the
+// saved run artifacts now, or removes them all
+func runClean(args []string) {
+	flags := flag.NewFlagSet("clean", flag.ExitOnError)
+	dir := flags.String("dir", getDefaultTestDir(), "Test directory the artifacts directory is relative to")
+	configFile := flags.String("config", testicle.DefaultConfigFile, "Configuration file with artifacts_dir and retention")
+	artifactsDir := flags.String("artifacts-dir", "", "Artifacts directory (default: artifacts_dir, or "+testicle.DefaultArtifactsDir+")")
+	all := flags.Bool("all", false, "Remove every saved run instead of applying the retention policy")
+	cache := flags.Bool("cache", false, "Also remove the cached test binaries of --warm")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: testicle clean [--all] [--cache] [--artifacts-dir <dir>]\n\n")
+		fmt.Fprintf(os.Stderr, "Removes saved runs past the retention policy (max_runs, max_age, max_disk\n")
+		fmt.Fprintf(os.Stderr, "in %s), or every run with --all.\n\n", testicle.DefaultConfigFile)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	config := &testicle.Config{Dir: *dir, ConfigFile: *configFile, ArtifactsDir: *artifactsDir}
+	store, err := testicle.OpenArtifactStore(config)
+	if err != nil {
+		log.Fatalf("❌ testicle clean failed: %v", err)
+	}
+
+	var removed []testicle.ArtifactRun
+	if *all {
+		removed, err = store.Clean()
+	} else {
+		removed, err = store.Prune(time.Now())
+	}
+	if err != nil {
+		log.Fatalf("❌ testicle clean failed: %v", err)
+	}
+
+	var freed int64
+	for _, run := range removed {
+		freed += run.Size
+	}
+	runs, size, _ := store.Usage()
+	fmt.Printf("🧹 Removed %d run(s), freed %s; %d run(s) (%s) kept in %s\n",
+		len(removed), testicle.FormatByteSize(freed), runs, testicle.FormatByteSize(size), store.Dir())
+
+	if *cache {
+		cacheDir := config.CacheDir
+		if cacheDir == "" {
+			cacheDir = testicle.DefaultBuildCacheDir()
+		}
+		if err := os.RemoveAll(cacheDir); err != nil {
+			log.Fatalf("❌ Removing the build cache failed: %v", err)
+		}
+		fmt.Printf("🧹 Removed the build cache %s\n", cacheDir)
+	}
+}
+
+// runLSP handles `testicle lsp`: it serves per-file test status, code
+// lenses, and failures to an editor over stdin and stdout until the editor
+// exits
+func runLSP(args []string) {
+	flags := flag.NewFlagSet("lsp", flag.ExitOnError)
+	dir := flags.String("dir", getDefaultTestDir(), "Test directory")
+	debug := flags.Bool("debug", false, "Enable debug output on stderr")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: testicle lsp [--dir <path>] [--debug]\n\n")
+		fmt.Fprintf(os.Stderr, "Speaks JSON-RPC with LSP framing on stdin and stdout. Saving a file re-runs\n")
+		fmt.Fprintf(os.Stderr, "the tests of its package and of the packages importing it. Logs go to stderr.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	server, err := testicle.NewLSPServer(&testicle.Config{Dir: *dir, Debug: *debug}, os.Stderr)
+	if err != nil {
+		log.Fatalf("❌ testicle lsp failed: %v", err)
+	}
+	if err := server.Serve(context.Background(), os.Stdin, os.Stdout); err != nil {
+		log.Fatalf("❌ testicle lsp failed: %v", err)
+	}
+}
+
+// printTree prints the discovered test tree, one package per block
+func printTree(tree *testicle.TestTree) {
+	// A workspace lists each module above its packages
+	depth := 0
+	switch {
+	case tree.Workspace != "":
+		fmt.Printf("🗂️  %s\n", tree.Workspace)
+		depth = 1
+	case tree.Module != "":
+		fmt.Printf("🌳 %s\n", tree.Module)
+	}
+	indent := strings.Repeat("  ", depth)
+	for i, pkg := range tree.Packages {
+		if tree.Workspace != "" && (i == 0 || tree.Packages[i-1].Module != pkg.Module) {
+			module := pkg.Module
+			if module == "" {
+				module = "(no module)"
+			}
+			fmt.Printf("🌳 %s\n", module)
+		}
+		fmt.Printf("%s📦 %s\n", indent, pkg.ImportPath)
+		for _, test := range pkg.Tests {
+			printTestNode(test, depth+1)
+		}
+	}
+	summary := fmt.Sprintf("%d package(s), %d test(s), %d subtest(s)", len(tree.Packages), tree.TestCount(), tree.SubtestCount())
+	if tree.Workspace != "" {
+		summary = fmt.Sprintf("%d module(s), %s", len(tree.Modules()), summary)
+	}
+	fmt.Printf("\n%s\n", summary)
+}
+
+// printTestNode prints a test and its subtests indented by depth
+func printTestNode(node *testicle.TestNode, depth int) {
+	indent := strings.Repeat("  ", depth)
+	line := indent + node.Name
+	if node.Kind != testicle.TestKindTest {
+		line += fmt.Sprintf(" [%s]", node.Kind)
+	}
+	if len(node.Tags) > 0 {
+		line += " @" + strings.Join(node.Tags, " @")
+	}
+	if node.Dynamic {
+		line += " (+ dynamic subtests)"
+	}
+	fmt.Println(line)
+	for _, sub := range node.Subtests {
+		printTestNode(sub, depth+1)
+	}
+}
+
 // getDefaultTestDir returns the appropriate default test directory
 // based on whether we're running in a container or locally
 func getDefaultTestDir() string {
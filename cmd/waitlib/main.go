@@ -4,7 +4,7 @@ package main
 
 import "github.com/nzions/sharedgolibs/pkg/waitlib"
 
-const version = "v1.0.0"
+const version = "v1.3.0"
 
 func main() {
 	waitlib.Run(version)
@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: CC0-1.0
+
+// Package fileutil holds the file locking and atomic write helpers shared by
+// the packages that keep state in files other processes also update.
+package fileutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LockPath takes an exclusive lock on the lock file at path, creating it if
+// needed and blocking until other holders release it, and returns the
+// function that releases it
+func LockPath(path string) (func(), error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	return func() {
+		unlockFile(file)
+		file.Close()
+	}, nil
+}
+
+// CreateTemp creates a uniquely named temporary file next to path, to be
+// renamed over it once complete
+func CreateTemp(path string) (*os.File, error) {
+	return os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+}
+
+// WriteAtomic writes data to a temporary file in the same directory and
+// renames it into place so readers never observe a partially written file
+func WriteAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := CreateTemp(path)
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestWriteAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+
+	if err := WriteAtomic(path, []byte("one"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteAtomic(path, []byte("two"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "two" {
+		t.Errorf("Expected the second write, got %q (%v)", data, err)
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected no temporary files left behind, got %v", entries)
+	}
+}
+
+func TestLockPath(t *testing.T) {
+	dir := t.TempDir()
+	lock := filepath.Join(dir, ".lock")
+	path := filepath.Join(dir, "counter")
+	if err := os.WriteFile(path, []byte("0"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each holder increments the counter file under the lock; a lost update
+	// means the lock did not exclude the others
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				unlock, err := LockPath(lock)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				data, _ := os.ReadFile(path)
+				value, _ := strconv.Atoi(string(data))
+				if err := WriteAtomic(path, []byte(strconv.Itoa(value+1)), 0644); err != nil {
+					t.Error(err)
+				}
+				unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "200" {
+		t.Errorf("Expected 200 increments, got %s", data)
+	}
+}
@@ -0,0 +1,46 @@
+//go:build !unix
+
+// SPDX-License-Identifier: CC0-1.0
+
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// locks stand in for flock(2), which this platform lacks: one mutex per lock
+// file only serializes the holders within one process
+var (
+	locksMutex sync.Mutex
+	locks      = make(map[string]*sync.Mutex)
+)
+
+// pathLock returns the mutex for the lock file
+func pathLock(file *os.File) *sync.Mutex {
+	path, err := filepath.Abs(file.Name())
+	if err != nil {
+		path = file.Name()
+	}
+
+	locksMutex.Lock()
+	defer locksMutex.Unlock()
+	lock, exists := locks[path]
+	if !exists {
+		lock = &sync.Mutex{}
+		locks[path] = lock
+	}
+	return lock
+}
+
+// lockFile takes the process-wide lock for file
+func lockFile(file *os.File) error {
+	pathLock(file).Lock()
+	return nil
+}
+
+// unlockFile releases the lock taken by lockFile
+func unlockFile(file *os.File) {
+	pathLock(file).Unlock()
+}
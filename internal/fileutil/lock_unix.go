@@ -0,0 +1,26 @@
+//go:build unix
+
+// SPDX-License-Identifier: CC0-1.0
+
+package fileutil
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive flock(2) on file. The kernel releases it if
+// the process dies, so a crashed holder never leaves the file locked.
+func lockFile(file *os.File) error {
+	for {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+// unlockFile releases the lock taken by lockFile
+func unlockFile(file *os.File) {
+	syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
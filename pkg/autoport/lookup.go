@@ -0,0 +1,133 @@
+package autoport
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Lookup helpers over the generated configuration. This file is maintained
+// by hand and is not overwritten by servicemanager -generate.
+
+// GetServiceByAlias returns the service that has a network alias, such as
+// "storage.googleapis.com". Aliases are hostnames, so the match ignores case.
+func GetServiceByAlias(alias string) (ServiceConfig, bool) {
+	return serviceByAlias(defaultConfig.Services, alias)
+}
+
+// GetHealthURL returns the health check URL of a service on localhost and
+// its external port, e.g. "https://localhost:8082/health". It returns false
+// for unknown services and services without a health path.
+func GetHealthURL(name string) (string, bool) {
+	service, exists := defaultConfig.Services[name]
+	if !exists || service.HealthPath == "" {
+		return "", false
+	}
+	return HealthURL(service), true
+}
+
+// GetDependencyOrder returns every service name ordered so each service
+// comes after the services it depends on. Services without an ordering
+// constraint between them are sorted by name; dependencies outside the
+// configuration are ignored. It returns an error if dependencies form a
+// cycle.
+func GetDependencyOrder() ([]string, error) {
+	return DependencyOrder(defaultConfig.Services)
+}
+
+// serviceByAlias finds the service with an alias; the first by name wins
+// if several share it
+func serviceByAlias(services map[string]ServiceConfig, alias string) (ServiceConfig, bool) {
+	for _, name := range sortedNames(services) {
+		service := services[name]
+		for _, candidate := range service.Aliases {
+			if strings.EqualFold(candidate, alias) {
+				return service, true
+			}
+		}
+	}
+	return ServiceConfig{}, false
+}
+
+// HealthURL composes the health URL of a service from its health path and
+// external port, for configurations other than the generated one. The path
+// may be a full URL ("http://localhost/health"), which gets the external port
+// unless it names one, or a bare path served on localhost with the scheme
+// following IsSecure. It returns "" for a service without a health path.
+func HealthURL(service ServiceConfig) string {
+	if service.HealthPath == "" {
+		return ""
+	}
+	if u, err := url.Parse(service.HealthPath); err == nil && u.Scheme != "" && u.Host != "" {
+		if u.Port() == "" && service.ExternalPort > 0 {
+			u.Host = net.JoinHostPort(u.Hostname(), strconv.Itoa(service.ExternalPort))
+		}
+		return u.String()
+	}
+
+	scheme := "http"
+	if service.IsSecure {
+		scheme = "https"
+	}
+	host := "localhost"
+	if service.ExternalPort > 0 {
+		host = net.JoinHostPort(host, strconv.Itoa(service.ExternalPort))
+	}
+	path := service.HealthPath
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return scheme + "://" + host + path
+}
+
+// DependencyOrder returns the names of services ordered so each service
+// comes after the services it depends on, for configurations other than the
+// generated one. Ties are sorted by name and dependencies outside services
+// are ignored. It returns an error if dependencies form a cycle.
+func DependencyOrder(services map[string]ServiceConfig) ([]string, error) {
+	ordered := make([]string, 0, len(services))
+	state := make(map[string]int) // 1 = visiting, 2 = done
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		service, exists := services[name]
+		if !exists || state[name] == 2 {
+			return nil
+		}
+		path = append(path, name)
+		if state[name] == 1 {
+			return fmt.Errorf("dependency cycle: %s", strings.Join(path, " -> "))
+		}
+		state[name] = 1
+
+		dependencies := append([]string(nil), service.DependsOn...)
+		sort.Strings(dependencies)
+		for _, dependency := range dependencies {
+			if err := visit(dependency, path); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		ordered = append(ordered, name)
+		return nil
+	}
+
+	for _, name := range sortedNames(services) {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// sortedNames returns the service names in name order
+func sortedNames(services map[string]ServiceConfig) []string {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
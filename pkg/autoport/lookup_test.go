@@ -0,0 +1,81 @@
+package autoport
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetServiceByAlias(t *testing.T) {
+	service, found := GetServiceByAlias("Storage.GoogleAPIs.com")
+	if !found || service.Name != "gcs" {
+		t.Errorf("Expected gcs, got %+v (found=%v)", service, found)
+	}
+	if _, found := GetServiceByAlias("gcs"); found {
+		t.Error("Expected a service name not to match as an alias")
+	}
+}
+
+func TestGetHealthURL(t *testing.T) {
+	if url, found := GetHealthURL("gcs"); !found || url != "https://localhost:8082/health" {
+		t.Errorf("Unexpected gcs health URL %q (found=%v)", url, found)
+	}
+	if url, found := GetHealthURL("metadata"); !found || url != "http://localhost:8088/" {
+		t.Errorf("Unexpected metadata health URL %q (found=%v)", url, found)
+	}
+	if _, found := GetHealthURL("nonexistent"); found {
+		t.Error("Expected no health URL for an unknown service")
+	}
+
+	tests := map[string]ServiceConfig{
+		"https://localhost:9443/ready?full=1": {HealthPath: "https://localhost/ready?full=1", ExternalPort: 9443},
+		"https://localhost:9443/health":       {HealthPath: "/health", ExternalPort: 9443, IsSecure: true},
+		"http://localhost:9080/status":        {HealthPath: "status", ExternalPort: 9080},
+		"https://localhost:9443/ready":        {HealthPath: "https://localhost:9443/ready", ExternalPort: 8081},
+		"http://dev-host:8088":                {HealthPath: "http://dev-host", ExternalPort: 8088},
+		"":                                    {ExternalPort: 8088},
+	}
+	for want, service := range tests {
+		if got := HealthURL(service); got != want {
+			t.Errorf("HealthURL(%q) = %q, want %q", service.HealthPath, got, want)
+		}
+	}
+}
+
+func TestGetDependencyOrder(t *testing.T) {
+	order, err := GetDependencyOrder()
+	if err != nil {
+		t.Fatalf("GetDependencyOrder failed: %v", err)
+	}
+	if len(order) != len(defaultConfig.Services) {
+		t.Fatalf("Expected %d services, got %v", len(defaultConfig.Services), order)
+	}
+
+	position := make(map[string]int, len(order))
+	for i, name := range order {
+		position[name] = i
+	}
+	for name, service := range defaultConfig.Services {
+		for _, dependency := range service.DependsOn {
+			if _, known := position[dependency]; known && position[dependency] > position[name] {
+				t.Errorf("%s is ordered before its dependency %s", name, dependency)
+			}
+		}
+	}
+}
+
+func TestDependencyOrderCycle(t *testing.T) {
+	services := map[string]ServiceConfig{
+		"a": {Name: "a", DependsOn: []string{"b"}},
+		"b": {Name: "b", DependsOn: []string{"c", "external"}},
+		"c": {Name: "c", DependsOn: []string{"a"}},
+	}
+	if _, err := DependencyOrder(services); err == nil || !strings.Contains(err.Error(), "a -> b -> c -> a") {
+		t.Errorf("Expected the cycle to be reported, got %v", err)
+	}
+
+	delete(services, "c")
+	order, err := DependencyOrder(services)
+	if err != nil || strings.Join(order, ",") != "b,a" {
+		t.Errorf("Expected b,a ignoring the external dependency, got %v (%v)", order, err)
+	}
+}
@@ -8,7 +8,7 @@ import (
 	"strings"
 )
 
-const Version = "0.1.0"
+const Version = "0.3.0"
 
 // BinaryType represents the type of binary file
 type BinaryType int
@@ -30,6 +30,11 @@ func (bt BinaryType) String() string {
 	}
 }
 
+// MarshalText encodes the type by name, e.g. "ELF", in JSON reports
+func (bt BinaryType) MarshalText() ([]byte, error) {
+	return []byte(bt.String()), nil
+}
+
 // BinaryInfo contains information about a detected binary
 type BinaryInfo struct {
 	Path string
@@ -109,15 +114,38 @@ func isExecutable(filePath string) bool {
 	return info.Mode()&0111 != 0
 }
 
+// skipExtensions are common text file extensions that are never binaries
+var skipExtensions = []string{".txt", ".md", ".go", ".py", ".js", ".html", ".css", ".json", ".yml", ".yaml", ".xml", ".log"}
+
+// isCandidate reports whether a file could be a binary worth reading: it
+// has no text file extension and is executable
+func isCandidate(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, skipExt := range skipExtensions {
+		if ext == skipExt {
+			return false
+		}
+	}
+	return isExecutable(path)
+}
+
 // FindBinaries searches for Mach-O and ELF binaries in the configured directory
 func (bc *BinaryCleaner) FindBinaries() ([]BinaryInfo, error) {
+	return bc.walk(func(path string, err error) {
+		if bc.config.Verbose {
+			fmt.Printf("Warning: Error accessing %s: %v\n", path, err)
+		}
+	})
+}
+
+// walk finds binaries in the configured directory, passing files that could
+// not be accessed or read to onError and continuing
+func (bc *BinaryCleaner) walk(onError func(path string, err error)) ([]BinaryInfo, error) {
 	var binaries []BinaryInfo
 
 	walkFunc := func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			if bc.config.Verbose {
-				fmt.Printf("Warning: Error accessing %s: %v\n", path, err)
-			}
+			onError(path, err)
 			return nil // Continue walking despite errors
 		}
 
@@ -130,26 +158,14 @@ func (bc *BinaryCleaner) FindBinaries() ([]BinaryInfo, error) {
 			return nil
 		}
 
-		// Skip files that are obviously not binaries (common extensions)
-		ext := strings.ToLower(filepath.Ext(path))
-		skipExtensions := []string{".txt", ".md", ".go", ".py", ".js", ".html", ".css", ".json", ".yml", ".yaml", ".xml", ".log"}
-		for _, skipExt := range skipExtensions {
-			if ext == skipExt {
-				return nil
-			}
-		}
-
-		// Check if file is executable (optimization)
-		if !isExecutable(path) {
+		if !isCandidate(path) {
 			return nil
 		}
 
 		// Detect binary type
 		binaryType, err := detectBinaryType(path)
 		if err != nil {
-			if bc.config.Verbose {
-				fmt.Printf("Warning: Error reading %s: %v\n", path, err)
-			}
+			onError(path, err)
 			return nil
 		}
 
@@ -0,0 +1,133 @@
+package binarycleaner
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Action is what happened to a file in a Report
+type Action string
+
+const (
+	ActionFound       Action = "found"        // Listed by FindReport
+	ActionWouldRemove Action = "would_remove" // Dry run
+	ActionRemoved     Action = "removed"
+	ActionFailed      Action = "failed"  // Removal failed
+	ActionSkipped     Action = "skipped" // Could not be accessed or read
+)
+
+// FileResult is the outcome for a single file
+type FileResult struct {
+	Path   string     `json:"path"`
+	Type   BinaryType `json:"type"`
+	Size   int64      `json:"size"`
+	Action Action     `json:"action"`
+	Error  string     `json:"error,omitempty"`
+}
+
+// Report is a structured record of a search or cleanup, for logging and
+// auditing. It holds one FileResult per binary and per skipped file.
+type Report struct {
+	Directory string       `json:"directory"`
+	DryRun    bool         `json:"dry_run"`
+	Recursive bool         `json:"recursive"`
+	Started   time.Time    `json:"started"`
+	Duration  string       `json:"duration"`
+	Files     []FileResult `json:"files"`
+
+	Binaries    int   `json:"binaries"`
+	Removed     int   `json:"removed"`
+	Failed      int   `json:"failed"`
+	Skipped     int   `json:"skipped"`
+	TotalSize   int64 `json:"total_size"`
+	RemovedSize int64 `json:"removed_size"`
+}
+
+// FindReport searches for binaries like FindBinaries, without printing, and
+// returns them as a Report with ActionFound
+func (bc *BinaryCleaner) FindReport() (*Report, error) {
+	return bc.report(func(binary BinaryInfo) FileResult {
+		return FileResult{Path: binary.Path, Type: binary.Type, Size: binary.Size, Action: ActionFound}
+	})
+}
+
+// CleanReport finds and removes binaries like Clean, without printing. A
+// failed removal is recorded in the report and the rest are still removed;
+// the error is only for a directory that can't be walked.
+func (bc *BinaryCleaner) CleanReport() (*Report, error) {
+	return bc.report(bc.removeBinary)
+}
+
+// removeBinary removes one binary, or only reports it in dry run mode
+func (bc *BinaryCleaner) removeBinary(binary BinaryInfo) FileResult {
+	result := FileResult{Path: binary.Path, Type: binary.Type, Size: binary.Size}
+	if bc.config.DryRun {
+		result.Action = ActionWouldRemove
+		return result
+	}
+	if err := os.Remove(binary.Path); err != nil {
+		result.Action = ActionFailed
+		result.Error = err.Error()
+		return result
+	}
+	result.Action = ActionRemoved
+	return result
+}
+
+// report walks the directory and records handle's result for each binary
+func (bc *BinaryCleaner) report(handle func(BinaryInfo) FileResult) (*Report, error) {
+	report := &Report{
+		Directory: bc.config.Directory,
+		DryRun:    bc.config.DryRun,
+		Recursive: bc.config.Recursive,
+		Started:   time.Now().UTC(),
+		Files:     []FileResult{},
+	}
+
+	binaries, err := bc.walk(func(path string, err error) {
+		report.Files = append(report.Files, FileResult{Path: path, Action: ActionSkipped, Error: err.Error()})
+		report.Skipped++
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, binary := range binaries {
+		result := handle(binary)
+		report.Files = append(report.Files, result)
+		report.Binaries++
+		report.TotalSize += binary.Size
+		switch result.Action {
+		case ActionRemoved:
+			report.Removed++
+			report.RemovedSize += binary.Size
+		case ActionFailed:
+			report.Failed++
+		}
+	}
+
+	report.Duration = time.Since(report.Started).String()
+	return report, nil
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteCSV writes one row per file with a header row
+func (r *Report) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"path", "type", "size", "action", "error"})
+	for _, file := range r.Files {
+		writer.Write([]string{file.Path, file.Type.String(), strconv.FormatInt(file.Size, 10), string(file.Action), file.Error})
+	}
+	writer.Flush()
+	return writer.Error()
+}
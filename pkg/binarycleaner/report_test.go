@@ -0,0 +1,117 @@
+package binarycleaner
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeReportFixtures creates an ELF binary, a Mach-O binary, and a text file
+func writeReportFixtures(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"elf_binary":   {0x7f, 'E', 'L', 'F', 0x02, 0x01, 0x01, 0x00},
+		"macho_binary": {0xcf, 0xfa, 0xed, 0xfe, 0x07, 0x00, 0x00, 0x01},
+		"script.sh":    []byte("#!/bin/sh\necho hi\n"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFindReport(t *testing.T) {
+	dir := writeReportFixtures(t)
+	report, err := New(Config{Directory: dir}).FindReport()
+	if err != nil {
+		t.Fatalf("FindReport() error = %v", err)
+	}
+
+	if report.Binaries != 2 || report.TotalSize != 16 || report.Removed != 0 {
+		t.Errorf("Unexpected totals: %+v", report)
+	}
+	for _, file := range report.Files {
+		if file.Action != ActionFound {
+			t.Errorf("Expected %s to be found, got %s", file.Path, file.Action)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "elf_binary")); err != nil {
+		t.Error("FindReport() removed a file")
+	}
+}
+
+func TestCleanReport(t *testing.T) {
+	dir := writeReportFixtures(t)
+
+	report, err := New(Config{Directory: dir, DryRun: true}).CleanReport()
+	if err != nil {
+		t.Fatalf("CleanReport() dry run error = %v", err)
+	}
+	if report.Binaries != 2 || report.Removed != 0 || !report.DryRun {
+		t.Errorf("Unexpected dry run totals: %+v", report)
+	}
+	for _, file := range report.Files {
+		if file.Action != ActionWouldRemove {
+			t.Errorf("Expected %s to be would_remove, got %s", file.Path, file.Action)
+		}
+	}
+
+	report, err = New(Config{Directory: dir}).CleanReport()
+	if err != nil {
+		t.Fatalf("CleanReport() error = %v", err)
+	}
+	if report.Removed != 2 || report.RemovedSize != 16 || report.Failed != 0 {
+		t.Errorf("Unexpected totals: %+v", report)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "elf_binary")); !os.IsNotExist(err) {
+		t.Error("Binary was not removed")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "script.sh")); err != nil {
+		t.Error("Script was removed")
+	}
+}
+
+func TestReportFormats(t *testing.T) {
+	report := &Report{
+		Directory: "/build",
+		Files: []FileResult{
+			{Path: "/build/app", Type: ELF, Size: 1024, Action: ActionRemoved},
+			{Path: "/build/tool, v2", Type: MachO, Size: 2048, Action: ActionFailed, Error: "permission denied"},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Files []struct {
+			Type   string `json:"type"`
+			Action string `json:"action"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, buf.String())
+	}
+	if len(decoded.Files) != 2 || decoded.Files[0].Type != "ELF" || decoded.Files[1].Action != "failed" {
+		t.Errorf("Unexpected JSON: %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV: %v", err)
+	}
+	if len(rows) != 3 || rows[0][0] != "path" || rows[2][0] != "/build/tool, v2" || rows[2][1] != "Mach-O" || rows[2][4] != "permission denied" {
+		t.Errorf("Unexpected CSV rows: %q", rows)
+	}
+}
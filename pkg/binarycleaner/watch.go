@@ -0,0 +1,144 @@
+package binarycleaner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultGracePeriod is how long Watch leaves a new binary alone after its
+// last change, so a freshly built binary can still be run
+const DefaultGracePeriod = 30 * time.Second
+
+// WatchOptions configures Watch
+type WatchOptions struct {
+	Directories []string         // Directories to watch (default Config.Directory); subdirectories too if Config.Recursive
+	GracePeriod time.Duration    // Default DefaultGracePeriod
+	OnResult    func(FileResult) // Called for each expired binary, with ActionRemoved, ActionWouldRemove, ActionFailed, or ActionSkipped
+}
+
+// Watch runs until ctx is cancelled, removing binaries that appear in the
+// watched directories once they have been unchanged for the grace period.
+// Binaries are detected as in FindBinaries; in dry run mode they are only
+// reported. Binaries that already exist are left alone until they change.
+func (bc *BinaryCleaner) Watch(ctx context.Context, opts WatchOptions) error {
+	directories := opts.Directories
+	if len(directories) == 0 {
+		directories = []string{bc.config.Directory}
+	}
+	grace := opts.GracePeriod
+	if grace <= 0 {
+		grace = DefaultGracePeriod
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	for _, dir := range directories {
+		if err := bc.addWatch(watcher, dir); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+	}
+
+	// Files wait until their deadline; a change pushes the deadline back
+	deadlines := make(map[string]time.Time)
+	due := make(chan string)
+	arm := func(path string, after time.Duration) {
+		time.AfterFunc(after, func() {
+			select {
+			case due <- path:
+			case <-ctx.Done():
+			}
+		})
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+				delete(deadlines, event.Name)
+				continue
+			}
+			info, err := os.Stat(event.Name)
+			if err != nil {
+				continue
+			}
+			if info.IsDir() {
+				if event.Has(fsnotify.Create) && bc.config.Recursive {
+					bc.addWatch(watcher, event.Name)
+				}
+				continue
+			}
+
+			if _, pending := deadlines[event.Name]; !pending {
+				arm(event.Name, grace)
+			}
+			deadlines[event.Name] = time.Now().Add(grace)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			if bc.config.Verbose {
+				fmt.Printf("Warning: Watch error: %v\n", err)
+			}
+
+		case path := <-due:
+			deadline, pending := deadlines[path]
+			if !pending {
+				continue
+			}
+			if wait := time.Until(deadline); wait > 0 {
+				arm(path, wait)
+				continue
+			}
+			delete(deadlines, path)
+
+			if result, ok := bc.expire(path); ok && opts.OnResult != nil {
+				opts.OnResult(result)
+			}
+		}
+	}
+}
+
+// addWatch watches dir and, if recursive, its subdirectories
+func (bc *BinaryCleaner) addWatch(watcher *fsnotify.Watcher, dir string) error {
+	if !bc.config.Recursive {
+		return watcher.Add(dir)
+	}
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		return watcher.Add(path)
+	})
+}
+
+// expire removes a file whose grace period is over if it is still a binary
+func (bc *BinaryCleaner) expire(path string) (FileResult, bool) {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() || !isCandidate(path) {
+		return FileResult{}, false
+	}
+	binaryType, err := detectBinaryType(path)
+	if err != nil {
+		return FileResult{Path: path, Action: ActionSkipped, Error: err.Error()}, true
+	}
+	if binaryType == Unknown {
+		return FileResult{}, false
+	}
+	return bc.removeBinary(BinaryInfo{Path: path, Type: binaryType, Size: info.Size()}), true
+}
@@ -0,0 +1,96 @@
+package binarycleaner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startWatch runs Watch in the background and collects its results
+func startWatch(t *testing.T, config Config, grace time.Duration) <-chan FileResult {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	results := make(chan FileResult, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- New(config).Watch(ctx, WatchOptions{
+			GracePeriod: grace,
+			OnResult:    func(result FileResult) { results <- result },
+		})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Watch() error = %v", err)
+		}
+	})
+	time.Sleep(50 * time.Millisecond) // Let the watcher start
+	return results
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing_binary")
+	if err := os.WriteFile(existing, []byte{0x7f, 'E', 'L', 'F'}, 0755); err != nil {
+		t.Fatal(err)
+	}
+	results := startWatch(t, Config{Directory: dir, Recursive: true}, 100*time.Millisecond)
+
+	sub := filepath.Join(dir, "cmd")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond) // Let the new directory be watched
+	binary := filepath.Join(sub, "app")
+	if err := os.WriteFile(binary, []byte{0x7f, 'E', 'L', 'F', 0x02}, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("text"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case result := <-results:
+		if result.Path != binary || result.Action != ActionRemoved || result.Type != ELF {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the new binary to be removed")
+	}
+	if _, err := os.Stat(binary); !os.IsNotExist(err) {
+		t.Error("Binary was not removed")
+	}
+	if _, err := os.Stat(existing); err != nil {
+		t.Error("Existing binary was removed")
+	}
+
+	select {
+	case result := <-results:
+		t.Errorf("Unexpected result: %+v", result)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestWatch_DryRun(t *testing.T) {
+	dir := t.TempDir()
+	results := startWatch(t, Config{Directory: dir, DryRun: true}, 100*time.Millisecond)
+
+	binary := filepath.Join(dir, "app")
+	if err := os.WriteFile(binary, []byte{0xcf, 0xfa, 0xed, 0xfe}, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case result := <-results:
+		if result.Action != ActionWouldRemove {
+			t.Errorf("Expected would_remove, got %+v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the new binary to be reported")
+	}
+	if _, err := os.Stat(binary); err != nil {
+		t.Error("Binary was removed in dry run mode")
+	}
+}
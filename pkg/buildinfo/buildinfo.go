@@ -0,0 +1,179 @@
+// SPDX-License-Identifier: CC0-1.0
+
+// Package buildinfo reports the version, git commit, build date, and library
+// package versions of a binary in one consistent format: a single line for
+// --version, key=value lines for --keys (which envinfo scrapes from
+// containers), and JSON for HTTP services.
+//
+// Example:
+//
+//	info := buildinfo.New("mytool", version).WithPackage("ca", ca.Version)
+//	if *keysFlag {
+//	    fmt.Print(info.Keys())
+//	    return
+//	}
+//	if *versionFlag {
+//	    fmt.Println(info)
+//	    return
+//	}
+//	http.Handle("/version", buildinfo.Handler(info))
+//
+// The commit and build date come from the VCS information Go embeds in
+// binaries built from a git checkout. They can be overridden at link time:
+//
+//	go build -ldflags "-X github.com/nzions/sharedgolibs/pkg/buildinfo.Commit=$(git rev-parse HEAD) \
+//	    -X github.com/nzions/sharedgolibs/pkg/buildinfo.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package buildinfo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+// Version is the current version of the buildinfo package
+const Version = "0.1.0"
+
+// Set with -ldflags -X to override the embedded VCS information
+var (
+	Commit string // Full git commit hash
+	Date   string // Build date, RFC 3339
+)
+
+// shortCommitLength is how much of the commit hash String shows
+const shortCommitLength = 7
+
+// Info describes a binary
+type Info struct {
+	Name      string            `json:"name"`
+	Version   string            `json:"version"`
+	Commit    string            `json:"commit,omitempty"`
+	Modified  bool              `json:"modified,omitempty"` // Built from a checkout with uncommitted changes
+	BuildDate string            `json:"build_date,omitempty"`
+	GoVersion string            `json:"go_version"`
+	Platform  string            `json:"platform"`           // GOOS/GOARCH
+	Packages  map[string]string `json:"packages,omitempty"` // sharedgolibs package versions, by package name
+}
+
+// New returns the build information of the running binary
+func New(name, version string) Info {
+	info := Info{
+		Name:      name,
+		Version:   version,
+		Commit:    Commit,
+		BuildDate: Date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	return info
+}
+
+// WithPackage returns a copy of info that also reports the version of a
+// library package the binary is built on
+func (i Info) WithPackage(name, version string) Info {
+	packages := make(map[string]string, len(i.Packages)+1)
+	for k, v := range i.Packages {
+		packages[k] = v
+	}
+	packages[name] = version
+	i.Packages = packages
+	return i
+}
+
+// String renders the single --version line, e.g.
+// "mytool 1.2.0 (commit 1a2b3c4, built 2025-01-01T12:00:00Z, go1.24.3 linux/amd64)"
+func (i Info) String() string {
+	var details []string
+	if i.Commit != "" {
+		commit := i.Commit
+		if len(commit) > shortCommitLength {
+			commit = commit[:shortCommitLength]
+		}
+		if i.Modified {
+			commit += "-dirty"
+		}
+		details = append(details, "commit "+commit)
+	}
+	if i.BuildDate != "" {
+		details = append(details, "built "+i.BuildDate)
+	}
+	details = append(details, i.GoVersion+" "+i.Platform)
+
+	return fmt.Sprintf("%s %s (%s)", i.Name, i.Version, strings.Join(details, ", "))
+}
+
+// Keys renders the machine-readable --keys output: one key=value per line,
+// sorted by key, with package versions as package.<name>=<version>
+func (i Info) Keys() string {
+	keys := map[string]string{
+		"name":       i.Name,
+		"version":    i.Version,
+		"commit":     i.Commit,
+		"modified":   fmt.Sprint(i.Modified),
+		"build_date": i.BuildDate,
+		"go_version": i.GoVersion,
+		"platform":   i.Platform,
+	}
+	for name, version := range i.Packages {
+		keys["package."+name] = version
+	}
+
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s=%s\n", name, keys[name])
+	}
+	return b.String()
+}
+
+// ParseKeys parses --keys output back into a map, ignoring lines that are
+// not key=value
+func ParseKeys(output string) map[string]string {
+	keys := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if ok && key != "" {
+			keys[key] = value
+		}
+	}
+	return keys
+}
+
+// Handler serves info as JSON, for mounting at e.g. /version
+func Handler(info Info) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+	})
+}
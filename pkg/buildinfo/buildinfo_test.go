@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package buildinfo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	info := Info{
+		Name:      "mytool",
+		Version:   "1.2.0",
+		Commit:    "1a2b3c4d5e6f7a8b",
+		Modified:  true,
+		BuildDate: "2025-01-01T12:00:00Z",
+		GoVersion: "go1.24.3",
+		Platform:  "linux/amd64",
+	}
+	expected := "mytool 1.2.0 (commit 1a2b3c4-dirty, built 2025-01-01T12:00:00Z, go1.24.3 linux/amd64)"
+	if got := info.String(); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	info = Info{Name: "mytool", Version: "1.2.0", GoVersion: "go1.24.3", Platform: "linux/amd64"}
+	if got := info.String(); got != "mytool 1.2.0 (go1.24.3 linux/amd64)" {
+		t.Errorf("Unexpected string without VCS information: %q", got)
+	}
+}
+
+func TestKeysRoundTrip(t *testing.T) {
+	info := New("mytool", "1.2.0").WithPackage("ca", "v2.12.0").WithPackage("util", "0.1.0")
+
+	output := info.Keys()
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i-1] > lines[i] {
+			t.Errorf("Keys not sorted: %q before %q", lines[i-1], lines[i])
+		}
+	}
+
+	keys := ParseKeys("some banner\n" + output)
+	expected := map[string]string{
+		"name":         "mytool",
+		"version":      "1.2.0",
+		"go_version":   runtime.Version(),
+		"platform":     runtime.GOOS + "/" + runtime.GOARCH,
+		"package.ca":   "v2.12.0",
+		"package.util": "0.1.0",
+		"modified":     "false",
+		"commit":       info.Commit,
+		"build_date":   info.BuildDate,
+	}
+	if len(keys) != len(expected) {
+		t.Errorf("Expected %d keys, got %v", len(expected), keys)
+	}
+	for key, value := range expected {
+		if keys[key] != value {
+			t.Errorf("Expected %s=%s, got %q", key, value, keys[key])
+		}
+	}
+}
+
+func TestWithPackageCopies(t *testing.T) {
+	base := New("mytool", "1.2.0").WithPackage("ca", "v2.12.0")
+	_ = base.WithPackage("util", "0.1.0")
+	if len(base.Packages) != 1 {
+		t.Errorf("WithPackage modified the original: %v", base.Packages)
+	}
+}
+
+func TestLinkerOverrides(t *testing.T) {
+	defer func(commit, date string) { Commit, Date = commit, date }(Commit, Date)
+	Commit, Date = "feedface", "2025-06-01T00:00:00Z"
+
+	info := New("mytool", "1.2.0")
+	if info.Commit != "feedface" || info.BuildDate != "2025-06-01T00:00:00Z" {
+		t.Errorf("Expected linker values to win, got commit %q date %q", info.Commit, info.BuildDate)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	info := New("mytool", "1.2.0").WithPackage("ca", "v2.12.0")
+	server := httptest.NewServer(Handler(info))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected application/json, got %s", ct)
+	}
+	var got Info
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if got.Name != "mytool" || got.Version != "1.2.0" || got.Packages["ca"] != "v2.12.0" {
+		t.Errorf("Unexpected info: %+v", got)
+	}
+
+	resp, err = http.Post(server.URL, "text/plain", nil)
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,230 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Errors of the approval workflow (see ServerConfig.ApprovalDomains)
+var (
+	// ErrApprovalRequired is returned by issuance paths that can't wait for
+	// an admin, such as gRPC and /sds, for names needing approval
+	ErrApprovalRequired = errors.New("certificate request requires approval")
+
+	// ErrCertRequestDenied is returned by the client helpers when an admin
+	// denied the request, or it wasn't approved in time
+	ErrCertRequestDenied = errors.New("certificate request denied")
+)
+
+// approvalNames returns the DNS SANs falling under one of the approval
+// domains, matched like name constraints. A wildcard needs approval when
+// any name it is valid for might: *.example.com is held for example.com,
+// .example.com, and db.example.com alike. IP, URI, and email SANs never
+// need approval.
+func approvalNames(domains, sans []string) []string {
+	var names []string
+	for _, san := range sans {
+		if net.ParseIP(san) != nil || strings.ContainsAny(san, ":/@") {
+			continue
+		}
+		for _, domain := range domains {
+			if needsApproval(san, domain) {
+				names = append(names, san)
+				break
+			}
+		}
+	}
+	return names
+}
+
+// needsApproval reports whether a DNS SAN falls under an approval domain
+func needsApproval(san, domain string) bool {
+	base, wildcard := strings.CutPrefix(strings.ToLower(san), "*.")
+	if !wildcard {
+		return matchDomainConstraint(san, domain)
+	}
+	// *.base covers every name one label below base
+	domain = strings.TrimPrefix(domain, ".")
+	if matchDomainConstraint(base, domain) {
+		return true
+	}
+	_, parent, found := strings.Cut(domain, ".")
+	return found && parent == base
+}
+
+// approvalRequired returns the names of a request that need approval
+func (s *Server) approvalRequired(sans []string) []string {
+	return approvalNames(s.approvalDomains, sans)
+}
+
+// requestsDecision is the optional body of POST /admin/requests/{id}/deny
+type requestsDecision struct {
+	Reason string `json:"reason"`
+}
+
+// handleAdminRequests serves GET /admin/requests, the requests awaiting
+// approval, and POST /admin/requests/{id}/approve or /deny. Denials take an
+// optional {"reason": "..."} body. Admin only.
+func (s *Server) handleAdminRequests(w http.ResponseWriter, r *http.Request) {
+	if _, scoped := requestNamespace(r); scoped {
+		http.Error(w, "Forbidden: approvals require the admin API key or a bearer token", http.StatusForbidden)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/requests"), "/")
+	if path == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"requests": s.issueQueue.AwaitingApproval()})
+		return
+	}
+
+	id, action, ok := strings.Cut(path, "/")
+	if !ok || (action != "approve" && action != "deny") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var decision requestsDecision
+	if action == "deny" && r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&decision); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ticket, err := decideRequest(s.issueQueue, id, action, decision.Reason, r.RemoteAddr)
+	switch {
+	case errors.Is(err, ErrTicketNotFound):
+		http.Error(w, "Ticket not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrNotAwaitingApproval):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, ErrIssueQueueFull):
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ticket)
+}
+
+// decideRequest approves or denies a request awaiting approval
+func decideRequest(queue *issueQueue, id, action, reason, by string) (IssueTicket, error) {
+	if action == "approve" {
+		ticket, err := queue.Approve(id)
+		if err == nil {
+			log.Printf("[ca] Certificate request for %s approved by %s (ticket %s)", ticket.ServiceName, by, id)
+		}
+		return ticket, err
+	}
+	ticket, err := queue.Deny(id, reason)
+	if err == nil {
+		log.Printf("[ca] Certificate request for %s denied by %s (ticket %s): %s", ticket.ServiceName, by, id, ticket.Error)
+	}
+	return ticket, err
+}
+
+// HandleApprovalRequests serves the requests awaiting approval: GET
+// /ui/requests renders them as an HTMX fragment, and POST
+// /ui/requests/{id}/approve or /deny decides one (with the reason from
+// hx-prompt) and renders the rest. Namespace-scoped users see nothing.
+func (g *GUIHandler) HandleApprovalRequests(w http.ResponseWriter, r *http.Request) {
+	if _, scoped := requestNamespace(r); scoped || g.approvals == nil {
+		g.writeHTMLResponse(w, "")
+		return
+	}
+
+	message := ""
+	if path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/ui/requests"), "/"); path != "" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id, action, _ := strings.Cut(path, "/")
+		if action != "approve" && action != "deny" {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		if _, err := decideRequest(g.approvals, id, action, r.Header.Get("HX-Prompt"), r.RemoteAddr); err != nil {
+			message = fmt.Sprintf(`<div class="alert alert-error"><strong>Error:</strong> %s</div>`, template.HTMLEscapeString(err.Error()))
+		}
+	}
+
+	g.writeHTMLResponse(w, message+approvalRequestsHTML(g.approvals.AwaitingApproval()))
+}
+
+// approvalRequestsHTML renders the requests awaiting approval as a panel,
+// or nothing when there are none
+func approvalRequestsHTML(tickets []IssueTicket) string {
+	if len(tickets) == 0 {
+		return ""
+	}
+	html := fmt.Sprintf(`
+<div class="panel">
+	<h3>AWAITING APPROVAL (%d)</h3>
+	<table class="table">
+		<thead>
+			<tr>
+				<th>SERVICE</th>
+				<th>SUBJECT ALT NAMES</th>
+				<th>REQUESTED</th>
+				<th>ACTIONS</th>
+			</tr>
+		</thead>
+		<tbody>`, len(tickets))
+	for _, ticket := range tickets {
+		sansHTML := ""
+		for _, entry := range sanEntries(ticket.SANs) {
+			sansHTML += fmt.Sprintf(`<div>%s</div>`, sanHTML(entry))
+		}
+		id := template.HTMLEscapeString(ticket.ID)
+		serviceName := template.HTMLEscapeString(ticket.ServiceName)
+		html += fmt.Sprintf(`
+			<tr>
+				<td><strong>%s</strong></td>
+				<td>%s</td>
+				<td>%s</td>
+				<td>
+					<button class="btn btn-primary" hx-post="/ui/requests/%s/approve" hx-target="#approval-requests"
+						hx-confirm="Issue a certificate for %s?">APPROVE</button>
+					<button class="btn" hx-post="/ui/requests/%s/deny" hx-target="#approval-requests"
+						hx-prompt="Reason for denying %s (optional)">DENY</button>
+				</td>
+			</tr>`,
+			serviceName, sansHTML, ticket.CreatedAt.Local().Format("01-02 15:04"),
+			id, serviceName, id, serviceName)
+	}
+	return html + `
+		</tbody>
+	</table>
+</div>`
+}
+
+// awaitApproval waits for the ticket of a 202 response to POST /cert, which
+// the server answers for names needing approval, and returns its
+// certificate once approved
+func awaitApproval(resp *http.Response) (*CertResponse, error) {
+	var ticket IssueTicket
+	if err := json.NewDecoder(resp.Body).Decode(&ticket); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCAResponse, err)
+	}
+	log.Printf("[ca] Certificate request for %s is awaiting approval (ticket %s)", ticket.ServiceName, ticket.ID)
+	return WaitForCertificate(context.Background(), ticket.ID)
+}
@@ -0,0 +1,189 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestApprovalWorkflow(t *testing.T) {
+	config := DefaultCAConfig()
+	config.KeySize = 2048
+	server, err := NewServer(&ServerConfig{CAConfig: config, EnableGUI: true, GUIAPIKey: "admin-key", ApprovalDomains: []string{"Prod.Example.com"}})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cert", server.handleCertRequest)
+	mux.HandleFunc("/cert/ticket/", server.handleCertTicket)
+	mux.HandleFunc("/admin/requests/", server.handleAdminRequests)
+	mux.HandleFunc("/ui/requests", server.gui.HandleApprovalRequests)
+	mux.HandleFunc("/ui/requests/", server.gui.HandleApprovalRequests)
+	caServer := httptest.NewServer(mux)
+	defer caServer.Close()
+	t.Setenv("SGL_CA", caServer.URL)
+	t.Setenv("SGL_CA_API_KEY", "")
+
+	// Other names are issued at once
+	if _, err := RequestCertificateV2("dev", []string{"dev.example.com"}); err != nil {
+		t.Fatalf("RequestCertificateV2 failed: %v", err)
+	}
+
+	type result struct {
+		cert *CertResponse
+		err  error
+	}
+	request := func(sans ...string) chan result {
+		done := make(chan result, 1)
+		go func() {
+			cert, err := RequestCertificateV2("api", sans)
+			done <- result{cert, err}
+		}()
+		return done
+	}
+	admin := func(method, path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+		return rr
+	}
+	awaiting := func() IssueTicket {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			var list struct{ Requests []IssueTicket }
+			json.NewDecoder(admin(http.MethodGet, "/admin/requests/").Body).Decode(&list)
+			if len(list.Requests) == 1 {
+				return list.Requests[0]
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected one request awaiting approval, got %+v", list.Requests)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// Approved requests are issued and returned to the waiting client
+	approved := request("api.prod.example.com", "10.0.0.1")
+	ticket := awaiting()
+	if ticket.Status != TicketAwaitingApproval || strings.Join(ticket.SANs, ",") != "api.prod.example.com,10.0.0.1" {
+		t.Errorf("Unexpected ticket %+v", ticket)
+	}
+	if rr := admin(http.MethodGet, "/ui/requests"); !strings.Contains(rr.Body.String(), "/ui/requests/"+ticket.ID+"/approve") {
+		t.Errorf("Expected the GUI to list the request, got %s", rr.Body)
+	}
+	if rr := admin(http.MethodPost, "/admin/requests/"+ticket.ID+"/approve"); rr.Code != http.StatusOK {
+		t.Fatalf("Approve returned %d: %s", rr.Code, rr.Body)
+	}
+	if got := <-approved; got.err != nil || !strings.Contains(got.cert.Certificate, "BEGIN CERTIFICATE") {
+		t.Fatalf("Expected the approved certificate, got %v", got.err)
+	}
+	if rr := admin(http.MethodPost, "/admin/requests/"+ticket.ID+"/approve"); rr.Code != http.StatusConflict {
+		t.Errorf("Expected 409 deciding a ticket twice, got %d", rr.Code)
+	}
+	if rr := admin(http.MethodPost, "/admin/requests/unknown/deny"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown ticket, got %d", rr.Code)
+	}
+
+	// Denials from the GUI carry the reason to the client
+	denied := request("prod.example.com")
+	ticket = awaiting()
+	deny := httptest.NewRequest(http.MethodPost, "/ui/requests/"+ticket.ID+"/deny", nil)
+	deny.Header.Set("HX-Prompt", "not on staging")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, deny)
+	if strings.Contains(rr.Body.String(), ticket.ID) {
+		t.Errorf("Expected the denied request to leave the GUI, got %s", rr.Body)
+	}
+	if got := <-denied; !errors.Is(got.err, ErrCertRequestDenied) || !strings.Contains(got.err.Error(), "not on staging") {
+		t.Errorf("Expected ErrCertRequestDenied with the reason, got %v", got.err)
+	}
+
+	// Namespace users can't decide
+	scoped := httptest.NewRequest(http.MethodGet, "/admin/requests/", nil)
+	scoped = scoped.WithContext(context.WithValue(scoped.Context(), namespaceContextKey{}, "team-a"))
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, scoped)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a namespace key, got %d", rr.Code)
+	}
+
+	// Paths that can't wait refuse names needing approval
+	_, err = (&grpcService{server: server}).issue(context.Background(), CertRequestV2{ServiceName: "api", SANs: []string{"*.prod.example.com"}})
+	if !errors.Is(err, ErrApprovalRequired) || status.Code(grpcError(err)) != codes.FailedPrecondition {
+		t.Errorf("Expected gRPC to refuse with FailedPrecondition, got %v", err)
+	}
+}
+
+func TestApprovalTimeout(t *testing.T) {
+	q := newIssueQueue(1, 1)
+	ticket, err := q.Hold("api", "", []string{"api.prod"}, PriorityNormal, func() (*CertResponse, error) {
+		t.Error("Expected a timed out request not to be issued")
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("Hold failed: %v", err)
+	}
+	if _, err := q.Hold("web", "", []string{"web.prod"}, PriorityNormal, nil); !errors.Is(err, ErrIssueQueueFull) {
+		t.Errorf("Expected ErrIssueQueueFull, got %v", err)
+	}
+
+	q.mutex.Lock()
+	q.tickets[ticket.ID].ticket.CreatedAt = time.Now().Add(-approvalTimeout - time.Minute)
+	q.mutex.Unlock()
+	if awaiting := q.AwaitingApproval(); len(awaiting) != 0 {
+		t.Errorf("Expected the request to time out, got %+v", awaiting)
+	}
+	if ticket, _ := q.Get(context.Background(), ticket.ID, "", false, 0); ticket.Status != TicketDenied || !strings.Contains(ticket.Error, "timed out") {
+		t.Errorf("Expected a denied ticket, got %+v", ticket)
+	}
+
+	if _, err := NewServer(&ServerConfig{GUIAPIKey: "admin-key", ApprovalDomains: []string{"*.prod"}}); err == nil {
+		t.Error("Expected a wildcard approval domain to be rejected")
+	}
+	// Without an admin credential requesters could approve themselves
+	if _, err := NewServer(&ServerConfig{ApprovalDomains: []string{"prod"}}); err == nil {
+		t.Error("Expected approval domains without an admin credential to be rejected")
+	}
+}
+
+func TestApprovalNames(t *testing.T) {
+	cases := []struct {
+		domain string
+		san    string
+		want   bool
+	}{
+		{"prod.example.com", "prod.example.com", true},
+		{"prod.example.com", "api.prod.example.com", true},
+		{"prod.example.com", "example.com", false},
+		{".prod.example.com", "prod.example.com", false},
+		{".prod.example.com", "api.prod.example.com", true},
+		{"prod.example.com", "10.0.0.1", false},
+		{"prod.example.com", "spiffe://prod.example.com/api", false},
+
+		// Wildcards need approval if any name they cover does
+		{"prod.example.com", "*.prod.example.com", true},
+		{".prod.example.com", "*.prod.example.com", true},
+		{".prod.example.com", "*.API.prod.example.com", true},
+		{"db.example.com", "*.example.com", true},
+		{".db.example.com", "*.example.com", true},
+		{"db.example.com", "*.dev.example.com", false},
+		{"a.db.example.com", "*.example.com", false},
+		{"prod.example.com", "*.example.com", true},
+	}
+	for _, c := range cases {
+		got := len(approvalNames([]string{c.domain}, []string{c.san})) > 0
+		if got != c.want {
+			t.Errorf("approvalNames(%q, %q) = %v, want %v", c.domain, c.san, got, c.want)
+		}
+	}
+}
@@ -5,18 +5,24 @@
 package ca
 
 import (
+	"context"
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"math/big"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 // CA represents a Certificate Authority with the ability to issue certificates
@@ -24,8 +30,21 @@ type CA struct {
 	cert       *x509.Certificate
 	privateKey *rsa.PrivateKey
 	storage    CertStorage
-	mutex      sync.RWMutex // Protects CA certificate and private key
-	persistDir string       // Directory for CA persistence (empty = RAM only)
+	mutex      sync.RWMutex  // Protects CA certificate and private key
+	persistDir string        // Directory for CA persistence (empty = RAM only)
+	keyCrypt   *KeyEncryptor // Encrypts persisted private keys (nil = plaintext)
+	leafKeyAlg KeyAlgorithm  // Key algorithm for issued certificates
+	keyPool    *KeyPool      // Pre-generated leaf keys (nil = generate inline)
+
+	certTemplates map[string]CertTemplate // Named leaf templates, built-ins included
+	staleCertAge  time.Duration           // Unused time before a certificate is stale (0 = DefaultStaleCertAge)
+
+	bundleExtra []*x509.Certificate // Published in the bundle after the root
+
+	events    *eventQueue  // Publishes issuance events (nil = disabled)
+	snapshots *snapshotter // Scheduled backups (nil = disabled)
+
+	tracerProvider trace.TracerProvider // Records issuance spans (nil = the global provider)
 }
 
 // IssuedCert represents a certificate that has been issued by the CA
@@ -37,6 +56,19 @@ type IssuedCert struct {
 	Certificate  string    `json:"certificate"`
 	PrivateKey   string    `json:"private_key,omitempty"` // Optional for security
 	SerialNumber string    `json:"serial_number"`
+
+	// Namespace is the tenant that requested the certificate; empty for
+	// certificates issued without a namespace API key
+	Namespace string `json:"namespace,omitempty"`
+
+	// Revocation state (tracked in index.json for external tooling)
+	Revoked   bool       `json:"revoked,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+
+	// Usage: the last download through the GUI or /cert/{serial}, and the
+	// last usage ping from the service (see ReloadingCertificate.PingUsage)
+	LastFetchedAt *time.Time `json:"last_fetched_at,omitempty"`
+	LastUsedAt    *time.Time `json:"last_used_at,omitempty"`
 }
 
 // CertRequest represents a request for a new certificate
@@ -50,6 +82,52 @@ type CertRequest struct {
 type CertRequestV2 struct {
 	ServiceName string   `json:"service_name"`
 	SANs        []string `json:"sans"` // Subject Alternative Names - mix of IPs and hostnames
+
+	// Optional overrides
+	ValidityDays int          `json:"validity_days,omitempty"` // 1 to MaxLeafValidityDays (0 = DefaultLeafValidity)
+	KeyAlgorithm KeyAlgorithm `json:"key_algorithm,omitempty"` // Empty = the CA's LeafKeyAlgorithm
+
+	// ValidityMinutes issues a short-lived certificate, e.g. 60 for an hour,
+	// instead of ValidityDays (see NewShortLivedCertificate)
+	ValidityMinutes int `json:"validity_minutes,omitempty"`
+
+	// Template names a CertTemplate ("web", "grpc-service", "client-auth",
+	// or one from CAConfig.CertTemplates) setting the key usages, validity,
+	// and key algorithm; ValidityDays and KeyAlgorithm above override it
+	Template string `json:"template,omitempty"`
+}
+
+// Leaf certificate validity
+const (
+	DefaultLeafValidity = 365 * 24 * time.Hour // 1 year
+	MaxLeafValidityDays = 825
+)
+
+// ErrInvalidCertRequest is returned when a certificate request has invalid options
+var ErrInvalidCertRequest = errors.New("invalid certificate request")
+
+// options validates the optional overrides of a V2 request
+func (req CertRequestV2) options() (certOptions, error) {
+	if req.ValidityDays < 0 || req.ValidityDays > MaxLeafValidityDays {
+		return certOptions{}, fmt.Errorf("%w: validity_days must be between 1 and %d", ErrInvalidCertRequest, MaxLeafValidityDays)
+	}
+	if req.ValidityMinutes < 0 || req.ValidityMinutes > MaxLeafValidityDays*24*60 {
+		return certOptions{}, fmt.Errorf("%w: validity_minutes must be between 1 and %d", ErrInvalidCertRequest, MaxLeafValidityDays*24*60)
+	}
+	if req.ValidityDays != 0 && req.ValidityMinutes != 0 {
+		return certOptions{}, fmt.Errorf("%w: set validity_days or validity_minutes, not both", ErrInvalidCertRequest)
+	}
+	if err := validateKeyAlgorithm(req.KeyAlgorithm); err != nil {
+		return certOptions{}, fmt.Errorf("%w: %v", ErrInvalidCertRequest, err)
+	}
+	validity := time.Duration(req.ValidityDays) * 24 * time.Hour
+	if req.ValidityMinutes != 0 {
+		validity = time.Duration(req.ValidityMinutes) * time.Minute
+	}
+	return certOptions{
+		validity:     validity,
+		keyAlgorithm: req.KeyAlgorithm,
+	}, nil
 }
 
 // CertResponse represents the response containing the issued certificate
@@ -77,6 +155,72 @@ type CAConfig struct {
 
 	// Directory to persist CA data (empty = RAM only)
 	PersistDir string
+
+	// Passphrase used to encrypt persisted private keys at rest
+	// (AES-256-GCM with an scrypt-derived key). Empty = plaintext.
+	KeyPassphrase string
+
+	// Raw 32-byte AES-256 key used instead of KeyPassphrase.
+	// Set at most one of KeyPassphrase and KeyEncryptionKey.
+	KeyEncryptionKey []byte
+
+	// Key algorithm for issued (leaf) certificates (default: rsa2048)
+	LeafKeyAlgorithm KeyAlgorithm
+
+	// Number of leaf keys to pre-generate in the background (0 = disabled)
+	KeyPoolSize int
+
+	// Background workers filling the key pool (default: 1)
+	KeyPoolWorkers int
+
+	// Extra PEM certificates published in the CA bundle after the root,
+	// e.g. intermediates or a previous root during rotation
+	BundleCertsPEM []byte
+
+	// X.509 name constraints on the generated root. With PermittedDNSDomains
+	// set (e.g. "local", "test"), certificates chaining to the root are only
+	// valid for those domains and their subdomains, even if the CA key leaks;
+	// ExcludedDNSDomains are never valid. A leading dot (".local") matches
+	// subdomains only. IP SANs are not constrained. Only applied when a new
+	// root is generated - a persisted root keeps its own constraints.
+	PermittedDNSDomains []string
+	ExcludedDNSDomains  []string
+
+	// RootCertPEM and RootKeyPEM import an existing root certificate and
+	// RSA private key (e.g. from mkcert or a corporate dev root) instead of
+	// generating one. The subject, validity, key size, and name constraint
+	// settings above are then ignored. See NewCAFromPEM.
+	RootCertPEM []byte
+	RootKeyPEM  []byte
+
+	// SnapshotDir receives a Backup archive every SnapshotInterval,
+	// keeping the newest SnapshotKeep (default DefaultSnapshotKeep).
+	// Snapshots are disabled unless both SnapshotDir and SnapshotInterval
+	// are set.
+	SnapshotDir      string
+	SnapshotInterval time.Duration
+	SnapshotKeep     int
+
+	// EventPublisher receives cert.issued, cert.revoked, and ca.rotated
+	// events, published in the background (nil = disabled). See
+	// NewEventPublisher for NATS and Redis.
+	EventPublisher EventPublisher
+
+	// CertTemplates adds named leaf certificate templates, selectable with
+	// CertRequestV2.Template, to DefaultCertTemplates. A template with a
+	// built-in's name replaces it.
+	CertTemplates map[string]CertTemplate
+
+	// StaleCertAge is how long a certificate can go without being fetched
+	// or used before the GUI marks it stale and PurgeUnusedCertificates
+	// removes it by default (default DefaultStaleCertAge)
+	StaleCertAge time.Duration
+
+	// TracerProvider records OpenTelemetry spans of issuance: ca.issue with
+	// ca.generate_key, ca.sign, and ca.persist children, carrying the
+	// service name, SAN count, and key algorithm (nil = the global
+	// provider, which records nothing until an SDK is installed)
+	TracerProvider trace.TracerProvider
 }
 
 // HTTPTransportSettings configures the global HTTP transport
@@ -132,14 +276,48 @@ func NewCA(config *CAConfig) (*CA, error) {
 		config = DefaultCAConfig()
 	}
 
+	if err := validateKeyAlgorithm(config.LeafKeyAlgorithm); err != nil {
+		return nil, err
+	}
+
+	bundleExtra, err := parseBundleCertsPEM(config.BundleCertsPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle certificates: %w", err)
+	}
+
+	permittedDomains, err := normalizeConstraintDomains(config.PermittedDNSDomains)
+	if err != nil {
+		return nil, fmt.Errorf("invalid permitted DNS domains: %w", err)
+	}
+	excludedDomains, err := normalizeConstraintDomains(config.ExcludedDNSDomains)
+	if err != nil {
+		return nil, fmt.Errorf("invalid excluded DNS domains: %w", err)
+	}
+
+	certTemplates, err := mergeCertTemplates(config.CertTemplates)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate templates: %w", err)
+	}
+
 	ca := &CA{
-		persistDir: config.PersistDir,
+		persistDir:    config.PersistDir,
+		leafKeyAlg:    config.LeafKeyAlgorithm,
+		bundleExtra:   bundleExtra,
+		certTemplates: certTemplates,
+		staleCertAge:  config.StaleCertAge,
+
+		tracerProvider: config.TracerProvider,
+	}
+
+	// Set up encryption at rest for persisted private keys
+	ca.keyCrypt, err = newKeyEncryptorFromConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key encryption config: %w", err)
 	}
 
 	// Initialize storage based on configuration
-	var err error
 	if config.PersistDir != "" {
-		ca.storage, err = NewDiskStorage(config.PersistDir)
+		ca.storage, err = NewEncryptedDiskStorage(config.PersistDir, ca.keyCrypt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to initialize disk storage: %w", err)
 		}
@@ -149,17 +327,95 @@ func NewCA(config *CAConfig) (*CA, error) {
 		fmt.Printf("[ca] Using RAM-only storage\n")
 	}
 
-	if err := ca.initialize(config); err != nil {
+	if err := ca.initialize(config, permittedDomains, excludedDomains); err != nil {
 		return nil, fmt.Errorf("failed to initialize CA: %w", err)
 	}
 
+	if config.KeyPoolSize > 0 {
+		ca.keyPool, err = NewKeyPool(config.KeyPoolSize, config.LeafKeyAlgorithm, config.KeyPoolWorkers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start key pool: %w", err)
+		}
+		fmt.Printf("[ca] Key pool enabled: %d %s keys\n", config.KeyPoolSize, ca.keyPool.alg)
+	}
+
+	if config.SnapshotDir != "" && config.SnapshotInterval > 0 {
+		ca.snapshots = ca.startSnapshots(config.SnapshotDir, config.SnapshotInterval, config.SnapshotKeep)
+		fmt.Printf("[ca] Snapshots every %s to %s\n", config.SnapshotInterval, config.SnapshotDir)
+	}
+
+	if config.EventPublisher != nil {
+		ca.events = newEventQueue(config.EventPublisher)
+		if disk, ok := ca.storage.(*DiskStorage); ok {
+			disk.onRevoked = func(cert *IssuedCert) {
+				ca.publishEvent(certEvent(EventCertRevoked, cert))
+			}
+		}
+	}
+
 	return ca, nil
 }
 
-// initialize sets up the CA certificate and private key
+// Close releases background resources such as the key pool and the
+// snapshot schedule. The CA remains usable; leaf keys are then generated
+// inline.
+func (ca *CA) Close() {
+	if ca.keyPool != nil {
+		ca.keyPool.Close()
+	}
+	if ca.snapshots != nil {
+		ca.snapshots.close()
+		ca.snapshots = nil
+	}
+	if ca.events != nil {
+		ca.events.close()
+	}
+}
+
+// KeyPoolStats returns key pool statistics. The second return value is
+// false if the key pool is disabled.
+func (ca *CA) KeyPoolStats() (KeyPoolStats, bool) {
+	if ca.keyPool == nil {
+		return KeyPoolStats{}, false
+	}
+	return ca.keyPool.Stats(), true
+}
+
+// newLeafKey returns a private key for a new leaf certificate, from the key
+// pool when enabled
+func (ca *CA) newLeafKey() (crypto.Signer, error) {
+	if ca.keyPool != nil {
+		return ca.keyPool.Get()
+	}
+	return generateKey(ca.leafKeyAlg)
+}
+
+// LeafKeyAlgorithm returns the key algorithm used for issued certificates
+// that do not request one
+func (ca *CA) LeafKeyAlgorithm() KeyAlgorithm {
+	if ca.leafKeyAlg == "" {
+		return DefaultLeafKeyAlgorithm
+	}
+	return ca.leafKeyAlg
+}
+
+// newLeafKeyFor returns a private key of the requested algorithm, using the
+// key pool when it matches the CA's leaf key algorithm
+func (ca *CA) newLeafKeyFor(alg KeyAlgorithm) (crypto.Signer, error) {
+	if alg == "" || alg == ca.LeafKeyAlgorithm() {
+		return ca.newLeafKey()
+	}
+	return generateKey(alg)
+}
+
 // initialize sets up the CA certificate and private key.
-// Loads from disk if persistence is enabled, otherwise generates a new CA.
-func (ca *CA) initialize(config *CAConfig) error {
+// Loads from disk if persistence is enabled, otherwise generates a new CA
+// with the given DNS name constraints.
+func (ca *CA) initialize(config *CAConfig, permittedDomains, excludedDomains []string) error {
+	if len(config.RootCertPEM) > 0 || len(config.RootKeyPEM) > 0 {
+		return ca.importRoot(config, permittedDomains, excludedDomains)
+	}
+
 	// Try to load existing CA from disk if persistence is enabled
 	if ca.persistDir != "" {
 		if err := ca.loadCAFromDisk(); err != nil {
@@ -170,9 +426,18 @@ func (ca *CA) initialize(config *CAConfig) error {
 	// If we loaded an existing CA, we're done
 	if ca.cert != nil && ca.privateKey != nil {
 		fmt.Printf("[ca] Loaded existing CA from disk\n")
+		configured := len(permittedDomains) > 0 || len(excludedDomains) > 0
+		if configured && (!slices.Equal(permittedDomains, ca.cert.PermittedDNSDomains) || !slices.Equal(excludedDomains, ca.cert.ExcludedDNSDomains)) {
+			fmt.Printf("[ca] Warning: configured name constraints differ from the persisted root's (permitted %v, excluded %v); delete %s to regenerate the root\n",
+				ca.cert.PermittedDNSDomains, ca.cert.ExcludedDNSDomains, ca.persistDir)
+		}
 		return nil
 	}
 
+	if ca.persistDir != "" && ca.keyCrypt != nil {
+		fmt.Printf("[ca] Private keys will be encrypted at rest\n")
+	}
+
 	// Generate new CA private key
 	var err error
 	ca.privateKey, err = rsa.GenerateKey(rand.Reader, config.KeySize)
@@ -198,6 +463,10 @@ func (ca *CA) initialize(config *CAConfig) error {
 		IsCA:                  true,
 		MaxPathLen:            0,
 		MaxPathLenZero:        true,
+
+		PermittedDNSDomainsCritical: len(permittedDomains) > 0,
+		PermittedDNSDomains:         permittedDomains,
+		ExcludedDNSDomains:          excludedDomains,
 	}
 
 	// Create CA certificate
@@ -217,6 +486,9 @@ func (ca *CA) initialize(config *CAConfig) error {
 		return fmt.Errorf("failed to save CA to disk: %w", err)
 	}
 
+	if len(permittedDomains) > 0 || len(excludedDomains) > 0 {
+		fmt.Printf("[ca] Root name constraints: permitted %v, excluded %v\n", permittedDomains, excludedDomains)
+	}
 	if ca.persistDir != "" {
 		fmt.Printf("[ca] Created and saved new CA to disk\n")
 	} else {
@@ -228,7 +500,11 @@ func (ca *CA) initialize(config *CAConfig) error {
 
 // Certificate returns the root CA certificate as an *x509.Certificate.
 // Certificate returns the root CA certificate as an *x509.Certificate.
+// The root can change at runtime (ReloadFromDisk, Restore), so read it
+// once and use the returned certificate.
 func (ca *CA) Certificate() *x509.Certificate {
+	ca.mutex.RLock()
+	defer ca.mutex.RUnlock()
 	return ca.cert
 }
 
@@ -237,7 +513,7 @@ func (ca *CA) Certificate() *x509.Certificate {
 func (ca *CA) CertificatePEM() []byte {
 	return pem.EncodeToMemory(&pem.Block{
 		Type:  "CERTIFICATE",
-		Bytes: ca.cert.Raw,
+		Bytes: ca.Certificate().Raw,
 	})
 }
 
@@ -265,7 +541,17 @@ func (ca *CA) PrivateKeyPEM() []byte {
 //
 //	resp, err := ca.IssueServiceCertificate(ca.CertRequest{ServiceName: "api", Domains: []string{"api.local"}})
 func (ca *CA) IssueServiceCertificate(req CertRequest) (*CertResponse, error) {
-	certPEM, keyPEM, err := ca.GenerateCertificate(req.ServiceName, req.ServiceIP, req.Domains)
+	return ca.issueServiceCertificate(context.Background(), req, "")
+}
+
+// issueServiceCertificate is IssueServiceCertificate recording the
+// namespace, traced as a child of ctx's span
+func (ca *CA) issueServiceCertificate(ctx context.Context, req CertRequest, namespace string) (resp *CertResponse, err error) {
+	opts := certOptions{namespace: namespace}
+	ctx, span := ca.startSpan(ctx, "ca.issue", ca.issueAttributes(req.ServiceName, req.Domains, opts)...)
+	defer func() { endSpan(span, err) }()
+
+	certPEM, keyPEM, err := ca.generateCertificate(ctx, req.ServiceName, req.ServiceIP, req.Domains, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate certificate: %w", err)
 	}
@@ -291,7 +577,26 @@ func (ca *CA) IssueServiceCertificate(req CertRequest) (*CertResponse, error) {
 //
 //	resp, err := ca.IssueServiceCertificateV2(ca.CertRequestV2{ServiceName: "api", SANs: []string{"api.local", "192.168.1.100"}})
 func (ca *CA) IssueServiceCertificateV2(req CertRequestV2) (*CertResponse, error) {
-	certPEM, keyPEM, err := ca.GenerateCertificateV2(req.ServiceName, req.SANs)
+	return ca.issueServiceCertificateV2(context.Background(), req, "")
+}
+
+// issueServiceCertificateV2 is IssueServiceCertificateV2 recording the
+// namespace, traced as a child of ctx's span
+func (ca *CA) issueServiceCertificateV2(ctx context.Context, req CertRequestV2, namespace string) (resp *CertResponse, err error) {
+	opts, err := ca.requestOptions(req)
+	if err != nil {
+		return nil, err
+	}
+	opts.namespace = namespace
+
+	attributes := ca.issueAttributes(req.ServiceName, req.SANs, opts)
+	if req.Template != "" {
+		attributes = append(attributes, attrTemplate.String(req.Template))
+	}
+	ctx, span := ca.startSpan(ctx, "ca.issue", attributes...)
+	defer func() { endSpan(span, err) }()
+
+	certPEM, keyPEM, err := ca.generateCertificateV2(ctx, req.ServiceName, req.SANs, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate certificate: %w", err)
 	}
@@ -311,7 +616,11 @@ func (ca *CA) IssueServiceCertificateV2(req CertRequestV2) (*CertResponse, error
 // Returns PEM-encoded certificate, private key, and error if any.
 func (ca *CA) GenerateCertificate(serviceName, serviceIP string, domains []string) (string, string, error) {
 	// Delegate to storage for thread-safe generation and storage
-	return ca.storage.GenerateAndStore(ca, serviceName, serviceIP, domains)
+	certPEM, keyPEM, err := ca.storage.GenerateAndStore(ca, serviceName, serviceIP, domains)
+	if err == nil {
+		ca.publishIssued(certPEM)
+	}
+	return certPEM, keyPEM, err
 }
 
 // GenerateCertificateV2 generates a certificate using the simplified V2 API with automatic IP detection.
@@ -325,7 +634,38 @@ func (ca *CA) GenerateCertificate(serviceName, serviceIP string, domains []strin
 // Returns PEM-encoded certificate, private key, and error if any.
 func (ca *CA) GenerateCertificateV2(serviceName string, sans []string) (string, string, error) {
 	// Delegate to storage for thread-safe generation and storage with V2 API
-	return ca.storage.GenerateAndStoreV2(ca, serviceName, sans)
+	certPEM, keyPEM, err := ca.storage.GenerateAndStoreV2(ca, serviceName, sans)
+	if err == nil {
+		ca.publishIssued(certPEM)
+	}
+	return certPEM, keyPEM, err
+}
+
+// generateCertificate is GenerateCertificate with per-certificate options,
+// traced as a child of ctx's span
+func (ca *CA) generateCertificate(ctx context.Context, serviceName, serviceIP string, domains []string, opts certOptions) (string, string, error) {
+	storage, ok := ca.storage.(optionsStorage)
+	if !ok {
+		if opts == (certOptions{}) {
+			return ca.GenerateCertificate(serviceName, serviceIP, domains)
+		}
+		return "", "", fmt.Errorf("%w: storage does not support validity, key algorithm, template, or namespace options", ErrInvalidCertRequest)
+	}
+	certPEM, keyPEM, err := storage.generateAndStore(ctx, ca, serviceName, serviceIP, domains, opts)
+	if err == nil {
+		ca.publishIssued(certPEM)
+	}
+	return certPEM, keyPEM, err
+}
+
+// generateCertificateV2 is GenerateCertificateV2 with per-certificate
+// options, traced as a child of ctx's span
+func (ca *CA) generateCertificateV2(ctx context.Context, serviceName string, sans []string, opts certOptions) (string, string, error) {
+	if _, ok := ca.storage.(optionsStorage); !ok && opts == (certOptions{}) {
+		return ca.GenerateCertificateV2(serviceName, sans)
+	}
+	// Pass empty serviceIP since IP addresses are included in the sans array
+	return ca.generateCertificate(ctx, serviceName, "", sans, opts)
 }
 
 // GetIssuedCertificates returns a slice of all certificates issued by this CA.
@@ -364,12 +704,20 @@ func (ca *CA) GetCertificateCount() int {
 // GetCAInfo returns a map containing metadata about the CA, such as subject and validity.
 // GetCAInfo returns a map containing metadata about the CA, such as subject and validity.
 func (ca *CA) GetCAInfo() map[string]interface{} {
-	return map[string]interface{}{
-		"subject":     ca.cert.Subject.CommonName,
-		"valid_until": ca.cert.NotAfter.Format(time.RFC3339),
-		"issued_at":   ca.cert.NotBefore.Format(time.RFC3339),
-		"serial":      ca.cert.SerialNumber.String(),
+	root := ca.Certificate()
+	info := map[string]interface{}{
+		"subject":     root.Subject.CommonName,
+		"valid_until": root.NotAfter.Format(time.RFC3339),
+		"issued_at":   root.NotBefore.Format(time.RFC3339),
+		"serial":      root.SerialNumber.String(),
+	}
+	if len(root.PermittedDNSDomains) > 0 {
+		info["permitted_dns_domains"] = root.PermittedDNSDomains
 	}
+	if len(root.ExcludedDNSDomains) > 0 {
+		info["excluded_dns_domains"] = root.ExcludedDNSDomains
+	}
+	return info
 }
 
 // ParseCertRequest parses a certificate request from JSON-encoded data.
@@ -406,6 +754,15 @@ func MarshalCertResponse(resp *CertResponse) ([]byte, error) {
 // saveCAKeyToDisk saves the CA certificate and private key to disk.
 // No-op if persistence is not enabled.
 func (ca *CA) saveCAKeyToDisk() error {
+	ca.mutex.RLock()
+	cert, key := ca.cert, ca.privateKey
+	ca.mutex.RUnlock()
+	return ca.writeRootToDisk(cert, key)
+}
+
+// writeRootToDisk saves a CA certificate and private key to disk, whether
+// or not the CA uses them yet. No-op if persistence is not enabled.
+func (ca *CA) writeRootToDisk(cert *x509.Certificate, key *rsa.PrivateKey) error {
 	if ca.persistDir == "" {
 		return nil // RAM-only mode
 	}
@@ -417,17 +774,21 @@ func (ca *CA) saveCAKeyToDisk() error {
 
 	// Save CA certificate
 	caCertPath := filepath.Join(ca.persistDir, "ca-cert.pem")
-	caCertPEM := ca.CertificatePEM()
+	caCertPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
 	if err := os.WriteFile(caCertPath, caCertPEM, 0644); err != nil {
 		return fmt.Errorf("failed to save CA certificate: %w", err)
 	}
 
 	// Save CA private key
 	caKeyPath := filepath.Join(ca.persistDir, "ca-key.pem")
-	caKeyPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(ca.privateKey),
-	})
+	caKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if ca.keyCrypt != nil {
+		var err error
+		caKeyPEM, err = ca.keyCrypt.EncryptPEM(caKeyPEM)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt CA private key: %w", err)
+		}
+	}
 	if err := os.WriteFile(caKeyPath, caKeyPEM, 0600); err != nil {
 		return fmt.Errorf("failed to save CA private key: %w", err)
 	}
@@ -476,6 +837,18 @@ func (ca *CA) loadCAFromDisk() error {
 		return fmt.Errorf("failed to read CA private key: %w", err)
 	}
 
+	// Decrypt the key if it was encrypted at rest
+	encrypted := isEncryptedKeyPEM(keyPEM)
+	if encrypted {
+		if ca.keyCrypt == nil {
+			return fmt.Errorf("failed to load CA private key: %w", ErrKeyEncrypted)
+		}
+		keyPEM, err = ca.keyCrypt.DecryptPEM(keyPEM)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt CA private key: %w", err)
+		}
+	}
+
 	keyBlock, _ := pem.Decode(keyPEM)
 	if keyBlock == nil {
 		return fmt.Errorf("failed to decode CA private key PEM")
@@ -492,5 +865,13 @@ func (ca *CA) loadCAFromDisk() error {
 	ca.privateKey = privateKey
 	ca.mutex.Unlock()
 
+	// Upgrade a plaintext key once encryption has been configured
+	if !encrypted && ca.keyCrypt != nil {
+		if err := ca.saveCAKeyToDisk(); err != nil {
+			return fmt.Errorf("failed to re-save CA private key encrypted: %w", err)
+		}
+		fmt.Printf("[ca] Encrypted existing CA private key at rest\n")
+	}
+
 	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"net"
 	"strings"
@@ -11,6 +12,22 @@ import (
 	"time"
 )
 
+// newTestCA creates a CA with a 2048-bit root key, which keeps tests fast;
+// configure, if set, adjusts the config first
+func newTestCA(t *testing.T, configure func(config *CAConfig)) *CA {
+	t.Helper()
+	config := DefaultCAConfig()
+	config.KeySize = 2048
+	if configure != nil {
+		configure(config)
+	}
+	authority, err := NewCA(config)
+	if err != nil {
+		t.Fatalf("NewCA failed: %v", err)
+	}
+	return authority
+}
+
 func TestNewCA(t *testing.T) {
 	ca, err := NewCA(nil)
 	if err != nil {
@@ -365,6 +382,154 @@ func TestIssueServiceCertificateV2_EmptySANs(t *testing.T) {
 	}
 }
 
+func TestIssueServiceCertificateV2_URIAndEmailSANs(t *testing.T) {
+	ca, err := NewCA(nil)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+
+	req := CertRequestV2{
+		ServiceName: "spiffe-workload",
+		SANs:        []string{"spiffe://cluster.local/ns/default/sa/app", "ops@example.com", "app.local", "10.0.0.7"},
+	}
+
+	response, err := ca.IssueServiceCertificateV2(req)
+	if err != nil {
+		t.Fatalf("Failed to issue certificate with URI/email SANs: %v", err)
+	}
+
+	block, _ := pem.Decode([]byte(response.Certificate))
+	if block == nil {
+		t.Fatal("Failed to decode certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+
+	if len(cert.URIs) != 1 || cert.URIs[0].String() != "spiffe://cluster.local/ns/default/sa/app" {
+		t.Errorf("Expected SPIFFE URI SAN, got %v", cert.URIs)
+	}
+	if len(cert.EmailAddresses) != 1 || cert.EmailAddresses[0] != "ops@example.com" {
+		t.Errorf("Expected email SAN, got %v", cert.EmailAddresses)
+	}
+	if len(cert.DNSNames) != 1 || cert.DNSNames[0] != "app.local" {
+		t.Errorf("Expected only app.local as DNS SAN, got %v", cert.DNSNames)
+	}
+	if len(cert.IPAddresses) != 1 {
+		t.Errorf("Expected 1 IP SAN, got %v", cert.IPAddresses)
+	}
+	if cert.Subject.CommonName != "app.local" {
+		t.Errorf("Expected CN 'app.local', got '%s'", cert.Subject.CommonName)
+	}
+
+	// A SPIFFE-only certificate falls back to the service name for CN
+	response, err = ca.IssueServiceCertificateV2(CertRequestV2{
+		ServiceName: "svid-only",
+		SANs:        []string{"spiffe://cluster.local/ns/default/sa/worker"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to issue SPIFFE-only certificate: %v", err)
+	}
+	block, _ = pem.Decode([]byte(response.Certificate))
+	cert, _ = x509.ParseCertificate(block.Bytes)
+	if cert.Subject.CommonName != "svid-only" {
+		t.Errorf("Expected CN 'svid-only', got '%s'", cert.Subject.CommonName)
+	}
+}
+
+func TestIssueServiceCertificateV2_Options(t *testing.T) {
+	ca, err := NewCA(nil)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+
+	response, err := ca.IssueServiceCertificateV2(CertRequestV2{
+		ServiceName:  "short-lived",
+		SANs:         []string{"short.local"},
+		ValidityDays: 7,
+		KeyAlgorithm: KeyAlgorithmECDSAP256,
+	})
+	if err != nil {
+		t.Fatalf("Failed to issue certificate with options: %v", err)
+	}
+
+	block, _ := pem.Decode([]byte(response.Certificate))
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	if cert.PublicKeyAlgorithm != x509.ECDSA {
+		t.Errorf("Expected ECDSA key, got %v", cert.PublicKeyAlgorithm)
+	}
+	if validity := cert.NotAfter.Sub(cert.NotBefore); validity != 7*24*time.Hour {
+		t.Errorf("Expected 7 day validity, got %v", validity)
+	}
+
+	// Defaults
+	response, err = ca.IssueServiceCertificateV2(CertRequestV2{ServiceName: "default", SANs: []string{"default.local"}})
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+	block, _ = pem.Decode([]byte(response.Certificate))
+	cert, _ = x509.ParseCertificate(block.Bytes)
+	if cert.PublicKeyAlgorithm != x509.RSA || cert.NotAfter.Sub(cert.NotBefore) != DefaultLeafValidity {
+		t.Errorf("Expected default RSA key and validity, got %v for %v", cert.PublicKeyAlgorithm, cert.NotAfter.Sub(cert.NotBefore))
+	}
+
+	for _, req := range []CertRequestV2{
+		{ServiceName: "bad", SANs: []string{"bad.local"}, ValidityDays: -1},
+		{ServiceName: "bad", SANs: []string{"bad.local"}, ValidityDays: MaxLeafValidityDays + 1},
+		{ServiceName: "bad", SANs: []string{"bad.local"}, KeyAlgorithm: "dsa1024"},
+	} {
+		if _, err := ca.IssueServiceCertificateV2(req); !errors.Is(err, ErrInvalidCertRequest) {
+			t.Errorf("Expected ErrInvalidCertRequest for %+v, got %v", req, err)
+		}
+	}
+}
+
+func TestParseSANs(t *testing.T) {
+	valid := [][]string{
+		{"example.com", "*.example.com", "127.0.0.1", "::1"},
+		{"spiffe://cluster.local/ns/default/sa/app"},
+		{"https://service.example.com/id", "spiffe://td/workload"},
+		{"admin@example.com"},
+	}
+	for _, sans := range valid {
+		if _, err := ParseSANs(sans); err != nil {
+			t.Errorf("ParseSANs(%v) unexpected error: %v", sans, err)
+		}
+	}
+
+	invalid := [][]string{
+		{""},
+		{" example.com"},
+		{"bad host.com"},
+		{"spiffe://cluster.local"},
+		{"spiffe://Cluster.Local/app"},
+		{"spiffe://cluster.local:8080/app"},
+		{"spiffe://cluster.local/app?x=1"},
+		{"spiffe://cluster.local/app/"},
+		{"spiffe://cluster.local/a", "spiffe://cluster.local/b"},
+		{"://missing-scheme"},
+		{"not an email@"},
+	}
+	for _, sans := range invalid {
+		_, err := ParseSANs(sans)
+		if !errors.Is(err, ErrInvalidSAN) {
+			t.Errorf("ParseSANs(%v) expected ErrInvalidSAN, got %v", sans, err)
+		}
+	}
+
+	set, err := ParseSANs([]string{"10.0.0.1", "10.0.0.1", "host"})
+	if err != nil {
+		t.Fatalf("ParseSANs failed: %v", err)
+	}
+	if len(set.IPAddresses) != 1 || len(set.DNSNames) != 1 {
+		t.Errorf("Expected duplicate IPs to be dropped, got %+v", set)
+	}
+}
+
 func TestGetIssuedCertificates(t *testing.T) {
 	ca, err := NewCA(nil)
 	if err != nil {
@@ -0,0 +1,432 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nzions/sharedgolibs/internal/fileutil"
+)
+
+// ErrInvalidBackup is returned by Restore for archives that are not CA
+// backups or are incomplete
+var ErrInvalidBackup = errors.New("invalid CA backup")
+
+// backupFormatVersion is the archive layout written by Backup
+const backupFormatVersion = 1
+
+// Files in a backup archive. The CA files use the persistence directory's
+// names, so an extracted backup is also a valid PersistDir.
+const (
+	backupManifestFile = "manifest.json"
+	backupCertFile     = "ca-cert.pem"
+	backupKeyFile      = "ca-key.pem"
+	backupStoreFile    = "cert-store.json"
+)
+
+// maxBackupFileSize bounds each file read from an archive
+const maxBackupFileSize = 256 << 20
+
+// DefaultSnapshotKeep is the number of snapshots kept when
+// CAConfig.SnapshotKeep is 0
+const DefaultSnapshotKeep = 7
+
+// BackupManifest describes the content of a backup archive
+type BackupManifest struct {
+	FormatVersion int       `json:"format_version"`
+	CAVersion     string    `json:"ca_version"` // Package version that wrote the backup
+	CreatedAt     time.Time `json:"created_at"`
+	Subject       string    `json:"subject"`
+	Certificates  int       `json:"certificates"`
+	KeysEncrypted bool      `json:"keys_encrypted"` // Restoring needs the same KeyPassphrase or KeyEncryptionKey
+}
+
+// restorableStorage is implemented by storage backends whose content
+// Restore can replace
+type restorableStorage interface {
+	replaceCerts(certs map[string]*IssuedCert) error
+}
+
+// Backup writes the CA certificate and key, issued certificates, and the
+// index as a single tar.gz. Private keys are encrypted when the CA encrypts
+// keys at rest. RAM-only CAs can be backed up too.
+func (ca *CA) Backup(w io.Writer) error {
+	issued, err := ca.storage.GetAll()
+	if err != nil {
+		return fmt.Errorf("failed to read issued certificates: %w", err)
+	}
+	certs := make(map[string]*IssuedCert, len(issued))
+	for _, cert := range issued {
+		copied := *cert
+		if ca.keyCrypt != nil && copied.PrivateKey != "" && !isEncryptedKeyPEM([]byte(copied.PrivateKey)) {
+			encrypted, err := ca.keyCrypt.EncryptPEM([]byte(copied.PrivateKey))
+			if err != nil {
+				return fmt.Errorf("failed to encrypt private key for %s: %w", copied.SerialNumber, err)
+			}
+			copied.PrivateKey = string(encrypted)
+		}
+		certs[copied.SerialNumber] = &copied
+	}
+
+	keyPEM := ca.PrivateKeyPEM()
+	if ca.keyCrypt != nil {
+		if keyPEM, err = ca.keyCrypt.EncryptPEM(keyPEM); err != nil {
+			return fmt.Errorf("failed to encrypt CA private key: %w", err)
+		}
+	}
+
+	manifest := BackupManifest{
+		FormatVersion: backupFormatVersion,
+		CAVersion:     Version,
+		CreatedAt:     time.Now().UTC(),
+		Subject:       ca.Certificate().Subject.String(),
+		Certificates:  len(certs),
+		KeysEncrypted: ca.keyCrypt != nil,
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal backup manifest: %w", err)
+	}
+	storeJSON, err := json.MarshalIndent(certs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal certificate store: %w", err)
+	}
+	indexJSON, err := json.MarshalIndent(buildIndex(certs), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal certificate index: %w", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, file := range []struct {
+		name string
+		data []byte
+		mode int64
+	}{
+		{backupManifestFile, manifestJSON, 0644},
+		{backupCertFile, ca.CertificatePEM(), 0644},
+		{backupKeyFile, keyPEM, 0600},
+		{backupStoreFile, storeJSON, 0600},
+		{IndexFileName, indexJSON, 0644},
+	} {
+		header := &tar.Header{Name: file.name, Mode: file.mode, Size: int64(len(file.data)), ModTime: manifest.CreatedAt}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write backup: %w", err)
+		}
+		if _, err := tw.Write(file.data); err != nil {
+			return fmt.Errorf("failed to write backup: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	return nil
+}
+
+// Restore replaces the CA certificate, key, and issued certificates with
+// the content of a Backup archive, persisting them when PersistDir is set.
+// Encrypted backups need the key encryption settings they were made with.
+func (ca *CA) Restore(r io.Reader) error {
+	files, err := readBackupFiles(r)
+	if err != nil {
+		return err
+	}
+
+	var manifest BackupManifest
+	if err := json.Unmarshal(files[backupManifestFile], &manifest); err != nil {
+		return fmt.Errorf("%w: unreadable manifest: %v", ErrInvalidBackup, err)
+	}
+	if manifest.FormatVersion < 1 || manifest.FormatVersion > backupFormatVersion {
+		return fmt.Errorf("%w: unsupported format version %d", ErrInvalidBackup, manifest.FormatVersion)
+	}
+
+	cert, key, err := ca.parseBackupRoot(files[backupCertFile], files[backupKeyFile])
+	if err != nil {
+		return err
+	}
+
+	certs := make(map[string]*IssuedCert)
+	if err := json.Unmarshal(files[backupStoreFile], &certs); err != nil {
+		return fmt.Errorf("%w: unreadable certificate store: %v", ErrInvalidBackup, err)
+	}
+	for serial, issued := range certs {
+		if issued == nil {
+			return fmt.Errorf("%w: empty certificate store entry %s", ErrInvalidBackup, serial)
+		}
+		if issued.SerialNumber != serial {
+			return fmt.Errorf("%w: certificate store entry %s holds serial %q", ErrInvalidBackup, serial, issued.SerialNumber)
+		}
+		if issued.PrivateKey == "" || !isEncryptedKeyPEM([]byte(issued.PrivateKey)) {
+			continue
+		}
+		if ca.keyCrypt == nil {
+			return fmt.Errorf("failed to restore private key for %s: %w", serial, ErrKeyEncrypted)
+		}
+		decrypted, err := ca.keyCrypt.DecryptPEM([]byte(issued.PrivateKey))
+		if err != nil {
+			return fmt.Errorf("failed to decrypt private key for %s: %w", serial, err)
+		}
+		issued.PrivateKey = string(decrypted)
+	}
+
+	storage, ok := ca.storage.(restorableStorage)
+	if !ok {
+		return fmt.Errorf("storage %T does not support restore", ca.storage)
+	}
+
+	// Persist everything before the running CA switches roots, and put the
+	// old root back on disk if that fails halfway
+	ca.mutex.RLock()
+	previous, previousKey := ca.cert, ca.privateKey
+	ca.mutex.RUnlock()
+
+	err = ca.writeRootToDisk(cert, key)
+	if err == nil {
+		if err = storage.replaceCerts(certs); err != nil {
+			err = fmt.Errorf("failed to restore issued certificates: %w", err)
+		}
+	} else {
+		err = fmt.Errorf("failed to save restored CA to disk: %w", err)
+	}
+	if err != nil {
+		if previous != nil {
+			if rollbackErr := ca.writeRootToDisk(previous, previousKey); rollbackErr != nil {
+				fmt.Printf("[ca] Failed to put the previous CA back on disk: %v\n", rollbackErr)
+			}
+		}
+		return err
+	}
+
+	ca.mutex.Lock()
+	ca.cert = cert
+	ca.privateKey = key
+	ca.mutex.Unlock()
+
+	if previous == nil || !previous.Equal(cert) {
+		ca.publishEvent(rootEvent(cert))
+	}
+	fmt.Printf("[ca] Restored backup from %s: %s, %d certificate(s)\n",
+		manifest.CreatedAt.Format(time.RFC3339), cert.Subject, len(certs))
+	return nil
+}
+
+// readBackupFiles extracts the known files from a backup archive
+func readBackupFiles(r io.Reader) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	defer gz.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		switch header.Name {
+		case backupManifestFile, backupCertFile, backupKeyFile, backupStoreFile:
+			data, err := io.ReadAll(io.LimitReader(tr, maxBackupFileSize+1))
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+			}
+			if len(data) > maxBackupFileSize {
+				return nil, fmt.Errorf("%w: %s is too large", ErrInvalidBackup, header.Name)
+			}
+			files[header.Name] = data
+		}
+	}
+
+	for _, name := range []string{backupManifestFile, backupCertFile, backupKeyFile, backupStoreFile} {
+		if _, ok := files[name]; !ok {
+			return nil, fmt.Errorf("%w: missing %s", ErrInvalidBackup, name)
+		}
+	}
+	return files, nil
+}
+
+// parseBackupRoot parses the backed up root, decrypting the key if needed.
+// Unlike an import, an expired root is accepted so its state can be moved.
+func (ca *CA) parseBackupRoot(certPEM, keyPEM []byte) (*x509.Certificate, *rsa.PrivateKey, error) {
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil {
+		return nil, nil, fmt.Errorf("%w: no certificate in %s", ErrInvalidBackup, backupCertFile)
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+
+	if isEncryptedKeyPEM(keyPEM) {
+		if ca.keyCrypt == nil {
+			return nil, nil, fmt.Errorf("failed to restore CA private key: %w", ErrKeyEncrypted)
+		}
+		if keyPEM, err = ca.keyCrypt.DecryptPEM(keyPEM); err != nil {
+			return nil, nil, fmt.Errorf("failed to decrypt CA private key: %w", err)
+		}
+	}
+	key, err := parseRootKeyPEM(keyPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	if !key.PublicKey.Equal(cert.PublicKey) {
+		return nil, nil, fmt.Errorf("%w: the private key does not match %s", ErrInvalidBackup, cert.Subject)
+	}
+	return cert, key, nil
+}
+
+// replaceCerts implements restorableStorage
+func (s *RAMStorage) replaceCerts(certs map[string]*IssuedCert) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.certs = certs
+	return nil
+}
+
+// replaceCerts implements restorableStorage
+func (s *DiskStorage) replaceCerts(certs map[string]*IssuedCert) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	unlock, err := lockPersistDir(s.persistDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	previous := s.certs
+	s.certs = certs
+	if err := s.saveToDisk(); err != nil {
+		s.certs = previous
+		return err
+	}
+	return nil
+}
+
+// handleAdminBackup serves a Backup archive. Namespace API keys are
+// refused: a backup holds the CA key and every namespace's certificates.
+func (s *Server) handleAdminBackup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, scoped := requestNamespace(r); scoped {
+		http.Error(w, "Forbidden: backups require the admin API key or a bearer token", http.StatusForbidden)
+		return
+	}
+
+	// Buffer the archive so a failure can still be reported as an error
+	var buf bytes.Buffer
+	if err := s.ca.Backup(&buf); err != nil {
+		log.Printf("[ca] Backup failed: %v", err)
+		http.Error(w, "Backup failed", http.StatusInternalServerError)
+		return
+	}
+
+	filename := "ca-backup-" + time.Now().UTC().Format("20060102-150405") + ".tar.gz"
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(buf.Bytes())
+	log.Printf("[ca] Backup downloaded by %s", r.RemoteAddr)
+}
+
+// Snapshot writes a Backup archive named ca-backup-<time>.tar.gz into dir
+// and returns its path. The file is written atomically.
+func (ca *CA) Snapshot(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := ca.Backup(&buf); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "ca-backup-"+time.Now().UTC().Format("20060102-150405.000")+".tar.gz")
+	if err := fileutil.WriteAtomic(path, buf.Bytes(), 0600); err != nil {
+		return "", fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return path, nil
+}
+
+// snapshotter takes scheduled snapshots until closed
+type snapshotter struct {
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+// startSnapshots snapshots the CA every interval, keeping the newest keep
+// snapshots in dir
+func (ca *CA) startSnapshots(dir string, interval time.Duration, keep int) *snapshotter {
+	if keep <= 0 {
+		keep = DefaultSnapshotKeep
+	}
+	s := &snapshotter{stop: make(chan struct{})}
+	s.done.Add(1)
+	go func() {
+		defer s.done.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				if _, err := ca.Snapshot(dir); err != nil {
+					fmt.Printf("[ca] Snapshot failed: %v\n", err)
+					continue
+				}
+				pruneSnapshots(dir, keep)
+			}
+		}
+	}()
+	return s
+}
+
+func (s *snapshotter) close() {
+	close(s.stop)
+	s.done.Wait()
+}
+
+// pruneSnapshots deletes all but the newest keep snapshots in dir
+func pruneSnapshots(dir string, keep int) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var snapshots []string
+	for _, entry := range entries {
+		if name := entry.Name(); strings.HasPrefix(name, "ca-backup-") && strings.HasSuffix(name, ".tar.gz") {
+			snapshots = append(snapshots, name)
+		}
+	}
+	// Timestamped names sort chronologically
+	sort.Sort(sort.Reverse(sort.StringSlice(snapshots)))
+	for i := keep; i < len(snapshots); i++ {
+		os.Remove(filepath.Join(dir, snapshots[i]))
+	}
+}
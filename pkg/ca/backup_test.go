@@ -0,0 +1,277 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newBackupTestCA(t *testing.T, persistDir, passphrase string) *CA {
+	t.Helper()
+	return newTestCA(t, func(config *CAConfig) {
+		config.PersistDir = persistDir
+		config.KeyPassphrase = passphrase
+	})
+}
+
+func TestBackupRestore(t *testing.T) {
+	source := newBackupTestCA(t, t.TempDir(), "")
+	if _, _, err := source.GenerateCertificateV2("api", []string{"api.local"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := source.GenerateCertificateV2("web", []string{"web.local"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	if err := source.Backup(&archive); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	// Restore into a different persisted CA, as on another machine
+	targetDir := t.TempDir()
+	target := newBackupTestCA(t, targetDir, "")
+	if err := target.Restore(bytes.NewReader(archive.Bytes())); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if !target.Certificate().Equal(source.Certificate()) || target.GetCertificateCount() != 2 {
+		t.Fatalf("Expected the source root and 2 certificates, got %d", target.GetCertificateCount())
+	}
+	if checkKey := target.checkKey(); checkKey.Status != HealthOK {
+		t.Errorf("Restored key doesn't match the root: %+v", checkKey)
+	}
+
+	// The restored state is persisted
+	reloaded := newBackupTestCA(t, targetDir, "")
+	if !reloaded.Certificate().Equal(source.Certificate()) || reloaded.GetCertificateCount() != 2 {
+		t.Errorf("Expected the restore to be persisted, got %d certificates", reloaded.GetCertificateCount())
+	}
+
+	// RAM-only CAs restore too
+	ram := newBackupTestCA(t, "", "")
+	if err := ram.Restore(bytes.NewReader(archive.Bytes())); err != nil || ram.GetCertificateCount() != 2 {
+		t.Errorf("RAM restore: %v, %d certificates", err, ram.GetCertificateCount())
+	}
+}
+
+func TestBackupRestoreEncrypted(t *testing.T) {
+	source := newBackupTestCA(t, t.TempDir(), "correct horse")
+	if _, _, err := source.GenerateCertificateV2("api", []string{"api.local"}); err != nil {
+		t.Fatal(err)
+	}
+	var archive bytes.Buffer
+	if err := source.Backup(&archive); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(archive.Bytes(), []byte("PRIVATE KEY")) {
+		t.Error("Expected no plaintext private keys in an encrypted backup")
+	}
+
+	plain := newBackupTestCA(t, "", "")
+	if err := plain.Restore(bytes.NewReader(archive.Bytes())); !errors.Is(err, ErrKeyEncrypted) {
+		t.Errorf("Expected ErrKeyEncrypted without the passphrase, got %v", err)
+	}
+
+	encrypted := newBackupTestCA(t, t.TempDir(), "correct horse")
+	if err := encrypted.Restore(bytes.NewReader(archive.Bytes())); err != nil {
+		t.Fatalf("Restore with the passphrase failed: %v", err)
+	}
+	certs := encrypted.GetIssuedCertificates()
+	if len(certs) != 1 || !strings.Contains(certs[0].PrivateKey, "BEGIN") || isEncryptedKeyPEM([]byte(certs[0].PrivateKey)) {
+		t.Errorf("Expected the leaf key to be decrypted in memory, got %+v", certs)
+	}
+}
+
+func TestRestoreRejectsInvalidArchives(t *testing.T) {
+	target := newBackupTestCA(t, "", "")
+	root := target.Certificate()
+
+	for name, data := range map[string][]byte{
+		"not gzip": []byte("hello"),
+		"empty":    {},
+	} {
+		if err := target.Restore(bytes.NewReader(data)); !errors.Is(err, ErrInvalidBackup) {
+			t.Errorf("%s: expected ErrInvalidBackup, got %v", name, err)
+		}
+	}
+	if !target.Certificate().Equal(root) {
+		t.Error("A failed restore changed the root")
+	}
+}
+
+// withStore returns a copy of a Backup archive with its certificate store
+// replaced
+func withStore(t *testing.T, archive []byte, store string) []byte {
+	t.Helper()
+	files, err := readBackupFiles(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files[backupStoreFile] = []byte(store)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, data := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(data))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRestoreRejectsInvalidStoreEntries(t *testing.T) {
+	var archive bytes.Buffer
+	if err := newBackupTestCA(t, "", "").Backup(&archive); err != nil {
+		t.Fatal(err)
+	}
+	target := newBackupTestCA(t, "", "")
+	root := target.Certificate()
+
+	for name, store := range map[string]string{
+		"null entry":      `{"x":null}`,
+		"serial mismatch": `{"01":{"serial_number":"02"}}`,
+	} {
+		if err := target.Restore(bytes.NewReader(withStore(t, archive.Bytes(), store))); !errors.Is(err, ErrInvalidBackup) {
+			t.Errorf("%s: expected ErrInvalidBackup, got %v", name, err)
+		}
+	}
+	if !target.Certificate().Equal(root) {
+		t.Error("A rejected restore changed the root")
+	}
+}
+
+func TestRestoreFailureKeepsRoot(t *testing.T) {
+	var archive bytes.Buffer
+	if err := newBackupTestCA(t, "", "").Backup(&archive); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	target := newBackupTestCA(t, dir, "")
+	root := target.Certificate()
+	rootPEM, err := os.ReadFile(filepath.Join(dir, "ca-cert.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A directory in the way makes saving the certificate store fail
+	storePath := filepath.Join(dir, "cert-store.json")
+	os.Remove(storePath)
+	if err := os.Mkdir(storePath, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := target.Restore(bytes.NewReader(archive.Bytes())); err == nil {
+		t.Fatal("Expected the restore to fail")
+	}
+	if !target.Certificate().Equal(root) {
+		t.Error("A failed restore changed the running root")
+	}
+	if onDisk, _ := os.ReadFile(filepath.Join(dir, "ca-cert.pem")); !bytes.Equal(onDisk, rootPEM) {
+		t.Error("A failed restore left the restored root on disk")
+	}
+}
+
+func TestAdminBackupEndpoint(t *testing.T) {
+	server, err := NewServer(&ServerConfig{
+		CAConfig:         DefaultCAConfig(),
+		GUIAPIKey:        "admin",
+		NamespaceAPIKeys: map[string]string{"key-a": "team-a"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := server.authenticateAdmin(http.HandlerFunc(server.handleAdminBackup))
+
+	for key, want := range map[string]int{"": http.StatusUnauthorized, "key-a": http.StatusForbidden, "admin": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodGet, "/admin/backup", nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("Key %q: expected %d, got %d", key, want, rr.Code)
+			continue
+		}
+		if want == http.StatusOK {
+			if !strings.Contains(rr.Header().Get("Content-Disposition"), "ca-backup-") {
+				t.Errorf("Expected an attachment, got %q", rr.Header().Get("Content-Disposition"))
+			}
+			if err := newBackupTestCA(t, "", "").Restore(rr.Body); err != nil {
+				t.Errorf("Downloaded backup doesn't restore: %v", err)
+			}
+		}
+	}
+
+	// Without an admin credential nobody may download the CA key
+	open, err := NewServer(&ServerConfig{CAConfig: DefaultCAConfig()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	open.authenticateAdmin(http.HandlerFunc(open.handleAdminBackup)).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/backup", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 without an admin credential, got %d", rr.Code)
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	config := DefaultCAConfig()
+	config.KeySize = 2048
+	config.SnapshotDir = dir
+	config.SnapshotInterval = 20 * time.Millisecond
+	config.SnapshotKeep = 2
+	authority, err := NewCA(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		matches, _ := filepath.Glob(filepath.Join(dir, "ca-backup-*.tar.gz"))
+		if len(matches) >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected scheduled snapshots, found %v", matches)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	authority.Close()
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "ca-backup-*.tar.gz"))
+	if len(matches) != 2 {
+		t.Errorf("Expected SnapshotKeep to keep 2 snapshots, found %d", len(matches))
+	}
+	file, err := os.Open(matches[len(matches)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := newBackupTestCA(t, "", "").Restore(file); err != nil {
+		t.Errorf("Snapshot doesn't restore: %v", err)
+	}
+}
@@ -0,0 +1,245 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+	"unicode/utf16"
+)
+
+// Bundle formats accepted by GET /ca/bundle?format=
+const (
+	BundleFormatPEM = "pem" // Concatenated PEM certificates (default)
+	BundleFormatDER = "der" // Concatenated DER certificates
+	BundleFormatJKS = "jks" // Java KeyStore with one trusted certificate entry per certificate
+)
+
+// DefaultJKSPassword protects JKS bundles unless ?password= is given. It is
+// the JDK's default trust store password.
+const DefaultJKSPassword = "changeit"
+
+// parseBundleCertsPEM parses extra bundle certificates from CAConfig
+func parseBundleCertsPEM(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for len(bytes.TrimSpace(data)) > 0 {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("invalid PEM data")
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// Bundle returns the certificates clients should trust: the root CA first,
+// followed by any intermediates or previous roots added to the bundle
+func (ca *CA) Bundle() []*x509.Certificate {
+	ca.mutex.RLock()
+	defer ca.mutex.RUnlock()
+
+	certs := []*x509.Certificate{ca.cert}
+	for _, cert := range ca.bundleExtra {
+		if !cert.Equal(ca.cert) {
+			certs = append(certs, cert)
+		}
+	}
+	return certs
+}
+
+// BundlePEM returns Bundle as concatenated PEM, suitable for SSL_CERT_FILE,
+// NODE_EXTRA_CA_CERTS, REQUESTS_CA_BUNDLE, and similar settings
+func (ca *CA) BundlePEM() []byte {
+	var buf bytes.Buffer
+	for _, cert := range ca.Bundle() {
+		pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	return buf.Bytes()
+}
+
+// AddBundleCertificate publishes an additional certificate in the CA bundle,
+// such as an intermediate or a previous root that issued certificates still
+// in use. Adding a certificate already in the bundle has no effect.
+func (ca *CA) AddBundleCertificate(cert *x509.Certificate) {
+	ca.mutex.Lock()
+	defer ca.mutex.Unlock()
+
+	if cert.Equal(ca.cert) {
+		return
+	}
+	for _, existing := range ca.bundleExtra {
+		if existing.Equal(cert) {
+			return
+		}
+	}
+	ca.bundleExtra = append(ca.bundleExtra, cert)
+}
+
+// EncodeBundle encodes certificates in one of the bundle formats. The
+// password is only used for JKS. It returns the data and its content type.
+func EncodeBundle(certs []*x509.Certificate, format, password string) ([]byte, string, error) {
+	switch format {
+	case "", BundleFormatPEM:
+		var buf bytes.Buffer
+		for _, cert := range certs {
+			pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+		}
+		return buf.Bytes(), "application/x-pem-file", nil
+	case BundleFormatDER:
+		var buf bytes.Buffer
+		for _, cert := range certs {
+			buf.Write(cert.Raw)
+		}
+		return buf.Bytes(), "application/pkix-cert", nil
+	case BundleFormatJKS:
+		if password == "" {
+			password = DefaultJKSPassword
+		}
+		return encodeJKS(certs, password), "application/x-java-keystore", nil
+	default:
+		return nil, "", fmt.Errorf("unsupported bundle format %q (expected %s, %s, or %s)", format, BundleFormatPEM, BundleFormatDER, BundleFormatJKS)
+	}
+}
+
+// JKS constants from the JDK's sun.security.provider.JavaKeyStore
+const (
+	jksMagic            = 0xFEEDFEED
+	jksVersion          = 2
+	jksTrustedCertEntry = 2
+	jksIntegritySalt    = "Mighty Aphrodite"
+)
+
+// encodeJKS writes a version 2 Java KeyStore holding certs as trusted
+// certificate entries aliased sharedgolibs-ca, sharedgolibs-ca-1, ...
+func encodeJKS(certs []*x509.Certificate, password string) []byte {
+	var buf bytes.Buffer
+	write := func(v interface{}) { binary.Write(&buf, binary.BigEndian, v) }
+	writeUTF := func(s string) {
+		write(uint16(len(s)))
+		buf.WriteString(s)
+	}
+
+	write(uint32(jksMagic))
+	write(uint32(jksVersion))
+	write(uint32(len(certs)))
+	for i, cert := range certs {
+		alias := "sharedgolibs-ca"
+		if i > 0 {
+			alias = fmt.Sprintf("sharedgolibs-ca-%d", i)
+		}
+
+		write(uint32(jksTrustedCertEntry))
+		writeUTF(alias)
+		write(cert.NotBefore.UnixMilli()) // Entry creation date
+		writeUTF("X.509")
+		write(uint32(len(cert.Raw)))
+		buf.Write(cert.Raw)
+	}
+
+	// Integrity check: SHA-1 over the UTF-16BE password, a fixed salt, and the store
+	digest := sha1.New()
+	for _, c := range utf16.Encode([]rune(password)) {
+		digest.Write([]byte{byte(c >> 8), byte(c)})
+	}
+	digest.Write([]byte(jksIntegritySalt))
+	digest.Write(buf.Bytes())
+	buf.Write(digest.Sum(nil))
+
+	return buf.Bytes()
+}
+
+// handleCABundle serves the CA bundle in the requested format
+func (s *Server) handleCABundle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = BundleFormatPEM
+	}
+	data, contentType, err := EncodeBundle(s.ca.Bundle(), format, r.URL.Query().Get("password"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=sharedgolibs-ca-bundle.%s", format))
+	w.Write(data)
+}
+
+// FetchCABundle downloads the PEM CA bundle from the CA server, ready to be
+// written to a file referenced by SSL_CERT_FILE, NODE_EXTRA_CA_CERTS,
+// REQUESTS_CA_BUNDLE, or CURL_CA_BUNDLE for non-Go processes.
+//
+// Environment Variables Used:
+//   - SGL_CA (required): CA server URL (must be http:// or https://)
+//   - SGL_CA_API_KEY (optional): API key for CA server authentication
+//   - SGL_CA_TOKEN (optional): Bearer token for CA server authentication
+//
+// The request times out after 10 seconds, so a CA server that accepts the
+// connection but never answers doesn't hang the caller.
+func FetchCABundle() ([]byte, error) {
+	caURL, err := getValidatedCAURL()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", caURL+"/ca/bundle", nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCARequest, err)
+	}
+
+	// Add API key or bearer token if configured
+	setAuthHeaders(req)
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCARequest, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, ErrUnauthorized
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: HTTP %d", ErrCARequest, resp.StatusCode)
+	}
+
+	bundle, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCAResponse, err)
+	}
+
+	// Never hand back something that would break a trust store file
+	certs, err := parseBundleCertsPEM(bundle)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCertParse, err)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%w: bundle contains no certificates", ErrCertParse)
+	}
+
+	return bundle, nil
+}
@@ -0,0 +1,163 @@
+package ca
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"unicode/utf16"
+)
+
+// newBundleTestCA creates a small CA whose bundle also carries a previous root
+func newBundleTestCA(t *testing.T) (*CA, *CA) {
+	t.Helper()
+	previous := newTestCA(t, func(config *CAConfig) { config.CommonName = "Previous Root CA" })
+	current := newTestCA(t, func(config *CAConfig) { config.BundleCertsPEM = previous.CertificatePEM() })
+	return current, previous
+}
+
+func TestCABundle(t *testing.T) {
+	current, previous := newBundleTestCA(t)
+
+	bundle := current.Bundle()
+	if len(bundle) != 2 || !bundle[0].Equal(current.Certificate()) || !bundle[1].Equal(previous.Certificate()) {
+		t.Fatalf("Expected root followed by previous root, got %d certificates", len(bundle))
+	}
+
+	// Duplicates and the root itself are not added again
+	current.AddBundleCertificate(previous.Certificate())
+	current.AddBundleCertificate(current.Certificate())
+	if len(current.Bundle()) != 2 {
+		t.Errorf("Expected bundle to stay at 2 certificates, got %d", len(current.Bundle()))
+	}
+
+	certs, err := parseBundleCertsPEM(current.BundlePEM())
+	if err != nil || len(certs) != 2 {
+		t.Errorf("Expected BundlePEM to hold 2 certificates, got %d (%v)", len(certs), err)
+	}
+
+	if _, err := NewCA(&CAConfig{KeySize: 2048, BundleCertsPEM: []byte("not pem")}); err == nil {
+		t.Error("Expected error for invalid BundleCertsPEM")
+	}
+}
+
+func TestEncodeBundle(t *testing.T) {
+	current, _ := newBundleTestCA(t)
+	certs := current.Bundle()
+
+	der, contentType, err := EncodeBundle(certs, BundleFormatDER, "")
+	if err != nil || contentType != "application/pkix-cert" {
+		t.Fatalf("DER encoding failed: %v (%s)", err, contentType)
+	}
+	parsed, err := x509.ParseCertificates(der)
+	if err != nil || len(parsed) != 2 {
+		t.Errorf("Expected 2 concatenated DER certificates, got %d (%v)", len(parsed), err)
+	}
+
+	if _, _, err := EncodeBundle(certs, "p12", ""); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+}
+
+func TestEncodeJKS(t *testing.T) {
+	current, _ := newBundleTestCA(t)
+	certs := current.Bundle()
+
+	data, _, err := EncodeBundle(certs, BundleFormatJKS, "")
+	if err != nil {
+		t.Fatalf("JKS encoding failed: %v", err)
+	}
+
+	// Verify the integrity digest the way keytool does
+	body, digest := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+	hash := sha1.New()
+	for _, c := range utf16.Encode([]rune(DefaultJKSPassword)) {
+		hash.Write([]byte{byte(c >> 8), byte(c)})
+	}
+	hash.Write([]byte(jksIntegritySalt))
+	hash.Write(body)
+	if !bytes.Equal(hash.Sum(nil), digest) {
+		t.Fatal("JKS integrity digest does not match the default password")
+	}
+
+	r := bytes.NewReader(body)
+	var magic, version, count uint32
+	binary.Read(r, binary.BigEndian, &magic)
+	binary.Read(r, binary.BigEndian, &version)
+	binary.Read(r, binary.BigEndian, &count)
+	if magic != jksMagic || version != jksVersion || count != 2 {
+		t.Fatalf("Unexpected JKS header: %x %d %d", magic, version, count)
+	}
+
+	readUTF := func() string {
+		var n uint16
+		binary.Read(r, binary.BigEndian, &n)
+		s := make([]byte, n)
+		io.ReadFull(r, s)
+		return string(s)
+	}
+	for i, want := range []string{"sharedgolibs-ca", "sharedgolibs-ca-1"} {
+		var tag uint32
+		var created int64
+		binary.Read(r, binary.BigEndian, &tag)
+		alias := readUTF()
+		binary.Read(r, binary.BigEndian, &created)
+		certType := readUTF()
+		var length uint32
+		binary.Read(r, binary.BigEndian, &length)
+		raw := make([]byte, length)
+		io.ReadFull(r, raw)
+
+		if tag != jksTrustedCertEntry || alias != want || certType != "X.509" || !bytes.Equal(raw, certs[i].Raw) {
+			t.Errorf("Entry %d: unexpected tag=%d alias=%s type=%s", i, tag, alias, certType)
+		}
+	}
+	if r.Len() != 0 {
+		t.Errorf("Expected no trailing data, got %d bytes", r.Len())
+	}
+}
+
+func TestCABundleEndpointAndFetch(t *testing.T) {
+	current, _ := newBundleTestCA(t)
+	server := &Server{ca: current}
+	ts := httptest.NewServer(http.HandlerFunc(server.handleCABundle))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "?format=jks&password=secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/x-java-keystore" {
+		t.Errorf("Unexpected JKS response: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	resp, err = http.Get(ts.URL + "?format=xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unknown format, got %d", resp.StatusCode)
+	}
+
+	// FetchCABundle appends /ca/bundle to SGL_CA
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ca/bundle", server.handleCABundle)
+	caServer := httptest.NewServer(mux)
+	defer caServer.Close()
+
+	t.Setenv("SGL_CA", caServer.URL)
+	t.Setenv("SGL_CA_API_KEY", "")
+	bundle, err := FetchCABundle()
+	if err != nil {
+		t.Fatalf("FetchCABundle failed: %v", err)
+	}
+	if !bytes.Equal(bundle, current.BundlePEM()) {
+		t.Error("Expected fetched bundle to match BundlePEM")
+	}
+}
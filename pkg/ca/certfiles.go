@@ -0,0 +1,275 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Default file names and modes used by WriteCertFiles
+const (
+	DefaultCertFileName  = "tls.crt"
+	DefaultKeyFileName   = "tls.key"
+	DefaultChainFileName = "ca.crt"
+
+	DefaultCertFileMode os.FileMode = 0644
+	DefaultKeyFileMode  os.FileMode = 0600
+
+	DefaultNotifyTimeout = 30 * time.Second
+)
+
+// ErrNotifyFailed is returned by WriteCertFiles when the files were written
+// but the consuming process could not be signaled or the command failed
+var ErrNotifyFailed = errors.New("certificate files written but notification failed")
+
+// WriteCertOptions controls how WriteCertFiles writes and announces files.
+// The zero value writes tls.crt, tls.key, and ca.crt with modes 0644/0600.
+type WriteCertOptions struct {
+	CertFile      string // Leaf certificate (default tls.crt); relative names are joined to dir
+	KeyFile       string // Private key (default tls.key)
+	ChainFile     string // CA certificate (default ca.crt; "-" to skip)
+	FullChainFile string // Leaf followed by the CA certificate, e.g. for nginx (empty = not written)
+
+	CertMode os.FileMode // Mode of the certificate and chain files (0 = 0644)
+	KeyMode  os.FileMode // Mode of the private key (0 = 0600)
+
+	// Owner changes the files' owner, as "user", "user:group", or numeric
+	// "uid:gid" (empty = unchanged). Usually requires root.
+	Owner string
+
+	// Signal is sent to the process in PID, or the one named in PIDFile,
+	// after the files are in place (nil with a PID or PIDFile = SIGHUP)
+	Signal  os.Signal
+	PID     int
+	PIDFile string
+
+	// Command runs after the files are in place, e.g. {"nginx", "-s", "reload"}
+	Command       []string
+	NotifyTimeout time.Duration // Limit for Command (0 = DefaultNotifyTimeout)
+}
+
+// CertFiles are the paths written by WriteCertFiles
+type CertFiles struct {
+	CertFile      string
+	KeyFile       string
+	ChainFile     string // Empty when skipped
+	FullChainFile string // Empty when not requested
+}
+
+// WriteCertFiles writes a certificate response to dir and notifies the
+// process that uses it. All files are staged as temporaries with their final
+// mode and owner, then renamed into place, so readers never see a partial
+// file or a world-readable key. The returned paths are valid whenever the
+// files were written, including when the error wraps ErrNotifyFailed.
+func WriteCertFiles(resp *CertResponse, dir string, opts *WriteCertOptions) (*CertFiles, error) {
+	if resp == nil || resp.Certificate == "" || resp.PrivateKey == "" {
+		return nil, errors.New("certificate response has no certificate or private key")
+	}
+	if opts == nil {
+		opts = &WriteCertOptions{}
+	}
+
+	uid, gid, err := lookupOwner(opts.Owner)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	certMode := opts.CertMode
+	if certMode == 0 {
+		certMode = DefaultCertFileMode
+	}
+	keyMode := opts.KeyMode
+	if keyMode == 0 {
+		keyMode = DefaultKeyFileMode
+	}
+	path := func(name, fallback string) string {
+		if name == "" {
+			name = fallback
+		}
+		if filepath.IsAbs(name) {
+			return name
+		}
+		return filepath.Join(dir, name)
+	}
+
+	files := &CertFiles{
+		CertFile: path(opts.CertFile, DefaultCertFileName),
+		KeyFile:  path(opts.KeyFile, DefaultKeyFileName),
+	}
+	// The key goes first so the certificate never points at an old key
+	staged := []stagedFile{
+		{path: files.KeyFile, data: []byte(resp.PrivateKey), mode: keyMode},
+		{path: files.CertFile, data: []byte(resp.Certificate), mode: certMode},
+	}
+	if opts.ChainFile != "-" && resp.CACert != "" {
+		files.ChainFile = path(opts.ChainFile, DefaultChainFileName)
+		staged = append(staged, stagedFile{path: files.ChainFile, data: []byte(resp.CACert), mode: certMode})
+	}
+	if opts.FullChainFile != "" {
+		files.FullChainFile = path(opts.FullChainFile, "")
+		fullChain := strings.TrimRight(resp.Certificate, "\n") + "\n" + resp.CACert
+		staged = append(staged, stagedFile{path: files.FullChainFile, data: []byte(fullChain), mode: certMode})
+	}
+
+	defer func() {
+		for _, file := range staged {
+			if file.tmp != "" {
+				os.Remove(file.tmp)
+			}
+		}
+	}()
+	for i := range staged {
+		if err := staged[i].stage(uid, gid); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", staged[i].path, err)
+		}
+	}
+	for i := range staged {
+		if err := os.Rename(staged[i].tmp, staged[i].path); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", staged[i].path, err)
+		}
+		staged[i].tmp = ""
+	}
+
+	if err := notifyCertConsumer(opts); err != nil {
+		return files, fmt.Errorf("%w: %v", ErrNotifyFailed, err)
+	}
+	return files, nil
+}
+
+// stagedFile is a file written to a temporary name next to its destination
+type stagedFile struct {
+	path string
+	data []byte
+	mode os.FileMode
+	tmp  string
+}
+
+// stage writes the temporary file with its final mode and owner (-1 keeps
+// the current uid or gid)
+func (f *stagedFile) stage(uid, gid int) error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), "."+filepath.Base(f.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	f.tmp = tmp.Name()
+
+	// Restrict the mode before writing so a key is never readable by others
+	if err := tmp.Chmod(f.mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if uid != -1 || gid != -1 {
+		if err := tmp.Chown(uid, gid); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if _, err := tmp.Write(f.data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	return tmp.Close()
+}
+
+// lookupOwner resolves "user", "user:group", or "uid:gid" to numeric ids,
+// with -1 for a part that is not changed
+func lookupOwner(owner string) (uid, gid int, err error) {
+	if owner == "" {
+		return -1, -1, nil
+	}
+	userName, groupName, hasGroup := strings.Cut(owner, ":")
+
+	uid, gid = -1, -1
+	if userName != "" {
+		if uid, err = strconv.Atoi(userName); err != nil {
+			u, lookupErr := user.Lookup(userName)
+			if lookupErr != nil {
+				return 0, 0, fmt.Errorf("unknown owner %q: %w", userName, lookupErr)
+			}
+			uid, _ = strconv.Atoi(u.Uid)
+			if !hasGroup {
+				gid, _ = strconv.Atoi(u.Gid)
+			}
+		}
+	}
+	if groupName != "" {
+		if gid, err = strconv.Atoi(groupName); err != nil {
+			g, lookupErr := user.LookupGroup(groupName)
+			if lookupErr != nil {
+				return 0, 0, fmt.Errorf("unknown group %q: %w", groupName, lookupErr)
+			}
+			gid, _ = strconv.Atoi(g.Gid)
+		}
+	}
+	return uid, gid, nil
+}
+
+// notifyCertConsumer signals the consuming process and runs the command
+// configured in opts
+func notifyCertConsumer(opts *WriteCertOptions) error {
+	pid := opts.PID
+	if opts.PIDFile != "" {
+		data, err := os.ReadFile(opts.PIDFile)
+		if err != nil {
+			return fmt.Errorf("reading PID file: %v", err)
+		}
+		if pid, err = strconv.Atoi(strings.TrimSpace(string(data))); err != nil || pid <= 0 {
+			return fmt.Errorf("PID file %s does not contain a process ID", opts.PIDFile)
+		}
+	}
+	if pid > 0 {
+		sig := opts.Signal
+		if sig == nil {
+			sig = syscall.SIGHUP
+		}
+		process, err := os.FindProcess(pid)
+		if err != nil {
+			return fmt.Errorf("finding process %d: %v", pid, err)
+		}
+		if err := process.Signal(sig); err != nil {
+			return fmt.Errorf("sending %v to process %d: %v", sig, pid, err)
+		}
+	}
+
+	if len(opts.Command) > 0 {
+		timeout := opts.NotifyTimeout
+		if timeout == 0 {
+			timeout = DefaultNotifyTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		var output bytes.Buffer
+		cmd := exec.CommandContext(ctx, opts.Command[0], opts.Command[1:]...)
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		if err := cmd.Run(); err != nil {
+			if out := strings.TrimSpace(output.String()); out != "" {
+				return fmt.Errorf("running %s: %v: %s", opts.Command[0], err, out)
+			}
+			return fmt.Errorf("running %s: %v", opts.Command[0], err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package ca
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func testCertResponse(t *testing.T) *CertResponse {
+	t.Helper()
+	config := DefaultCAConfig()
+	config.KeySize = 2048
+	ca, err := NewCA(config)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	resp, err := ca.IssueServiceCertificateV2(CertRequestV2{ServiceName: "web", SANs: []string{"web.local"}})
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+	return resp
+}
+
+func TestWriteCertFiles(t *testing.T) {
+	resp := testCertResponse(t)
+
+	t.Run("Defaults", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "certs")
+		files, err := WriteCertFiles(resp, dir, nil)
+		if err != nil {
+			t.Fatalf("WriteCertFiles failed: %v", err)
+		}
+
+		want := map[string]struct {
+			content string
+			mode    os.FileMode
+		}{
+			files.CertFile:  {resp.Certificate, 0644},
+			files.KeyFile:   {resp.PrivateKey, 0600},
+			files.ChainFile: {resp.CACert, 0644},
+		}
+		for path, w := range want {
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("Expected %s to exist: %v", path, err)
+			}
+			if info.Mode().Perm() != w.mode {
+				t.Errorf("Expected %s mode %v, got %v", path, w.mode, info.Mode().Perm())
+			}
+			data, _ := os.ReadFile(path)
+			if string(data) != w.content {
+				t.Errorf("Unexpected content in %s", path)
+			}
+		}
+		if filepath.Base(files.KeyFile) != DefaultKeyFileName || files.FullChainFile != "" {
+			t.Errorf("Unexpected paths: %+v", files)
+		}
+
+		// No temporary files are left behind
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 3 {
+			t.Errorf("Expected 3 files, got %d", len(entries))
+		}
+	})
+
+	t.Run("CustomNamesAndFullChain", func(t *testing.T) {
+		dir := t.TempDir()
+		absKey := filepath.Join(t.TempDir(), "private", "web.key")
+		files, err := WriteCertFiles(resp, dir, &WriteCertOptions{
+			CertFile:      "web.crt",
+			KeyFile:       absKey,
+			ChainFile:     "-",
+			FullChainFile: "fullchain.pem",
+			KeyMode:       0640,
+		})
+		if err != nil {
+			t.Fatalf("WriteCertFiles failed: %v", err)
+		}
+		if files.KeyFile != absKey || files.ChainFile != "" || files.CertFile != filepath.Join(dir, "web.crt") {
+			t.Errorf("Unexpected paths: %+v", files)
+		}
+		if info, _ := os.Stat(absKey); info.Mode().Perm() != 0640 {
+			t.Errorf("Expected key mode 0640, got %v", info.Mode().Perm())
+		}
+		if _, err := os.Stat(filepath.Join(dir, DefaultChainFileName)); !os.IsNotExist(err) {
+			t.Error("Expected the chain file to be skipped")
+		}
+		fullChain, _ := os.ReadFile(files.FullChainFile)
+		if strings.Count(string(fullChain), "BEGIN CERTIFICATE") != 2 || !strings.HasPrefix(string(fullChain), resp.Certificate) {
+			t.Errorf("Expected leaf followed by CA in the full chain, got:\n%s", fullChain)
+		}
+	})
+
+	t.Run("ReplacesExistingFiles", func(t *testing.T) {
+		dir := t.TempDir()
+		keyPath := filepath.Join(dir, DefaultKeyFileName)
+		os.WriteFile(keyPath, []byte("old"), 0644)
+
+		if _, err := WriteCertFiles(resp, dir, nil); err != nil {
+			t.Fatalf("WriteCertFiles failed: %v", err)
+		}
+		info, _ := os.Stat(keyPath)
+		data, _ := os.ReadFile(keyPath)
+		if string(data) != resp.PrivateKey || info.Mode().Perm() != 0600 {
+			t.Errorf("Expected the old world-readable key to be replaced, got mode %v", info.Mode().Perm())
+		}
+	})
+
+	t.Run("Owner", func(t *testing.T) {
+		dir := t.TempDir()
+		owner := fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid())
+		if _, err := WriteCertFiles(resp, dir, &WriteCertOptions{Owner: owner}); err != nil {
+			t.Fatalf("Expected chown to the current user to succeed, got %v", err)
+		}
+		if _, err := WriteCertFiles(resp, dir, &WriteCertOptions{Owner: "no-such-user-sgl"}); err == nil {
+			t.Error("Expected an unknown owner to fail")
+		}
+	})
+
+	t.Run("InvalidResponse", func(t *testing.T) {
+		if _, err := WriteCertFiles(&CertResponse{Certificate: resp.Certificate}, t.TempDir(), nil); err == nil {
+			t.Error("Expected a response without a key to fail")
+		}
+	})
+}
+
+func TestWriteCertFilesNotify(t *testing.T) {
+	resp := testCertResponse(t)
+
+	t.Run("Signal", func(t *testing.T) {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGUSR1)
+		defer signal.Stop(signals)
+
+		dir := t.TempDir()
+		pidFile := filepath.Join(dir, "app.pid")
+		os.WriteFile(pidFile, []byte(fmt.Sprintf("%d\n", os.Getpid())), 0644)
+
+		if _, err := WriteCertFiles(resp, dir, &WriteCertOptions{PIDFile: pidFile, Signal: syscall.SIGUSR1}); err != nil {
+			t.Fatalf("WriteCertFiles failed: %v", err)
+		}
+		select {
+		case <-signals:
+		case <-time.After(5 * time.Second):
+			t.Error("Expected SIGUSR1 to be delivered")
+		}
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		dir := t.TempDir()
+		marker := filepath.Join(dir, "reloaded")
+		if _, err := WriteCertFiles(resp, dir, &WriteCertOptions{Command: []string{"touch", marker}}); err != nil {
+			t.Fatalf("WriteCertFiles failed: %v", err)
+		}
+		if _, err := os.Stat(marker); err != nil {
+			t.Errorf("Expected the command to run: %v", err)
+		}
+	})
+
+	t.Run("FailureKeepsFiles", func(t *testing.T) {
+		dir := t.TempDir()
+		files, err := WriteCertFiles(resp, dir, &WriteCertOptions{Command: []string{"sh", "-c", "echo reload failed; exit 3"}})
+		if !errors.Is(err, ErrNotifyFailed) || !strings.Contains(err.Error(), "reload failed") {
+			t.Fatalf("Expected ErrNotifyFailed with the command output, got %v", err)
+		}
+		if files == nil {
+			t.Fatal("Expected written paths alongside the notification error")
+		}
+		if _, err := os.Stat(files.CertFile); err != nil {
+			t.Errorf("Expected the certificate to be written: %v", err)
+		}
+
+		if _, err := WriteCertFiles(resp, dir, &WriteCertOptions{PIDFile: filepath.Join(dir, "missing.pid")}); !errors.Is(err, ErrNotifyFailed) {
+			t.Errorf("Expected ErrNotifyFailed for a missing PID file, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,271 @@
+// SPDX-License-Identifier: CC0-1.0
+
+// Package certinfo parses X.509 certificates and describes them in a
+// structured, display-ready form.
+package certinfo
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Summary is a structured description of a certificate
+type Summary struct {
+	Subject            string    `json:"subject"`
+	Issuer             string    `json:"issuer"`
+	CommonName         string    `json:"common_name"`
+	SerialNumber       string    `json:"serial_number"`
+	DNSNames           []string  `json:"dns_names,omitempty"`
+	IPAddresses        []string  `json:"ip_addresses,omitempty"`
+	URIs               []string  `json:"uris,omitempty"`
+	EmailAddresses     []string  `json:"email_addresses,omitempty"`
+	IsCA               bool      `json:"is_ca"`
+	KeyType            string    `json:"key_type"`
+	KeySize            int       `json:"key_size,omitempty"` // Bits (RSA modulus or ECDSA curve)
+	SignatureAlgorithm string    `json:"signature_algorithm"`
+	NotBefore          time.Time `json:"not_before"`
+	NotAfter           time.Time `json:"not_after"`
+	DaysToExpiry       int       `json:"days_to_expiry"` // Negative once expired
+	Expired            bool      `json:"expired"`
+	SHA256Fingerprint  string    `json:"sha256_fingerprint"`
+	SHA1Fingerprint    string    `json:"sha1_fingerprint"`
+
+	// Chain is set when a root was supplied via WithRoots or WithRootPEM
+	Chain *ChainResult `json:"chain,omitempty"`
+}
+
+// ChainResult reports whether the certificate chains to the supplied roots
+type ChainResult struct {
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+}
+
+// SANs returns all Subject Alternative Names in DNS, IP, URI, email order
+func (s *Summary) SANs() []string {
+	sans := make([]string, 0, len(s.DNSNames)+len(s.IPAddresses)+len(s.URIs)+len(s.EmailAddresses))
+	sans = append(sans, s.DNSNames...)
+	sans = append(sans, s.IPAddresses...)
+	sans = append(sans, s.URIs...)
+	sans = append(sans, s.EmailAddresses...)
+	return sans
+}
+
+// String renders the summary as aligned "Field: value" lines
+func (s *Summary) String() string {
+	var b strings.Builder
+
+	line := func(label, value string) {
+		fmt.Fprintf(&b, "%-20s %s\n", label+":", value)
+	}
+
+	line("Subject", s.Subject)
+	line("Issuer", s.Issuer)
+	line("Serial", s.SerialNumber)
+	if sans := s.SANs(); len(sans) > 0 {
+		line("SANs", strings.Join(sans, ", "))
+	}
+	line("CA", fmt.Sprintf("%t", s.IsCA))
+	if s.KeySize > 0 {
+		line("Key", fmt.Sprintf("%s %d", s.KeyType, s.KeySize))
+	} else {
+		line("Key", s.KeyType)
+	}
+	line("Signature", s.SignatureAlgorithm)
+	line("Not Before", s.NotBefore.Format(time.RFC3339))
+	line("Not After", s.NotAfter.Format(time.RFC3339))
+	if s.Expired {
+		line("Expiry", fmt.Sprintf("EXPIRED %d days ago", -s.DaysToExpiry))
+	} else {
+		line("Expiry", fmt.Sprintf("%d days remaining", s.DaysToExpiry))
+	}
+	line("SHA-256", s.SHA256Fingerprint)
+	line("SHA-1", s.SHA1Fingerprint)
+	if s.Chain != nil {
+		if s.Chain.Valid {
+			line("Chain", "valid")
+		} else {
+			line("Chain", "INVALID: "+s.Chain.Error)
+		}
+	}
+
+	return b.String()
+}
+
+// Option configures ParseAndDescribe and Describe
+type Option func(*options)
+
+type options struct {
+	roots         *x509.CertPool
+	intermediates *x509.CertPool
+	now           time.Time
+	err           error
+}
+
+// WithRoots verifies the certificate chain against the given root pool
+func WithRoots(pool *x509.CertPool) Option {
+	return func(o *options) {
+		o.roots = pool
+	}
+}
+
+// WithRootPEM verifies the certificate chain against the PEM-encoded root
+// certificate(s)
+func WithRootPEM(rootPEM []byte) Option {
+	return func(o *options) {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(rootPEM) {
+			o.err = fmt.Errorf("no certificates found in root PEM")
+			return
+		}
+		o.roots = pool
+	}
+}
+
+// WithIntermediates adds intermediate certificates used for chain verification
+func WithIntermediates(certs ...*x509.Certificate) Option {
+	return func(o *options) {
+		if o.intermediates == nil {
+			o.intermediates = x509.NewCertPool()
+		}
+		for _, cert := range certs {
+			o.intermediates.AddCert(cert)
+		}
+	}
+}
+
+// WithTime evaluates expiry and chain validity at t instead of now
+func WithTime(t time.Time) Option {
+	return func(o *options) {
+		o.now = t
+	}
+}
+
+// ParseAndDescribe parses the first certificate in certPEM and describes it.
+// Any further certificates in certPEM are treated as intermediates when
+// verifying the chain.
+func ParseAndDescribe(certPEM []byte, opts ...Option) (*Summary, error) {
+	certs, err := ParsePEM(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) > 1 {
+		opts = append([]Option{WithIntermediates(certs[1:]...)}, opts...)
+	}
+	return Describe(certs[0], opts...)
+}
+
+// ParsePEM parses all CERTIFICATE blocks in data, skipping other block types
+func ParsePEM(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found in PEM data")
+	}
+	return certs, nil
+}
+
+// Describe builds a Summary for an already parsed certificate
+func Describe(cert *x509.Certificate, opts ...Option) (*Summary, error) {
+	o := &options{now: time.Now()}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.err != nil {
+		return nil, o.err
+	}
+
+	sha256Sum := sha256.Sum256(cert.Raw)
+	sha1Sum := sha1.Sum(cert.Raw)
+
+	s := &Summary{
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		CommonName:         cert.Subject.CommonName,
+		SerialNumber:       fmt.Sprintf("%x", cert.SerialNumber),
+		DNSNames:           cert.DNSNames,
+		EmailAddresses:     cert.EmailAddresses,
+		IsCA:               cert.IsCA,
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		Expired:            o.now.After(cert.NotAfter),
+		DaysToExpiry:       daysUntil(o.now, cert.NotAfter),
+		SHA256Fingerprint:  fingerprint(sha256Sum[:]),
+		SHA1Fingerprint:    fingerprint(sha1Sum[:]),
+	}
+
+	for _, ip := range cert.IPAddresses {
+		s.IPAddresses = append(s.IPAddresses, ip.String())
+	}
+	for _, u := range cert.URIs {
+		s.URIs = append(s.URIs, u.String())
+	}
+
+	s.KeyType, s.KeySize = describeKey(cert.PublicKey)
+
+	if o.roots != nil {
+		_, err := cert.Verify(x509.VerifyOptions{
+			Roots:         o.roots,
+			Intermediates: o.intermediates,
+			CurrentTime:   o.now,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		s.Chain = &ChainResult{Valid: err == nil}
+		if err != nil {
+			s.Chain.Error = err.Error()
+		}
+	}
+
+	return s, nil
+}
+
+// describeKey returns the public key algorithm and size in bits
+func describeKey(pub any) (string, int) {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return "RSA", key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return "ECDSA", key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return "Ed25519", 0
+	default:
+		return fmt.Sprintf("%T", pub), 0
+	}
+}
+
+// daysUntil returns whole days from now until t, rounding toward zero
+func daysUntil(now, t time.Time) int {
+	return int(t.Sub(now).Hours() / 24)
+}
+
+// fingerprint formats a digest as colon-separated uppercase hex
+func fingerprint(sum []byte) string {
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
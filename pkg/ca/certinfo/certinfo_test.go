@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package certinfo
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestCert creates a certificate signed by parent (self-signed if parent is nil)
+func newTestCert(t *testing.T, cn string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, notAfter time.Time) (*x509.Certificate, *ecdsa.PrivateKey, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	spiffe, _ := url.Parse("spiffe://cluster.local/ns/default/sa/app")
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	if !isCA {
+		template.DNSNames = []string{"app.local"}
+		template.IPAddresses = []net.IP{net.ParseIP("10.0.0.1")}
+		template.URIs = []*url.URL{spiffe}
+		template.EmailAddresses = []string{"ops@example.com"}
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent, parentKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert, key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestParseAndDescribe(t *testing.T) {
+	root, rootKey, rootPEM := newTestCert(t, "Test Root", true, nil, nil, time.Now().Add(365*24*time.Hour))
+	_, _, leafPEM := newTestCert(t, "app.local", false, root, rootKey, time.Now().Add(30*24*time.Hour+time.Hour))
+
+	s, err := ParseAndDescribe(leafPEM, WithRootPEM(rootPEM))
+	if err != nil {
+		t.Fatalf("ParseAndDescribe failed: %v", err)
+	}
+
+	if s.CommonName != "app.local" || !strings.Contains(s.Issuer, "Test Root") {
+		t.Errorf("Unexpected subject/issuer: %s / %s", s.Subject, s.Issuer)
+	}
+	if len(s.SANs()) != 4 {
+		t.Errorf("Expected 4 SANs, got %v", s.SANs())
+	}
+	if s.URIs[0] != "spiffe://cluster.local/ns/default/sa/app" {
+		t.Errorf("Unexpected URI SAN: %v", s.URIs)
+	}
+	if s.KeyType != "ECDSA" || s.KeySize != 256 {
+		t.Errorf("Expected ECDSA 256, got %s %d", s.KeyType, s.KeySize)
+	}
+	if s.DaysToExpiry != 30 || s.Expired {
+		t.Errorf("Expected 30 days to expiry, got %d (expired=%t)", s.DaysToExpiry, s.Expired)
+	}
+	if len(s.SHA256Fingerprint) != 32*3-1 || len(s.SHA1Fingerprint) != 20*3-1 {
+		t.Errorf("Unexpected fingerprint format: %s / %s", s.SHA256Fingerprint, s.SHA1Fingerprint)
+	}
+	if s.Chain == nil || !s.Chain.Valid {
+		t.Errorf("Expected valid chain, got %+v", s.Chain)
+	}
+
+	out := s.String()
+	for _, want := range []string{"Subject:", "SHA-256:", "Chain:", "valid", "30 days remaining"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("String() missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestParseAndDescribe_WrongRoot(t *testing.T) {
+	root, rootKey, _ := newTestCert(t, "Real Root", true, nil, nil, time.Now().Add(24*time.Hour))
+	_, _, otherPEM := newTestCert(t, "Other Root", true, nil, nil, time.Now().Add(24*time.Hour))
+	_, _, leafPEM := newTestCert(t, "app.local", false, root, rootKey, time.Now().Add(24*time.Hour))
+
+	s, err := ParseAndDescribe(leafPEM, WithRootPEM(otherPEM))
+	if err != nil {
+		t.Fatalf("ParseAndDescribe failed: %v", err)
+	}
+	if s.Chain == nil || s.Chain.Valid || s.Chain.Error == "" {
+		t.Errorf("Expected invalid chain with error, got %+v", s.Chain)
+	}
+}
+
+func TestParseAndDescribe_Expired(t *testing.T) {
+	_, _, certPEM := newTestCert(t, "old", true, nil, nil, time.Now().Add(-48*time.Hour-time.Hour))
+
+	s, err := ParseAndDescribe(certPEM)
+	if err != nil {
+		t.Fatalf("ParseAndDescribe failed: %v", err)
+	}
+	if !s.Expired || s.DaysToExpiry != -2 {
+		t.Errorf("Expected expired 2 days ago, got expired=%t days=%d", s.Expired, s.DaysToExpiry)
+	}
+	if s.Chain != nil {
+		t.Error("Chain should be nil when no root is supplied")
+	}
+	if !strings.Contains(s.String(), "EXPIRED 2 days ago") {
+		t.Errorf("Expected expired notice in output:\n%s", s.String())
+	}
+}
+
+func TestParseAndDescribe_Errors(t *testing.T) {
+	if _, err := ParseAndDescribe([]byte("not pem")); err == nil {
+		t.Error("Expected error for non-PEM input")
+	}
+
+	keyOnly := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{1}})
+	if _, err := ParseAndDescribe(keyOnly); err == nil {
+		t.Error("Expected error when PEM holds no certificate")
+	}
+
+	_, _, certPEM := newTestCert(t, "x", true, nil, nil, time.Now().Add(time.Hour))
+	if _, err := ParseAndDescribe(certPEM, WithRootPEM([]byte("garbage"))); err == nil {
+		t.Error("Expected error for invalid root PEM")
+	}
+}
@@ -0,0 +1,189 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Built-in certificate template names
+const (
+	CertTemplateWeb         = "web"
+	CertTemplateGRPCService = "grpc-service"
+	CertTemplateClientAuth  = "client-auth"
+)
+
+// CertTemplate bundles the X.509 settings of a kind of leaf certificate, so
+// callers can ask for a "grpc-service" certificate instead of choosing key
+// usages themselves. Zero fields keep the CA defaults.
+type CertTemplate struct {
+	Description  string             `json:"description,omitempty"`
+	KeyUsage     x509.KeyUsage      `json:"-"`                       // Default DigitalSignature | KeyEncipherment
+	ExtKeyUsage  []x509.ExtKeyUsage `json:"-"`                       // Default ServerAuth and ClientAuth
+	Validity     time.Duration      `json:"-"`                       // Default DefaultLeafValidity
+	KeyAlgorithm KeyAlgorithm       `json:"key_algorithm,omitempty"` // Default the CA's LeafKeyAlgorithm
+}
+
+// DefaultCertTemplates returns the built-in templates:
+//   - web: HTTPS server certificates (server auth only, 90 days)
+//   - grpc-service: services that serve and call over mTLS (server and
+//     client auth, ECDSA P-256)
+//   - client-auth: client identities for mTLS (client auth only, ECDSA
+//     P-256, 30 days)
+func DefaultCertTemplates() map[string]CertTemplate {
+	return map[string]CertTemplate{
+		CertTemplateWeb: {
+			Description: "HTTPS server",
+			KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			Validity:    90 * 24 * time.Hour,
+		},
+		CertTemplateGRPCService: {
+			Description:  "gRPC service with mutual TLS",
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+			KeyAlgorithm: KeyAlgorithmECDSAP256,
+		},
+		CertTemplateClientAuth: {
+			Description:  "mTLS client identity",
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			Validity:     30 * 24 * time.Hour,
+			KeyAlgorithm: KeyAlgorithmECDSAP256,
+		},
+	}
+}
+
+// keyUsages returns the template's key usages, or the defaults, for a leaf
+// key of alg. Key encipherment only means something for RSA key transport,
+// and strict TLS stacks reject it on ECDSA certificates, so it is dropped
+// for ECDSA keys.
+func (t *CertTemplate) keyUsages(alg KeyAlgorithm) (x509.KeyUsage, []x509.ExtKeyUsage) {
+	keyUsage := x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature
+	extKeyUsage := []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	if t != nil {
+		if t.KeyUsage != 0 {
+			keyUsage = t.KeyUsage
+		}
+		if len(t.ExtKeyUsage) > 0 {
+			extKeyUsage = slices.Clone(t.ExtKeyUsage)
+		}
+	}
+	if alg == KeyAlgorithmECDSAP256 || alg == KeyAlgorithmECDSAP384 {
+		keyUsage &^= x509.KeyUsageKeyEncipherment
+	}
+	return keyUsage, extKeyUsage
+}
+
+// mergeCertTemplates validates the configured templates and adds them to the
+// built-ins, replacing built-ins of the same name
+func mergeCertTemplates(configured map[string]CertTemplate) (map[string]CertTemplate, error) {
+	templates := DefaultCertTemplates()
+	for name, tmpl := range configured {
+		if strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("certificate template name cannot be empty")
+		}
+		if tmpl.Validity < 0 || tmpl.Validity > MaxLeafValidityDays*24*time.Hour {
+			return nil, fmt.Errorf("certificate template %q: validity must be at most %d days", name, MaxLeafValidityDays)
+		}
+		if err := validateKeyAlgorithm(tmpl.KeyAlgorithm); err != nil {
+			return nil, fmt.Errorf("certificate template %q: %w", name, err)
+		}
+		tmpl.ExtKeyUsage = slices.Clone(tmpl.ExtKeyUsage)
+		templates[name] = tmpl
+	}
+	return templates, nil
+}
+
+// CertTemplate returns the named certificate template
+func (ca *CA) CertTemplate(name string) (CertTemplate, bool) {
+	tmpl, ok := ca.certTemplates[name]
+	if ok {
+		tmpl.ExtKeyUsage = slices.Clone(tmpl.ExtKeyUsage)
+	}
+	return tmpl, ok
+}
+
+// CertTemplateNames returns the names of the CA's certificate templates, sorted
+func (ca *CA) CertTemplateNames() []string {
+	names := make([]string, 0, len(ca.certTemplates))
+	for name := range ca.certTemplates {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// requestOptions validates a V2 request and applies its template, whose
+// validity and key algorithm are used unless the request overrides them
+func (ca *CA) requestOptions(req CertRequestV2) (certOptions, error) {
+	opts, err := req.options()
+	if err != nil || req.Template == "" {
+		return opts, err
+	}
+	tmpl, ok := ca.CertTemplate(req.Template)
+	if !ok {
+		return certOptions{}, fmt.Errorf("%w: unknown template %q (available: %s)",
+			ErrInvalidCertRequest, req.Template, strings.Join(ca.CertTemplateNames(), ", "))
+	}
+	if opts.validity == 0 {
+		opts.validity = tmpl.Validity
+	}
+	if opts.keyAlgorithm == "" {
+		opts.keyAlgorithm = tmpl.KeyAlgorithm
+	}
+	opts.template = &tmpl
+	return opts, nil
+}
+
+// certTemplateInfo describes a template in GET /cert/templates
+type certTemplateInfo struct {
+	Name         string       `json:"name"`
+	Description  string       `json:"description,omitempty"`
+	KeyUsage     []string     `json:"key_usage"`
+	ExtKeyUsage  []string     `json:"ext_key_usage"`
+	ValidityDays int          `json:"validity_days"`
+	KeyAlgorithm KeyAlgorithm `json:"key_algorithm"`
+}
+
+// certTemplateInfos describes the CA's templates with defaults filled in
+func (ca *CA) certTemplateInfos() []certTemplateInfo {
+	var infos []certTemplateInfo
+	for _, name := range ca.CertTemplateNames() {
+		tmpl := ca.certTemplates[name]
+		validity := tmpl.Validity
+		if validity == 0 {
+			validity = DefaultLeafValidity
+		}
+		keyAlgorithm := tmpl.KeyAlgorithm
+		if keyAlgorithm == "" {
+			keyAlgorithm = ca.LeafKeyAlgorithm()
+		}
+		keyUsage, extKeyUsage := tmpl.keyUsages(keyAlgorithm)
+		infos = append(infos, certTemplateInfo{
+			Name:         name,
+			Description:  tmpl.Description,
+			KeyUsage:     keyUsageNames(keyUsage),
+			ExtKeyUsage:  extKeyUsageNames(extKeyUsage),
+			ValidityDays: int(validity / (24 * time.Hour)),
+			KeyAlgorithm: keyAlgorithm,
+		})
+	}
+	return infos
+}
+
+// handleCertTemplates lists the certificate templates accepted by /cert
+func (s *Server) handleCertTemplates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.ca.certTemplateInfos())
+}
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// issueParsed issues a V2 certificate and parses it
+func issueParsed(t *testing.T, ca *CA, req CertRequestV2) *x509.Certificate {
+	t.Helper()
+	response, err := ca.IssueServiceCertificateV2(req)
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+	block, _ := pem.Decode([]byte(response.Certificate))
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert
+}
+
+func TestCertTemplates(t *testing.T) {
+	ca, err := NewCA(&CAConfig{
+		CommonName:     "Template Test CA",
+		ValidityPeriod: 24 * time.Hour,
+		KeySize:        2048,
+		CertTemplates: map[string]CertTemplate{
+			"code-signing": {
+				KeyUsage:    x509.KeyUsageDigitalSignature,
+				ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+				Validity:    7 * 24 * time.Hour,
+			},
+			CertTemplateWeb: {ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, Validity: 14 * 24 * time.Hour},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+
+	want := []string{"client-auth", "code-signing", "grpc-service", "web"}
+	if names := ca.CertTemplateNames(); !reflect.DeepEqual(names, want) {
+		t.Errorf("Expected templates %v, got %v", want, names)
+	}
+
+	cert := issueParsed(t, ca, CertRequestV2{ServiceName: "orders", SANs: []string{"orders.local"}, Template: CertTemplateGRPCService})
+	if cert.PublicKeyAlgorithm != x509.ECDSA || cert.KeyUsage != x509.KeyUsageDigitalSignature {
+		t.Errorf("Expected an ECDSA signing-only key, got %v with usage %v", cert.PublicKeyAlgorithm, cert.KeyUsage)
+	}
+	if !reflect.DeepEqual(cert.ExtKeyUsage, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}) {
+		t.Errorf("Expected server and client auth, got %v", cert.ExtKeyUsage)
+	}
+
+	// The request overrides the template's validity and key algorithm
+	cert = issueParsed(t, ca, CertRequestV2{ServiceName: "cli", SANs: []string{"cli@example.com"}, Template: CertTemplateClientAuth,
+		ValidityDays: 3, KeyAlgorithm: KeyAlgorithmRSA2048})
+	if cert.PublicKeyAlgorithm != x509.RSA || cert.NotAfter.Sub(cert.NotBefore) != 3*24*time.Hour {
+		t.Errorf("Expected the request's RSA key and 3 day validity, got %v for %v", cert.PublicKeyAlgorithm, cert.NotAfter.Sub(cert.NotBefore))
+	}
+	if !reflect.DeepEqual(cert.ExtKeyUsage, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}) {
+		t.Errorf("Expected client auth only, got %v", cert.ExtKeyUsage)
+	}
+
+	// A configured template replaces the built-in, keeping unset defaults
+	cert = issueParsed(t, ca, CertRequestV2{ServiceName: "site", SANs: []string{"site.local"}, Template: CertTemplateWeb})
+	if cert.NotAfter.Sub(cert.NotBefore) != 14*24*time.Hour || cert.KeyUsage != x509.KeyUsageDigitalSignature|x509.KeyUsageKeyEncipherment {
+		t.Errorf("Expected the configured web template, got %v with usage %v", cert.NotAfter.Sub(cert.NotBefore), cert.KeyUsage)
+	}
+
+	// Key encipherment is RSA only
+	cert = issueParsed(t, ca, CertRequestV2{ServiceName: "site", SANs: []string{"site.local"}, Template: CertTemplateWeb, KeyAlgorithm: KeyAlgorithmECDSAP384})
+	if cert.PublicKeyAlgorithm != x509.ECDSA || cert.KeyUsage != x509.KeyUsageDigitalSignature {
+		t.Errorf("Expected an ECDSA key without key encipherment, got %v with usage %v", cert.PublicKeyAlgorithm, cert.KeyUsage)
+	}
+	cert = issueParsed(t, ca, CertRequestV2{ServiceName: "plain", SANs: []string{"plain.local"}, KeyAlgorithm: KeyAlgorithmECDSAP256})
+	if cert.KeyUsage != x509.KeyUsageDigitalSignature {
+		t.Errorf("Expected default usages without key encipherment for ECDSA, got %v", cert.KeyUsage)
+	}
+
+	cert = issueParsed(t, ca, CertRequestV2{ServiceName: "signer", SANs: []string{"signer.local"}, Template: "code-signing"})
+	if !reflect.DeepEqual(cert.ExtKeyUsage, []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}) {
+		t.Errorf("Expected code signing, got %v", cert.ExtKeyUsage)
+	}
+
+	_, err = ca.IssueServiceCertificateV2(CertRequestV2{ServiceName: "x", SANs: []string{"x.local"}, Template: "mail"})
+	if !errors.Is(err, ErrInvalidCertRequest) || !strings.Contains(err.Error(), "available: client-auth, code-signing, grpc-service, web") {
+		t.Errorf("Expected ErrInvalidCertRequest listing the templates, got %v", err)
+	}
+}
+
+func TestCertTemplatesConfigErrors(t *testing.T) {
+	for name, templates := range map[string]map[string]CertTemplate{
+		"empty name":    {"": {}},
+		"long validity": {"forever": {Validity: (MaxLeafValidityDays + 1) * 24 * time.Hour}},
+		"bad algorithm": {"dsa": {KeyAlgorithm: "dsa1024"}},
+	} {
+		config := DefaultCAConfig()
+		config.CertTemplates = templates
+		if _, err := NewCA(config); err == nil {
+			t.Errorf("%s: expected NewCA to fail", name)
+		}
+	}
+}
+
+func TestHandleCertTemplates(t *testing.T) {
+	server, err := NewServer(&ServerConfig{Port: "8093", CAConfig: DefaultCAConfig()})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	server.handleCertTemplates(rr, httptest.NewRequest(http.MethodGet, "/cert/templates", nil))
+	var infos []certTemplateInfo
+	if err := json.NewDecoder(rr.Body).Decode(&infos); err != nil || len(infos) != 3 {
+		t.Fatalf("Expected 3 templates, got %v, %v", infos, err)
+	}
+	if infos[2].Name != CertTemplateWeb || infos[2].ValidityDays != 90 || infos[2].KeyAlgorithm != KeyAlgorithmRSA2048 ||
+		!reflect.DeepEqual(infos[2].ExtKeyUsage, []string{"server auth"}) {
+		t.Errorf("Unexpected web template: %+v", infos[2])
+	}
+
+	// Templates are selectable through POST /cert
+	rr = httptest.NewRecorder()
+	server.handleCertRequest(rr, httptest.NewRequest(http.MethodPost, "/cert",
+		strings.NewReader(`{"service_name": "cli", "sans": ["cli.local"], "template": "nope"}`)))
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "unknown template") {
+		t.Errorf("Expected 400 for an unknown template, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/nzions/sharedgolibs/pkg/dockerutil"
+)
+
+// ComposeServiceLabel is the label Docker Compose puts the service name in
+const ComposeServiceLabel = "com.docker.compose.service"
+
+// containerInspectTimeout bounds finding Docker and inspecting a container
+const containerInspectTimeout = 10 * time.Second
+
+// ErrContainerInspect is returned when a container's names and addresses
+// can't be read from Docker
+var ErrContainerInspect = errors.New("failed to inspect container")
+
+// RequestCertificateForContainer requests a certificate covering every name
+// and address a container is reached by, so compose services don't need a
+// hand-maintained SAN list. The SANs are those of ContainerSANs and the
+// service name is the compose service, or else the container name.
+//
+// Environment Variables Used:
+//   - SGL_CA (required): CA server URL for certificate requests
+//   - SGL_CA_API_KEY (optional): API key for CA server authentication
+//   - SGL_CA_TOKEN (optional): Bearer token for CA server authentication
+//   - DOCKER_HOST (optional): Docker engine to inspect the container on,
+//     otherwise found by dockerutil.DetectClient
+//
+// Parameters:
+//   - containerName: Name or ID of the container
+//
+// Returns a CertResponse containing the PEM-encoded certificate and private key,
+// or an error if the container can't be inspected or the request fails.
+func RequestCertificateForContainer(containerName string) (*CertResponse, error) {
+	serviceName, sans, err := inspectContainerSANs(containerName)
+	if err != nil {
+		return nil, err
+	}
+	return RequestCertificateV2(serviceName, sans)
+}
+
+// ContainerSANs returns the names and addresses of a container, found
+// through the Docker engine dockerutil.DetectClient locates:
+//   - its compose service, container name, and hostname
+//   - its aliases and DNS names on every network it is attached to
+//   - its IP addresses on those networks
+//   - localhost, 127.0.0.1, and ::1 if it publishes ports to the host
+func ContainerSANs(containerName string) ([]string, error) {
+	_, sans, err := inspectContainerSANs(containerName)
+	return sans, err
+}
+
+// inspectContainerSANs inspects a container for its service name and SANs
+func inspectContainerSANs(containerName string) (string, []string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), containerInspectTimeout)
+	defer cancel()
+
+	detection, err := dockerutil.DetectClient(ctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w %s: %v", ErrContainerInspect, containerName, err)
+	}
+	defer detection.Client.Close()
+
+	inspect, err := detection.Client.ContainerInspect(ctx, containerName)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w %s: %v", ErrContainerInspect, containerName, err)
+	}
+	serviceName, sans := containerSANs(inspect)
+	if len(sans) == 0 {
+		return "", nil, fmt.Errorf("%w %s: no names or addresses", ErrContainerInspect, containerName)
+	}
+	return serviceName, sans, nil
+}
+
+// containerSANs collects the service name and SANs of an inspected
+// container. Names come first so the Common Name is the compose service or
+// container name. Docker's default hostname, the short container ID, is
+// left out.
+func containerSANs(inspect container.InspectResponse) (serviceName string, sans []string) {
+	seen := make(map[string]bool)
+	add := func(san string) {
+		san = strings.TrimSpace(san)
+		if san == "" || seen[strings.ToLower(san)] || isShortContainerID(san, inspect.ID) {
+			return
+		}
+		seen[strings.ToLower(san)] = true
+		sans = append(sans, san)
+	}
+
+	name := strings.TrimPrefix(inspect.Name, "/")
+	serviceName = name
+	if inspect.Config != nil {
+		if service := inspect.Config.Labels[ComposeServiceLabel]; service != "" {
+			serviceName = service
+			add(service)
+		}
+	}
+	add(name)
+	if inspect.Config != nil {
+		add(inspect.Config.Hostname)
+		if inspect.Config.Domainname != "" {
+			add(inspect.Config.Hostname + "." + inspect.Config.Domainname)
+		}
+	}
+
+	var ips []string
+	publishes := false
+	if settings := inspect.NetworkSettings; settings != nil {
+		networks := make([]string, 0, len(settings.Networks))
+		for network := range settings.Networks {
+			networks = append(networks, network)
+		}
+		sort.Strings(networks)
+		for _, network := range networks {
+			endpoint := settings.Networks[network]
+			if endpoint == nil {
+				continue
+			}
+			for _, alias := range endpoint.Aliases {
+				add(alias)
+			}
+			for _, dnsName := range endpoint.DNSNames {
+				add(dnsName)
+			}
+			ips = append(ips, endpoint.IPAddress, endpoint.GlobalIPv6Address)
+		}
+		for _, bindings := range settings.Ports {
+			publishes = publishes || len(bindings) > 0
+		}
+	}
+	for _, ip := range ips {
+		if net.ParseIP(ip) != nil {
+			add(ip)
+		}
+	}
+	if publishes {
+		add("localhost")
+		add("127.0.0.1")
+		add("::1")
+	}
+	return serviceName, sans
+}
+
+// isShortContainerID reports whether name is a prefix of the container ID
+// at least as long as the 12 characters Docker shows
+func isShortContainerID(name, id string) bool {
+	return len(name) >= 12 && strings.HasPrefix(id, name)
+}
@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
+)
+
+func TestContainerSANs(t *testing.T) {
+	id := "3f4e5d6c7b8a9f0e1d2c3b4a5f6e7d8c9b0a1f2e3d4c5b6a7f8e9d0c1b2a3f4e"
+	inspect := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{ID: id, Name: "/shop-api-1"},
+		Config: &container.Config{
+			Hostname: id[:12], // Docker's default
+			Labels:   map[string]string{ComposeServiceLabel: "api"},
+		},
+		NetworkSettings: &container.NetworkSettings{
+			Networks: map[string]*network.EndpointSettings{
+				"shop_default": {Aliases: []string{"shop-api-1", "api"}, DNSNames: []string{"shop-api-1", "api", id[:12]}, IPAddress: "172.18.0.4"},
+				"backend":      {Aliases: []string{"orders-api"}, IPAddress: "172.19.0.2", GlobalIPv6Address: "fd00::2"},
+			},
+		},
+	}
+
+	serviceName, sans := containerSANs(inspect)
+	want := []string{"api", "shop-api-1", "orders-api", "172.19.0.2", "fd00::2", "172.18.0.4"}
+	if serviceName != "api" || !reflect.DeepEqual(sans, want) {
+		t.Errorf("Got %q %v, want %q %v", serviceName, sans, "api", want)
+	}
+
+	// Without compose, a custom hostname, and ports published to the host
+	inspect.Config = &container.Config{Hostname: "db", Domainname: "local"}
+	inspect.NetworkSettings.Networks = map[string]*network.EndpointSettings{"bridge": {IPAddress: "172.17.0.2"}}
+	inspect.NetworkSettings.Ports = nat.PortMap{"5432/tcp": {{HostIP: "0.0.0.0", HostPort: "5432"}}}
+	serviceName, sans = containerSANs(inspect)
+	want = []string{"shop-api-1", "db", "db.local", "172.17.0.2", "localhost", "127.0.0.1", "::1"}
+	if serviceName != "shop-api-1" || !reflect.DeepEqual(sans, want) {
+		t.Errorf("Got %q %v, want %q %v", serviceName, sans, "shop-api-1", want)
+	}
+}
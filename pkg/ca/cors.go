@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig controls cross-origin access to the CA server, for browser-based
+// tools served from another origin
+type CORSConfig struct {
+	AllowedOrigins   []string      // Origins allowed to call the server; "*" allows any
+	AllowedMethods   []string      // Defaults to GET, POST, OPTIONS
+	AllowedHeaders   []string      // Defaults to Content-Type, Authorization, X-API-Key
+	AllowCredentials bool          // Allow cookies and auth headers; the origin is echoed instead of "*"
+	MaxAge           time.Duration // How long browsers may cache preflight results (0 = not sent)
+}
+
+// DefaultCORSConfig allows any origin to use the API with an API key header
+func DefaultCORSConfig() *CORSConfig {
+	return &CORSConfig{
+		AllowedOrigins: []string{"*"},
+		MaxAge:         10 * time.Minute,
+	}
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for origin, or
+// "" when the origin is not allowed
+func (c *CORSConfig) allowOrigin(origin string) string {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			// Browsers reject a wildcard on credentialed requests
+			if c.AllowCredentials {
+				return origin
+			}
+			return "*"
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// withCORS adds CORS headers to responses for allowed origins and answers
+// preflight requests before API key checks, since browsers send preflights
+// without credentials
+func withCORS(config *CORSConfig, next http.Handler) http.Handler {
+	methods := config.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodPost, http.MethodOptions}
+	}
+	headers := config.AllowedHeaders
+	if len(headers) == 0 {
+		headers = []string{"Content-Type", "Authorization", "X-API-Key"}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		allowed := config.allowOrigin(origin)
+		if allowed != "" {
+			w.Header().Set("Access-Control-Allow-Origin", allowed)
+			if config.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+
+		// Preflight
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if allowed == "" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+			if config.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(config.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestBaseURL returns the scheme and host the client used to reach the
+// server. With trustForwarded, X-Forwarded-Proto and X-Forwarded-Host set by
+// a reverse proxy take precedence over the connection's own values.
+func requestBaseURL(r *http.Request, trustForwarded bool) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.Host
+
+	if trustForwarded {
+		if proto := firstHeaderValue(r, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwardedHost := firstHeaderValue(r, "X-Forwarded-Host"); forwardedHost != "" {
+			host = forwardedHost
+		}
+	}
+
+	return fmt.Sprintf("%s://%s", scheme, host)
+}
+
+// firstHeaderValue returns the first entry of a comma-separated header, which
+// is the one added by the proxy closest to the client
+func firstHeaderValue(r *http.Request, name string) string {
+	value, _, _ := strings.Cut(r.Header.Get(name), ",")
+	return strings.ToLower(strings.TrimSpace(value))
+}
@@ -0,0 +1,150 @@
+package ca
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nzions/sharedgolibs/pkg/middleware"
+)
+
+func TestWithCORS(t *testing.T) {
+	// API key middleware sits inside CORS, as in Server.Start
+	api := middleware.WithAPIKey("secret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	t.Run("PreflightBypassesAPIKey", func(t *testing.T) {
+		handler := withCORS(&CORSConfig{AllowedOrigins: []string{"http://localhost:5173"}, MaxAge: time.Minute}, api)
+
+		req := httptest.NewRequest(http.MethodOptions, "/cert", nil)
+		req.Header.Set("Origin", "http://localhost:5173")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		req.Header.Set("Access-Control-Request-Headers", "X-API-Key")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNoContent {
+			t.Fatalf("Expected 204 for preflight, got %d", rr.Code)
+		}
+		if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "http://localhost:5173" {
+			t.Errorf("Expected origin echoed, got %q", got)
+		}
+		if got := rr.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(got, "X-API-Key") {
+			t.Errorf("Expected X-API-Key in allowed headers, got %q", got)
+		}
+		if got := rr.Header().Get("Access-Control-Max-Age"); got != "60" {
+			t.Errorf("Expected max age 60, got %q", got)
+		}
+	})
+
+	t.Run("DisallowedOrigin", func(t *testing.T) {
+		handler := withCORS(&CORSConfig{AllowedOrigins: []string{"http://localhost:5173"}}, api)
+
+		req := httptest.NewRequest(http.MethodOptions, "/cert", nil)
+		req.Header.Set("Origin", "http://evil.example")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusForbidden || rr.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("Expected forbidden preflight without allow header, got %d %q", rr.Code, rr.Header().Get("Access-Control-Allow-Origin"))
+		}
+	})
+
+	t.Run("SimpleRequest", func(t *testing.T) {
+		handler := withCORS(DefaultCORSConfig(), api)
+
+		req := httptest.NewRequest(http.MethodGet, "/ca", nil)
+		req.Header.Set("Origin", "https://tools.example")
+		req.Header.Set("X-API-Key", "secret")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK || rr.Header().Get("Access-Control-Allow-Origin") != "*" {
+			t.Errorf("Expected wildcard origin on success, got %d %q", rr.Code, rr.Header().Get("Access-Control-Allow-Origin"))
+		}
+		if rr.Header().Get("Vary") != "Origin" {
+			t.Errorf("Expected Vary: Origin, got %q", rr.Header().Get("Vary"))
+		}
+	})
+
+	t.Run("CredentialsEchoOrigin", func(t *testing.T) {
+		handler := withCORS(&CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}, api)
+
+		req := httptest.NewRequest(http.MethodGet, "/ca?api_key=secret", nil)
+		req.Header.Set("Origin", "https://tools.example")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Header().Get("Access-Control-Allow-Origin") != "https://tools.example" || rr.Header().Get("Access-Control-Allow-Credentials") != "true" {
+			t.Errorf("Expected echoed origin with credentials, got %v", rr.Header())
+		}
+	})
+
+	t.Run("NoOrigin", func(t *testing.T) {
+		handler := withCORS(DefaultCORSConfig(), api)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/ca?api_key=secret", nil))
+
+		if rr.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Error("Expected no CORS headers for same-origin requests")
+		}
+	})
+}
+
+func TestRequestBaseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		tls     bool
+		headers map[string]string
+		trust   bool
+		want    string
+	}{
+		{"Plain", false, nil, true, "http://ca.internal:8090"},
+		{"TLS", true, nil, false, "https://ca.internal:8090"},
+		{"ForwardedIgnoredByDefault", false, map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "ca.dev.test"}, false, "http://ca.internal:8090"},
+		{"Forwarded", false, map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "ca.dev.test"}, true, "https://ca.dev.test"},
+		{"ProxyChain", false, map[string]string{"X-Forwarded-Proto": "https, http", "X-Forwarded-Host": "ca.dev.test, proxy.internal"}, true, "https://ca.dev.test"},
+		{"InvalidProto", false, map[string]string{"X-Forwarded-Proto": "javascript"}, true, "http://ca.internal:8090"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://ca.internal:8090/ui/api", nil)
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			if got := requestBaseURL(req, tt.trust); got != tt.want {
+				t.Errorf("requestBaseURL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGUIBaseURLBehindProxy(t *testing.T) {
+	server, err := NewServer(&ServerConfig{
+		CAConfig:              DefaultCAConfig(),
+		EnableGUI:             true,
+		TrustForwardedHeaders: true,
+	})
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://127.0.0.1:8090/ui/api", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "ca.dev.test")
+	rr := httptest.NewRecorder()
+	server.gui.HandleAPI(rr, req)
+
+	if !strings.Contains(rr.Body.String(), "https://ca.dev.test/cert") {
+		t.Error("Expected API docs to use the forwarded base URL")
+	}
+}
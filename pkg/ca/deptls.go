@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// TLS settings for development dependencies (NATS, Redis, Postgres, ...)
+// that run with certificates from the CA. These helpers fetch trust and
+// client certificates from the CA server, so each service doesn't wire its
+// own.
+
+// Default file names written by PGSSLFiles, matching libpq's defaults in
+// ~/.postgresql
+const (
+	PGRootCertFileName = "root.crt"
+	PGCertFileName     = "postgresql.crt"
+	PGKeyFileName      = "postgresql.key"
+)
+
+// TLSConfigForClient returns a client TLS config that trusts the CA bundle,
+// e.g. for nats.Secure(cfg) or a database driver. With a serviceName it also
+// presents a client certificate issued for that name, for servers that
+// verify clients (NATS "verify: true", Postgres "cert" auth).
+//
+// Environment Variables Used:
+//   - SGL_CA (required): CA server URL
+//   - SGL_CA_API_KEY (optional): API key for CA server authentication
+//   - SGL_CA_TOKEN (optional): Bearer token for CA server authentication
+func TLSConfigForClient(serviceName string) (*tls.Config, error) {
+	roots, err := fetchRootPool()
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    roots,
+	}
+	if serviceName == "" {
+		return config, nil
+	}
+
+	certResp, err := RequestCertificateV2(serviceName, []string{serviceName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to request client certificate: %w", err)
+	}
+	cert, err := tls.X509KeyPair([]byte(certResp.Certificate), []byte(certResp.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse client certificate: %w", err)
+	}
+	config.Certificates = []tls.Certificate{cert}
+	return config, nil
+}
+
+// RedisTLSConfig returns a TLS config for Redis clients such as go-redis
+// (redis.Options.TLSConfig). Redis verifies client certificates by default
+// (tls-auth-clients yes), so one is always issued for serviceName.
+//
+// Environment Variables Used:
+//   - SGL_CA (required): CA server URL
+//   - SGL_CA_API_KEY (optional): API key for CA server authentication
+//   - SGL_CA_TOKEN (optional): Bearer token for CA server authentication
+func RedisTLSConfig(serviceName string) (*tls.Config, error) {
+	if serviceName == "" {
+		return nil, errors.New("redis client certificate needs a service name")
+	}
+	return TLSConfigForClient(serviceName)
+}
+
+// PGSSL are the files written by PGSSLFiles, named after the libpq
+// connection parameters that point at them
+type PGSSL struct {
+	RootCert string // sslrootcert: the CA certificate
+	Cert     string // sslcert: the client certificate
+	Key      string // sslkey: the client key, mode 0600 as libpq requires
+}
+
+// PGSSLFiles writes a client certificate for the Postgres user and the CA
+// certificate to dir, for "cert" authentication with sslmode=verify-full.
+// The certificate's CommonName is the user, which is what Postgres matches
+// against the role.
+//
+// Environment Variables Used:
+//   - SGL_CA (required): CA server URL
+//   - SGL_CA_API_KEY (optional): API key for CA server authentication
+//   - SGL_CA_TOKEN (optional): Bearer token for CA server authentication
+func PGSSLFiles(dir, user string) (*PGSSL, error) {
+	if user == "" {
+		return nil, errors.New("postgres client certificate needs a user")
+	}
+	certResp, err := RequestCertificateV2(user, []string{user})
+	if err != nil {
+		return nil, fmt.Errorf("failed to request client certificate: %w", err)
+	}
+
+	files, err := WriteCertFiles(certResp, dir, &WriteCertOptions{
+		CertFile:  PGCertFileName,
+		KeyFile:   PGKeyFileName,
+		ChainFile: PGRootCertFileName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &PGSSL{RootCert: files.ChainFile, Cert: files.CertFile, Key: files.KeyFile}, nil
+}
+
+// DSN returns the files as libpq keyword/value connection parameters with
+// sslmode=verify-full, to append to a DSN such as "host=db user=app"
+func (p *PGSSL) DSN() string {
+	return fmt.Sprintf("sslmode=verify-full sslrootcert=%s sslcert=%s sslkey=%s",
+		quotePGValue(p.RootCert), quotePGValue(p.Cert), quotePGValue(p.Key))
+}
+
+// URLQuery returns the same parameters as a query string for postgres://
+// connection URLs
+func (p *PGSSL) URLQuery() string {
+	values := url.Values{}
+	values.Set("sslmode", "verify-full")
+	values.Set("sslrootcert", p.RootCert)
+	values.Set("sslcert", p.Cert)
+	values.Set("sslkey", p.Key)
+	return values.Encode()
+}
+
+// quotePGValue quotes a keyword/value parameter if it is empty or contains
+// spaces, quotes, or backslashes
+func quotePGValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " '\\") {
+		return value
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// fetchRootPool returns a pool of the CA bundle from the CA server, which
+// includes previous roots during a rotation
+func fetchRootPool() (*x509.CertPool, error) {
+	bundle, err := FetchCABundle()
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("%w: no certificates in CA bundle", ErrCertParse)
+	}
+	return roots, nil
+}
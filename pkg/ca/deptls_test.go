@@ -0,0 +1,183 @@
+package ca
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startDepTLSCA serves the bundle and certificate endpoints from a fresh CA
+// and points SGL_CA at them
+func startDepTLSCA(t *testing.T) *CA {
+	t.Helper()
+	authority := newTestCA(t, nil)
+	server := &Server{ca: authority}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ca/bundle", server.handleCABundle)
+	mux.HandleFunc("/cert", server.handleCertRequest)
+	caServer := httptest.NewServer(mux)
+	t.Cleanup(caServer.Close)
+
+	t.Setenv("SGL_CA", caServer.URL)
+	t.Setenv("SGL_CA_API_KEY", "")
+	return authority
+}
+
+func TestTLSConfigForClient(t *testing.T) {
+	authority := startDepTLSCA(t)
+
+	// A server that requires client certificates from the CA, like NATS with verify
+	serverCert, serverKey, err := authority.GenerateCertificateV2("nats", []string{"localhost", "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPair, err := tls.X509KeyPair([]byte(serverCert), []byte(serverKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(authority.Certificate())
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{keyPair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	peers := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			tlsConn := conn.(*tls.Conn)
+			if tlsConn.Handshake() == nil {
+				peers <- tlsConn.ConnectionState().PeerCertificates[0].Subject.CommonName
+			}
+			conn.Close()
+		}
+	}()
+
+	dial := func(config *tls.Config) error {
+		config.ServerName = "localhost"
+		conn, err := tls.Dial("tcp", listener.Addr().String(), config)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	config, err := TLSConfigForClient("worker")
+	if err != nil {
+		t.Fatalf("TLSConfigForClient failed: %v", err)
+	}
+	if len(config.Certificates) != 1 {
+		t.Fatalf("Expected a client certificate, got %d", len(config.Certificates))
+	}
+	if err := dial(config); err != nil {
+		t.Fatalf("mTLS connection failed: %v", err)
+	}
+	select {
+	case cn := <-peers:
+		if cn != "worker" {
+			t.Errorf("Expected client certificate for worker, got %q", cn)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Server did not accept the client certificate")
+	}
+
+	trustOnly, err := TLSConfigForClient("")
+	if err != nil {
+		t.Fatalf("TLSConfigForClient without a service failed: %v", err)
+	}
+	if len(trustOnly.Certificates) != 0 || trustOnly.RootCAs == nil {
+		t.Errorf("Expected a trust-only config, got %+v", trustOnly)
+	}
+
+	if _, err := RedisTLSConfig(""); err == nil {
+		t.Error("Expected RedisTLSConfig to require a service name")
+	}
+	redis, err := RedisTLSConfig("cache-client")
+	if err != nil || len(redis.Certificates) != 1 {
+		t.Errorf("Expected a Redis config with a client certificate, got %v", err)
+	}
+}
+
+func TestPGSSLFiles(t *testing.T) {
+	startDepTLSCA(t)
+	dir := filepath.Join(t.TempDir(), "pg ssl")
+
+	files, err := PGSSLFiles(dir, "app")
+	if err != nil {
+		t.Fatalf("PGSSLFiles failed: %v", err)
+	}
+	if files.Cert != filepath.Join(dir, PGCertFileName) || files.Key != filepath.Join(dir, PGKeyFileName) || files.RootCert != filepath.Join(dir, PGRootCertFileName) {
+		t.Errorf("Unexpected paths: %+v", files)
+	}
+
+	info, err := os.Stat(files.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected key mode 0600, got %v", info.Mode().Perm())
+	}
+	certPEM, err := os.ReadFile(files.Cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Subject.CommonName != "app" {
+		t.Errorf("Expected CommonName app, got %q", cert.Subject.CommonName)
+	}
+	roots := x509.NewCertPool()
+	rootPEM, _ := os.ReadFile(files.RootCert)
+	if !roots.AppendCertsFromPEM(rootPEM) {
+		t.Fatal("Expected the CA certificate in root.crt")
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+		t.Errorf("Client certificate does not verify against root.crt: %v", err)
+	}
+
+	dsn := files.DSN()
+	want := "sslmode=verify-full sslrootcert='" + files.RootCert + "' sslcert='" + files.Cert + "' sslkey='" + files.Key + "'"
+	if dsn != want {
+		t.Errorf("DSN() = %q, want %q", dsn, want)
+	}
+	query, err := url.ParseQuery(files.URLQuery())
+	if err != nil || query.Get("sslkey") != files.Key || query.Get("sslmode") != "verify-full" {
+		t.Errorf("Unexpected URLQuery %q: %v", files.URLQuery(), err)
+	}
+
+	if _, err := PGSSLFiles(dir, ""); err == nil {
+		t.Error("Expected PGSSLFiles to require a user")
+	}
+}
+
+func TestQuotePGValue(t *testing.T) {
+	tests := map[string]string{
+		"/etc/ssl/root.crt": "/etc/ssl/root.crt",
+		"":                  "''",
+		"/my certs/key":     "'/my certs/key'",
+		`it's\here`:         `'it\'s\\here'`,
+	}
+	for value, want := range tests {
+		if got := quotePGValue(value); got != want {
+			t.Errorf("quotePGValue(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/nzions/sharedgolibs/pkg/util"
+)
+
+// DiagnosisWarn is the status of a check that found a problem the client
+// can work around. Other checks report HealthOK, HealthFail, or
+// HealthSkipped (an earlier check failed).
+const DiagnosisWarn = "warn"
+
+// Names of the checks run by Diagnose, in order
+const (
+	CheckURL          = "url"
+	CheckConnectivity = "connectivity"
+	CheckAuth         = "auth"
+	CheckClock        = "clock"
+	CheckTrust        = "trust"
+)
+
+// Diagnosis tuning. Overridable for tests.
+var (
+	// diagnoseTimeout bounds each request to the CA server
+	diagnoseTimeout = 5 * time.Second
+
+	// clockSkewTolerance is the largest clock difference to the server that
+	// freshly issued certificates tolerate: they are valid from the moment
+	// the server signs them
+	clockSkewTolerance = time.Minute
+
+	// systemCertPool loads the local trust store
+	systemCertPool = x509.SystemCertPool
+)
+
+// DiagnosticCheck is the result of one Diagnose check
+type DiagnosticCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Fix    string `json:"fix,omitempty"` // What to do about a failure or warning
+}
+
+// Diagnosis is the result of Diagnose
+type Diagnosis struct {
+	URL           string            `json:"url"`
+	ServerVersion string            `json:"server_version,omitempty"`
+	Checks        []DiagnosticCheck `json:"checks"`
+}
+
+// OK reports whether no check failed. Warnings don't count.
+func (d *Diagnosis) OK() bool {
+	for _, check := range d.Checks {
+		if check.Status == HealthFail {
+			return false
+		}
+	}
+	return true
+}
+
+// Diagnose checks that the CA server in SGL_CA can be used from this
+// machine: the URL is valid, the server is reachable, SGL_CA_API_KEY or
+// SGL_CA_TOKEN are accepted, the local clock agrees with the server's, and
+// whether the system trust store already trusts the CA. Failed and warning
+// checks carry an actionable fix. Checks that depend on a failed one are
+// skipped.
+//
+// Environment Variables Used:
+//   - SGL_CA (required): CA server URL (must be http:// or https://)
+//   - SGL_CA_API_KEY (optional): API key for CA server authentication
+//   - SGL_CA_TOKEN (optional): Bearer token for CA server authentication
+func Diagnose(ctx context.Context) *Diagnosis {
+	caURL := strings.TrimRight(util.MustGetEnv("SGL_CA", ""), "/")
+	d := &Diagnosis{URL: caURL}
+
+	if err := validateCAURL(caURL); err != nil {
+		d.add(CheckURL, HealthFail, err.Error(), urlFix(caURL, err))
+		d.skip(CheckConnectivity, CheckAuth, CheckClock, CheckTrust)
+		return d
+	}
+	d.add(CheckURL, HealthOK, caURL, "")
+
+	// Connectivity comes before trust, so don't require a trusted TLS
+	// certificate here; the trust check verifies it
+	client := &http.Client{
+		Timeout:   diagnoseTimeout,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+
+	health, serverTLS, err := diagnoseProbe(ctx, client, caURL)
+	if err != nil {
+		d.add(CheckConnectivity, HealthFail, err.Error(), connectivityFix(caURL, err))
+		d.skip(CheckAuth, CheckClock, CheckTrust)
+		return d
+	}
+	d.ServerVersion = health.Version
+	detail := "CA server is reachable"
+	if health.Version != "" {
+		detail = fmt.Sprintf("CA server %s is reachable", health.Version)
+	}
+	d.add(CheckConnectivity, HealthOK, detail, "")
+
+	rootPEM, err := diagnoseFetchRoot(ctx, client, caURL)
+	switch {
+	case errors.Is(err, ErrUnauthorized):
+		d.add(CheckAuth, HealthFail, "The server rejected the request (HTTP 401)", authFix())
+	case err != nil:
+		d.add(CheckAuth, HealthFail, err.Error(), "Check the CA server logs; GET "+caURL+"/ca should return the root certificate")
+	case !hasCredentials():
+		d.add(CheckAuth, HealthOK, "The server does not require credentials", "")
+	default:
+		d.add(CheckAuth, HealthOK, "Credentials accepted", "")
+	}
+
+	d.Checks = append(d.Checks, clockCheck(health.Time, time.Now()))
+
+	if err != nil {
+		d.skip(CheckTrust)
+		return d
+	}
+	d.Checks = append(d.Checks, trustCheck(rootPEM, serverTLS))
+	return d
+}
+
+// add appends a check result
+func (d *Diagnosis) add(name, status, detail, fix string) {
+	d.Checks = append(d.Checks, DiagnosticCheck{Name: name, Status: status, Detail: detail, Fix: fix})
+}
+
+// skip appends skipped results for checks that depend on a failed one
+func (d *Diagnosis) skip(names ...string) {
+	for _, name := range names {
+		d.add(name, HealthSkipped, "", "")
+	}
+}
+
+// diagnoseProbe calls the unauthenticated /healthz probe and returns its
+// report and the server's TLS state (nil over http). Servers older than
+// v2.22.0 have no probe; a 404 still proves connectivity, with the server's
+// Date header standing in for its clock.
+func diagnoseProbe(ctx context.Context, client *http.Client, caURL string) (*HealthReport, *tls.ConnectionState, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", caURL+"/healthz", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	report := &HealthReport{}
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(report); err != nil {
+			return nil, nil, fmt.Errorf("%s does not look like a CA server: %w", caURL, err)
+		}
+	} else if resp.StatusCode != http.StatusNotFound {
+		return nil, nil, fmt.Errorf("%s/healthz returned HTTP %d", caURL, resp.StatusCode)
+	}
+	if report.Time.IsZero() {
+		report.Time, _ = http.ParseTime(resp.Header.Get("Date"))
+	}
+	return report, resp.TLS, nil
+}
+
+// diagnoseFetchRoot fetches the root certificate with the configured
+// credentials
+func diagnoseFetchRoot(ctx context.Context, client *http.Client, caURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", caURL+"/ca", nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCARequest, err)
+	}
+	setAuthHeaders(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCARequest, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, ErrUnauthorized
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: HTTP %d", ErrCARequest, resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// hasCredentials reports whether SGL_CA_API_KEY or SGL_CA_TOKEN is set
+func hasCredentials() bool {
+	return util.MustGetEnv("SGL_CA_API_KEY", "") != "" || util.MustGetEnv("SGL_CA_TOKEN", "") != ""
+}
+
+// clockCheck compares the local clock with the server's
+func clockCheck(serverTime, now time.Time) DiagnosticCheck {
+	check := DiagnosticCheck{Name: CheckClock}
+	if serverTime.IsZero() {
+		check.Status = HealthSkipped
+		check.Detail = "The server did not report its time"
+		return check
+	}
+
+	// Date headers have a resolution of one second
+	skew := now.Sub(serverTime).Round(time.Second)
+	switch {
+	case skew < -clockSkewTolerance:
+		check.Status = HealthFail
+		check.Detail = fmt.Sprintf("Local clock is %s behind the CA server; new certificates will not be valid yet", -skew)
+	case skew > clockSkewTolerance:
+		check.Status = HealthFail
+		check.Detail = fmt.Sprintf("Local clock is %s ahead of the CA server; short-lived certificates may look expired", skew)
+	default:
+		check.Status = HealthOK
+		check.Detail = fmt.Sprintf("Clocks agree within %s", max(skew, -skew))
+		return check
+	}
+	check.Fix = "Synchronize the clock with NTP on both machines (on Docker Desktop or Colima, restarting the VM resyncs it)"
+	return check
+}
+
+// trustCheck reports whether the system trust store already trusts the
+// root, and for https servers whether their certificate chains to it
+func trustCheck(rootPEM []byte, serverTLS *tls.ConnectionState) DiagnosticCheck {
+	check := DiagnosticCheck{Name: CheckTrust}
+	block, _ := pem.Decode(rootPEM)
+	if block == nil {
+		check.Status = HealthFail
+		check.Detail = "GET /ca did not return a PEM certificate"
+		return check
+	}
+	root, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		check.Status = HealthFail
+		check.Detail = fmt.Sprintf("GET /ca returned an invalid certificate: %v", err)
+		return check
+	}
+
+	if serverTLS != nil && len(serverTLS.PeerCertificates) > 0 {
+		roots := x509.NewCertPool()
+		roots.AddCert(root)
+		intermediates := x509.NewCertPool()
+		for _, cert := range serverTLS.PeerCertificates[1:] {
+			intermediates.AddCert(cert)
+		}
+		if _, err := serverTLS.PeerCertificates[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates}); err != nil {
+			check.Status = HealthFail
+			check.Detail = fmt.Sprintf("The server's TLS certificate does not chain to its own root: %v", err)
+			check.Fix = "Restart the CA server so it re-issues its TLS certificate, or point SGL_CA at its http:// port"
+			return check
+		}
+	}
+
+	pool, err := systemCertPool()
+	if err != nil {
+		check.Status = HealthSkipped
+		check.Detail = fmt.Sprintf("Cannot read the system trust store: %v", err)
+		return check
+	}
+	if _, err := root.Verify(x509.VerifyOptions{Roots: pool}); err == nil {
+		check.Status = HealthOK
+		check.Detail = fmt.Sprintf("%q is trusted by the system trust store", root.Subject.CommonName)
+		return check
+	}
+
+	// Go programs calling UpdateTransport trust the CA anyway, so this only
+	// affects browsers, curl, and other tools
+	check.Status = DiagnosisWarn
+	check.Detail = fmt.Sprintf("%q is not in the system trust store; browsers and curl will reject its certificates", root.Subject.CommonName)
+	check.Fix = trustStoreFix()
+	return check
+}
+
+// urlFix suggests a fix for an invalid SGL_CA
+func urlFix(caURL string, err error) string {
+	switch {
+	case caURL == "":
+		return "Set SGL_CA to the CA server URL, e.g. export SGL_CA=http://localhost:8090"
+	case errors.Is(err, ErrUnsupportedScheme) || !strings.Contains(caURL, "://"):
+		return "Include the scheme: SGL_CA=http://" + strings.TrimPrefix(caURL, "//")
+	default:
+		return "Use the form http(s)://host:port, e.g. SGL_CA=http://localhost:8090"
+	}
+}
+
+// connectivityFix suggests a fix for an unreachable server
+func connectivityFix(caURL string, err error) string {
+	var dnsErr *net.DNSError
+	switch {
+	case errors.As(err, &dnsErr):
+		return fmt.Sprintf("The host %q does not resolve. Inside a container use the CA's compose service name; on the host use localhost", dnsErr.Name)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		fix := "Nothing is listening there. Start the CA server, or check the port in SGL_CA"
+		if strings.Contains(caURL, "localhost") || strings.Contains(caURL, "127.0.0.1") {
+			fix += "; inside a container localhost is the container itself, use the CA's service name or host.docker.internal"
+		}
+		return fix
+	case errors.Is(err, context.DeadlineExceeded) || isTimeout(err):
+		return "The connection timed out. Check firewalls and that SGL_CA names a reachable host"
+	case strings.Contains(err.Error(), "server gave HTTP response to HTTPS client"):
+		return "The server speaks plain HTTP: use http:// in SGL_CA"
+	case strings.Contains(err.Error(), "malformed HTTP response"):
+		return "The server speaks TLS: use https:// in SGL_CA"
+	default:
+		return "Check that the CA server is running and SGL_CA points at it"
+	}
+}
+
+// isTimeout reports whether err is a network timeout
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// authFix suggests a fix for rejected credentials
+func authFix() string {
+	switch {
+	case util.MustGetEnv("SGL_CA_TOKEN", "") != "":
+		return "SGL_CA_TOKEN was rejected: check it has not expired and is issued for the CA server's audience"
+	case util.MustGetEnv("SGL_CA_API_KEY", "") != "":
+		return "SGL_CA_API_KEY was rejected: it must match the server's API key or one of its namespace keys"
+	default:
+		return "The server requires authentication: set SGL_CA_API_KEY (or SGL_CA_TOKEN) to the server's API key"
+	}
+}
+
+// trustStoreFix explains how to add the root to this platform's trust store
+func trustStoreFix() string {
+	fetch := "Fetch the root with curl -o sgl-ca.crt $SGL_CA/ca, then "
+	if util.MustGetEnv("SGL_CA_API_KEY", "") != "" {
+		fetch = `Fetch the root with curl -H "X-API-Key: $SGL_CA_API_KEY" -o sgl-ca.crt $SGL_CA/ca, then `
+	}
+	return fetch + trustStoreCommand(runtime.GOOS)
+}
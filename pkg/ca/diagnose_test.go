@@ -0,0 +1,143 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// checkStatuses maps each check's name to its status
+func checkStatuses(d *Diagnosis) map[string]string {
+	statuses := make(map[string]string)
+	for _, check := range d.Checks {
+		statuses[check.Name] = check.Status
+	}
+	return statuses
+}
+
+// checkResult returns the named check
+func checkResult(d *Diagnosis, name string) DiagnosticCheck {
+	for _, check := range d.Checks {
+		if check.Name == name {
+			return check
+		}
+	}
+	return DiagnosticCheck{}
+}
+
+func TestDiagnose(t *testing.T) {
+	server, err := NewServer(&ServerConfig{CAConfig: DefaultCAConfig(), GUIAPIKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", server.handleHealthz)
+	mux.Handle("/ca", server.authenticate(http.HandlerFunc(server.handleCARequest)))
+	caServer := httptest.NewServer(mux)
+	defer caServer.Close()
+
+	originalPool := systemCertPool
+	defer func() { systemCertPool = originalPool }()
+	systemCertPool = func() (*x509.CertPool, error) { return x509.NewCertPool(), nil }
+
+	t.Setenv("SGL_CA_TOKEN", "")
+
+	t.Run("healthy", func(t *testing.T) {
+		t.Setenv("SGL_CA", caServer.URL+"/")
+		t.Setenv("SGL_CA_API_KEY", "secret")
+
+		d := Diagnose(context.Background())
+		want := map[string]string{CheckURL: HealthOK, CheckConnectivity: HealthOK, CheckAuth: HealthOK, CheckClock: HealthOK, CheckTrust: DiagnosisWarn}
+		if got := checkStatuses(d); !reflect.DeepEqual(got, want) || !d.OK() {
+			t.Errorf("Expected %v, got %+v", want, d.Checks)
+		}
+		if d.URL != caServer.URL || d.ServerVersion != Version {
+			t.Errorf("Unexpected URL %q or server version %q", d.URL, d.ServerVersion)
+		}
+		if trust := checkResult(d, CheckTrust); !strings.Contains(trust.Fix, "sgl-ca.crt") || !strings.Contains(trust.Fix, "X-API-Key") {
+			t.Errorf("Expected a trust store fix, got %q", trust.Fix)
+		}
+
+		// Once the root is in the trust store the warning goes away
+		systemCertPool = func() (*x509.CertPool, error) {
+			pool := x509.NewCertPool()
+			pool.AppendCertsFromPEM(server.ca.CertificatePEM())
+			return pool, nil
+		}
+		defer func() { systemCertPool = func() (*x509.CertPool, error) { return x509.NewCertPool(), nil } }()
+		if trust := checkResult(Diagnose(context.Background()), CheckTrust); trust.Status != HealthOK {
+			t.Errorf("Expected the root to be trusted, got %+v", trust)
+		}
+	})
+
+	t.Run("wrong API key", func(t *testing.T) {
+		t.Setenv("SGL_CA", caServer.URL)
+		t.Setenv("SGL_CA_API_KEY", "wrong")
+
+		d := Diagnose(context.Background())
+		auth := checkResult(d, CheckAuth)
+		if auth.Status != HealthFail || !strings.Contains(auth.Fix, "SGL_CA_API_KEY was rejected") || d.OK() {
+			t.Errorf("Expected the API key to be rejected, got %+v", auth)
+		}
+		if got := checkStatuses(d); got[CheckClock] != HealthOK || got[CheckTrust] != HealthSkipped {
+			t.Errorf("Expected the clock to be checked and trust skipped, got %v", got)
+		}
+	})
+
+	t.Run("invalid URL", func(t *testing.T) {
+		for caURL, fix := range map[string]string{"": "export SGL_CA=", "localhost:8090": "Include the scheme"} {
+			t.Setenv("SGL_CA", caURL)
+			d := Diagnose(context.Background())
+			if check := checkResult(d, CheckURL); check.Status != HealthFail || !strings.Contains(check.Fix, fix) {
+				t.Errorf("SGL_CA=%q: expected a fix containing %q, got %+v", caURL, fix, check)
+			}
+			if got := checkStatuses(d); got[CheckConnectivity] != HealthSkipped || got[CheckTrust] != HealthSkipped {
+				t.Errorf("Expected later checks to be skipped, got %v", got)
+			}
+		}
+	})
+
+	t.Run("connection refused", func(t *testing.T) {
+		closed := httptest.NewServer(http.NotFoundHandler())
+		closed.Close()
+		t.Setenv("SGL_CA", closed.URL)
+
+		check := checkResult(Diagnose(context.Background()), CheckConnectivity)
+		if check.Status != HealthFail || !strings.Contains(check.Fix, "Nothing is listening") {
+			t.Errorf("Expected a connection refused fix, got %+v", check)
+		}
+	})
+}
+
+func TestDiagnoseClockSkew(t *testing.T) {
+	// A server without /healthz is reachable; its Date header gives its time
+	skewed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(10*time.Minute).UTC().Format(http.TimeFormat))
+		if r.URL.Path == "/ca" {
+			json.NewEncoder(w).Encode("not a certificate")
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer skewed.Close()
+	t.Setenv("SGL_CA", skewed.URL)
+
+	d := Diagnose(context.Background())
+	if check := checkResult(d, CheckConnectivity); check.Status != HealthOK {
+		t.Errorf("Expected a server without /healthz to be reachable, got %+v", check)
+	}
+	if check := checkResult(d, CheckClock); check.Status != HealthFail || !strings.Contains(check.Detail, "behind") || check.Fix == "" {
+		t.Errorf("Expected a clock skew failure, got %+v", check)
+	}
+	if check := checkResult(d, CheckTrust); check.Status != HealthFail {
+		t.Errorf("Expected an invalid root to fail, got %+v", check)
+	}
+}
@@ -22,6 +22,9 @@ import (
 // Environment Variables Used:
 //   - SGL_CA (required): CA server URL for certificate requests
 //   - SGL_CA_API_KEY (optional): API key for CA server authentication
+//   - SGL_CA_TOKEN (optional): Bearer token for CA server authentication
+//   - SGL_CA_CERT_LIFETIME (optional): Serve short-lived certificates of this
+//     lifetime (e.g. "1h"), renewed in the background and logged (see NewShortLivedCertificate)
 //
 // Parameters:
 //   - serviceName: Name of the service for certificate generation
@@ -34,32 +37,25 @@ import (
 //
 // Returns a configured server with TLS certificates, ready to call ListenAndServe().
 func CreateSecureDualProtocolServer(serviceName, port string, sans []string, handler http.Handler, logger logi.Logger) (*dualprotocol.Server, error) {
-	// Request certificate from CA using simplified V2 API with automatic IP detection and CN selection
-	certResp, err := RequestCertificateV2(serviceName, sans)
-	if err != nil {
-		return nil, fmt.Errorf("failed to request certificate: %w", err)
-	}
-
-	// Print certificate details once issued
-	printCertificateDetails(serviceName, certResp.Certificate, logger)
-
-	// Parse the certificate and key
-	cert, err := tls.X509KeyPair([]byte(certResp.Certificate), []byte(certResp.PrivateKey))
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse certificate: %w", err)
-	}
-
-	// Create TLS config
-	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{cert},
-		ServerName:   serviceName + ".local",
-	}
+	return CreateSecureDualProtocolServerWithOptions(serviceName, port, sans, handler, logger, dualprotocol.DefaultOptions())
+}
 
+// CreateSecureDualProtocolServerWithOptions is CreateSecureDualProtocolServer
+// with explicit listener tuning (sniff timeout, TCP keepalive, header limits,
+// idle timeout). For long-lived streams such as SSE, also clear the returned
+// server's WriteTimeout.
+func CreateSecureDualProtocolServerWithOptions(serviceName, port string, sans []string, handler http.Handler, logger logi.Logger, opts dualprotocol.Options) (*dualprotocol.Server, error) {
 	// Use default logger if none provided
 	if logger == nil {
 		logger = logi.NewDemonLogger("dual-protocol-server")
 	}
 
+	tlsConfig, err := dualProtocolTLSConfig(serviceName, sans, logger)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.ServerName = serviceName + ".local"
+
 	// Use default handler if none provided
 	if handler == nil {
 		handler = createDefaultHandler()
@@ -78,7 +74,48 @@ func CreateSecureDualProtocolServer(serviceName, port string, sans []string, han
 		MaxHeaderBytes: 1 << 20, // 1MB
 	}
 
-	return dualprotocol.NewServer(server, tlsConfig, logger), nil
+	return dualprotocol.NewServerWithOptions(server, tlsConfig, logger, opts), nil
+}
+
+// dualProtocolTLSConfig serves a certificate from the CA, short-lived and
+// renewed in the background when CertLifetimeEnv is set
+func dualProtocolTLSConfig(serviceName string, sans []string, logger logi.Logger) (*tls.Config, error) {
+	lifetime, err := certLifetimeFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if lifetime > 0 {
+		rc, err := NewShortLivedCertificate(serviceName, sans, lifetime)
+		if err != nil {
+			return nil, err
+		}
+		stats := rc.Stats()
+		logger.Info("🔐 Short-lived certificate issued",
+			"service", serviceName,
+			"serial_number", stats.Serial,
+			"lifetime", lifetime.String(),
+			"expires_at", stats.NotAfter.Format("2006-01-02 15:04:05 MST"),
+			"renews_at", stats.RenewAt.Format("2006-01-02 15:04:05 MST"),
+		)
+		logRenewals(rc, logger)
+		return rc.TLSConfig(), nil
+	}
+
+	// Request certificate from CA using simplified V2 API with automatic IP detection and CN selection
+	certResp, err := RequestCertificateV2(serviceName, sans)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request certificate: %w", err)
+	}
+
+	// Print certificate details once issued
+	printCertificateDetails(serviceName, certResp.Certificate, logger)
+
+	// Parse the certificate and key
+	cert, err := tls.X509KeyPair([]byte(certResp.Certificate), []byte(certResp.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
 }
 
 // createDefaultHandler creates a simple default handler that shows protocol information
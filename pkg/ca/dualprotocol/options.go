@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package dualprotocol
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// Default listener tuning values used by DefaultOptions
+const (
+	DefaultSniffTimeout        = 5 * time.Second
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+	DefaultTCPKeepAlivePeriod  = 30 * time.Second
+)
+
+// Options tunes the dual protocol listener and the per-connection HTTP
+// timeouts. Zero values fall back to DefaultOptions, except where noted.
+type Options struct {
+	// SniffTimeout bounds how long to wait for a client's first byte before
+	// giving up on protocol detection. Raise it for slow clients.
+	SniffTimeout time.Duration
+
+	// TLSHandshakeTimeout bounds the TLS handshake after a TLS client is detected
+	TLSHandshakeTimeout time.Duration
+
+	// TCPKeepAlivePeriod sets the TCP keepalive period on accepted
+	// connections. Negative disables keepalives.
+	TCPKeepAlivePeriod time.Duration
+
+	// MaxHeaderBytes overrides http.Server.MaxHeaderBytes when non-zero
+	MaxHeaderBytes int
+
+	// ReadHeaderTimeout overrides http.Server.ReadHeaderTimeout when non-zero
+	ReadHeaderTimeout time.Duration
+
+	// IdleTimeout overrides http.Server.IdleTimeout (keep-alive idle time
+	// between requests) when non-zero. Long-lived streams such as SSE are
+	// not affected; avoid setting http.Server.WriteTimeout for those.
+	IdleTimeout time.Duration
+}
+
+// DefaultOptions returns the listener tuning used by NewServer
+func DefaultOptions() Options {
+	return Options{
+		SniffTimeout:        DefaultSniffTimeout,
+		TLSHandshakeTimeout: DefaultTLSHandshakeTimeout,
+		TCPKeepAlivePeriod:  DefaultTCPKeepAlivePeriod,
+	}
+}
+
+// withDefaults fills zero-valued listener settings from DefaultOptions
+func (o Options) withDefaults() Options {
+	defaults := DefaultOptions()
+	if o.SniffTimeout <= 0 {
+		o.SniffTimeout = defaults.SniffTimeout
+	}
+	if o.TLSHandshakeTimeout <= 0 {
+		o.TLSHandshakeTimeout = defaults.TLSHandshakeTimeout
+	}
+	if o.TCPKeepAlivePeriod == 0 {
+		o.TCPKeepAlivePeriod = defaults.TCPKeepAlivePeriod
+	}
+	return o
+}
+
+// applyToServer copies the HTTP-level settings onto server
+func (o Options) applyToServer(server *http.Server) {
+	if server == nil {
+		return
+	}
+	if o.MaxHeaderBytes > 0 {
+		server.MaxHeaderBytes = o.MaxHeaderBytes
+	}
+	if o.ReadHeaderTimeout > 0 {
+		server.ReadHeaderTimeout = o.ReadHeaderTimeout
+	}
+	if o.IdleTimeout > 0 {
+		server.IdleTimeout = o.IdleTimeout
+	}
+}
+
+// NewServerWithOptions creates a dual protocol server with explicit listener
+// tuning. NewServer is equivalent to passing DefaultOptions().
+func NewServerWithOptions(server *http.Server, tlsConfig *tls.Config, logger Logger, opts Options) *Server {
+	s := NewServer(server, tlsConfig, logger)
+	s.options = opts.withDefaults()
+	s.options.applyToServer(server)
+	return s
+}
+
+// Options returns the effective listener tuning
+func (s *Server) Options() Options {
+	return s.options
+}
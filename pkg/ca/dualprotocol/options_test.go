@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package dualprotocol
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nzions/sharedgolibs/pkg/logi"
+)
+
+func TestNewServerWithOptions(t *testing.T) {
+	httpServer := &http.Server{}
+	server := NewServerWithOptions(httpServer, nil, logi.NewDemonLogger("test-dual-protocol"), Options{
+		SniffTimeout:   2 * time.Second,
+		MaxHeaderBytes: 4096,
+		IdleTimeout:    time.Minute,
+	})
+
+	opts := server.Options()
+	if opts.SniffTimeout != 2*time.Second {
+		t.Errorf("Expected sniff timeout 2s, got %s", opts.SniffTimeout)
+	}
+	if opts.TLSHandshakeTimeout != DefaultTLSHandshakeTimeout || opts.TCPKeepAlivePeriod != DefaultTCPKeepAlivePeriod {
+		t.Errorf("Expected defaults for unset fields, got %+v", opts)
+	}
+	if httpServer.MaxHeaderBytes != 4096 || httpServer.IdleTimeout != time.Minute {
+		t.Errorf("Expected HTTP settings applied, got MaxHeaderBytes=%d IdleTimeout=%s", httpServer.MaxHeaderBytes, httpServer.IdleTimeout)
+	}
+
+	if got := NewServer(&http.Server{}, nil, logi.NewDemonLogger("test")).Options(); got != DefaultOptions() {
+		t.Errorf("NewServer should use DefaultOptions, got %+v", got)
+	}
+}
+
+// serveWithOptions starts a server on a random local port with opts
+func serveWithOptions(t *testing.T, httpServer *http.Server, opts Options) (*Server, string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := NewServerWithOptions(httpServer, testTLSConfig(t), logi.NewDemonLogger("test-dual-protocol"), opts)
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	return server, listener.Addr().String()
+}
+
+// waitForClose reads from conn until the server closes it or timeout elapses
+func waitForClose(t *testing.T, conn net.Conn, timeout time.Duration) time.Duration {
+	t.Helper()
+	start := time.Now()
+	conn.SetReadDeadline(start.Add(timeout))
+	_, err := io.ReadAll(conn)
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Fatalf("Server did not close idle connection within %s", timeout)
+	}
+	return time.Since(start)
+}
+
+func TestSniffTimeout(t *testing.T) {
+	server, addr := serveWithOptions(t, &http.Server{Handler: http.NotFoundHandler()}, Options{SniffTimeout: 100 * time.Millisecond})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	waitForClose(t, conn, 3*time.Second)
+	waitForStats(t, server, func(s Stats) bool { return s.SniffErrors == 1 })
+}
+
+func TestReadHeaderTimeoutPreservedAfterSniff(t *testing.T) {
+	httpServer := &http.Server{Handler: http.NotFoundHandler()}
+	_, addr := serveWithOptions(t, httpServer, Options{ReadHeaderTimeout: 200 * time.Millisecond})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	// Send enough for protocol detection, then stall mid-header; the
+	// server's header deadline must still apply after sniffing
+	if _, err := conn.Write([]byte("GET / HTTP/1.1\r\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	if elapsed := waitForClose(t, conn, 3*time.Second); elapsed > 2*time.Second {
+		t.Errorf("Connection closed after %s, expected ReadHeaderTimeout to apply", elapsed)
+	}
+}
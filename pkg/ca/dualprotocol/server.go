@@ -6,6 +6,7 @@ import (
 	"bufio"
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -33,9 +34,6 @@ const (
 	// TLS record type for handshake (first byte of TLS connection)
 	tlsHandshakeType = 0x16
 
-	// Detection timeout for protocol detection
-	detectionTimeout = 5 * time.Second
-
 	// Buffer size for peeking at connection data
 	peekBufferSize = 1
 )
@@ -46,6 +44,16 @@ func GetConnectionInfo(r *http.Request) (*ConnectionInfo, bool) {
 	return info, ok
 }
 
+// ConnectionInfoFromConn returns the detected connection info for a
+// connection accepted by a dual protocol server, such as the net.Conn
+// returned by http.Hijacker or a WebSocket library's NetConn().
+func ConnectionInfoFromConn(conn net.Conn) (*ConnectionInfo, bool) {
+	if dc, ok := conn.(*dualConn); ok {
+		return dc.connInfo, true
+	}
+	return nil, false
+}
+
 // Server provides a server that can handle both HTTP and HTTPS
 // connections on the same port by detecting the protocol from connection bytes
 type Server struct {
@@ -57,8 +65,18 @@ type Server struct {
 	shutdownCtx    context.Context
 	shutdownCancel context.CancelFunc
 	logger         Logger
+	stats          *listenerStats
+	options        Options
+
+	mutex       sync.Mutex // Guards listener and dualListener while serving
+	serving     bool
+	connContext sync.Once // Installs the ConnContext wrapper
 }
 
+// ErrAlreadyServing is returned by Serve and ListenAndServe while the
+// server is already serving a listener
+var ErrAlreadyServing = errors.New("dual protocol server is already serving")
+
 // Logger interface for logging operations
 type Logger interface {
 	Info(msg string, keysAndValues ...any)
@@ -72,6 +90,8 @@ type dualListener struct {
 	net.Listener
 	tlsConfig *tls.Config
 	logger    Logger
+	stats     *listenerStats
+	options   Options
 }
 
 // dualConn wraps net.Conn to detect TLS vs HTTP protocol
@@ -85,6 +105,13 @@ type dualConn struct {
 	buffer       []byte
 	connInfo     *ConnectionInfo
 	logger       Logger
+	stats        *listenerStats
+	options      Options
+
+	// Read deadline requested by the caller (e.g. http.Server), restored
+	// after protocol detection applies its own sniff deadline
+	deadlineMu   sync.Mutex
+	readDeadline time.Time
 }
 
 // bufferedConn wraps a connection to use a buffered reader for reads
@@ -104,9 +131,32 @@ func (c *dualConn) GetConnectionInfo() *ConnectionInfo {
 	return c.connInfo
 }
 
-// WrapHandlerWithConnectionInfo wraps an HTTP handler to inject connection info into the request context
+// SetDeadline records the read deadline so protocol detection can restore it
+func (c *dualConn) SetDeadline(t time.Time) error {
+	c.deadlineMu.Lock()
+	c.readDeadline = t
+	c.deadlineMu.Unlock()
+	return c.Conn.SetDeadline(t)
+}
+
+// SetReadDeadline records the read deadline so protocol detection can restore it
+func (c *dualConn) SetReadDeadline(t time.Time) error {
+	c.deadlineMu.Lock()
+	c.readDeadline = t
+	c.deadlineMu.Unlock()
+	return c.Conn.SetReadDeadline(t)
+}
+
+// WrapHandlerWithConnectionInfo wraps an HTTP handler to inject connection info into the request context.
+// Requests served by a dual protocol Server already carry the detected info,
+// which is passed through unchanged.
 func WrapHandlerWithConnectionInfo(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := GetConnectionInfo(r); ok {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
 		// Create connection info based on the request
 		connInfo := &ConnectionInfo{
 			Protocol:   "HTTP",
@@ -140,11 +190,20 @@ func NewServer(server *http.Server, tlsConfig *tls.Config, logger Logger) *Serve
 		shutdownCtx:    ctx,
 		shutdownCancel: cancel,
 		logger:         logger,
+		stats:          &listenerStats{},
+		options:        DefaultOptions(),
 	}
 }
 
 // ListenAndServe starts the dual protocol server on the configured address
 func (s *Server) ListenAndServe() error {
+	s.mutex.Lock()
+	serving := s.serving
+	s.mutex.Unlock()
+	if serving {
+		return ErrAlreadyServing
+	}
+
 	if s.Addr == "" {
 		s.Addr = ":8443"
 	}
@@ -154,16 +213,55 @@ func (s *Server) ListenAndServe() error {
 		return fmt.Errorf("failed to listen on %s: %w", s.Addr, err)
 	}
 
+	return s.Serve(listener)
+}
+
+// Serve accepts connections on listener, detecting HTTP vs HTTPS per
+// connection. Like http.Server.Serve it closes listener when it returns;
+// it returns ErrAlreadyServing, closing listener, while another Serve or
+// ListenAndServe call is running.
+func (s *Server) Serve(listener net.Listener) error {
+	s.mutex.Lock()
+	if s.serving {
+		s.mutex.Unlock()
+		listener.Close()
+		return ErrAlreadyServing
+	}
+	s.serving = true
 	s.listener = listener
 	s.dualListener = &dualListener{
 		Listener:  listener,
 		tlsConfig: s.tlsConfig,
 		logger:    s.logger,
+		stats:     s.stats,
+		options:   s.options,
 	}
+	dualListener := s.dualListener
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		s.serving = false
+		s.mutex.Unlock()
+	}()
+
+	// Attach each connection's detected info to its request contexts. This
+	// also survives connection hijacking (e.g. WebSocket upgrades).
+	s.connContext.Do(func() {
+		userConnContext := s.Server.ConnContext
+		s.Server.ConnContext = func(ctx context.Context, c net.Conn) context.Context {
+			if userConnContext != nil {
+				ctx = userConnContext(ctx, c)
+			}
+			if info, ok := ConnectionInfoFromConn(c); ok {
+				ctx = context.WithValue(ctx, ConnectionInfoKey, info)
+			}
+			return ctx
+		}
+	})
 
-	s.logger.Info("Starting dual protocol server", "addr", s.Addr)
+	s.logger.Info("Starting dual protocol server", "addr", listener.Addr().String())
 
-	return s.Server.Serve(s.dualListener)
+	return s.Server.Serve(dualListener)
 }
 
 // Shutdown gracefully shuts down the server
@@ -185,12 +283,23 @@ func (l *dualListener) Accept() (net.Conn, error) {
 		return nil, err
 	}
 
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		if l.options.TCPKeepAlivePeriod > 0 {
+			tcpConn.SetKeepAlive(true)
+			tcpConn.SetKeepAlivePeriod(l.options.TCPKeepAlivePeriod)
+		} else if l.options.TCPKeepAlivePeriod < 0 {
+			tcpConn.SetKeepAlive(false)
+		}
+	}
+
 	return &dualConn{
 		Conn:         conn,
 		originalConn: conn, // Store original connection
 		reader:       bufio.NewReader(conn),
 		tlsConfig:    l.tlsConfig,
 		logger:       l.logger,
+		stats:        l.stats,
+		options:      l.options,
 		connInfo: &ConnectionInfo{
 			RemoteAddr: conn.RemoteAddr().String(),
 			DetectedAt: time.Now(),
@@ -221,18 +330,24 @@ func (c *dualConn) detectProtocol() error {
 	c.detected = true
 
 	// Set detection timeout
-	if err := c.Conn.SetReadDeadline(time.Now().Add(detectionTimeout)); err != nil {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.options.SniffTimeout)); err != nil {
 		return fmt.Errorf("failed to set read deadline: %w", err)
 	}
 
 	// Peek at the first byte to determine protocol
 	firstByte, err := c.reader.Peek(peekBufferSize)
 	if err != nil {
-		return fmt.Errorf("failed to peek at connection data: %w", err)
+		err = fmt.Errorf("failed to peek at connection data: %w", err)
+		c.stats.sniffErrors.Add(1)
+		c.stats.recordError(c.connInfo.RemoteAddr, err)
+		return err
 	}
 
-	// Reset deadline after detection
-	if err := c.Conn.SetReadDeadline(time.Time{}); err != nil {
+	// Restore the caller's deadline after detection
+	c.deadlineMu.Lock()
+	readDeadline := c.readDeadline
+	c.deadlineMu.Unlock()
+	if err := c.Conn.SetReadDeadline(readDeadline); err != nil {
 		c.logger.Warn("Failed to reset read deadline", "error", err)
 	}
 
@@ -242,12 +357,19 @@ func (c *dualConn) detectProtocol() error {
 		c.connInfo.IsTLS = true
 		c.connInfo.Protocol = "HTTPS"
 		c.logger.Debug("Detected TLS connection", "remote_addr", c.Conn.RemoteAddr())
-		return c.upgradeToTLS()
+		if err := c.upgradeToTLS(); err != nil {
+			c.stats.tlsFailures.Add(1)
+			c.stats.recordError(c.connInfo.RemoteAddr, err)
+			return err
+		}
+		c.stats.httpsConns.Add(1)
+		return nil
 	}
 
 	c.isTLS = false
 	c.connInfo.IsTLS = false
 	c.connInfo.Protocol = "HTTP"
+	c.stats.httpConns.Add(1)
 	c.logger.Debug("Detected HTTP connection", "remote_addr", c.Conn.RemoteAddr())
 	return nil
 }
@@ -269,21 +391,14 @@ func (c *dualConn) upgradeToTLS() error {
 	tlsConn := tls.Server(wrappedConn, c.tlsConfig)
 
 	// Perform TLS handshake with timeout
-	handshakeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	handshakeCtx, cancel := context.WithTimeout(context.Background(), c.options.TLSHandshakeTimeout)
 	defer cancel()
 
-	handshakeChan := make(chan error, 1)
-	go func() {
-		handshakeChan <- tlsConn.Handshake()
-	}()
-
-	select {
-	case err := <-handshakeChan:
-		if err != nil {
-			return fmt.Errorf("TLS handshake failed: %w", err)
+	if err := tlsConn.HandshakeContext(handshakeCtx); err != nil {
+		if handshakeCtx.Err() != nil {
+			return fmt.Errorf("TLS handshake timeout after %s", c.options.TLSHandshakeTimeout)
 		}
-	case <-handshakeCtx.Done():
-		return fmt.Errorf("TLS handshake timeout")
+		return fmt.Errorf("TLS handshake failed: %w", err)
 	}
 
 	// Update connection info with TLS details
@@ -3,8 +3,12 @@
 package dualprotocol
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
+	"net"
 	"net/http"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -123,3 +127,60 @@ func (m *mockResponseWriter) Write(data []byte) (int, error) {
 func (m *mockResponseWriter) WriteHeader(status int) {
 	m.status = status
 }
+
+func TestServeWhileServing(t *testing.T) {
+	var connContexts atomic.Int32
+	httpServer := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			connContexts.Add(1)
+			return ctx
+		},
+	}
+	server := NewServer(httpServer, testTLSConfig(t), logi.NewDemonLogger("test-dual-protocol"))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	get := func() {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			resp, err := client.Get("http://" + listener.Addr().String() + "/")
+			if err == nil {
+				resp.Body.Close()
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Server never answered: %v", err)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	get()
+
+	second, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Serve(second); !errors.Is(err, ErrAlreadyServing) {
+		t.Errorf("Expected ErrAlreadyServing, got %v", err)
+	}
+	if _, err := second.Accept(); err == nil {
+		t.Error("Expected the rejected listener to be closed")
+	}
+	if err := server.ListenAndServe(); !errors.Is(err, ErrAlreadyServing) {
+		t.Errorf("Expected ErrAlreadyServing from ListenAndServe, got %v", err)
+	}
+
+	// The user's ConnContext still runs once per connection
+	before := connContexts.Load()
+	get()
+	if got := connContexts.Load() - before; got != 1 {
+		t.Errorf("Expected ConnContext to run once for a connection, got %d", got)
+	}
+}
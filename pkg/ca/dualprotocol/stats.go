@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package dualprotocol
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DebugPath is the conventional mount point for DebugHandler
+const DebugPath = "/debug/dualprotocol"
+
+// Stats holds per-listener connection counters
+type Stats struct {
+	HTTPConnections      uint64 `json:"http_connections"`
+	HTTPSConnections     uint64 `json:"https_connections"`
+	SniffErrors          uint64 `json:"sniff_errors"`
+	TLSHandshakeFailures uint64 `json:"tls_handshake_failures"`
+
+	// Most recent detection or handshake failure, for diagnosing clients
+	LastError           string     `json:"last_error,omitempty"`
+	LastErrorRemoteAddr string     `json:"last_error_remote_addr,omitempty"`
+	LastErrorAt         *time.Time `json:"last_error_at,omitempty"`
+}
+
+// listenerStats is the concurrency-safe counterpart of Stats
+type listenerStats struct {
+	httpConns   atomic.Uint64
+	httpsConns  atomic.Uint64
+	sniffErrors atomic.Uint64
+	tlsFailures atomic.Uint64
+
+	mutex           sync.Mutex
+	lastError       string
+	lastErrorRemote string
+	lastErrorAt     time.Time
+}
+
+// recordError stores the most recent failure
+func (s *listenerStats) recordError(remoteAddr string, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lastError = err.Error()
+	s.lastErrorRemote = remoteAddr
+	s.lastErrorAt = time.Now()
+}
+
+// snapshot returns a point-in-time copy of the counters
+func (s *listenerStats) snapshot() Stats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stats := Stats{
+		HTTPConnections:      s.httpConns.Load(),
+		HTTPSConnections:     s.httpsConns.Load(),
+		SniffErrors:          s.sniffErrors.Load(),
+		TLSHandshakeFailures: s.tlsFailures.Load(),
+		LastError:            s.lastError,
+		LastErrorRemoteAddr:  s.lastErrorRemote,
+	}
+	if !s.lastErrorAt.IsZero() {
+		at := s.lastErrorAt
+		stats.LastErrorAt = &at
+	}
+	return stats
+}
+
+// GetStats returns the connection counters for this server's listener
+func (s *Server) GetStats() Stats {
+	return s.stats.snapshot()
+}
+
+// DebugHandler returns a handler that serves GetStats as JSON. Mount it
+// explicitly (e.g. at DebugPath); it is not registered automatically.
+func (s *Server) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.GetStats())
+	})
+}
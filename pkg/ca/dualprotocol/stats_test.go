@@ -0,0 +1,146 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package dualprotocol
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nzions/sharedgolibs/pkg/logi"
+)
+
+// testTLSConfig returns a TLS config with a self-signed certificate for 127.0.0.1
+func testTLSConfig(t *testing.T) *tls.Config {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
+
+// startTestServer serves handler on a random local port and returns its address
+func startTestServer(t *testing.T, handler http.Handler) (*Server, string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	server := NewServer(&http.Server{Handler: handler}, testTLSConfig(t), logi.NewDemonLogger("test-dual-protocol"))
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	return server, listener.Addr().String()
+}
+
+// waitForStats polls until cond holds for the server's stats
+func waitForStats(t *testing.T, server *Server, cond func(Stats) bool) Stats {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stats := server.GetStats()
+		if cond(stats) {
+			return stats
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for stats, last: %+v", stats)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServerStats(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	})
+	server, addr := startTestServer(t, handler)
+
+	// Plain HTTP
+	resp, err := http.Get("http://" + addr + "/")
+	if err != nil {
+		t.Fatalf("HTTP request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	// HTTPS on the same port
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err = client.Get("https://" + addr + "/")
+	if err != nil {
+		t.Fatalf("HTTPS request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	// Client that connects and hangs up before sending anything
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	conn.Close()
+
+	// Client that starts a TLS record and then sends garbage
+	conn, err = net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	conn.Write([]byte{tlsHandshakeType, 0x03, 0x01, 0x00, 0x05, 'h', 'e', 'l', 'l', 'o'})
+	conn.Close()
+
+	stats := waitForStats(t, server, func(s Stats) bool {
+		return s.HTTPConnections >= 1 && s.HTTPSConnections >= 1 && s.SniffErrors >= 1 && s.TLSHandshakeFailures >= 1
+	})
+	if stats.LastError == "" || stats.LastErrorAt == nil || stats.LastErrorRemoteAddr == "" {
+		t.Errorf("Expected last error details, got %+v", stats)
+	}
+}
+
+func TestDebugHandler(t *testing.T) {
+	server := NewServer(&http.Server{}, nil, logi.NewDemonLogger("test-dual-protocol"))
+	server.stats.httpConns.Add(3)
+	server.stats.sniffErrors.Add(1)
+
+	rr := httptest.NewRecorder()
+	server.DebugHandler().ServeHTTP(rr, httptest.NewRequest("GET", DebugPath, nil))
+
+	var stats Stats
+	if err := json.Unmarshal(rr.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Failed to decode stats: %v", err)
+	}
+	if stats.HTTPConnections != 3 || stats.SniffErrors != 1 || stats.LastErrorAt != nil {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	rr = httptest.NewRecorder()
+	server.DebugHandler().ServeHTTP(rr, httptest.NewRequest("POST", DebugPath, nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", rr.Code)
+	}
+}
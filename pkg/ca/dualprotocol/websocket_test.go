@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package dualprotocol
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"testing"
+	"time"
+
+	gorilla "github.com/gorilla/websocket"
+	"nhooyr.io/websocket"
+)
+
+// wsEchoHandler upgrades with gorilla/websocket and echoes each message
+// prefixed with the detected protocol of the underlying connection
+func wsEchoHandler(t *testing.T) http.Handler {
+	upgrader := gorilla.Upgrader{}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, ok := GetConnectionInfo(r)
+		if !ok {
+			t.Error("Expected connection info in request context")
+			http.Error(w, "no connection info", http.StatusInternalServerError)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		// ConnectionInfo must also be reachable from the hijacked connection
+		connInfo, ok := ConnectionInfoFromConn(conn.NetConn())
+		if !ok || connInfo != info {
+			t.Error("Expected hijacked connection to carry the same ConnectionInfo")
+		}
+
+		for {
+			msgType, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(msgType, append([]byte(info.Protocol+":"), msg...)); err != nil {
+				return
+			}
+		}
+	})
+}
+
+func TestWebSocket_Gorilla(t *testing.T) {
+	_, addr := startTestServer(t, wsEchoHandler(t))
+
+	tests := []struct {
+		url      string
+		protocol string
+	}{
+		{"ws://" + addr + "/ws", "HTTP"},
+		{"wss://" + addr + "/ws", "HTTPS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.protocol, func(t *testing.T) {
+			dialer := gorilla.Dialer{
+				TLSClientConfig:  &tls.Config{InsecureSkipVerify: true},
+				HandshakeTimeout: 5 * time.Second,
+			}
+			conn, _, err := dialer.Dial(tt.url, nil)
+			if err != nil {
+				t.Fatalf("Dial %s failed: %v", tt.url, err)
+			}
+			defer conn.Close()
+
+			if err := conn.WriteMessage(gorilla.TextMessage, []byte("ping")); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				t.Fatalf("Read failed: %v", err)
+			}
+			if want := tt.protocol + ":ping"; string(msg) != want {
+				t.Errorf("Expected %q, got %q", want, msg)
+			}
+		})
+	}
+}
+
+func TestWebSocket_Nhooyr(t *testing.T) {
+	server, addr := startTestServer(t, wsEchoHandler(t))
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+
+	for _, scheme := range []string{"ws", "wss"} {
+		t.Run(scheme, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			conn, _, err := websocket.Dial(ctx, scheme+"://"+addr+"/ws", &websocket.DialOptions{HTTPClient: client})
+			if err != nil {
+				t.Fatalf("Dial failed: %v", err)
+			}
+			defer conn.Close(websocket.StatusNormalClosure, "")
+
+			if err := conn.Write(ctx, websocket.MessageText, []byte("ping")); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			_, msg, err := conn.Read(ctx)
+			if err != nil {
+				t.Fatalf("Read failed: %v", err)
+			}
+
+			want := "HTTP:ping"
+			if scheme == "wss" {
+				want = "HTTPS:ping"
+			}
+			if string(msg) != want {
+				t.Errorf("Expected %q, got %q", want, msg)
+			}
+		})
+	}
+
+	stats := server.GetStats()
+	if stats.HTTPConnections < 1 || stats.HTTPSConnections < 1 {
+		t.Errorf("Expected both ws:// and wss:// connections to be counted, got %+v", stats)
+	}
+}
+
+func TestWrapHandlerPreservesDetectedInfo(t *testing.T) {
+	var protocol string
+	handler := WrapHandlerWithConnectionInfo(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, _ := GetConnectionInfo(r)
+		protocol = info.Protocol
+	}))
+	_, addr := startTestServer(t, handler)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Get("https://" + addr + "/")
+	if err != nil {
+		t.Fatalf("HTTPS request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if protocol != "HTTPS" {
+		t.Errorf("Expected wrapped handler to see detected HTTPS, got %q", protocol)
+	}
+}
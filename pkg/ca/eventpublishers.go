@@ -0,0 +1,314 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// DefaultEventPrefix prefixes event subjects and channels, giving e.g.
+// sgl.ca.cert.issued
+const DefaultEventPrefix = "sgl.ca"
+
+// NewEventPublisher returns a NATS or Redis publisher for brokerURL
+// (nats://[user:pass@]host[:4222] or redis://[:pass@]host[:6379]). Events
+// go to <prefix>.<type>, with DefaultEventPrefix when prefix is empty.
+func NewEventPublisher(brokerURL, prefix string) (EventPublisher, error) {
+	u, err := url.Parse(brokerURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid event broker URL: %w", err)
+	}
+	switch u.Scheme {
+	case "nats":
+		return NewNATSPublisher(brokerURL, prefix)
+	case "redis":
+		return NewRedisPublisher(brokerURL, prefix)
+	default:
+		return nil, fmt.Errorf("unsupported event broker scheme %q (want nats or redis)", u.Scheme)
+	}
+}
+
+// NATSPublisher publishes events to a NATS server with the core text
+// protocol, on subject <prefix>.<type>. Each publish is confirmed with a
+// PING round trip so broker errors are reported.
+type NATSPublisher struct {
+	prefix string
+	conn   *lineConn
+}
+
+// NewNATSPublisher creates a publisher for nats://[user:pass@]host[:port];
+// a user without a password is sent as an auth token
+func NewNATSPublisher(natsURL, prefix string) (*NATSPublisher, error) {
+	u, err := parseBrokerURL(natsURL, "nats", "4222")
+	if err != nil {
+		return nil, err
+	}
+
+	connect := map[string]any{"verbose": false, "pedantic": false, "name": "sharedgolibs-ca", "lang": "go", "version": Version}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			connect["user"] = u.User.Username()
+			connect["pass"] = pass
+		} else {
+			connect["auth_token"] = u.User.Username()
+		}
+	}
+	connectJSON, err := json.Marshal(connect)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NATSPublisher{
+		prefix: eventPrefix(prefix),
+		conn: &lineConn{
+			addr: u.Host,
+			handshake: func(c *lineConn) error {
+				// The server greets with INFO before accepting CONNECT
+				if _, err := c.readLine(); err != nil {
+					return err
+				}
+				if err := c.write(fmt.Sprintf("CONNECT %s\r\nPING\r\n", connectJSON)); err != nil {
+					return err
+				}
+				return readNATSPong(c)
+			},
+		},
+	}, nil
+}
+
+// Publish sends an event as JSON to <prefix>.<type>
+func (p *NATSPublisher) Publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	subject := p.prefix + "." + event.Type
+	return p.conn.do(ctx, func(c *lineConn) error {
+		if err := c.write(fmt.Sprintf("PUB %s %d\r\n%s\r\nPING\r\n", subject, len(payload), payload)); err != nil {
+			return err
+		}
+		return readNATSPong(c)
+	})
+}
+
+// Close closes the connection to the server
+func (p *NATSPublisher) Close() error {
+	return p.conn.close()
+}
+
+// readNATSPong reads until the PONG answering our PING, answering server
+// PINGs and failing on -ERR
+func readNATSPong(c *lineConn) error {
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if err := c.write("PONG\r\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+// RedisPublisher publishes events with Redis PUBLISH on channel
+// <prefix>.<type>; subscribe to all of them with PSUBSCRIBE sgl.ca.*
+type RedisPublisher struct {
+	prefix string
+	conn   *lineConn
+}
+
+// NewRedisPublisher creates a publisher for redis://[[user]:pass@]host[:port]
+func NewRedisPublisher(redisURL, prefix string) (*RedisPublisher, error) {
+	u, err := parseBrokerURL(redisURL, "redis", "6379")
+	if err != nil {
+		return nil, err
+	}
+
+	var auth []string
+	if u.User != nil {
+		pass, _ := u.User.Password()
+		auth = []string{"AUTH", pass}
+		if user := u.User.Username(); user != "" {
+			auth = []string{"AUTH", user, pass}
+		}
+	}
+
+	p := &RedisPublisher{prefix: eventPrefix(prefix), conn: &lineConn{addr: u.Host}}
+	if auth != nil {
+		p.conn.handshake = func(c *lineConn) error {
+			_, err := redisCommand(c, auth...)
+			return err
+		}
+	}
+	return p, nil
+}
+
+// Publish sends an event as JSON to channel <prefix>.<type>
+func (p *RedisPublisher) Publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	channel := p.prefix + "." + event.Type
+	return p.conn.do(ctx, func(c *lineConn) error {
+		_, err := redisCommand(c, "PUBLISH", channel, string(payload))
+		return err
+	})
+}
+
+// Close closes the connection to the server
+func (p *RedisPublisher) Close() error {
+	return p.conn.close()
+}
+
+// redisCommand sends a command in RESP and reads a simple, integer, or
+// error reply
+func redisCommand(c *lineConn, args ...string) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := c.write(b.String()); err != nil {
+		return "", err
+	}
+
+	reply, err := c.readLine()
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case strings.HasPrefix(reply, "+"), strings.HasPrefix(reply, ":"):
+		return reply[1:], nil
+	case strings.HasPrefix(reply, "-"):
+		return "", fmt.Errorf("redis: %s", reply[1:])
+	default:
+		return "", fmt.Errorf("redis: unexpected reply %q", reply)
+	}
+}
+
+// parseBrokerURL parses a broker URL, adding the default port
+func parseBrokerURL(rawURL, scheme, defaultPort string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s URL: %w", scheme, err)
+	}
+	if u.Scheme != scheme || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid %s URL %q: want %s://host[:port]", scheme, rawURL, scheme)
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+	return u, nil
+}
+
+// eventPrefix returns prefix, or DefaultEventPrefix if empty
+func eventPrefix(prefix string) string {
+	if prefix == "" {
+		return DefaultEventPrefix
+	}
+	return strings.TrimSuffix(prefix, ".")
+}
+
+// lineConn is a lazily dialed connection for line-based broker protocols.
+// A request on a reused connection that fails is retried once on a new
+// connection, since brokers close idle clients.
+type lineConn struct {
+	addr      string
+	handshake func(*lineConn) error // Run after each dial (optional)
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// do runs a request while holding the connection, dialing if needed
+func (c *lineConn) do(ctx context.Context, request func(*lineConn) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		reused := c.conn != nil
+		err := c.attempt(ctx, request)
+		if err == nil {
+			return nil
+		}
+		c.drop()
+		if !reused || attempt > 0 || ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// attempt dials if needed and runs one request under the context deadline
+func (c *lineConn) attempt(ctx context.Context, request func(*lineConn) error) error {
+	dialed := false
+	if c.conn == nil {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+		if err != nil {
+			return err
+		}
+		c.conn, c.reader = conn, bufio.NewReader(conn)
+		dialed = true
+	}
+
+	deadline, _ := ctx.Deadline() // Zero clears an earlier deadline
+	c.conn.SetDeadline(deadline)
+
+	if dialed && c.handshake != nil {
+		if err := c.handshake(c); err != nil {
+			return err
+		}
+	}
+	return request(c)
+}
+
+// write sends raw protocol text
+func (c *lineConn) write(s string) error {
+	_, err := c.conn.Write([]byte(s))
+	return err
+}
+
+// readLine reads one CRLF-terminated line without the terminator
+func (c *lineConn) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// drop closes a connection after an error
+func (c *lineConn) drop() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn, c.reader = nil, nil
+	}
+}
+
+// close closes the connection
+func (c *lineConn) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn, c.reader = nil, nil
+	return err
+}
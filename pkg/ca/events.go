@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Event types published by the CA
+const (
+	EventCertIssued  = "cert.issued"  // A certificate was issued
+	EventCertRevoked = "cert.revoked" // A revocation was picked up from index.json
+	EventCARotated   = "ca.rotated"   // ReloadFromDisk loaded a different root
+)
+
+// Event describes a change in the CA. Publishers send it as JSON.
+type Event struct {
+	Type         string    `json:"type"`
+	Time         time.Time `json:"time"`
+	SerialNumber string    `json:"serial_number,omitempty"`
+	ServiceName  string    `json:"service_name,omitempty"`
+	Namespace    string    `json:"namespace,omitempty"`
+	SANs         []string  `json:"sans,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at"` // Of the certificate, or of the new root for ca.rotated
+
+	// CAFingerprint is the SHA-256 of the root certificate (ca.rotated only)
+	CAFingerprint string `json:"ca_fingerprint,omitempty"`
+}
+
+// EventPublisher delivers CA events to other tooling, such as service
+// managers and dashboards that react to issuance instead of polling
+type EventPublisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Event queue limits: events are published in order by one goroutine, and
+// dropped with a log line if the publisher falls this far behind
+const (
+	eventQueueSize      = 256
+	eventPublishTimeout = 5 * time.Second
+)
+
+// eventQueue publishes events in the background so a slow or unreachable
+// broker never delays issuance
+type eventQueue struct {
+	publisher EventPublisher
+	events    chan Event
+	done      chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// newEventQueue starts publishing queued events to publisher
+func newEventQueue(publisher EventPublisher) *eventQueue {
+	q := &eventQueue{
+		publisher: publisher,
+		events:    make(chan Event, eventQueueSize),
+		done:      make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// run publishes events until the queue is closed and drained
+func (q *eventQueue) run() {
+	defer close(q.done)
+	for event := range q.events {
+		ctx, cancel := context.WithTimeout(context.Background(), eventPublishTimeout)
+		if err := q.publisher.Publish(ctx, event); err != nil {
+			fmt.Printf("[ca] Failed to publish %s event: %v\n", event.Type, err)
+		}
+		cancel()
+	}
+}
+
+// publish queues an event without blocking
+func (q *eventQueue) publish(event Event) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	select {
+	case q.events <- event:
+	default:
+		fmt.Printf("[ca] Event queue full, dropping %s event\n", event.Type)
+	}
+}
+
+// close stops accepting events and waits for queued ones to be published
+func (q *eventQueue) close() {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.events)
+	}
+	q.mu.Unlock()
+	<-q.done
+}
+
+// publishEvent queues an event if a publisher is configured
+func (ca *CA) publishEvent(event Event) {
+	if ca.events == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	ca.events.publish(event)
+}
+
+// certEvent builds a certificate event from an issued certificate
+func certEvent(eventType string, cert *IssuedCert) Event {
+	return Event{
+		Type:         eventType,
+		SerialNumber: cert.SerialNumber,
+		ServiceName:  cert.ServiceName,
+		Namespace:    cert.Namespace,
+		SANs:         cert.Domains,
+		ExpiresAt:    cert.ExpiresAt,
+	}
+}
+
+// publishIssued publishes cert.issued for a certificate the storage just
+// recorded, looked up by the serial number in its PEM
+func (ca *CA) publishIssued(certPEM string) {
+	if ca.events == nil {
+		return
+	}
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return
+	}
+	parsed, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return
+	}
+	if cert, ok := ca.GetCertificateBySerial(fmt.Sprintf("%x", parsed.SerialNumber)); ok {
+		ca.publishEvent(certEvent(EventCertIssued, cert))
+	}
+}
+
+// rootEvent builds a ca.rotated event for a root certificate
+func rootEvent(root *x509.Certificate) Event {
+	fingerprint := sha256.Sum256(root.Raw)
+	return Event{
+		Type:          EventCARotated,
+		SerialNumber:  fmt.Sprintf("%x", root.SerialNumber),
+		ExpiresAt:     root.NotAfter,
+		CAFingerprint: hex.EncodeToString(fingerprint[:]),
+	}
+}
@@ -0,0 +1,243 @@
+package ca
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingPublisher collects published events
+type recordingPublisher struct {
+	events chan Event
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event Event) error {
+	p.events <- event
+	return nil
+}
+
+func (p *recordingPublisher) next(t *testing.T) Event {
+	t.Helper()
+	select {
+	case event := <-p.events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected an event")
+		return Event{}
+	}
+}
+
+func TestCAEvents(t *testing.T) {
+	dir := t.TempDir()
+	publisher := &recordingPublisher{events: make(chan Event, 10)}
+	config := DefaultCAConfig()
+	config.KeySize = 2048
+	config.PersistDir = dir
+	config.EventPublisher = publisher
+
+	ca, err := NewCA(config)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	defer ca.Close()
+
+	t.Run("Issued", func(t *testing.T) {
+		if _, err := ca.IssueServiceCertificate(CertRequest{ServiceName: "api", Domains: []string{"api.local"}}); err != nil {
+			t.Fatal(err)
+		}
+		event := publisher.next(t)
+		if event.Type != EventCertIssued || event.ServiceName != "api" || event.SerialNumber == "" || event.Time.IsZero() {
+			t.Errorf("Unexpected event: %+v", event)
+		}
+
+		if _, err := ca.issueServiceCertificateV2(context.Background(), CertRequestV2{ServiceName: "web", SANs: []string{"web.local"}, ValidityDays: 7}, "team-a"); err != nil {
+			t.Fatal(err)
+		}
+		event = publisher.next(t)
+		if event.ServiceName != "web" || event.Namespace != "team-a" || len(event.SANs) != 1 {
+			t.Errorf("Unexpected event: %+v", event)
+		}
+	})
+
+	t.Run("Revoked", func(t *testing.T) {
+		index, err := ReadCertIndex(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		revokedAt := time.Now().UTC()
+		index.Entries[0].Revoked = true
+		index.Entries[0].RevokedAt = &revokedAt
+		time.Sleep(10 * time.Millisecond)
+		if err := writeCertIndex(dir, index); err != nil {
+			t.Fatal(err)
+		}
+
+		ca.GetIssuedCertificates() // Picks up the index change
+		event := publisher.next(t)
+		if event.Type != EventCertRevoked || event.SerialNumber != index.Entries[0].SerialNumber {
+			t.Errorf("Unexpected event: %+v", event)
+		}
+
+		// Reloading again reports nothing new
+		if err := ca.ReloadFromDisk(); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case event := <-publisher.events:
+			t.Errorf("Unexpected event: %+v", event)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+
+	t.Run("Rotated", func(t *testing.T) {
+		otherConfig := DefaultCAConfig()
+		otherConfig.KeySize = 2048
+		otherConfig.PersistDir = t.TempDir()
+		other, err := NewCA(otherConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"ca-cert.pem", "ca-key.pem"} {
+			data, err := os.ReadFile(filepath.Join(otherConfig.PersistDir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := ca.ReloadFromDisk(); err != nil {
+			t.Fatal(err)
+		}
+		event := publisher.next(t)
+		if event.Type != EventCARotated || event.CAFingerprint == "" || event.SerialNumber != fmt.Sprintf("%x", other.Certificate().SerialNumber) {
+			t.Errorf("Unexpected event: %+v", event)
+		}
+	})
+}
+
+// fakeBroker accepts one connection and runs serve on it
+func fakeBroker(t *testing.T, serve func(r *bufio.Reader, w io.Writer)) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		serve(bufio.NewReader(conn), conn)
+	}()
+	return listener.Addr().String()
+}
+
+func TestNATSPublisher(t *testing.T) {
+	received := make(chan string, 2)
+	addr := fakeBroker(t, func(r *bufio.Reader, w io.Writer) {
+		io.WriteString(w, "INFO {\"server_id\":\"test\"}\r\n")
+		connect, _ := r.ReadString('\n')
+		received <- connect
+		r.ReadString('\n') // PING
+		io.WriteString(w, "PONG\r\n")
+
+		pub, _ := r.ReadString('\n')
+		payload, _ := r.ReadString('\n')
+		r.ReadString('\n') // PING
+		received <- pub + payload
+		io.WriteString(w, "PONG\r\n")
+	})
+
+	publisher, err := NewNATSPublisher("nats://dev:secret@"+addr, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := publisher.Publish(ctx, Event{Type: EventCertIssued, ServiceName: "api"}); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	if connect := <-received; !strings.Contains(connect, `"user":"dev"`) || !strings.Contains(connect, `"pass":"secret"`) {
+		t.Errorf("Expected credentials in CONNECT, got %q", connect)
+	}
+	message := <-received
+	if !strings.HasPrefix(message, "PUB sgl.ca.cert.issued ") {
+		t.Errorf("Unexpected PUB: %q", message)
+	}
+	var event Event
+	if err := json.Unmarshal([]byte(message[strings.Index(message, "\r\n")+2:]), &event); err != nil || event.ServiceName != "api" {
+		t.Errorf("Unexpected payload %q: %v", message, err)
+	}
+}
+
+func TestRedisPublisher(t *testing.T) {
+	received := make(chan []string, 2)
+	addr := fakeBroker(t, func(r *bufio.Reader, w io.Writer) {
+		for _, reply := range []string{"+OK\r\n", ":1\r\n"} {
+			header, _ := r.ReadString('\n')
+			var count int
+			fmt.Sscanf(header, "*%d", &count)
+			args := make([]string, 0, count)
+			for i := 0; i < count; i++ {
+				r.ReadString('\n') // $length
+				arg, _ := r.ReadString('\n')
+				args = append(args, strings.TrimRight(arg, "\r\n"))
+			}
+			received <- args
+			io.WriteString(w, reply)
+		}
+	})
+
+	publisher, err := NewRedisPublisher("redis://:secret@"+addr, "dev.ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := publisher.Publish(ctx, Event{Type: EventCARotated, CAFingerprint: "abc"}); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	if auth := <-received; strings.Join(auth, " ") != "AUTH secret" {
+		t.Errorf("Expected AUTH, got %q", auth)
+	}
+	publish := <-received
+	if len(publish) != 3 || publish[0] != "PUBLISH" || publish[1] != "dev.ca.ca.rotated" || !strings.Contains(publish[2], `"ca_fingerprint":"abc"`) {
+		t.Errorf("Unexpected PUBLISH: %q", publish)
+	}
+}
+
+func TestNewEventPublisher(t *testing.T) {
+	if p, err := NewEventPublisher("nats://localhost", ""); err != nil {
+		t.Errorf("Expected a NATS publisher, got %v", err)
+	} else if _, ok := p.(*NATSPublisher); !ok {
+		t.Errorf("Expected *NATSPublisher, got %T", p)
+	}
+	if p, err := NewEventPublisher("redis://localhost:6380", ""); err != nil {
+		t.Errorf("Expected a Redis publisher, got %v", err)
+	} else if _, ok := p.(*RedisPublisher); !ok {
+		t.Errorf("Expected *RedisPublisher, got %T", p)
+	}
+	for _, bad := range []string{"kafka://localhost", "nats://", "::"} {
+		if _, err := NewEventPublisher(bad, ""); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
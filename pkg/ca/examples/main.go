@@ -75,25 +75,14 @@ func basicExample() {
 	fmt.Printf("   Certificate length: %d bytes\n", len(certPEM))
 	fmt.Printf("   Private key length: %d bytes\n", len(keyPEM))
 
-	// Save certificates to files
-	if err := os.WriteFile("service.crt", []byte(certPEM), 0644); err != nil {
-		log.Printf("Failed to save certificate: %v", err)
-	} else {
-		fmt.Println("   Saved to: service.crt")
-	}
-
-	if err := os.WriteFile("service.key", []byte(keyPEM), 0600); err != nil {
-		log.Printf("Failed to save private key: %v", err)
-	} else {
-		fmt.Println("   Saved to: service.key")
-	}
-
-	// Save CA certificate
-	caCertPEM := certificateAuthority.CertificatePEM()
-	if err := os.WriteFile("ca.crt", caCertPEM, 0644); err != nil {
-		log.Printf("Failed to save CA certificate: %v", err)
+	// Save certificate, key (0600), and CA certificate atomically
+	response := &ca.CertResponse{Certificate: certPEM, PrivateKey: keyPEM, CACert: string(certificateAuthority.CertificatePEM())}
+	files, err := ca.WriteCertFiles(response, ".", &ca.WriteCertOptions{CertFile: "service.crt", KeyFile: "service.key"})
+	if err != nil {
+		log.Printf("Failed to save certificate files: %v", err)
 	} else {
-		fmt.Println("   CA saved to: ca.crt")
+		fmt.Printf("   Saved to: %s, %s\n", files.CertFile, files.KeyFile)
+		fmt.Printf("   CA saved to: %s\n", files.ChainFile)
 	}
 
 	// Show certificate store info
@@ -339,19 +328,18 @@ func multipleServicesExample() {
 			continue
 		}
 
-		// Save certificate files
-		certFile := fmt.Sprintf("%s.crt", service.name)
-		keyFile := fmt.Sprintf("%s.key", service.name)
-
-		if err := os.WriteFile(certFile, []byte(certPEM), 0644); err != nil {
-			log.Printf("Failed to save certificate: %v", err)
-		}
-
-		if err := os.WriteFile(keyFile, []byte(keyPEM), 0600); err != nil {
-			log.Printf("Failed to save private key: %v", err)
+		// Save certificate files; the shared CA certificate is saved below
+		files, err := ca.WriteCertFiles(&ca.CertResponse{Certificate: certPEM, PrivateKey: keyPEM}, ".", &ca.WriteCertOptions{
+			CertFile:  service.name + ".crt",
+			KeyFile:   service.name + ".key",
+			ChainFile: "-",
+		})
+		if err != nil {
+			log.Printf("Failed to save certificate files: %v", err)
+			continue
 		}
 
-		fmt.Printf("        ✅ Saved: %s, %s\n", certFile, keyFile)
+		fmt.Printf("        ✅ Saved: %s, %s\n", files.CertFile, files.KeyFile)
 	}
 
 	// Show summary
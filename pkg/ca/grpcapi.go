@@ -0,0 +1,479 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nzions/sharedgolibs/pkg/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// GRPCServiceName is the CA's gRPC service, served next to the REST API on
+// the same port
+const GRPCServiceName = "sgl.ca.v1.CertificateAuthority"
+
+// caProto describes the gRPC API. Clients in other languages can generate
+// stubs from this equivalent .proto, or use server reflection:
+//
+//	syntax = "proto3";
+//	package sgl.ca.v1;
+//
+//	service CertificateAuthority {
+//	  // Issues a certificate, like POST /cert with a V2 request
+//	  rpc IssueCertificate(CertificateRequest) returns (Certificate);
+//	  // Returns the CA bundle, like GET /ca/bundle
+//	  rpc GetCABundle(CABundleRequest) returns (CABundle);
+//	  // Issues a certificate and pushes renewals, like GET /sds
+//	  rpc WatchCertificate(CertificateRequest) returns (stream Certificate);
+//	}
+//
+//	message CertificateRequest {
+//	  string service_name = 1;
+//	  repeated string sans = 2;
+//	  int32 validity_days = 3;
+//	  string key_algorithm = 4;
+//	  string template = 5;
+//	  int32 validity_minutes = 6;
+//	}
+//
+//	message Certificate {
+//	  string name = 1;         // Service name
+//	  string version_info = 2; // Serial number, hex
+//	  string certificate = 3;  // PEM
+//	  string private_key = 4;  // PEM
+//	  string ca_cert = 5;      // PEM
+//	  int64 expires_at = 6;    // Unix seconds
+//	  int64 renew_at = 7;      // Unix seconds
+//	}
+//
+//	message CABundleRequest {}
+//
+//	message CABundle {
+//	  string pem = 1;
+//	}
+var caProto = registerCAProto()
+
+// gRPC message descriptors
+var (
+	certificateRequestDesc = caProto.Messages().ByName("CertificateRequest")
+	certificateDesc        = caProto.Messages().ByName("Certificate")
+	caBundleRequestDesc    = caProto.Messages().ByName("CABundleRequest")
+	caBundleDesc           = caProto.Messages().ByName("CABundle")
+)
+
+// registerCAProto builds caProto and registers it for server reflection
+func registerCAProto() protoreflect.FileDescriptor {
+	field := func(name string, number int32, kind descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:   kind.Enum(),
+		}
+	}
+	str := descriptorpb.FieldDescriptorProto_TYPE_STRING
+	sans := field("sans", 2, str)
+	sans.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	method := func(name, input, output string, streaming bool) *descriptorpb.MethodDescriptorProto {
+		return &descriptorpb.MethodDescriptorProto{
+			Name:            proto.String(name),
+			InputType:       proto.String(".sgl.ca.v1." + input),
+			OutputType:      proto.String(".sgl.ca.v1." + output),
+			ServerStreaming: proto.Bool(streaming),
+		}
+	}
+
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("sgl/ca/v1/ca.proto"),
+		Package: proto.String("sgl.ca.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("CertificateRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("service_name", 1, str),
+					sans,
+					field("validity_days", 3, descriptorpb.FieldDescriptorProto_TYPE_INT32),
+					field("key_algorithm", 4, str),
+					field("template", 5, str),
+					field("validity_minutes", 6, descriptorpb.FieldDescriptorProto_TYPE_INT32),
+				},
+			},
+			{
+				Name: proto.String("Certificate"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("name", 1, str),
+					field("version_info", 2, str),
+					field("certificate", 3, str),
+					field("private_key", 4, str),
+					field("ca_cert", 5, str),
+					field("expires_at", 6, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+					field("renew_at", 7, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+				},
+			},
+			{Name: proto.String("CABundleRequest")},
+			{
+				Name:  proto.String("CABundle"),
+				Field: []*descriptorpb.FieldDescriptorProto{field("pem", 1, str)},
+			},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("CertificateAuthority"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				method("IssueCertificate", "CertificateRequest", "Certificate", false),
+				method("GetCABundle", "CABundleRequest", "CABundle", false),
+				method("WatchCertificate", "CertificateRequest", "Certificate", true),
+			},
+		}},
+	}
+
+	fd, err := protodesc.NewFile(file, protoregistry.GlobalFiles)
+	if err != nil {
+		panic(fmt.Sprintf("ca: invalid gRPC descriptor: %v", err))
+	}
+	if err := protoregistry.GlobalFiles.RegisterFile(fd); err != nil {
+		panic(fmt.Sprintf("ca: registering gRPC descriptor: %v", err))
+	}
+	return fd
+}
+
+// protoString returns a string field of m
+func protoString(m protoreflect.Message, name string) string {
+	return m.Get(m.Descriptor().Fields().ByName(protoreflect.Name(name))).String()
+}
+
+// setProto sets a field of m, leaving zero values unset
+func setProto(m protoreflect.Message, name string, value protoreflect.Value) {
+	m.Set(m.Descriptor().Fields().ByName(protoreflect.Name(name)), value)
+}
+
+// certRequestToProto encodes a CertificateRequest
+func certRequestToProto(req CertRequestV2) *dynamicpb.Message {
+	m := dynamicpb.NewMessage(certificateRequestDesc)
+	setProto(m, "service_name", protoreflect.ValueOfString(req.ServiceName))
+	sans := m.Mutable(certificateRequestDesc.Fields().ByName("sans")).List()
+	for _, san := range req.SANs {
+		sans.Append(protoreflect.ValueOfString(san))
+	}
+	setProto(m, "validity_days", protoreflect.ValueOfInt32(int32(req.ValidityDays)))
+	setProto(m, "key_algorithm", protoreflect.ValueOfString(string(req.KeyAlgorithm)))
+	setProto(m, "template", protoreflect.ValueOfString(req.Template))
+	setProto(m, "validity_minutes", protoreflect.ValueOfInt32(int32(req.ValidityMinutes)))
+	return m
+}
+
+// certRequestFromProto decodes and validates a CertificateRequest
+func certRequestFromProto(m protoreflect.Message) (CertRequestV2, error) {
+	req := CertRequestV2{
+		ServiceName:     protoString(m, "service_name"),
+		ValidityDays:    int(m.Get(certificateRequestDesc.Fields().ByName("validity_days")).Int()),
+		ValidityMinutes: int(m.Get(certificateRequestDesc.Fields().ByName("validity_minutes")).Int()),
+		KeyAlgorithm:    KeyAlgorithm(protoString(m, "key_algorithm")),
+		Template:        protoString(m, "template"),
+	}
+	sans := m.Get(certificateRequestDesc.Fields().ByName("sans")).List()
+	for i := 0; i < sans.Len(); i++ {
+		req.SANs = append(req.SANs, sans.Get(i).String())
+	}
+
+	if req.ServiceName == "" {
+		return req, fmt.Errorf("%w: service_name is required", ErrInvalidCertRequest)
+	}
+	if len(req.SANs) == 0 {
+		return req, fmt.Errorf("%w: sans are required", ErrInvalidCertRequest)
+	}
+	return req, nil
+}
+
+// secretUpdateToProto encodes a Certificate
+func secretUpdateToProto(update *SecretUpdate) *dynamicpb.Message {
+	m := dynamicpb.NewMessage(certificateDesc)
+	setProto(m, "name", protoreflect.ValueOfString(update.Name))
+	setProto(m, "version_info", protoreflect.ValueOfString(update.VersionInfo))
+	setProto(m, "certificate", protoreflect.ValueOfString(update.Certificate))
+	setProto(m, "private_key", protoreflect.ValueOfString(update.PrivateKey))
+	setProto(m, "ca_cert", protoreflect.ValueOfString(update.CACert))
+	setProto(m, "expires_at", protoreflect.ValueOfInt64(update.ExpiresAt.Unix()))
+	setProto(m, "renew_at", protoreflect.ValueOfInt64(update.RenewAt.Unix()))
+	return m
+}
+
+// secretUpdateFromProto decodes a Certificate
+func secretUpdateFromProto(m protoreflect.Message) *SecretUpdate {
+	return &SecretUpdate{
+		Name:        protoString(m, "name"),
+		VersionInfo: protoString(m, "version_info"),
+		Certificate: protoString(m, "certificate"),
+		PrivateKey:  protoString(m, "private_key"),
+		CACert:      protoString(m, "ca_cert"),
+		ExpiresAt:   time.Unix(m.Get(certificateDesc.Fields().ByName("expires_at")).Int(), 0),
+		RenewAt:     time.Unix(m.Get(certificateDesc.Fields().ByName("renew_at")).Int(), 0),
+	}
+}
+
+// grpcError maps an issuance error to a gRPC status
+func grpcError(err error) error {
+	if isCertRequestError(err) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, ErrApprovalRequired) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Error(codes.Internal, "certificate generation failed")
+}
+
+// grpcNamespace returns the namespace of an authenticated gRPC call. Calls
+// are served through ServeHTTP, so the request context carries it.
+func grpcNamespace(ctx context.Context) string {
+	namespace, _ := ctx.Value(namespaceContextKey{}).(string)
+	return namespace
+}
+
+// grpcService implements the CertificateAuthority gRPC service
+type grpcService struct {
+	server *Server
+}
+
+// issue issues a certificate for a gRPC request. Calls can't wait for an
+// admin, so names needing approval are refused.
+func (g *grpcService) issue(ctx context.Context, req CertRequestV2) (*SecretUpdate, error) {
+	if names := g.server.approvalRequired(req.SANs); len(names) > 0 {
+		return nil, fmt.Errorf("%w for %s: request it with POST /cert", ErrApprovalRequired, strings.Join(names, ", "))
+	}
+	resp, err := g.server.ca.issueServiceCertificateV2(ctx, req, grpcNamespace(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return newSecretUpdate(req.ServiceName, resp)
+}
+
+func (g *grpcService) issueCertificate(ctx context.Context, in *dynamicpb.Message) (*dynamicpb.Message, error) {
+	req, err := certRequestFromProto(in)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	log.Printf("[ca] Certificate request (gRPC) for service: %s, SANs: %v", req.ServiceName, req.SANs)
+	update, err := g.issue(ctx, req)
+	if err != nil {
+		log.Printf("[ca] Failed to generate certificate for %s: %v", req.ServiceName, err)
+		return nil, grpcError(err)
+	}
+	return secretUpdateToProto(update), nil
+}
+
+func (g *grpcService) getCABundle(ctx context.Context, in *dynamicpb.Message) (*dynamicpb.Message, error) {
+	bundle := dynamicpb.NewMessage(caBundleDesc)
+	setProto(bundle, "pem", protoreflect.ValueOfString(string(g.server.ca.BundlePEM())))
+	return bundle, nil
+}
+
+// watchCertificate issues a certificate and streams its renewals, like
+// GET /sds. Failed renewals are logged and retried; the stream stays open.
+func (g *grpcService) watchCertificate(in *dynamicpb.Message, stream grpc.ServerStream) error {
+	req, err := certRequestFromProto(in)
+	if err != nil {
+		return grpcError(err)
+	}
+	ctx := stream.Context()
+	issue := func() (*SecretUpdate, error) { return g.issue(ctx, req) }
+
+	current, err := issue()
+	if err != nil {
+		log.Printf("[ca] Failed to start certificate stream for %s: %v", req.ServiceName, err)
+		return grpcError(err)
+	}
+	log.Printf("[ca] Certificate stream (gRPC) opened for %s", req.ServiceName)
+	defer log.Printf("[ca] Certificate stream (gRPC) closed for %s", req.ServiceName)
+
+	send := func(update *SecretUpdate) error { return stream.SendMsg(secretUpdateToProto(update)) }
+	if err := send(current); err != nil {
+		return err
+	}
+	g.server.streamRenewals(ctx, req.ServiceName, current, issue, streamSink{send: send})
+	return ctx.Err()
+}
+
+// unaryHandler adapts a method of grpcService to grpc.MethodDesc
+func unaryHandler(input protoreflect.MessageDescriptor, method string, call func(*grpcService, context.Context, *dynamicpb.Message) (*dynamicpb.Message, error)) grpc.MethodHandler {
+	return func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+		in := dynamicpb.NewMessage(input)
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+		handler := func(ctx context.Context, req any) (any, error) {
+			return call(srv.(*grpcService), ctx, req.(*dynamicpb.Message))
+		}
+		if interceptor == nil {
+			return handler(ctx, in)
+		}
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + GRPCServiceName + "/" + method}
+		return interceptor(ctx, in, info, handler)
+	}
+}
+
+// grpcServiceDesc registers grpcService, in place of generated code
+var grpcServiceDesc = grpc.ServiceDesc{
+	ServiceName: GRPCServiceName,
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "IssueCertificate", Handler: unaryHandler(certificateRequestDesc, "IssueCertificate", (*grpcService).issueCertificate)},
+		{MethodName: "GetCABundle", Handler: unaryHandler(caBundleRequestDesc, "GetCABundle", (*grpcService).getCABundle)},
+	},
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "WatchCertificate",
+		ServerStreams: true,
+		Handler: func(srv any, stream grpc.ServerStream) error {
+			in := dynamicpb.NewMessage(certificateRequestDesc)
+			if err := stream.RecvMsg(in); err != nil {
+				return err
+			}
+			return srv.(*grpcService).watchCertificate(in, stream)
+		},
+	}},
+	Metadata: "sgl/ca/v1/ca.proto",
+}
+
+// newGRPCServer returns the gRPC server for s, with server reflection so
+// tools like grpcurl can list and call it
+func (s *Server) newGRPCServer() *grpc.Server {
+	server := grpc.NewServer()
+	server.RegisterService(&grpcServiceDesc, &grpcService{server: s})
+	reflection.Register(server)
+	return server
+}
+
+// grpcHandler serves the gRPC API over HTTP/2, with the REST API's
+// authentication
+func (s *Server) grpcHandler() http.Handler {
+	var handler http.Handler = s.newGRPCServer()
+	if s.requiresAuth() {
+		handler = s.authenticate(handler)
+	}
+	return handler
+}
+
+// withGRPC sends gRPC requests (HTTP/2 with an application/grpc content
+// type) to grpcHandler and everything else to next
+func withGRPC(grpcHandler, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			grpcHandler.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GRPCClient calls the CA server's gRPC API. Calls carry SGL_CA_API_KEY and
+// SGL_CA_TOKEN, when set, like the REST client functions.
+//
+// Example:
+//
+//	conn, err := grpc.NewClient("ca:8090", grpc.WithTransportCredentials(insecure.NewCredentials()))
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	client := ca.NewGRPCClient(conn)
+//	cert, err := client.IssueCertificate(ctx, ca.CertRequestV2{ServiceName: "api", SANs: []string{"api"}})
+type GRPCClient struct {
+	conn grpc.ClientConnInterface
+}
+
+// NewGRPCClient returns a client for the CA server on conn
+func NewGRPCClient(conn grpc.ClientConnInterface) *GRPCClient {
+	return &GRPCClient{conn: conn}
+}
+
+// authContext adds the CA credentials from the environment to ctx
+func (c *GRPCClient) authContext(ctx context.Context) context.Context {
+	if apiKey := util.MustGetEnv("SGL_CA_API_KEY", ""); apiKey != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-api-key", apiKey)
+	}
+	if token := util.MustGetEnv("SGL_CA_TOKEN", ""); token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	}
+	return ctx
+}
+
+// IssueCertificate issues a certificate. The result's Certificate,
+// PrivateKey, and CACert are the CertResponse fields.
+func (c *GRPCClient) IssueCertificate(ctx context.Context, req CertRequestV2) (*SecretUpdate, error) {
+	out := dynamicpb.NewMessage(certificateDesc)
+	if err := c.conn.Invoke(c.authContext(ctx), "/"+GRPCServiceName+"/IssueCertificate", certRequestToProto(req), out); err != nil {
+		return nil, grpcClientError(err)
+	}
+	return secretUpdateFromProto(out), nil
+}
+
+// GetCABundle returns the CA bundle as concatenated PEM
+func (c *GRPCClient) GetCABundle(ctx context.Context) ([]byte, error) {
+	out := dynamicpb.NewMessage(caBundleDesc)
+	if err := c.conn.Invoke(c.authContext(ctx), "/"+GRPCServiceName+"/GetCABundle", dynamicpb.NewMessage(caBundleRequestDesc), out); err != nil {
+		return nil, grpcClientError(err)
+	}
+	return []byte(protoString(out, "pem")), nil
+}
+
+// WatchCertificate calls handle with a new certificate and every renewal
+// the server pushes, until ctx is done, the stream ends, or handle returns
+// an error, which WatchCertificate then returns. Unlike the package-level
+// WatchCertificate it does not reconnect.
+func (c *GRPCClient) WatchCertificate(ctx context.Context, req CertRequestV2, handle func(*SecretUpdate) error) error {
+	ctx, cancel := context.WithCancel(c.authContext(ctx))
+	defer cancel()
+
+	stream, err := c.conn.NewStream(ctx, &grpcServiceDesc.Streams[0], "/"+GRPCServiceName+"/WatchCertificate")
+	if err != nil {
+		return grpcClientError(err)
+	}
+	if err := stream.SendMsg(certRequestToProto(req)); err != nil {
+		return grpcClientError(err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		return grpcClientError(err)
+	}
+	for {
+		out := dynamicpb.NewMessage(certificateDesc)
+		if err := stream.RecvMsg(out); err != nil {
+			if errors.Is(err, io.EOF) {
+				return fmt.Errorf("%w: stream ended", ErrCAResponse)
+			}
+			return grpcClientError(err)
+		}
+		if err := handle(secretUpdateFromProto(out)); err != nil {
+			return err
+		}
+	}
+}
+
+// grpcClientError maps a gRPC status to the package's errors
+func grpcClientError(err error) error {
+	switch status.Code(err) {
+	case codes.Unauthenticated:
+		return ErrUnauthorized
+	case codes.InvalidArgument:
+		return fmt.Errorf("%w: %s", ErrInvalidCertRequest, status.Convert(err).Message())
+	case codes.Canceled, codes.DeadlineExceeded:
+		return err
+	default:
+		return fmt.Errorf("%w: %v", ErrCARequest, err)
+	}
+}
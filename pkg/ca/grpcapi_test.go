@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestGRPCAPI(t *testing.T) {
+	originalKeepAlive := secretKeepAlive
+	secretKeepAlive = 20 * time.Millisecond
+	defer func() { secretKeepAlive = originalKeepAlive }()
+
+	dir := t.TempDir()
+	authority := newTestCA(t, persistIn(dir))
+	server := &Server{ca: authority, guiAPIKey: "secret"}
+	mux := http.NewServeMux()
+	mux.Handle("/ca", server.authenticate(http.HandlerFunc(server.handleCARequest)))
+	caServer := httptest.NewServer(h2c.NewHandler(withGRPC(server.grpcHandler(), mux), &http2.Server{}))
+	defer caServer.Close()
+
+	conn, err := grpc.NewClient(strings.TrimPrefix(caServer.URL, "http://"), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := NewGRPCClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	t.Setenv("SGL_CA_TOKEN", "")
+
+	t.Setenv("SGL_CA_API_KEY", "wrong")
+	if _, err := client.GetCABundle(ctx); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized, got %v", err)
+	}
+	t.Setenv("SGL_CA_API_KEY", "secret")
+
+	bundle, err := client.GetCABundle(ctx)
+	if err != nil || string(bundle) != string(authority.BundlePEM()) {
+		t.Errorf("Unexpected bundle %q, %v", bundle, err)
+	}
+
+	cert, err := client.IssueCertificate(ctx, CertRequestV2{ServiceName: "api", SANs: []string{"api.local", "127.0.0.1"}, ValidityDays: 7})
+	if err != nil {
+		t.Fatalf("IssueCertificate failed: %v", err)
+	}
+	block, _ := pem.Decode([]byte(cert.Certificate))
+	if block == nil {
+		t.Fatalf("Expected a PEM certificate, got %q", cert.Certificate)
+	}
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf.DNSNames[0] != "api.local" || cert.ExpiresAt.Unix() != leaf.NotAfter.Unix() || cert.PrivateKey == "" || cert.CACert != string(authority.CertificatePEM()) {
+		t.Errorf("Unexpected certificate %+v", cert)
+	}
+	if leaf.NotAfter.Sub(leaf.NotBefore) > 8*24*time.Hour {
+		t.Errorf("Expected a 7 day certificate, got %s", leaf.NotAfter.Sub(leaf.NotBefore))
+	}
+
+	for _, req := range []CertRequestV2{{SANs: []string{"api.local"}}, {ServiceName: "api", SANs: []string{"bad name!"}}} {
+		if _, err := client.IssueCertificate(ctx, req); !errors.Is(err, ErrInvalidCertRequest) {
+			t.Errorf("Expected %+v to be rejected, got %v", req, err)
+		}
+	}
+
+	// The stream pushes a new certificate when the root is rotated
+	errDone := errors.New("done")
+	var updates []*SecretUpdate
+	err = client.WatchCertificate(ctx, CertRequestV2{ServiceName: "web", SANs: []string{"web.local"}}, func(update *SecretUpdate) error {
+		updates = append(updates, update)
+		if len(updates) == 2 {
+			return errDone
+		}
+		otherDir := t.TempDir()
+		newTestCA(t, persistIn(otherDir))
+		for _, name := range []string{"ca-cert.pem", "ca-key.pem"} {
+			data, err := os.ReadFile(filepath.Join(otherDir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return authority.ReloadFromDisk()
+	})
+	if !errors.Is(err, errDone) {
+		t.Fatalf("Expected the handler's error, got %v", err)
+	}
+	if updates[0].Name != "web" || updates[1].VersionInfo == updates[0].VersionInfo || updates[1].CACert != string(authority.CertificatePEM()) {
+		t.Error("Expected a new certificate under the rotated root")
+	}
+
+	// REST requests share the port
+	req, _ := http.NewRequest(http.MethodGet, caServer.URL+"/ca", nil)
+	req.Header.Set("X-API-Key", "secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != string(authority.CertificatePEM()) {
+		t.Errorf("Expected GET /ca next to gRPC, got %d", resp.StatusCode)
+	}
+}
@@ -1,11 +1,16 @@
 package ca
 
 import (
+	"crypto/x509"
 	"embed"
+	"encoding/pem"
 	"fmt"
 	"html/template"
+	"log"
 	"net/http"
+	"net/url"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -21,6 +26,14 @@ type GUIHandler struct {
 	ca        *CA
 	templates *template.Template
 	apiKey    string
+
+	// trustForwarded uses X-Forwarded-Proto/Host when building BaseURL
+	trustForwarded bool
+
+	// approvals holds the server's requests awaiting approval, and
+	// approvalDomains the names needing it (see ServerConfig.ApprovalDomains)
+	approvals       *issueQueue
+	approvalDomains []string
 }
 
 // CertificateViewModel represents a certificate for the GUI
@@ -28,6 +41,32 @@ type CertificateViewModel struct {
 	*IssuedCert
 	IsExpired      bool
 	IsExpiringSoon bool
+	IsStale        bool // Not fetched or used for the CA's StaleCertAge
+	SANs           []SANEntry
+}
+
+// SANEntry is a single Subject Alternative Name with its type for display
+type SANEntry struct {
+	Type  SANType
+	Value string
+}
+
+// sanEntries classifies a certificate's SANs for display
+func sanEntries(sans []string) []SANEntry {
+	entries := make([]SANEntry, 0, len(sans))
+	for _, san := range sans {
+		entries = append(entries, SANEntry{Type: ClassifySAN(san), Value: san})
+	}
+	return entries
+}
+
+// sanHTML renders a SAN as code, tagging non-DNS types
+func sanHTML(entry SANEntry) string {
+	value := template.HTMLEscapeString(entry.Value)
+	if entry.Type == SANTypeDNS {
+		return fmt.Sprintf(`<code>%s</code>`, value)
+	}
+	return fmt.Sprintf(`<code>%s</code> <span class="badge">%s</span>`, value, strings.ToUpper(string(entry.Type)))
 }
 
 // DashboardData holds data for the dashboard template
@@ -46,6 +85,8 @@ type DashboardData struct {
 	AllCerts             []CertificateViewModel
 	RequireAPIKey        bool
 	BaseURL              string
+	Namespace            string // Namespace of a scoped API key; empty for admin
+	Trust                BrowserTrust
 }
 
 // CertificatesData holds data for the certificates template
@@ -56,24 +97,46 @@ type CertificatesData struct {
 	Certificates  []CertificateViewModel
 	RequireAPIKey bool
 	BaseURL       string
+	Namespace     string // Namespace of a scoped API key; empty for admin
 }
 
 // GenerateData holds data for the generate template
 type GenerateData struct {
+	Title               string
+	Page                string
+	Version             string
+	RequireAPIKey       bool
+	BaseURL             string
+	Namespace           string         // Namespace of a scoped API key; empty for admin
+	KeyAlgorithms       []KeyAlgorithm // Choices for the key algorithm dropdown
+	DefaultKeyAlgorithm KeyAlgorithm   // The CA's leaf key algorithm
+	ValidityDays        []int          // Choices for the validity dropdown
+	DefaultValidityDays int
+	MaxValidityDays     int
+	Templates           []string // Certificate template names
+}
+
+// APIData holds data for the API documentation template
+type APIData struct {
 	Title         string
 	Page          string
 	Version       string
 	RequireAPIKey bool
 	BaseURL       string
+	Namespace     string // Namespace of a scoped API key; empty for admin
 }
 
-// APIData holds data for the API documentation template
-type APIData struct {
+// VerifyData holds data for the chain verification template
+type VerifyData struct {
 	Title         string
 	Page          string
 	Version       string
 	RequireAPIKey bool
 	BaseURL       string
+	Namespace     string                 // Namespace of a scoped API key; empty for admin
+	Certificates  []CertificateViewModel // Issued certificates to pick from
+	Serial        string                 // Preselected certificate, from ?serial=
+	Result        *ChainVerification     // Verification of the preselected certificate
 }
 
 // NewGUIHandler creates a new GUI handler
@@ -115,9 +178,24 @@ func (g *GUIHandler) RequireAPIKey(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// visibleCertificates returns the issued certificates the request may see
+func (g *GUIHandler) visibleCertificates(r *http.Request) []*IssuedCert {
+	return visibleCertificates(r, g.ca.GetIssuedCertificates())
+}
+
+// visibleCertificate returns the certificate with the given serial, or nil
+// if it does not exist or belongs to another namespace
+func (g *GUIHandler) visibleCertificate(r *http.Request, serialNumber string) *IssuedCert {
+	cert, ok := g.ca.GetCertificateBySerial(serialNumber)
+	if !ok || !visibleTo(r, cert) {
+		return nil
+	}
+	return cert
+}
+
 // HandleDashboard renders the dashboard page
 func (g *GUIHandler) HandleDashboard(w http.ResponseWriter, r *http.Request) {
-	certs := g.ca.GetIssuedCertificates()
+	certs := g.visibleCertificates(r)
 	allCerts := g.prepareCertificates(certs)
 	recentCerts := allCerts
 
@@ -129,12 +207,9 @@ func (g *GUIHandler) HandleDashboard(w http.ResponseWriter, r *http.Request) {
 	caInfo := g.ca.GetCAInfo()
 	caCert := g.ca.Certificate()
 
-	// Determine base URL from request
-	scheme := "http"
-	if r.TLS != nil {
-		scheme = "https"
-	}
-	baseURL := fmt.Sprintf("%s://%s", scheme, r.Host)
+	// Determine base URL from request, as seen through any reverse proxy
+	baseURL := requestBaseURL(r, g.trustForwarded)
+	namespace, _ := requestNamespace(r)
 
 	data := DashboardData{
 		Title:                "Dashboard",
@@ -151,6 +226,8 @@ func (g *GUIHandler) HandleDashboard(w http.ResponseWriter, r *http.Request) {
 		AllCerts:             allCerts,
 		RequireAPIKey:        g.apiKey != "",
 		BaseURL:              baseURL,
+		Namespace:            namespace,
+		Trust:                browserTrust(caCert, r, g.trustForwarded),
 	}
 
 	// Add additional CA info from the CA's GetCAInfo method
@@ -171,15 +248,12 @@ func (g *GUIHandler) HandleDashboard(w http.ResponseWriter, r *http.Request) {
 
 // HandleCertificates renders the certificates list page
 func (g *GUIHandler) HandleCertificates(w http.ResponseWriter, r *http.Request) {
-	certs := g.ca.GetIssuedCertificates()
+	certs := g.visibleCertificates(r)
 	certificates := g.prepareCertificates(certs)
 
-	// Determine base URL from request
-	scheme := "http"
-	if r.TLS != nil {
-		scheme = "https"
-	}
-	baseURL := fmt.Sprintf("%s://%s", scheme, r.Host)
+	// Determine base URL from request, as seen through any reverse proxy
+	baseURL := requestBaseURL(r, g.trustForwarded)
+	namespace, _ := requestNamespace(r)
 
 	data := CertificatesData{
 		Title:         "Certificates",
@@ -188,6 +262,7 @@ func (g *GUIHandler) HandleCertificates(w http.ResponseWriter, r *http.Request)
 		Certificates:  certificates,
 		RequireAPIKey: g.apiKey != "",
 		BaseURL:       baseURL,
+		Namespace:     namespace,
 	}
 
 	if err := g.templates.ExecuteTemplate(w, "base.html", data); err != nil {
@@ -199,19 +274,23 @@ func (g *GUIHandler) HandleCertificates(w http.ResponseWriter, r *http.Request)
 // HandleGenerate renders the generate certificate page or processes the form
 func (g *GUIHandler) HandleGenerate(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
-		// Determine base URL from request
-		scheme := "http"
-		if r.TLS != nil {
-			scheme = "https"
-		}
-		baseURL := fmt.Sprintf("%s://%s", scheme, r.Host)
+		// Determine base URL from request, as seen through any reverse proxy
+		baseURL := requestBaseURL(r, g.trustForwarded)
+		namespace, _ := requestNamespace(r)
 
 		data := GenerateData{
-			Title:         "Generate Certificate",
-			Page:          "generate",
-			Version:       Version,
-			RequireAPIKey: g.apiKey != "",
-			BaseURL:       baseURL,
+			Title:               "Generate Certificate",
+			Page:                "generate",
+			Version:             Version,
+			RequireAPIKey:       g.apiKey != "",
+			BaseURL:             baseURL,
+			Namespace:           namespace,
+			KeyAlgorithms:       []KeyAlgorithm{KeyAlgorithmRSA2048, KeyAlgorithmRSA4096, KeyAlgorithmECDSAP256, KeyAlgorithmECDSAP384},
+			DefaultKeyAlgorithm: g.ca.LeafKeyAlgorithm(),
+			ValidityDays:        []int{7, 30, 90, 365, MaxLeafValidityDays},
+			DefaultValidityDays: int(DefaultLeafValidity / (24 * time.Hour)),
+			MaxValidityDays:     MaxLeafValidityDays,
+			Templates:           g.ca.CertTemplateNames(),
 		}
 
 		if err := g.templates.ExecuteTemplate(w, "base.html", data); err != nil {
@@ -236,12 +315,9 @@ func (g *GUIHandler) HandleAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Determine base URL from request
-	scheme := "http"
-	if r.TLS != nil {
-		scheme = "https"
-	}
-	baseURL := fmt.Sprintf("%s://%s", scheme, r.Host)
+	// Determine base URL from request, as seen through any reverse proxy
+	baseURL := requestBaseURL(r, g.trustForwarded)
+	namespace, _ := requestNamespace(r)
 
 	data := APIData{
 		Title:         "API Documentation",
@@ -249,6 +325,7 @@ func (g *GUIHandler) HandleAPI(w http.ResponseWriter, r *http.Request) {
 		Version:       Version,
 		RequireAPIKey: g.apiKey != "",
 		BaseURL:       baseURL,
+		Namespace:     namespace,
 	}
 
 	if err := g.templates.ExecuteTemplate(w, "base.html", data); err != nil {
@@ -257,50 +334,245 @@ func (g *GUIHandler) HandleAPI(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleGenerateForm processes the certificate generation form
-func (g *GUIHandler) handleGenerateForm(w http.ResponseWriter, r *http.Request) {
-	serviceName := strings.TrimSpace(r.FormValue("service_name"))
-	serviceIP := strings.TrimSpace(r.FormValue("service_ip"))
-	domainsText := strings.TrimSpace(r.FormValue("domains"))
+// HandleVerify renders the chain verification page, or verifies the
+// certificate posted by its form: a pasted PEM certificate or the serial of
+// an issued one. GET ?serial= preselects and verifies an issued certificate.
+func (g *GUIHandler) HandleVerify(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		namespace, _ := requestNamespace(r)
+		data := VerifyData{
+			Title:         "Verify Certificate",
+			Page:          "verify",
+			Version:       Version,
+			RequireAPIKey: g.apiKey != "",
+			BaseURL:       requestBaseURL(r, g.trustForwarded),
+			Namespace:     namespace,
+			Certificates:  g.prepareCertificates(g.visibleCertificates(r)),
+			Serial:        strings.TrimSpace(r.URL.Query().Get("serial")),
+		}
+		if data.Serial != "" {
+			// Errors are shown when the form is submitted
+			data.Result, _ = g.verifyForm(r, "", data.Serial)
+		}
+		if err := g.templates.ExecuteTemplate(w, "base.html", data); err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
 
-	if serviceName == "" || serviceIP == "" || domainsText == "" {
-		g.writeHTMLResponse(w, `
+	case http.MethodPost:
+		result, err := g.verifyForm(r, r.FormValue("certificate"), strings.TrimSpace(r.FormValue("serial")))
+		if err != nil {
+			g.writeHTMLResponse(w, fmt.Sprintf(`
 			<div class="alert alert-error">
-				<strong>Error:</strong> All fields are required.
+				<strong>Error:</strong> %s
 			</div>
-		`)
-		return
+		`, template.HTMLEscapeString(err.Error())))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		if err := g.templates.ExecuteTemplate(w, "verify-result", result); err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
+}
 
-	// Parse domains from textarea (one per line)
-	domains := []string{}
-	for _, domain := range strings.Split(domainsText, "\n") {
-		domain = strings.TrimSpace(domain)
-		if domain != "" {
-			domains = append(domains, domain)
+// verifyForm verifies a pasted certificate, or else the issued certificate
+// with the given serial. The CA's record of a pasted certificate is only
+// shown if the request may see it.
+func (g *GUIHandler) verifyForm(r *http.Request, certPEM, serial string) (*ChainVerification, error) {
+	if strings.TrimSpace(certPEM) == "" {
+		if serial == "" {
+			return nil, fmt.Errorf("paste a PEM certificate or pick an issued one")
+		}
+		issued := g.visibleCertificate(r, serial)
+		if issued == nil {
+			return nil, fmt.Errorf("certificate %s not found", serial)
 		}
+		certPEM = issued.Certificate
 	}
 
-	if len(domains) == 0 {
-		g.writeHTMLResponse(w, `
+	result, err := g.ca.VerifyChain([]byte(certPEM))
+	if err != nil {
+		return nil, err
+	}
+	if result.Issued != nil && !visibleTo(r, result.Issued) {
+		result.Issued = nil
+	}
+	return result, nil
+}
+
+// certRequestFromForm builds a V2 certificate request from the generate form:
+// service_name, sans (one per line), and the optional validity_days,
+// key_algorithm, and template
+func certRequestFromForm(r *http.Request) (CertRequestV2, error) {
+	req := CertRequestV2{
+		ServiceName:  strings.TrimSpace(r.FormValue("service_name")),
+		KeyAlgorithm: KeyAlgorithm(strings.TrimSpace(r.FormValue("key_algorithm"))),
+		Template:     strings.TrimSpace(r.FormValue("template")),
+	}
+
+	for _, san := range strings.Split(r.FormValue("sans"), "\n") {
+		if san = strings.TrimSpace(san); san != "" {
+			req.SANs = append(req.SANs, san)
+		}
+	}
+
+	if days := strings.TrimSpace(r.FormValue("validity_days")); days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return req, fmt.Errorf("%w: validity_days must be a number of days", ErrInvalidCertRequest)
+		}
+		req.ValidityDays = n
+	}
+
+	if _, err := req.options(); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+// commonNameReason explains why selectCommonName picked the CN it did
+func commonNameReason(sanSet *SANSet) string {
+	switch {
+	case len(sanSet.DNSNames) > 0:
+		return "FIRST DNS NAME"
+	case len(sanSet.IPAddresses) > 0:
+		return "FIRST IP ADDRESS (NO DNS NAMES)"
+	default:
+		return "SERVICE NAME (ONLY URI/EMAIL SANS)"
+	}
+}
+
+// parseCertificatePEM parses a single PEM-encoded certificate
+func parseCertificatePEM(certPEM string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode certificate PEM")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// HandleGeneratePreview renders the CN selection and classified SANs for the
+// generate form before anything is issued
+func (g *GUIHandler) HandleGeneratePreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req, err := certRequestFromForm(r)
+	if err != nil {
+		g.writeHTMLResponse(w, fmt.Sprintf(`<div class="alert alert-error">%s</div>`, template.HTMLEscapeString(err.Error())))
+		return
+	}
+	if len(req.SANs) == 0 {
+		g.writeHTMLResponse(w, `<div class="alert">ENTER AT LEAST ONE SAN TO PREVIEW THE COMMON NAME</div>`)
+		return
+	}
+
+	sanSet, err := ParseSANs(req.SANs)
+	if err != nil {
+		g.writeHTMLResponse(w, fmt.Sprintf(`<div class="alert alert-error">%s</div>`, template.HTMLEscapeString(err.Error())))
+		return
+	}
+
+	serviceName := req.ServiceName
+	if serviceName == "" {
+		serviceName = "(service name)"
+	}
+
+	opts, err := g.ca.requestOptions(req)
+	if err != nil {
+		g.writeHTMLResponse(w, fmt.Sprintf(`<div class="alert alert-error">%s</div>`, template.HTMLEscapeString(err.Error())))
+		return
+	}
+	validity := opts.validity
+	if validity <= 0 {
+		validity = DefaultLeafValidity
+	}
+	keyAlgorithm := opts.keyAlgorithm
+	if keyAlgorithm == "" {
+		keyAlgorithm = g.ca.LeafKeyAlgorithm()
+	}
+	_, extKeyUsage := opts.template.keyUsages(keyAlgorithm)
+
+	html := fmt.Sprintf(`
+		<dl class="cert-details">
+			<dt>COMMON NAME</dt><dd><code>%s</code> <span class="badge">%s</span></dd>
+			<dt>EXPIRES</dt><dd>%s</dd>
+			<dt>KEY</dt><dd>%s</dd>
+			<dt>USAGE</dt><dd>%s</dd>
+			<dt>SANS</dt><dd>`,
+		template.HTMLEscapeString(selectCommonName(serviceName, sanSet)),
+		commonNameReason(sanSet),
+		time.Now().Add(validity).Format("2006-01-02"),
+		template.HTMLEscapeString(string(keyAlgorithm)),
+		strings.Join(extKeyUsageNames(extKeyUsage), ", "),
+	)
+	for _, entry := range sanEntries(req.SANs) {
+		html += fmt.Sprintf(`<div>%s</div>`, sanHTML(entry))
+	}
+	html += `</dd></dl>`
+
+	g.writeHTMLResponse(w, html)
+}
+
+// handleGenerateForm processes the certificate generation form
+func (g *GUIHandler) handleGenerateForm(w http.ResponseWriter, r *http.Request) {
+	req, err := certRequestFromForm(r)
+	if err == nil && (req.ServiceName == "" || len(req.SANs) == 0) {
+		err = fmt.Errorf("service name and at least one SAN are required")
+	}
+	if err != nil {
+		g.writeHTMLResponse(w, fmt.Sprintf(`
 			<div class="alert alert-error">
-				<strong>Error:</strong> At least one domain is required.
+				<strong>Error:</strong> %s
 			</div>
-		`)
+		`, template.HTMLEscapeString(err.Error())))
+		return
+	}
+
+	// Admins approve their own requests; namespace users must ask for one
+	namespace, scoped := requestNamespace(r)
+	if names := approvalNames(g.approvalDomains, req.SANs); scoped && len(names) > 0 {
+		g.writeHTMLResponse(w, fmt.Sprintf(`
+			<div class="alert alert-error">
+				<strong>Error:</strong> %s requires approval: request it with POST /cert
+			</div>
+		`, template.HTMLEscapeString(strings.Join(names, ", "))))
 		return
 	}
 
 	// Generate certificate
-	certPEM, keyPEM, err := g.ca.GenerateCertificate(serviceName, serviceIP, domains)
+	resp, err := g.ca.issueServiceCertificateV2(r.Context(), req, namespace)
+	if err != nil {
+		g.writeHTMLResponse(w, fmt.Sprintf(`
+			<div class="alert alert-error">
+				<strong>Error:</strong> Failed to generate certificate: %s
+			</div>
+		`, template.HTMLEscapeString(err.Error())))
+		return
+	}
+
+	cert, err := parseCertificatePEM(resp.Certificate)
 	if err != nil {
 		g.writeHTMLResponse(w, fmt.Sprintf(`
 			<div class="alert alert-error">
-				<strong>Error:</strong> Failed to generate certificate: %v
+				<strong>Error:</strong> %s
 			</div>
-		`, err))
+		`, template.HTMLEscapeString(err.Error())))
 		return
 	}
 
+	sansHTML := ""
+	for _, entry := range sanEntries(req.SANs) {
+		sansHTML += fmt.Sprintf(`<div>%s</div>`, sanHTML(entry))
+	}
+	serviceName := template.HTMLEscapeString(req.ServiceName)
+
 	// Success response with certificate details
 	html := fmt.Sprintf(`
 		<div class="alert alert-success">
@@ -312,10 +584,11 @@ func (g *GUIHandler) handleGenerateForm(w http.ResponseWriter, r *http.Request)
 			<table class="table">
 				<tbody>
 					<tr><td><strong>Service Name</strong></td><td>%s</td></tr>
-					<tr><td><strong>Service IP</strong></td><td>%s</td></tr>
-					<tr><td><strong>Domains</strong></td><td>%s</td></tr>
-					<tr><td><strong>Certificate Size</strong></td><td>%d bytes</td></tr>
-					<tr><td><strong>Private Key Size</strong></td><td>%d bytes</td></tr>
+					<tr><td><strong>Common Name</strong></td><td><code>%s</code></td></tr>
+					<tr><td><strong>SANs</strong></td><td>%s</td></tr>
+					<tr><td><strong>Serial</strong></td><td><code>%x</code></td></tr>
+					<tr><td><strong>Expires</strong></td><td>%s</td></tr>
+					<tr><td><strong>Key Algorithm</strong></td><td>%s</td></tr>
 				</tbody>
 			</table>
 		</div>
@@ -342,10 +615,14 @@ func (g *GUIHandler) handleGenerateForm(w http.ResponseWriter, r *http.Request)
 		</div>
 	`,
 		serviceName,
-		serviceName, serviceIP, strings.Join(domains, ", "),
-		len(certPEM), len(keyPEM),
-		certPEM, serviceName,
-		keyPEM, serviceName,
+		serviceName,
+		template.HTMLEscapeString(cert.Subject.CommonName),
+		sansHTML,
+		cert.SerialNumber,
+		cert.NotAfter.Format("2006-01-02 15:04"),
+		cert.PublicKeyAlgorithm,
+		resp.Certificate, serviceName,
+		resp.PrivateKey, serviceName,
 	)
 
 	g.writeHTMLResponse(w, html)
@@ -362,14 +639,7 @@ func (g *GUIHandler) HandleCertDetails(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	certs := g.ca.GetIssuedCertificates()
-	var foundCert *IssuedCert
-	for _, cert := range certs {
-		if cert.SerialNumber == serialNumber {
-			foundCert = cert
-			break
-		}
-	}
+	foundCert := g.visibleCertificate(r, serialNumber)
 
 	if foundCert == nil {
 		g.writeHTMLResponse(w, `
@@ -413,6 +683,8 @@ func (g *GUIHandler) prepareCertificate(cert *IssuedCert) CertificateViewModel {
 		IssuedCert:     cert,
 		IsExpired:      now.After(cert.ExpiresAt),
 		IsExpiringSoon: !now.After(cert.ExpiresAt) && cert.ExpiresAt.Sub(now) < expiringThreshold,
+		IsStale:        cert.IsStale(now, g.ca.StaleCertAge()),
+		SANs:           sanEntries(cert.Domains),
 	}
 }
 
@@ -476,6 +748,8 @@ func (g *GUIHandler) HandleLegacyWebUI(w http.ResponseWriter, r *http.Request) {
                 <li><code>GET /ca</code> - Download CA certificate</li>
                 <li><code>POST /cert</code> - Request service certificate</li>
                 <li><code>GET /health</code> - Health check</li>
+                <li><code>GET /healthz</code> - Liveness probe</li>
+                <li><code>GET /readyz</code> - Readiness probe</li>
             </ul>
         </div>
     </div>
@@ -572,7 +846,7 @@ func (g *GUIHandler) HandleLegacyCertsList(w http.ResponseWriter, r *http.Reques
 	}
 
 	// Get certificates and prepare for display
-	certList := g.ca.GetIssuedCertificates()
+	certList := g.visibleCertificates(r)
 
 	// Sort by issued date (newest first)
 	sort.Slice(certList, func(i, j int) bool {
@@ -650,8 +924,8 @@ func (g *GUIHandler) HandleLegacyGenerateForm(w http.ResponseWriter, r *http.Req
             
             <div class="form-group">
                 <label for="domains">Domain Names (SANs)</label>
-                <textarea id="domains" name="domains" required placeholder="example.com&#10;api.example.com&#10;192.168.1.100&#10;localhost"></textarea>
-                <div class="help">One domain/IP per line. Supports hostnames and IP addresses.</div>
+                <textarea id="domains" name="domains" required placeholder="example.com&#10;api.example.com&#10;192.168.1.100&#10;localhost&#10;spiffe://cluster.local/ns/default/sa/app"></textarea>
+                <div class="help">One SAN per line. Supports hostnames, IP addresses, URIs (e.g. SPIFFE IDs), and email addresses.</div>
             </div>
             
             <button type="submit" class="button">🔐 Generate Certificate</button>
@@ -806,6 +1080,10 @@ func (g *GUIHandler) HandleDownloadCAKey(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	if _, scoped := requestNamespace(r); scoped {
+		http.Error(w, "Forbidden: the CA key requires the admin API key", http.StatusForbidden)
+		return
+	}
 
 	caKeyPEM := g.ca.PrivateKeyPEM()
 
@@ -830,14 +1108,7 @@ func (g *GUIHandler) HandleDownloadCert(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	certs := g.ca.GetIssuedCertificates()
-	var foundCert *IssuedCert
-	for _, cert := range certs {
-		if cert.SerialNumber == serialNumber {
-			foundCert = cert
-			break
-		}
-	}
+	foundCert := g.visibleCertificate(r, serialNumber)
 
 	if foundCert == nil {
 		http.Error(w, "Certificate not found", http.StatusNotFound)
@@ -848,6 +1119,7 @@ func (g *GUIHandler) HandleDownloadCert(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Content-Type", "application/x-pem-file")
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
 	w.Write([]byte(foundCert.Certificate))
+	g.recordFetch(foundCert)
 }
 
 // HandleDownloadCertKey handles individual certificate private key download requests
@@ -866,14 +1138,7 @@ func (g *GUIHandler) HandleDownloadCertKey(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	certs := g.ca.GetIssuedCertificates()
-	var foundCert *IssuedCert
-	for _, cert := range certs {
-		if cert.SerialNumber == serialNumber {
-			foundCert = cert
-			break
-		}
-	}
+	foundCert := g.visibleCertificate(r, serialNumber)
 
 	if foundCert == nil {
 		http.Error(w, "Certificate not found", http.StatusNotFound)
@@ -884,15 +1149,94 @@ func (g *GUIHandler) HandleDownloadCertKey(w http.ResponseWriter, r *http.Reques
 	w.Header().Set("Content-Type", "application/x-pem-file")
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
 	w.Write([]byte(foundCert.PrivateKey))
+	g.recordFetch(foundCert)
+}
+
+// recordFetch records a certificate download for usage tracking
+func (g *GUIHandler) recordFetch(cert *IssuedCert) {
+	if err := g.ca.RecordCertificateFetched(cert.SerialNumber); err != nil {
+		log.Printf("[ca] Failed to record download of %s: %v", cert.SerialNumber, err)
+	}
 }
 
 // HandleCertsTable handles HTMX requests for the certificates table
 func (g *GUIHandler) HandleCertsTable(w http.ResponseWriter, r *http.Request) {
-	certs := g.ca.GetIssuedCertificates()
+	certs := g.visibleCertificates(r)
 	certificates := g.prepareCertificates(certs)
 
-	// Generate table HTML
-	html := `<table class="table" hx-get="/ui/certs-table" hx-trigger="every 30s" hx-swap="outerHTML">
+	g.writeHTMLResponse(w, certsTableHTML(certificates, "/ui/certs-table", ""))
+}
+
+// maxSearchResults caps the rows returned by /ui/certs/search
+const maxSearchResults = 100
+
+// HandleCertsSearch handles HTMX requests for certificates matching ?q= by
+// service name, SAN, or serial, returning at most maxSearchResults rows
+func (g *GUIHandler) HandleCertsSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	matches := searchCertificates(g.prepareCertificates(g.visibleCertificates(r)), query)
+
+	caption := fmt.Sprintf("%d MATCH", len(matches))
+	if len(matches) != 1 {
+		caption += "ES"
+	}
+	if len(matches) > maxSearchResults {
+		caption = fmt.Sprintf("SHOWING %d OF %d MATCHES - REFINE SEARCH", maxSearchResults, len(matches))
+		matches = matches[:maxSearchResults]
+	}
+
+	refreshURL := "/ui/certs/search?q=" + url.QueryEscape(query)
+	g.writeHTMLResponse(w, certsTableHTML(matches, refreshURL, caption))
+}
+
+// searchCertificates returns the certificates whose service name, SANs, or
+// serial contain query, ignoring case. Serials also match when written with
+// colons or a 0x prefix.
+func searchCertificates(certs []CertificateViewModel, query string) []CertificateViewModel {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return certs
+	}
+	serial := strings.TrimPrefix(strings.ReplaceAll(query, ":", ""), "0x")
+
+	var matches []CertificateViewModel
+	for _, cert := range certs {
+		if certMatches(cert, query, serial) {
+			matches = append(matches, cert)
+		}
+	}
+	return matches
+}
+
+// certMatches reports whether a certificate matches a lowercased search query
+func certMatches(cert CertificateViewModel, query, serial string) bool {
+	if strings.Contains(strings.ToLower(cert.ServiceName), query) {
+		return true
+	}
+	if serial != "" && strings.Contains(strings.ToLower(cert.SerialNumber), serial) {
+		return true
+	}
+	for _, domain := range cert.Domains {
+		if strings.Contains(strings.ToLower(domain), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// certsTableHTML renders the certificates table, refreshed every 30s from
+// refreshURL, with an optional caption
+func certsTableHTML(certificates []CertificateViewModel, refreshURL, caption string) string {
+	html := fmt.Sprintf(`<table id="certs-table" class="table" hx-get="%s" hx-trigger="every 30s" hx-swap="outerHTML">`, template.HTMLEscapeString(refreshURL))
+	if caption != "" {
+		html += fmt.Sprintf(`<caption>%s</caption>`, template.HTMLEscapeString(caption))
+	}
+	html += `
 		<thead>
 			<tr>
 				<th>SERVICE</th>
@@ -907,6 +1251,11 @@ func (g *GUIHandler) HandleCertsTable(w http.ResponseWriter, r *http.Request) {
 		</thead>
 		<tbody>`
 
+	if len(certificates) == 0 {
+		html += `
+			<tr><td colspan="8">NO MATCHING CERTIFICATES</td></tr>`
+	}
+
 	for _, cert := range certificates {
 		statusClass := "badge-success"
 		statusText := "VALID"
@@ -918,26 +1267,46 @@ func (g *GUIHandler) HandleCertsTable(w http.ResponseWriter, r *http.Request) {
 			statusText = "EXPIRING"
 		}
 
+		usageHTML := ""
+		if cert.IsStale {
+			usageHTML += ` <span class="badge badge-warning" title="Not fetched or used recently">STALE</span>`
+		}
+		if cert.NeverFetched() {
+			usageHTML += ` <span class="badge" title="Never downloaded or reported in use">NEVER FETCHED</span>`
+		}
+
 		domainsHTML := ""
-		if len(cert.Domains) > 1 {
-			domainsHTML = fmt.Sprintf(`<details><summary>%d domains</summary>`, len(cert.Domains))
-			for _, domain := range cert.Domains {
-				domainsHTML += fmt.Sprintf(`<div><code>%s</code></div>`, domain)
+		if len(cert.SANs) > 1 {
+			domainsHTML = fmt.Sprintf(`<details><summary>%d SANs</summary>`, len(cert.SANs))
+			for _, san := range cert.SANs {
+				domainsHTML += fmt.Sprintf(`<div>%s</div>`, sanHTML(san))
 			}
 			domainsHTML += `</details>`
-		} else if len(cert.Domains) > 0 {
-			domainsHTML = fmt.Sprintf(`<code>%s</code>`, cert.Domains[0])
+		} else if len(cert.SANs) > 0 {
+			domainsHTML = sanHTML(cert.SANs[0])
 		}
 
+		commonName := ""
+		if len(cert.Domains) > 0 {
+			commonName = cert.Domains[0]
+		}
+		serviceName := template.HTMLEscapeString(cert.ServiceName)
+		namespaceHTML := ""
+		if cert.Namespace != "" {
+			namespaceHTML = fmt.Sprintf(` <span class="badge">%s</span>`, template.HTMLEscapeString(cert.Namespace))
+		}
+		serial := template.HTMLEscapeString(cert.SerialNumber)
+		fileName := template.HTMLEscapeString(template.JSEscapeString(cert.ServiceName))
+
 		html += fmt.Sprintf(`
 			<tr>
-				<td><strong>%s</strong></td>
+				<td><strong>%s</strong>%s</td>
 				<td><code>%s</code></td>
 				<td>%s</td>
 				<td><code>%s</code></td>
 				<td>%s</td>
 				<td>%s</td>
-				<td><span class="badge %s">%s</span></td>
+				<td><span class="badge %s">%s</span>%s</td>
 				<td>
 					<div class="download-links">
 						<a href="/cert/%s" class="btn" onclick="downloadFile('/cert/%s', '%s.crt')" title="Download certificate">CERT</a>
@@ -945,22 +1314,20 @@ func (g *GUIHandler) HandleCertsTable(w http.ResponseWriter, r *http.Request) {
 					</div>
 				</td>
 			</tr>`,
-			cert.ServiceName,
-			cert.Domains[0],
+			serviceName, namespaceHTML,
+			template.HTMLEscapeString(commonName),
 			domainsHTML,
-			cert.SerialNumber,
+			serial,
 			cert.IssuedAt.Format("01-02 15:04"),
 			cert.ExpiresAt.Format("01-02 15:04"),
-			statusClass, statusText,
-			cert.SerialNumber, cert.SerialNumber, cert.ServiceName,
-			cert.SerialNumber, cert.SerialNumber, cert.ServiceName,
+			statusClass, statusText, usageHTML,
+			serial, serial, fileName,
+			serial, serial, fileName,
 		)
 	}
 
 	html += `</tbody></table>`
-
-	w.Header().Set("Content-Type", "text/html")
-	w.Write([]byte(html))
+	return html
 }
 
 // HandleLogStream handles Server-Sent Events for live log streaming
@@ -987,7 +1354,7 @@ func (g *GUIHandler) HandleLogStream(w http.ResponseWriter, r *http.Request) {
 	for {
 		select {
 		case <-ticker.C:
-			certs := g.ca.GetIssuedCertificates()
+			certs := g.visibleCertificates(r)
 			message := fmt.Sprintf("System status: %d certificates active", len(certs))
 			fmt.Fprintf(w, "data: %s\n\n", message)
 			if f, ok := w.(http.Flusher); ok {
@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchCertificates(t *testing.T) {
+	certs := []CertificateViewModel{
+		{IssuedCert: &IssuedCert{ServiceName: "api", Domains: []string{"api.internal", "10.0.0.5"}, SerialNumber: "1a2b3c"}},
+		{IssuedCert: &IssuedCert{ServiceName: "Database", Domains: []string{"db.internal"}, SerialNumber: "ff00ee"}},
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"", []string{"api", "Database"}},
+		{"data", []string{"Database"}},
+		{"API.INTERNAL", []string{"api"}},
+		{"10.0.0", []string{"api"}},
+		{"1A:2B", []string{"api"}},
+		{"0xff00", []string{"Database"}},
+		{"internal", []string{"api", "Database"}},
+		{"nothing", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			var names []string
+			for _, cert := range searchCertificates(certs, tt.query) {
+				names = append(names, cert.ServiceName)
+			}
+			if fmt.Sprint(names) != fmt.Sprint(tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, names)
+			}
+		})
+	}
+}
+
+func TestHandleCertsSearch(t *testing.T) {
+	ca, err := NewCA(nil)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	gui, err := NewGUIHandler(ca, "")
+	if err != nil {
+		t.Fatalf("Failed to create GUI handler: %v", err)
+	}
+
+	for i := 0; i < maxSearchResults+5; i++ {
+		name := fmt.Sprintf("svc-%d", i)
+		if _, _, err := ca.GenerateCertificate(name, "", []string{name + ".internal"}); err != nil {
+			t.Fatalf("Failed to generate certificate: %v", err)
+		}
+	}
+	if _, _, err := ca.GenerateCertificate("<script>", "", []string{"xss.internal"}); err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+
+	search := func(query string) string {
+		rec := httptest.NewRecorder()
+		gui.HandleCertsSearch(rec, httptest.NewRequest(http.MethodGet, "/ui/certs/search?q="+query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	body := search("svc-42")
+	if !strings.Contains(body, "svc-42.internal") || strings.Contains(body, "svc-41") || !strings.Contains(body, "1 MATCH<") {
+		t.Errorf("Expected only svc-42, got %s", body)
+	}
+	if !strings.Contains(body, `id="certs-table"`) || !strings.Contains(body, `hx-get="/ui/certs/search?q=svc-42"`) {
+		t.Error("Expected a table that refreshes the same search")
+	}
+
+	body = search("svc")
+	if rows := strings.Count(body, "<tr>") - 1; rows != maxSearchResults {
+		t.Errorf("Expected %d rows, got %d", maxSearchResults, rows)
+	}
+	if !strings.Contains(body, fmt.Sprintf("SHOWING %d OF %d MATCHES", maxSearchResults, maxSearchResults+5)) {
+		t.Error("Expected truncation caption")
+	}
+
+	body = search("xss")
+	if strings.Contains(body, "<script>") || !strings.Contains(body, "&lt;script&gt;") {
+		t.Errorf("Expected escaped service name, got %s", body)
+	}
+
+	if body := search("nothing-matches"); !strings.Contains(body, "NO MATCHING CERTIFICATES") {
+		t.Errorf("Expected empty result row, got %s", body)
+	}
+}
+
+func TestGenerateFormV2(t *testing.T) {
+	ca, err := NewCA(nil)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	gui, err := NewGUIHandler(ca, "")
+	if err != nil {
+		t.Fatalf("Failed to create GUI handler: %v", err)
+	}
+
+	post := func(handler http.HandlerFunc, path string, form url.Values) string {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Body.String()
+	}
+
+	form := url.Values{
+		"service_name":  {"web"},
+		"sans":          {"10.0.0.9\nweb.local\n"},
+		"validity_days": {"30"},
+		"key_algorithm": {"ecdsa-p256"},
+	}
+
+	// The preview shows the CN that will be selected without issuing anything
+	preview := post(gui.HandleGeneratePreview, "/ui/generate/preview", form)
+	if !strings.Contains(preview, "<code>web.local</code> <span class=\"badge\">FIRST DNS NAME</span>") || !strings.Contains(preview, "ecdsa-p256") {
+		t.Errorf("Unexpected preview: %s", preview)
+	}
+	if ca.GetCertificateCount() != 0 {
+		t.Error("Preview issued a certificate")
+	}
+
+	ipOnly := url.Values{"sans": {"10.0.0.9"}}
+	if preview := post(gui.HandleGeneratePreview, "/ui/generate/preview", ipOnly); !strings.Contains(preview, "FIRST IP ADDRESS") {
+		t.Errorf("Expected IP CN in preview, got %s", preview)
+	}
+
+	bad := url.Values{"sans": {"web.local"}, "validity_days": {"9999"}}
+	if preview := post(gui.HandleGeneratePreview, "/ui/generate/preview", bad); !strings.Contains(preview, "alert-error") {
+		t.Errorf("Expected validity error in preview, got %s", preview)
+	}
+
+	// Generating issues through the V2 API, with no service IP required
+	result := post(gui.HandleGenerate, "/ui/generate", form)
+	if !strings.Contains(result, "alert-success") || !strings.Contains(result, "<code>web.local</code>") || !strings.Contains(result, "ECDSA") {
+		t.Errorf("Unexpected generate result: %s", result)
+	}
+
+	certs := ca.GetIssuedCertificates()
+	if len(certs) != 1 {
+		t.Fatalf("Expected 1 certificate, got %d", len(certs))
+	}
+	if validity := certs[0].ExpiresAt.Sub(certs[0].IssuedAt); validity < 29*24*time.Hour || validity > 31*24*time.Hour {
+		t.Errorf("Expected 30 day validity, got %v", validity)
+	}
+
+	if result := post(gui.HandleGenerate, "/ui/generate", url.Values{"service_name": {"web"}}); !strings.Contains(result, "alert-error") {
+		t.Errorf("Expected error without SANs, got %s", result)
+	}
+}
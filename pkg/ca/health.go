@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Health and component statuses reported by /healthz and /readyz
+const (
+	HealthOK          = "ok"
+	HealthUnavailable = "unavailable" // Overall status when a component fails
+	HealthFail        = "fail"
+	HealthSkipped     = "skipped" // Component not applicable, e.g. persistence in RAM-only mode
+)
+
+// ComponentHealth is the result of one readiness check
+type ComponentHealth struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// HealthReport is the JSON body of /healthz and /readyz
+type HealthReport struct {
+	Status     string            `json:"status"`
+	Version    string            `json:"version"`
+	Time       time.Time         `json:"time"`
+	Components []ComponentHealth `json:"components,omitempty"`
+}
+
+// Readiness checks that the CA can issue certificates: its key is loaded and
+// matches the root, the clock is within the root's validity, and the
+// persistence directory (if any) is writable
+func (ca *CA) Readiness() HealthReport {
+	now := time.Now()
+	report := HealthReport{Status: HealthOK, Version: Version, Time: now.UTC()}
+	report.Components = []ComponentHealth{
+		ca.checkKey(),
+		ca.checkClock(now),
+		ca.checkPersistence(),
+	}
+	for _, component := range report.Components {
+		if component.Status == HealthFail {
+			report.Status = HealthUnavailable
+		}
+	}
+	return report
+}
+
+// checkKey verifies that the CA private key is loaded and belongs to the
+// CA certificate
+func (ca *CA) checkKey() ComponentHealth {
+	ca.mutex.RLock()
+	defer ca.mutex.RUnlock()
+
+	component := ComponentHealth{Name: "key", Status: HealthFail}
+	switch {
+	case ca.cert == nil:
+		component.Detail = "CA certificate not loaded"
+	case ca.privateKey == nil:
+		component.Detail = "CA private key not loaded"
+	case !ca.privateKey.PublicKey.Equal(ca.cert.PublicKey):
+		component.Detail = "CA private key does not match the CA certificate"
+	default:
+		component.Status = HealthOK
+	}
+	return component
+}
+
+// checkClock verifies that now is within the CA certificate's validity.
+// A clock set before NotBefore makes every issued certificate look not
+// yet valid; after NotAfter the CA can no longer issue usable certificates.
+func (ca *CA) checkClock(now time.Time) ComponentHealth {
+	component := ComponentHealth{Name: "clock", Status: HealthFail}
+	cert := ca.Certificate()
+	switch {
+	case cert == nil:
+		component.Detail = "no CA certificate to compare against"
+	case now.Before(cert.NotBefore):
+		component.Detail = fmt.Sprintf("clock %s is before the CA's NotBefore %s", now.UTC().Format(time.RFC3339), cert.NotBefore.UTC().Format(time.RFC3339))
+	case now.After(cert.NotAfter):
+		component.Detail = fmt.Sprintf("CA certificate expired at %s", cert.NotAfter.UTC().Format(time.RFC3339))
+	default:
+		component.Status = HealthOK
+	}
+	return component
+}
+
+// checkPersistence verifies that issued certificates can be saved by
+// writing and removing a probe file in the persistence directory
+func (ca *CA) checkPersistence() ComponentHealth {
+	component := ComponentHealth{Name: "persistence", Status: HealthSkipped, Detail: "RAM only"}
+	if ca.persistDir == "" {
+		return component
+	}
+
+	probe, err := os.CreateTemp(ca.persistDir, ".readyz-*")
+	if err != nil {
+		component.Status = HealthFail
+		component.Detail = fmt.Sprintf("%s is not writable: %v", ca.persistDir, err)
+		return component
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	component.Status = HealthOK
+	component.Detail = ca.persistDir
+	return component
+}
+
+// handleHealthz is the liveness probe: the process is up and serving HTTP
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeHealthReport(w, HealthReport{Status: HealthOK, Version: Version, Time: time.Now().UTC()})
+}
+
+// handleReadyz is the readiness probe: 200 when the CA can issue
+// certificates, 503 with the failing components otherwise. Probes are
+// unauthenticated, so only component statuses are reported; the details
+// (paths, OS errors) are in /health.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	report := s.ca.Readiness()
+	for i := range report.Components {
+		report.Components[i].Detail = ""
+	}
+	writeHealthReport(w, report)
+}
+
+func writeHealthReport(w http.ResponseWriter, report HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status != HealthOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// persistIn configures a test CA to persist to dir
+func persistIn(dir string) func(*CAConfig) {
+	return func(config *CAConfig) { config.PersistDir = dir }
+}
+
+func componentStatus(report HealthReport, name string) ComponentHealth {
+	for _, component := range report.Components {
+		if component.Name == name {
+			return component
+		}
+	}
+	return ComponentHealth{}
+}
+
+func TestReadiness(t *testing.T) {
+	authority := newTestCA(t, persistIn(t.TempDir()))
+	report := authority.Readiness()
+	if report.Status != HealthOK || report.Version != Version || len(report.Components) != 3 {
+		t.Fatalf("Expected a ready CA, got %+v", report)
+	}
+	for _, component := range report.Components {
+		if component.Status != HealthOK {
+			t.Errorf("Component %s: expected ok, got %+v", component.Name, component)
+		}
+	}
+
+	if got := componentStatus(newTestCA(t, nil).Readiness(), "persistence"); got.Status != HealthSkipped {
+		t.Errorf("Expected persistence to be skipped in RAM-only mode, got %+v", got)
+	}
+
+	t.Run("unwritable persistence", func(t *testing.T) {
+		// A path below a regular file can't be written, even as root
+		file := filepath.Join(t.TempDir(), "file")
+		os.WriteFile(file, nil, 0644)
+		authority.persistDir = filepath.Join(file, "ca")
+		defer func(dir string) { authority.persistDir = dir }(authority.persistDir)
+
+		report := authority.Readiness()
+		if report.Status != HealthUnavailable || componentStatus(report, "persistence").Status != HealthFail {
+			t.Errorf("Expected persistence to fail, got %+v", report)
+		}
+	})
+
+	t.Run("clock", func(t *testing.T) {
+		cert := authority.Certificate()
+		for _, now := range []time.Time{cert.NotBefore.Add(-time.Hour), cert.NotAfter.Add(time.Hour)} {
+			if got := authority.checkClock(now); got.Status != HealthFail || got.Detail == "" {
+				t.Errorf("checkClock(%s): expected a failure, got %+v", now, got)
+			}
+		}
+	})
+
+	t.Run("mismatched key", func(t *testing.T) {
+		other, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		original := authority.privateKey
+		authority.privateKey = other
+		defer func() { authority.privateKey = original }()
+
+		if got := authority.checkKey(); got.Status != HealthFail {
+			t.Errorf("Expected a mismatched key to fail, got %+v", got)
+		}
+	})
+}
+
+func TestHealthProbes(t *testing.T) {
+	server := &Server{ca: newTestCA(t, persistIn(t.TempDir()))}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		setup   func()
+		status  int
+	}{
+		{"healthz", server.handleHealthz, http.MethodGet, nil, http.StatusOK},
+		{"readyz", server.handleReadyz, http.MethodGet, nil, http.StatusOK},
+		{"readyz not ready", server.handleReadyz, http.MethodGet, func() { server.ca.persistDir = "/dev/null/ca" }, http.StatusServiceUnavailable},
+		{"healthz POST", server.handleHealthz, http.MethodPost, nil, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.setup != nil {
+				tt.setup()
+			}
+			rr := httptest.NewRecorder()
+			tt.handler(rr, httptest.NewRequest(tt.method, "/probe", nil))
+			if rr.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, rr.Code, rr.Body.String())
+			}
+			if tt.method != http.MethodGet {
+				return
+			}
+
+			var report HealthReport
+			if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+				t.Fatalf("Invalid JSON: %v", err)
+			}
+			if report.Version != Version || rr.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("Unexpected report %+v", report)
+			}
+			if (rr.Code == http.StatusOK) != (report.Status == HealthOK) {
+				t.Errorf("Status %q doesn't match HTTP %d", report.Status, rr.Code)
+			}
+			for _, component := range report.Components {
+				if component.Detail != "" {
+					t.Errorf("Expected no details from an unauthenticated probe, got %+v", component)
+				}
+			}
+		})
+	}
+
+	// The details are in the authenticated /health
+	rr := httptest.NewRecorder()
+	server.handleHealth(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var health struct {
+		Readiness HealthReport `json:"readiness"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&health); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if len(health.Readiness.Components) != 3 || !strings.Contains(health.Readiness.Components[2].Detail, "/dev/null/ca") {
+		t.Errorf("Expected the persistence detail in /health, got %+v", health.Readiness)
+	}
+}
@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidRoot is returned when imported root material can't be used to
+// sign certificates
+var ErrInvalidRoot = errors.New("invalid CA root")
+
+// NewCAFromPEM creates a CA from an existing root certificate and RSA
+// private key, e.g. an mkcert root or a corporate development root,
+// instead of generating a new one. Other settings are taken from
+// DefaultCAConfig; set CAConfig.RootCertPEM and RootKeyPEM with NewCA to
+// combine an imported root with persistence or a key pool.
+// Example:
+//
+//	certPEM, _ := os.ReadFile(filepath.Join(caroot, "rootCA.pem"))
+//	keyPEM, _ := os.ReadFile(filepath.Join(caroot, "rootCA-key.pem"))
+//	authority, err := ca.NewCAFromPEM(certPEM, keyPEM)
+func NewCAFromPEM(certPEM, keyPEM []byte) (*CA, error) {
+	config := DefaultCAConfig()
+	config.RootCertPEM = certPEM
+	config.RootKeyPEM = keyPEM
+	return NewCA(config)
+}
+
+// importRoot installs the root from CAConfig.RootCertPEM/RootKeyPEM. A
+// persisted root is kept only if it is the same certificate, so an import
+// never silently replaces a different root on disk.
+func (ca *CA) importRoot(config *CAConfig, permittedDomains, excludedDomains []string) error {
+	cert, key, err := parseRootPEM(config.RootCertPEM, config.RootKeyPEM, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if ca.persistDir != "" {
+		if err := ca.loadCAFromDisk(); err != nil {
+			return fmt.Errorf("failed to load CA from disk: %w", err)
+		}
+		if ca.cert != nil && !ca.cert.Equal(cert) {
+			return fmt.Errorf("%s already holds a different root (%s); remove ca-cert.pem and ca-key.pem to import %s",
+				ca.persistDir, ca.cert.Subject, cert.Subject)
+		}
+	}
+
+	ca.mutex.Lock()
+	ca.cert = cert
+	ca.privateKey = key
+	ca.mutex.Unlock()
+
+	if err := ca.saveCAKeyToDisk(); err != nil {
+		return fmt.Errorf("failed to save CA to disk: %w", err)
+	}
+
+	if len(permittedDomains) > 0 || len(excludedDomains) > 0 {
+		fmt.Printf("[ca] Warning: name constraints are ignored for an imported root (it has permitted %v, excluded %v)\n",
+			cert.PermittedDNSDomains, cert.ExcludedDNSDomains)
+	}
+	fmt.Printf("[ca] Imported CA root: %s (expires %s)\n", cert.Subject, cert.NotAfter.Format(time.DateOnly))
+	return nil
+}
+
+// parseRootPEM parses and validates imported root material. The PEM data
+// may contain other blocks; the first certificate and the first private key
+// are used, so a combined file can be passed as both arguments.
+func parseRootPEM(certPEM, keyPEM []byte, now time.Time) (*x509.Certificate, *rsa.PrivateKey, error) {
+	certBlock := findPEMBlock(certPEM, "CERTIFICATE")
+	if certBlock == nil {
+		return nil, nil, fmt.Errorf("%w: no CERTIFICATE block in the certificate PEM", ErrInvalidRoot)
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidRoot, err)
+	}
+
+	switch {
+	case !cert.BasicConstraintsValid || !cert.IsCA:
+		return nil, nil, fmt.Errorf("%w: %s is not a CA certificate", ErrInvalidRoot, cert.Subject)
+	case cert.KeyUsage != 0 && cert.KeyUsage&x509.KeyUsageCertSign == 0:
+		return nil, nil, fmt.Errorf("%w: %s is not allowed to sign certificates (no certSign key usage)", ErrInvalidRoot, cert.Subject)
+	case now.After(cert.NotAfter):
+		return nil, nil, fmt.Errorf("%w: %s expired at %s", ErrInvalidRoot, cert.Subject, cert.NotAfter.Format(time.RFC3339))
+	}
+
+	key, err := parseRootKeyPEM(keyPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !key.PublicKey.Equal(cert.PublicKey) {
+		return nil, nil, fmt.Errorf("%w: the private key does not match %s", ErrInvalidRoot, cert.Subject)
+	}
+	return cert, key, nil
+}
+
+// parseRootKeyPEM parses a PKCS#1 or PKCS#8 RSA private key
+func parseRootKeyPEM(keyPEM []byte) (*rsa.PrivateKey, error) {
+	block := findPEMBlock(keyPEM, "RSA PRIVATE KEY", "PRIVATE KEY", "EC PRIVATE KEY")
+	if block == nil {
+		return nil, fmt.Errorf("%w: no private key block in the key PEM", ErrInvalidRoot)
+	}
+	if _, encrypted := block.Headers["Proc-Type"]; encrypted {
+		return nil, fmt.Errorf("%w: the private key is passphrase-protected; decrypt it first", ErrInvalidRoot)
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRoot, err)
+		}
+		return key, nil
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRoot, err)
+		}
+		if key, ok := parsed.(*rsa.PrivateKey); ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("%w: only RSA root keys are supported, got %T", ErrInvalidRoot, parsed)
+	default:
+		return nil, fmt.Errorf("%w: only RSA root keys are supported, got %s", ErrInvalidRoot, block.Type)
+	}
+}
+
+// findPEMBlock returns the first block of one of the types
+func findPEMBlock(data []byte, types ...string) *pem.Block {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil
+		}
+		for _, t := range types {
+			if block.Type == t {
+				return block
+			}
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// externalRoot builds root material the way an external tool would, with
+// the key in PKCS#8 like mkcert writes it
+func externalRoot(t *testing.T, modify func(*x509.Certificate)) (certPEM, keyPEM []byte, key *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(7),
+		Subject:               pkix.Name{CommonName: "mkcert dev root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if modify != nil {
+		modify(template)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})
+	return certPEM, keyPEM, key
+}
+
+func TestNewCAFromPEM(t *testing.T) {
+	certPEM, keyPEM, _ := externalRoot(t, nil)
+	authority, err := NewCAFromPEM(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("NewCAFromPEM failed: %v", err)
+	}
+	if authority.Certificate().Subject.CommonName != "mkcert dev root" {
+		t.Errorf("Expected the imported root, got %s", authority.Certificate().Subject)
+	}
+
+	leafPEM, _, err := authority.GenerateCertificateV2("api", []string{"api.local"})
+	if err != nil {
+		t.Fatalf("Issuing from the imported root failed: %v", err)
+	}
+	block, _ := pem.Decode([]byte(leafPEM))
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(certPEM)
+	if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, DNSName: "api.local"}); err != nil {
+		t.Errorf("Issued certificate doesn't chain to the imported root: %v", err)
+	}
+
+	// A combined file works as both arguments
+	combined := append(append([]byte{}, keyPEM...), certPEM...)
+	if _, err := NewCAFromPEM(combined, combined); err != nil {
+		t.Errorf("Expected a combined PEM file to import: %v", err)
+	}
+}
+
+func TestNewCAFromPEMValidation(t *testing.T) {
+	_, otherKeyPEM, _ := externalRoot(t, nil)
+	certPEM, _, key := externalRoot(t, nil)
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if _, err := NewCAFromPEM(certPEM, pkcs1); err != nil {
+		t.Errorf("Expected a PKCS#1 key to import: %v", err)
+	}
+
+	leafPEM, leafKeyPEM, _ := externalRoot(t, func(c *x509.Certificate) { c.IsCA = false })
+	noSignPEM, noSignKeyPEM, _ := externalRoot(t, func(c *x509.Certificate) { c.KeyUsage = x509.KeyUsageDigitalSignature })
+	expiredPEM, expiredKeyPEM, _ := externalRoot(t, func(c *x509.Certificate) { c.NotAfter = time.Now().Add(-time.Minute) })
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecDER, _ := x509.MarshalPKCS8PrivateKey(ecKey)
+	ecKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: ecDER})
+
+	tests := []struct {
+		name    string
+		cert    []byte
+		key     []byte
+		message string
+	}{
+		{"not a CA", leafPEM, leafKeyPEM, "not a CA certificate"},
+		{"no certSign", noSignPEM, noSignKeyPEM, "not allowed to sign"},
+		{"expired", expiredPEM, expiredKeyPEM, "expired"},
+		{"mismatched key", certPEM, otherKeyPEM, "does not match"},
+		{"ECDSA key", certPEM, ecKeyPEM, "only RSA"},
+		{"missing key", certPEM, nil, "no private key"},
+		{"missing certificate", nil, pkcs1, "no CERTIFICATE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCAFromPEM(tt.cert, tt.key)
+			if !errors.Is(err, ErrInvalidRoot) || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Expected ErrInvalidRoot mentioning %q, got %v", tt.message, err)
+			}
+		})
+	}
+}
+
+func TestImportedRootPersistence(t *testing.T) {
+	dir := t.TempDir()
+	certPEM, keyPEM, _ := externalRoot(t, nil)
+	certFile := filepath.Join(dir, "rootCA.pem")
+	keyFile := filepath.Join(dir, "rootCA-key.pem")
+	os.WriteFile(certFile, certPEM, 0644)
+	os.WriteFile(keyFile, keyPEM, 0600)
+
+	persistDir := filepath.Join(dir, "ca")
+	serverConfig := DefaultServerConfig()
+	serverConfig.CAConfig.KeySize = 2048
+	serverConfig.PersistDir = persistDir
+	serverConfig.RootCertFile = certFile
+	serverConfig.RootKeyFile = keyFile
+	server, err := NewServer(serverConfig)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	server.GetCA().Close()
+
+	// Restarting without the import keeps the persisted imported root
+	config := DefaultCAConfig()
+	config.PersistDir = persistDir
+	reloaded, err := NewCA(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(reloaded.CertificatePEM()) != string(certPEM) {
+		t.Error("Expected the imported root to be persisted")
+	}
+
+	// Importing a different root over it is refused
+	otherCert, otherKey, _ := externalRoot(t, nil)
+	config.RootCertPEM = otherCert
+	config.RootKeyPEM = otherKey
+	if _, err := NewCA(config); err == nil || !strings.Contains(err.Error(), "different root") {
+		t.Errorf("Expected importing over a different persisted root to fail, got %v", err)
+	}
+
+	serverConfig = DefaultServerConfig()
+	serverConfig.RootCertFile = certFile
+	if _, err := NewServer(serverConfig); err == nil {
+		t.Error("Expected RootCertFile without RootKeyFile to fail")
+	}
+}
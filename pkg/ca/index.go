@@ -0,0 +1,172 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/nzions/sharedgolibs/internal/fileutil"
+)
+
+// IndexFileName is the machine-readable index written alongside cert-store.json
+const IndexFileName = "index.json"
+
+// IndexVersion is the schema version of index.json
+const IndexVersion = 1
+
+// CertIndex is the on-disk index of issued certificates. It carries metadata
+// only (no key material) so it can be read by external tooling and backups.
+type CertIndex struct {
+	Version   int          `json:"version"`
+	UpdatedAt time.Time    `json:"updated_at"`
+	Entries   []IndexEntry `json:"entries"`
+}
+
+// IndexEntry describes a single issued certificate in the index
+type IndexEntry struct {
+	SerialNumber string     `json:"serial_number"`
+	ServiceName  string     `json:"service_name"`
+	Namespace    string     `json:"namespace,omitempty"`
+	SANs         []string   `json:"sans"`
+	IssuedAt     time.Time  `json:"issued_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	Revoked      bool       `json:"revoked"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+
+	// Usage tracking; absent until the certificate is first fetched or used
+	LastFetchedAt *time.Time `json:"last_fetched_at,omitempty"`
+	LastUsedAt    *time.Time `json:"last_used_at,omitempty"`
+}
+
+// newIndexEntry builds an index entry from an issued certificate
+func newIndexEntry(cert *IssuedCert) IndexEntry {
+	return IndexEntry{
+		SerialNumber: cert.SerialNumber,
+		ServiceName:  cert.ServiceName,
+		Namespace:    cert.Namespace,
+		SANs:         cert.Domains,
+		IssuedAt:     cert.IssuedAt,
+		ExpiresAt:    cert.ExpiresAt,
+		Revoked:      cert.Revoked,
+		RevokedAt:    cert.RevokedAt,
+
+		LastFetchedAt: cert.LastFetchedAt,
+		LastUsedAt:    cert.LastUsedAt,
+	}
+}
+
+// issuedCert converts an index entry into a metadata-only IssuedCert
+// (used for entries added to the index by external tooling)
+func (e IndexEntry) issuedCert() *IssuedCert {
+	return &IssuedCert{
+		ServiceName:  e.ServiceName,
+		Namespace:    e.Namespace,
+		Domains:      e.SANs,
+		IssuedAt:     e.IssuedAt,
+		ExpiresAt:    e.ExpiresAt,
+		SerialNumber: e.SerialNumber,
+		Revoked:      e.Revoked,
+		RevokedAt:    e.RevokedAt,
+
+		LastFetchedAt: e.LastFetchedAt,
+		LastUsedAt:    e.LastUsedAt,
+	}
+}
+
+// buildIndex creates a sorted index from a certificate map
+func buildIndex(certs map[string]*IssuedCert) *CertIndex {
+	index := &CertIndex{
+		Version:   IndexVersion,
+		UpdatedAt: time.Now().UTC(),
+		Entries:   make([]IndexEntry, 0, len(certs)),
+	}
+	for _, cert := range certs {
+		index.Entries = append(index.Entries, newIndexEntry(cert))
+	}
+
+	// Stable ordering keeps diffs and backups readable
+	sort.Slice(index.Entries, func(i, j int) bool {
+		if !index.Entries[i].IssuedAt.Equal(index.Entries[j].IssuedAt) {
+			return index.Entries[i].IssuedAt.Before(index.Entries[j].IssuedAt)
+		}
+		return index.Entries[i].SerialNumber < index.Entries[j].SerialNumber
+	})
+
+	return index
+}
+
+// ReadCertIndex reads index.json from a CA persistence directory.
+// Returns an empty index if the file does not exist.
+func ReadCertIndex(persistDir string) (*CertIndex, error) {
+	data, err := os.ReadFile(filepath.Join(persistDir, IndexFileName))
+	if os.IsNotExist(err) {
+		return &CertIndex{Version: IndexVersion}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate index: %w", err)
+	}
+	if len(data) == 0 {
+		return &CertIndex{Version: IndexVersion}, nil
+	}
+
+	var index CertIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse certificate index: %w", err)
+	}
+	return &index, nil
+}
+
+// writeCertIndex writes index.json atomically (temp file + rename)
+func writeCertIndex(persistDir string, index *CertIndex) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal certificate index: %w", err)
+	}
+	return fileutil.WriteAtomic(filepath.Join(persistDir, IndexFileName), data, 0644)
+}
+
+// ReloadFromDisk re-reads the CA certificate, private key, certificate store,
+// and index.json from the persistence directory. Entries added to index.json
+// by external tooling become visible through GetIssuedCertificates, and
+// revocation state recorded in the index is applied to known certificates.
+// No-op if persistence is not enabled.
+func (ca *CA) ReloadFromDisk() error {
+	if ca.persistDir == "" {
+		return nil // RAM-only mode
+	}
+
+	ca.mutex.RLock()
+	previous := ca.cert
+	ca.mutex.RUnlock()
+
+	if err := ca.loadCAFromDisk(); err != nil {
+		return fmt.Errorf("failed to reload CA from disk: %w", err)
+	}
+
+	ca.mutex.RLock()
+	root := ca.cert
+	ca.mutex.RUnlock()
+	if root != nil && (previous == nil || !bytes.Equal(previous.Raw, root.Raw)) {
+		ca.publishEvent(rootEvent(root))
+	}
+
+	if r, ok := ca.storage.(reloadableStorage); ok {
+		if err := r.Reload(); err != nil {
+			return fmt.Errorf("failed to reload certificate store: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// reloadableStorage is implemented by storage backends that can re-read
+// their state from an external source
+type reloadableStorage interface {
+	Reload() error
+}
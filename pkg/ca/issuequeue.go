@@ -0,0 +1,623 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Issuance queue defaults for ServerConfig.IssueQueueSize and
+// IssueQueueWorkers
+const (
+	DefaultIssueQueueSize    = 64
+	DefaultIssueQueueWorkers = 2
+)
+
+// ticketRetention is how long finished tickets can be collected
+const ticketRetention = 10 * time.Minute
+
+// maxTicketWait caps GET /cert/ticket/{id}?wait=
+const maxTicketWait = time.Minute
+
+// approvalTimeout is how long a request waits for approval before it is
+// denied
+const approvalTimeout = 24 * time.Hour
+
+// Errors of the asynchronous issuance queue
+var (
+	ErrIssueQueueFull      = errors.New("certificate issuance queue is full")
+	ErrTicketNotFound      = errors.New("issuance ticket not found")
+	ErrNotAwaitingApproval = errors.New("issuance ticket is not awaiting approval")
+)
+
+// IssuePriority orders queued certificate requests: higher priorities are
+// issued first, and requests of the same priority in arrival order
+type IssuePriority int
+
+// Issuance priorities
+const (
+	PriorityLow IssuePriority = iota - 1
+	PriorityNormal
+	PriorityHigh
+)
+
+// String returns the priority's name as used in ?priority=
+func (p IssuePriority) String() string {
+	switch {
+	case p < PriorityNormal:
+		return "low"
+	case p > PriorityNormal:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+// ParseIssuePriority parses low, normal, or high; empty is normal
+func ParseIssuePriority(s string) (IssuePriority, error) {
+	switch strings.ToLower(s) {
+	case "", "normal":
+		return PriorityNormal, nil
+	case "low":
+		return PriorityLow, nil
+	case "high":
+		return PriorityHigh, nil
+	default:
+		return PriorityNormal, fmt.Errorf("unknown priority %q (expected low, normal, or high)", s)
+	}
+}
+
+// TicketStatus is the state of an asynchronous issuance request
+type TicketStatus string
+
+// Ticket states; a ticket ends done, failed, or denied. Requests needing
+// approval (see ServerConfig.ApprovalDomains) are queued once approved.
+const (
+	TicketAwaitingApproval TicketStatus = "awaiting_approval"
+	TicketQueued           TicketStatus = "queued"
+	TicketIssuing          TicketStatus = "issuing"
+	TicketDone             TicketStatus = "done"
+	TicketFailed           TicketStatus = "failed"
+	TicketDenied           TicketStatus = "denied"
+)
+
+// IssueTicket tracks a certificate request queued with POST /cert?async=true
+type IssueTicket struct {
+	ID          string       `json:"id"`
+	Status      TicketStatus `json:"status"`
+	Priority    string       `json:"priority"`
+	ServiceName string       `json:"service_name"`
+	SANs        []string     `json:"sans,omitempty"`     // Requests needing approval, for review
+	Position    int          `json:"position,omitempty"` // 1 = next to issue, while queued
+	CreatedAt   time.Time    `json:"created_at"`
+	CompletedAt *time.Time   `json:"completed_at,omitempty"`
+
+	// Error is set when the request failed or was denied; Certificate when
+	// it is done
+	Error       string        `json:"error,omitempty"`
+	Certificate *CertResponse `json:"certificate,omitempty"`
+}
+
+// Finished reports whether the ticket is done, failed, or denied
+func (t *IssueTicket) Finished() bool {
+	return t.Status == TicketDone || t.Status == TicketFailed || t.Status == TicketDenied
+}
+
+// queuedIssue is a ticket with the issuance it waits for
+type queuedIssue struct {
+	ticket    IssueTicket
+	priority  IssuePriority
+	namespace string
+	issue     func() (*CertResponse, error)
+	done      chan struct{} // Closed when the ticket finishes
+}
+
+// issueQueue is a bounded priority queue of certificate requests issued by
+// a fixed number of workers, so bursts of requests get a ticket at once
+// instead of waiting on key generation in their HTTP requests
+type issueQueue struct {
+	mutex   sync.Mutex
+	ready   *sync.Cond
+	size    int
+	workers int
+	started bool
+	pending []*queuedIssue // By priority, then arrival
+	tickets map[string]*queuedIssue
+}
+
+// newIssueQueue creates a queue holding up to size pending requests
+func newIssueQueue(size, workers int) *issueQueue {
+	if size <= 0 {
+		size = DefaultIssueQueueSize
+	}
+	if workers <= 0 {
+		workers = DefaultIssueQueueWorkers
+	}
+	q := &issueQueue{size: size, workers: workers, tickets: make(map[string]*queuedIssue)}
+	q.ready = sync.NewCond(&q.mutex)
+	return q
+}
+
+// newQueuedIssue creates a queued ticket for issue
+func newQueuedIssue(serviceName, namespace string, priority IssuePriority, issue func() (*CertResponse, error)) (*queuedIssue, error) {
+	id, err := newTicketID()
+	if err != nil {
+		return nil, err
+	}
+	return &queuedIssue{
+		ticket: IssueTicket{
+			ID:          id,
+			Status:      TicketQueued,
+			Priority:    priority.String(),
+			ServiceName: serviceName,
+			CreatedAt:   time.Now().UTC(),
+		},
+		priority:  priority,
+		namespace: namespace,
+		issue:     issue,
+		done:      make(chan struct{}),
+	}, nil
+}
+
+// Submit queues issue and returns its ticket. Workers start with the first
+// request, so servers that never queue run none.
+func (q *issueQueue) Submit(serviceName, namespace string, priority IssuePriority, issue func() (*CertResponse, error)) (IssueTicket, error) {
+	item, err := newQueuedIssue(serviceName, namespace, priority, issue)
+	if err != nil {
+		return IssueTicket{}, err
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.pruneLocked(time.Now())
+	if len(q.pending) >= q.size {
+		return IssueTicket{}, ErrIssueQueueFull
+	}
+	q.tickets[item.ticket.ID] = item
+	q.enqueueLocked(item)
+	return q.snapshotLocked(item), nil
+}
+
+// Hold records issue as awaiting approval and returns its ticket. It joins
+// the queue once approved with Approve, or finishes denied with Deny. At
+// most size requests await approval at a time.
+func (q *issueQueue) Hold(serviceName, namespace string, sans []string, priority IssuePriority, issue func() (*CertResponse, error)) (IssueTicket, error) {
+	item, err := newQueuedIssue(serviceName, namespace, priority, issue)
+	if err != nil {
+		return IssueTicket{}, err
+	}
+	item.ticket.Status = TicketAwaitingApproval
+	item.ticket.SANs = sans
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.pruneLocked(time.Now())
+	if len(q.awaitingLocked()) >= q.size {
+		return IssueTicket{}, ErrIssueQueueFull
+	}
+	q.tickets[item.ticket.ID] = item
+	return q.snapshotLocked(item), nil
+}
+
+// Approve queues a request awaiting approval for issuance
+func (q *issueQueue) Approve(id string) (IssueTicket, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	item, ok := q.tickets[id]
+	if !ok {
+		return IssueTicket{}, ErrTicketNotFound
+	}
+	if item.ticket.Status != TicketAwaitingApproval {
+		return IssueTicket{}, ErrNotAwaitingApproval
+	}
+	if len(q.pending) >= q.size {
+		return IssueTicket{}, ErrIssueQueueFull
+	}
+	item.ticket.Status = TicketQueued
+	q.enqueueLocked(item)
+	return q.snapshotLocked(item), nil
+}
+
+// Deny finishes a request awaiting approval without issuing it
+func (q *issueQueue) Deny(id, reason string) (IssueTicket, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	item, ok := q.tickets[id]
+	if !ok {
+		return IssueTicket{}, ErrTicketNotFound
+	}
+	if item.ticket.Status != TicketAwaitingApproval {
+		return IssueTicket{}, ErrNotAwaitingApproval
+	}
+	q.denyLocked(item, reason, time.Now())
+	return q.snapshotLocked(item), nil
+}
+
+// AwaitingApproval returns the requests awaiting approval, oldest first
+func (q *issueQueue) AwaitingApproval() []IssueTicket {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.pruneLocked(time.Now())
+	awaiting := q.awaitingLocked()
+	tickets := make([]IssueTicket, 0, len(awaiting))
+	for _, item := range awaiting {
+		tickets = append(tickets, q.snapshotLocked(item))
+	}
+	sort.Slice(tickets, func(i, j int) bool { return tickets[i].CreatedAt.Before(tickets[j].CreatedAt) })
+	return tickets
+}
+
+// awaitingLocked returns the requests awaiting approval
+func (q *issueQueue) awaitingLocked() []*queuedIssue {
+	var awaiting []*queuedIssue
+	for _, item := range q.tickets {
+		if item.ticket.Status == TicketAwaitingApproval {
+			awaiting = append(awaiting, item)
+		}
+	}
+	return awaiting
+}
+
+// denyLocked finishes a request awaiting approval as denied
+func (q *issueQueue) denyLocked(item *queuedIssue, reason string, now time.Time) {
+	completed := now.UTC()
+	item.ticket.Status = TicketDenied
+	item.ticket.CompletedAt = &completed
+	item.ticket.Error = "Certificate request denied"
+	if reason != "" {
+		item.ticket.Error += ": " + reason
+	}
+	item.issue = nil
+	close(item.done)
+}
+
+// enqueueLocked adds a ticket to the queue by priority, starting the
+// workers with the first request
+func (q *issueQueue) enqueueLocked(item *queuedIssue) {
+	priority := item.priority
+	position := len(q.pending)
+	for position > 0 && q.pending[position-1].priority < priority {
+		position--
+	}
+	q.pending = append(q.pending, nil)
+	copy(q.pending[position+1:], q.pending[position:])
+	q.pending[position] = item
+
+	if !q.started {
+		q.started = true
+		for i := 0; i < q.workers; i++ {
+			go q.work()
+		}
+	}
+	q.ready.Signal()
+}
+
+// work issues pending requests one at a time
+func (q *issueQueue) work() {
+	for {
+		q.mutex.Lock()
+		for len(q.pending) == 0 {
+			q.ready.Wait()
+		}
+		item := q.pending[0]
+		q.pending = q.pending[1:]
+		item.ticket.Status = TicketIssuing
+		q.mutex.Unlock()
+
+		response, err := item.issue()
+
+		q.mutex.Lock()
+		completed := time.Now().UTC()
+		item.ticket.CompletedAt = &completed
+		if err != nil {
+			item.ticket.Status = TicketFailed
+			item.ticket.Error = issueErrorMessage(err)
+			log.Printf("[ca] Queued certificate for %s failed: %v", item.ticket.ServiceName, err)
+		} else {
+			item.ticket.Status = TicketDone
+			item.ticket.Certificate = response
+		}
+		item.issue = nil
+		close(item.done)
+		q.mutex.Unlock()
+	}
+}
+
+// Get returns the ticket with the given ID, waiting up to wait for it to
+// finish. Tickets of other namespaces are not found for scoped requests.
+func (q *issueQueue) Get(ctx context.Context, id, namespace string, scoped bool, wait time.Duration) (IssueTicket, error) {
+	q.mutex.Lock()
+	item, ok := q.tickets[id]
+	q.mutex.Unlock()
+	if !ok || (scoped && item.namespace != namespace) {
+		return IssueTicket{}, ErrTicketNotFound
+	}
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-item.done:
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.snapshotLocked(item), nil
+}
+
+// Pending returns the number of requests waiting for a worker
+func (q *issueQueue) Pending() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.pending)
+}
+
+// snapshotLocked copies a ticket with its current queue position
+func (q *issueQueue) snapshotLocked(item *queuedIssue) IssueTicket {
+	ticket := item.ticket
+	if ticket.Status == TicketQueued {
+		for i, pending := range q.pending {
+			if pending == item {
+				ticket.Position = i + 1
+				break
+			}
+		}
+	}
+	return ticket
+}
+
+// pruneLocked denies requests awaiting approval for longer than
+// approvalTimeout and forgets tickets finished more than ticketRetention ago
+func (q *issueQueue) pruneLocked(now time.Time) {
+	for id, item := range q.tickets {
+		if item.ticket.Status == TicketAwaitingApproval && now.Sub(item.ticket.CreatedAt) > approvalTimeout {
+			q.denyLocked(item, "approval timed out", now)
+		}
+		if item.ticket.CompletedAt != nil && now.Sub(*item.ticket.CompletedAt) > ticketRetention {
+			delete(q.tickets, id)
+		}
+	}
+}
+
+// newTicketID returns a random ticket ID
+func newTicketID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate ticket ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// issueErrorMessage is the message returned to clients for a failed
+// issuance: request errors are explained, internal ones are not
+func issueErrorMessage(err error) string {
+	if isCertRequestError(err) {
+		return err.Error()
+	}
+	return "Certificate generation failed"
+}
+
+// isCertRequestError reports whether err is the client's fault
+func isCertRequestError(err error) bool {
+	return errors.Is(err, ErrInvalidSAN) || errors.Is(err, ErrInvalidCertRequest) || errors.Is(err, ErrNameNotPermitted)
+}
+
+// respondIssued issues a certificate for a validated POST /cert request:
+// at once, or with ?async=true through the issuance queue, answering 202
+// with a ticket to collect from GET /cert/ticket/{id}. Requests for names
+// needing approval always get a ticket, held until an admin decides.
+func (s *Server) respondIssued(w http.ResponseWriter, r *http.Request, serviceName, api string, sans []string, issue func() (*CertResponse, error)) {
+	sensitive := s.approvalRequired(sans)
+	if async := r.URL.Query().Get("async"); async == "true" || async == "1" || len(sensitive) > 0 {
+		priority, err := ParseIssuePriority(r.URL.Query().Get("priority"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		namespace, _ := requestNamespace(r)
+		var ticket IssueTicket
+		if len(sensitive) > 0 {
+			ticket, err = s.issueQueue.Hold(serviceName, namespace, sans, priority, issue)
+		} else {
+			ticket, err = s.issueQueue.Submit(serviceName, namespace, priority, issue)
+		}
+		if errors.Is(err, ErrIssueQueueFull) {
+			log.Printf("[ca] Issuance queue full, refusing %s from %s", serviceName, r.RemoteAddr)
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			log.Printf("[ca] Failed to queue certificate for %s: %v", serviceName, err)
+			http.Error(w, "Failed to queue certificate request", http.StatusInternalServerError)
+			return
+		}
+
+		if len(sensitive) > 0 {
+			log.Printf("[ca] Certificate for %s awaits approval for %s (%s, ticket %s)", serviceName, strings.Join(sensitive, ", "), api, ticket.ID)
+		} else {
+			log.Printf("[ca] Certificate for %s queued (%s, %s priority, ticket %s)", serviceName, api, ticket.Priority, ticket.ID)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/cert/ticket/"+ticket.ID)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(ticket)
+		return
+	}
+
+	response, err := issue()
+	if err != nil {
+		log.Printf("[ca] Failed to generate certificate for %s: %v", serviceName, err)
+		if isCertRequestError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Certificate generation failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+	log.Printf("[ca] ✅ Certificate issued for %s (%s)", serviceName, api)
+}
+
+// handleCertTicket serves GET /cert/ticket/{id}; ?wait= (a Go duration, at
+// most a minute) holds the request until the ticket finishes
+func (s *Server) handleCertTicket(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/cert/ticket/")
+	if id == "" || strings.Contains(id, "/") {
+		http.Error(w, "Ticket ID is required", http.StatusBadRequest)
+		return
+	}
+	var wait time.Duration
+	if value := r.URL.Query().Get("wait"); value != "" {
+		var err error
+		if wait, err = time.ParseDuration(value); err != nil || wait < 0 {
+			http.Error(w, "wait must be a duration such as 30s", http.StatusBadRequest)
+			return
+		}
+		wait = min(wait, maxTicketWait)
+	}
+
+	namespace, scoped := requestNamespace(r)
+	ticket, err := s.issueQueue.Get(r.Context(), id, namespace, scoped, wait)
+	if err != nil {
+		http.Error(w, "Ticket not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ticket)
+}
+
+// RequestCertificateAsync queues a V2 certificate request with the SGL_CA
+// server and returns its ticket without waiting for the certificate. A full
+// queue fails with ErrIssueQueueFull. Collect the certificate with
+// WaitForCertificate, or poll with GetCertificateTicket.
+func RequestCertificateAsync(certReq CertRequestV2, priority IssuePriority) (*IssueTicket, error) {
+	caURL, err := getValidatedCAURL()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := createCertRequestV2(caURL+"/cert?async=true&priority="+priority.String(), certReq)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCARequest, err)
+	}
+	setAuthHeaders(req)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCARequest, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusAccepted:
+	case http.StatusUnauthorized:
+		return nil, ErrUnauthorized
+	case http.StatusServiceUnavailable:
+		return nil, ErrIssueQueueFull
+	default:
+		return nil, fmt.Errorf("%w: server returned status %d", ErrCARequest, resp.StatusCode)
+	}
+
+	var ticket IssueTicket
+	if err := json.NewDecoder(resp.Body).Decode(&ticket); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCAResponse, err)
+	}
+	return &ticket, nil
+}
+
+// GetCertificateTicket returns the current state of a ticket from
+// RequestCertificateAsync without waiting
+func GetCertificateTicket(id string) (*IssueTicket, error) {
+	return getCertificateTicket(context.Background(), id, 0)
+}
+
+// WaitForCertificate waits for the ticket from RequestCertificateAsync to
+// finish and returns its certificate. The server holds each poll until the
+// ticket finishes or up to 30 seconds, so waiting costs few requests. A
+// failed issuance returns ErrCARequest with the server's reason, and a
+// denied one ErrCertRequestDenied.
+func WaitForCertificate(ctx context.Context, id string) (*CertResponse, error) {
+	for {
+		ticket, err := getCertificateTicket(ctx, id, 30*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		switch ticket.Status {
+		case TicketDone:
+			return ticket.Certificate, nil
+		case TicketFailed:
+			return nil, fmt.Errorf("%w: %s", ErrCARequest, ticket.Error)
+		case TicketDenied:
+			return nil, fmt.Errorf("%w: %s", ErrCertRequestDenied, ticket.Error)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// getCertificateTicket fetches a ticket, asking the server to wait
+func getCertificateTicket(ctx context.Context, id string, wait time.Duration) (*IssueTicket, error) {
+	caURL, err := getValidatedCAURL()
+	if err != nil {
+		return nil, err
+	}
+
+	ticketURL := caURL + "/cert/ticket/" + url.PathEscape(id)
+	if wait > 0 {
+		ticketURL += "?wait=" + wait.String()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ticketURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCARequest, err)
+	}
+	setAuthHeaders(req)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%w: %v", ErrCARequest, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return nil, ErrUnauthorized
+	case http.StatusNotFound:
+		return nil, ErrTicketNotFound
+	default:
+		return nil, fmt.Errorf("%w: server returned status %d", ErrCARequest, resp.StatusCode)
+	}
+
+	var ticket IssueTicket
+	if err := json.NewDecoder(resp.Body).Decode(&ticket); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCAResponse, err)
+	}
+	return &ticket, nil
+}
@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIssueQueueOrder(t *testing.T) {
+	q := newIssueQueue(3, 1)
+	release := make(chan struct{})
+	var issued []string
+	issue := func(name string) func() (*CertResponse, error) {
+		return func() (*CertResponse, error) {
+			<-release
+			issued = append(issued, name)
+			return &CertResponse{Certificate: name}, nil
+		}
+	}
+
+	// The first request occupies the only worker while the rest queue
+	first, err := q.Submit("first", "", PriorityNormal, issue("first"))
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for q.Pending() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	var tickets []IssueTicket
+	for _, request := range []struct {
+		name     string
+		priority IssuePriority
+	}{{"low", PriorityLow}, {"normal", PriorityNormal}, {"high", PriorityHigh}} {
+		ticket, err := q.Submit(request.name, "", request.priority, issue(request.name))
+		if err != nil {
+			t.Fatalf("Submit(%s) failed: %v", request.name, err)
+		}
+		tickets = append(tickets, ticket)
+	}
+	if tickets[2].Position != 1 || tickets[2].Priority != "high" {
+		t.Errorf("Expected the high priority request next, got %+v", tickets[2])
+	}
+	if _, err := q.Submit("overflow", "", PriorityHigh, issue("overflow")); !errors.Is(err, ErrIssueQueueFull) {
+		t.Errorf("Expected ErrIssueQueueFull, got %v", err)
+	}
+
+	close(release)
+	ticket, err := q.Get(context.Background(), tickets[0].ID, "", false, 5*time.Second)
+	if err != nil || ticket.Status != TicketDone || ticket.Certificate.Certificate != "low" {
+		t.Fatalf("Expected the low priority ticket done, got %+v, %v", ticket, err)
+	}
+	if want := "first,high,normal,low"; strings.Join(issued, ",") != want {
+		t.Errorf("Issued %v, want %s", issued, want)
+	}
+	if ticket, _ := q.Get(context.Background(), first.ID, "", false, 0); ticket.CompletedAt == nil || !ticket.Finished() {
+		t.Errorf("Expected the first ticket finished, got %+v", ticket)
+	}
+
+	if _, err := q.Get(context.Background(), tickets[0].ID, "team-a", true, 0); !errors.Is(err, ErrTicketNotFound) {
+		t.Errorf("Expected tickets of other namespaces to be hidden, got %v", err)
+	}
+}
+
+func TestAsyncCertificateRequests(t *testing.T) {
+	config := DefaultCAConfig()
+	config.KeySize = 2048
+	server, err := NewServer(&ServerConfig{CAConfig: config})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cert", server.handleCertRequest)
+	mux.HandleFunc("/cert/ticket/", server.handleCertTicket)
+	caServer := httptest.NewServer(mux)
+	defer caServer.Close()
+	t.Setenv("SGL_CA", caServer.URL)
+	t.Setenv("SGL_CA_API_KEY", "")
+
+	ticket, err := RequestCertificateAsync(CertRequestV2{ServiceName: "api", SANs: []string{"api.local"}}, PriorityHigh)
+	if err != nil {
+		t.Fatalf("RequestCertificateAsync failed: %v", err)
+	}
+	if ticket.ID == "" || ticket.Priority != "high" || ticket.ServiceName != "api" {
+		t.Errorf("Unexpected ticket: %+v", ticket)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cert, err := WaitForCertificate(ctx, ticket.ID)
+	if err != nil {
+		t.Fatalf("WaitForCertificate failed: %v", err)
+	}
+	if parsed, err := parseCertificatePEM(cert.Certificate); err != nil || parsed.DNSNames[0] != "api.local" {
+		t.Errorf("Expected a certificate for api.local, got %v", err)
+	}
+	if polled, err := GetCertificateTicket(ticket.ID); err != nil || polled.Status != TicketDone {
+		t.Errorf("Expected a done ticket, got %+v, %v", polled, err)
+	}
+
+	// Invalid requests fail their ticket with the reason
+	ticket, err = RequestCertificateAsync(CertRequestV2{ServiceName: "bad", SANs: []string{"bad.local"}, ValidityDays: 9999}, PriorityNormal)
+	if err != nil {
+		t.Fatalf("RequestCertificateAsync failed: %v", err)
+	}
+	if _, err := WaitForCertificate(ctx, ticket.ID); !errors.Is(err, ErrCARequest) || !strings.Contains(err.Error(), "validity") {
+		t.Errorf("Expected the validation error, got %v", err)
+	}
+
+	if _, err := GetCertificateTicket("unknown"); !errors.Is(err, ErrTicketNotFound) {
+		t.Errorf("Expected ErrTicketNotFound, got %v", err)
+	}
+
+	// V1 requests queue too, and bad priorities are refused up front
+	post := func(query, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/cert"+query, strings.NewReader(body)))
+		return rr
+	}
+	rr := post("?async=true", `{"service_name": "legacy", "domains": ["legacy.local"]}`)
+	var queued IssueTicket
+	if err := json.NewDecoder(rr.Body).Decode(&queued); err != nil || rr.Code != http.StatusAccepted || rr.Header().Get("Location") != "/cert/ticket/"+queued.ID {
+		t.Errorf("Expected 202 with a ticket, got %d (%v)", rr.Code, err)
+	}
+	if rr := post("?async=true&priority=urgent", `{"service_name": "api", "sans": ["api.local"]}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown priority, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,242 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// EncryptedKeyPEMType is the PEM block type used for private keys encrypted at rest
+const EncryptedKeyPEMType = "SGL ENCRYPTED PRIVATE KEY"
+
+// scrypt parameters for passphrase-derived keys (interactive-login strength)
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+	saltLen      = 16
+)
+
+// ErrKeyEncrypted is returned when an encrypted private key is found on disk
+// but no passphrase or encryption key was configured.
+var ErrKeyEncrypted = errors.New("private key is encrypted but no passphrase or encryption key is configured")
+
+// KeyEncryptor encrypts and decrypts PEM-encoded private keys with AES-256-GCM.
+// The AES key is either supplied directly or derived from a passphrase with
+// scrypt. Derived keys are cached per salt, so re-encrypting a store of many
+// leaf keys pays the scrypt cost once.
+type KeyEncryptor struct {
+	passphrase []byte
+	rawKey     []byte
+	salt       []byte
+
+	mutex   sync.Mutex
+	derived map[string][]byte // salt (hex) -> derived key
+}
+
+// NewPassphraseEncryptor returns a KeyEncryptor that derives its AES key from
+// passphrase using scrypt.
+func NewPassphraseEncryptor(passphrase string) (*KeyEncryptor, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase cannot be empty")
+	}
+
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	return &KeyEncryptor{
+		passphrase: []byte(passphrase),
+		salt:       salt,
+		derived:    make(map[string][]byte),
+	}, nil
+}
+
+// NewKeyEncryptor returns a KeyEncryptor that uses a raw 32-byte AES-256 key.
+func NewKeyEncryptor(key []byte) (*KeyEncryptor, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+
+	return &KeyEncryptor{
+		rawKey:  append([]byte(nil), key...),
+		derived: make(map[string][]byte),
+	}, nil
+}
+
+// newKeyEncryptorFromConfig returns the encryptor configured on config, or nil
+// if encryption at rest is not enabled.
+func newKeyEncryptorFromConfig(config *CAConfig) (*KeyEncryptor, error) {
+	switch {
+	case len(config.KeyEncryptionKey) > 0 && config.KeyPassphrase != "":
+		return nil, fmt.Errorf("set either KeyPassphrase or KeyEncryptionKey, not both")
+	case len(config.KeyEncryptionKey) > 0:
+		return NewKeyEncryptor(config.KeyEncryptionKey)
+	case config.KeyPassphrase != "":
+		return NewPassphraseEncryptor(config.KeyPassphrase)
+	default:
+		return nil, nil
+	}
+}
+
+// EncryptPEM encrypts a PEM-encoded private key and returns it wrapped in an
+// EncryptedKeyPEMType block. The original block type is preserved so
+// DecryptPEM can restore it exactly.
+func (e *KeyEncryptor) EncryptPEM(keyPEM []byte) ([]byte, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode private key PEM")
+	}
+	if block.Type == EncryptedKeyPEMType {
+		return keyPEM, nil // Already encrypted
+	}
+
+	aesKey, err := e.keyForSalt(e.salt)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(aesKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	headers := map[string]string{
+		"Cipher":     "AES-256-GCM",
+		"Inner-Type": block.Type,
+		"Nonce":      hex.EncodeToString(nonce),
+	}
+	if e.rawKey == nil {
+		headers["KDF"] = "scrypt"
+		headers["Salt"] = hex.EncodeToString(e.salt)
+		headers["N"] = strconv.Itoa(scryptN)
+		headers["R"] = strconv.Itoa(scryptR)
+		headers["P"] = strconv.Itoa(scryptP)
+	} else {
+		headers["KDF"] = "none"
+	}
+
+	// Bind the inner type to the ciphertext so it cannot be swapped
+	ciphertext := gcm.Seal(nil, nonce, block.Bytes, []byte(block.Type))
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type:    EncryptedKeyPEMType,
+		Headers: headers,
+		Bytes:   ciphertext,
+	}), nil
+}
+
+// DecryptPEM reverses EncryptPEM. Unencrypted PEM input is returned unchanged.
+func (e *KeyEncryptor) DecryptPEM(data []byte) ([]byte, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode private key PEM")
+	}
+	if block.Type != EncryptedKeyPEMType {
+		return data, nil
+	}
+
+	var aesKey []byte
+	var err error
+	switch block.Headers["KDF"] {
+	case "scrypt":
+		if e.passphrase == nil {
+			return nil, fmt.Errorf("key was encrypted with a passphrase but a raw encryption key is configured")
+		}
+		salt, decodeErr := hex.DecodeString(block.Headers["Salt"])
+		if decodeErr != nil {
+			return nil, fmt.Errorf("invalid salt in encrypted key: %w", decodeErr)
+		}
+		aesKey, err = e.keyForSalt(salt)
+	case "none":
+		if e.rawKey == nil {
+			return nil, fmt.Errorf("key was encrypted with a raw encryption key but a passphrase is configured")
+		}
+		aesKey = e.rawKey
+	default:
+		return nil, fmt.Errorf("unsupported key derivation %q", block.Headers["KDF"])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := hex.DecodeString(block.Headers["Nonce"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid nonce in encrypted key: %w", err)
+	}
+
+	gcm, err := newGCM(aesKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce length in encrypted key")
+	}
+
+	innerType := block.Headers["Inner-Type"]
+	plaintext, err := gcm.Open(nil, nonce, block.Bytes, []byte(innerType))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt private key (wrong passphrase?)")
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: innerType, Bytes: plaintext}), nil
+}
+
+// keyForSalt returns the AES key for salt, deriving and caching it if needed.
+func (e *KeyEncryptor) keyForSalt(salt []byte) ([]byte, error) {
+	if e.rawKey != nil {
+		return e.rawKey, nil
+	}
+
+	cacheKey := hex.EncodeToString(salt)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if key, ok := e.derived[cacheKey]; ok {
+		return key, nil
+	}
+
+	key, err := scrypt.Key(e.passphrase, salt, scryptN, scryptR, scryptP, scryptKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive encryption key: %w", err)
+	}
+	e.derived[cacheKey] = key
+	return key, nil
+}
+
+// isEncryptedKeyPEM reports whether data holds an EncryptedKeyPEMType block
+func isEncryptedKeyPEM(data []byte) bool {
+	block, _ := pem.Decode(data)
+	return block != nil && block.Type == EncryptedKeyPEMType
+}
+
+// newGCM creates an AES-GCM AEAD for key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return gcm, nil
+}
@@ -0,0 +1,191 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// KeyAlgorithm selects the key type generated for leaf certificates
+type KeyAlgorithm string
+
+const (
+	KeyAlgorithmRSA2048   KeyAlgorithm = "rsa2048"
+	KeyAlgorithmRSA4096   KeyAlgorithm = "rsa4096"
+	KeyAlgorithmECDSAP256 KeyAlgorithm = "ecdsa-p256"
+	KeyAlgorithmECDSAP384 KeyAlgorithm = "ecdsa-p384"
+)
+
+// DefaultLeafKeyAlgorithm is used when CAConfig.LeafKeyAlgorithm is empty
+const DefaultLeafKeyAlgorithm = KeyAlgorithmRSA2048
+
+// validateKeyAlgorithm returns an error for unknown algorithms
+func validateKeyAlgorithm(alg KeyAlgorithm) error {
+	switch alg {
+	case KeyAlgorithmRSA2048, KeyAlgorithmRSA4096, KeyAlgorithmECDSAP256, KeyAlgorithmECDSAP384, "":
+		return nil
+	default:
+		return fmt.Errorf("unsupported key algorithm %q", alg)
+	}
+}
+
+// generateKey creates a new private key for alg
+func generateKey(alg KeyAlgorithm) (crypto.Signer, error) {
+	switch alg {
+	case KeyAlgorithmRSA2048, "":
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case KeyAlgorithmRSA4096:
+		return rsa.GenerateKey(rand.Reader, 4096)
+	case KeyAlgorithmECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyAlgorithmECDSAP384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported key algorithm %q", alg)
+	}
+}
+
+// marshalKeyPEM encodes a leaf private key as PEM. RSA keys keep the
+// PKCS#1 "RSA PRIVATE KEY" encoding for compatibility with existing clients.
+func marshalKeyPEM(key crypto.Signer) ([]byte, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(k),
+		}), nil
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal EC private key: %w", err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+}
+
+// KeyPoolStats reports key pool usage
+type KeyPoolStats struct {
+	Algorithm KeyAlgorithm `json:"algorithm"`
+	Size      int          `json:"size"`      // Configured capacity
+	Available int          `json:"available"` // Keys ready to hand out
+	Hits      uint64       `json:"hits"`      // Requests served from the pool
+	Misses    uint64       `json:"misses"`    // Requests that generated a key inline
+	Generated uint64       `json:"generated"` // Keys generated in the background
+}
+
+// KeyPool pre-generates leaf private keys in the background so certificate
+// issuance does not wait on key generation. When the pool is drained, Get
+// falls back to generating a key inline.
+type KeyPool struct {
+	alg  KeyAlgorithm
+	keys chan crypto.Signer
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	generated atomic.Uint64
+
+	stop      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewKeyPool creates a pool holding up to size keys of algorithm alg and
+// starts filling it with the given number of background workers
+// (minimum 1).
+func NewKeyPool(size int, alg KeyAlgorithm, workers int) (*KeyPool, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("key pool size must be positive, got %d", size)
+	}
+	if alg == "" {
+		alg = DefaultLeafKeyAlgorithm
+	}
+	if err := validateKeyAlgorithm(alg); err != nil {
+		return nil, err
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	p := &KeyPool{
+		alg:  alg,
+		keys: make(chan crypto.Signer, size),
+		stop: make(chan struct{}),
+	}
+
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.fill()
+	}
+
+	return p, nil
+}
+
+// fill generates keys until the pool is closed, blocking while it is full
+func (p *KeyPool) fill() {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+
+		key, err := generateKey(p.alg)
+		if err != nil {
+			fmt.Printf("[ca] Key pool: failed to generate key: %v\n", err)
+			return
+		}
+
+		select {
+		case p.keys <- key:
+			p.generated.Add(1)
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// Get returns a pre-generated key, or generates one inline if the pool is empty
+func (p *KeyPool) Get() (crypto.Signer, error) {
+	select {
+	case key := <-p.keys:
+		p.hits.Add(1)
+		return key, nil
+	default:
+		p.misses.Add(1)
+		return generateKey(p.alg)
+	}
+}
+
+// Stats returns current pool statistics
+func (p *KeyPool) Stats() KeyPoolStats {
+	return KeyPoolStats{
+		Algorithm: p.alg,
+		Size:      cap(p.keys),
+		Available: len(p.keys),
+		Hits:      p.hits.Load(),
+		Misses:    p.misses.Load(),
+		Generated: p.generated.Load(),
+	}
+}
+
+// Close stops the background workers. Keys already in the pool remain
+// available to Get.
+func (p *KeyPool) Close() {
+	p.closeOnce.Do(func() {
+		close(p.stop)
+	})
+	p.wg.Wait()
+}
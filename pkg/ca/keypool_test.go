@@ -0,0 +1,143 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"crypto/ecdsa"
+	"crypto/tls"
+	"testing"
+	"time"
+)
+
+// waitForPool waits until the pool holds at least n keys
+func waitForPool(t testing.TB, pool *KeyPool, n int) {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for pool.Stats().Available < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Key pool did not fill to %d keys", n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestKeyPool(t *testing.T) {
+	pool, err := NewKeyPool(2, KeyAlgorithmECDSAP256, 1)
+	if err != nil {
+		t.Fatalf("Failed to create key pool: %v", err)
+	}
+	defer pool.Close()
+
+	waitForPool(t, pool, 2)
+
+	key, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if _, ok := key.(*ecdsa.PrivateKey); !ok {
+		t.Errorf("Expected ECDSA key, got %T", key)
+	}
+
+	stats := pool.Stats()
+	if stats.Hits != 1 || stats.Size != 2 || stats.Algorithm != KeyAlgorithmECDSAP256 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// Drain the pool after closing: Get must still succeed inline
+	pool.Close()
+	for i := 0; i < 3; i++ {
+		if _, err := pool.Get(); err != nil {
+			t.Fatalf("Get after Close failed: %v", err)
+		}
+	}
+	if pool.Stats().Misses == 0 {
+		t.Error("Expected misses once the pool was drained")
+	}
+}
+
+func TestKeyPool_InvalidConfig(t *testing.T) {
+	if _, err := NewKeyPool(0, KeyAlgorithmRSA2048, 1); err == nil {
+		t.Error("Expected error for zero pool size")
+	}
+	if _, err := NewKeyPool(1, "dsa", 1); err == nil {
+		t.Error("Expected error for unsupported algorithm")
+	}
+
+	config := DefaultCAConfig()
+	config.KeySize = 2048
+	config.LeafKeyAlgorithm = "dsa"
+	if _, err := NewCA(config); err == nil {
+		t.Error("Expected NewCA to reject unsupported leaf key algorithm")
+	}
+}
+
+func TestCAWithKeyPool(t *testing.T) {
+	config := DefaultCAConfig()
+	config.KeySize = 2048
+	config.LeafKeyAlgorithm = KeyAlgorithmECDSAP256
+	config.KeyPoolSize = 4
+
+	ca, err := NewCA(config)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	defer ca.Close()
+
+	waitForPool(t, ca.keyPool, 1)
+
+	resp, err := ca.IssueServiceCertificateV2(CertRequestV2{ServiceName: "pooled", SANs: []string{"pooled.local"}})
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+
+	// Issued key and certificate must form a usable TLS pair
+	if _, err := tls.X509KeyPair([]byte(resp.Certificate), []byte(resp.PrivateKey)); err != nil {
+		t.Errorf("Issued certificate and key do not match: %v", err)
+	}
+
+	stats, ok := ca.KeyPoolStats()
+	if !ok {
+		t.Fatal("Expected key pool stats")
+	}
+	if stats.Hits != 1 {
+		t.Errorf("Expected issuance to use the pool, got %+v", stats)
+	}
+
+	if _, ok := (&CA{}).KeyPoolStats(); ok {
+		t.Error("Expected no stats when key pool is disabled")
+	}
+}
+
+func benchmarkIssue(b *testing.B, poolSize int) {
+	config := DefaultCAConfig()
+	config.KeySize = 2048
+	config.KeyPoolSize = poolSize
+	config.KeyPoolWorkers = 4
+
+	ca, err := NewCA(config)
+	if err != nil {
+		b.Fatalf("Failed to create CA: %v", err)
+	}
+	defer ca.Close()
+
+	if ca.keyPool != nil {
+		waitForPool(b, ca.keyPool, poolSize)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if ca.keyPool != nil && i > 0 && i%poolSize == 0 {
+			// Measure issuance latency with a warm pool, not refill throughput
+			b.StopTimer()
+			waitForPool(b, ca.keyPool, poolSize)
+			b.StartTimer()
+		}
+		if _, err := ca.IssueServiceCertificateV2(CertRequestV2{ServiceName: "bench", SANs: []string{"bench.local"}}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkIssueCertificate_NoPool(b *testing.B) { benchmarkIssue(b, 0) }
+
+func BenchmarkIssueCertificate_WarmPool(b *testing.B) { benchmarkIssue(b, 16) }
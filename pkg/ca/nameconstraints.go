@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNameNotPermitted is returned when a certificate request names a DNS
+// domain outside the root CA's name constraints
+var ErrNameNotPermitted = errors.New("name not permitted by the CA's name constraints")
+
+// normalizeConstraintDomains validates and lowercases DNS name constraints.
+// A domain such as "local" matches itself and every subdomain; a leading
+// dot (".local") matches subdomains only, as in RFC 5280.
+func normalizeConstraintDomains(domains []string) ([]string, error) {
+	var normalized []string
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		labels := strings.TrimPrefix(domain, ".")
+		switch {
+		case labels == "":
+			return nil, fmt.Errorf("empty domain %q", domain)
+		case strings.Contains(labels, "*"):
+			return nil, fmt.Errorf("domain %q must not contain a wildcard (use %q to match subdomains)", domain, "."+strings.TrimPrefix(labels, "*."))
+		case strings.HasSuffix(labels, ".") || strings.Contains(labels, ".."):
+			return nil, fmt.Errorf("domain %q has an empty label", domain)
+		case strings.ContainsAny(labels, ":/@ "):
+			return nil, fmt.Errorf("domain %q is not a DNS name", domain)
+		}
+		normalized = append(normalized, domain)
+	}
+	return normalized, nil
+}
+
+// matchDomainConstraint reports whether a DNS name falls under a constraint
+func matchDomainConstraint(name, constraint string) bool {
+	name = strings.ToLower(name)
+	if strings.HasPrefix(constraint, ".") {
+		return strings.HasSuffix(name, constraint)
+	}
+	return name == constraint || strings.HasSuffix(name, "."+constraint)
+}
+
+// checkNameConstraints rejects DNS names the root's name constraints would
+// make invalid, so a request fails at issuance rather than at verification.
+// IP, URI, and email SANs are not constrained.
+func checkNameConstraints(root *x509.Certificate, dnsNames []string) error {
+	for _, name := range dnsNames {
+		for _, excluded := range root.ExcludedDNSDomains {
+			if matchDomainConstraint(name, excluded) {
+				return fmt.Errorf("%w: %s is excluded (%s)", ErrNameNotPermitted, name, excluded)
+			}
+		}
+
+		if len(root.PermittedDNSDomains) == 0 {
+			continue
+		}
+		permitted := false
+		for _, domain := range root.PermittedDNSDomains {
+			permitted = permitted || matchDomainConstraint(name, domain)
+		}
+		if !permitted {
+			return fmt.Errorf("%w: %s is outside the permitted domains (%s)", ErrNameNotPermitted, name, strings.Join(root.PermittedDNSDomains, ", "))
+		}
+	}
+	return nil
+}
+
+// NameConstraints returns the DNS name constraints of the root certificate.
+// Both are empty for an unconstrained root.
+func (ca *CA) NameConstraints() (permitted, excluded []string) {
+	ca.mutex.RLock()
+	defer ca.mutex.RUnlock()
+	return ca.cert.PermittedDNSDomains, ca.cert.ExcludedDNSDomains
+}
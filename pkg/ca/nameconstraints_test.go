@@ -0,0 +1,140 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestNameConstraints(t *testing.T) {
+	config := DefaultCAConfig()
+	config.KeySize = 2048
+	config.PermittedDNSDomains = []string{"Local", "test"}
+	config.ExcludedDNSDomains = []string{"secret.local"}
+
+	ca, err := NewCA(config)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+
+	root := ca.Certificate()
+	if !root.PermittedDNSDomainsCritical {
+		t.Error("Expected the name constraints extension to be critical")
+	}
+	permitted, excluded := ca.NameConstraints()
+	if len(permitted) != 2 || permitted[0] != "local" || len(excluded) != 1 {
+		t.Errorf("Unexpected constraints: permitted %v, excluded %v", permitted, excluded)
+	}
+	if info := ca.GetCAInfo(); info["permitted_dns_domains"] == nil {
+		t.Errorf("Expected constraints in CA info, got %v", info)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	// Permitted names are issued and verify against the root
+	for _, sans := range [][]string{{"api.local"}, {"local"}, {"web.app.test", "127.0.0.1"}, {"10.0.0.1"}} {
+		response, err := ca.IssueServiceCertificateV2(CertRequestV2{ServiceName: "svc", SANs: sans})
+		if err != nil {
+			t.Errorf("Expected %v to be issued, got %v", sans, err)
+			continue
+		}
+		block, _ := pem.Decode([]byte(response.Certificate))
+		leaf, _ := x509.ParseCertificate(block.Bytes)
+		if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots}); err != nil {
+			t.Errorf("Expected %v to verify, got %v", sans, err)
+		}
+	}
+
+	// Names outside the constraints are refused at issuance
+	for _, sans := range [][]string{{"example.com"}, {"api.local", "evil.com"}, {"db.secret.local"}, {"notlocal"}} {
+		if _, err := ca.IssueServiceCertificateV2(CertRequestV2{ServiceName: "svc", SANs: sans}); !errors.Is(err, ErrNameNotPermitted) {
+			t.Errorf("Expected ErrNameNotPermitted for %v, got %v", sans, err)
+		}
+	}
+	if _, err := ca.IssueServiceCertificate(CertRequest{ServiceName: "svc", Domains: []string{"example.com"}}); !errors.Is(err, ErrNameNotPermitted) {
+		t.Errorf("Expected ErrNameNotPermitted for the V1 API, got %v", err)
+	}
+}
+
+func TestNameConstraintsEnforcedByVerifiers(t *testing.T) {
+	config := DefaultCAConfig()
+	config.KeySize = 2048
+	config.PermittedDNSDomains = []string{"local"}
+
+	ca, err := NewCA(config)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+
+	// A certificate signed with the root key directly, as with a leaked key,
+	// is rejected by clients
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		DNSNames:     []string{"bank.example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Certificate(), key.Public(), ca.privateKey)
+	if err != nil {
+		t.Fatalf("Failed to sign certificate: %v", err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Certificate())
+	_, err = leaf.Verify(x509.VerifyOptions{Roots: roots})
+	var invalid x509.CertificateInvalidError
+	if !errors.As(err, &invalid) || invalid.Reason != x509.CANotAuthorizedForThisName {
+		t.Errorf("Expected CANotAuthorizedForThisName, got %v", err)
+	}
+}
+
+func TestNormalizeConstraintDomains(t *testing.T) {
+	domains, err := normalizeConstraintDomains([]string{" Local ", ".test"})
+	if err != nil || len(domains) != 2 || domains[0] != "local" || domains[1] != ".test" {
+		t.Errorf("Unexpected normalization: %v, %v", domains, err)
+	}
+
+	for _, domain := range []string{"", "*.local", "a..local", "local.", "https://local", "user@local"} {
+		if _, err := normalizeConstraintDomains([]string{domain}); err == nil {
+			t.Errorf("Expected an error for %q", domain)
+		}
+	}
+
+	config := DefaultCAConfig()
+	config.PermittedDNSDomains = []string{"*.local"}
+	if _, err := NewCA(config); err == nil {
+		t.Error("Expected NewCA to reject a wildcard constraint")
+	}
+}
+
+func TestMatchDomainConstraint(t *testing.T) {
+	cases := []struct {
+		name, constraint string
+		want             bool
+	}{
+		{"local", "local", true},
+		{"api.local", "local", true},
+		{"API.Local", "local", true},
+		{"*.api.local", "local", true},
+		{"notlocal", "local", false},
+		{"local", ".local", false},
+		{"api.local", ".local", true},
+	}
+	for _, c := range cases {
+		if got := matchDomainConstraint(c.name, c.constraint); got != c.want {
+			t.Errorf("matchDomainConstraint(%q, %q) = %t, want %t", c.name, c.constraint, got, c.want)
+		}
+	}
+}
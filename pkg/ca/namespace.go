@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// ErrInvalidNamespace is returned for a malformed namespace API key mapping
+var ErrInvalidNamespace = errors.New("invalid namespace")
+
+// namespacePattern matches namespace names: lowercase letters, digits, and
+// dashes, starting with a letter or digit
+var namespacePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// namespaceContextKey carries a request's namespace in its context
+type namespaceContextKey struct{}
+
+// validateNamespaceKeys checks a NamespaceAPIKeys mapping. Keys must be
+// non-empty and distinct from the admin API key.
+func validateNamespaceKeys(keys map[string]string, adminKey string) error {
+	for key, namespace := range keys {
+		if key == "" {
+			return fmt.Errorf("%w: empty API key for namespace %q", ErrInvalidNamespace, namespace)
+		}
+		if key == adminKey {
+			return fmt.Errorf("%w: API key for namespace %q is the admin API key", ErrInvalidNamespace, namespace)
+		}
+		if !namespacePattern.MatchString(namespace) {
+			return fmt.Errorf("%w: %q must be lowercase letters, digits, and dashes", ErrInvalidNamespace, namespace)
+		}
+	}
+	return nil
+}
+
+// requestNamespace returns the namespace a request is scoped to. ok is false
+// for admin requests, which see every namespace.
+func requestNamespace(r *http.Request) (namespace string, ok bool) {
+	namespace, ok = r.Context().Value(namespaceContextKey{}).(string)
+	return namespace, ok
+}
+
+// visibleTo reports whether the request may see a certificate
+func visibleTo(r *http.Request, cert *IssuedCert) bool {
+	namespace, scoped := requestNamespace(r)
+	return !scoped || cert.Namespace == namespace
+}
+
+// visibleCertificates filters certificates down to those the request may see
+func visibleCertificates(r *http.Request, certs []*IssuedCert) []*IssuedCert {
+	if _, scoped := requestNamespace(r); !scoped {
+		return certs
+	}
+	visible := make([]*IssuedCert, 0, len(certs))
+	for _, cert := range certs {
+		if visibleTo(r, cert) {
+			visible = append(visible, cert)
+		}
+	}
+	return visible
+}
+
+// authenticateAdmin guards admin endpoints: they need the admin API key or
+// a bearer token, so without either configured they are refused instead
+// of open to anyone like the other endpoints
+func (s *Server) authenticateAdmin(next http.Handler) http.Handler {
+	if s.guiAPIKey == "" && s.tokens == nil {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Forbidden: configure GUIAPIKey or TokenAuth to use admin endpoints", http.StatusForbidden)
+		})
+	}
+	return s.authenticate(next)
+}
+
+// authenticate wraps withAuth with namespace API keys: a namespace key scopes
+// the request to its namespace, while the admin API key and bearer tokens
+// see every namespace
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	admin := withAuth(s.guiAPIKey, s.tokens, next)
+	if len(s.namespaceKeys) == 0 {
+		return admin
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if namespace, ok := s.namespaceKeys[requestAPIKey(r)]; ok {
+			ctx := context.WithValue(r.Context(), namespaceContextKey{}, namespace)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+		if s.guiAPIKey == "" && s.tokens == nil {
+			writeUnauthorized(w, "Unauthorized: Invalid or missing API key")
+			return
+		}
+		admin.ServeHTTP(w, r)
+	})
+}
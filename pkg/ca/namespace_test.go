@@ -0,0 +1,152 @@
+package ca
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNamespaceKeysValidation(t *testing.T) {
+	cases := map[string]map[string]string{
+		"EmptyKey":     {"": "team-a"},
+		"AdminKey":     {"admin": "team-a"},
+		"Uppercase":    {"key": "Team-A"},
+		"LeadingDash":  {"key": "-team"},
+		"EmptyName":    {"key": ""},
+		"PathSegments": {"key": "team/a"},
+	}
+	for name, keys := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewServer(&ServerConfig{GUIAPIKey: "admin", NamespaceAPIKeys: keys})
+			if !errors.Is(err, ErrInvalidNamespace) {
+				t.Errorf("Expected ErrInvalidNamespace, got %v", err)
+			}
+		})
+	}
+}
+
+func TestServerNamespaces(t *testing.T) {
+	server, err := NewServer(&ServerConfig{
+		CAConfig:         DefaultCAConfig(),
+		EnableGUI:        true,
+		GUIAPIKey:        "admin",
+		NamespaceAPIKeys: map[string]string{"key-a": "team-a", "key-b": "team-b"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/cert", server.authenticate(http.HandlerFunc(server.handleCertRequest)))
+	mux.Handle("/cert/", server.authenticate(http.HandlerFunc(server.gui.HandleDownloadCert)))
+	mux.Handle("/ui/certs-table", server.authenticate(http.HandlerFunc(server.gui.HandleCertsTable)))
+	mux.Handle("/ca-key", server.authenticate(http.HandlerFunc(server.gui.HandleDownloadCAKey)))
+
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do(http.MethodPost, "/cert", "key-a", `{"service_name":"alpha","sans":["alpha.internal"]}`); rr.Code != http.StatusOK {
+		t.Fatalf("V2 request for team-a failed: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodPost, "/cert", "key-b", `{"service_name":"beta","domains":["beta.internal"]}`); rr.Code != http.StatusOK {
+		t.Fatalf("V1 request for team-b failed: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodPost, "/cert", "admin", `{"service_name":"shared","sans":["shared.internal"]}`); rr.Code != http.StatusOK {
+		t.Fatalf("Admin request failed: %d %s", rr.Code, rr.Body.String())
+	}
+
+	serials := make(map[string]string)
+	for _, cert := range server.ca.GetIssuedCertificates() {
+		serials[cert.ServiceName] = cert.SerialNumber
+		want := map[string]string{"alpha": "team-a", "beta": "team-b", "shared": ""}[cert.ServiceName]
+		if cert.Namespace != want {
+			t.Errorf("Expected %s in namespace %q, got %q", cert.ServiceName, want, cert.Namespace)
+		}
+	}
+
+	t.Run("Listings", func(t *testing.T) {
+		cases := []struct {
+			key      string
+			visible  []string
+			filtered []string
+		}{
+			{"key-a", []string{"alpha"}, []string{"beta", "shared"}},
+			{"key-b", []string{"beta"}, []string{"alpha", "shared"}},
+			{"admin", []string{"alpha", "beta", "shared", "team-a"}, nil},
+		}
+		for _, c := range cases {
+			body := do(http.MethodGet, "/ui/certs-table", c.key, "").Body.String()
+			for _, name := range c.visible {
+				if !strings.Contains(body, name) {
+					t.Errorf("%s: expected %s in the table", c.key, name)
+				}
+			}
+			for _, name := range c.filtered {
+				if strings.Contains(body, name) {
+					t.Errorf("%s: expected %s to be filtered out", c.key, name)
+				}
+			}
+		}
+	})
+
+	t.Run("Downloads", func(t *testing.T) {
+		if rr := do(http.MethodGet, "/cert/"+serials["alpha"], "key-a", ""); rr.Code != http.StatusOK {
+			t.Errorf("Expected team-a to download its certificate, got %d", rr.Code)
+		}
+		if rr := do(http.MethodGet, "/cert/"+serials["alpha"], "key-b", ""); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected team-b not to see team-a's certificate, got %d", rr.Code)
+		}
+		if rr := do(http.MethodGet, "/cert/"+serials["alpha"], "admin", ""); rr.Code != http.StatusOK {
+			t.Errorf("Expected admin to download any certificate, got %d", rr.Code)
+		}
+	})
+
+	t.Run("CAKey", func(t *testing.T) {
+		if rr := do(http.MethodGet, "/ca-key", "key-a", ""); rr.Code != http.StatusForbidden {
+			t.Errorf("Expected a namespace key to be refused the CA key, got %d", rr.Code)
+		}
+		if rr := do(http.MethodGet, "/ca-key", "admin", ""); rr.Code != http.StatusOK {
+			t.Errorf("Expected admin to download the CA key, got %d", rr.Code)
+		}
+	})
+
+	t.Run("UnknownKey", func(t *testing.T) {
+		if rr := do(http.MethodGet, "/ui/certs-table", "wrong", ""); rr.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for an unknown key, got %d", rr.Code)
+		}
+	})
+}
+
+func TestServerNamespacesWithoutAdmin(t *testing.T) {
+	server, err := NewServer(&ServerConfig{
+		CAConfig:         DefaultCAConfig(),
+		NamespaceAPIKeys: map[string]string{"key-a": "team-a"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	if !server.requiresAuth() {
+		t.Error("Expected namespace keys to require authentication")
+	}
+
+	handler := server.authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for key, want := range map[string]int{"": http.StatusUnauthorized, "wrong": http.StatusUnauthorized, "key-a": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodGet, "/ca", nil)
+		req.Header.Set("X-API-Key", key)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("Key %q: expected %d, got %d", key, want, rr.Code)
+		}
+	}
+}
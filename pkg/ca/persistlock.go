@@ -0,0 +1,22 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"path/filepath"
+
+	"github.com/nzions/sharedgolibs/internal/fileutil"
+)
+
+// LockFileName is the lock file in a persistence directory. CA instances
+// sharing the directory (e.g. containers mounting one Docker volume) hold an
+// exclusive lock on it while they allocate a serial number and update
+// cert-store.json and index.json. External tooling that edits index.json
+// should take the same lock (flock(2) on Unix).
+const LockFileName = ".lock"
+
+// lockPersistDir takes the exclusive persistence directory lock, blocking
+// until other instances release it, and returns the function that releases it
+func lockPersistDir(persistDir string) (func(), error) {
+	return fileutil.LockPath(filepath.Join(persistDir, LockFileName))
+}
@@ -0,0 +1,142 @@
+package ca
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// startPinnedServer serves HTTPS with a leaf from the CA, sending the CA
+// certificate in the chain
+func startPinnedServer(t *testing.T, authority *CA) (*httptest.Server, *tls.Certificate) {
+	t.Helper()
+	certPEM, keyPEM, err := authority.GenerateCertificateV2("pinned", []string{"localhost", "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPair, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPair.Certificate = append(keyPair.Certificate, authority.Certificate().Raw)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{keyPair}}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server, &keyPair
+}
+
+func getWith(transport *http.Transport, url string) error {
+	resp, err := (&http.Client{Transport: transport}).Get(url)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func TestPinnedTransport(t *testing.T) {
+	authority := startDepTLSCA(t)
+	server, keyPair := startPinnedServer(t, authority)
+	leafPin := SPKIPin(keyPair.Leaf)
+	caPin := SPKIPin(authority.Certificate())
+
+	otherConfig := DefaultCAConfig()
+	otherConfig.KeySize = 2048
+	otherConfig.CommonName = "Other CA"
+	otherCA, err := NewCA(otherConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPin := SPKIPin(otherCA.Certificate())
+
+	tests := []struct {
+		name    string
+		withCA  bool
+		pins    []string
+		wantErr bool
+	}{
+		{"leaf pin", false, []string{leafPin}, false},
+		{"CA pin", false, []string{otherPin, caPin}, false},
+		{"wrong pin", false, []string{otherPin}, true},
+		{"leaf pin with CA", true, []string{leafPin}, false},
+		{"wrong pin with CA", true, []string{otherPin}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTransport := PinnedTransport
+			if tt.withCA {
+				newTransport = PinnedTransportWithCA
+			}
+			transport, err := newTransport(tt.pins...)
+			if err != nil {
+				t.Fatalf("Failed to create transport: %v", err)
+			}
+			err = getWith(transport, server.URL)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr && !errors.Is(err, ErrPinMismatch) {
+				t.Errorf("Expected ErrPinMismatch, got %v", err)
+			}
+		})
+	}
+
+	t.Run("CA pin with foreign leaf", func(t *testing.T) {
+		// A pinned CA certificate in the chain doesn't vouch for a leaf it didn't sign
+		foreignServer, _ := startPinnedServer(t, otherCA)
+		transport, err := PinnedTransport(otherPin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := getWith(transport, foreignServer.URL); err != nil {
+			t.Fatalf("Expected the other CA's own leaf to pass: %v", err)
+		}
+		transport, err = PinnedTransport(caPin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := getWith(transport, foreignServer.URL); !errors.Is(err, ErrPinMismatch) {
+			t.Errorf("Expected ErrPinMismatch, got %v", err)
+		}
+	})
+}
+
+func TestDecodePin(t *testing.T) {
+	sum := sha256.Sum256([]byte("key"))
+	encoded := hex.EncodeToString(sum[:])
+	var colons []string
+	for i := 0; i < len(encoded); i += 2 {
+		colons = append(colons, strings.ToUpper(encoded[i:i+2]))
+	}
+
+	for _, pin := range []string{
+		encoded,
+		strings.Join(colons, ":"),
+		"sha256//LHDhK3oGRvkiefQnx7OOczTY5Tic/xZ6HcMOc/gmtoM=",
+		" LHDhK3oGRvkiefQnx7OOczTY5Tic/xZ6HcMOc/gmtoM=",
+	} {
+		digest, err := decodePin(pin)
+		if err != nil {
+			t.Errorf("decodePin(%q) failed: %v", pin, err)
+		} else if digest != sum {
+			t.Errorf("decodePin(%q) = %x, want %x", pin, digest, sum)
+		}
+	}
+
+	for _, pin := range []string{"", "abcd", "not base64!"} {
+		if _, err := decodePin(pin); err == nil {
+			t.Errorf("Expected decodePin(%q) to fail", pin)
+		}
+	}
+	if _, err := PinnedTransport(); err == nil {
+		t.Error("Expected PinnedTransport to require a pin")
+	}
+}
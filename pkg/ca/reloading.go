@@ -0,0 +1,356 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ReloadingCertificate serves a service certificate from the CA and
+// re-issues it when it nears expiry or the process receives SIGHUP, so
+// long-running servers pick up fresh certificates without restarting. See
+// NewShortLivedCertificate for certificates valid for minutes or hours.
+//
+// Environment Variables Used:
+//   - SGL_CA (required): CA server URL for certificate requests
+//   - SGL_CA_API_KEY (optional): API key for CA server authentication
+//   - SGL_CA_TOKEN (optional): Bearer token for CA server authentication
+type ReloadingCertificate struct {
+	serviceName string
+	sans        []string
+
+	validityMinutes int  // Requested validity (0 = the CA's default)
+	autoRenew       bool // Renew on a timer, not only on handshakes
+
+	// Overridable for tests
+	request     func(req *CertRequestV2) (*CertResponse, error)
+	reportUsage func(serial string) error
+	now         func() time.Time
+
+	reloadMutex sync.Mutex // Serializes requests to the CA
+
+	mutex    sync.RWMutex
+	cert     *tls.Certificate
+	renewAt  time.Time // Renew in the background after this time
+	renewing bool
+
+	pingInterval time.Duration // Usage ping interval (0 = disabled)
+	nextPing     time.Time
+
+	onRenewal  func(RenewalEvent)
+	stats      RenewalStats
+	renewTimer *time.Timer
+	closed     bool
+
+	signals chan os.Signal
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewReloadingTLSConfig returns a TLS config whose GetCertificate always
+// serves the latest certificate for serviceName, re-issuing it from the CA
+// on expiry or SIGHUP. Use it for servers not created by
+// CreateSecureHTTPSServerV2, e.g. gRPC servers or custom listeners.
+func NewReloadingTLSConfig(serviceName string, sans []string) (*tls.Config, error) {
+	rc, err := NewReloadingCertificate(serviceName, sans)
+	if err != nil {
+		return nil, err
+	}
+	return rc.TLSConfig(), nil
+}
+
+// NewReloadingCertificate requests the initial certificate and starts
+// watching for SIGHUP. Call Close to stop watching.
+func NewReloadingCertificate(serviceName string, sans []string) (*ReloadingCertificate, error) {
+	rc := &ReloadingCertificate{
+		serviceName: serviceName,
+		sans:        sans,
+		request:     requestCertificateV2,
+		reportUsage: ReportCertificateUsage,
+		now:         time.Now,
+	}
+	if err := rc.renew(RenewalReasonStart); err != nil {
+		return nil, err
+	}
+	rc.watchSignals()
+	return rc, nil
+}
+
+// Reasons a ReloadingCertificate requests a certificate, in RenewalEvent
+const (
+	RenewalReasonStart   = "start"   // The first certificate
+	RenewalReasonRenewal = "renewal" // Two thirds of the lifetime passed
+	RenewalReasonExpired = "expired" // A handshake found it expired
+	RenewalReasonSignal  = "signal"  // SIGHUP
+	RenewalReasonReload  = "reload"  // Reload was called
+)
+
+// RenewalEvent reports a certificate request of a ReloadingCertificate
+type RenewalEvent struct {
+	ServiceName string
+	Reason      string
+	Serial      string    // Serial number of the new certificate, hex
+	NotAfter    time.Time // Expiry of the new certificate
+	Err         error     // The request failed; the previous certificate is still served
+}
+
+// RenewalStats counts the certificate requests of a ReloadingCertificate
+type RenewalStats struct {
+	ServiceName string    `json:"service_name"`
+	Serial      string    `json:"serial"`
+	NotAfter    time.Time `json:"not_after"`
+	RenewAt     time.Time `json:"renew_at"`
+	Renewals    uint64    `json:"renewals"` // Certificates installed after the first
+	Failures    uint64    `json:"failures"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// OnRenewal calls fn after each certificate request, successful or not,
+// e.g. to log renewals with the server's logger. fn must not block.
+func (rc *ReloadingCertificate) OnRenewal(fn func(RenewalEvent)) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	rc.onRenewal = fn
+}
+
+// Stats returns the served certificate and the renewal counters
+func (rc *ReloadingCertificate) Stats() RenewalStats {
+	rc.mutex.RLock()
+	defer rc.mutex.RUnlock()
+	return rc.stats
+}
+
+// TLSConfig returns a new TLS config serving the reloading certificate
+func (rc *ReloadingCertificate) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: rc.GetCertificate,
+	}
+}
+
+// Reload re-issues the certificate now. On failure the previous
+// certificate keeps being served.
+func (rc *ReloadingCertificate) Reload() error {
+	return rc.renew(RenewalReasonReload)
+}
+
+// renew re-issues the certificate now for reason
+func (rc *ReloadingCertificate) renew(reason string) error {
+	rc.reloadMutex.Lock()
+	defer rc.reloadMutex.Unlock()
+	return rc.reload(reason)
+}
+
+// reload requests and installs a new certificate, reporting the outcome to
+// the stats and OnRenewal; reloadMutex must be held
+func (rc *ReloadingCertificate) reload(reason string) error {
+	cert, err := rc.issue()
+	event := RenewalEvent{ServiceName: rc.serviceName, Reason: reason, Err: err}
+	if err != nil {
+		rc.recordRenewal(event)
+		return err
+	}
+
+	// Renew once two thirds of the lifetime has passed
+	leaf := cert.Leaf
+	lifetime := leaf.NotAfter.Sub(leaf.NotBefore)
+	renewAt := leaf.NotAfter.Add(-lifetime / 3)
+
+	rc.mutex.Lock()
+	rc.cert = cert
+	rc.renewAt = renewAt
+	rc.mutex.Unlock()
+
+	log.Printf("[ca] Loaded certificate for %s (serial %s, expires %s)", rc.serviceName, leaf.SerialNumber, leaf.NotAfter.Format(time.RFC3339))
+	rc.scheduleRenewal(renewAt.Sub(rc.now()))
+	event.Serial = leaf.SerialNumber.Text(16)
+	event.NotAfter = leaf.NotAfter
+	rc.recordRenewal(event)
+	return nil
+}
+
+// recordRenewal counts a certificate request and passes it to OnRenewal
+func (rc *ReloadingCertificate) recordRenewal(event RenewalEvent) {
+	rc.mutex.Lock()
+	stats := &rc.stats
+	stats.ServiceName = rc.serviceName
+	if event.Err != nil {
+		stats.Failures++
+		stats.LastError = event.Err.Error()
+	} else {
+		if stats.Serial != "" {
+			stats.Renewals++
+		}
+		stats.Serial, stats.NotAfter, stats.RenewAt = event.Serial, event.NotAfter, rc.renewAt
+	}
+	onRenewal := rc.onRenewal
+	rc.mutex.Unlock()
+
+	if onRenewal != nil {
+		onRenewal(event)
+	}
+}
+
+// issue requests a certificate from the CA and parses it
+func (rc *ReloadingCertificate) issue() (*tls.Certificate, error) {
+	resp, err := rc.request(&CertRequestV2{
+		ServiceName:     rc.serviceName,
+		SANs:            rc.sans,
+		ValidityMinutes: rc.validityMinutes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to request certificate: %w", err)
+	}
+
+	cert, err := tls.X509KeyPair([]byte(resp.Certificate), []byte(resp.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	// Leaf is only filled in by X509KeyPair without GODEBUG=x509keypairleaf=0
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+	}
+	return &cert, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate. An expired certificate
+// is re-issued before the handshake continues; one in its renewal window is
+// re-issued in the background while the current one is still served.
+func (rc *ReloadingCertificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	rc.mutex.RLock()
+	cert, renewAt := rc.cert, rc.renewAt
+	rc.mutex.RUnlock()
+
+	switch {
+	case !rc.now().Before(cert.Leaf.NotAfter):
+		return rc.reloadExpired()
+	case !rc.now().Before(renewAt):
+		rc.renewInBackground()
+	}
+
+	rc.pingIfDue(cert)
+	return cert, nil
+}
+
+// PingUsage reports the served certificate to the CA as in use (see
+// ReportCertificateUsage) on the first handshake of every interval, so the
+// CA can tell certificates in service from abandoned ones. Idle servers
+// send no pings. An interval of 0 stops pinging.
+func (rc *ReloadingCertificate) PingUsage(interval time.Duration) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	rc.pingInterval = interval
+	rc.nextPing = time.Time{}
+}
+
+// pingIfDue reports cert in use in the background if a ping is due
+func (rc *ReloadingCertificate) pingIfDue(cert *tls.Certificate) {
+	now := rc.now()
+	rc.mutex.Lock()
+	if rc.pingInterval <= 0 || now.Before(rc.nextPing) {
+		rc.mutex.Unlock()
+		return
+	}
+	rc.nextPing = now.Add(rc.pingInterval)
+	rc.mutex.Unlock()
+
+	serial := cert.Leaf.SerialNumber.Text(16)
+	go func() {
+		if err := rc.reportUsage(serial); err != nil {
+			log.Printf("[ca] Usage ping for %s (serial %s) failed: %v", rc.serviceName, serial, err)
+		}
+	}()
+}
+
+// reloadExpired re-issues an expired certificate once, however many
+// handshakes are waiting on it
+func (rc *ReloadingCertificate) reloadExpired() (*tls.Certificate, error) {
+	rc.reloadMutex.Lock()
+	defer rc.reloadMutex.Unlock()
+
+	rc.mutex.RLock()
+	cert := rc.cert
+	rc.mutex.RUnlock()
+	if rc.now().Before(cert.Leaf.NotAfter) {
+		return cert, nil // Another handshake already re-issued it
+	}
+
+	if err := rc.reload(RenewalReasonExpired); err != nil {
+		return nil, fmt.Errorf("certificate for %s expired and could not be re-issued: %w", rc.serviceName, err)
+	}
+
+	rc.mutex.RLock()
+	defer rc.mutex.RUnlock()
+	return rc.cert, nil
+}
+
+// renewInBackground starts a single background renewal
+func (rc *ReloadingCertificate) renewInBackground() {
+	rc.mutex.Lock()
+	if rc.renewing {
+		rc.mutex.Unlock()
+		return
+	}
+	rc.renewing = true
+	rc.mutex.Unlock()
+
+	go func() {
+		defer func() {
+			rc.mutex.Lock()
+			rc.renewing = false
+			rc.mutex.Unlock()
+		}()
+		if err := rc.renew(RenewalReasonRenewal); err != nil {
+			log.Printf("[ca] Certificate renewal for %s failed: %v", rc.serviceName, err)
+		}
+	}()
+}
+
+// watchSignals re-issues the certificate on each SIGHUP
+func (rc *ReloadingCertificate) watchSignals() {
+	rc.signals = make(chan os.Signal, 1)
+	rc.done = make(chan struct{})
+	signal.Notify(rc.signals, syscall.SIGHUP)
+
+	go func() {
+		for {
+			select {
+			case <-rc.signals:
+				log.Printf("[ca] SIGHUP received, re-issuing certificate for %s", rc.serviceName)
+				if err := rc.renew(RenewalReasonSignal); err != nil {
+					log.Printf("[ca] Certificate reload for %s failed: %v", rc.serviceName, err)
+				}
+			case <-rc.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops watching for SIGHUP and renewing on a timer. The last
+// certificate keeps being served.
+func (rc *ReloadingCertificate) Close() {
+	rc.once.Do(func() {
+		signal.Stop(rc.signals)
+		close(rc.done)
+
+		rc.mutex.Lock()
+		rc.closed = true
+		if rc.renewTimer != nil {
+			rc.renewTimer.Stop()
+		}
+		rc.mutex.Unlock()
+		unregisterReloadingCertificate(rc)
+	})
+}
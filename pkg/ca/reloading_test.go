@@ -0,0 +1,182 @@
+package ca
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// newTestReloadingCertificate returns a ReloadingCertificate issuing from a
+// local CA and the number of certificates it has requested
+func newTestReloadingCertificate(t *testing.T) (*ReloadingCertificate, *atomic.Int32) {
+	t.Helper()
+
+	config := DefaultCAConfig()
+	config.KeySize = 2048
+	authority, err := NewCA(config)
+	if err != nil {
+		t.Fatalf("NewCA failed: %v", err)
+	}
+
+	var requests atomic.Int32
+	rc := &ReloadingCertificate{
+		serviceName: "reload-test",
+		sans:        []string{"localhost", "127.0.0.1"},
+		request: func(req *CertRequestV2) (*CertResponse, error) {
+			requests.Add(1)
+			return authority.IssueServiceCertificateV2(*req)
+		},
+		now: time.Now,
+	}
+	if err := rc.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	return rc, &requests
+}
+
+// waitForRequests waits until at least n certificates have been requested
+func waitForRequests(t *testing.T, requests *atomic.Int32, n int32) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for requests.Load() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d certificate requests, got %d", n, requests.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitForRenewal waits until a background renewal has finished, so the
+// test can swap the clock and request function it calls
+func waitForRenewal(t *testing.T, rc *ReloadingCertificate) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rc.mutex.RLock()
+		renewing := rc.renewing
+		rc.mutex.RUnlock()
+		if !renewing {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the background renewal to finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReloadingCertificateExpiry(t *testing.T) {
+	rc, requests := newTestReloadingCertificate(t)
+
+	first, err := rc.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("GetCertificate failed: %v", err)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("Expected a fresh certificate to be served as is, got %d requests", requests.Load())
+	}
+
+	// Inside the renewal window the current certificate is served while a
+	// new one is issued in the background
+	rc.now = func() time.Time { return rc.renewAt.Add(time.Second) }
+	served, err := rc.GetCertificate(nil)
+	if err != nil || served != first {
+		t.Errorf("Expected current certificate during background renewal, got %v", err)
+	}
+	waitForRequests(t, requests, 2)
+	waitForRenewal(t, rc) // It still reads rc.now
+
+	// An expired certificate is re-issued before the handshake continues
+	rc.mutex.RLock()
+	notAfter := rc.cert.Leaf.NotAfter
+	rc.mutex.RUnlock()
+	rc.now = func() time.Time { return notAfter.Add(time.Second) }
+	before := requests.Load()
+	if _, err := rc.GetCertificate(nil); err != nil {
+		t.Fatalf("GetCertificate failed: %v", err)
+	}
+	if requests.Load() != before+1 {
+		t.Errorf("Expected synchronous re-issue of an expired certificate")
+	}
+
+	// A failing CA keeps the last certificate for reloads
+	waitForRenewal(t, rc)
+	rc.request = func(*CertRequestV2) (*CertResponse, error) { return nil, ErrCARequest }
+	rc.mutex.RLock()
+	current := rc.cert
+	rc.mutex.RUnlock()
+	if err := rc.Reload(); err == nil {
+		t.Error("Expected Reload error from failing CA")
+	}
+	if rc.cert != current {
+		t.Error("Expected previous certificate kept after a failed reload")
+	}
+}
+
+func TestReloadingCertificateSIGHUP(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGHUP is not delivered on Windows")
+	}
+
+	rc, requests := newTestReloadingCertificate(t)
+	rc.watchSignals()
+	defer rc.Close()
+
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := process.Signal(syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	waitForRequests(t, requests, 2)
+}
+
+func TestNewReloadingTLSConfig(t *testing.T) {
+	config := DefaultCAConfig()
+	config.KeySize = 2048
+	authority, err := NewCA(config)
+	if err != nil {
+		t.Fatalf("NewCA failed: %v", err)
+	}
+	caServer := httptest.NewServer(http.HandlerFunc((&Server{ca: authority}).handleCertRequest))
+	defer caServer.Close()
+
+	t.Setenv("SGL_CA", caServer.URL)
+	t.Setenv("SGL_CA_API_KEY", "")
+	tlsConfig, err := NewReloadingTLSConfig("reload-test", []string{"localhost", "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("NewReloadingTLSConfig failed: %v", err)
+	}
+
+	// httptest's StartTLS would add its own certificate, which takes
+	// precedence over GetCertificate for clients not sending SNI
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(authority.Certificate())
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	resp, err := client.Get("https://" + listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Request over reloading TLS config failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200, got %d", resp.StatusCode)
+	}
+}
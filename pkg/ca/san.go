@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+// SANType identifies the kind of a Subject Alternative Name
+type SANType string
+
+const (
+	SANTypeDNS   SANType = "dns"
+	SANTypeIP    SANType = "ip"
+	SANTypeURI   SANType = "uri"
+	SANTypeEmail SANType = "email"
+)
+
+// ErrInvalidSAN is returned when a Subject Alternative Name fails validation
+var ErrInvalidSAN = fmt.Errorf("invalid subject alternative name")
+
+// ClassifySAN returns the type of a SAN string. Anything containing "://"
+// is a URI (e.g. spiffe://cluster/ns/app), anything containing "@" is an
+// email address, parseable IPs are IPs, and everything else is a DNS name.
+func ClassifySAN(san string) SANType {
+	switch {
+	case strings.Contains(san, "://"):
+		return SANTypeURI
+	case strings.Contains(san, "@"):
+		return SANTypeEmail
+	case net.ParseIP(san) != nil:
+		return SANTypeIP
+	default:
+		return SANTypeDNS
+	}
+}
+
+// SANSet holds SANs split by type, ready to be placed in a certificate template
+type SANSet struct {
+	DNSNames       []string
+	IPAddresses    []net.IP
+	URIs           []*url.URL
+	EmailAddresses []string
+}
+
+// ParseSANs classifies and validates a mixed list of SANs. Duplicate IPs are
+// dropped. A certificate may carry at most one SPIFFE ID, as required by the
+// X.509-SVID specification.
+func ParseSANs(sans []string) (*SANSet, error) {
+	set := &SANSet{}
+	seenIPs := make(map[string]bool)
+	spiffeIDs := 0
+
+	for _, san := range sans {
+		if strings.TrimSpace(san) != san || san == "" {
+			return nil, fmt.Errorf("%w: %q has leading/trailing whitespace or is empty", ErrInvalidSAN, san)
+		}
+
+		switch ClassifySAN(san) {
+		case SANTypeURI:
+			u, err := parseURISAN(san)
+			if err != nil {
+				return nil, err
+			}
+			if u.Scheme == "spiffe" {
+				spiffeIDs++
+				if spiffeIDs > 1 {
+					return nil, fmt.Errorf("%w: only one SPIFFE ID is allowed per certificate", ErrInvalidSAN)
+				}
+			}
+			set.URIs = append(set.URIs, u)
+		case SANTypeEmail:
+			addr, err := mail.ParseAddress(san)
+			if err != nil || addr.Address != san {
+				return nil, fmt.Errorf("%w: %q is not a valid email address", ErrInvalidSAN, san)
+			}
+			set.EmailAddresses = append(set.EmailAddresses, san)
+		case SANTypeIP:
+			if !seenIPs[san] {
+				set.IPAddresses = append(set.IPAddresses, net.ParseIP(san))
+				seenIPs[san] = true
+			}
+		default:
+			if strings.ContainsAny(san, " \t/") {
+				return nil, fmt.Errorf("%w: %q is not a valid DNS name", ErrInvalidSAN, san)
+			}
+			set.DNSNames = append(set.DNSNames, san)
+		}
+	}
+
+	return set, nil
+}
+
+// parseURISAN validates a URI SAN, applying the SPIFFE ID rules to spiffe:// URIs
+func parseURISAN(san string) (*url.URL, error) {
+	u, err := url.Parse(san)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("%w: %q is not an absolute URI", ErrInvalidSAN, san)
+	}
+
+	if u.Scheme != "spiffe" {
+		return u, nil
+	}
+
+	// See https://github.com/spiffe/spiffe/blob/main/standards/SPIFFE-ID.md
+	switch {
+	case u.User != nil || u.Port() != "":
+		return nil, fmt.Errorf("%w: SPIFFE ID %q must not contain userinfo or a port", ErrInvalidSAN, san)
+	case u.RawQuery != "" || u.Fragment != "":
+		return nil, fmt.Errorf("%w: SPIFFE ID %q must not contain a query or fragment", ErrInvalidSAN, san)
+	case strings.ToLower(u.Host) != u.Host:
+		return nil, fmt.Errorf("%w: SPIFFE trust domain %q must be lowercase", ErrInvalidSAN, u.Host)
+	case u.Path == "" || u.Path == "/":
+		return nil, fmt.Errorf("%w: SPIFFE ID %q must include a workload path", ErrInvalidSAN, san)
+	case strings.HasSuffix(u.Path, "/") || strings.Contains(u.Path, "//"):
+		return nil, fmt.Errorf("%w: SPIFFE ID %q has an empty path segment", ErrInvalidSAN, san)
+	}
+
+	return u, nil
+}
@@ -0,0 +1,393 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"bufio"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Certificate stream timing. Overridable for tests.
+var (
+	// secretKeepAlive is how often GET /sds sends a keepalive and checks
+	// whether the root was rotated
+	secretKeepAlive = 30 * time.Second
+
+	// Reconnect backoff for WatchCertificate, reset after each update
+	secretRetryMin = time.Second
+	secretRetryMax = 30 * time.Second
+)
+
+// SecretUpdate is a certificate pushed to a GET /sds subscriber: the
+// initial certificate, then one per renewal or root rotation. Like an
+// Envoy SDS resource, VersionInfo changes with every update.
+type SecretUpdate struct {
+	Name        string    `json:"name"`         // Service name the certificate is for
+	VersionInfo string    `json:"version_info"` // Serial number of the certificate
+	Certificate string    `json:"certificate"`
+	PrivateKey  string    `json:"private_key"`
+	CACert      string    `json:"ca_cert"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	RenewAt     time.Time `json:"renew_at"` // When the server pushes the next certificate
+}
+
+// CertResponse returns the update's certificate, key, and CA, e.g. for
+// WriteCertFiles
+func (u *SecretUpdate) CertResponse() *CertResponse {
+	return &CertResponse{Certificate: u.Certificate, PrivateKey: u.PrivateKey, CACert: u.CACert}
+}
+
+// newSecretUpdate describes an issued certificate. It is renewed once two
+// thirds of its lifetime has passed, like ReloadingCertificate.
+func newSecretUpdate(name string, resp *CertResponse) (*SecretUpdate, error) {
+	block, _ := pem.Decode([]byte(resp.Certificate))
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM certificate", ErrCertParse)
+	}
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCertParse, err)
+	}
+
+	lifetime := leaf.NotAfter.Sub(leaf.NotBefore)
+	return &SecretUpdate{
+		Name:        name,
+		VersionInfo: fmt.Sprintf("%x", leaf.SerialNumber),
+		Certificate: resp.Certificate,
+		PrivateKey:  resp.PrivateKey,
+		CACert:      resp.CACert,
+		ExpiresAt:   leaf.NotAfter,
+		RenewAt:     leaf.NotAfter.Add(-lifetime / 3),
+	}, nil
+}
+
+// secretRequest reads a V2 certificate request from the GET /sds query:
+// service_name, sans (comma-separated or repeated), and the optional
+// validity_days, validity_minutes, key_algorithm, and template
+func secretRequest(query url.Values) (CertRequestV2, error) {
+	req := CertRequestV2{
+		ServiceName:  query.Get("service_name"),
+		KeyAlgorithm: KeyAlgorithm(query.Get("key_algorithm")),
+		Template:     query.Get("template"),
+	}
+	for _, value := range query["sans"] {
+		for _, san := range strings.Split(value, ",") {
+			if san = strings.TrimSpace(san); san != "" {
+				req.SANs = append(req.SANs, san)
+			}
+		}
+	}
+	if days := query.Get("validity_days"); days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return req, fmt.Errorf("%w: invalid validity_days %q", ErrInvalidCertRequest, days)
+		}
+		req.ValidityDays = n
+	}
+	if minutes := query.Get("validity_minutes"); minutes != "" {
+		n, err := strconv.Atoi(minutes)
+		if err != nil {
+			return req, fmt.Errorf("%w: invalid validity_minutes %q", ErrInvalidCertRequest, minutes)
+		}
+		req.ValidityMinutes = n
+	}
+
+	if req.ServiceName == "" {
+		return req, fmt.Errorf("%w: service_name is required", ErrInvalidCertRequest)
+	}
+	if len(req.SANs) == 0 {
+		return req, fmt.Errorf("%w: sans are required", ErrInvalidCertRequest)
+	}
+	return req, nil
+}
+
+// encodeQuery is the inverse of secretRequest
+func (req CertRequestV2) encodeQuery() string {
+	query := url.Values{
+		"service_name": {req.ServiceName},
+		"sans":         {strings.Join(req.SANs, ",")},
+	}
+	if req.ValidityDays != 0 {
+		query.Set("validity_days", strconv.Itoa(req.ValidityDays))
+	}
+	if req.ValidityMinutes != 0 {
+		query.Set("validity_minutes", strconv.Itoa(req.ValidityMinutes))
+	}
+	if req.KeyAlgorithm != "" {
+		query.Set("key_algorithm", string(req.KeyAlgorithm))
+	}
+	if req.Template != "" {
+		query.Set("template", req.Template)
+	}
+	return query.Encode()
+}
+
+// handleSecretStream serves GET /sds: a Server-Sent Events stream that
+// issues a certificate right away and pushes a new one ("secret" events)
+// when it nears expiry or the root is rotated. Failed renewals send an
+// "error" event and are retried at the next keepalive.
+func (s *Server) handleSecretStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	req, err := secretRequest(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if names := s.approvalRequired(req.SANs); len(names) > 0 {
+		http.Error(w, fmt.Sprintf("%v for %s: request it with POST /cert", ErrApprovalRequired, strings.Join(names, ", ")), http.StatusForbidden)
+		return
+	}
+	namespace, _ := requestNamespace(r)
+	issue := func() (*SecretUpdate, error) {
+		resp, err := s.ca.issueServiceCertificateV2(r.Context(), req, namespace)
+		if err != nil {
+			return nil, err
+		}
+		return newSecretUpdate(req.ServiceName, resp)
+	}
+
+	// Report a bad request as an HTTP status before the stream starts
+	current, err := issue()
+	if err != nil {
+		log.Printf("[ca] Failed to start certificate stream for %s: %v", req.ServiceName, err)
+		if errors.Is(err, ErrInvalidSAN) || errors.Is(err, ErrInvalidCertRequest) || errors.Is(err, ErrNameNotPermitted) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Certificate generation failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no") // Don't let nginx buffer the stream
+	log.Printf("[ca] Certificate stream opened for %s from %s", req.ServiceName, r.RemoteAddr)
+	defer log.Printf("[ca] Certificate stream closed for %s", req.ServiceName)
+
+	if writeSSE(w, "secret", current) != nil {
+		return
+	}
+	flusher.Flush()
+
+	s.streamRenewals(r.Context(), req.ServiceName, current, issue, streamSink{
+		send: func(update *SecretUpdate) error {
+			if err := writeSSE(w, "secret", update); err != nil {
+				return err
+			}
+			flusher.Flush()
+			return nil
+		},
+		keepAlive: func() error {
+			if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+				return err
+			}
+			flusher.Flush()
+			return nil
+		},
+		renewFailed: func(err error) error {
+			if err := writeSSE(w, "error", map[string]string{"error": err.Error()}); err != nil {
+				return err
+			}
+			flusher.Flush()
+			return nil
+		},
+	})
+}
+
+// streamSink is where streamRenewals pushes certificates. keepAlive and
+// renewFailed are optional; an error from any of them ends the stream.
+type streamSink struct {
+	send        func(*SecretUpdate) error
+	keepAlive   func() error // Called on keepalive ticks without a renewal
+	renewFailed func(error) error
+}
+
+// streamRenewals pushes a new certificate from issue to sink when current
+// nears expiry or the root is rotated, until ctx is done or the sink fails.
+// Failed renewals are retried at the next keepalive.
+func (s *Server) streamRenewals(ctx context.Context, serviceName string, current *SecretUpdate, issue func() (*SecretUpdate, error), sink streamSink) {
+	keepAlive := time.NewTicker(secretKeepAlive)
+	defer keepAlive.Stop()
+	renew := time.NewTimer(time.Until(current.RenewAt))
+	defer renew.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			if string(s.ca.CertificatePEM()) == current.CACert {
+				if sink.keepAlive != nil && sink.keepAlive() != nil {
+					return
+				}
+				continue
+			}
+			log.Printf("[ca] Root rotated, re-issuing streamed certificate for %s", serviceName)
+		case <-renew.C:
+		}
+
+		next, err := issue()
+		if err != nil {
+			log.Printf("[ca] Failed to renew streamed certificate for %s: %v", serviceName, err)
+			if sink.renewFailed != nil && sink.renewFailed(err) != nil {
+				return
+			}
+			renew.Reset(secretKeepAlive)
+			continue
+		}
+		current = next
+		if sink.send(current) != nil {
+			return
+		}
+		renew.Reset(time.Until(current.RenewAt))
+	}
+}
+
+// writeSSE writes one Server-Sent Event with a JSON payload
+func writeSSE(w io.Writer, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
+}
+
+// errStopWatching wraps errors that end WatchCertificate instead of
+// reconnecting
+type errStopWatching struct{ error }
+
+func (e errStopWatching) Unwrap() error { return e.error }
+
+// WatchCertificate subscribes to GET /sds and calls handle with the initial
+// certificate and every renewal until ctx is done or handle returns an
+// error, which WatchCertificate then returns. Dropped streams reconnect with
+// backoff; a reconnect issues a fresh certificate. Rejected requests
+// (unauthorized, invalid SANs) are not retried.
+//
+// Environment Variables Used:
+//   - SGL_CA (required): CA server URL (must be http:// or https://)
+//   - SGL_CA_API_KEY (optional): API key for CA server authentication
+//   - SGL_CA_TOKEN (optional): Bearer token for CA server authentication
+func WatchCertificate(ctx context.Context, req CertRequestV2, handle func(*SecretUpdate) error) error {
+	caURL, err := getValidatedCAURL()
+	if err != nil {
+		return err
+	}
+
+	retry := secretRetryMin
+	for {
+		received, err := streamSecrets(ctx, caURL+"/sds?"+req.encodeQuery(), handle)
+		var stop errStopWatching
+		if errors.As(err, &stop) {
+			return stop.error
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if received {
+			retry = secretRetryMin
+		}
+		log.Printf("[ca] Certificate stream for %s dropped, reconnecting in %s: %v", req.ServiceName, retry, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retry):
+		}
+		retry = min(retry*2, secretRetryMax)
+	}
+}
+
+// streamSecrets reads one GET /sds stream and reports whether any update
+// arrived. Errors that must not be retried are wrapped in errStopWatching.
+func streamSecrets(ctx context.Context, streamURL string, handle func(*SecretUpdate) error) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", streamURL, nil)
+	if err != nil {
+		return false, errStopWatching{fmt.Errorf("%w: %v", ErrCARequest, err)}
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	// Add API key or bearer token if configured, and the trace context
+	setAuthHeaders(req)
+	injectTraceContext(req)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrCARequest, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return false, errStopWatching{ErrUnauthorized}
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return false, errStopWatching{fmt.Errorf("%w: HTTP %d: %s", ErrCARequest, resp.StatusCode, strings.TrimSpace(string(body)))}
+	case resp.StatusCode != http.StatusOK:
+		return false, fmt.Errorf("%w: HTTP %d", ErrCARequest, resp.StatusCode)
+	}
+
+	received := false
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, 1<<20)
+	var event, data string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		case line == "":
+			if err := dispatchSecretEvent(event, data, handle); err != nil {
+				return received, err
+			}
+			received = received || event == "secret"
+			event, data = "", ""
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return received, fmt.Errorf("%w: %v", ErrCAResponse, err)
+	}
+	return received, fmt.Errorf("%w: stream ended", ErrCAResponse)
+}
+
+// dispatchSecretEvent hands a "secret" event to handle and logs "error"
+// events; keepalives have no event name and are ignored
+func dispatchSecretEvent(event, data string, handle func(*SecretUpdate) error) error {
+	switch event {
+	case "secret":
+		var update SecretUpdate
+		if err := json.Unmarshal([]byte(data), &update); err != nil {
+			return fmt.Errorf("%w: %v", ErrCAResponse, err)
+		}
+		if err := handle(&update); err != nil {
+			return errStopWatching{err}
+		}
+	case "error":
+		log.Printf("[ca] CA failed to renew a streamed certificate: %s", data)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSecretRequest(t *testing.T) {
+	req := CertRequestV2{ServiceName: "web", SANs: []string{"web.local", "127.0.0.1"}, ValidityDays: 7, KeyAlgorithm: KeyAlgorithmECDSAP256, Template: CertTemplateWeb}
+	query, err := url.ParseQuery(req.encodeQuery())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed, err := secretRequest(query); err != nil || !reflect.DeepEqual(parsed, req) {
+		t.Errorf("Expected %+v to round-trip, got %+v, %v", req, parsed, err)
+	}
+
+	// Repeated sans parameters are accepted too
+	parsed, err := secretRequest(url.Values{"service_name": {"web"}, "sans": {"a.local", " b.local,"}})
+	if err != nil || !reflect.DeepEqual(parsed.SANs, []string{"a.local", "b.local"}) {
+		t.Errorf("Unexpected SANs %v, %v", parsed.SANs, err)
+	}
+
+	for _, query := range []url.Values{
+		{"sans": {"web.local"}},
+		{"service_name": {"web"}},
+		{"service_name": {"web"}, "sans": {"web.local"}, "validity_days": {"week"}},
+	} {
+		if _, err := secretRequest(query); !errors.Is(err, ErrInvalidCertRequest) {
+			t.Errorf("Expected %v to be rejected, got %v", query, err)
+		}
+	}
+}
+
+func TestSecretStream(t *testing.T) {
+	originalKeepAlive := secretKeepAlive
+	secretKeepAlive = 20 * time.Millisecond
+	defer func() { secretKeepAlive = originalKeepAlive }()
+
+	dir := t.TempDir()
+	authority := newTestCA(t, persistIn(dir))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sds", (&Server{ca: authority}).handleSecretStream)
+	caServer := httptest.NewServer(mux)
+	defer caServer.Close()
+
+	t.Setenv("SGL_CA", caServer.URL)
+	t.Setenv("SGL_CA_API_KEY", "")
+	t.Setenv("SGL_CA_TOKEN", "")
+
+	t.Run("invalid request", func(t *testing.T) {
+		resp, err := http.Get(caServer.URL + "/sds?service_name=web")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 without SANs, got %d", resp.StatusCode)
+		}
+
+		err = WatchCertificate(context.Background(), CertRequestV2{ServiceName: "web", SANs: []string{"bad name!"}}, func(*SecretUpdate) error {
+			return nil
+		})
+		if !errors.Is(err, ErrCARequest) || !strings.Contains(err.Error(), "HTTP 400") {
+			t.Errorf("Expected a rejected request not to be retried, got %v", err)
+		}
+	})
+
+	t.Run("initial certificate and root rotation", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		errDone := errors.New("done")
+		var updates []*SecretUpdate
+		err := WatchCertificate(ctx, CertRequestV2{ServiceName: "web", SANs: []string{"web.local"}}, func(update *SecretUpdate) error {
+			updates = append(updates, update)
+			if len(updates) == 2 {
+				return errDone
+			}
+
+			// Rotate the root; the next keepalive re-issues under it
+			otherDir := t.TempDir()
+			newTestCA(t, persistIn(otherDir))
+			for _, name := range []string{"ca-cert.pem", "ca-key.pem"} {
+				data, err := os.ReadFile(filepath.Join(otherDir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			return authority.ReloadFromDisk()
+		})
+		if !errors.Is(err, errDone) {
+			t.Fatalf("Expected the handler's error, got %v", err)
+		}
+
+		first, second := updates[0], updates[1]
+		if first.Name != "web" || first.VersionInfo == "" || first.PrivateKey == "" || !first.RenewAt.Before(first.ExpiresAt) {
+			t.Errorf("Unexpected first update: %+v", first)
+		}
+		if second.VersionInfo == first.VersionInfo || second.CACert == first.CACert || second.CACert != string(authority.CertificatePEM()) {
+			t.Error("Expected a new certificate under the rotated root")
+		}
+		if resp := second.CertResponse(); resp.Certificate != second.Certificate || resp.CACert != second.CACert {
+			t.Errorf("Unexpected CertResponse: %+v", resp)
+		}
+	})
+}
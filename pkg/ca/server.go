@@ -2,14 +2,17 @@
 package ca
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
 	"strings"
 
-	"github.com/nzions/sharedgolibs/pkg/middleware"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // Server wraps the CA with HTTP server functionality
@@ -18,7 +21,14 @@ type Server struct {
 	port      string
 	enableGUI bool
 	guiAPIKey string
+	tokens    *TokenVerifier
 	gui       *GUIHandler
+
+	namespaceKeys   map[string]string
+	cors            *CORSConfig
+	issueQueue      *issueQueue
+	approvalDomains []string
+	propagateTrace  bool
 }
 
 // ServerConfig holds configuration for the CA server
@@ -28,19 +38,75 @@ type ServerConfig struct {
 	EnableGUI  bool   // Enable the web GUI interface
 	GUIAPIKey  string // API key required for GUI access (if set)
 	PersistDir string // Directory to persist CA data (empty = RAM only)
+
+	// RootCertFile and RootKeyFile load an existing root (e.g. mkcert's
+	// rootCA.pem and rootCA-key.pem) instead of generating one. Both must
+	// be set; they override CAConfig.RootCertPEM and RootKeyPEM.
+	RootCertFile string
+	RootKeyFile  string
+
+	// CORS enables cross-origin requests from browser-based tools (nil = disabled)
+	CORS *CORSConfig
+
+	// TokenAuth accepts bearer tokens verified against a JWKS (nil = disabled).
+	// With GUIAPIKey also set, either credential is accepted.
+	TokenAuth *TokenAuthConfig
+
+	// NamespaceAPIKeys maps API keys to namespaces, so one server can be
+	// shared by several teams. A namespace key issues, lists, and downloads
+	// only the certificates of its namespace; GUIAPIKey and bearer tokens
+	// are the admin view and see every namespace.
+	NamespaceAPIKeys map[string]string
+
+	// TrustForwardedHeaders builds GUI links from X-Forwarded-Proto and
+	// X-Forwarded-Host, and takes the dashboard's browser address from
+	// X-Forwarded-For, for access through a reverse proxy. Only enable it
+	// when the server is reachable solely through that proxy.
+	TrustForwardedHeaders bool
+
+	// IssueQueueSize bounds the requests waiting in the queue behind
+	// POST /cert?async=true (default DefaultIssueQueueSize), issued by
+	// IssueQueueWorkers at a time (default DefaultIssueQueueWorkers)
+	IssueQueueSize    int
+	IssueQueueWorkers int
+
+	// ApprovalDomains hold POST /cert requests for DNS names under these
+	// domains (matched like name constraints: "example.com" with its
+	// subdomains, ".example.com" subdomains only) until an admin approves
+	// or denies them in the GUI or through /admin/requests. The client
+	// helpers wait for the decision. Useful for shared staging CAs.
+	// Requires GUIAPIKey or TokenAuth to tell admins from requesters.
+	ApprovalDomains []string
+
+	// TracePropagation continues the trace of a client's W3C traceparent
+	// header, so a request's server span and its issuance spans (see
+	// CAConfig.TracerProvider) show up under the client's span. Without
+	// it every request starts a new trace.
+	TracePropagation bool
 }
 
 // DefaultServerConfig returns sensible defaults for server configuration
 func DefaultServerConfig() *ServerConfig {
 	return &ServerConfig{
 		Port:       "8090",
-		CAConfig:   DefaultCAConfig(),
+		CAConfig:   defaultServerCAConfig(),
 		EnableGUI:  true, // GUI enabled by default
 		GUIAPIKey:  "",   // No API key by default
 		PersistDir: "",   // RAM only by default
 	}
 }
 
+// DefaultServerKeyPoolSize is the key pool size used by DefaultServerConfig
+const DefaultServerKeyPoolSize = 16
+
+// defaultServerCAConfig returns DefaultCAConfig with a key pool enabled, so
+// bursts of certificate requests don't wait on key generation
+func defaultServerCAConfig() *CAConfig {
+	config := DefaultCAConfig()
+	config.KeyPoolSize = DefaultServerKeyPoolSize
+	return config
+}
+
 // NewServer creates a new CA server
 func NewServer(config *ServerConfig) (*Server, error) {
 	if config == nil {
@@ -51,20 +117,60 @@ func NewServer(config *ServerConfig) (*Server, error) {
 	if config.CAConfig != nil {
 		config.CAConfig.PersistDir = config.PersistDir
 	} else {
-		config.CAConfig = DefaultCAConfig()
+		config.CAConfig = defaultServerCAConfig()
 		config.CAConfig.PersistDir = config.PersistDir
 	}
 
+	if config.RootCertFile != "" || config.RootKeyFile != "" {
+		if config.RootCertFile == "" || config.RootKeyFile == "" {
+			return nil, fmt.Errorf("RootCertFile and RootKeyFile must be set together")
+		}
+		var err error
+		if config.CAConfig.RootCertPEM, err = os.ReadFile(config.RootCertFile); err != nil {
+			return nil, fmt.Errorf("failed to read root certificate: %w", err)
+		}
+		if config.CAConfig.RootKeyPEM, err = os.ReadFile(config.RootKeyFile); err != nil {
+			return nil, fmt.Errorf("failed to read root key: %w", err)
+		}
+	}
+
 	ca, err := NewCA(config.CAConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create CA: %w", err)
 	}
 
+	if err := validateNamespaceKeys(config.NamespaceAPIKeys, config.GUIAPIKey); err != nil {
+		return nil, err
+	}
+	approvalDomains, err := normalizeConstraintDomains(config.ApprovalDomains)
+	if err != nil {
+		return nil, fmt.Errorf("invalid approval domain: %w", err)
+	}
+	// Without an admin credential every caller is an admin, free to
+	// approve its own requests
+	if len(approvalDomains) > 0 && config.GUIAPIKey == "" && config.TokenAuth == nil {
+		return nil, fmt.Errorf("ApprovalDomains require GUIAPIKey or TokenAuth for the admins deciding requests")
+	}
+
 	server := &Server{
-		ca:        ca,
-		port:      config.Port,
-		enableGUI: config.EnableGUI,
-		guiAPIKey: config.GUIAPIKey,
+		ca:            ca,
+		port:          config.Port,
+		enableGUI:     config.EnableGUI,
+		guiAPIKey:     config.GUIAPIKey,
+		cors:          config.CORS,
+		namespaceKeys: config.NamespaceAPIKeys,
+		issueQueue:    newIssueQueue(config.IssueQueueSize, config.IssueQueueWorkers),
+
+		approvalDomains: approvalDomains,
+		propagateTrace:  config.TracePropagation,
+	}
+
+	if config.TokenAuth != nil {
+		tokens, err := NewTokenVerifier(config.TokenAuth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure token auth: %w", err)
+		}
+		server.tokens = tokens
 	}
 
 	// Initialize GUI handler if enabled
@@ -73,6 +179,9 @@ func NewServer(config *ServerConfig) (*Server, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to create GUI handler: %w", err)
 		}
+		gui.trustForwarded = config.TrustForwardedHeaders
+		gui.approvals = server.issueQueue
+		gui.approvalDomains = approvalDomains
 		server.gui = gui
 	}
 
@@ -81,48 +190,89 @@ func NewServer(config *ServerConfig) (*Server, error) {
 
 // Start starts the HTTP server
 func (s *Server) Start() error {
-	// Set up HTTP handlers with API key protection if configured
-	var caHandler, certHandler, healthHandler http.Handler
+	// Set up HTTP handlers with API key or token protection if configured
+	var caHandler, bundleHandler, certHandler, ticketHandler, templatesHandler, usageHandler, validateHandler, secretHandler, healthHandler, metricsHandler http.Handler
 	caHandler = http.HandlerFunc(s.handleCARequest)
+	bundleHandler = http.HandlerFunc(s.handleCABundle)
 	certHandler = http.HandlerFunc(s.handleCertRequest)
+	ticketHandler = http.HandlerFunc(s.handleCertTicket)
+	templatesHandler = http.HandlerFunc(s.handleCertTemplates)
+	usageHandler = http.HandlerFunc(s.handleCertUsage)
+	validateHandler = http.HandlerFunc(s.handleValidate)
+	secretHandler = http.HandlerFunc(s.handleSecretStream)
 	healthHandler = http.HandlerFunc(s.handleHealth)
-
-	// Apply API key middleware to API endpoints if API key is configured
-	if s.guiAPIKey != "" {
-		caHandler = middleware.WithAPIKey(s.guiAPIKey, caHandler)
-		certHandler = middleware.WithAPIKey(s.guiAPIKey, certHandler)
-		healthHandler = middleware.WithAPIKey(s.guiAPIKey, healthHandler)
+	metricsHandler = http.HandlerFunc(s.handleMetrics)
+
+	// Apply auth middleware to API endpoints if an API key or token auth is configured
+	if s.requiresAuth() {
+		caHandler = s.authenticate(caHandler)
+		bundleHandler = s.authenticate(bundleHandler)
+		certHandler = s.authenticate(certHandler)
+		ticketHandler = s.authenticate(ticketHandler)
+		templatesHandler = s.authenticate(templatesHandler)
+		usageHandler = s.authenticate(usageHandler)
+		validateHandler = s.authenticate(validateHandler)
+		secretHandler = s.authenticate(secretHandler)
+		healthHandler = s.authenticate(healthHandler)
+		metricsHandler = s.authenticate(metricsHandler)
 	}
 
 	http.Handle("/ca", caHandler)
+	http.Handle("/ca/bundle", bundleHandler)
 	http.Handle("/cert", certHandler)
+	http.Handle("/cert/ticket/", ticketHandler)
+	http.Handle("/cert/templates", templatesHandler)
+	http.Handle("/cert/usage", usageHandler)
+	http.Handle("/validate", validateHandler)
+	http.Handle("/sds", secretHandler)
 	http.Handle("/health", healthHandler)
+	http.Handle("/metrics", metricsHandler)
+
+	// Backups hold the CA key and purges delete certificates, so they
+	// require an admin credential
+	http.Handle("/admin/backup", s.authenticateAdmin(http.HandlerFunc(s.handleAdminBackup)))
+	http.Handle("/admin/purge-unused", s.authenticateAdmin(http.HandlerFunc(s.handleAdminPurgeUnused)))
+	http.Handle("/admin/requests", s.authenticate(http.HandlerFunc(s.handleAdminRequests)))
+	http.Handle("/admin/requests/", s.authenticate(http.HandlerFunc(s.handleAdminRequests)))
+
+	// Probes report statuses but no CA details, so orchestrators can call
+	// them without credentials
+	http.HandleFunc("/healthz", s.handleHealthz)
+	http.HandleFunc("/readyz", s.handleReadyz)
 
 	// Web UI handlers (only if GUI is enabled)
 	if s.enableGUI && s.gui != nil {
-		// Apply API key middleware if configured
-		var dashboardHandler, certsHandler, generateHandler, apiHandler, certDetailsHandler, downloadCAHandler, downloadCAKeyHandler, certsTableHandler, logStreamHandler, staticHandler http.Handler
+		// Apply auth middleware if configured
+		var dashboardHandler, certsHandler, generateHandler, generatePreviewHandler, apiHandler, certDetailsHandler, downloadCAHandler, downloadCAKeyHandler, certsTableHandler, certsSearchHandler, logStreamHandler, staticHandler, verifyHandler, requestsHandler http.Handler
 		dashboardHandler = http.HandlerFunc(s.gui.HandleDashboard)
 		certsHandler = http.HandlerFunc(s.gui.HandleCertificates)
 		generateHandler = http.HandlerFunc(s.gui.HandleGenerate)
+		generatePreviewHandler = http.HandlerFunc(s.gui.HandleGeneratePreview)
 		apiHandler = http.HandlerFunc(s.gui.HandleAPI)
 		certDetailsHandler = http.HandlerFunc(s.gui.HandleCertDetails)
 		downloadCAHandler = http.HandlerFunc(s.gui.HandleDownloadCA)
 		downloadCAKeyHandler = http.HandlerFunc(s.gui.HandleDownloadCAKey)
 		certsTableHandler = http.HandlerFunc(s.gui.HandleCertsTable)
+		certsSearchHandler = http.HandlerFunc(s.gui.HandleCertsSearch)
 		logStreamHandler = http.HandlerFunc(s.gui.HandleLogStream)
 		staticHandler = http.HandlerFunc(s.gui.HandleStatic)
-
-		if s.guiAPIKey != "" {
-			dashboardHandler = middleware.WithAPIKey(s.guiAPIKey, dashboardHandler)
-			certsHandler = middleware.WithAPIKey(s.guiAPIKey, certsHandler)
-			generateHandler = middleware.WithAPIKey(s.guiAPIKey, generateHandler)
-			apiHandler = middleware.WithAPIKey(s.guiAPIKey, apiHandler)
-			certDetailsHandler = middleware.WithAPIKey(s.guiAPIKey, certDetailsHandler)
-			downloadCAHandler = middleware.WithAPIKey(s.guiAPIKey, downloadCAHandler)
-			downloadCAKeyHandler = middleware.WithAPIKey(s.guiAPIKey, downloadCAKeyHandler)
-			certsTableHandler = middleware.WithAPIKey(s.guiAPIKey, certsTableHandler)
-			logStreamHandler = middleware.WithAPIKey(s.guiAPIKey, logStreamHandler)
+		verifyHandler = http.HandlerFunc(s.gui.HandleVerify)
+		requestsHandler = http.HandlerFunc(s.gui.HandleApprovalRequests)
+
+		if s.requiresAuth() {
+			dashboardHandler = s.authenticate(dashboardHandler)
+			certsHandler = s.authenticate(certsHandler)
+			generateHandler = s.authenticate(generateHandler)
+			generatePreviewHandler = s.authenticate(generatePreviewHandler)
+			apiHandler = s.authenticate(apiHandler)
+			certDetailsHandler = s.authenticate(certDetailsHandler)
+			downloadCAHandler = s.authenticate(downloadCAHandler)
+			downloadCAKeyHandler = s.authenticate(downloadCAKeyHandler)
+			certsTableHandler = s.authenticate(certsTableHandler)
+			certsSearchHandler = s.authenticate(certsSearchHandler)
+			logStreamHandler = s.authenticate(logStreamHandler)
+			verifyHandler = s.authenticate(verifyHandler)
+			requestsHandler = s.authenticate(requestsHandler)
 			// Note: Static files typically don't require API key authentication
 			// Note: Certificate downloads (/cert/) are handled by special function below
 		}
@@ -131,59 +281,93 @@ func (s *Server) Start() error {
 		http.Handle("/ui/", dashboardHandler)
 		http.Handle("/ui/certs", certsHandler)
 		http.Handle("/ui/generate", generateHandler)
+		http.Handle("/ui/generate/preview", generatePreviewHandler)
+		http.Handle("/ui/verify", verifyHandler)
 		http.Handle("/ui/api", apiHandler)
 		http.Handle("/ui/cert-details/", certDetailsHandler)
 		http.Handle("/ui/download-ca", downloadCAHandler)
 		http.Handle("/ca-key", downloadCAKeyHandler)
 		http.Handle("/ui/certs-table", certsTableHandler)
+		http.Handle("/ui/certs/search", certsSearchHandler)
 		http.Handle("/ui/logs", logStreamHandler)
 		http.Handle("/ui/static/", staticHandler)
+		http.Handle("/ui/requests", requestsHandler)
+		http.Handle("/ui/requests/", requestsHandler)
 
-		// Certificate download routes - these need special handling
-		http.HandleFunc("/cert/", func(w http.ResponseWriter, r *http.Request) {
-			if s.guiAPIKey != "" {
-				// Check API key
-				apiKey := r.Header.Get("X-API-Key")
-				if apiKey == "" {
-					apiKey = r.URL.Query().Get("api_key")
-				}
-				if apiKey != s.guiAPIKey {
-					http.Error(w, "Unauthorized: Invalid or missing API key", http.StatusUnauthorized)
-					return
-				}
-			}
-
+		// Certificate download routes share the /cert/ prefix
+		http.Handle("/cert/", s.authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Check if it's a key request
 			if strings.HasSuffix(r.URL.Path, "/key") {
 				s.gui.HandleDownloadCertKey(w, r)
 			} else {
 				s.gui.HandleDownloadCert(w, r)
 			}
-		})
+		})))
 	}
 
 	log.Printf("[ca] Certificate Authority listening on port %s", s.port)
 	log.Printf("[ca] Endpoints:")
 	log.Printf("[ca]   GET  /ca    - Download CA certificate")
-	log.Printf("[ca]   POST /cert  - Request service certificate")
+	log.Printf("[ca]   GET  /ca/bundle - Download CA bundle (?format=pem|der|jks)")
+	log.Printf("[ca]   POST /cert  - Request service certificate (?async=true queues it)")
+	log.Printf("[ca]   GET  /cert/ticket/{id} - Collect a queued certificate (?wait=30s)")
+	log.Printf("[ca]   GET  /cert/templates - List certificate templates")
+	log.Printf("[ca]   POST /cert/usage - Report a certificate in use")
+	log.Printf("[ca]   POST /validate - Verify a PEM chain (?at= verifies it as of another time)")
+	log.Printf("[ca]   GET  /sds   - Stream a service certificate and its renewals (SSE)")
 	log.Printf("[ca]   GET  /health - Health check")
+	log.Printf("[ca]   GET  /healthz - Liveness probe (no auth)")
+	log.Printf("[ca]   GET  /readyz - Readiness probe (no auth)")
+	log.Printf("[ca]   GET  /metrics - Prometheus metrics")
+	log.Printf("[ca]   GET  /admin/backup - Download a backup archive (admin only)")
+	log.Printf("[ca]   POST /admin/purge-unused - Remove certificates not fetched or used recently (admin only)")
+	log.Printf("[ca]   GET  /admin/requests - Requests awaiting approval; POST /admin/requests/{id}/approve|deny (admin only)")
+	log.Printf("[ca]   gRPC %s - IssueCertificate, GetCABundle, WatchCertificate (h2c, with reflection)", GRPCServiceName)
 
 	if s.guiAPIKey != "" {
 		log.Printf("[ca]   Note: All endpoints require API key authentication")
 		log.Printf("[ca]   Use X-API-Key header or ?api_key= query parameter")
 	}
+	if s.tokens != nil {
+		log.Printf("[ca]   Bearer tokens accepted (JWKS: %s)", s.tokens.config.JWKSURL)
+	}
+	if len(s.namespaceKeys) > 0 {
+		log.Printf("[ca]   Namespaces: %d API keys scoped to a namespace", len(s.namespaceKeys))
+	}
+	if len(s.approvalDomains) > 0 {
+		log.Printf("[ca]   Approval required for: %s", strings.Join(s.approvalDomains, ", "))
+	}
+
+	if s.cors != nil {
+		log.Printf("[ca]   CORS enabled for origins: %s", strings.Join(s.cors.AllowedOrigins, ", "))
+	}
 
 	if s.enableGUI {
 		log.Printf("[ca]   GUI Interface:")
 		log.Printf("[ca]     GET  /ui/   - Web UI dashboard")
 		log.Printf("[ca]     GET  /ui/certs - List issued certificates")
+		log.Printf("[ca]     GET  /ui/certs/search?q= - Search certificates by service, SAN, or serial")
 		log.Printf("[ca]     GET  /ui/generate - Generate new certificate")
 		log.Printf("[ca]     GET  /ui/api - API documentation")
 	} else {
 		log.Printf("[ca]   GUI interface is disabled")
 	}
 
-	return http.ListenAndServe(":"+s.port, nil)
+	var handler http.Handler = http.DefaultServeMux
+	if s.cors != nil {
+		handler = withCORS(s.cors, handler)
+	}
+
+	// gRPC shares the port: h2c accepts HTTP/2 without TLS, and gRPC calls
+	// are told apart by their content type
+	handler = withGRPC(s.grpcHandler(), handler)
+	handler = s.withTracing(handler)
+	return http.ListenAndServe(":"+s.port, h2c.NewHandler(handler, &http2.Server{}))
+}
+
+// requiresAuth reports whether requests need an API key or bearer token
+func (s *Server) requiresAuth() bool {
+	return s.guiAPIKey != "" || s.tokens != nil || len(s.namespaceKeys) > 0
 }
 
 // GetCA returns the underlying CA instance
@@ -236,16 +420,13 @@ func (s *Server) handleCertRequest(w http.ResponseWriter, r *http.Request) {
 				log.Printf("[ca] Certificate request (V2) from %s for service: %s, SANs: %v", r.RemoteAddr, reqV2.ServiceName, reqV2.SANs)
 
 				// Issue certificate using the CA with V2 format
-				response, err := s.ca.IssueServiceCertificateV2(reqV2)
-				if err != nil {
-					log.Printf("[ca] Failed to generate certificate for %s: %v", reqV2.ServiceName, err)
-					http.Error(w, "Certificate generation failed", http.StatusInternalServerError)
-					return
-				}
-
-				w.Header().Set("Content-Type", "application/json")
-				json.NewEncoder(w).Encode(response)
-				log.Printf("[ca] ✅ Certificate issued for %s (V2)", reqV2.ServiceName)
+				// Queued requests are issued after the request ends, still
+				// within its trace
+				namespace, _ := requestNamespace(r)
+				ctx := context.WithoutCancel(r.Context())
+				s.respondIssued(w, r, reqV2.ServiceName, "V2", reqV2.SANs, func() (*CertResponse, error) {
+					return s.ca.issueServiceCertificateV2(ctx, reqV2, namespace)
+				})
 				return
 			} else {
 				// Invalid V2 request
@@ -289,17 +470,11 @@ func (s *Server) handleCertRequest(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[ca] Certificate request (V1) from %s for service: %s, IP: %s, domains: %v", r.RemoteAddr, req.ServiceName, req.ServiceIP, req.Domains)
 
 	// Issue certificate using the CA
-	response, err := s.ca.IssueServiceCertificate(req)
-	if err != nil {
-		log.Printf("[ca] Failed to generate certificate for %s: %v", req.ServiceName, err)
-		http.Error(w, "Certificate generation failed", http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-
-	log.Printf("[ca] ✅ Certificate issued for %s (V1)", req.ServiceName)
+	namespace, _ := requestNamespace(r)
+	ctx := context.WithoutCancel(r.Context())
+	s.respondIssued(w, r, req.ServiceName, "V1", req.Domains, func() (*CertResponse, error) {
+		return s.ca.issueServiceCertificate(ctx, req, namespace)
+	})
 }
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
@@ -310,11 +485,42 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 
 	caInfo := s.ca.GetCAInfo()
 	response := map[string]interface{}{
-		"status":  "healthy",
-		"version": Version,
-		"ca_info": caInfo,
+		"status":    "healthy",
+		"version":   Version,
+		"ca_info":   caInfo,
+		"readiness": s.ca.Readiness(),
+	}
+	if stats, ok := s.ca.KeyPoolStats(); ok {
+		response["key_pool"] = stats
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// handleMetrics serves CA metrics in the Prometheus text exposition format
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	metric := func(name, help, kind string, value interface{}) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+	}
+
+	metric("ca_issued_certificates", "Number of certificates issued by this CA.", "gauge", s.ca.GetCertificateCount())
+
+	if stats, ok := s.ca.KeyPoolStats(); ok {
+		metric("ca_key_pool_size", "Configured key pool capacity.", "gauge", stats.Size)
+		metric("ca_key_pool_available", "Pre-generated keys ready for issuance.", "gauge", stats.Available)
+		metric("ca_key_pool_hits_total", "Certificate requests served from the key pool.", "counter", stats.Hits)
+		metric("ca_key_pool_misses_total", "Certificate requests that generated a key inline.", "counter", stats.Misses)
+		metric("ca_key_pool_generated_total", "Keys generated in the background.", "counter", stats.Generated)
+	}
+
+	metric("ca_issue_queue_pending", "Asynchronous certificate requests waiting for a worker.", "gauge", s.issueQueue.Pending())
+	metric("ca_requests_awaiting_approval", "Certificate requests held for an admin's approval.", "gauge", len(s.issueQueue.AwaitingApproval()))
+}
@@ -622,3 +622,43 @@ func TestServerStartAndLifecycle(t *testing.T) {
 		}
 	})
 }
+
+func TestServerHealthAndMetricsKeyPool(t *testing.T) {
+	config := DefaultServerConfig()
+	config.CAConfig.KeySize = 2048
+	config.CAConfig.KeyPoolSize = 2
+	config.EnableGUI = false
+
+	server, err := NewServer(config)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer server.ca.Close()
+
+	rr := httptest.NewRecorder()
+	server.handleHealth(rr, httptest.NewRequest("GET", "/health", nil))
+
+	var health map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &health); err != nil {
+		t.Fatalf("Failed to parse health response: %v", err)
+	}
+	pool, ok := health["key_pool"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected key_pool in health response, got %v", health)
+	}
+	if pool["size"] != float64(2) {
+		t.Errorf("Expected key pool size 2, got %v", pool["size"])
+	}
+
+	rr = httptest.NewRecorder()
+	server.handleMetrics(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	body := rr.Body.String()
+	for _, want := range []string{"ca_issued_certificates 0", "ca_key_pool_size 2", "# TYPE ca_key_pool_hits_total counter"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Metrics missing %q:\n%s", want, body)
+		}
+	}
+}
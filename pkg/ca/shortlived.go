@@ -0,0 +1,163 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nzions/sharedgolibs/pkg/logi"
+)
+
+// CertLifetimeEnv names the environment variable that switches
+// CreateSecureHTTPSServerV2 and CreateSecureDualProtocolServer to
+// short-lived certificates, e.g. SGL_CA_CERT_LIFETIME=1h
+const CertLifetimeEnv = "SGL_CA_CERT_LIFETIME"
+
+// MinShortLivedCertLifetime is the shortest lifetime NewShortLivedCertificate
+// accepts; the CA issues validity in whole minutes
+const MinShortLivedCertLifetime = time.Minute
+
+// renewalRetryInterval is how long a failed timed renewal waits before
+// trying again. Overridable for tests.
+var renewalRetryInterval = 30 * time.Second
+
+// NewShortLivedCertificate is NewReloadingCertificate for certificates valid
+// for only lifetime, such as an hour, rounded up to whole minutes. Each
+// certificate is renewed in the background once two thirds of its lifetime
+// has passed, even without handshakes, so local servers exercise the same
+// certificate reload paths as production ones with short-lived certificates.
+// Watch renewals with OnRenewal, Stats, or RenewalMetricsHandler.
+func NewShortLivedCertificate(serviceName string, sans []string, lifetime time.Duration) (*ReloadingCertificate, error) {
+	if lifetime < MinShortLivedCertLifetime {
+		return nil, fmt.Errorf("%w: certificate lifetime %s is shorter than %s", ErrInvalidCertRequest, lifetime, MinShortLivedCertLifetime)
+	}
+	rc := &ReloadingCertificate{
+		serviceName:     serviceName,
+		sans:            sans,
+		validityMinutes: int((lifetime + time.Minute - 1) / time.Minute),
+		autoRenew:       true,
+		request:         requestCertificateV2,
+		reportUsage:     ReportCertificateUsage,
+		now:             time.Now,
+	}
+	if err := rc.renew(RenewalReasonStart); err != nil {
+		return nil, err
+	}
+	rc.watchSignals()
+	registerReloadingCertificate(rc)
+	return rc, nil
+}
+
+// scheduleRenewal renews a short-lived certificate in the background after
+// the given time
+func (rc *ReloadingCertificate) scheduleRenewal(after time.Duration) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	if !rc.autoRenew || rc.closed {
+		return
+	}
+	if rc.renewTimer != nil {
+		rc.renewTimer.Stop()
+	}
+	rc.renewTimer = time.AfterFunc(after, rc.renewScheduled)
+}
+
+// renewScheduled is the timed renewal, retried until it succeeds
+func (rc *ReloadingCertificate) renewScheduled() {
+	if err := rc.renew(RenewalReasonRenewal); err != nil {
+		log.Printf("[ca] Certificate renewal for %s failed, retrying in %s: %v", rc.serviceName, renewalRetryInterval, err)
+		rc.scheduleRenewal(renewalRetryInterval)
+	}
+}
+
+// certLifetimeFromEnv returns the short-lived certificate lifetime set by
+// CertLifetimeEnv, or 0 when unset
+func certLifetimeFromEnv() (time.Duration, error) {
+	value := os.Getenv(CertLifetimeEnv)
+	if value == "" {
+		return 0, nil
+	}
+	lifetime, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", CertLifetimeEnv, value, err)
+	}
+	return lifetime, nil
+}
+
+// logRenewals reports the renewals of rc with logger
+func logRenewals(rc *ReloadingCertificate, logger logi.Logger) {
+	rc.OnRenewal(func(event RenewalEvent) {
+		if event.Err != nil {
+			logger.Warn("Certificate renewal failed", "service", event.ServiceName, "reason", event.Reason, "error", event.Err)
+			return
+		}
+		logger.Info("🔄 Certificate renewed",
+			"service", event.ServiceName,
+			"reason", event.Reason,
+			"serial_number", event.Serial,
+			"expires_at", event.NotAfter.Format("2006-01-02 15:04:05 MST"),
+		)
+	})
+}
+
+// Short-lived certificates open in this process, for RenewalMetricsHandler
+var (
+	openCertsMutex sync.Mutex
+	openCerts      = make(map[*ReloadingCertificate]bool)
+)
+
+func registerReloadingCertificate(rc *ReloadingCertificate) {
+	openCertsMutex.Lock()
+	defer openCertsMutex.Unlock()
+	openCerts[rc] = true
+}
+
+func unregisterReloadingCertificate(rc *ReloadingCertificate) {
+	openCertsMutex.Lock()
+	defer openCertsMutex.Unlock()
+	delete(openCerts, rc)
+}
+
+// RenewalMetricsHandler serves the Stats of every short-lived certificate
+// not yet closed in the Prometheus text exposition format, labeled by
+// service. Mount it explicitly, e.g. at /metrics/certificates.
+func RenewalMetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		openCertsMutex.Lock()
+		stats := make([]RenewalStats, 0, len(openCerts))
+		for rc := range openCerts {
+			stats = append(stats, rc.Stats())
+		}
+		openCertsMutex.Unlock()
+		sort.Slice(stats, func(i, j int) bool { return stats[i].ServiceName < stats[j].ServiceName })
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+		metric := func(name, help, kind string, value func(RenewalStats) interface{}) {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+			for _, s := range stats {
+				fmt.Fprintf(w, "%s{service=%q} %v\n", name, s.ServiceName, value(s))
+			}
+		}
+
+		metric("ca_certificate_renewals_total", "Certificates re-issued after the first.", "counter",
+			func(s RenewalStats) interface{} { return s.Renewals })
+		metric("ca_certificate_renewal_failures_total", "Failed certificate requests.", "counter",
+			func(s RenewalStats) interface{} { return s.Failures })
+		metric("ca_certificate_expiry_timestamp_seconds", "Expiry of the served certificate.", "gauge",
+			func(s RenewalStats) interface{} { return s.NotAfter.Unix() })
+		metric("ca_certificate_renew_timestamp_seconds", "When the served certificate is renewed.", "gauge",
+			func(s RenewalStats) interface{} { return s.RenewAt.Unix() })
+	})
+}
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShortLivedCertificate(t *testing.T) {
+	startDepTLSCA(t)
+
+	if _, err := NewShortLivedCertificate("short", []string{"localhost"}, 30*time.Second); !errors.Is(err, ErrInvalidCertRequest) {
+		t.Errorf("Expected a sub-minute lifetime to be rejected, got %v", err)
+	}
+
+	rc, err := NewShortLivedCertificate("short", []string{"localhost"}, 59*time.Minute+time.Second)
+	if err != nil {
+		t.Fatalf("NewShortLivedCertificate failed: %v", err)
+	}
+	defer rc.Close()
+	cert, _ := rc.GetCertificate(nil)
+	if lifetime := cert.Leaf.NotAfter.Sub(cert.Leaf.NotBefore); lifetime != time.Hour {
+		t.Errorf("Expected the lifetime rounded up to 1h, got %s", lifetime)
+	}
+
+	var mutex sync.Mutex
+	var events []RenewalEvent
+	rc.OnRenewal(func(event RenewalEvent) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		events = append(events, event)
+	})
+	renewals := func() []RenewalEvent {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]RenewalEvent(nil), events...)
+	}
+	waitFor := func(what string, done func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !done() {
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for %s, got %+v", what, renewals())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// The timer renews without any handshake
+	rc.scheduleRenewal(time.Millisecond)
+	waitFor("a renewal", func() bool { return rc.Stats().Renewals == 1 })
+	if event := renewals()[0]; event.Reason != RenewalReasonRenewal || event.Err != nil || event.Serial != rc.Stats().Serial {
+		t.Errorf("Unexpected renewal event %+v", event)
+	}
+	if renewed, _ := rc.GetCertificate(nil); renewed.Leaf.SerialNumber.Cmp(cert.Leaf.SerialNumber) == 0 {
+		t.Error("Expected a new certificate to be served")
+	}
+
+	// Failed timed renewals are retried
+	originalRetry := renewalRetryInterval
+	renewalRetryInterval = time.Millisecond
+	defer func() { renewalRetryInterval = originalRetry }()
+	request := rc.request
+	rc.reloadMutex.Lock()
+	rc.request = func(*CertRequestV2) (*CertResponse, error) { return nil, ErrCARequest }
+	rc.reloadMutex.Unlock()
+	rc.scheduleRenewal(time.Millisecond)
+	waitFor("retries", func() bool { return rc.Stats().Failures >= 2 })
+	rc.reloadMutex.Lock()
+	rc.request = request
+	rc.reloadMutex.Unlock()
+	waitFor("a renewal after the failures", func() bool { return rc.Stats().Renewals == 2 })
+
+	recorder := httptest.NewRecorder()
+	RenewalMetricsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(recorder.Body)
+	if !strings.Contains(string(body), "ca_certificate_renewals_total{service=\"short\"} 2\n") {
+		t.Errorf("Unexpected metrics:\n%s", body)
+	}
+
+	rc.Close()
+	recorder = httptest.NewRecorder()
+	RenewalMetricsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if strings.Contains(recorder.Body.String(), `service="short"`) {
+		t.Error("Expected a closed certificate to leave the metrics")
+	}
+}
+
+func TestCertLifetimeEnv(t *testing.T) {
+	startDepTLSCA(t)
+
+	t.Setenv(CertLifetimeEnv, "soon")
+	if _, err := CreateSecureHTTPSServerV2("env", "8443", []string{"localhost"}, nil); err == nil {
+		t.Error("Expected an invalid lifetime to be rejected")
+	}
+
+	t.Setenv(CertLifetimeEnv, "2h")
+	server, err := CreateSecureHTTPSServerV2("env", "8443", []string{"localhost"}, nil)
+	if err != nil {
+		t.Fatalf("CreateSecureHTTPSServerV2 failed: %v", err)
+	}
+	cert, err := server.TLSConfig.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lifetime := cert.Leaf.NotAfter.Sub(cert.Leaf.NotBefore); lifetime != 2*time.Hour {
+		t.Errorf("Expected a 2h certificate, got %s", lifetime)
+	}
+
+	dual, err := CreateSecureDualProtocolServer("env", "8443", []string{"localhost"}, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateSecureDualProtocolServer failed: %v", err)
+	}
+	if dual == nil {
+		t.Fatal("Expected a dual protocol server")
+	}
+}
+
+func TestValidityMinutes(t *testing.T) {
+	for _, req := range []CertRequestV2{
+		{ValidityMinutes: -1},
+		{ValidityMinutes: MaxLeafValidityDays*24*60 + 1},
+		{ValidityDays: 1, ValidityMinutes: 60},
+	} {
+		if _, err := req.options(); !errors.Is(err, ErrInvalidCertRequest) {
+			t.Errorf("Expected %+v to be rejected, got %v", req, err)
+		}
+	}
+	if opts, err := (CertRequestV2{ValidityMinutes: 90}).options(); err != nil || opts.validity != 90*time.Minute {
+		t.Errorf("Expected 90 minutes, got %s, %v", opts.validity, err)
+	}
+}
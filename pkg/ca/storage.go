@@ -1,12 +1,13 @@
 package ca
 
 import (
+	"context"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"math/big"
 	"net"
@@ -14,6 +15,8 @@ import (
 	"path/filepath"
 	"sync"
 	"time"
+
+	"github.com/nzions/sharedgolibs/internal/fileutil"
 )
 
 // CertStorage defines the interface for certificate storage
@@ -30,6 +33,38 @@ type CertStorage interface {
 	Count() (int, error)
 }
 
+// maxSerialAttempts bounds how often a certificate is re-issued when its
+// random serial number is already taken
+const maxSerialAttempts = 5
+
+// errSerialTaken reports a serial number already used by a stored certificate
+var errSerialTaken = errors.New("serial number already issued")
+
+// newSerialNumber picks a random serial number for a new certificate.
+// Overridable for tests.
+var newSerialNumber = func() (*big.Int, error) {
+	return rand.Int(rand.Reader, big.NewInt(1000000000))
+}
+
+// issueUnique issues certificates with generate until store accepts one
+// whose serial number isn't taken yet
+func issueUnique(generate func() (string, string, *IssuedCert, error), store func(*IssuedCert) error) (string, string, error) {
+	for attempt := 1; ; attempt++ {
+		certPEM, keyPEM, issuedCert, err := generate()
+		if err != nil {
+			return "", "", err
+		}
+		err = store(issuedCert)
+		if errors.Is(err, errSerialTaken) && attempt < maxSerialAttempts {
+			continue
+		}
+		if err != nil {
+			return "", "", err
+		}
+		return certPEM, keyPEM, nil
+	}
+}
+
 // RAMStorage implements in-memory certificate storage
 type RAMStorage struct {
 	certs map[string]*IssuedCert
@@ -47,36 +82,34 @@ func NewRAMStorage() *RAMStorage {
 // GenerateAndStore generates a certificate and stores it atomically in memory.
 // Returns PEM-encoded certificate, private key, and error if any.
 func (s *RAMStorage) GenerateAndStore(ca *CA, serviceName, serviceIP string, domains []string) (string, string, error) {
-	// Generate the certificate
-	serviceCertPEM, serviceKeyPEM, issuedCert, err := s.generateCertificate(ca, serviceName, serviceIP, domains)
-	if err != nil {
-		return "", "", err
-	}
-
-	// Store atomically
-	s.mutex.Lock()
-	s.certs[issuedCert.SerialNumber] = issuedCert
-	s.mutex.Unlock()
-
-	return serviceCertPEM, serviceKeyPEM, nil
+	return s.generateAndStore(context.Background(), ca, serviceName, serviceIP, domains, certOptions{})
 }
 
 // GenerateAndStoreV2 generates a certificate using the V2 API with automatic IP detection
 // and stores it atomically in memory.
 func (s *RAMStorage) GenerateAndStoreV2(ca *CA, serviceName string, sans []string) (string, string, error) {
-	// Use the existing generateCertificate method but pass empty serviceIP since
-	// IP addresses are now included in the sans array
-	serviceCertPEM, serviceKeyPEM, issuedCert, err := s.generateCertificate(ca, serviceName, "", sans)
-	if err != nil {
-		return "", "", err
+	// Pass empty serviceIP since IP addresses are included in the sans array
+	return s.generateAndStore(context.Background(), ca, serviceName, "", sans, certOptions{})
+}
+
+// generateAndStore is GenerateAndStore with per-certificate options,
+// traced as a child of ctx's span
+func (s *RAMStorage) generateAndStore(ctx context.Context, ca *CA, serviceName, serviceIP string, domains []string, opts certOptions) (string, string, error) {
+	generate := func() (string, string, *IssuedCert, error) {
+		return s.generateCertificate(ctx, ca, serviceName, serviceIP, domains, opts)
 	}
+	return issueUnique(generate, ca.tracedStore(ctx, "ram", s.store))
+}
 
-	// Store atomically
+// store adds a certificate unless its serial number is taken
+func (s *RAMStorage) store(issuedCert *IssuedCert) error {
 	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, taken := s.certs[issuedCert.SerialNumber]; taken {
+		return errSerialTaken
+	}
 	s.certs[issuedCert.SerialNumber] = issuedCert
-	s.mutex.Unlock()
-
-	return serviceCertPEM, serviceKeyPEM, nil
+	return nil
 }
 
 // GetAll returns all certificates from memory.
@@ -113,19 +146,61 @@ func (s *RAMStorage) Count() (int, error) {
 	return len(s.certs), nil
 }
 
+// recordUsage records a fetch or use of a certificate in memory
+func (s *RAMStorage) recordUsage(serial string, fetched bool, at time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	cert, exists := s.certs[serial]
+	if !exists {
+		return ErrCertificateNotFound
+	}
+	if updated := cert.withUsage(fetched, at); updated != nil {
+		s.certs[serial] = updated
+	}
+	return nil
+}
+
+// remove deletes certificates from memory
+func (s *RAMStorage) remove(serials []string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, serial := range serials {
+		delete(s.certs, serial)
+	}
+	return nil
+}
+
 // DiskStorage implements persistent certificate storage
 type DiskStorage struct {
 	persistDir string
 	certs      map[string]*IssuedCert
 	mutex      sync.RWMutex
+	keyCrypt   *KeyEncryptor // Encrypts leaf private keys at rest (nil = plaintext)
+
+	// Modification times observed at the last load/save, used to pick up
+	// changes made to cert-store.json or index.json by other processes
+	storeModTime time.Time
+	indexModTime time.Time
+
+	// onRevoked is called for certificates a reload finds newly revoked
+	onRevoked func(*IssuedCert)
 }
 
 // NewDiskStorage creates a new disk-based certificate storage for issued certificates.
 // Returns a pointer to DiskStorage and error if initialization fails.
 func NewDiskStorage(persistDir string) (*DiskStorage, error) {
+	return NewEncryptedDiskStorage(persistDir, nil)
+}
+
+// NewEncryptedDiskStorage creates a disk-based certificate storage that
+// encrypts leaf private keys in cert-store.json with enc. Encrypted keys are
+// decrypted transparently at load. A nil enc stores keys in plaintext.
+func NewEncryptedDiskStorage(persistDir string, enc *KeyEncryptor) (*DiskStorage, error) {
 	storage := &DiskStorage{
 		persistDir: persistDir,
 		certs:      make(map[string]*IssuedCert),
+		keyCrypt:   enc,
 	}
 
 	// Create directory if it doesn't exist
@@ -151,55 +226,84 @@ func NewDiskStorage(persistDir string) (*DiskStorage, error) {
 // GenerateAndStore generates a certificate and stores it atomically to disk.
 // Returns PEM-encoded certificate, private key, and error if any.
 func (s *DiskStorage) GenerateAndStore(ca *CA, serviceName, serviceIP string, domains []string) (string, string, error) {
-	// Generate the certificate
-	serviceCertPEM, serviceKeyPEM, issuedCert, err := s.generateCertificate(ca, serviceName, serviceIP, domains)
-	if err != nil {
-		return "", "", err
-	}
+	return s.generateAndStore(context.Background(), ca, serviceName, serviceIP, domains, certOptions{})
+}
 
-	// Store atomically (both in memory and on disk)
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+// GenerateAndStoreV2 generates a certificate using the V2 API with automatic IP detection
+// and stores it atomically to disk.
+func (s *DiskStorage) GenerateAndStoreV2(ca *CA, serviceName string, sans []string) (string, string, error) {
+	// Pass empty serviceIP since IP addresses are included in the sans array
+	return s.generateAndStore(context.Background(), ca, serviceName, "", sans, certOptions{})
+}
 
-	s.certs[issuedCert.SerialNumber] = issuedCert
-	err = s.saveToDisk()
-	if err != nil {
-		// Rollback the in-memory change if disk save fails
-		delete(s.certs, issuedCert.SerialNumber)
-		return "", "", fmt.Errorf("failed to persist certificate to disk: %w", err)
+// generateAndStore is GenerateAndStore with per-certificate options,
+// traced as a child of ctx's span
+func (s *DiskStorage) generateAndStore(ctx context.Context, ca *CA, serviceName, serviceIP string, domains []string, opts certOptions) (string, string, error) {
+	generate := func() (string, string, *IssuedCert, error) {
+		return s.generateCertificate(ctx, ca, serviceName, serviceIP, domains, opts)
 	}
+	return issueUnique(generate, ca.tracedStore(ctx, "disk", s.store))
+}
 
-	return serviceCertPEM, serviceKeyPEM, nil
+// store adds a certificate unless its serial number is taken, both in
+// memory and on disk
+func (s *DiskStorage) store(issuedCert *IssuedCert) error {
+	return s.modify(func() error {
+		return s.addLocked(issuedCert)
+	})
 }
 
-// GenerateAndStoreV2 generates a certificate using the V2 API with automatic IP detection
-// and stores it atomically to disk.
-func (s *DiskStorage) GenerateAndStoreV2(ca *CA, serviceName string, sans []string) (string, string, error) {
-	// Use the existing generateCertificate method but pass empty serviceIP since
-	// IP addresses are now included in the sans array
-	serviceCertPEM, serviceKeyPEM, issuedCert, err := s.generateCertificate(ca, serviceName, "", sans)
+// modify applies change to the merged certificates of every CA instance
+// sharing the persistence directory. It takes the directory lock and
+// reloads their certificates first; saving only this instance's view would
+// drop theirs.
+func (s *DiskStorage) modify(change func() error) error {
+	s.mutex.Lock()
+	unlock, err := lockPersistDir(s.persistDir)
 	if err != nil {
-		return "", "", err
+		s.mutex.Unlock()
+		return fmt.Errorf("failed to persist certificate to disk: %w", err)
 	}
+	// A malformed external write mustn't block issuance: carry on with the
+	// current view, and saving it repairs the files
+	revoked, err := s.reloadLocked()
+	if err != nil {
+		fmt.Printf("[ca] Failed to reload certificate store, keeping the current view: %v\n", err)
+	}
+	err = change()
+	unlock()
+	onRevoked := s.onRevoked
+	s.mutex.Unlock()
 
-	// Store atomically (both in memory and on disk)
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	if onRevoked != nil {
+		for _, cert := range revoked {
+			onRevoked(cert)
+		}
+	}
+	return err
+}
+
+// addLocked adds a certificate and saves the store (must be called with
+// the mutex and the directory lock held)
+func (s *DiskStorage) addLocked(issuedCert *IssuedCert) error {
+	if _, taken := s.certs[issuedCert.SerialNumber]; taken {
+		return errSerialTaken
+	}
 
 	s.certs[issuedCert.SerialNumber] = issuedCert
-	err = s.saveToDisk()
-	if err != nil {
+	if err := s.saveToDisk(); err != nil {
 		// Rollback the in-memory change if disk save fails
 		delete(s.certs, issuedCert.SerialNumber)
-		return "", "", fmt.Errorf("failed to persist certificate to disk: %w", err)
+		return fmt.Errorf("failed to persist certificate to disk: %w", err)
 	}
-
-	return serviceCertPEM, serviceKeyPEM, nil
+	return nil
 }
 
 // GetAll returns all certificates from disk storage.
 // Returns a slice of IssuedCert pointers and error if retrieval fails.
 func (s *DiskStorage) GetAll() ([]*IssuedCert, error) {
+	s.refreshIfChanged()
+
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
@@ -213,6 +317,8 @@ func (s *DiskStorage) GetAll() ([]*IssuedCert, error) {
 // GetBySerial returns a certificate by serial number from disk storage.
 // Returns the certificate and error if not found.
 func (s *DiskStorage) GetBySerial(serial string) (*IssuedCert, error) {
+	s.refreshIfChanged()
+
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
@@ -226,34 +332,131 @@ func (s *DiskStorage) GetBySerial(serial string) (*IssuedCert, error) {
 // Count returns the number of certificates in disk storage.
 // Returns the count and error if retrieval fails.
 func (s *DiskStorage) Count() (int, error) {
+	s.refreshIfChanged()
+
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 	return len(s.certs), nil
 }
 
+// recordUsage records a fetch or use of a certificate on disk. Repeats
+// within usageWriteInterval don't rewrite the store.
+func (s *DiskStorage) recordUsage(serial string, fetched bool, at time.Time) error {
+	s.refreshIfChanged()
+	s.mutex.RLock()
+	cert, exists := s.certs[serial]
+	s.mutex.RUnlock()
+	if !exists {
+		return ErrCertificateNotFound
+	}
+	if cert.withUsage(fetched, at) == nil {
+		return nil
+	}
+
+	return s.modify(func() error {
+		cert, exists := s.certs[serial]
+		if !exists {
+			return ErrCertificateNotFound
+		}
+		updated := cert.withUsage(fetched, at)
+		if updated == nil {
+			return nil // Recorded by another instance meanwhile
+		}
+		s.certs[serial] = updated
+		if err := s.saveToDisk(); err != nil {
+			s.certs[serial] = cert
+			return fmt.Errorf("failed to persist certificate usage: %w", err)
+		}
+		return nil
+	})
+}
+
+// remove deletes certificates from the store and index on disk
+func (s *DiskStorage) remove(serials []string) error {
+	return s.modify(func() error {
+		removed := make(map[string]*IssuedCert)
+		for _, serial := range serials {
+			if cert, exists := s.certs[serial]; exists {
+				removed[serial] = cert
+				delete(s.certs, serial)
+			}
+		}
+		if err := s.saveToDisk(); err != nil {
+			// Rollback the in-memory change if disk save fails
+			for serial, cert := range removed {
+				s.certs[serial] = cert
+			}
+			return fmt.Errorf("failed to persist purged certificates: %w", err)
+		}
+		return nil
+	})
+}
+
 // generateCertificate creates a new certificate for the given service and domains (RAMStorage).
 // Returns PEM-encoded certificate, private key, IssuedCert, and error if any.
-func (s *RAMStorage) generateCertificate(ca *CA, serviceName, serviceIP string, domains []string) (string, string, *IssuedCert, error) {
-	return generateCertificateInternal(ca, serviceName, serviceIP, domains)
+func (s *RAMStorage) generateCertificate(ctx context.Context, ca *CA, serviceName, serviceIP string, domains []string, opts certOptions) (string, string, *IssuedCert, error) {
+	return generateCertificateInternal(ctx, ca, serviceName, serviceIP, domains, opts)
 }
 
 // generateCertificate creates a new certificate for the given service and domains (DiskStorage).
 // Returns PEM-encoded certificate, private key, IssuedCert, and error if any.
-func (s *DiskStorage) generateCertificate(ca *CA, serviceName, serviceIP string, domains []string) (string, string, *IssuedCert, error) {
-	return generateCertificateInternal(ca, serviceName, serviceIP, domains)
+func (s *DiskStorage) generateCertificate(ctx context.Context, ca *CA, serviceName, serviceIP string, domains []string, opts certOptions) (string, string, *IssuedCert, error) {
+	return generateCertificateInternal(ctx, ca, serviceName, serviceIP, domains, opts)
+}
+
+// certOptions overrides per-certificate defaults; zero values keep the defaults
+type certOptions struct {
+	validity     time.Duration // Default DefaultLeafValidity
+	keyAlgorithm KeyAlgorithm  // Default the CA's leaf key algorithm
+	namespace    string        // Recorded on the IssuedCert; default none
+	template     *CertTemplate // Key usages; default server and client auth
+}
+
+// optionsStorage is implemented by storages that honor certOptions
+type optionsStorage interface {
+	generateAndStore(ctx context.Context, ca *CA, serviceName, serviceIP string, domains []string, opts certOptions) (string, string, error)
+}
+
+// selectCommonName picks the CommonName for a certificate:
+//  1. The first DNS name if available
+//  2. If no DNS names, the first IP
+//  3. If only URI/email SANs (e.g. a SPIFFE ID), the service name
+//
+// It never adds a .local suffix - names are used exactly as the client supplied them.
+func selectCommonName(serviceName string, sanSet *SANSet) string {
+	switch {
+	case len(sanSet.DNSNames) > 0:
+		return sanSet.DNSNames[0]
+	case len(sanSet.IPAddresses) > 0:
+		return sanSet.IPAddresses[0].String()
+	default:
+		return serviceName
+	}
 }
 
 // generateCertificateInternal contains the shared certificate generation logic for both storage types.
 // Returns PEM-encoded certificate, private key, IssuedCert, and error if any.
-func generateCertificateInternal(ca *CA, serviceName, serviceIP string, domains []string) (string, string, *IssuedCert, error) {
-	// Generate service private key
-	serviceKey, err := rsa.GenerateKey(rand.Reader, 2048)
+func generateCertificateInternal(ctx context.Context, ca *CA, serviceName, serviceIP string, domains []string, opts certOptions) (string, string, *IssuedCert, error) {
+	validity := opts.validity
+	if validity <= 0 {
+		validity = DefaultLeafValidity
+	}
+
+	// Generate service private key (from the key pool when enabled)
+	alg := opts.keyAlgorithm
+	if alg == "" {
+		alg = ca.LeafKeyAlgorithm()
+	}
+	_, keySpan := ca.startSpan(ctx, "ca.generate_key", attrKeyAlgorithm.String(string(alg)),
+		attrKeyPooled.Bool(ca.keyPool != nil && alg == ca.LeafKeyAlgorithm()))
+	serviceKey, err := ca.newLeafKeyFor(opts.keyAlgorithm)
+	endSpan(keySpan, err)
 	if err != nil {
 		return "", "", nil, fmt.Errorf("failed to generate service private key: %w", err)
 	}
 
-	// Generate serial number
-	serialNumber, err := rand.Int(rand.Reader, big.NewInt(1000000000))
+	// Generate serial number; storing the certificate checks it is unique
+	serialNumber, err := newSerialNumber()
 	if err != nil {
 		return "", "", nil, fmt.Errorf("failed to generate serial number: %w", err)
 	}
@@ -263,27 +466,14 @@ func generateCertificateInternal(ca *CA, serviceName, serviceIP string, domains
 		return "", "", nil, fmt.Errorf("domains/SANs cannot be empty")
 	}
 
-	// Determine the CommonName with new logic:
-	// 1. Use first non-IP domain if available
-	// 2. If only IPs, use first IP as CN
-	// 3. Never add .local suffix - use exactly what client supplies
-	var commonName string
-	var foundHostname bool
-
-	// First pass: look for non-IP domains
-	for _, domain := range domains {
-		if net.ParseIP(domain) == nil {
-			// This is not an IP address, use it as CN
-			commonName = domain
-			foundHostname = true
-			break
-		}
+	// Classify and validate SANs (DNS, IP, URI, email)
+	sanSet, err := ParseSANs(domains)
+	if err != nil {
+		return "", "", nil, err
 	}
 
-	// If no hostname found, use first IP as CN
-	if !foundHostname {
-		commonName = domains[0] // Use first IP address as CN
-	}
+	commonName := selectCommonName(serviceName, sanSet)
+	keyUsage, extKeyUsage := opts.template.keyUsages(alg)
 
 	// Create certificate template
 	template := x509.Certificate{
@@ -293,35 +483,27 @@ func generateCertificateInternal(ca *CA, serviceName, serviceIP string, domains
 			Organization: []string{"SharedGoLibs Services"},
 		},
 		NotBefore:             time.Now(),
-		NotAfter:              time.Now().Add(365 * 24 * time.Hour), // 1 year
-		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		NotAfter:              time.Now().Add(validity),
+		KeyUsage:              keyUsage,
+		ExtKeyUsage:           extKeyUsage,
 		BasicConstraintsValid: true,
+		DNSNames:              sanSet.DNSNames,
+		URIs:                  sanSet.URIs,
+		EmailAddresses:        sanSet.EmailAddresses,
 	}
 
-	// Keep track of added IPs to avoid duplicates
-	addedIPs := make(map[string]bool)
-
-	// Add service IP first if provided
+	// Add service IP first if provided, then IPs from the SANs without duplicates
+	var svcIP net.IP
 	if serviceIP != "" && serviceIP != "0.0.0.0" {
-		if ip := net.ParseIP(serviceIP); ip != nil {
-			template.IPAddresses = append(template.IPAddresses, ip)
-			addedIPs[serviceIP] = true
+		if svcIP = net.ParseIP(serviceIP); svcIP != nil {
+			template.IPAddresses = append(template.IPAddresses, svcIP)
 		}
 	}
-
-	// Process domains - separate DNS names from IP addresses
-	for _, domain := range domains {
-		if ip := net.ParseIP(domain); ip != nil {
-			// This is an IP address - add only if not already present
-			if !addedIPs[domain] {
-				template.IPAddresses = append(template.IPAddresses, ip)
-				addedIPs[domain] = true
-			}
-		} else {
-			// This is a DNS name
-			template.DNSNames = append(template.DNSNames, domain)
+	for _, ip := range sanSet.IPAddresses {
+		if svcIP != nil && svcIP.Equal(ip) {
+			continue
 		}
+		template.IPAddresses = append(template.IPAddresses, ip)
 	}
 
 	// Generate certificate using CA (need to protect CA access)
@@ -334,7 +516,13 @@ func generateCertificateInternal(ca *CA, serviceName, serviceIP string, domains
 		return "", "", nil, fmt.Errorf("CA not properly initialized")
 	}
 
-	certDER, err := x509.CreateCertificate(rand.Reader, &template, caCert, &serviceKey.PublicKey, caKey)
+	if err := checkNameConstraints(caCert, sanSet.DNSNames); err != nil {
+		return "", "", nil, err
+	}
+
+	_, signSpan := ca.startSpan(ctx, "ca.sign", attrSerial.String(fmt.Sprintf("%x", serialNumber)))
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, caCert, serviceKey.Public(), caKey)
+	endSpan(signSpan, err)
 	if err != nil {
 		return "", "", nil, fmt.Errorf("failed to create certificate: %w", err)
 	}
@@ -346,10 +534,10 @@ func generateCertificateInternal(ca *CA, serviceName, serviceIP string, domains
 	})
 
 	// Encode private key as PEM
-	serviceKeyPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(serviceKey),
-	})
+	serviceKeyPEM, err := marshalKeyPEM(serviceKey)
+	if err != nil {
+		return "", "", nil, err
+	}
 
 	// Create IssuedCert record
 	issuedCert := &IssuedCert{
@@ -360,50 +548,191 @@ func generateCertificateInternal(ca *CA, serviceName, serviceIP string, domains
 		Certificate:  string(serviceCertPEM),
 		PrivateKey:   string(serviceKeyPEM),
 		SerialNumber: fmt.Sprintf("%x", serialNumber),
+		Namespace:    opts.namespace,
 	}
 
 	return string(serviceCertPEM), string(serviceKeyPEM), issuedCert, nil
 }
 
-// saveToDisk saves the certificate store to disk (must be called with mutex locked).
+// saveToDisk saves the certificate store and index to disk (must be called with mutex locked).
 // Returns error if saving fails.
 func (s *DiskStorage) saveToDisk() error {
 	certStorePath := filepath.Join(s.persistDir, "cert-store.json")
 
-	data, err := json.MarshalIndent(s.certs, "", "  ")
+	certs, err := s.encryptedCerts()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(certs, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal certificate store: %w", err)
 	}
 
-	if err := os.WriteFile(certStorePath, data, 0644); err != nil {
+	if err := fileutil.WriteAtomic(certStorePath, data, 0644); err != nil {
 		return fmt.Errorf("failed to save certificate store: %w", err)
 	}
 
+	if err := writeCertIndex(s.persistDir, buildIndex(s.certs)); err != nil {
+		return fmt.Errorf("failed to save certificate index: %w", err)
+	}
+
+	s.storeModTime, s.indexModTime = s.fileModTimes()
 	return nil
 }
 
-// loadFromDisk loads the certificate store from disk.
-// Returns error if loading fails.
+// loadFromDisk loads the certificate store from disk and merges index.json
+// (must be called with mutex locked or before the storage is shared).
+// Returns error if loading fails, leaving the modification times alone so
+// the files are read again once fixed.
 func (s *DiskStorage) loadFromDisk() error {
 	certStorePath := filepath.Join(s.persistDir, "cert-store.json")
+	storeModTime, indexModTime := s.fileModTimes()
 
-	// Check if file exists
-	if _, err := os.Stat(certStorePath); os.IsNotExist(err) {
-		return nil // No existing store to load
+	data, err := os.ReadFile(certStorePath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read certificate store: %w", err)
 	}
 
-	data, err := os.ReadFile(certStorePath)
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.certs); err != nil {
+			return fmt.Errorf("failed to unmarshal certificate store: %w", err)
+		}
+	}
+
+	if err := s.decryptKeys(); err != nil {
+		return err
+	}
+
+	index, err := ReadCertIndex(s.persistDir)
 	if err != nil {
-		return fmt.Errorf("failed to read certificate store: %w", err)
+		return err
 	}
 
-	if len(data) == 0 {
-		return nil // Empty file, nothing to load
+	// Entries added externally appear as metadata-only certificates; revocation
+	// state recorded in the index wins over the store
+	for _, entry := range index.Entries {
+		if entry.SerialNumber == "" || len(entry.SANs) == 0 {
+			continue // Not enough information to be useful
+		}
+		if cert, exists := s.certs[entry.SerialNumber]; exists {
+			cert.Revoked = entry.Revoked
+			cert.RevokedAt = entry.RevokedAt
+			cert.LastFetchedAt = laterTime(cert.LastFetchedAt, entry.LastFetchedAt)
+			cert.LastUsedAt = laterTime(cert.LastUsedAt, entry.LastUsedAt)
+			continue
+		}
+		s.certs[entry.SerialNumber] = entry.issuedCert()
+	}
+
+	s.storeModTime, s.indexModTime = storeModTime, indexModTime
+	return nil
+}
+
+// encryptedCerts returns the certificate map to write to cert-store.json,
+// with private keys encrypted if encryption at rest is configured
+func (s *DiskStorage) encryptedCerts() (map[string]*IssuedCert, error) {
+	if s.keyCrypt == nil {
+		return s.certs, nil
 	}
 
-	if err := json.Unmarshal(data, &s.certs); err != nil {
-		return fmt.Errorf("failed to unmarshal certificate store: %w", err)
+	certs := make(map[string]*IssuedCert, len(s.certs))
+	for serial, cert := range s.certs {
+		if cert.PrivateKey == "" {
+			certs[serial] = cert
+			continue
+		}
+		encrypted, err := s.keyCrypt.EncryptPEM([]byte(cert.PrivateKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt private key for %s: %w", serial, err)
+		}
+		copied := *cert
+		copied.PrivateKey = string(encrypted)
+		certs[serial] = &copied
 	}
+	return certs, nil
+}
 
+// decryptKeys decrypts private keys loaded from cert-store.json in place
+func (s *DiskStorage) decryptKeys() error {
+	for serial, cert := range s.certs {
+		if cert.PrivateKey == "" || !isEncryptedKeyPEM([]byte(cert.PrivateKey)) {
+			continue
+		}
+		if s.keyCrypt == nil {
+			return fmt.Errorf("failed to load private key for %s: %w", serial, ErrKeyEncrypted)
+		}
+		decrypted, err := s.keyCrypt.DecryptPEM([]byte(cert.PrivateKey))
+		if err != nil {
+			return fmt.Errorf("failed to decrypt private key for %s: %w", serial, err)
+		}
+		cert.PrivateKey = string(decrypted)
+	}
 	return nil
 }
+
+// Reload discards in-memory state and re-reads the certificate store and
+// index from disk.
+func (s *DiskStorage) Reload() error {
+	s.mutex.Lock()
+	revoked, err := s.reloadLocked()
+	onRevoked := s.onRevoked
+	s.mutex.Unlock()
+
+	if onRevoked != nil {
+		for _, cert := range revoked {
+			onRevoked(cert)
+		}
+	}
+	return err
+}
+
+// reloadLocked re-reads the certificate store and index (must be called with
+// mutex locked) and returns the certificates newly revoked in the index. If
+// either file can't be loaded the current view is kept.
+func (s *DiskStorage) reloadLocked() ([]*IssuedCert, error) {
+	previous := s.certs
+	s.certs = make(map[string]*IssuedCert)
+	if err := s.loadFromDisk(); err != nil {
+		s.certs = previous
+		return nil, err
+	}
+
+	// Report revocations recorded in the index since the last load
+	var revoked []*IssuedCert
+	for serial, cert := range s.certs {
+		if old, known := previous[serial]; cert.Revoked && (!known || !old.Revoked) {
+			revoked = append(revoked, cert)
+		}
+	}
+	return revoked, nil
+}
+
+// refreshIfChanged reloads from disk when another process has modified
+// cert-store.json or index.json since the last load or save.
+func (s *DiskStorage) refreshIfChanged() {
+	storeMod, indexMod := s.fileModTimes()
+
+	s.mutex.RLock()
+	changed := !storeMod.Equal(s.storeModTime) || !indexMod.Equal(s.indexModTime)
+	s.mutex.RUnlock()
+
+	if changed {
+		// Keep serving the current view if the external write is malformed
+		if err := s.Reload(); err != nil {
+			fmt.Printf("[ca] Failed to reload certificate store: %v\n", err)
+		}
+	}
+}
+
+// fileModTimes returns the modification times of cert-store.json and
+// index.json (zero if missing).
+func (s *DiskStorage) fileModTimes() (store, index time.Time) {
+	if info, err := os.Stat(filepath.Join(s.persistDir, "cert-store.json")); err == nil {
+		store = info.ModTime()
+	}
+	if info, err := os.Stat(filepath.Join(s.persistDir, IndexFileName)); err == nil {
+		index = info.ModTime()
+	}
+	return store, index
+}
@@ -0,0 +1,492 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // Hashes for RS256/ES256 and RS384/ES384
+	_ "crypto/sha512" // and RS512/ES512
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nzions/sharedgolibs/pkg/util"
+)
+
+// ErrInvalidToken is returned when a bearer token fails verification
+var ErrInvalidToken = errors.New("invalid bearer token")
+
+// Defaults for TokenAuthConfig
+const (
+	DefaultJWKSRefreshInterval = 15 * time.Minute
+	DefaultTokenClockSkew      = time.Minute
+)
+
+// minRSAKeyBits is the smallest RSA modulus accepted from the JWKS
+const minRSAKeyBits = 2048
+
+// jwksMinRefetch limits JWKS fetches triggered by unknown key IDs, so tokens
+// with made-up kids can't be used to hammer the identity provider
+const jwksMinRefetch = 10 * time.Second
+
+// TokenAuthConfig enables bearer token (JWT) authentication for the CA
+// server. Tokens are verified against the keys published at JWKSURL, as
+// issued by an OIDC provider or a local identity emulator.
+type TokenAuthConfig struct {
+	JWKSURL  string // JSON Web Key Set URL, e.g. http://idp:8080/.well-known/jwks.json
+	Issuer   string // Required "iss" claim (empty = not checked)
+	Audience string // Required entry in the "aud" claim (empty = not checked)
+
+	ClockSkew       time.Duration // Leeway for exp and nbf (0 = DefaultTokenClockSkew)
+	RefreshInterval time.Duration // How long fetched keys are cached (0 = DefaultJWKSRefreshInterval)
+	HTTPClient      *http.Client  // Client for fetching the JWKS (nil = 10s timeout client)
+}
+
+// TokenClaims are the verified registered claims of a bearer token
+type TokenClaims struct {
+	Issuer    string    `json:"iss"`
+	Subject   string    `json:"sub"`
+	Audience  []string  `json:"aud"`
+	ExpiresAt time.Time `json:"exp"`
+}
+
+// TokenVerifier verifies bearer tokens against a JWKS. Keys are cached for
+// the refresh interval and refetched early when a token names an unknown key.
+type TokenVerifier struct {
+	config *TokenAuthConfig
+	client *http.Client
+	now    func() time.Time
+
+	mutex     sync.Mutex
+	keys      map[string]*jsonWebKey
+	fetchedAt time.Time
+	fetching  chan struct{} // Closed when the JWKS fetch in flight is done
+	fetchErr  error         // Of the last fetch
+}
+
+// NewTokenVerifier creates a verifier for config. Keys are fetched on first use.
+func NewTokenVerifier(config *TokenAuthConfig) (*TokenVerifier, error) {
+	if config == nil || config.JWKSURL == "" {
+		return nil, errors.New("token auth requires a JWKS URL")
+	}
+	if !strings.HasPrefix(config.JWKSURL, "http://") && !strings.HasPrefix(config.JWKSURL, "https://") {
+		return nil, fmt.Errorf("JWKS URL %q must be http or https", config.JWKSURL)
+	}
+
+	client := config.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &TokenVerifier{config: config, client: client, now: time.Now}, nil
+}
+
+// jsonWebKey is a public key from a JWKS
+type jsonWebKey struct {
+	KeyID     string `json:"kid"`
+	KeyType   string `json:"kty"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC
+	Curve string `json:"crv"`
+	X     string `json:"x"`
+	Y     string `json:"y"`
+
+	publicKey crypto.PublicKey
+}
+
+// parse decodes the key material, or returns an error for unsupported keys
+func (k *jsonWebKey) parse() error {
+	decode := base64.RawURLEncoding.DecodeString
+	switch k.KeyType {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return fmt.Errorf("bad modulus: %v", err)
+		}
+		e, err := decode(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return errors.New("bad exponent")
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.N.BitLen() < minRSAKeyBits {
+			return fmt.Errorf("RSA modulus of %d bits is below %d", key.N.BitLen(), minRSAKeyBits)
+		}
+		if key.E < 3 {
+			return fmt.Errorf("RSA exponent %d is too small", key.E)
+		}
+		k.publicKey = key
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return fmt.Errorf("bad x coordinate: %v", err)
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return fmt.Errorf("bad y coordinate: %v", err)
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return errors.New("point is not on the curve")
+		}
+		k.publicKey = key
+	default:
+		return fmt.Errorf("unsupported key type %q", k.KeyType)
+	}
+	return nil
+}
+
+// signingAlgorithms maps supported JWS algorithms to their hash
+var signingAlgorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+// verifySignature checks a JWS signature over signed with key
+func verifySignature(key crypto.PublicKey, alg string, signed, signature []byte) error {
+	hash, ok := signingAlgorithms[alg]
+	if !ok {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("algorithm %s does not match an RSA key", alg)
+		}
+		return rsa.VerifyPKCS1v15(key, hash, digest, signature)
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") {
+			return fmt.Errorf("algorithm %s does not match an EC key", alg)
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("bad signature length")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.New("signature mismatch")
+		}
+		return nil
+	}
+	return errors.New("unsupported key")
+}
+
+// audience accepts the "aud" claim as a string or an array of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New("aud must be a string or an array of strings")
+	}
+	*a = list
+	return nil
+}
+
+// Verify checks a compact JWS token's signature and its exp, nbf, iss, and
+// aud claims. Errors wrap ErrInvalidToken.
+func (v *TokenVerifier) Verify(token string) (*TokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: not a JWT", ErrInvalidToken)
+	}
+
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	if _, ok := signingAlgorithms[header.Algorithm]; !ok {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, header.Algorithm)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrInvalidToken, err)
+	}
+	key, err := v.key(header.KeyID, header.Algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if err := verifySignature(key.publicKey, header.Algorithm, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	var claims struct {
+		Issuer    string   `json:"iss"`
+		Subject   string   `json:"sub"`
+		Audience  audience `json:"aud"`
+		ExpiresAt *float64 `json:"exp"`
+		NotBefore *float64 `json:"nbf"`
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+
+	skew := v.config.ClockSkew
+	if skew == 0 {
+		skew = DefaultTokenClockSkew
+	}
+	now := v.now()
+	if claims.ExpiresAt == nil {
+		return nil, fmt.Errorf("%w: missing exp claim", ErrInvalidToken)
+	}
+	expiresAt := time.Unix(int64(*claims.ExpiresAt), 0)
+	if now.After(expiresAt.Add(skew)) {
+		return nil, fmt.Errorf("%w: expired at %s", ErrInvalidToken, expiresAt.UTC().Format(time.RFC3339))
+	}
+	if claims.NotBefore != nil {
+		if notBefore := time.Unix(int64(*claims.NotBefore), 0); now.Add(skew).Before(notBefore) {
+			return nil, fmt.Errorf("%w: not valid before %s", ErrInvalidToken, notBefore.UTC().Format(time.RFC3339))
+		}
+	}
+	if v.config.Issuer != "" && claims.Issuer != v.config.Issuer {
+		return nil, fmt.Errorf("%w: issuer %q, want %q", ErrInvalidToken, claims.Issuer, v.config.Issuer)
+	}
+	if v.config.Audience != "" && !slices.Contains(claims.Audience, v.config.Audience) {
+		return nil, fmt.Errorf("%w: audience %v does not include %q", ErrInvalidToken, []string(claims.Audience), v.config.Audience)
+	}
+
+	return &TokenClaims{Issuer: claims.Issuer, Subject: claims.Subject, Audience: claims.Audience, ExpiresAt: expiresAt}, nil
+}
+
+// decodeSegment decodes a base64url JSON segment of a token
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// key returns the signing key for kid, fetching the JWKS when the cache is
+// stale or, at most every jwksMinRefetch, when kid is unknown. Without a kid
+// the token must match the only key usable for alg. The JWKS is fetched
+// without holding the lock, once for all callers needing it, so tokens
+// with cached keys don't wait for a slow identity provider.
+func (v *TokenVerifier) key(kid, alg string) (*jsonWebKey, error) {
+	v.mutex.Lock()
+	refresh := v.config.RefreshInterval
+	if refresh == 0 {
+		refresh = DefaultJWKSRefreshInterval
+	}
+	now := v.now()
+	stale := v.keys == nil || now.Sub(v.fetchedAt) > refresh
+
+	if !stale {
+		key, err := v.findKey(kid, alg)
+		if err == nil || (v.fetching == nil && now.Sub(v.fetchedAt) < jwksMinRefetch) {
+			v.mutex.Unlock()
+			return key, err
+		}
+	}
+
+	done := v.fetching
+	if done == nil {
+		done = v.startFetch()
+	}
+	v.mutex.Unlock()
+	<-done
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if v.keys == nil {
+		return nil, v.fetchErr
+	}
+	// Keep serving the cached keys while the provider is unreachable
+	return v.findKey(kid, alg)
+}
+
+// startFetch fetches the JWKS in the background and returns the channel
+// closed once the keys are replaced; mutex must be held
+func (v *TokenVerifier) startFetch() chan struct{} {
+	done := make(chan struct{})
+	v.fetching = done
+	v.fetchedAt = v.now()
+	go func() {
+		keys, err := v.fetchKeys()
+		v.mutex.Lock()
+		if err == nil {
+			v.keys = keys
+		}
+		v.fetchErr = err
+		v.fetching = nil
+		v.mutex.Unlock()
+		close(done)
+	}()
+	return done
+}
+
+// findKey looks up a cached key usable for alg
+func (v *TokenVerifier) findKey(kid, alg string) (*jsonWebKey, error) {
+	usable := func(key *jsonWebKey) bool {
+		return key.Algorithm == "" || key.Algorithm == alg
+	}
+
+	if kid != "" {
+		key, ok := v.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+		if !usable(key) {
+			return nil, fmt.Errorf("key %q is for %s, token uses %s", kid, key.Algorithm, alg)
+		}
+		return key, nil
+	}
+
+	var match *jsonWebKey
+	for _, key := range v.keys {
+		if usable(key) {
+			if match != nil {
+				return nil, errors.New("token has no kid and the key set has several keys")
+			}
+			match = key
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("no key for %s", alg)
+	}
+	return match, nil
+}
+
+// fetchKeys fetches the current JWKS. Encryption keys and keys of
+// unsupported types are skipped.
+func (v *TokenVerifier) fetchKeys() (map[string]*jsonWebKey, error) {
+	resp, err := v.client.Get(v.config.JWKSURL)
+	if err != nil {
+		return nil, fmt.Errorf("fetching JWKS: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching JWKS: %s returned %d", v.config.JWKSURL, resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []*jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("parsing JWKS: %v", err)
+	}
+
+	keys := make(map[string]*jsonWebKey, len(set.Keys))
+	for i, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		if err := key.parse(); err != nil {
+			continue
+		}
+		id := key.KeyID
+		if id == "" {
+			id = fmt.Sprintf("#%d", i) // Only reachable by tokens without a kid
+		}
+		keys[id] = key
+	}
+	return keys, nil
+}
+
+// bearerToken returns the token from an "Authorization: Bearer" header
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// withAuth requires a valid API key (X-API-Key header or api_key query
+// parameter) or, when verifier is set, a valid bearer token. Either one is
+// enough, so existing API key clients keep working when tokens are enabled.
+func withAuth(apiKey string, verifier *TokenVerifier, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if apiKey == "" && verifier == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if apiKey != "" && requestAPIKey(r) == apiKey {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		message := "Unauthorized: Invalid or missing API key"
+		if verifier != nil {
+			message = "Unauthorized: Invalid or missing credentials"
+			if token := bearerToken(r); token != "" {
+				_, err := verifier.Verify(token)
+				if err == nil {
+					next.ServeHTTP(w, r)
+					return
+				}
+				message = "Unauthorized: " + err.Error()
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ca"`)
+		}
+		writeUnauthorized(w, message)
+	})
+}
+
+// requestAPIKey returns the API key from the X-API-Key header or the
+// api_key query parameter
+func requestAPIKey(r *http.Request) string {
+	if provided := r.Header.Get("X-API-Key"); provided != "" {
+		return provided
+	}
+	return r.URL.Query().Get("api_key")
+}
+
+// writeUnauthorized writes a 401 with a JSON error body
+func writeUnauthorized(w http.ResponseWriter, message string) {
+	body, _ := json.Marshal(map[string]string{"error": message})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	w.Write(body)
+}
+
+// setAuthHeaders adds the client credentials from SGL_CA_API_KEY and
+// SGL_CA_TOKEN to a request for the CA server
+func setAuthHeaders(req *http.Request) {
+	if apiKey := util.MustGetEnv("SGL_CA_API_KEY", ""); apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	if token := util.MustGetEnv("SGL_CA_TOKEN", ""); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
@@ -0,0 +1,316 @@
+package ca
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testIdentityProvider serves a JWKS and signs tokens, like a local OIDC emulator
+type testIdentityProvider struct {
+	rsaKey  *rsa.PrivateKey
+	ecKey   *ecdsa.PrivateKey
+	jwks    atomic.Value // []map[string]string
+	fetches atomic.Int32
+	server  *httptest.Server
+}
+
+func newTestIdentityProvider(t *testing.T) *testIdentityProvider {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	idp := &testIdentityProvider{rsaKey: rsaKey, ecKey: ecKey}
+	b64 := base64.RawURLEncoding.EncodeToString
+	idp.jwks.Store([]map[string]string{
+		{"kid": "rsa-1", "kty": "RSA", "alg": "RS256", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kid": "ec-1", "kty": "EC", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+		{"kid": "enc-1", "kty": "RSA", "use": "enc", "n": b64(rsaKey.N.Bytes()), "e": "AQAB"},
+	})
+	idp.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idp.fetches.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{"keys": idp.jwks.Load()})
+	}))
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// sign creates a compact JWS with the given header and claims
+func (idp *testIdentityProvider) sign(t *testing.T, header, claims map[string]any) string {
+	t.Helper()
+	encode := func(v any) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := encode(header) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch header["alg"] {
+	case "RS256":
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, idp.rsaKey, crypto.SHA256, digest[:])
+	case "ES256":
+		r, s, _ := ecdsa.Sign(rand.Reader, idp.ecKey, digest[:])
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"iss": "http://idp.local",
+		"sub": "svc-a",
+		"aud": []string{"other", "ca"},
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"nbf": time.Now().Add(-time.Minute).Unix(),
+	}
+}
+
+func TestTokenVerifier(t *testing.T) {
+	idp := newTestIdentityProvider(t)
+	verifier, err := NewTokenVerifier(&TokenAuthConfig{JWKSURL: idp.server.URL, Issuer: "http://idp.local", Audience: "ca"})
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+
+	for _, alg := range []string{"RS256", "ES256"} {
+		kid := map[string]string{"RS256": "rsa-1", "ES256": "ec-1"}[alg]
+		claims, err := verifier.Verify(idp.sign(t, map[string]any{"alg": alg, "kid": kid}, validClaims()))
+		if err != nil {
+			t.Errorf("Expected %s token to verify, got %v", alg, err)
+			continue
+		}
+		if claims.Subject != "svc-a" || claims.Issuer != "http://idp.local" {
+			t.Errorf("Unexpected claims: %+v", claims)
+		}
+	}
+
+	single := validClaims()
+	single["aud"] = "ca"
+	if _, err := verifier.Verify(idp.sign(t, map[string]any{"alg": "RS256", "kid": "rsa-1"}, single)); err != nil {
+		t.Errorf("Expected a string audience to verify, got %v", err)
+	}
+
+	tamper := func(claim string, value any) map[string]any {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, claim)
+		} else {
+			claims[claim] = value
+		}
+		return claims
+	}
+	rejected := map[string]string{
+		"expired":        idp.sign(t, map[string]any{"alg": "RS256", "kid": "rsa-1"}, tamper("exp", time.Now().Add(-time.Hour).Unix())),
+		"no exp":         idp.sign(t, map[string]any{"alg": "RS256", "kid": "rsa-1"}, tamper("exp", nil)),
+		"not yet valid":  idp.sign(t, map[string]any{"alg": "RS256", "kid": "rsa-1"}, tamper("nbf", time.Now().Add(time.Hour).Unix())),
+		"wrong issuer":   idp.sign(t, map[string]any{"alg": "RS256", "kid": "rsa-1"}, tamper("iss", "http://evil")),
+		"wrong audience": idp.sign(t, map[string]any{"alg": "RS256", "kid": "rsa-1"}, tamper("aud", "billing")),
+		"unknown kid":    idp.sign(t, map[string]any{"alg": "RS256", "kid": "rsa-9"}, validClaims()),
+		"encryption key": idp.sign(t, map[string]any{"alg": "RS256", "kid": "enc-1"}, validClaims()),
+		"alg mismatch":   idp.sign(t, map[string]any{"alg": "ES256", "kid": "rsa-1"}, validClaims()),
+		"alg none":       idp.sign(t, map[string]any{"alg": "none", "kid": "rsa-1"}, validClaims()),
+		"not a jwt":      "abc.def",
+	}
+	valid := idp.sign(t, map[string]any{"alg": "RS256", "kid": "rsa-1"}, validClaims())
+	parts := strings.Split(valid, ".")
+	forged, _ := json.Marshal(tamper("sub", "admin"))
+	rejected["forged claims"] = parts[0] + "." + base64.RawURLEncoding.EncodeToString(forged) + "." + parts[2]
+
+	for name, token := range rejected {
+		if _, err := verifier.Verify(token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+}
+
+func TestTokenVerifierKeyRotation(t *testing.T) {
+	idp := newTestIdentityProvider(t)
+	verifier, _ := NewTokenVerifier(&TokenAuthConfig{JWKSURL: idp.server.URL})
+	now := time.Now()
+	verifier.now = func() time.Time { return now }
+
+	token := idp.sign(t, map[string]any{"alg": "RS256", "kid": "rsa-1"}, validClaims())
+	for i := 0; i < 3; i++ {
+		if _, err := verifier.Verify(token); err != nil {
+			t.Fatalf("Expected token to verify, got %v", err)
+		}
+	}
+	if got := idp.fetches.Load(); got != 1 {
+		t.Errorf("Expected keys to be cached after one fetch, got %d fetches", got)
+	}
+
+	// A new kid is picked up once the refetch limit allows it
+	keys := idp.jwks.Load().([]map[string]string)
+	rotated := map[string]string{}
+	for k, v := range keys[0] {
+		rotated[k] = v
+	}
+	rotated["kid"] = "rsa-2"
+	idp.jwks.Store(append(keys, rotated))
+
+	rotatedToken := idp.sign(t, map[string]any{"alg": "RS256", "kid": "rsa-2"}, validClaims())
+	if _, err := verifier.Verify(rotatedToken); err == nil {
+		t.Error("Expected unknown kid to fail within the refetch limit")
+	}
+	now = now.Add(jwksMinRefetch + time.Second)
+	if _, err := verifier.Verify(rotatedToken); err != nil {
+		t.Errorf("Expected rotated key to verify after refetch, got %v", err)
+	}
+	if got := idp.fetches.Load(); got != 2 {
+		t.Errorf("Expected 2 fetches, got %d", got)
+	}
+
+	// Cached keys keep working while the provider is down
+	idp.server.Close()
+	now = now.Add(DefaultJWKSRefreshInterval + time.Second)
+	if _, err := verifier.Verify(token); err != nil {
+		t.Errorf("Expected cached keys to be used when the JWKS is unreachable, got %v", err)
+	}
+}
+
+func TestTokenVerifierSlowProvider(t *testing.T) {
+	idp := newTestIdentityProvider(t)
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": idp.jwks.Load()})
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	verifier, _ := NewTokenVerifier(&TokenAuthConfig{JWKSURL: slow.URL})
+	now := time.Now()
+	verifier.now = func() time.Time { return now }
+	token := idp.sign(t, map[string]any{"alg": "RS256", "kid": "rsa-1"}, validClaims())
+	if _, err := verifier.Verify(token); err != nil {
+		t.Fatalf("Expected token to verify, got %v", err)
+	}
+
+	// An unknown kid refetches; the fetch hangs, but cached keys don't wait
+	now = now.Add(jwksMinRefetch + time.Second)
+	go verifier.Verify(idp.sign(t, map[string]any{"alg": "RS256", "kid": "rsa-9"}, validClaims()))
+	for fetches.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	verified := make(chan error, 1)
+	go func() {
+		_, err := verifier.Verify(token)
+		verified <- err
+	}()
+	select {
+	case err := <-verified:
+		if err != nil {
+			t.Errorf("Expected the cached key to verify, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("Expected a cached key not to wait for the JWKS fetch")
+	}
+}
+
+func TestJSONWebKeyRejectsWeakRSA(t *testing.T) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	strong, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	cases := map[string]*jsonWebKey{
+		"1024-bit modulus": {KeyType: "RSA", N: b64(weak.N.Bytes()), E: "AQAB"},
+		"exponent 1":       {KeyType: "RSA", N: b64(strong.N.Bytes()), E: "AQ"},
+	}
+	for name, key := range cases {
+		if err := key.parse(); err == nil {
+			t.Errorf("%s: expected the key to be rejected", name)
+		}
+	}
+	if err := (&jsonWebKey{KeyType: "RSA", N: b64(strong.N.Bytes()), E: "AQAB"}).parse(); err != nil {
+		t.Errorf("Expected a 2048-bit key to parse, got %v", err)
+	}
+}
+
+func TestNewTokenVerifierValidation(t *testing.T) {
+	for _, config := range []*TokenAuthConfig{nil, {}, {JWKSURL: "file:///etc/jwks.json"}} {
+		if _, err := NewTokenVerifier(config); err == nil {
+			t.Errorf("Expected an error for %+v", config)
+		}
+	}
+}
+
+func TestWithAuth(t *testing.T) {
+	idp := newTestIdentityProvider(t)
+	verifier, _ := NewTokenVerifier(&TokenAuthConfig{JWKSURL: idp.server.URL, Audience: "ca"})
+	token := idp.sign(t, map[string]any{"alg": "ES256", "kid": "ec-1"}, validClaims())
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
+	cases := []struct {
+		name     string
+		apiKey   string
+		verifier *TokenVerifier
+		header   map[string]string
+		query    string
+		want     int
+	}{
+		{"NoAuthConfigured", "", nil, nil, "", http.StatusOK},
+		{"APIKeyOnlyMissing", "secret", nil, nil, "", http.StatusUnauthorized},
+		{"APIKeyOnlyHeader", "secret", nil, map[string]string{"X-API-Key": "secret"}, "", http.StatusOK},
+		{"APIKeyOnlyQuery", "secret", nil, nil, "?api_key=secret", http.StatusOK},
+		{"TokenOnlyValid", "", verifier, map[string]string{"Authorization": "Bearer " + token}, "", http.StatusOK},
+		{"TokenOnlyLowercaseScheme", "", verifier, map[string]string{"Authorization": "bearer " + token}, "", http.StatusOK},
+		{"TokenOnlyInvalid", "", verifier, map[string]string{"Authorization": "Bearer x.y.z"}, "", http.StatusUnauthorized},
+		{"TokenOnlyMissing", "", verifier, nil, "", http.StatusUnauthorized},
+		{"BothWithAPIKey", "secret", verifier, map[string]string{"X-API-Key": "secret"}, "", http.StatusOK},
+		{"BothWithToken", "secret", verifier, map[string]string{"Authorization": "Bearer " + token}, "", http.StatusOK},
+		{"BothWithWrongKey", "secret", verifier, map[string]string{"X-API-Key": "wrong"}, "", http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/ca"+c.query, nil)
+			for k, v := range c.header {
+				req.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+			withAuth(c.apiKey, c.verifier, ok).ServeHTTP(rr, req)
+			if rr.Code != c.want {
+				t.Errorf("Expected %d, got %d: %s", c.want, rr.Code, rr.Body.String())
+			}
+			if rr.Code == http.StatusUnauthorized && c.verifier != nil && rr.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected a WWW-Authenticate challenge")
+			}
+		})
+	}
+}
+
+func TestSetAuthHeaders(t *testing.T) {
+	t.Setenv("SGL_CA_API_KEY", "secret")
+	t.Setenv("SGL_CA_TOKEN", "abc.def.ghi")
+
+	req := httptest.NewRequest(http.MethodGet, "/ca", nil)
+	setAuthHeaders(req)
+	if req.Header.Get("X-API-Key") != "secret" || req.Header.Get("Authorization") != "Bearer abc.def.ghi" {
+		t.Errorf("Unexpected headers: %v", req.Header)
+	}
+	if token := bearerToken(req); token != "abc.def.ghi" {
+		t.Errorf("Expected the token back, got %q", token)
+	}
+}
@@ -0,0 +1,102 @@
+package ca
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of the CA's spans
+const tracerName = "github.com/nzions/sharedgolibs/pkg/ca"
+
+// Span attributes of the issuance path
+const (
+	attrServiceName  = attribute.Key("ca.service_name")
+	attrSANCount     = attribute.Key("ca.san.count")
+	attrKeyAlgorithm = attribute.Key("ca.key.algorithm")
+	attrKeyPooled    = attribute.Key("ca.key.pooled")
+	attrNamespace    = attribute.Key("ca.namespace")
+	attrTemplate     = attribute.Key("ca.template")
+	attrSerial       = attribute.Key("ca.serial")
+	attrStorage      = attribute.Key("ca.storage")
+)
+
+// startSpan starts a span of the issuance path as a child of ctx's span.
+// Without CAConfig.TracerProvider the global provider is used, which
+// records nothing until the application installs an OpenTelemetry SDK.
+func (ca *CA) startSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	provider := ca.tracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	tracer := provider.Tracer(tracerName, trace.WithInstrumentationVersion(Version))
+	return tracer.Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// endSpan ends a span, marking it failed when err is set
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// issueAttributes describe a certificate request on its ca.issue span
+func (ca *CA) issueAttributes(serviceName string, sans []string, opts certOptions) []attribute.KeyValue {
+	alg := opts.keyAlgorithm
+	if alg == "" {
+		alg = ca.LeafKeyAlgorithm()
+	}
+	attributes := []attribute.KeyValue{
+		attrServiceName.String(serviceName),
+		attrSANCount.Int(len(sans)),
+		attrKeyAlgorithm.String(string(alg)),
+	}
+	if opts.namespace != "" {
+		attributes = append(attributes, attrNamespace.String(opts.namespace))
+	}
+	return attributes
+}
+
+// tracedStore wraps a storage's store in a ca.persist span
+func (ca *CA) tracedStore(ctx context.Context, storage string, store func(*IssuedCert) error) func(*IssuedCert) error {
+	return func(issuedCert *IssuedCert) error {
+		_, span := ca.startSpan(ctx, "ca.persist", attrStorage.String(storage), attrSerial.String(issuedCert.SerialNumber))
+		err := store(issuedCert)
+		endSpan(span, err)
+		return err
+	}
+}
+
+// withTracing records a server span per request, continuing the client's
+// trace from its traceparent header with ServerConfig.TracePropagation
+func (s *Server) withTracing(handler http.Handler) http.Handler {
+	propagator := propagation.NewCompositeTextMapPropagator()
+	if s.propagateTrace {
+		propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+	}
+	options := []otelhttp.Option{
+		otelhttp.WithPropagators(propagator),
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Path
+		}),
+	}
+	if s.ca.tracerProvider != nil {
+		options = append(options, otelhttp.WithTracerProvider(s.ca.tracerProvider))
+	}
+	return otelhttp.NewHandler(handler, "ca", options...)
+}
+
+// injectTraceContext adds the trace context of the request's context to
+// its headers, with the global propagator the application configured
+// (see otel.SetTextMapPropagator), so the CA server can continue the trace
+func injectTraceContext(req *http.Request) {
+	otel.GetTextMapPropagator().Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+}
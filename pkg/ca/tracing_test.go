@@ -0,0 +1,126 @@
+package ca
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTracedCA returns a CA recording its spans
+func newTracedCA(t *testing.T) (*CA, *tracetest.SpanRecorder) {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	ca := newTestCA(t, func(config *CAConfig) {
+		config.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	})
+	t.Cleanup(ca.Close)
+	return ca, recorder
+}
+
+// spansByName indexes ended spans by name
+func spansByName(recorder *tracetest.SpanRecorder) map[string]sdktrace.ReadOnlySpan {
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	return spans
+}
+
+// spanAttribute returns an attribute of a span
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestIssuanceSpans(t *testing.T) {
+	ca, recorder := newTracedCA(t)
+
+	_, err := ca.IssueServiceCertificateV2(CertRequestV2{ServiceName: "api", SANs: []string{"api.local", "10.0.0.1"}, KeyAlgorithm: KeyAlgorithmECDSAP256})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spans := spansByName(recorder)
+	issue := spans["ca.issue"]
+	if issue == nil {
+		t.Fatalf("Expected a ca.issue span, got %v", spans)
+	}
+	if got := spanAttribute(issue, attrServiceName).AsString(); got != "api" {
+		t.Errorf("Expected ca.service_name api, got %q", got)
+	}
+	if got := spanAttribute(issue, attrSANCount).AsInt64(); got != 2 {
+		t.Errorf("Expected ca.san.count 2, got %d", got)
+	}
+	if got := spanAttribute(issue, attrKeyAlgorithm).AsString(); got != string(KeyAlgorithmECDSAP256) {
+		t.Errorf("Expected ca.key.algorithm %s, got %q", KeyAlgorithmECDSAP256, got)
+	}
+
+	for _, name := range []string{"ca.generate_key", "ca.sign", "ca.persist"} {
+		span := spans[name]
+		if span == nil {
+			t.Errorf("Expected a %s span", name)
+			continue
+		}
+		if span.Parent().SpanID() != issue.SpanContext().SpanID() {
+			t.Errorf("Expected %s under ca.issue", name)
+		}
+	}
+	if got := spanAttribute(spans["ca.persist"], attrStorage).AsString(); got != "ram" {
+		t.Errorf("Expected ca.storage ram, got %q", got)
+	}
+}
+
+func TestIssuanceSpanError(t *testing.T) {
+	ca, recorder := newTracedCA(t)
+
+	if _, err := ca.IssueServiceCertificateV2(CertRequestV2{ServiceName: "api", SANs: []string{"bad host"}}); err == nil {
+		t.Fatal("Expected an invalid SAN to fail")
+	}
+	issue := spansByName(recorder)["ca.issue"]
+	if issue == nil || issue.Status().Code != codes.Error {
+		t.Errorf("Expected a failed ca.issue span, got %+v", issue)
+	}
+}
+
+func TestServerTracePropagation(t *testing.T) {
+	const traceparent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	clientTrace, _ := trace.TraceIDFromHex("0af7651916cd43dd8448eb211c80319c")
+
+	for _, propagate := range []bool{true, false} {
+		ca, recorder := newTracedCA(t)
+		server := &Server{ca: ca, issueQueue: newIssueQueue(0, 0), propagateTrace: propagate}
+		handler := server.withTracing(http.HandlerFunc(server.handleCertRequest))
+
+		body := bytes.NewBufferString(`{"service_name": "api", "sans": ["api.local"]}`)
+		req := httptest.NewRequest(http.MethodPost, "/cert", body)
+		req.Header.Set("traceparent", traceparent)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+
+		spans := spansByName(recorder)
+		serverSpan, issue := spans["POST /cert"], spans["ca.issue"]
+		if serverSpan == nil || issue == nil {
+			t.Fatalf("Expected server and ca.issue spans, got %v", spans)
+		}
+		if issue.Parent().SpanID() != serverSpan.SpanContext().SpanID() {
+			t.Errorf("Expected ca.issue under the server span")
+		}
+		if continued := serverSpan.SpanContext().TraceID() == clientTrace; continued != propagate {
+			t.Errorf("TracePropagation %v: client trace continued = %v", propagate, continued)
+		}
+	}
+}
@@ -114,6 +114,7 @@ func getValidatedCAURL() (string, error) {
 // Environment Variables Used:
 //   - SGL_CA (required): CA server URL (must be http:// or https://)
 //   - SGL_CA_API_KEY (optional): API key for CA server authentication
+//   - SGL_CA_TOKEN (optional): Bearer token for CA server authentication
 //
 // Environment Variables Checked (will error if found):
 //   - STORAGE_EMULATOR_HOST, PUBSUB_EMULATOR_HOST, FIRESTORE_EMULATOR_HOST, etc.
@@ -147,6 +148,7 @@ func UpdateTransport() error {
 // Environment Variables Used:
 //   - SGL_CA (optional): CA server URL (must be http:// or https://) - if not set, function returns nil
 //   - SGL_CA_API_KEY (optional): API key for CA server authentication (only used if SGL_CA is set)
+//   - SGL_CA_TOKEN (optional): Bearer token for CA server authentication (only used if SGL_CA is set)
 //
 // Environment Variables Checked (will warn if found and SGL_CA is set):
 //   - STORAGE_EMULATOR_HOST, PUBSUB_EMULATOR_HOST, FIRESTORE_EMULATOR_HOST, etc.
@@ -185,6 +187,7 @@ func UpdateTransportOnlyIf() error {
 // Environment Variables Used:
 //   - SGL_CA (required): CA server URL (must be http:// or https://)
 //   - SGL_CA_API_KEY (optional): API key for CA server authentication
+//   - SGL_CA_TOKEN (optional): Bearer token for CA server authentication
 //
 // Environment Variables Checked (will warn if found):
 //   - STORAGE_EMULATOR_HOST, PUBSUB_EMULATOR_HOST, FIRESTORE_EMULATOR_HOST, etc.
@@ -207,7 +210,7 @@ func UpdateTransportMust() {
 //
 // This function:
 //  1. Makes a GET request to caURL+"/ca" to fetch the CA certificate
-//  2. Optionally includes SGL_CA_API_KEY header if the environment variable is set
+//  2. Optionally includes the SGL_CA_API_KEY and SGL_CA_TOKEN credentials if set
 //  3. Parses the returned PEM-encoded CA certificate
 //  4. Creates a new http.Transport with a TLS config trusting the CA
 //  5. Replaces both http.DefaultClient.Transport and http.DefaultTransport
@@ -228,11 +231,8 @@ func updateTransportWithCA(caURL string) error {
 		return fmt.Errorf("%w: %v", ErrCARequest, err)
 	}
 
-	// Add API key if configured
-	apiKey := util.MustGetEnv("SGL_CA_API_KEY", "")
-	if apiKey != "" {
-		req.Header.Set("X-API-Key", apiKey)
-	}
+	// Add API key or bearer token if configured
+	setAuthHeaders(req)
 
 	// Make the request
 	resp, err := http.DefaultClient.Do(req)
@@ -294,6 +294,7 @@ func updateTransportWithCA(caURL string) error {
 // Environment Variables Used:
 //   - SGL_CA (required): CA server URL (must be http:// or https://)
 //   - SGL_CA_API_KEY (optional): API key for CA server authentication
+//   - SGL_CA_TOKEN (optional): Bearer token for CA server authentication
 //
 // Parameters:
 //   - serviceName: Name of the service requesting the certificate
@@ -321,11 +322,8 @@ func RequestCertificate(serviceName, serviceIP string, domains []string) (*CertR
 		return nil, fmt.Errorf("%w: %v", ErrCARequest, err)
 	}
 
-	// Add API key if configured
-	apiKey := util.MustGetEnv("SGL_CA_API_KEY", "")
-	if apiKey != "" {
-		req.Header.Set("X-API-Key", apiKey)
-	}
+	// Add API key or bearer token if configured
+	setAuthHeaders(req)
 
 	// Make the request
 	resp, err := http.DefaultClient.Do(req)
@@ -339,6 +337,11 @@ func RequestCertificate(serviceName, serviceIP string, domains []string) (*CertR
 		return nil, ErrUnauthorized
 	}
 
+	// Names needing approval are held until an admin decides
+	if resp.StatusCode == http.StatusAccepted {
+		return awaitApproval(resp)
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("%w: server returned status %d", ErrCARequest, resp.StatusCode)
 	}
@@ -360,6 +363,7 @@ func RequestCertificate(serviceName, serviceIP string, domains []string) (*CertR
 // Environment Variables Used:
 //   - SGL_CA (required): CA server URL for certificate requests
 //   - SGL_CA_API_KEY (optional): API key for CA server authentication
+//   - SGL_CA_TOKEN (optional): Bearer token for CA server authentication
 //
 // Parameters:
 //   - serviceName: Name of the service for certificate generation
@@ -404,6 +408,7 @@ func CreateSecureHTTPSServer(serviceName, serviceIP, port string, domains []stri
 // Environment Variables Used:
 //   - SGL_CA (required): CA server URL for certificate requests
 //   - SGL_CA_API_KEY (optional): API key for CA server authentication
+//   - SGL_CA_TOKEN (optional): Bearer token for CA server authentication
 //
 // Parameters:
 //   - serviceName: Name of the service for certificate generation
@@ -447,6 +452,7 @@ func CreateSecureGRPCServer(serviceName, serviceIP string, domains []string, opt
 // Environment Variables Used:
 //   - SGL_CA (required): CA server URL to fetch the CA certificate from
 //   - SGL_CA_API_KEY (optional): API key for CA server authentication
+//   - SGL_CA_TOKEN (optional): Bearer token for CA server authentication
 //
 // Returns credentials.TransportCredentials that can be used with grpc.WithTransportCredentials()
 // for secure gRPC client connections.
@@ -462,11 +468,8 @@ func CreateGRPCCredentials() (credentials.TransportCredentials, error) {
 		return nil, fmt.Errorf("%w: %v", ErrCARequest, err)
 	}
 
-	// Add API key if configured
-	apiKey := util.MustGetEnv("SGL_CA_API_KEY", "")
-	if apiKey != "" {
-		req.Header.Set("X-API-Key", apiKey)
-	}
+	// Add API key or bearer token if configured
+	setAuthHeaders(req)
 
 	// Make the request
 	resp, err := http.DefaultClient.Do(req)
@@ -520,6 +523,7 @@ func CreateGRPCCredentials() (credentials.TransportCredentials, error) {
 // Environment Variables Used:
 //   - SGL_CA (required): CA server URL to fetch the CA certificate from
 //   - SGL_CA_API_KEY (optional): API key for CA server authentication
+//   - SGL_CA_TOKEN (optional): Bearer token for CA server authentication
 //
 // Returns a slice of grpc.DialOption that can be passed to grpc.Dial() or grpc.NewClient()
 // to establish secure connections to gRPC servers with CA-issued certificates.
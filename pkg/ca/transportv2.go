@@ -4,12 +4,11 @@ package ca
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"net/http"
-
-	"github.com/nzions/sharedgolibs/pkg/util"
 )
 
 // CreateSecureHTTPSServerV2 creates an HTTPS server with certificates from the CA using the V2 API.
@@ -18,6 +17,9 @@ import (
 // Environment Variables Used:
 //   - SGL_CA (required): CA server URL for certificate requests
 //   - SGL_CA_API_KEY (optional): API key for CA server authentication
+//   - SGL_CA_TOKEN (optional): Bearer token for CA server authentication
+//   - SGL_CA_CERT_LIFETIME (optional): Serve short-lived certificates of this
+//     lifetime (e.g. "1h"), renewed in the background (see NewShortLivedCertificate)
 //
 // Parameters:
 //   - serviceName: Name of the service for certificate generation
@@ -27,6 +29,22 @@ import (
 //
 // Returns a configured *SecureHTTPSServer with TLS certificates, ready to call ListenAndServeTLS().
 func CreateSecureHTTPSServerV2(serviceName, port string, sans []string, handler http.Handler) (*SecureHTTPSServer, error) {
+	lifetime, err := certLifetimeFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if lifetime > 0 {
+		rc, err := NewShortLivedCertificate(serviceName, sans, lifetime)
+		if err != nil {
+			return nil, err
+		}
+		return NewSecureHTTPSServer(&http.Server{
+			Addr:      ":" + port,
+			Handler:   handler,
+			TLSConfig: rc.TLSConfig(),
+		}), nil
+	}
+
 	// Request certificate from CA using V2 API
 	certResp, err := RequestCertificateV2(serviceName, sans)
 	if err != nil {
@@ -61,6 +79,7 @@ func CreateSecureHTTPSServerV2(serviceName, port string, sans []string, handler
 // Environment Variables Used:
 //   - SGL_CA (required): CA server URL (must be http:// or https://)
 //   - SGL_CA_API_KEY (optional): API key for CA server authentication
+//   - SGL_CA_TOKEN (optional): Bearer token for CA server authentication
 //
 // Parameters:
 //   - serviceName: Name of the service requesting the certificate
@@ -69,15 +88,43 @@ func CreateSecureHTTPSServerV2(serviceName, port string, sans []string, handler
 // Returns a CertResponse containing the PEM-encoded certificate and private key,
 // or an error if the request fails or authentication is required but invalid.
 func RequestCertificateV2(serviceName string, sans []string) (*CertResponse, error) {
-	caURL, err := getValidatedCAURL()
-	if err != nil {
-		return nil, err
-	}
+	return RequestCertificateV2Context(context.Background(), serviceName, sans)
+}
+
+// RequestCertificateV2Context is RequestCertificateV2 sending the trace
+// context of ctx's span with the application's global propagator (see
+// otel.SetTextMapPropagator), so a server with
+// ServerConfig.TracePropagation traces the issuance under that span.
+// Canceling ctx abandons the request.
+func RequestCertificateV2Context(ctx context.Context, serviceName string, sans []string) (*CertResponse, error) {
+	return requestCertificateV2Context(ctx, &CertRequestV2{
+		ServiceName: serviceName,
+		SANs:        sans,
+	})
+}
 
-	// Create certificate request with new V2 format
-	certReq := &CertRequestV2{
+// RequestCertificateWithTemplate is RequestCertificateV2 using a named
+// certificate template of the CA server, such as "grpc-service", "web", or
+// "client-auth", for the key usages, validity, and key algorithm. An unknown
+// template fails with ErrCARequest (status 400).
+func RequestCertificateWithTemplate(serviceName, template string, sans []string) (*CertResponse, error) {
+	return requestCertificateV2(&CertRequestV2{
 		ServiceName: serviceName,
 		SANs:        sans,
+		Template:    template,
+	})
+}
+
+// requestCertificateV2 sends a V2 certificate request to the SGL_CA server
+func requestCertificateV2(certReq *CertRequestV2) (*CertResponse, error) {
+	return requestCertificateV2Context(context.Background(), certReq)
+}
+
+// requestCertificateV2Context is requestCertificateV2 traced under ctx
+func requestCertificateV2Context(ctx context.Context, certReq *CertRequestV2) (*CertResponse, error) {
+	caURL, err := getValidatedCAURL()
+	if err != nil {
+		return nil, err
 	}
 
 	// Create HTTP request
@@ -85,12 +132,11 @@ func RequestCertificateV2(serviceName string, sans []string) (*CertResponse, err
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrCARequest, err)
 	}
+	req = req.WithContext(ctx)
 
-	// Add API key if configured
-	apiKey := util.MustGetEnv("SGL_CA_API_KEY", "")
-	if apiKey != "" {
-		req.Header.Set("X-API-Key", apiKey)
-	}
+	// Add API key or bearer token if configured, and the trace context
+	setAuthHeaders(req)
+	injectTraceContext(req)
 
 	// Make the request
 	resp, err := http.DefaultClient.Do(req)
@@ -104,6 +150,11 @@ func RequestCertificateV2(serviceName string, sans []string) (*CertResponse, err
 		return nil, ErrUnauthorized
 	}
 
+	// Names needing approval are held until an admin decides
+	if resp.StatusCode == http.StatusAccepted {
+		return awaitApproval(resp)
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("%w: server returned status %d", ErrCARequest, resp.StatusCode)
 	}
@@ -116,6 +167,38 @@ func RequestCertificateV2(serviceName string, sans []string) (*CertResponse, err
 	return &certResp, nil
 }
 
+// ReportCertificateUsage tells the SGL_CA server that the certificate with
+// the given serial number (hex, as in IssuedCert.SerialNumber) is in use, so
+// the CA's GUI doesn't mark it stale and PurgeUnusedCertificates keeps it.
+// ReloadingCertificate.PingUsage calls it periodically.
+func ReportCertificateUsage(serial string) error {
+	caURL, err := getValidatedCAURL()
+	if err != nil {
+		return err
+	}
+
+	req, err := createCertRequestV2(caURL+"/cert/usage", certUsageRequest{SerialNumber: serial})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCARequest, err)
+	}
+	setAuthHeaders(req)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCARequest, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusOK:
+		return nil
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	default:
+		return fmt.Errorf("%w: server returned status %d", ErrCARequest, resp.StatusCode)
+	}
+}
+
 // createCertRequestV2 creates an HTTP POST request for certificate generation using V2 format.
 // Serializes any certificate request struct to JSON and sets appropriate headers.
 //
@@ -0,0 +1,213 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime"
+	"strings"
+)
+
+// TrustStatus reports whether a CA root is in the operating system trust
+// store
+type TrustStatus struct {
+	Trusted      bool   `json:"trusted"`
+	CommonName   string `json:"common_name"`
+	Store        string `json:"store"`                  // The trust store checked, e.g. "macOS keychain"
+	Instructions string `json:"instructions,omitempty"` // How to install the root when it isn't trusted
+}
+
+// IsCATrustedBySystem fetches the root of the CA server in SGL_CA and checks
+// whether the system trust store (the macOS keychain, the Windows
+// certificate store, or the ca-certificates bundle elsewhere) trusts it.
+// Programs using UpdateTransport trust the CA regardless; this tells whether
+// browsers, curl, and other tools will. When the root isn't trusted,
+// Instructions explains how to install it on this platform.
+//
+// Environment Variables Used:
+//   - SGL_CA (required): CA server URL (must be http:// or https://)
+//   - SGL_CA_API_KEY (optional): API key for CA server authentication
+//   - SGL_CA_TOKEN (optional): Bearer token for CA server authentication
+func IsCATrustedBySystem() (*TrustStatus, error) {
+	caURL, err := getValidatedCAURL()
+	if err != nil {
+		return nil, err
+	}
+
+	// The root may not be trusted yet, which is the question, so don't
+	// require a trusted TLS certificate to fetch it
+	client := &http.Client{
+		Timeout:   diagnoseTimeout,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+	rootPEM, err := diagnoseFetchRoot(context.Background(), client, strings.TrimRight(caURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(rootPEM)
+	if block == nil {
+		return nil, fmt.Errorf("%w: GET /ca did not return a PEM certificate", ErrCARequest)
+	}
+	root, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: GET /ca returned an invalid certificate: %v", ErrCARequest, err)
+	}
+
+	status, err := rootTrustStatus(root)
+	if err != nil {
+		return nil, err
+	}
+	if !status.Trusted {
+		status.Instructions = trustStoreFix()
+	}
+	return status, nil
+}
+
+// rootTrustStatus checks root against this machine's trust store. On macOS
+// and Windows the platform verifier consults the keychain and certificate
+// store themselves.
+func rootTrustStatus(root *x509.Certificate) (*TrustStatus, error) {
+	pool, err := systemCertPool()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the system trust store: %w", err)
+	}
+	_, err = root.Verify(x509.VerifyOptions{Roots: pool})
+	return &TrustStatus{
+		Trusted:    err == nil,
+		CommonName: root.Subject.CommonName,
+		Store:      trustStoreName(runtime.GOOS),
+	}, nil
+}
+
+// trustStoreName names the system trust store of goos
+func trustStoreName(goos string) string {
+	switch goos {
+	case "darwin":
+		return "macOS keychain"
+	case "windows":
+		return "Windows certificate store"
+	default:
+		return "system CA bundle (ca-certificates)"
+	}
+}
+
+// trustStoreCommand installs sgl-ca.crt in the system trust store of goos
+func trustStoreCommand(goos string) string {
+	switch goos {
+	case "darwin":
+		return "sudo security add-trusted-cert -d -r trustRoot -k /Library/Keychains/System.keychain sgl-ca.crt"
+	case "windows":
+		return "certutil -addstore -f ROOT sgl-ca.crt"
+	default:
+		return "sudo cp sgl-ca.crt /usr/local/share/ca-certificates/ && sudo update-ca-certificates"
+	}
+}
+
+// Trust of the root by the browser viewing the dashboard
+const (
+	BrowserTrustTrusted   = "trusted"
+	BrowserTrustUntrusted = "untrusted"
+	BrowserTrustUnknown   = "unknown" // The browser's trust store can't be checked
+)
+
+// BrowserTrust is whether the browser viewing the dashboard trusts the
+// CA. Only the CA server's own trust store can be checked, so the status is
+// known only for browsers on the same machine that use the system store.
+type BrowserTrust struct {
+	Browser      string // Firefox, Chrome, Edge, or Safari; empty when unrecognized
+	Platform     string // The browser's OS as a GOOS, e.g. darwin; empty when unrecognized
+	Status       string // BrowserTrustTrusted, BrowserTrustUntrusted, or BrowserTrustUnknown
+	Detail       string
+	Instructions string // How to install the root for this browser, unless trusted
+}
+
+// browserTrust works out whether the browser making r trusts root. With
+// trustForwarded, the client address in X-Forwarded-For is the browser's.
+func browserTrust(root *x509.Certificate, r *http.Request, trustForwarded bool) BrowserTrust {
+	browser, platform := parseUserAgent(r.UserAgent())
+	trust := BrowserTrust{Browser: browser, Platform: platform, Status: BrowserTrustUnknown}
+	download := "Download ROOT CERT (PEM) as sgl-ca.crt, then "
+
+	switch {
+	case platform != "darwin" && platform != "windows" && platform != "linux":
+		trust.Detail = "Cannot tell which trust store this browser uses"
+		trust.Instructions = "Download ROOT CERT (PEM) and install it as a trusted CA certificate in the device settings"
+		return trust
+	case browser == "Firefox" && platform == "linux":
+		trust.Detail = "Firefox on Linux uses its own certificate store, not the system's"
+		trust.Instructions = download + "in Firefox open Settings → Privacy & Security → View Certificates → Authorities → Import, and check \"Trust this CA to identify websites\""
+		return trust
+	case (browser == "Chrome" || browser == "Edge") && platform == "linux":
+		trust.Detail = browser + " on Linux uses the NSS database in ~/.pki/nssdb, not the system store"
+		trust.Instructions = download + `certutil -d sql:$HOME/.pki/nssdb -A -t "C,," -n sgl-ca -i sgl-ca.crt (certutil is in libnss3-tools), and restart ` + browser
+		return trust
+	}
+
+	trust.Instructions = download + trustStoreCommand(platform)
+	if platform != runtime.GOOS || !isLoopbackRequest(r, trustForwarded) {
+		trust.Detail = fmt.Sprintf("This browser is not on the CA server's machine, so its %s cannot be checked", trustStoreName(platform))
+		return trust
+	}
+	status, err := rootTrustStatus(root)
+	switch {
+	case err != nil:
+		trust.Detail = "Trust check failed: " + err.Error()
+	case status.Trusted:
+		trust.Status = BrowserTrustTrusted
+		trust.Detail = fmt.Sprintf("%q is trusted by the %s", status.CommonName, status.Store)
+		trust.Instructions = ""
+	default:
+		trust.Status = BrowserTrustUntrusted
+		trust.Detail = fmt.Sprintf("%q is not in the %s; this browser will warn about certificates it issues", status.CommonName, status.Store)
+	}
+	return trust
+}
+
+// parseUserAgent recognizes the browser and its OS from a User-Agent
+func parseUserAgent(userAgent string) (browser, platform string) {
+	switch {
+	case strings.Contains(userAgent, "iPhone") || strings.Contains(userAgent, "iPad"):
+		platform = "ios"
+	case strings.Contains(userAgent, "Android"):
+		platform = "android"
+	case strings.Contains(userAgent, "Windows"):
+		platform = "windows"
+	case strings.Contains(userAgent, "Macintosh"):
+		platform = "darwin"
+	case strings.Contains(userAgent, "Linux") || strings.Contains(userAgent, "X11"):
+		platform = "linux"
+	}
+
+	// Chromium browsers also claim Safari, and Edge also claims Chrome
+	switch {
+	case strings.Contains(userAgent, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "Chrome/") || strings.Contains(userAgent, "Chromium/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	}
+	return browser, platform
+}
+
+// isLoopbackRequest reports whether r comes from the CA server's machine
+func isLoopbackRequest(r *http.Request, trustForwarded bool) bool {
+	if forwardedFor := firstHeaderValue(r, "X-Forwarded-For"); trustForwarded && forwardedFor != "" {
+		ip := net.ParseIP(forwardedFor)
+		return ip != nil && ip.IsLoopback()
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
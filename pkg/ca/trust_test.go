@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestIsCATrustedBySystem(t *testing.T) {
+	server, err := NewServer(&ServerConfig{CAConfig: DefaultCAConfig(), GUIAPIKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/ca", server.authenticate(http.HandlerFunc(server.handleCARequest)))
+	caServer := httptest.NewServer(mux)
+	defer caServer.Close()
+	t.Setenv("SGL_CA", caServer.URL)
+	t.Setenv("SGL_CA_TOKEN", "")
+
+	originalPool := systemCertPool
+	defer func() { systemCertPool = originalPool }()
+	systemCertPool = func() (*x509.CertPool, error) { return x509.NewCertPool(), nil }
+
+	t.Setenv("SGL_CA_API_KEY", "wrong")
+	if _, err := IsCATrustedBySystem(); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized, got %v", err)
+	}
+
+	t.Setenv("SGL_CA_API_KEY", "secret")
+	status, err := IsCATrustedBySystem()
+	if err != nil {
+		t.Fatalf("IsCATrustedBySystem failed: %v", err)
+	}
+	if status.Trusted || status.CommonName != server.ca.Certificate().Subject.CommonName || !strings.Contains(status.Instructions, "sgl-ca.crt") {
+		t.Errorf("Expected an untrusted root with instructions, got %+v", status)
+	}
+
+	systemCertPool = func() (*x509.CertPool, error) {
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(server.ca.CertificatePEM())
+		return pool, nil
+	}
+	if status, err := IsCATrustedBySystem(); err != nil || !status.Trusted || status.Instructions != "" {
+		t.Errorf("Expected a trusted root, got %+v, %v", status, err)
+	}
+}
+
+func TestBrowserTrust(t *testing.T) {
+	ca, err := NewCA(DefaultCAConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := ca.Certificate()
+
+	originalPool := systemCertPool
+	defer func() { systemCertPool = originalPool }()
+	systemCertPool = func() (*x509.CertPool, error) {
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(ca.CertificatePEM())
+		return pool, nil
+	}
+
+	userAgents := map[string]string{
+		"linux":   "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
+		"darwin":  "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_5) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Safari/605.1.15",
+		"windows": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36 Edg/126.0.0.0",
+	}
+	request := func(userAgent, remoteAddr string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("User-Agent", userAgent)
+		r.RemoteAddr = remoteAddr
+		return r
+	}
+
+	tests := []struct {
+		name       string
+		userAgent  string
+		remoteAddr string
+		browser    string
+		status     string
+		detail     string
+	}{
+		{"firefox on linux", "Mozilla/5.0 (X11; Linux x86_64; rv:127.0) Gecko/20100101 Firefox/127.0", "127.0.0.1:1234", "Firefox", BrowserTrustUnknown, "own certificate store"},
+		{"chrome on linux", userAgents["linux"], "127.0.0.1:1234", "Chrome", BrowserTrustUnknown, "NSS database"},
+		{"safari", userAgents["darwin"], "192.0.2.10:1234", "Safari", BrowserTrustUnknown, "not on the CA server's machine"},
+		{"edge", userAgents["windows"], "192.0.2.10:1234", "Edge", BrowserTrustUnknown, "Windows certificate store"},
+		{"iphone", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1", "127.0.0.1:1234", "Safari", BrowserTrustUnknown, "Cannot tell"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trust := browserTrust(root, request(tt.userAgent, tt.remoteAddr), false)
+			if trust.Browser != tt.browser || trust.Status != tt.status || !strings.Contains(trust.Detail, tt.detail) || trust.Instructions == "" {
+				t.Errorf("Unexpected trust: %+v", trust)
+			}
+		})
+	}
+
+	// A browser on this machine using the system store is checked. Chrome
+	// and Firefox on Linux keep their own stores, so use an unknown one.
+	userAgents["linux"] = "Mozilla/5.0 (X11; Linux x86_64)"
+	userAgent, ok := userAgents[runtime.GOOS]
+	if !ok {
+		t.Skipf("No test browser for %s", runtime.GOOS)
+	}
+	if trust := browserTrust(root, request(userAgent, "127.0.0.1:1234"), false); trust.Status != BrowserTrustTrusted || trust.Instructions != "" {
+		t.Errorf("Expected a trusted root, got %+v", trust)
+	}
+	forwarded := request(userAgent, "127.0.0.1:1234")
+	forwarded.Header.Set("X-Forwarded-For", "192.0.2.10")
+	if trust := browserTrust(root, forwarded, true); trust.Status != BrowserTrustUnknown {
+		t.Errorf("Expected a proxied browser to be unknown, got %+v", trust)
+	}
+}
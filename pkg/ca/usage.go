@@ -0,0 +1,240 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultStaleCertAge is how long a certificate can go without being fetched
+// or used before it counts as stale
+const DefaultStaleCertAge = 30 * 24 * time.Hour
+
+// usageWriteInterval limits how often repeated fetches or pings of a
+// certificate are written to disk
+const usageWriteInterval = time.Minute
+
+// ErrCertificateNotFound is returned for usage of an unknown serial number
+var ErrCertificateNotFound = errors.New("certificate not found")
+
+// usageStorage is implemented by storage backends that track certificate
+// usage and can remove certificates
+type usageStorage interface {
+	recordUsage(serial string, fetched bool, at time.Time) error
+	remove(serials []string) error
+}
+
+// LastActivity returns when the certificate was last fetched or used, or
+// the zero time if neither was ever recorded
+func (c *IssuedCert) LastActivity() time.Time {
+	var last time.Time
+	for _, t := range []*time.Time{c.LastFetchedAt, c.LastUsedAt} {
+		if t != nil && t.After(last) {
+			last = *t
+		}
+	}
+	return last
+}
+
+// NeverFetched reports whether the certificate was never downloaded or
+// reported in use since it was issued
+func (c *IssuedCert) NeverFetched() bool {
+	return c.LastFetchedAt == nil && c.LastUsedAt == nil
+}
+
+// IsStale reports whether the certificate went unused for staleAfter: its
+// last activity, or its issuance if it never had any, is older than that
+func (c *IssuedCert) IsStale(now time.Time, staleAfter time.Duration) bool {
+	last := c.LastActivity()
+	if last.IsZero() {
+		last = c.IssuedAt
+	}
+	return now.Sub(last) > staleAfter
+}
+
+// withUsage returns a copy of the certificate with a fetch or use recorded
+// at, or nil if one was recorded within usageWriteInterval. Certificates are
+// copied because readers hold the stored pointers without a lock.
+func (c *IssuedCert) withUsage(fetched bool, at time.Time) *IssuedCert {
+	last := c.LastUsedAt
+	if fetched {
+		last = c.LastFetchedAt
+	}
+	if last != nil && at.Sub(*last) < usageWriteInterval {
+		return nil
+	}
+
+	updated := *c
+	if fetched {
+		updated.LastFetchedAt = &at
+	} else {
+		updated.LastUsedAt = &at
+	}
+	return &updated
+}
+
+// laterTime returns the later of two optional times
+func laterTime(a, b *time.Time) *time.Time {
+	if a == nil || (b != nil && b.After(*a)) {
+		return b
+	}
+	return a
+}
+
+// StaleCertAge returns how long certificates go unused before the GUI marks
+// them stale
+func (ca *CA) StaleCertAge() time.Duration {
+	if ca.staleCertAge > 0 {
+		return ca.staleCertAge
+	}
+	return DefaultStaleCertAge
+}
+
+// RecordCertificateFetched records that the certificate with the given serial
+// number was downloaded. Repeated fetches within a minute are recorded once.
+func (ca *CA) RecordCertificateFetched(serial string) error {
+	return ca.recordUsage(serial, true)
+}
+
+// RecordCertificateUsed records that a service reported the certificate with
+// the given serial number in use, e.g. through a ReloadingCertificate usage
+// ping. Repeated pings within a minute are recorded once.
+func (ca *CA) RecordCertificateUsed(serial string) error {
+	return ca.recordUsage(serial, false)
+}
+
+// recordUsage records a fetch or use with the storage, if it tracks usage
+func (ca *CA) recordUsage(serial string, fetched bool) error {
+	storage, ok := ca.storage.(usageStorage)
+	if !ok {
+		return nil
+	}
+	return storage.recordUsage(serial, fetched, time.Now())
+}
+
+// PurgeUnusedCertificates removes the certificates that have not been
+// fetched or used for olderThan (see IssuedCert.IsStale) from the store and
+// index.json, and returns them. With dryRun, they are only returned.
+func (ca *CA) PurgeUnusedCertificates(olderThan time.Duration, dryRun bool) ([]*IssuedCert, error) {
+	if olderThan <= 0 {
+		return nil, fmt.Errorf("purge age must be positive, got %v", olderThan)
+	}
+	storage, ok := ca.storage.(usageStorage)
+	if !ok {
+		return nil, fmt.Errorf("certificate storage does not support purging")
+	}
+
+	now := time.Now()
+	var unused []*IssuedCert
+	var serials []string
+	for _, cert := range ca.GetIssuedCertificates() {
+		if cert.IsStale(now, olderThan) {
+			unused = append(unused, cert)
+			serials = append(serials, cert.SerialNumber)
+		}
+	}
+	if dryRun || len(unused) == 0 {
+		return unused, nil
+	}
+	if err := storage.remove(serials); err != nil {
+		return nil, err
+	}
+	return unused, nil
+}
+
+// certUsageRequest is the body of POST /cert/usage
+type certUsageRequest struct {
+	SerialNumber string `json:"serial_number"`
+}
+
+// handleCertUsage records a usage ping for an issued certificate. Scoped API
+// keys can only report certificates of their namespace.
+func (s *Server) handleCertUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req certUsageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SerialNumber == "" {
+		http.Error(w, "serial_number is required", http.StatusBadRequest)
+		return
+	}
+	cert, found := s.ca.GetCertificateBySerial(req.SerialNumber)
+	if !found || !visibleTo(r, cert) {
+		http.Error(w, "Certificate not found", http.StatusNotFound)
+		return
+	}
+	if err := s.ca.RecordCertificateUsed(req.SerialNumber); err != nil {
+		log.Printf("[ca] Failed to record usage of %s: %v", req.SerialNumber, err)
+		http.Error(w, "Failed to record usage", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// purgedCert describes a certificate in the POST /admin/purge-unused response
+type purgedCert struct {
+	SerialNumber  string     `json:"serial_number"`
+	ServiceName   string     `json:"service_name"`
+	IssuedAt      time.Time  `json:"issued_at"`
+	LastFetchedAt *time.Time `json:"last_fetched_at,omitempty"`
+	LastUsedAt    *time.Time `json:"last_used_at,omitempty"`
+}
+
+// handleAdminPurgeUnused removes certificates unused for ?older_than_days
+// (default the CA's StaleCertAge); ?dry_run=true only lists them
+func (s *Server) handleAdminPurgeUnused(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, scoped := requestNamespace(r); scoped {
+		http.Error(w, "Forbidden: purging requires the admin API key or a bearer token", http.StatusForbidden)
+		return
+	}
+
+	olderThan := s.ca.StaleCertAge()
+	if days := r.URL.Query().Get("older_than_days"); days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			http.Error(w, "older_than_days must be a positive number of days", http.StatusBadRequest)
+			return
+		}
+		olderThan = time.Duration(n) * 24 * time.Hour
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+	certs, err := s.ca.PurgeUnusedCertificates(olderThan, dryRun)
+	if err != nil {
+		log.Printf("[ca] Purging unused certificates failed: %v", err)
+		http.Error(w, "Purge failed", http.StatusInternalServerError)
+		return
+	}
+
+	purged := make([]purgedCert, 0, len(certs))
+	for _, cert := range certs {
+		purged = append(purged, purgedCert{
+			SerialNumber:  cert.SerialNumber,
+			ServiceName:   cert.ServiceName,
+			IssuedAt:      cert.IssuedAt,
+			LastFetchedAt: cert.LastFetchedAt,
+			LastUsedAt:    cert.LastUsedAt,
+		})
+	}
+	if !dryRun {
+		log.Printf("[ca] Purged %d certificate(s) unused for %d days, requested by %s", len(purged), int(olderThan/(24*time.Hour)), r.RemoteAddr)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"dry_run":      dryRun,
+		"certificates": purged,
+	})
+}
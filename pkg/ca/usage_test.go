@@ -0,0 +1,254 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// issueForUsage issues a certificate and returns its stored record
+func issueForUsage(t *testing.T, ca *CA, serviceName string) *IssuedCert {
+	t.Helper()
+	response, err := ca.IssueServiceCertificateV2(CertRequestV2{ServiceName: serviceName, SANs: []string{serviceName + ".local"}})
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+	cert, _ := parseCertificatePEM(response.Certificate)
+	issued, found := ca.GetCertificateBySerial(cert.SerialNumber.Text(16))
+	if !found {
+		t.Fatalf("Issued certificate %s not found", cert.SerialNumber.Text(16))
+	}
+	return issued
+}
+
+// backdate makes a stored certificate look issued age ago
+func backdate(t *testing.T, ca *CA, serial string, age time.Duration) {
+	t.Helper()
+	cert, _ := ca.GetCertificateBySerial(serial)
+	cert.IssuedAt = time.Now().Add(-age)
+}
+
+func TestCertificateUsage(t *testing.T) {
+	config := DefaultCAConfig()
+	config.KeySize = 2048
+	ca, err := NewCA(config)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+
+	cert := issueForUsage(t, ca, "orders")
+	if !cert.NeverFetched() || cert.IsStale(time.Now(), DefaultStaleCertAge) {
+		t.Fatalf("Expected a new certificate to be never fetched but not stale: %+v", cert)
+	}
+
+	if err := ca.RecordCertificateFetched(cert.SerialNumber); err != nil {
+		t.Fatalf("RecordCertificateFetched failed: %v", err)
+	}
+	fetched, _ := ca.GetCertificateBySerial(cert.SerialNumber)
+	if fetched.NeverFetched() || fetched.LastFetchedAt == nil || fetched.LastUsedAt != nil {
+		t.Errorf("Expected a fetch to be recorded, got %+v", fetched)
+	}
+
+	// Repeats within usageWriteInterval are not recorded again
+	if err := ca.RecordCertificateFetched(cert.SerialNumber); err != nil {
+		t.Fatalf("RecordCertificateFetched failed: %v", err)
+	}
+	if again, _ := ca.GetCertificateBySerial(cert.SerialNumber); again != fetched {
+		t.Error("Expected a repeated fetch to keep the record")
+	}
+
+	if err := ca.RecordCertificateUsed(cert.SerialNumber); err != nil {
+		t.Fatalf("RecordCertificateUsed failed: %v", err)
+	}
+	used, _ := ca.GetCertificateBySerial(cert.SerialNumber)
+	if used.LastUsedAt == nil || !used.LastActivity().Equal(*used.LastUsedAt) {
+		t.Errorf("Expected the use to be the last activity, got %+v", used)
+	}
+
+	if err := ca.RecordCertificateUsed("deadbeef"); !errors.Is(err, ErrCertificateNotFound) {
+		t.Errorf("Expected ErrCertificateNotFound, got %v", err)
+	}
+
+	// Activity, or issuance without any, decides staleness
+	now := time.Now()
+	old := now.Add(-40 * 24 * time.Hour)
+	for name, tt := range map[string]struct {
+		cert  IssuedCert
+		stale bool
+	}{
+		"new, never fetched":    {IssuedCert{IssuedAt: now}, false},
+		"old, never fetched":    {IssuedCert{IssuedAt: old}, true},
+		"old, used recently":    {IssuedCert{IssuedAt: old, LastUsedAt: &now}, false},
+		"old, fetched long ago": {IssuedCert{IssuedAt: old, LastFetchedAt: &old}, true},
+	} {
+		if stale := tt.cert.IsStale(now, DefaultStaleCertAge); stale != tt.stale {
+			t.Errorf("%s: IsStale() = %v, want %v", name, stale, tt.stale)
+		}
+	}
+}
+
+func TestCertificateUsagePersistence(t *testing.T) {
+	config := DefaultCAConfig()
+	config.KeySize = 2048
+	config.PersistDir = t.TempDir()
+	ca, err := NewCA(config)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+
+	kept := issueForUsage(t, ca, "kept")
+	unused := issueForUsage(t, ca, "unused")
+	if err := ca.RecordCertificateUsed(kept.SerialNumber); err != nil {
+		t.Fatalf("RecordCertificateUsed failed: %v", err)
+	}
+
+	index, err := ReadCertIndex(config.PersistDir)
+	if err != nil {
+		t.Fatalf("ReadCertIndex failed: %v", err)
+	}
+	for _, entry := range index.Entries {
+		if (entry.LastUsedAt != nil) != (entry.SerialNumber == kept.SerialNumber) {
+			t.Errorf("Unexpected usage in index entry %+v", entry)
+		}
+	}
+
+	// Another instance sharing the directory sees the usage
+	other, err := NewCA(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen CA: %v", err)
+	}
+	if cert, _ := other.GetCertificateBySerial(kept.SerialNumber); cert == nil || cert.LastUsedAt == nil {
+		t.Errorf("Expected the usage to be persisted, got %+v", cert)
+	}
+
+	backdate(t, ca, kept.SerialNumber, 60*24*time.Hour)
+	backdate(t, ca, unused.SerialNumber, 60*24*time.Hour)
+	purged, err := ca.PurgeUnusedCertificates(DefaultStaleCertAge, false)
+	if err != nil {
+		t.Fatalf("PurgeUnusedCertificates failed: %v", err)
+	}
+	if len(purged) != 1 || purged[0].SerialNumber != unused.SerialNumber {
+		t.Fatalf("Expected only the unused certificate to be purged, got %v", purged)
+	}
+	if _, found := other.GetCertificateBySerial(unused.SerialNumber); found {
+		t.Error("Expected the purged certificate to be gone from disk")
+	}
+	if _, found := other.GetCertificateBySerial(kept.SerialNumber); !found {
+		t.Error("Expected the used certificate to be kept")
+	}
+}
+
+func TestCertificateUsageHTTP(t *testing.T) {
+	config := DefaultCAConfig()
+	config.KeySize = 2048
+	server, err := NewServer(&ServerConfig{CAConfig: config, EnableGUI: true})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	ca := server.ca
+	cert := issueForUsage(t, ca, "api")
+
+	rr := httptest.NewRecorder()
+	server.gui.HandleDownloadCert(rr, httptest.NewRequest(http.MethodGet, "/cert/"+cert.SerialNumber, nil))
+	if fetched, _ := ca.GetCertificateBySerial(cert.SerialNumber); rr.Code != http.StatusOK || fetched.LastFetchedAt == nil {
+		t.Errorf("Expected the download to be recorded, got %d", rr.Code)
+	}
+
+	ping := func(body string) int {
+		rr := httptest.NewRecorder()
+		server.handleCertUsage(rr, httptest.NewRequest(http.MethodPost, "/cert/usage", strings.NewReader(body)))
+		return rr.Code
+	}
+	if code := ping(`{"serial_number": "` + cert.SerialNumber + `"}`); code != http.StatusNoContent {
+		t.Errorf("Expected 204 for a usage ping, got %d", code)
+	}
+	if code := ping(`{"serial_number": "deadbeef"}`); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown serial, got %d", code)
+	}
+	if code := ping(`{}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a serial, got %d", code)
+	}
+
+	// The GUI marks certificates never fetched and stale
+	stale := issueForUsage(t, ca, "abandoned")
+	backdate(t, ca, stale.SerialNumber, 45*24*time.Hour)
+	rr = httptest.NewRecorder()
+	server.gui.HandleCertsTable(rr, httptest.NewRequest(http.MethodGet, "/ui/certs-table", nil))
+	if strings.Count(rr.Body.String(), ">STALE<") != 1 || strings.Count(rr.Body.String(), ">NEVER FETCHED<") != 1 {
+		t.Errorf("Expected one stale, never fetched certificate in %s", rr.Body.String())
+	}
+
+	purge := func(query string) ([]purgedCert, int) {
+		rr := httptest.NewRecorder()
+		server.handleAdminPurgeUnused(rr, httptest.NewRequest(http.MethodPost, "/admin/purge-unused"+query, nil))
+		var result struct {
+			Certificates []purgedCert `json:"certificates"`
+		}
+		json.NewDecoder(rr.Body).Decode(&result)
+		return result.Certificates, rr.Code
+	}
+	if certs, code := purge("?dry_run=true"); code != http.StatusOK || len(certs) != 1 || ca.GetCertificateCount() != 2 {
+		t.Errorf("Expected a dry run to list the stale certificate only, got %d: %+v", code, certs)
+	}
+	if certs, code := purge("?older_than_days=60"); code != http.StatusOK || len(certs) != 0 {
+		t.Errorf("Expected nothing unused for 60 days, got %d: %+v", code, certs)
+	}
+	if certs, code := purge(""); code != http.StatusOK || len(certs) != 1 || certs[0].ServiceName != "abandoned" || ca.GetCertificateCount() != 1 {
+		t.Errorf("Expected the stale certificate to be purged, got %d: %+v", code, certs)
+	}
+	if _, code := purge("?older_than_days=0"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a zero age, got %d", code)
+	}
+
+	// This server has no admin credential, so the endpoint itself is refused
+	rr = httptest.NewRecorder()
+	server.authenticateAdmin(http.HandlerFunc(server.handleAdminPurgeUnused)).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/purge-unused", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 without an admin credential, got %d", rr.Code)
+	}
+}
+
+func TestReloadingCertificatePingUsage(t *testing.T) {
+	rc, _ := newTestReloadingCertificate(t)
+	var pings atomic.Int32
+	var serial atomic.Value
+	rc.reportUsage = func(s string) error {
+		serial.Store(s)
+		pings.Add(1)
+		return nil
+	}
+
+	now := time.Now()
+	rc.now = func() time.Time { return now }
+	rc.GetCertificate(nil)
+	time.Sleep(50 * time.Millisecond)
+	if pings.Load() != 0 {
+		t.Fatal("Expected no pings before PingUsage")
+	}
+
+	rc.PingUsage(time.Hour)
+	for i := 0; i < 3; i++ {
+		rc.GetCertificate(nil)
+	}
+	now = now.Add(2 * time.Hour)
+	cert, _ := rc.GetCertificate(nil)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for pings.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if pings.Load() != 2 {
+		t.Errorf("Expected one ping per interval, got %d", pings.Load())
+	}
+	if serial.Load() != cert.Leaf.SerialNumber.Text(16) {
+		t.Errorf("Expected the served serial, got %v", serial.Load())
+	}
+}
@@ -0,0 +1,432 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Roles of the certificates in a ChainVerification
+const (
+	ChainRoleLeaf         = "leaf"
+	ChainRoleIntermediate = "intermediate"
+	ChainRoleRoot         = "root"          // The CA's current root
+	ChainRolePreviousRoot = "previous root" // A root in the CA bundle other than the current one
+	ChainRoleUnknownRoot  = "unknown root"  // A self-signed certificate this CA doesn't publish
+)
+
+// maxChainLength bounds the issuers followed from a certificate
+const maxChainLength = 10
+
+// maxValidateBodySize bounds the PEM accepted by POST /validate
+const maxValidateBodySize = 1 << 20
+
+// ChainVerification is the result of VerifyChain: the parsed certificate,
+// its chain of issuers, and whether it is valid against the CA's roots.
+// Problems make a certificate invalid; warnings don't, but need attention,
+// such as a certificate issued by a previous root.
+type ChainVerification struct {
+	Chain    []*ChainCertificate `json:"chain"` // The certificate first, its root last
+	Valid    bool                `json:"valid"`
+	Problems []string            `json:"problems,omitempty"`
+	Warnings []string            `json:"warnings,omitempty"`
+	At       time.Time           `json:"at"` // The time the chain was verified as of
+
+	// Issued is the CA's record of the certificate, if it issued it
+	Issued *IssuedCert `json:"issued,omitempty"`
+}
+
+// ChainCertificate is the parsed form of one certificate in a chain
+type ChainCertificate struct {
+	Role               string     `json:"role"` // One of the ChainRole constants
+	Subject            string     `json:"subject"`
+	Issuer             string     `json:"issuer"`
+	SerialNumber       string     `json:"serial_number"` // Hex, as in IssuedCert
+	NotBefore          time.Time  `json:"not_before"`
+	NotAfter           time.Time  `json:"not_after"`
+	SANs               []SANEntry `json:"sans,omitempty"`
+	KeyAlgorithm       string     `json:"key_algorithm"`
+	SignatureAlgorithm string     `json:"signature_algorithm"`
+	KeyUsage           []string   `json:"key_usage,omitempty"`
+	ExtKeyUsage        []string   `json:"ext_key_usage,omitempty"`
+	IsCA               bool       `json:"is_ca"`
+	Fingerprint        string     `json:"fingerprint"` // SHA-256 of the DER, hex
+
+	// Status is "valid", "expired", or "not yet valid" at verification time
+	Status string `json:"status"`
+}
+
+// Leaf returns the verified certificate
+func (v *ChainVerification) Leaf() *ChainCertificate {
+	return v.Chain[0]
+}
+
+// VerifyChain parses PEM certificates, the first being the certificate to
+// verify and any others intermediates, and verifies it against the CA's
+// current root and the previous roots published in its bundle. Problems are
+// explained in terms of the CA, e.g. a certificate signed by a root that was
+// replaced since. It returns an error only if the PEM holds no certificate.
+func (ca *CA) VerifyChain(pemData []byte) (*ChainVerification, error) {
+	return ca.ValidateAt(pemData, time.Now())
+}
+
+// ValidateAt verifies a chain like VerifyChain, but as of at instead of
+// now, so tests can check how clients behave just before and after a
+// certificate expires without mocking their clocks. Revocation is checked
+// against the CA's current records whatever the time.
+func (ca *CA) ValidateAt(pemData []byte, at time.Time) (*ChainVerification, error) {
+	certs, err := parseBundleCertsPEM(pemData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCertParse, err)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%w: no certificate in PEM data", ErrCertParse)
+	}
+	return ca.verifyChain(certs[0], certs[1:], at), nil
+}
+
+// verifyChain verifies cert at now, with extra intermediates
+func (ca *CA) verifyChain(cert *x509.Certificate, intermediates []*x509.Certificate, now time.Time) *ChainVerification {
+	bundle := ca.Bundle()
+	root := bundle[0]
+	v := &ChainVerification{At: now}
+
+	// Follow the issuers as far as the supplied and published certificates go
+	chain := []*x509.Certificate{cert}
+	candidates := append(append([]*x509.Certificate{}, intermediates...), bundle...)
+	for current := cert; !isSelfSigned(current) && len(chain) < maxChainLength; {
+		issuer := findIssuer(current, candidates)
+		if issuer == nil {
+			v.Problems = append(v.Problems, missingIssuerProblem(current, root))
+			break
+		}
+		chain = append(chain, issuer)
+		current = issuer
+	}
+
+	for i, c := range chain {
+		info := newChainCertificate(c, chainRole(c, i, root, bundle), now)
+		v.Chain = append(v.Chain, info)
+		switch info.Status {
+		case "expired":
+			v.Problems = append(v.Problems, fmt.Sprintf("%s %s expired on %s (%s ago)",
+				info.Role, describeCert(c), c.NotAfter.Format(time.RFC3339), formatAge(now.Sub(c.NotAfter))))
+		case "not yet valid":
+			v.Problems = append(v.Problems, fmt.Sprintf("%s %s is not valid until %s; check the clock of the machine that issued or is using it",
+				info.Role, describeCert(c), c.NotBefore.Format(time.RFC3339)))
+		}
+	}
+
+	top := v.Chain[len(v.Chain)-1]
+	switch {
+	case len(chain) == 1 && top.Role == ChainRoleRoot:
+		v.Warnings = append(v.Warnings, "this is the CA's root certificate itself")
+	case top.Role == ChainRolePreviousRoot:
+		v.Warnings = append(v.Warnings, fmt.Sprintf("signed by previous root %s (SHA-256 %s) before rotation; "+
+			"clients that trust only the current root reject it, so re-issue it", describeCert(chain[len(chain)-1]), top.Fingerprint))
+	case top.Role == ChainRoleUnknownRoot:
+		v.Problems = append(v.Problems, fmt.Sprintf("chains to root %s, which is not a root of this CA", describeCert(chain[len(chain)-1])))
+	}
+
+	if issued, ok := ca.GetCertificateBySerial(v.Leaf().SerialNumber); ok && bytes.Equal(issuedDER(issued), cert.Raw) {
+		v.Issued = issued
+		if issued.Revoked {
+			problem := "revoked"
+			if issued.RevokedAt != nil {
+				problem += " on " + issued.RevokedAt.Format(time.RFC3339)
+			}
+			v.Problems = append(v.Problems, problem)
+		}
+	}
+
+	// The checks above explain the usual failures; the standard verifier
+	// catches the rest, such as name constraints and key usage
+	if len(v.Problems) == 0 && top.Role != ChainRoleLeaf {
+		if err := verifyWithPool(chain, now); err != nil {
+			v.Problems = append(v.Problems, fmt.Sprintf("verification failed: %v", err))
+		}
+	}
+	v.Valid = len(v.Problems) == 0
+	return v
+}
+
+// handleValidate verifies the PEM chain in the request body with ValidateAt,
+// as of ?at= (RFC 3339 or Unix seconds) or now. An invalid chain is still a
+// 200; the result says why.
+func (s *Server) handleValidate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	at := time.Now()
+	if value := r.URL.Query().Get("at"); value != "" {
+		var err error
+		if at, err = parseValidateTime(value); err != nil {
+			http.Error(w, "at must be an RFC 3339 timestamp or Unix seconds", http.StatusBadRequest)
+			return
+		}
+	}
+	pemData, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxValidateBodySize))
+	if err != nil {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	v, err := s.ca.ValidateAt(pemData, at)
+	if err != nil {
+		http.Error(w, "Body must be a PEM certificate, optionally followed by intermediates", http.StatusBadRequest)
+		return
+	}
+	if v.Issued != nil && !visibleTo(r, v.Issued) {
+		v.Issued = nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// parseValidateTime parses the ?at= of /validate
+func parseValidateTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// ValidateCertificateAt asks the SGL_CA server to verify a PEM chain as of
+// at, like CA.ValidateAt, for tests that talk to a shared CA server
+func ValidateCertificateAt(pemData []byte, at time.Time) (*ChainVerification, error) {
+	caURL, err := getValidatedCAURL()
+	if err != nil {
+		return nil, err
+	}
+
+	validateURL := caURL + "/validate?at=" + url.QueryEscape(at.Format(time.RFC3339))
+	req, err := http.NewRequest(http.MethodPost, validateURL, bytes.NewReader(pemData))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCARequest, err)
+	}
+	req.Header.Set("Content-Type", "application/x-pem-file")
+	setAuthHeaders(req)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCARequest, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return nil, ErrUnauthorized
+	case http.StatusBadRequest:
+		return nil, fmt.Errorf("%w: no certificate in PEM data", ErrCertParse)
+	default:
+		return nil, fmt.Errorf("%w: server returned status %d", ErrCARequest, resp.StatusCode)
+	}
+
+	var v ChainVerification
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCAResponse, err)
+	}
+	return &v, nil
+}
+
+// verifyWithPool runs the standard verifier over a chain found by verifyChain
+func verifyWithPool(chain []*x509.Certificate, now time.Time) error {
+	if len(chain) == 1 {
+		return nil // A root by itself
+	}
+	roots, intermediates := x509.NewCertPool(), x509.NewCertPool()
+	roots.AddCert(chain[len(chain)-1])
+	for _, c := range chain[1 : len(chain)-1] {
+		intermediates.AddCert(c)
+	}
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
+
+// findIssuer returns the candidate whose key signed cert
+func findIssuer(cert *x509.Certificate, candidates []*x509.Certificate) *x509.Certificate {
+	for _, candidate := range candidates {
+		if candidate.Equal(cert) || !bytes.Equal(candidate.RawSubject, cert.RawIssuer) {
+			continue
+		}
+		if cert.CheckSignatureFrom(candidate) == nil {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// isSelfSigned reports whether cert is signed by its own key
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(cert) == nil
+}
+
+// missingIssuerProblem explains why no issuer of cert was found
+func missingIssuerProblem(cert, root *x509.Certificate) string {
+	if bytes.Equal(cert.RawIssuer, root.RawSubject) {
+		return fmt.Sprintf("signed by a previous root named %q that is no longer published; "+
+			"it was issued before the root was rotated or regenerated, so re-issue it", root.Subject.CommonName)
+	}
+	return fmt.Sprintf("issuer %q is neither this CA's root nor in its bundle; "+
+		"paste the intermediates after the certificate if it has any", cert.Issuer.String())
+}
+
+// chainRole returns the role of the certificate at position i of a chain
+func chainRole(cert *x509.Certificate, i int, root *x509.Certificate, bundle []*x509.Certificate) string {
+	switch {
+	case cert.Equal(root):
+		return ChainRoleRoot
+	case isSelfSigned(cert):
+		for _, published := range bundle[1:] {
+			if published.Equal(cert) {
+				return ChainRolePreviousRoot
+			}
+		}
+		return ChainRoleUnknownRoot
+	case i == 0:
+		return ChainRoleLeaf
+	default:
+		return ChainRoleIntermediate
+	}
+}
+
+// newChainCertificate parses the fields of cert shown by the GUI
+func newChainCertificate(cert *x509.Certificate, role string, now time.Time) *ChainCertificate {
+	fingerprint := sha256.Sum256(cert.Raw)
+	info := &ChainCertificate{
+		Role:               role,
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		SerialNumber:       fmt.Sprintf("%x", cert.SerialNumber),
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		SANs:               sanEntries(certSANs(cert)),
+		KeyAlgorithm:       keyDescription(cert),
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		KeyUsage:           keyUsageNames(cert.KeyUsage),
+		ExtKeyUsage:        extKeyUsageNames(cert.ExtKeyUsage),
+		IsCA:               cert.IsCA,
+		Fingerprint:        hex.EncodeToString(fingerprint[:]),
+		Status:             "valid",
+	}
+	switch {
+	case now.After(cert.NotAfter):
+		info.Status = "expired"
+	case now.Before(cert.NotBefore):
+		info.Status = "not yet valid"
+	}
+	return info
+}
+
+// certSANs lists a certificate's SANs in the form ParseSANs accepts
+func certSANs(cert *x509.Certificate) []string {
+	var sans []string
+	sans = append(sans, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	return append(sans, cert.EmailAddresses...)
+}
+
+// keyDescription names the key type and size, e.g. "RSA 2048" or "ECDSA P-256"
+func keyDescription(cert *x509.Certificate) string {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA " + key.Curve.Params().Name
+	default:
+		return cert.PublicKeyAlgorithm.String()
+	}
+}
+
+// describeCert names a certificate for messages by its common name, or
+// its whole subject without one
+func describeCert(cert *x509.Certificate) string {
+	if cert.Subject.CommonName != "" {
+		return fmt.Sprintf("%q", cert.Subject.CommonName)
+	}
+	return fmt.Sprintf("%q", cert.Subject.String())
+}
+
+// formatAge formats a duration coarsely for messages
+func formatAge(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%d days", int(d/(24*time.Hour)))
+	case d >= 2*time.Hour:
+		return fmt.Sprintf("%d hours", int(d/time.Hour))
+	default:
+		return d.Round(time.Minute).String()
+	}
+}
+
+// issuedDER returns the DER of an issued certificate's PEM, or nil
+func issuedDER(issued *IssuedCert) []byte {
+	block, _ := pem.Decode([]byte(issued.Certificate))
+	if block == nil {
+		return nil
+	}
+	return block.Bytes
+}
+
+// keyUsageNames lists the key usages set in usage
+func keyUsageNames(usage x509.KeyUsage) []string {
+	names := []string{
+		"digital signature", "content commitment", "key encipherment", "data encipherment",
+		"key agreement", "certificate signing", "CRL signing", "encipher only", "decipher only",
+	}
+	var set []string
+	for i, name := range names {
+		if usage&(1<<i) != 0 {
+			set = append(set, name)
+		}
+	}
+	return set
+}
+
+// extKeyUsageNames lists extended key usages by name
+func extKeyUsageNames(usages []x509.ExtKeyUsage) []string {
+	names := map[x509.ExtKeyUsage]string{
+		x509.ExtKeyUsageAny:             "any",
+		x509.ExtKeyUsageServerAuth:      "server auth",
+		x509.ExtKeyUsageClientAuth:      "client auth",
+		x509.ExtKeyUsageCodeSigning:     "code signing",
+		x509.ExtKeyUsageEmailProtection: "email protection",
+		x509.ExtKeyUsageTimeStamping:    "time stamping",
+		x509.ExtKeyUsageOCSPSigning:     "OCSP signing",
+	}
+	var list []string
+	for _, usage := range usages {
+		name, ok := names[usage]
+		if !ok {
+			name = fmt.Sprintf("usage %d", usage)
+		}
+		list = append(list, name)
+	}
+	return list
+}
@@ -0,0 +1,210 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package ca
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyChain(t *testing.T) {
+	previous, err := NewCA(nil)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	current, err := NewCA(nil)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+
+	oldPEM, _, err := previous.GenerateCertificateV2("legacy", []string{"legacy.local", "10.0.0.7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newPEM, _, err := current.GenerateCertificateV2("api", []string{"api.local"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Issued by the current root
+	v, err := current.VerifyChain([]byte(newPEM))
+	if err != nil {
+		t.Fatalf("VerifyChain failed: %v", err)
+	}
+	if !v.Valid || len(v.Problems) != 0 || len(v.Warnings) != 0 {
+		t.Errorf("Expected a valid certificate, got %+v", v)
+	}
+	if len(v.Chain) != 2 || v.Leaf().Role != ChainRoleLeaf || v.Chain[1].Role != ChainRoleRoot {
+		t.Fatalf("Expected leaf and root, got %+v", v.Chain)
+	}
+	if v.Issued == nil || v.Issued.ServiceName != "api" || v.Leaf().SANs[0].Value != "api.local" {
+		t.Errorf("Expected the issued record and SANs, got %+v, %+v", v.Issued, v.Leaf())
+	}
+
+	// Signed by a root that has since been replaced
+	v, _ = current.VerifyChain([]byte(oldPEM))
+	if v.Valid || len(v.Problems) != 1 || !strings.Contains(v.Problems[0], "previous root") || !strings.Contains(v.Problems[0], "re-issue") {
+		t.Errorf("Expected an unpublished previous root, got %+v", v.Problems)
+	}
+
+	// Still trusted while the previous root is in the bundle
+	current.AddBundleCertificate(previous.Certificate())
+	v, _ = current.VerifyChain([]byte(oldPEM))
+	if !v.Valid || len(v.Warnings) != 1 || !strings.Contains(v.Warnings[0], "before rotation") {
+		t.Errorf("Expected a previous-root warning, got %+v", v)
+	}
+	if v.Chain[1].Role != ChainRolePreviousRoot || v.Issued != nil {
+		t.Errorf("Expected the previous root without an issued record, got %+v", v.Chain[1])
+	}
+
+	// Expired, along with the root here
+	leaf, _ := parseCertificatePEM(newPEM)
+	v = current.verifyChain(leaf, nil, leaf.NotAfter.Add(72*time.Hour))
+	if v.Valid || !strings.HasPrefix(v.Problems[0], `leaf "api.local" expired on`) || !strings.Contains(v.Problems[0], "(3 days ago)") {
+		t.Errorf("Expected an expiry problem, got %+v", v.Problems)
+	}
+
+	// A root this CA doesn't know
+	rootPEM, _, _ := externalRoot(t, nil)
+	v, _ = current.VerifyChain(rootPEM)
+	if v.Valid || v.Leaf().Role != ChainRoleUnknownRoot || !strings.Contains(v.Problems[0], "not a root of this CA") {
+		t.Errorf("Expected an unknown root, got %+v", v)
+	}
+
+	if _, err := current.VerifyChain([]byte("not a certificate")); err == nil {
+		t.Error("Expected an error for invalid PEM")
+	}
+}
+
+func TestKeyUsageNames(t *testing.T) {
+	got := keyUsageNames(x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment)
+	if strings.Join(got, ",") != "digital signature,key encipherment" {
+		t.Errorf("Unexpected key usages %v", got)
+	}
+	got = extKeyUsageNames([]x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsage(99)})
+	if strings.Join(got, ",") != "server auth,usage 99" {
+		t.Errorf("Unexpected extended key usages %v", got)
+	}
+}
+
+func TestHandleVerify(t *testing.T) {
+	ca, err := NewCA(nil)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	gui, err := NewGUIHandler(ca, "")
+	if err != nil {
+		t.Fatalf("Failed to create GUI handler: %v", err)
+	}
+	certPEM, _, err := ca.GenerateCertificateV2("<api>", []string{"api.local"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := parseCertificatePEM(certPEM)
+	serial := newChainCertificate(cert, ChainRoleLeaf, time.Now()).SerialNumber
+
+	post := func(form url.Values) string {
+		req := httptest.NewRequest(http.MethodPost, "/ui/verify", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		gui.HandleVerify(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	for name, form := range map[string]url.Values{
+		"pasted": {"certificate": {certPEM}},
+		"picked": {"serial": {serial}},
+	} {
+		body := post(form)
+		if !strings.Contains(body, "VALID:") || !strings.Contains(body, "&lt;api&gt;") || !strings.Contains(body, "api.local") {
+			t.Errorf("%s: expected a valid chain, got %s", name, body)
+		}
+	}
+	if body := post(url.Values{"serial": {"ffff"}}); !strings.Contains(body, "not found") {
+		t.Errorf("Expected an unknown serial error, got %s", body)
+	}
+	if body := post(url.Values{}); !strings.Contains(body, "paste a PEM certificate") {
+		t.Errorf("Expected a missing certificate error, got %s", body)
+	}
+
+	rec := httptest.NewRecorder()
+	gui.HandleVerify(rec, httptest.NewRequest(http.MethodGet, "/ui/verify?serial="+serial, nil))
+	if body := rec.Body.String(); !strings.Contains(body, "CHAIN VERIFICATION") || !strings.Contains(body, `selected`) || !strings.Contains(body, "VALID:") {
+		t.Errorf("Expected the page with the preselected certificate verified, got %d", rec.Code)
+	}
+}
+
+func TestValidateAt(t *testing.T) {
+	config := DefaultCAConfig()
+	config.KeySize = 2048
+	server, err := NewServer(&ServerConfig{CAConfig: config})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	certPEM, _, err := server.ca.GenerateCertificateV2("api", []string{"api.local"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := parseCertificatePEM(certPEM)
+
+	// Either side of the expiry boundary
+	v, err := server.ca.ValidateAt([]byte(certPEM), leaf.NotAfter.Add(-time.Second))
+	if err != nil || !v.Valid || !v.At.Equal(leaf.NotAfter.Add(-time.Second)) {
+		t.Errorf("Expected valid a second before expiry, got %+v, %v", v, err)
+	}
+	v, _ = server.ca.ValidateAt([]byte(certPEM), leaf.NotAfter.Add(time.Second))
+	if v.Valid || v.Leaf().Status != "expired" {
+		t.Errorf("Expected expired a second after expiry, got %+v", v)
+	}
+	v, _ = server.ca.ValidateAt([]byte(certPEM), leaf.NotBefore.Add(-time.Hour))
+	if v.Valid || v.Leaf().Status != "not yet valid" {
+		t.Errorf("Expected not yet valid before issuance, got %+v", v)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/validate", server.handleValidate)
+	caServer := httptest.NewServer(mux)
+	defer caServer.Close()
+	t.Setenv("SGL_CA", caServer.URL)
+	t.Setenv("SGL_CA_API_KEY", "")
+
+	v, err = ValidateCertificateAt([]byte(certPEM), leaf.NotAfter.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("ValidateCertificateAt failed: %v", err)
+	}
+	if v.Valid || !strings.Contains(v.Problems[0], "expired on") || v.Issued == nil {
+		t.Errorf("Expected an expired issued certificate, got %+v", v)
+	}
+	if _, err := ValidateCertificateAt([]byte("not a certificate"), time.Now()); !errors.Is(err, ErrCertParse) {
+		t.Errorf("Expected ErrCertParse for invalid PEM, got %v", err)
+	}
+
+	post := func(query string) (int, *ChainVerification) {
+		rec := httptest.NewRecorder()
+		server.handleValidate(rec, httptest.NewRequest(http.MethodPost, "/validate"+query, strings.NewReader(certPEM)))
+		var v ChainVerification
+		json.NewDecoder(rec.Body).Decode(&v)
+		return rec.Code, &v
+	}
+	before := strconv.FormatInt(leaf.NotAfter.Add(-time.Minute).Unix(), 10)
+	if code, v := post("?at=" + before); code != http.StatusOK || !v.Valid {
+		t.Errorf("Expected valid at Unix time %s, got %d: %+v", before, code, v)
+	}
+	if code, v := post(""); code != http.StatusOK || !v.Valid || time.Since(v.At) > time.Minute {
+		t.Errorf("Expected valid now without ?at=, got %d: %+v", code, v)
+	}
+	if code, _ := post("?at=tomorrow"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid time, got %d", code)
+	}
+}
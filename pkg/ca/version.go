@@ -18,6 +18,43 @@ package ca
 //   - v2.0.4: ENHANCEMENT: Added certificate details printing to CreateSecureDualProtocolServer for debugging
 //   - v2.0.5: RELEASE: Certificate details printing feature complete with comprehensive cert information
 //   - v2.1.0: FEATURE: Added transportv2.go with V2 transport functions, deprecated old methods
+//   - v2.2.0: FEATURE: Persisted index.json with certificate metadata, CA.ReloadFromDisk(), external entry pickup
+//   - v2.3.0: FEATURE: Optional AES-256-GCM encryption at rest for persisted CA and leaf private keys
+//   - v2.4.0: FEATURE: URI (SPIFFE ID) and email SANs in CertRequestV2 with validation and GUI display
+//   - v2.5.0: FEATURE: certinfo subpackage for certificate inspection, `ca inspect` CLI
+//   - v2.6.0: FEATURE: Background leaf key pool with configurable size/algorithm, stats in /health and /metrics
+//   - v2.7.0: FEATURE: dualprotocol connection counters via GetStats() and optional /debug/dualprotocol handler
+//   - v2.8.0: FEATURE: dualprotocol.Options for sniff timeout, TCP keepalive, header limits, idle timeout; FIX: http.Server read deadlines preserved after sniffing
+//   - v2.9.0: FEATURE: dualprotocol WebSocket upgrades over ws:// and wss://, ConnectionInfoFromConn(); ConnectionInfo set on every request
+//   - v2.10.0: FEATURE: Configurable CORS for the CA server, X-Forwarded-Proto/Host support for GUI base URLs
+//   - v2.11.0: FEATURE: GET /ca/bundle (pem, der, jks), CA.Bundle(), CAConfig.BundleCertsPEM, FetchCABundle()
+//   - v2.12.0: FEATURE: NewReloadingTLSConfig()/ReloadingCertificate with renewal before expiry and SIGHUP re-issue
+//   - v2.13.0: FEATURE: GUI light/dark theme toggle, "/" certificate search backed by GET /ui/certs/search
+//   - v2.14.0: FEATURE: CertRequestV2 validity_days and key_algorithm, GUI generate form on the V2 API with CN preview
+//   - v2.15.0: FEATURE: CAConfig.PermittedDNSDomains/ExcludedDNSDomains X.509 name constraints on the root
+//   - v2.16.0: FEATURE: ServerConfig.TokenAuth bearer token (JWT) auth against a JWKS URL, SGL_CA_TOKEN for clients
+//   - v2.17.0: FEATURE: WriteCertFiles() atomic cert/key/chain writes with modes, owner, and SIGHUP/command hooks
+//   - v2.18.0: FEATURE: ServerConfig.NamespaceAPIKeys per-team namespaces scoping issuance, listings, and the GUI
+//   - v2.19.0: FEATURE: CAConfig.EventPublisher issuance/revocation/rotation events with NATS and Redis publishers
+//   - v2.20.0: FEATURE: TLSConfigForClient(), RedisTLSConfig(), and PGSSLFiles() for NATS, Redis, and Postgres clients
+//   - v2.21.0: FEATURE: PinnedTransport() and PinnedTransportWithCA() SPKI pin validation, SPKIPin()
+//   - v2.22.0: FEATURE: /healthz liveness and /readyz readiness (key, clock, persistence) probes, CA.Readiness()
+//   - v2.23.0: FEATURE: NewCAFromPEM(), CAConfig.RootCertPEM/RootKeyPEM, ServerConfig.RootCertFile/RootKeyFile root import
+//   - v2.24.0: FEATURE: CA.Backup()/Restore() tar.gz archives, scheduled snapshots, admin-only /admin/backup
+//   - v2.25.0: FEATURE: GET /sds certificate stream (SDS-style), WatchCertificate(), SecretUpdate, cmd/certsidecar
+//   - v2.26.0: FEATURE: Multi-instance safe PersistDir: flock'd .lock, store merges other instances' certificates, unique serials
+//   - v2.27.0: FEATURE: Diagnose() and `ca doctor` check SGL_CA URL, connectivity, credentials, clock skew, and trust store
+//   - v2.28.0: FEATURE: VerifyChain() and the GUI VERIFY page show a certificate's chain and why it is or isn't trusted
+//   - v2.29.0: FEATURE: RequestCertificateForContainer() infers SANs from Docker container names, aliases, and IPs
+//   - v2.30.0: FEATURE: Named leaf certificate templates (web, grpc-service, client-auth) selectable with CertRequestV2.Template and /cert
+//   - v2.31.0: FEATURE: Certificate usage tracking (last fetched/used), stale and never-fetched GUI badges, and /admin/purge-unused
+//   - v2.32.0: FEATURE: Asynchronous issuance with POST /cert?async=true tickets, a bounded priority queue, and client helpers to poll or wait
+//   - v2.33.0: FEATURE: IsCATrustedBySystem() checks the OS trust store for the root, and the dashboard shows whether the viewing browser trusts it
+//   - v2.34.0: FEATURE: gRPC CertificateAuthority service (IssueCertificate, GetCABundle, WatchCertificate) on the server port over h2c, NewGRPCClient()
+//   - v2.35.0: FEATURE: Short-lived certificates (validity_minutes, NewShortLivedCertificate, SGL_CA_CERT_LIFETIME) renewed in the background, with renewal events and metrics
+//   - v2.36.0: FEATURE: Issuance approval for ServerConfig.ApprovalDomains, decided in the GUI or through /admin/requests while the client helpers wait
+//   - v2.37.0: FEATURE: ValidateAt() and POST /validate?at= verify a certificate chain as of any time, for testing expiry boundaries
+//   - v2.38.0: FEATURE: OpenTelemetry spans of server requests and issuance (key generation, signing, persistence), with optional trace propagation from clients
 
 // Version of the CA package
-const Version = "v2.1.0"
+const Version = "v2.38.0"
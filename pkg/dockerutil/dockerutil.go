@@ -0,0 +1,259 @@
+// SPDX-License-Identifier: CC0-1.0
+
+// Package dockerutil locates a working Docker-compatible engine for local
+// development tooling. It probes DOCKER_HOST, the platform default socket,
+// Docker Desktop, Colima, Rancher Desktop, and Podman in order, caches the
+// first host that answers a ping, and can explain why detection failed.
+//
+// Example:
+//
+//	det, err := dockerutil.DetectClient(ctx)
+//	if err != nil {
+//	    fmt.Println(dockerutil.Diagnose(ctx))
+//	    return err
+//	}
+//	defer det.Client.Close()
+//	fmt.Printf("using %s (%s)\n", det.Runtime, det.Host)
+package dockerutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// Version is the current version of the dockerutil package
+const Version = "0.1.0"
+
+// Runtime identifies the engine distribution behind a Docker host.
+type Runtime string
+
+const (
+	RuntimeEnv            Runtime = "docker-host-env"
+	RuntimeDefault        Runtime = "docker"
+	RuntimeDockerDesktop  Runtime = "docker-desktop"
+	RuntimeColima         Runtime = "colima"
+	RuntimeRancherDesktop Runtime = "rancher-desktop"
+	RuntimePodman         Runtime = "podman"
+)
+
+// ProbeTimeout bounds each individual ping when the caller's context has a
+// later (or no) deadline.
+var ProbeTimeout = 5 * time.Second
+
+// ErrNotAvailable is returned (wrapped in a *DetectError) when no candidate
+// host answers.
+var ErrNotAvailable = errors.New("docker not available")
+
+// Candidate is a Docker host that detection will try.
+type Candidate struct {
+	Runtime Runtime `json:"runtime"`
+	Host    string  `json:"host"`
+}
+
+// Probe is the outcome of trying a single candidate.
+type Probe struct {
+	Candidate
+	Err error `json:"-"`
+}
+
+// OK reports whether the candidate answered a ping.
+func (p Probe) OK() bool {
+	return p.Err == nil
+}
+
+// Detection is a successfully connected Docker client. The caller owns
+// Client and should Close it when done.
+type Detection struct {
+	Client  *client.Client
+	Host    string
+	Runtime Runtime
+}
+
+// DetectError lists every probe that failed during detection.
+type DetectError struct {
+	Probes []Probe
+}
+
+func (e *DetectError) Error() string {
+	if len(e.Probes) == 0 {
+		return ErrNotAvailable.Error() + ": no candidate hosts"
+	}
+	reasons := make([]string, 0, len(e.Probes))
+	for _, p := range e.Probes {
+		reasons = append(reasons, fmt.Sprintf("%s (%s): %v", p.Runtime, p.Host, p.Err))
+	}
+	return fmt.Sprintf("%s: tried %d host(s): %s", ErrNotAvailable, len(e.Probes), strings.Join(reasons, "; "))
+}
+
+func (e *DetectError) Unwrap() error {
+	return ErrNotAvailable
+}
+
+var (
+	cacheMu sync.Mutex
+	cached  *Candidate
+)
+
+// DetectClient returns a client for the first reachable Docker host. A
+// DOCKER_HOST override is used exclusively; otherwise well-known sockets are
+// probed in order. The winning candidate is cached for the life of the
+// process, so later calls skip straight to it (re-probing if it goes away).
+func DetectClient(ctx context.Context) (*Detection, error) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	if cached != nil {
+		if cli, err := connect(ctx, *cached); err == nil {
+			return &Detection{Client: cli, Host: cached.Host, Runtime: cached.Runtime}, nil
+		}
+		cached = nil
+	}
+
+	var failed []Probe
+	for _, c := range Candidates() {
+		cli, err := connect(ctx, c)
+		if err != nil {
+			failed = append(failed, Probe{Candidate: c, Err: err})
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		winner := c
+		cached = &winner
+		return &Detection{Client: cli, Host: c.Host, Runtime: c.Runtime}, nil
+	}
+
+	return nil, &DetectError{Probes: failed}
+}
+
+// ResetCache forgets the cached host so the next DetectClient re-probes.
+func ResetCache() {
+	cacheMu.Lock()
+	cached = nil
+	cacheMu.Unlock()
+}
+
+// Candidates returns the hosts DetectClient would try, in order.
+func Candidates() []Candidate {
+	if host := os.Getenv(client.EnvOverrideHost); host != "" {
+		return []Candidate{{Runtime: RuntimeEnv, Host: host}}
+	}
+
+	candidates := []Candidate{{Runtime: RuntimeDefault, Host: client.DefaultDockerHost}}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		colimaProfile := os.Getenv("COLIMA_PROFILE")
+		if colimaProfile == "" {
+			colimaProfile = "default"
+		}
+		candidates = append(candidates,
+			unixCandidate(RuntimeDockerDesktop, home, ".docker", "run", "docker.sock"),
+			unixCandidate(RuntimeDockerDesktop, home, ".docker", "desktop", "docker.sock"),
+			unixCandidate(RuntimeColima, home, ".colima", colimaProfile, "docker.sock"),
+			unixCandidate(RuntimeRancherDesktop, home, ".rd", "docker.sock"),
+			unixCandidate(RuntimePodman, home, ".local", "share", "containers", "podman", "machine", "podman.sock"),
+		)
+	}
+
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		candidates = append(candidates, unixCandidate(RuntimePodman, runtimeDir, "podman", "podman.sock"))
+	}
+	candidates = append(candidates, Candidate{Runtime: RuntimePodman, Host: "unix:///run/podman/podman.sock"})
+
+	return candidates
+}
+
+// Diagnose probes every candidate (without stopping at the first success)
+// and returns a report explaining what was found at each location.
+func Diagnose(ctx context.Context) *Diagnostics {
+	d := &Diagnostics{DockerHost: os.Getenv(client.EnvOverrideHost)}
+	for _, c := range Candidates() {
+		cli, err := connect(ctx, c)
+		if cli != nil {
+			cli.Close()
+		}
+		d.Probes = append(d.Probes, Probe{Candidate: c, Err: err})
+	}
+	return d
+}
+
+// Diagnostics is the result of Diagnose.
+type Diagnostics struct {
+	DockerHost string
+	Probes     []Probe
+}
+
+// Available reports whether any probed host answered.
+func (d *Diagnostics) Available() bool {
+	for _, p := range d.Probes {
+		if p.OK() {
+			return true
+		}
+	}
+	return false
+}
+
+// String renders the report as human-readable lines.
+func (d *Diagnostics) String() string {
+	var b strings.Builder
+	if d.DockerHost != "" {
+		fmt.Fprintf(&b, "DOCKER_HOST is set to %s; other locations are not probed\n", d.DockerHost)
+	}
+	for _, p := range d.Probes {
+		if p.OK() {
+			fmt.Fprintf(&b, "  ✓ %-16s %s\n", p.Runtime, p.Host)
+		} else {
+			fmt.Fprintf(&b, "  ✗ %-16s %s: %v\n", p.Runtime, p.Host, p.Err)
+		}
+	}
+	if !d.Available() {
+		b.WriteString("No Docker engine reachable. Start Docker Desktop, Colima, Rancher Desktop, or Podman, or set DOCKER_HOST.\n")
+	}
+	return b.String()
+}
+
+// unixCandidate builds a unix:// candidate from path elements.
+func unixCandidate(runtime Runtime, elem ...string) Candidate {
+	return Candidate{Runtime: runtime, Host: "unix://" + filepath.Join(elem...)}
+}
+
+// connect creates a client for c and verifies it with a ping.
+func connect(ctx context.Context, c Candidate) (*client.Client, error) {
+	if path, ok := strings.CutPrefix(c.Host, "unix://"); ok {
+		if _, err := os.Stat(path); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("socket not found")
+			}
+			return nil, fmt.Errorf("socket not accessible: %w", err)
+		}
+	}
+
+	// DOCKER_HOST may come with DOCKER_TLS_VERIFY/DOCKER_CERT_PATH, so honour the full environment
+	hostOpt := client.WithHost(c.Host)
+	if c.Runtime == RuntimeEnv {
+		hostOpt = client.FromEnv
+	}
+
+	cli, err := client.NewClientWithOpts(hostOpt, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("invalid host: %w", err)
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, ProbeTimeout)
+	defer cancel()
+
+	if _, err := cli.Ping(pingCtx); err != nil {
+		cli.Close()
+		return nil, fmt.Errorf("ping failed: %w", err)
+	}
+	return cli, nil
+}
@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package dockerutil
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	if Version == "" {
+		t.Error("Version should not be empty")
+	}
+
+	// Version should follow semantic versioning pattern (without 'v' prefix)
+	if len(Version) < 5 {
+		t.Errorf("Version %q should follow X.Y.Z format", Version)
+	}
+}
+
+func TestCandidates_DockerHostOverride(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "tcp://10.0.0.5:2375")
+
+	candidates := Candidates()
+	if len(candidates) != 1 {
+		t.Fatalf("Expected DOCKER_HOST to be used exclusively, got %d candidates", len(candidates))
+	}
+	if candidates[0].Runtime != RuntimeEnv || candidates[0].Host != "tcp://10.0.0.5:2375" {
+		t.Errorf("Unexpected candidate: %+v", candidates[0])
+	}
+}
+
+func TestCandidates_WellKnownSockets(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("DOCKER_HOST", "")
+	t.Setenv("HOME", home)
+	t.Setenv("COLIMA_PROFILE", "work")
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+
+	want := map[Runtime]string{
+		RuntimeColima:         "unix://" + filepath.Join(home, ".colima", "work", "docker.sock"),
+		RuntimeRancherDesktop: "unix://" + filepath.Join(home, ".rd", "docker.sock"),
+	}
+
+	seen := make(map[Runtime]bool)
+	podmanXDG := false
+	for _, c := range Candidates() {
+		seen[c.Runtime] = true
+		if host, ok := want[c.Runtime]; ok && c.Host != host {
+			t.Errorf("%s: expected host %s, got %s", c.Runtime, host, c.Host)
+		}
+		if c.Host == "unix:///run/user/1000/podman/podman.sock" {
+			podmanXDG = true
+		}
+	}
+
+	for _, r := range []Runtime{RuntimeDefault, RuntimeDockerDesktop, RuntimeColima, RuntimeRancherDesktop, RuntimePodman} {
+		if !seen[r] {
+			t.Errorf("Expected a %s candidate", r)
+		}
+	}
+	if !podmanXDG {
+		t.Error("Expected rootless Podman socket under XDG_RUNTIME_DIR")
+	}
+}
+
+func TestConnect_MissingSocket(t *testing.T) {
+	c := Candidate{Runtime: RuntimeColima, Host: "unix://" + filepath.Join(t.TempDir(), "docker.sock")}
+
+	cli, err := connect(context.Background(), c)
+	if err == nil {
+		cli.Close()
+		t.Fatal("Expected error for missing socket")
+	}
+	if !strings.Contains(err.Error(), "socket not found") {
+		t.Errorf("Expected 'socket not found', got %v", err)
+	}
+}
+
+func TestDetectError(t *testing.T) {
+	err := &DetectError{Probes: []Probe{
+		{Candidate: Candidate{Runtime: RuntimeColima, Host: "unix:///x.sock"}, Err: errors.New("socket not found")},
+	}}
+
+	if !errors.Is(err, ErrNotAvailable) {
+		t.Error("Expected DetectError to wrap ErrNotAvailable")
+	}
+	if !strings.Contains(err.Error(), "colima (unix:///x.sock): socket not found") {
+		t.Errorf("Error should list failed probes, got %q", err.Error())
+	}
+}
+
+func TestDiagnosticsString(t *testing.T) {
+	d := &Diagnostics{Probes: []Probe{
+		{Candidate: Candidate{Runtime: RuntimeDefault, Host: "unix:///var/run/docker.sock"}, Err: errors.New("socket not found")},
+		{Candidate: Candidate{Runtime: RuntimeColima, Host: "unix:///colima.sock"}},
+	}}
+
+	if !d.Available() {
+		t.Error("Expected Available to be true when one probe succeeded")
+	}
+
+	out := d.String()
+	if !strings.Contains(out, "✗ docker") || !strings.Contains(out, "✓ colima") {
+		t.Errorf("Unexpected diagnostics output:\n%s", out)
+	}
+	if strings.Contains(out, "No Docker engine reachable") {
+		t.Error("Should not print failure hint when an engine is reachable")
+	}
+}
+
+func TestDetectClient_NoEngine(t *testing.T) {
+	ResetCache()
+	t.Setenv("DOCKER_HOST", "unix://"+filepath.Join(t.TempDir(), "missing.sock"))
+
+	det, err := DetectClient(context.Background())
+	if err == nil {
+		det.Client.Close()
+		t.Fatal("Expected detection to fail")
+	}
+
+	var detectErr *DetectError
+	if !errors.As(err, &detectErr) || len(detectErr.Probes) != 1 {
+		t.Errorf("Expected DetectError with one probe, got %v", err)
+	}
+}
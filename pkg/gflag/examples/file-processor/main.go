@@ -4,6 +4,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -24,6 +25,21 @@ func main() {
 		help      = gflag.BoolP("help", "h", false, "show this help message")
 	)
 
+	// Validate flag values as part of parsing
+	gflag.Validate("format", func(v string) error {
+		switch v {
+		case "text", "json", "csv":
+			return nil
+		}
+		return errors.New("valid formats: text, json, csv")
+	})
+	gflag.Validate("max-count", func(v int) error {
+		if v < 0 {
+			return errors.New("must not be negative")
+		}
+		return nil
+	})
+
 	// Parse command line arguments
 	gflag.Parse()
 
@@ -43,13 +59,6 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Validate format
-	validFormats := map[string]bool{"text": true, "json": true, "csv": true}
-	if !validFormats[*format] {
-		fmt.Fprintf(os.Stderr, "Error: invalid format '%s'. Valid formats: text, json, csv\n", *format)
-		os.Exit(1)
-	}
-
 	// Show configuration if verbose
 	if *verbose && !*quiet {
 		fmt.Printf("Configuration:\n")
@@ -71,8 +71,8 @@
 // API Functions:
 //
 // The package provides both Type and TypeP variants for all flag functions:
-//   - Type functions: String, Bool, Int, StringVar, BoolVar, IntVar
-//   - TypeP functions: StringP, BoolP, IntP, StringVarP, BoolVarP, IntVarP
+//   - Type functions: String, Bool, Int, Count, StringVar, BoolVar, IntVar, CountVar
+//   - TypeP functions: StringP, BoolP, IntP, CountP, StringVarP, BoolVarP, IntVarP, CountVarP
 //
 // The P variants accept a short name parameter, while the non-P variants
 // only accept the long name.
@@ -81,18 +81,66 @@
 //   - Short flags: -v, -p 8080, -n name
 //   - Long flags: --verbose, --port=8080, --name=name
 //   - Combined short flags: -vp 8080 (equivalent to -v -p 8080)
+//   - Count flags: -vvv, -v -v -v, or --verbose=3 (see Count)
+//   - Negated booleans: --no-color sets --color to false (automatically added)
 //   - Help flags: --help, -h (automatically added)
+//
+// An undefined flag is reported with the closest defined flag, as in
+// "flag provided but not defined: -recursiv (did you mean --recursive?)".
+//
+// Validation:
+//
+// Validate attaches a validator to a flag, and AfterParse a hook checking
+// the flags together. Parse runs them all once the arguments are parsed and
+// reports every failure at once:
+//
+//	fs.Validate("port", func(v int) error {
+//	    if v < 1 || v > 65535 {
+//	        return errors.New("must be between 1 and 65535")
+//	    }
+//	    return nil
+//	})
+//	fs.AfterParse(func(fs *gflag.FlagSet) error {
+//	    if fs.IsSet("cert") != fs.IsSet("key") {
+//	        return errors.New("--cert and --key must be used together")
+//	    }
+//	    return nil
+//	})
+//
+// Help Output:
+//
+// Help lists the flags sorted by name with usage text wrapped to the
+// terminal's width, and pages it through $PAGER (default "less -FRX") when
+// it is taller than the terminal; PAGER=cat turns paging off. UsageMarkdown
+// renders the same flags as a Markdown table for a README:
+//
+//	os.WriteFile("FLAGS.md", []byte(gflag.UsageMarkdown()), 0644)
+//
+// Hidden and Experimental Flags:
+//
+// MarkHidden omits a flag from usage output while it keeps working.
+// Experiment registers flags only when the experiment is enabled through
+// GFLAG_EXPERIMENTS (comma-separated names, or "all") or the
+// gflag_experiments build tag, so tools can ship in-progress options
+// without advertising them:
+//
+//	flags.Experiment("parallel", func(fs *gflag.FlagSet) {
+//	    fs.IntVar(&jobs, "jobs", "j", 1, "parallel jobs")
+//	})
 package gflag
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+
+	"github.com/nzions/sharedgolibs/pkg/util"
 )
 
 // Version is the current version of the gflag package
-const Version = "1.3.0"
+const Version = "1.8.0"
 
 // Value represents the interface to the dynamic value stored in a flag.
 type Value interface {
@@ -107,6 +155,10 @@ type Flag struct {
 	Usage     string // help message
 	Value     Value  // value as set
 	DefValue  string // default value (as text); for usage message
+	Hidden    bool   // omitted from usage message (see MarkHidden)
+
+	// Experiment names the experiment that registered the flag, if any
+	Experiment string
 }
 
 // FlagSet represents a set of defined flags.
@@ -118,6 +170,15 @@ type FlagSet struct {
 	shortMap      map[string]*Flag // maps short names to flags
 	usage         func()
 	errorHandling ErrorHandling
+
+	// disabled holds the flags of experiments that aren't enabled, so
+	// using one explains how to enable it
+	disabled []*Flag
+
+	// validators and afterParse run once Parse has parsed every argument,
+	// in the order they were added
+	validators []flagValidator
+	afterParse []func(fs *FlagSet) error
 }
 
 // CommandLine is the default set of command-line flags, parsed from os.Args.
@@ -141,10 +202,7 @@ func NewFlagSet(name string, errorHandling ErrorHandling) *FlagSet {
 		shortMap:      make(map[string]*Flag),
 		errorHandling: errorHandling,
 	}
-	f.usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage of %s:\n", f.name)
-		f.PrintDefaults()
-	}
+	f.usage = f.defaultUsage
 
 	// Automatically add help flags if they don't already exist
 	f.addHelpFlagIfNotExists()
@@ -183,6 +241,50 @@ func (b *boolValue) String() string {
 	return strconv.FormatBool(bool(*b))
 }
 
+// countIncrement is the value the parser sets on a count flag for each
+// occurrence without an explicit value
+const countIncrement = "+1"
+
+// countValue implements Value for count flags.
+type countValue int
+
+func newCountValue(p *int) *countValue {
+	*p = 0
+	return (*countValue)(p)
+}
+
+func (c *countValue) Set(s string) error {
+	if s == countIncrement {
+		*c++
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 0, 64)
+	if err != nil {
+		return err
+	}
+	*c = countValue(v)
+	return nil
+}
+
+func (c *countValue) String() string {
+	return strconv.Itoa(int(*c))
+}
+
+// isNoArgFlag reports whether a flag is complete without a value: bool
+// flags are set to true and count flags incremented
+func isNoArgFlag(flag *Flag) (noArgValue string, ok bool) {
+	switch flag.Value.(type) {
+	case *boolValue:
+		return "true", true
+	case *countValue:
+		return countIncrement, true
+	}
+	return "", false
+}
+
+// negatedPrefix turns a bool flag --name into --no-name
+const negatedPrefix = "no-"
+
 // stringValue implements Value for string flags.
 type stringValue string
 
@@ -273,6 +375,19 @@ func (f *FlagSet) IntVar(p *int, name, shortName string, value int, usage string
 	f.Var(newIntValue(value, p), name, shortName, usage)
 }
 
+// Count defines a count flag with specified name, short name, and usage string.
+// Each occurrence increments it (-vvv or -v -v -v is 3), and --name=N sets it.
+func (f *FlagSet) Count(name, shortName, usage string) *int {
+	p := new(int)
+	f.CountVar(p, name, shortName, usage)
+	return p
+}
+
+// CountVar defines a count flag with specified name, short name, and usage string.
+func (f *FlagSet) CountVar(p *int, name, shortName, usage string) {
+	f.Var(newCountValue(p), name, shortName, usage)
+}
+
 // Parse parses flag definitions from the argument list, which should not
 // include the command name.
 func (f *FlagSet) Parse(arguments []string) error {
@@ -308,6 +423,9 @@ func (f *FlagSet) Parse(arguments []string) error {
 		}
 	}
 
+	if err := f.validate(); err != nil {
+		return f.handleError(err)
+	}
 	return nil
 }
 
@@ -342,7 +460,16 @@ func (f *FlagSet) parseLongFlag(flagStr string, arguments []string, i *int) erro
 
 	flag, exists := f.flags[name]
 	if !exists {
-		return fmt.Errorf("flag provided but not defined: -%s", name)
+		if negated := f.negatedBoolFlag(name); negated != nil {
+			if hasValue {
+				return fmt.Errorf("flag does not take a value: --%s", name)
+			}
+			return negated.Value.Set("false")
+		}
+		if flag := f.disabledFlag(name, ""); flag != nil {
+			return experimentError(flag)
+		}
+		return f.undefinedFlagError(name, f.suggestLong(name))
 	}
 
 	// Special handling for help flag
@@ -351,13 +478,12 @@ func (f *FlagSet) parseLongFlag(flagStr string, arguments []string, i *int) erro
 		return nil
 	}
 
-	// Special handling for bool flags
-	if _, isBool := flag.Value.(*boolValue); isBool {
+	// Bool and count flags take an optional value
+	if noArgValue, ok := isNoArgFlag(flag); ok {
 		if hasValue {
 			return flag.Value.Set(value)
-		} else {
-			return flag.Value.Set("true")
 		}
+		return flag.Value.Set(noArgValue)
 	}
 
 	// Non-bool flags need a value
@@ -380,7 +506,10 @@ func (f *FlagSet) parseShortFlag(flagStr string, arguments []string, i *int) err
 		shortName := string(char)
 		flag, exists := f.shortMap[shortName]
 		if !exists {
-			return fmt.Errorf("flag provided but not defined: -%s", shortName)
+			if flag := f.disabledFlag("", shortName); flag != nil {
+				return experimentError(flag)
+			}
+			return f.undefinedFlagError(shortName, f.suggestShort(flagStr, shortName))
 		}
 
 		// Special handling for help flag (short form)
@@ -391,9 +520,9 @@ func (f *FlagSet) parseShortFlag(flagStr string, arguments []string, i *int) err
 			}
 		}
 
-		// Special handling for bool flags
-		if _, isBool := flag.Value.(*boolValue); isBool {
-			err := flag.Value.Set("true")
+		// Bool and count flags combine with the following short flags
+		if noArgValue, ok := isNoArgFlag(flag); ok {
+			err := flag.Value.Set(noArgValue)
 			if err != nil {
 				return err
 			}
@@ -418,6 +547,24 @@ func (f *FlagSet) parseShortFlag(flagStr string, arguments []string, i *int) err
 	return nil
 }
 
+// negatedBoolFlag returns the bool flag that --no-<name> negates, or nil.
+// A flag explicitly defined as no-<name> takes precedence, since it is
+// looked up first.
+func (f *FlagSet) negatedBoolFlag(name string) *Flag {
+	target, ok := strings.CutPrefix(name, negatedPrefix)
+	if !ok || target == "help" {
+		return nil
+	}
+	flag, exists := f.flags[target]
+	if !exists {
+		return nil
+	}
+	if _, isBool := flag.Value.(*boolValue); !isBool {
+		return nil
+	}
+	return flag
+}
+
 // showHelpAndExit displays help message and exits based on error handling
 func (f *FlagSet) showHelpAndExit() {
 	f.usage()
@@ -450,22 +597,226 @@ func (f *FlagSet) Arg(i int) string {
 	return f.args[i]
 }
 
-// PrintDefaults prints to standard error the default values of all defined flags.
+// PrintDefaults prints to standard error the default values of all defined
+// flags, sorted by name, wrapping usage text to the terminal's width.
 func (f *FlagSet) PrintDefaults() {
-	for _, flag := range f.flags {
-		format := "  -%s"
-		if flag.ShortName != "" {
-			format = "  -%s, --%s"
-			fmt.Fprintf(os.Stderr, format, flag.ShortName, flag.Name)
-		} else {
-			fmt.Fprintf(os.Stderr, "      --%s", flag.Name)
+	width, _, _ := stderrSize()
+	f.writeDefaults(os.Stderr, width)
+}
+
+// MarkHidden omits the named flag from usage output. The flag still parses
+// normally.
+func (f *FlagSet) MarkHidden(name string) error {
+	flag, exists := f.flags[name]
+	if !exists {
+		return fmt.Errorf("flag provided but not defined: %s", name)
+	}
+	flag.Hidden = true
+	return nil
+}
+
+// flagValidator checks the value of one flag
+type flagValidator struct {
+	flag  *Flag
+	check func(value Value) error
+}
+
+// Validate adds a validator of the named flag, run after Parse has parsed
+// all arguments, including when the flag keeps its default. The validator
+// is one of:
+//   - func(string) error, for any flag, given its value as text
+//   - func(int) error, for int and count flags
+//   - func(bool) error, for bool flags
+//
+// An error is returned if the flag isn't defined or the validator doesn't
+// match its type.
+func (f *FlagSet) Validate(name string, validator any) error {
+	flag, exists := f.flags[name]
+	if !exists {
+		return fmt.Errorf("flag provided but not defined: %s", name)
+	}
+
+	var check func(value Value) error
+	switch validator := validator.(type) {
+	case func(string) error:
+		check = func(value Value) error { return validator(value.String()) }
+	case func(int) error:
+		switch flag.Value.(type) {
+		case *intValue, *countValue:
+			check = func(value Value) error {
+				v, _ := strconv.Atoi(value.String())
+				return validator(v)
+			}
 		}
+	case func(bool) error:
+		if _, isBool := flag.Value.(*boolValue); isBool {
+			check = func(value Value) error { return validator(value.String() == "true") }
+		}
+	default:
+		return fmt.Errorf("unsupported validator %T for flag --%s", validator, name)
+	}
+	if check == nil {
+		return fmt.Errorf("validator %T doesn't match the type of flag --%s", validator, name)
+	}
+
+	f.validators = append(f.validators, flagValidator{flag: flag, check: check})
+	return nil
+}
 
-		if flag.DefValue != "" && flag.DefValue != "false" {
-			fmt.Fprintf(os.Stderr, " (default %q)", flag.DefValue)
+// AfterParse adds a hook run after Parse has parsed all arguments and run
+// the flag validators, for checks involving several flags or the
+// remaining arguments. Its error is reported like a flag's.
+func (f *FlagSet) AfterParse(hook func(fs *FlagSet) error) {
+	f.afterParse = append(f.afterParse, hook)
+}
+
+// validate runs the validators and post-parse hooks, joining every failure
+// into one error with a line each
+func (f *FlagSet) validate() error {
+	var errs []error
+	for _, validator := range f.validators {
+		if err := validator.check(validator.flag.Value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid value %q for flag --%s: %w", validator.flag.Value.String(), validator.flag.Name, err))
+		}
+	}
+	for _, hook := range f.afterParse {
+		if err := hook(f); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ExperimentsEnv is the environment variable listing the enabled
+// experiments, comma-separated; "all" enables every experiment
+const ExperimentsEnv = "GFLAG_EXPERIMENTS"
+
+// ExperimentEnabled reports whether the named experiment is enabled by
+// ExperimentsEnv or by building with the gflag_experiments tag.
+func ExperimentEnabled(name string) bool {
+	if experimentsBuildTag {
+		return true
+	}
+	for _, experiment := range strings.Split(os.Getenv(ExperimentsEnv), ",") {
+		experiment = strings.TrimSpace(experiment)
+		if experiment == name || experiment == "all" {
+			return true
+		}
+	}
+	return false
+}
+
+// Experiment calls register to define the flags of the named experiment
+// and reports whether it is enabled. The flags are only defined when the
+// experiment is enabled (see ExperimentEnabled); otherwise using one fails
+// with an error that says how to enable it, and usage output doesn't list
+// them.
+func (f *FlagSet) Experiment(name string, register func(fs *FlagSet)) bool {
+	if !ExperimentEnabled(name) {
+		// Register into a scratch set to learn the flag names
+		scratch := NewFlagSet(f.name, ContinueOnError)
+		register(scratch)
+		for flagName, flag := range scratch.flags {
+			if flagName != "help" {
+				flag.Experiment = name
+				f.disabled = append(f.disabled, flag)
+			}
 		}
-		fmt.Fprintf(os.Stderr, "\n        %s\n", flag.Usage)
+		return false
 	}
+
+	existing := make(map[string]bool, len(f.flags))
+	for flagName := range f.flags {
+		existing[flagName] = true
+	}
+	register(f)
+	for flagName, flag := range f.flags {
+		if !existing[flagName] {
+			flag.Experiment = name
+		}
+	}
+	return true
+}
+
+// disabledFlag returns the flag of a disabled experiment with the given
+// long or short name, or nil
+func (f *FlagSet) disabledFlag(name, shortName string) *Flag {
+	for _, flag := range f.disabled {
+		if (name != "" && flag.Name == name) || (shortName != "" && flag.ShortName == shortName) {
+			return flag
+		}
+	}
+	return nil
+}
+
+// undefinedFlagError reports an undefined flag, with the suggested flag if
+// there is one
+func (f *FlagSet) undefinedFlagError(name, suggestion string) error {
+	if suggestion != "" {
+		return fmt.Errorf("flag provided but not defined: -%s (did you mean %s?)", name, suggestion)
+	}
+	return fmt.Errorf("flag provided but not defined: -%s", name)
+}
+
+// suggestLong returns the flag closest to the undefined --name: a long name
+// or --no- negation within two edits, or the short flag it names, such as -v
+// for --v. It returns "" when nothing is close.
+func (f *FlagSet) suggestLong(name string) string {
+	if flag, exists := f.shortMap[name]; exists && !flag.Hidden {
+		return "-" + name
+	}
+	if name, ok := f.closestName(name); ok {
+		return "--" + name
+	}
+	return ""
+}
+
+// suggestShort returns the flag closest to the undefined short flag
+// shortName of cluster: the long flag close to the whole cluster, as for
+// -verbose, or the short flag differing only in case.
+func (f *FlagSet) suggestShort(cluster, shortName string) string {
+	if len(cluster) > 1 {
+		if name, ok := f.closestName(cluster); ok {
+			return "--" + name
+		}
+	}
+	for _, other := range []string{strings.ToLower(shortName), strings.ToUpper(shortName)} {
+		if flag, exists := f.shortMap[other]; exists && other != shortName && !flag.Hidden {
+			return "-" + other
+		}
+	}
+	return ""
+}
+
+// closestName returns the visible long name, or --no- negation, with the
+// smallest Levenshtein distance to name: at most two edits, and fewer edits
+// than name has characters. Ties go to the alphabetically first name.
+func (f *FlagSet) closestName(name string) (string, bool) {
+	best, bestDistance := "", 3
+	consider := func(candidate string) {
+		d := util.EditDistance(name, candidate)
+		if d < bestDistance || (d == bestDistance && candidate < best) {
+			best, bestDistance = candidate, d
+		}
+	}
+	for _, flag := range f.flags {
+		if flag.Hidden {
+			continue
+		}
+		consider(flag.Name)
+		if _, isBool := flag.Value.(*boolValue); isBool && flag.Name != "help" {
+			consider(negatedPrefix + flag.Name)
+		}
+	}
+	if best == "" || bestDistance >= len(name) {
+		return "", false
+	}
+	return best, true
+}
+
+// experimentError explains that flag belongs to a disabled experiment
+func experimentError(flag *Flag) error {
+	return fmt.Errorf("flag --%s is experimental: enable it with %s=%s", flag.Name, ExperimentsEnv, flag.Experiment)
 }
 
 // Modern API methods for cleaner flag handling
@@ -485,6 +836,11 @@ func (f *FlagSet) AddInt(name, shortName string, value int, usage string) {
 	f.IntVar(new(int), name, shortName, value, usage)
 }
 
+// AddCount adds a count flag with specified name, short name, and usage string.
+func (f *FlagSet) AddCount(name, shortName, usage string) {
+	f.CountVar(new(int), name, shortName, usage)
+}
+
 // GetBool returns the value of the named bool flag.
 func (f *FlagSet) GetBool(name string) bool {
 	flag, exists := f.flags[name]
@@ -521,6 +877,18 @@ func (f *FlagSet) GetInt(name string) int {
 	return 0
 }
 
+// GetCount returns the value of the named count flag.
+func (f *FlagSet) GetCount(name string) int {
+	flag, exists := f.flags[name]
+	if !exists {
+		return 0
+	}
+	if countVal, ok := flag.Value.(*countValue); ok {
+		return int(*countVal)
+	}
+	return 0
+}
+
 // Set sets the value of the named flag.
 func (f *FlagSet) Set(name, value string) error {
 	flag, exists := f.flags[name]
@@ -600,6 +968,16 @@ func Int(name string, value int, usage string) *int {
 	return CommandLine.Int(name, "", value, usage)
 }
 
+// CountP defines a count flag with specified name, short name, and usage string.
+func CountP(name, shortName, usage string) *int {
+	return CommandLine.Count(name, shortName, usage)
+}
+
+// Count defines a count flag with specified name and usage string.
+func Count(name, usage string) *int {
+	return CommandLine.Count(name, "", usage)
+}
+
 // Var defines a flag with the specified name and usage string.
 // The type and value of the flag are represented by the first argument, of type Value.
 func Var(value Value, name, usage string) {
@@ -642,6 +1020,39 @@ func IntVarP(p *int, name, shortName string, value int, usage string) {
 	CommandLine.IntVar(p, name, shortName, value, usage)
 }
 
+// CountVar defines a count flag with specified name and usage string.
+func CountVar(p *int, name, usage string) {
+	CommandLine.CountVar(p, name, "", usage)
+}
+
+// CountVarP defines a count flag with specified name, short name, and usage string.
+func CountVarP(p *int, name, shortName, usage string) {
+	CommandLine.CountVar(p, name, shortName, usage)
+}
+
+// MarkHidden omits the named flag from CommandLine's usage output.
+func MarkHidden(name string) error {
+	return CommandLine.MarkHidden(name)
+}
+
+// Validate adds a validator of the named CommandLine flag (see
+// FlagSet.Validate).
+func Validate(name string, validator any) error {
+	return CommandLine.Validate(name, validator)
+}
+
+// AfterParse adds a hook run after CommandLine is parsed (see
+// FlagSet.AfterParse).
+func AfterParse(hook func(fs *FlagSet) error) {
+	CommandLine.AfterParse(hook)
+}
+
+// Experiment defines the flags of the named experiment on CommandLine when
+// it is enabled, and reports whether it is.
+func Experiment(name string, register func(fs *FlagSet)) bool {
+	return CommandLine.Experiment(name, register)
+}
+
 // Parse parses the command-line flags from os.Args[1:].
 func Parse() {
 	CommandLine.Parse(os.Args[1:])
@@ -662,6 +1073,11 @@ func Arg(i int) string {
 	return CommandLine.Arg(i)
 }
 
+// UsageMarkdown returns the command-line flags as a Markdown table.
+func UsageMarkdown() string {
+	return CommandLine.UsageMarkdown()
+}
+
 // Modern API package-level convenience functions
 
 // AddBool adds a bool flag to the default CommandLine flagset.
@@ -679,6 +1095,11 @@ func AddInt(name, shortName string, value int, usage string) {
 	CommandLine.AddInt(name, shortName, value, usage)
 }
 
+// AddCount adds a count flag to the default CommandLine flagset.
+func AddCount(name, shortName, usage string) {
+	CommandLine.AddCount(name, shortName, usage)
+}
+
 // GetBool returns the value of the named bool flag from CommandLine.
 func GetBool(name string) bool {
 	return CommandLine.GetBool(name)
@@ -694,6 +1115,11 @@ func GetInt(name string) int {
 	return CommandLine.GetInt(name)
 }
 
+// GetCount returns the value of the named count flag from CommandLine.
+func GetCount(name string) int {
+	return CommandLine.GetCount(name)
+}
+
 // Set sets the value of the named flag in CommandLine.
 func Set(name, value string) error {
 	return CommandLine.Set(name, value)
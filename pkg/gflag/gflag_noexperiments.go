@@ -0,0 +1,9 @@
+//go:build !gflag_experiments
+
+// SPDX-License-Identifier: CC0-1.0
+
+package gflag
+
+// experimentsBuildTag enables every experiment in builds with the
+// gflag_experiments tag
+const experimentsBuildTag = false
@@ -3,9 +3,12 @@
 package gflag
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -510,6 +513,73 @@ func TestErrorHandling_UndefinedFlagMessages(t *testing.T) {
 	}
 }
 
+func TestErrorHandling_UndefinedFlagSuggestions(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		expectedErr string
+	}{
+		{
+			name:        "misspelled long flag",
+			args:        []string{"--recursiv"},
+			expectedErr: "flag provided but not defined: -recursiv (did you mean --recursive?)",
+		},
+		{
+			name:        "misspelled long flag with value",
+			args:        []string{"--prot=80"},
+			expectedErr: "flag provided but not defined: -prot (did you mean --port?)",
+		},
+		{
+			name:        "misspelled negation",
+			args:        []string{"--no-recursve"},
+			expectedErr: "flag provided but not defined: -no-recursve (did you mean --no-recursive?)",
+		},
+		{
+			name:        "short name as long flag",
+			args:        []string{"--r"},
+			expectedErr: "flag provided but not defined: -r (did you mean -r?)",
+		},
+		{
+			name:        "long name with one dash",
+			args:        []string{"-recursive"},
+			expectedErr: "flag provided but not defined: -e (did you mean --recursive?)",
+		},
+		{
+			name:        "short flag in the wrong case",
+			args:        []string{"-R"},
+			expectedErr: "flag provided but not defined: -R (did you mean -r?)",
+		},
+		{
+			name:        "hidden flags are not suggested",
+			args:        []string{"--secrt"},
+			expectedErr: "flag provided but not defined: -secrt",
+		},
+		{
+			name:        "nothing close",
+			args:        []string{"--zz"},
+			expectedErr: "flag provided but not defined: -zz",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := NewFlagSet("test", ContinueOnError)
+			fs.Bool("recursive", "r", false, "recurse into directories")
+			fs.Int("port", "p", 8080, "server port")
+			fs.String("secret", "", "", "secret token")
+			fs.MarkHidden("secret")
+
+			err := fs.Parse(tt.args)
+			if err == nil {
+				t.Fatal("expected error but got none")
+			}
+			if err.Error() != tt.expectedErr {
+				t.Errorf("expected error %q, got %q", tt.expectedErr, err.Error())
+			}
+		})
+	}
+}
+
 func TestFlagSet_ErrorsLegacy(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -1142,3 +1212,385 @@ func TestPackageLevelVarFunctionsDefaults(t *testing.T) {
 		t.Errorf("expected int flag default to be 999, got %d", intFlag)
 	}
 }
+
+func TestFlagSet_Count(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected int
+	}{
+		{name: "not given", args: []string{}, expected: 0},
+		{name: "short once", args: []string{"-v"}, expected: 1},
+		{name: "short combined", args: []string{"-vvv"}, expected: 3},
+		{name: "short repeated", args: []string{"-v", "-v"}, expected: 2},
+		{name: "long repeated", args: []string{"--verbose", "-v", "--verbose"}, expected: 3},
+		{name: "long explicit value", args: []string{"--verbose=5"}, expected: 5},
+		{name: "combined with bool", args: []string{"-vqv"}, expected: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := NewFlagSet("test", ContinueOnError)
+			verbose := fs.Count("verbose", "v", "increase verbosity")
+			fs.Bool("quiet", "q", false, "quiet")
+
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if *verbose != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, *verbose)
+			}
+			if fs.GetCount("verbose") != tt.expected {
+				t.Errorf("GetCount: expected %d, got %d", tt.expected, fs.GetCount("verbose"))
+			}
+		})
+	}
+
+	// A count flag does not consume the next argument
+	fs := NewFlagSet("test", ContinueOnError)
+	verbose := fs.Count("verbose", "v", "increase verbosity")
+	if err := fs.Parse([]string{"-v", "file.txt"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if *verbose != 1 || fs.NArg() != 1 || fs.Arg(0) != "file.txt" {
+		t.Errorf("expected count 1 and one argument, got %d and %v", *verbose, fs.Args())
+	}
+
+	if err := fs.Parse([]string{"--verbose=lots"}); err == nil {
+		t.Error("expected an error for a non-numeric count")
+	}
+}
+
+func TestFlagSet_NegatedBool(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		defValue bool
+		expected bool
+	}{
+		{name: "negated default true", args: []string{"--no-color"}, defValue: true, expected: false},
+		{name: "negated default false", args: []string{"--no-color"}, defValue: false, expected: false},
+		{name: "last one wins", args: []string{"--no-color", "--color"}, defValue: true, expected: true},
+		{name: "plain flag still works", args: []string{"--color"}, defValue: false, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := NewFlagSet("test", ContinueOnError)
+			color := fs.Bool("color", "c", tt.defValue, "colorize output")
+
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if *color != tt.expected {
+				t.Errorf("expected %t, got %t", tt.expected, *color)
+			}
+		})
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		fs := NewFlagSet("test", ContinueOnError)
+		fs.Bool("color", "", true, "colorize output")
+		fs.String("name", "", "x", "a string flag")
+
+		for _, args := range [][]string{{"--no-color=true"}, {"--no-name"}, {"--no-help"}, {"--no-missing"}} {
+			if err := fs.Parse(args); err == nil {
+				t.Errorf("expected an error for %v", args)
+			}
+		}
+	})
+
+	t.Run("explicit no flag takes precedence", func(t *testing.T) {
+		fs := NewFlagSet("test", ContinueOnError)
+		cache := fs.Bool("cache", "", true, "use the cache")
+		noCache := fs.Bool("no-cache", "", false, "bypass the cache for this run")
+
+		if err := fs.Parse([]string{"--no-cache"}); err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		if !*cache || !*noCache {
+			t.Errorf("expected the explicit --no-cache flag to be set, got cache=%t no-cache=%t", *cache, *noCache)
+		}
+	})
+}
+
+func TestPackageLevelCountFunctions(t *testing.T) {
+	originalCommandLine := CommandLine
+	defer func() { CommandLine = originalCommandLine }()
+
+	CommandLine = NewFlagSet("test", ContinueOnError)
+	verbose := CountP("verbose", "v", "increase verbosity")
+	var debug int
+	CountVar(&debug, "debug", "debug level")
+	AddCount("trace", "t", "trace level")
+
+	if err := CommandLine.Parse([]string{"-vv", "--debug", "--debug", "-ttt"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if *verbose != 2 || debug != 2 || GetCount("trace") != 3 {
+		t.Errorf("expected 2, 2, 3, got %d, %d, %d", *verbose, debug, GetCount("trace"))
+	}
+}
+
+// captureDefaults returns what PrintDefaults writes to stderr
+func captureDefaults(t *testing.T, fs *FlagSet) string {
+	t.Helper()
+	return captureStderr(t, fs.PrintDefaults)
+}
+
+func TestFlagSet_MarkHidden(t *testing.T) {
+	fs := NewFlagSet("test", ContinueOnError)
+	fs.Bool("verbose", "v", false, "enable verbose output")
+	debugDump := fs.String("debug-dump", "", "", "write internal state to a file")
+
+	if err := fs.MarkHidden("debug-dump"); err != nil {
+		t.Fatalf("MarkHidden failed: %v", err)
+	}
+	if err := fs.MarkHidden("missing"); err == nil {
+		t.Error("expected an error hiding an undefined flag")
+	}
+
+	usage := captureDefaults(t, fs)
+	if strings.Contains(usage, "debug-dump") || !strings.Contains(usage, "verbose") {
+		t.Errorf("expected only the hidden flag omitted from usage, got:\n%s", usage)
+	}
+	if err := fs.Parse([]string{"--debug-dump=state.json"}); err != nil || *debugDump != "state.json" {
+		t.Errorf("expected a hidden flag to parse, got %q, %v", *debugDump, err)
+	}
+}
+
+func TestFlagSet_Experiment(t *testing.T) {
+	if experimentsBuildTag {
+		t.Skip("every experiment is enabled by the gflag_experiments build tag")
+	}
+
+	define := func() (*FlagSet, *int, bool) {
+		fs := NewFlagSet("test", ContinueOnError)
+		jobs := new(int)
+		enabled := fs.Experiment("parallel", func(fs *FlagSet) {
+			fs.IntVar(jobs, "jobs", "j", 1, "parallel jobs")
+		})
+		return fs, jobs, enabled
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Setenv(ExperimentsEnv, "")
+		fs, _, enabled := define()
+		if enabled || fs.GetFlag("jobs") != nil {
+			t.Fatal("expected the experiment's flags to be left undefined")
+		}
+		for _, args := range [][]string{{"--jobs=4"}, {"-j", "4"}} {
+			err := fs.Parse(args)
+			if err == nil || !strings.Contains(err.Error(), "GFLAG_EXPERIMENTS=parallel") {
+				t.Errorf("expected an error naming the experiment for %v, got %v", args, err)
+			}
+		}
+		if usage := captureDefaults(t, fs); strings.Contains(usage, "jobs") {
+			t.Errorf("expected usage without the experiment's flags, got:\n%s", usage)
+		}
+	})
+
+	for _, env := range []string{"parallel", "other, parallel", "all"} {
+		t.Run("enabled by "+env, func(t *testing.T) {
+			t.Setenv(ExperimentsEnv, env)
+			fs, jobs, enabled := define()
+			if !enabled {
+				t.Fatal("expected the experiment to be enabled")
+			}
+			if err := fs.Parse([]string{"-j", "4"}); err != nil || *jobs != 4 {
+				t.Errorf("expected --jobs to parse, got %d, %v", *jobs, err)
+			}
+			if flag := fs.GetFlag("jobs"); flag.Experiment != "parallel" || fs.GetFlag("help").Experiment != "" {
+				t.Error("expected only the experiment's flags to be marked")
+			}
+			if usage := captureDefaults(t, fs); !strings.Contains(usage, "parallel jobs (experimental: parallel)") {
+				t.Errorf("expected usage to mark the experimental flag, got:\n%s", usage)
+			}
+		})
+	}
+}
+
+func TestFlagSet_Validate(t *testing.T) {
+	define := func() *FlagSet {
+		fs := NewFlagSet("test", ContinueOnError)
+		fs.Int("port", "p", 8080, "server port")
+		fs.String("format", "f", "text", "output format")
+		fs.Bool("tls", "", false, "serve TLS")
+		fs.Count("verbose", "v", "verbosity")
+		fs.String("cert", "", "", "TLS certificate")
+
+		validators := map[string]any{
+			"port": func(v int) error {
+				if v < 1 || v > 65535 {
+					return errors.New("must be between 1 and 65535")
+				}
+				return nil
+			},
+			"format": func(v string) error {
+				if v != "text" && v != "json" {
+					return errors.New("must be text or json")
+				}
+				return nil
+			},
+			"verbose": func(v int) error {
+				if v > 2 {
+					return errors.New("at most -vv")
+				}
+				return nil
+			},
+		}
+		for _, name := range []string{"port", "format", "verbose"} {
+			if err := fs.Validate(name, validators[name]); err != nil {
+				t.Fatalf("Validate(%s) failed: %v", name, err)
+			}
+		}
+		fs.AfterParse(func(fs *FlagSet) error {
+			if fs.GetBool("tls") && fs.GetString("cert") == "" {
+				return errors.New("--tls requires --cert")
+			}
+			return nil
+		})
+		return fs
+	}
+
+	if err := define().Parse([]string{"-p", "443", "--format=json", "-vv"}); err != nil {
+		t.Errorf("expected valid flags to parse, got %v", err)
+	}
+
+	err := define().Parse([]string{"--port=0", "-f", "xml", "-vvv", "--tls"})
+	want := []string{
+		`invalid value "0" for flag --port: must be between 1 and 65535`,
+		`invalid value "xml" for flag --format: must be text or json`,
+		`invalid value "3" for flag --verbose: at most -vv`,
+		"--tls requires --cert",
+	}
+	if err == nil || err.Error() != strings.Join(want, "\n") {
+		t.Errorf("expected every failure reported in order, got:\n%v", err)
+	}
+
+	// Defaults are validated too
+	fs := define()
+	fs.Validate("cert", func(v string) error {
+		if v == "" {
+			return errors.New("required")
+		}
+		return nil
+	})
+	if err := fs.Parse(nil); err == nil || !strings.Contains(err.Error(), "--cert: required") {
+		t.Errorf("expected the unset flag validated, got %v", err)
+	}
+}
+
+func TestFlagSet_ValidateErrors(t *testing.T) {
+	fs := NewFlagSet("test", ContinueOnError)
+	fs.String("name", "n", "", "name")
+	fs.Bool("tls", "", false, "serve TLS")
+
+	if err := fs.Validate("missing", func(string) error { return nil }); err == nil {
+		t.Error("expected an error validating an undefined flag")
+	}
+	if err := fs.Validate("name", func(int) error { return nil }); err == nil {
+		t.Error("expected an error for an int validator of a string flag")
+	}
+	if err := fs.Validate("name", func(float64) error { return nil }); err == nil {
+		t.Error("expected an error for an unsupported validator")
+	}
+	if err := fs.Validate("tls", func(v bool) error {
+		if v {
+			return errors.New("not supported yet")
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if err := fs.Parse([]string{"--tls"}); err == nil || !strings.Contains(err.Error(), "not supported yet") {
+		t.Errorf("expected the bool validator to run, got %v", err)
+	}
+}
+
+func TestFlagSet_UsageMarkdown(t *testing.T) {
+	fs := NewFlagSet("test", ContinueOnError)
+	fs.Int("port", "p", 8080, "server port")
+	fs.Bool("color", "", true, "colorize output")
+	fs.Count("verbose", "v", "more output (-vv for debug)")
+	fs.String("sep", "", "|", "field separator, e.g. | or ,")
+	fs.String("secret", "", "", "not documented")
+	fs.MarkHidden("secret")
+
+	want := "| Flag | Default | Description |\n" +
+		"|------|---------|-------------|\n" +
+		"| `--[no-]color` | `true` | colorize output |\n" +
+		"| `-h`, `--help` |  | show help message |\n" +
+		"| `-p`, `--port` | `8080` | server port |\n" +
+		"| `--sep` | `\\|` | field separator, e.g. \\| or , |\n" +
+		"| `-v`, `--verbose` | repeatable | more output (-vv for debug) |\n"
+	if got := fs.UsageMarkdown(); got != want {
+		t.Errorf("UsageMarkdown() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	text := "write each run's report as one self-contained HTML file"
+	got := wrapText(text, 24)
+	want := []string{"write each run's report", "as one self-contained", "HTML file"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrapText = %q, want %q", got, want)
+	}
+	if got := wrapText(text, 10); len(got) != 1 {
+		t.Errorf("Expected narrow widths to leave text alone, got %q", got)
+	}
+}
+
+func TestHelpPager(t *testing.T) {
+	originalSize, originalPager := stderrSize, runPager
+	defer func() { stderrSize, runPager = originalSize, originalPager }()
+
+	var paged []string
+	runPager = func(command, help string) error {
+		paged = append(paged, command+"\n"+help)
+		return nil
+	}
+	height := 5
+	stderrSize = func() (int, int, bool) { return 40, height, true }
+
+	fs := NewFlagSet("tool", ContinueOnError)
+	fs.String("output", "o", "", "file to write the report to instead of standard output")
+	fs.Bool("verbose", "v", false, "verbose output")
+
+	t.Setenv(PagerEnv, "more")
+	fs.Parse([]string{"--help"})
+	if len(paged) != 1 || !strings.HasPrefix(paged[0], "more\nUsage of tool:\n") {
+		t.Fatalf("Expected the help paged with $PAGER, got %q", paged)
+	}
+	if !strings.Contains(paged[0], "        file to write the report to\n        instead of standard output\n") {
+		t.Errorf("Expected usage wrapped to the terminal, got:\n%s", paged[0])
+	}
+
+	// Help fitting the terminal, or PAGER=cat, goes straight to stderr
+	height = 50
+	if out := captureStderr(t, func() { fs.Parse([]string{"-h"}) }); len(paged) != 1 || !strings.HasPrefix(out, "Usage of tool:\n") {
+		t.Errorf("Expected unpaged help, got %q", out)
+	}
+	height = 5
+	t.Setenv(PagerEnv, "cat")
+	if out := captureStderr(t, func() { fs.Parse([]string{"-h"}) }); len(paged) != 1 || !strings.Contains(out, "--verbose") {
+		t.Errorf("Expected PAGER=cat to disable paging, got %q", out)
+	}
+}
+
+// captureStderr returns what fn writes to stderr
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	originalStderr := os.Stderr
+	os.Stderr = w
+	fn()
+	os.Stderr = originalStderr
+	w.Close()
+
+	out, _ := io.ReadAll(r)
+	return string(out)
+}
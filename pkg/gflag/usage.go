@@ -0,0 +1,181 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package gflag
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/term"
+)
+
+// PagerEnv names the environment variable holding the help pager command,
+// as for git and man. "cat" (or any command that isn't found) disables
+// paging.
+const PagerEnv = "PAGER"
+
+// defaultPager pages help when PAGER is unset: -F quits at once if the help
+// fits after all, -R keeps colors, -X leaves the help on screen
+const defaultPager = "less -FRX"
+
+// Terminal access, overridable for tests
+var (
+	stderrSize = func() (width, height int, ok bool) {
+		fd := int(os.Stderr.Fd())
+		if !term.IsTerminal(fd) {
+			return 0, 0, false
+		}
+		width, height, err := term.GetSize(fd)
+		return width, height, err == nil
+	}
+	runPager = func(command string, help string) error {
+		fields := strings.Fields(command)
+		cmd := exec.Command(fields[0], fields[1:]...)
+		cmd.Stdin = strings.NewReader(help)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		return cmd.Run()
+	}
+)
+
+// usageFlag is a flag as listed in usage output
+type usageFlag struct {
+	names   string // "-v, --verbose", or "    --[no-]color"
+	short   string // Short name, if any
+	long    string // Long name with the [no-] prefix of bools defaulting to true
+	def     string // Default to show, if any
+	usage   string
+	isCount bool
+}
+
+// usageFlags returns the flags shown in usage output, sorted by name
+func (f *FlagSet) usageFlags() []usageFlag {
+	var flags []usageFlag
+	for _, flag := range f.flags {
+		if flag.Hidden {
+			continue
+		}
+		u := usageFlag{short: flag.ShortName, long: flag.Name, usage: flag.Usage}
+		if _, isBool := flag.Value.(*boolValue); isBool && flag.DefValue == "true" {
+			u.long = "[" + negatedPrefix + "]" + flag.Name // Only useful negated
+		}
+		if _, isCount := flag.Value.(*countValue); isCount {
+			u.isCount = true
+		} else if flag.DefValue != "" && flag.DefValue != "false" {
+			u.def = flag.DefValue
+		}
+		if flag.Experiment != "" {
+			u.usage += " (experimental: " + flag.Experiment + ")"
+		}
+		flags = append(flags, u)
+	}
+	sort.Slice(flags, func(i, j int) bool {
+		return strings.TrimPrefix(flags[i].long, "["+negatedPrefix+"]") < strings.TrimPrefix(flags[j].long, "["+negatedPrefix+"]")
+	})
+	return flags
+}
+
+// writeDefaults writes the PrintDefaults listing to w, wrapping usage text
+// to width columns (0 = no wrapping)
+func (f *FlagSet) writeDefaults(w io.Writer, width int) {
+	const indent = "        "
+	for _, flag := range f.usageFlags() {
+		if flag.short != "" {
+			fmt.Fprintf(w, "  -%s, --%s", flag.short, flag.long)
+		} else {
+			fmt.Fprintf(w, "      --%s", flag.long)
+		}
+
+		if flag.isCount {
+			fmt.Fprint(w, " (repeatable)")
+		} else if flag.def != "" {
+			fmt.Fprintf(w, " (default %q)", flag.def)
+		}
+		fmt.Fprintln(w)
+		for _, line := range wrapText(flag.usage, width-len(indent)) {
+			fmt.Fprintf(w, "%s%s\n", indent, line)
+		}
+	}
+}
+
+// wrapText breaks text into lines of at most width columns at spaces; words
+// longer than a line get a line of their own. A width below 20 is too
+// narrow to help, and leaves text as it is.
+func wrapText(text string, width int) []string {
+	if width < 20 || runewidth.StringWidth(text) <= width {
+		return []string{text}
+	}
+	var lines []string
+	line, lineWidth := "", 0
+	for _, word := range strings.Fields(text) {
+		wordWidth := runewidth.StringWidth(word)
+		if line != "" && lineWidth+1+wordWidth > width {
+			lines = append(lines, line)
+			line, lineWidth = "", 0
+		}
+		if line != "" {
+			line += " "
+			lineWidth++
+		}
+		line += word
+		lineWidth += wordWidth
+	}
+	return append(lines, line)
+}
+
+// defaultUsage prints the usage message, wrapped to the width of the
+// terminal and paged when taller than it (see PagerEnv)
+func (f *FlagSet) defaultUsage() {
+	width, height, terminal := stderrSize()
+	var help bytes.Buffer
+	fmt.Fprintf(&help, "Usage of %s:\n", f.name)
+	f.writeDefaults(&help, width)
+
+	if terminal && strings.Count(help.String(), "\n") >= height {
+		pager, set := os.LookupEnv(PagerEnv)
+		if !set {
+			pager = defaultPager
+		}
+		if pager = strings.TrimSpace(pager); pager != "" && pager != "cat" {
+			if err := runPager(pager, help.String()); err == nil {
+				return
+			}
+		}
+	}
+	os.Stderr.Write(help.Bytes())
+}
+
+// UsageMarkdown returns the flags as a Markdown table, sorted by name, for
+// generating a README or man page: the names, default, and usage of every
+// flag not hidden.
+func (f *FlagSet) UsageMarkdown() string {
+	var b strings.Builder
+	b.WriteString("| Flag | Default | Description |\n")
+	b.WriteString("|------|---------|-------------|\n")
+	for _, flag := range f.usageFlags() {
+		names := "`--" + flag.long + "`"
+		if flag.short != "" {
+			names = "`-" + flag.short + "`, " + names
+		}
+		def := ""
+		if flag.isCount {
+			def = "repeatable"
+		} else if flag.def != "" {
+			def = "`" + flag.def + "`"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s |\n", names, escapeMarkdownCell(def), escapeMarkdownCell(flag.usage))
+	}
+	return b.String()
+}
+
+// escapeMarkdownCell keeps text on one line of a table cell
+func escapeMarkdownCell(text string) string {
+	text = strings.ReplaceAll(text, "|", `\|`)
+	return strings.ReplaceAll(text, "\n", "<br>")
+}
@@ -0,0 +1,62 @@
+package logi
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Config describes where a daemon's logs go. The zero value (plus a Name)
+// logs to stdout like NewDemonLogger.
+type Config struct {
+	Name      string       // Daemon name added to every record
+	Debug     bool         // Log debug messages; DEBUG=1 also enables them
+	NoConsole bool         // Don't log to stdout; SILENT_LOGS=1 has the same effect
+	File      string       // Log file path, empty for none
+	Rotate    RotateConfig // Rotation and retention for File
+	RingSize  int          // Recent lines kept in memory for Recent, 0 for none
+}
+
+// NewLogger creates a DaemonLogger writing to every destination in the
+// config. Call Close on shutdown to close the log file.
+func NewLogger(config Config) (*DaemonLogger, error) {
+	debugOn := config.Debug || strings.ToLower(os.Getenv("DEBUG")) == "1"
+	silent := config.NoConsole || strings.ToLower(os.Getenv("SILENT_LOGS")) == "1"
+
+	level := slog.LevelInfo
+	if debugOn {
+		level = slog.LevelDebug
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	l := &DaemonLogger{daemon: config.Name, debugOn: debugOn}
+	var handlers []slog.Handler
+	if !silent {
+		handlers = append(handlers, slog.NewTextHandler(os.Stdout, opts))
+	}
+	if config.File != "" {
+		file, err := OpenRotatingFile(config.File, config.Rotate)
+		if err != nil {
+			return nil, err
+		}
+		l.closers = append(l.closers, file)
+		handlers = append(handlers, slog.NewTextHandler(file, opts))
+	}
+	if config.RingSize > 0 {
+		l.ring = NewRingBuffer(config.RingSize)
+		handlers = append(handlers, slog.NewTextHandler(l.ring, opts))
+	}
+
+	var handler slog.Handler
+	switch len(handlers) {
+	case 0:
+		handler = slog.NewTextHandler(io.Discard, opts)
+	case 1:
+		handler = handlers[0]
+	default:
+		handler = NewTeeHandler(handlers...)
+	}
+	l.logger = slog.New(handler).With("daemon", "["+config.Name+"]")
+	return l, nil
+}
@@ -0,0 +1,47 @@
+package logi
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewLogger(t *testing.T) {
+	os.Unsetenv("DEBUG")
+	os.Unsetenv("SILENT_LOGS")
+
+	path := filepath.Join(t.TempDir(), "ca.log")
+	logger, err := NewLogger(Config{Name: "ca", NoConsole: true, File: path, RingSize: 2})
+	if err != nil {
+		t.Fatalf("NewLogger failed: %v", err)
+	}
+	logger.Info("started", "port", 8443)
+	logger.Debug("hidden")
+	logger.Warn("slow request")
+	logger.Error("failed", "err", "boom")
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "daemon=[ca] port=8443") || strings.Contains(string(data), "hidden") {
+		t.Errorf("Unexpected log file:\n%s", data)
+	}
+	recent := logger.Recent()
+	if len(recent) != 2 || !strings.Contains(recent[0], "slow request") || !strings.Contains(recent[1], "boom") {
+		t.Errorf("Unexpected recent lines: %v", recent)
+	}
+
+	logger, err = NewLogger(Config{Name: "quiet", NoConsole: true, Debug: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("discarded")
+	if logger.Recent() != nil || logger.Close() != nil {
+		t.Error("Expected a logger without destinations to have no recent lines")
+	}
+}
@@ -1,6 +1,7 @@
 package logi
 
 import (
+	"errors"
 	"io"
 	"log/slog"
 	"os"
@@ -12,6 +13,8 @@ type DaemonLogger struct {
 	logger  *slog.Logger
 	daemon  string
 	debugOn bool
+	closers []io.Closer // Log files opened by NewLogger
+	ring    *RingBuffer
 }
 
 // NewSilentLogger creates a logger that discards all logs
@@ -76,3 +79,22 @@ func (l *DaemonLogger) Warn(msg string, keysAndValues ...any) {
 func (l *DaemonLogger) SLogger() *slog.Logger {
 	return l.logger
 }
+
+// Recent returns the lines kept in memory when Config.RingSize is set,
+// oldest first
+func (l *DaemonLogger) Recent() []string {
+	if l.ring == nil {
+		return nil
+	}
+	return l.ring.Lines()
+}
+
+// Close closes any log files the logger writes to
+func (l *DaemonLogger) Close() error {
+	var errs []error
+	for _, closer := range l.closers {
+		errs = append(errs, closer.Close())
+	}
+	l.closers = nil
+	return errors.Join(errs...)
+}
@@ -1,7 +1,7 @@
 package logi
 
 // Version of the logi package
-const Version = "0.2.0"
+const Version = "0.3.0"
 
 // Logger defines the interface for logging in the Logi package.
 type Logger interface {
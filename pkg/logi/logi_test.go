@@ -14,7 +14,7 @@ func TestVersion(t *testing.T) {
 		t.Errorf("Version %q seems too short", Version)
 	}
 
-	expectedVersion := "0.2.0"
+	expectedVersion := "0.3.0"
 	if Version != expectedVersion {
 		t.Errorf("Expected version %q, got %q", expectedVersion, Version)
 	}
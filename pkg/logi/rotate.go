@@ -0,0 +1,184 @@
+package logi
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat names rotated files, e.g. ca.log.20240102-150405.000
+const backupTimeFormat = "20060102-150405.000"
+
+// RotateConfig controls when a RotatingFile rotates and which rotated files
+// it keeps. Zero values disable the corresponding limit.
+type RotateConfig struct {
+	MaxSize    int64         // Rotate before the file grows past this many bytes
+	Interval   time.Duration // Rotate once the file has been written for this long
+	MaxBackups int           // Number of rotated files to keep
+	MaxAge     time.Duration // Delete rotated files older than this
+}
+
+// RotatingFile is an io.WriteCloser that appends to a log file and rotates
+// it by size or age. Rotated files are renamed with a timestamp suffix next
+// to the original.
+type RotatingFile struct {
+	mu     sync.Mutex
+	path   string
+	config RotateConfig
+	file   *os.File
+	size   int64
+	opened time.Time
+	now    func() time.Time
+}
+
+// OpenRotatingFile opens or creates the log file at path, creating its
+// directory, and appends to it
+func OpenRotatingFile(path string, config RotateConfig) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, config: config, now: time.Now}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("creating log directory: %w", err)
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write appends p to the file, rotating first if p would exceed MaxSize or
+// the file is older than Interval
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.shouldRotate(len(p)) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Rotate rotates the file now, e.g. on SIGHUP
+func (r *RotatingFile) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return os.ErrClosed
+	}
+	return r.rotate()
+}
+
+// Close closes the current file
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// Backups lists the rotated files for this log, newest first
+func (r *RotatingFile) Backups() ([]string, error) {
+	matches, err := filepath.Glob(r.path + ".*")
+	if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, match := range matches {
+		if _, ok := r.backupTime(match); ok {
+			backups = append(backups, match)
+		}
+	}
+	// The timestamp suffix sorts chronologically
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return backups, nil
+}
+
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("opening log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("opening log file: %w", err)
+	}
+	r.file = file
+	r.size = info.Size()
+	r.opened = r.now()
+	return nil
+}
+
+func (r *RotatingFile) shouldRotate(n int) bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.config.MaxSize > 0 && r.size+int64(n) > r.config.MaxSize {
+		return true
+	}
+	return r.config.Interval > 0 && r.now().Sub(r.opened) >= r.config.Interval
+}
+
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("closing log file: %w", err)
+	}
+	r.file = nil
+
+	backup := r.path + "." + r.now().Format(backupTimeFormat)
+	if err := os.Rename(r.path, backup); err != nil {
+		// Keep logging to the current file rather than losing messages
+		if openErr := r.open(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("rotating log file: %w", err)
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.prune()
+	return nil
+}
+
+// prune deletes rotated files beyond MaxBackups or older than MaxAge.
+// Failures are ignored; they are retried on the next rotation.
+func (r *RotatingFile) prune() {
+	if r.config.MaxBackups <= 0 && r.config.MaxAge <= 0 {
+		return
+	}
+	backups, err := r.Backups()
+	if err != nil {
+		return
+	}
+	cutoff := r.now().Add(-r.config.MaxAge)
+	for i, backup := range backups {
+		rotated, _ := r.backupTime(backup)
+		if (r.config.MaxBackups > 0 && i >= r.config.MaxBackups) ||
+			(r.config.MaxAge > 0 && rotated.Before(cutoff)) {
+			os.Remove(backup)
+		}
+	}
+}
+
+// backupTime parses the rotation time from a rotated file's name
+func (r *RotatingFile) backupTime(name string) (time.Time, bool) {
+	suffix, ok := strings.CutPrefix(name, r.path+".")
+	if !ok {
+		return time.Time{}, false
+	}
+	rotated, err := time.ParseInLocation(backupTimeFormat, suffix, time.Local)
+	return rotated, err == nil
+}
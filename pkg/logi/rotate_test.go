@@ -0,0 +1,127 @@
+package logi
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock returns a clock advancing one second per call, so every
+// rotation gets a distinct backup name
+func fakeClock(start time.Time) func() time.Time {
+	now := start
+	return func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+}
+
+func TestRotatingFile_RotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "daemon.log")
+	file, err := OpenRotatingFile(path, RotateConfig{MaxSize: 10, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("OpenRotatingFile failed: %v", err)
+	}
+	defer file.Close()
+	file.now = fakeClock(time.Date(2024, 1, 2, 15, 4, 5, 0, time.Local))
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "fourth\n" {
+		t.Errorf("Expected the current file to hold the last line, got %q", data)
+	}
+	backups, err := file.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected MaxBackups to keep 2 rotated files, got %v", backups)
+	}
+	newest, _ := os.ReadFile(backups[0])
+	if string(newest) != "third\n" {
+		t.Errorf("Expected the newest backup first, got %q", newest)
+	}
+}
+
+func TestRotatingFile_RotatesByInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.log")
+	if err := os.WriteFile(path, []byte("existing\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := OpenRotatingFile(path, RotateConfig{Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	now := time.Now()
+	file.now = func() time.Time { return now }
+	file.opened = now
+	file.Write([]byte("appended\n"))
+	if backups, _ := file.Backups(); len(backups) != 0 {
+		t.Fatalf("Expected no rotation within the interval, got %v", backups)
+	}
+
+	now = now.Add(time.Hour)
+	file.Write([]byte("next hour\n"))
+	backups, _ := file.Backups()
+	if len(backups) != 1 {
+		t.Fatalf("Expected one rotation, got %v", backups)
+	}
+	old, _ := os.ReadFile(backups[0])
+	if string(old) != "existing\nappended\n" {
+		t.Errorf("Expected the file to be appended to before rotating, got %q", old)
+	}
+}
+
+func TestRotatingFile_MaxAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.log")
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.Local)
+	stale := path + "." + now.Add(-48*time.Hour).Format(backupTimeFormat)
+	recent := path + "." + now.Add(-time.Hour).Format(backupTimeFormat)
+	unrelated := path + ".bak"
+	for _, name := range []string{stale, recent, unrelated} {
+		os.WriteFile(name, []byte("old\n"), 0644)
+	}
+
+	file, err := OpenRotatingFile(path, RotateConfig{MaxAge: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	file.now = func() time.Time { return now }
+	file.Write([]byte("line\n"))
+	if err := file.Rotate(); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("Expected the backup older than MaxAge to be deleted")
+	}
+	for _, name := range []string{recent, unrelated} {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("Expected %s to be kept: %v", filepath.Base(name), err)
+		}
+	}
+}
+
+func TestRotatingFile_Closed(t *testing.T) {
+	file, err := OpenRotatingFile(filepath.Join(t.TempDir(), "daemon.log"), RotateConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	if _, err := file.Write([]byte("late\n")); err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Errorf("Expected writing to a closed file to fail, got %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Errorf("Expected Close to be idempotent, got %v", err)
+	}
+}
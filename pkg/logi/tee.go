@@ -0,0 +1,107 @@
+package logi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// TeeHandler is a slog.Handler that fans each record out to several
+// handlers, e.g. the console, a log file, and a ring buffer. Each handler
+// keeps its own level.
+type TeeHandler struct {
+	handlers []slog.Handler
+}
+
+// NewTeeHandler creates a handler writing to all of handlers
+func NewTeeHandler(handlers ...slog.Handler) *TeeHandler {
+	return &TeeHandler{handlers: handlers}
+}
+
+// Enabled reports whether any handler accepts the level
+func (h *TeeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h.handlers {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle passes the record to every handler that accepts its level. A
+// failing handler doesn't stop the others; their errors are joined.
+func (h *TeeHandler) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, handler := range h.handlers {
+		if handler.Enabled(ctx, record.Level) {
+			if err := handler.Handle(ctx, record.Clone()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WithAttrs returns a TeeHandler whose handlers all have the attributes
+func (h *TeeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, handler := range h.handlers {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return &TeeHandler{handlers: handlers}
+}
+
+// WithGroup returns a TeeHandler whose handlers all use the group
+func (h *TeeHandler) WithGroup(name string) slog.Handler {
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, handler := range h.handlers {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return &TeeHandler{handlers: handlers}
+}
+
+// RingBuffer is an io.Writer keeping the last lines written to it, so a
+// daemon can show recent log output (e.g. in a status page) without
+// reading its log file. slog handlers write one record per Write.
+type RingBuffer struct {
+	mu    sync.Mutex
+	lines []string
+	next  int
+	full  bool
+}
+
+// NewRingBuffer creates a buffer holding the last size lines
+func NewRingBuffer(size int) *RingBuffer {
+	if size < 1 {
+		size = 1
+	}
+	return &RingBuffer{lines: make([]string, size)}
+}
+
+// Write stores each line in p, dropping the oldest when the buffer is full
+func (b *RingBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		b.lines[b.next] = line
+		b.next = (b.next + 1) % len(b.lines)
+		if b.next == 0 {
+			b.full = true
+		}
+	}
+	return len(p), nil
+}
+
+// Lines returns the buffered lines, oldest first
+func (b *RingBuffer) Lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.full {
+		return append([]string(nil), b.lines[:b.next]...)
+	}
+	return append(append([]string(nil), b.lines[b.next:]...), b.lines[:b.next]...)
+}
@@ -0,0 +1,50 @@
+package logi
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTeeHandler_FansOut(t *testing.T) {
+	var info, debug bytes.Buffer
+	handler := NewTeeHandler(
+		slog.NewTextHandler(&info, &slog.HandlerOptions{Level: slog.LevelInfo}),
+		slog.NewTextHandler(&debug, &slog.HandlerOptions{Level: slog.LevelDebug}),
+	)
+	logger := slog.New(handler).With("daemon", "[ca]").WithGroup("req")
+
+	logger.Debug("cache miss", "key", "a")
+	logger.Info("issued", "serial", 42)
+
+	if strings.Contains(info.String(), "cache miss") || !strings.Contains(info.String(), "req.serial=42") {
+		t.Errorf("Unexpected info output:\n%s", info.String())
+	}
+	if !strings.Contains(debug.String(), "cache miss") || !strings.Contains(debug.String(), "daemon=[ca]") {
+		t.Errorf("Unexpected debug output:\n%s", debug.String())
+	}
+	if NewTeeHandler().Enabled(context.Background(), slog.LevelError) {
+		t.Error("Expected an empty tee to be disabled")
+	}
+}
+
+func TestRingBuffer_KeepsLastLines(t *testing.T) {
+	ring := NewRingBuffer(3)
+	if lines := ring.Lines(); len(lines) != 0 {
+		t.Errorf("Expected an empty buffer, got %v", lines)
+	}
+
+	ring.Write([]byte("one\n"))
+	ring.Write([]byte("two\nthree\n"))
+	if lines := ring.Lines(); !reflect.DeepEqual(lines, []string{"one", "two", "three"}) {
+		t.Errorf("Unexpected lines: %v", lines)
+	}
+
+	ring.Write([]byte("four\n"))
+	if lines := ring.Lines(); !reflect.DeepEqual(lines, []string{"two", "three", "four"}) {
+		t.Errorf("Expected the oldest line to be dropped, got %v", lines)
+	}
+}
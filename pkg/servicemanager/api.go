@@ -0,0 +1,126 @@
+package servicemanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// APIPrefix is the path prefix of the Handler endpoints, the major
+// version of the API matching SchemaVersion
+const APIPrefix = "/v1"
+
+// ServiceFilter selects services in GET /v1/services?filter=
+type ServiceFilter string
+
+const (
+	FilterAll        ServiceFilter = ""
+	FilterExpected   ServiceFilter = "expected"
+	FilterUnexpected ServiceFilter = "unexpected"
+	FilterDocker     ServiceFilter = "docker"
+	FilterLocal      ServiceFilter = "local"
+)
+
+// valid reports whether f is one of the filters
+func (f ServiceFilter) valid() bool {
+	switch f {
+	case FilterAll, FilterExpected, FilterUnexpected, FilterDocker, FilterLocal:
+		return true
+	}
+	return false
+}
+
+// APIError is the body of a Handler error response
+type APIError struct {
+	Error string `json:"error"`
+}
+
+// DiscoverFiltered discovers the services selected by a filter, like the
+// -expected, -unexpected, -docker, and -local flags
+func (sm *ServiceManager) DiscoverFiltered(filter ServiceFilter) ([]ServiceInfo, error) {
+	if !filter.valid() {
+		return nil, fmt.Errorf("unknown filter %q (use expected, unexpected, docker, or local)", filter)
+	}
+	switch filter {
+	case FilterExpected:
+		return sm.DiscoverExpectedServices()
+	case FilterUnexpected:
+		return sm.DiscoverUnexpectedServices()
+	case FilterDocker:
+		return sm.DiscoverDockerServices()
+	case FilterLocal:
+		return sm.DiscoverLocalServices(), nil
+	default:
+		return sm.DiscoverAllServices()
+	}
+}
+
+// Handler serves discovery results for dashboards and scripts, as the
+// documents described by JSONSchema:
+//
+//	GET /v1/services         []ServiceInfo (?filter=expected|unexpected|docker|local)
+//	GET /v1/services/{port}  ServiceInfo, or 404 when nothing listens on the port
+//	GET /v1/status           ServiceStatus
+//	GET /v1/missing          []autoport.ServiceConfig
+//	GET /v1/schema           The JSON Schema
+//
+// Every response carries SchemaVersionHeader, and errors are an APIError.
+// Requests are served one at a time since each one scans the ports.
+// Package servicemanager/client is a typed client for it.
+func (sm *ServiceManager) Handler() http.Handler {
+	var mu sync.Mutex
+	serve := func(pattern string, handle func(r *http.Request) (interface{}, int, error)) (string, http.HandlerFunc) {
+		return "GET " + APIPrefix + pattern, func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			body, code, err := handle(r)
+			mu.Unlock()
+			if err != nil {
+				body = APIError{Error: err.Error()}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set(SchemaVersionHeader, strconv.Itoa(SchemaVersion))
+			w.WriteHeader(code)
+			json.NewEncoder(w).Encode(body)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(serve("/services", func(r *http.Request) (interface{}, int, error) {
+		filter := ServiceFilter(r.URL.Query().Get("filter"))
+		if !filter.valid() {
+			return nil, http.StatusBadRequest, fmt.Errorf("unknown filter %q (use expected, unexpected, docker, or local)", filter)
+		}
+		services, err := sm.DiscoverFiltered(filter)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		return services, http.StatusOK, nil
+	}))
+	mux.HandleFunc(serve("/services/{port}", func(r *http.Request) (interface{}, int, error) {
+		port, err := strconv.Atoi(r.PathValue("port"))
+		if err != nil || port <= 0 || port > 65535 {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid port %q", r.PathValue("port"))
+		}
+		service, err := sm.CheckPort(port)
+		if err != nil {
+			return nil, http.StatusNotFound, err
+		}
+		return service, http.StatusOK, nil
+	}))
+	mux.HandleFunc(serve("/status", func(r *http.Request) (interface{}, int, error) {
+		status, err := sm.GetServiceStatus()
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		return status, http.StatusOK, nil
+	}))
+	mux.HandleFunc(serve("/missing", func(r *http.Request) (interface{}, int, error) {
+		return sm.GetMissingServices(), http.StatusOK, nil
+	}))
+	mux.HandleFunc(serve("/schema", func(r *http.Request) (interface{}, int, error) {
+		return json.RawMessage(JSONSchema()), http.StatusOK, nil
+	}))
+	return mux
+}
@@ -0,0 +1,62 @@
+package servicemanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	handler := NewSimple(WithPortRange(port, port)).Handler()
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Header().Get(SchemaVersionHeader) != "1" || rec.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s: missing headers %v", path, rec.Header())
+		}
+		return rec
+	}
+
+	rec := get("/v1/services/" + strconv.Itoa(port))
+	var service ServiceInfo
+	if err := json.NewDecoder(rec.Body).Decode(&service); err != nil || rec.Code != http.StatusOK || service.ExternalPort != port || !service.IsListening {
+		t.Errorf("Expected the listening service, got %d: %+v (%v)", rec.Code, service, err)
+	}
+
+	rec = get("/v1/services?filter=local")
+	var services []ServiceInfo
+	if err := json.NewDecoder(rec.Body).Decode(&services); err != nil || len(services) != 1 || services[0].ExternalPort != port {
+		t.Errorf("Expected one local service, got %d: %+v (%v)", rec.Code, services, err)
+	}
+
+	for path, code := range map[string]int{
+		"/v1/services?filter=bogus": http.StatusBadRequest,
+		"/v1/services/65534":        http.StatusNotFound,
+		"/v1/services/http":         http.StatusBadRequest,
+	} {
+		rec := get(path)
+		var apiErr APIError
+		if err := json.NewDecoder(rec.Body).Decode(&apiErr); err != nil || rec.Code != code || apiErr.Error == "" {
+			t.Errorf("%s: expected %d with an error, got %d: %+v", path, code, rec.Code, apiErr)
+		}
+	}
+
+	rec = get("/v1/schema")
+	var schema, published bytes.Buffer
+	json.Compact(&schema, rec.Body.Bytes())
+	json.Compact(&published, JSONSchema())
+	if !bytes.Equal(schema.Bytes(), published.Bytes()) {
+		t.Error("Expected /v1/schema to serve the published schema")
+	}
+}
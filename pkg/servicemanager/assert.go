@@ -0,0 +1,230 @@
+package servicemanager
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Expectation declares what a running environment should look like, for
+// environment tests of docker-compose stacks. It is usually written by
+// Snapshot and checked by Assert.
+type Expectation struct {
+	Services        []ExpectedService `yaml:"services" json:"services"`
+	AllowUnexpected bool              `yaml:"allow_unexpected,omitempty" json:"allow_unexpected,omitempty"` // Extra listening services are not a mismatch
+}
+
+// ExpectedService is one service an Expectation requires. Empty fields are
+// not checked.
+type ExpectedService struct {
+	Port   int         `yaml:"port" json:"port"`
+	Name   string      `yaml:"name,omitempty" json:"name,omitempty"`
+	Type   ServiceType `yaml:"type,omitempty" json:"type,omitempty"`
+	Image  string      `yaml:"image,omitempty" json:"image,omitempty"`
+	Health HealthState `yaml:"health,omitempty" json:"health,omitempty"` // Probes the health URL when set
+}
+
+// AssertMismatch is one difference between an Expectation and the running
+// environment
+type AssertMismatch struct {
+	Port     int    `json:"port"`
+	Service  string `json:"service,omitempty"`
+	Field    string `json:"field"` // service, name, type, image, health, or unexpected
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// AssertReport is the result of Assert
+type AssertReport struct {
+	Checked    int              `json:"checked"`
+	Mismatches []AssertMismatch `json:"mismatches"`
+}
+
+// Passed reports whether the environment matched the expectation
+func (r *AssertReport) Passed() bool {
+	return len(r.Mismatches) == 0
+}
+
+// ParseExpectation decodes a YAML expectation, rejecting unknown fields so
+// typos don't silently skip checks
+func ParseExpectation(data []byte) (*Expectation, error) {
+	var expectation Expectation
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&expectation); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse expectation: %w", err)
+	}
+
+	seen := make(map[int]bool)
+	for _, service := range expectation.Services {
+		if service.Port <= 0 || service.Port > 65535 {
+			return nil, fmt.Errorf("invalid port %d in expectation", service.Port)
+		}
+		if seen[service.Port] {
+			return nil, fmt.Errorf("port %d is listed more than once", service.Port)
+		}
+		seen[service.Port] = true
+
+		switch service.Health {
+		case "", HealthHealthy, HealthUnhealthy, HealthUnknown:
+		default:
+			return nil, fmt.Errorf("port %d: invalid health %q", service.Port, service.Health)
+		}
+	}
+	return &expectation, nil
+}
+
+// LoadExpectation reads a YAML expectation file
+func LoadExpectation(path string) (*Expectation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseExpectation(data)
+}
+
+// Snapshot describes the running environment as an Expectation, so a known
+// good state can be saved and asserted later. Health is recorded for
+// services with a health URL.
+func (sm *ServiceManager) Snapshot(ctx context.Context) (*Expectation, error) {
+	services, err := sm.DiscoverAllServices()
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover services: %w", err)
+	}
+
+	expectation := &Expectation{}
+	for _, service := range services {
+		if !service.IsListening {
+			continue
+		}
+		expected := ExpectedService{
+			Port: service.ExternalPort,
+			Name: service.Name,
+			Type: service.Type,
+		}
+		if service.Type == ServiceTypeDockerContainer {
+			expected.Image = service.Image
+		}
+		if health := sm.CheckHealth(ctx, service); health.State != HealthUnknown {
+			expected.Health = health.State
+		}
+		expectation.Services = append(expectation.Services, expected)
+	}
+	return expectation, nil
+}
+
+// Assert checks the running environment against an expectation: every
+// expected service must be listening with the given name, type, image, and
+// health, and, unless AllowUnexpected, nothing else may be listening.
+func (sm *ServiceManager) Assert(ctx context.Context, expectation *Expectation) (*AssertReport, error) {
+	services, err := sm.DiscoverAllServices()
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover services: %w", err)
+	}
+
+	health := func(service ServiceInfo) HealthState {
+		return sm.CheckHealth(ctx, service).State
+	}
+	return &AssertReport{
+		Checked:    len(expectation.Services),
+		Mismatches: compareExpectation(expectation, services, health),
+	}, nil
+}
+
+// compareExpectation lists the differences between an expectation and the
+// discovered services, ordered by port. health is only called for services
+// whose expected health is set.
+func compareExpectation(expectation *Expectation, services []ServiceInfo, health func(ServiceInfo) HealthState) []AssertMismatch {
+	running := make(map[int]ServiceInfo)
+	for _, service := range services {
+		if service.IsListening {
+			running[service.ExternalPort] = service
+		}
+	}
+
+	var mismatches []AssertMismatch
+	expectedPorts := make(map[int]bool)
+	for _, expected := range expectation.Services {
+		expectedPorts[expected.Port] = true
+		mismatch := func(field, want, got string) {
+			mismatches = append(mismatches, AssertMismatch{
+				Port:     expected.Port,
+				Service:  expected.Name,
+				Field:    field,
+				Expected: want,
+				Actual:   got,
+			})
+		}
+
+		service, ok := running[expected.Port]
+		if !ok {
+			mismatch("service", "listening", "not listening")
+			continue
+		}
+		if expected.Name != "" && service.Name != expected.Name {
+			mismatch("name", expected.Name, service.Name)
+		}
+		if expected.Type != "" && service.Type != expected.Type {
+			mismatch("type", string(expected.Type), string(service.Type))
+		}
+		if expected.Image != "" && !imageSatisfies(service.Image, expected.Image) {
+			mismatch("image", expected.Image, service.Image)
+		}
+		if expected.Health != "" {
+			if state := health(service); state != expected.Health {
+				mismatch("health", string(expected.Health), string(state))
+			}
+		}
+	}
+
+	if !expectation.AllowUnexpected {
+		for port, service := range running {
+			if expectedPorts[port] {
+				continue
+			}
+			mismatches = append(mismatches, AssertMismatch{
+				Port:     port,
+				Service:  service.Name,
+				Field:    "unexpected",
+				Expected: "not listening",
+				Actual:   fmt.Sprintf("%s (%s)", service.Name, service.Type),
+			})
+		}
+	}
+
+	sort.SliceStable(mismatches, func(i, j int) bool {
+		return mismatches[i].Port < mismatches[j].Port
+	})
+	return mismatches
+}
+
+// imageSatisfies reports whether a running image meets an expected one. A
+// tagged expectation must match exactly; an untagged one accepts any tag of
+// the same repository.
+func imageSatisfies(actual, expected string) bool {
+	if actual == expected {
+		return true
+	}
+	if _, tagged := imageTag(expected); tagged {
+		return false
+	}
+	repository, _ := imageTag(actual)
+	return repository == expected
+}
+
+// imageTag splits an image reference into repository and tag, ignoring a
+// registry port such as localhost:5000/app
+func imageTag(image string) (string, bool) {
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return image, false
+	}
+	return image[:i], true
+}
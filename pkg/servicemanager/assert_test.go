@@ -0,0 +1,107 @@
+package servicemanager
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseExpectation(t *testing.T) {
+	data := []byte(`
+allow_unexpected: true
+services:
+  - port: 8080
+    name: api
+    type: docker
+    image: example/api:1.2
+    health: healthy
+  - port: 5432
+`)
+	expectation, err := ParseExpectation(data)
+	if err != nil {
+		t.Fatalf("ParseExpectation failed: %v", err)
+	}
+	if !expectation.AllowUnexpected || len(expectation.Services) != 2 {
+		t.Fatalf("Unexpected expectation: %+v", expectation)
+	}
+	if api := expectation.Services[0]; api.Type != ServiceTypeDockerContainer || api.Health != HealthHealthy || api.Image != "example/api:1.2" {
+		t.Errorf("Unexpected service: %+v", api)
+	}
+
+	for name, bad := range map[string]string{
+		"unknown field":  "services:\n  - port: 80\n    imge: nginx\n",
+		"invalid port":   "services:\n  - port: 0\n",
+		"duplicate port": "services:\n  - port: 80\n  - port: 80\n",
+		"invalid health": "services:\n  - port: 80\n    health: ok\n",
+	} {
+		if _, err := ParseExpectation([]byte(bad)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestCompareExpectation(t *testing.T) {
+	services := []ServiceInfo{
+		{Name: "api", Type: ServiceTypeDockerContainer, ExternalPort: 8080, Image: "example/api:1.3", IsListening: true},
+		{Name: "web", Type: ServiceTypeLocalProcess, ExternalPort: 3000, IsListening: true, HealthURL: "http://localhost/health"},
+		{Name: "db", Type: ServiceTypeDockerContainer, ExternalPort: 5432, Image: "postgres:16", IsListening: true},
+		{Name: "stray", Type: ServiceTypeLocalProcess, ExternalPort: 9999, IsListening: true},
+		{Name: "stopped", Type: ServiceTypeDockerContainer, ExternalPort: 6379, Image: "redis:7"},
+	}
+	expectation := &Expectation{Services: []ExpectedService{
+		{Port: 8080, Name: "api", Image: "example/api:1.2"},
+		{Port: 3000, Name: "web", Type: ServiceTypeDockerContainer, Health: HealthHealthy},
+		{Port: 5432, Image: "postgres"},
+		{Port: 6379, Name: "cache"},
+	}}
+
+	probed := 0
+	health := func(service ServiceInfo) HealthState {
+		probed++
+		return HealthUnhealthy
+	}
+	mismatches := compareExpectation(expectation, services, health)
+
+	var got []string
+	for _, m := range mismatches {
+		got = append(got, m.Field+"@"+m.Expected+"->"+m.Actual)
+	}
+	want := []string{
+		"type@docker->local",
+		"health@healthy->unhealthy",
+		"service@listening->not listening",
+		"image@example/api:1.2->example/api:1.3",
+		"unexpected@not listening->stray (local)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected mismatches:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if probed != 1 {
+		t.Errorf("Expected one health probe, got %d", probed)
+	}
+
+	expectation.AllowUnexpected = true
+	for _, m := range compareExpectation(expectation, services, health) {
+		if m.Field == "unexpected" {
+			t.Errorf("Unexpected service reported with AllowUnexpected: %+v", m)
+		}
+	}
+}
+
+func TestImageSatisfies(t *testing.T) {
+	tests := []struct {
+		actual, expected string
+		want             bool
+	}{
+		{"nginx:1.25", "nginx:1.25", true},
+		{"nginx:1.25", "nginx", true},
+		{"nginx:1.25", "nginx:1.24", false},
+		{"localhost:5000/app:2", "localhost:5000/app", true},
+		{"localhost:5000/app:2", "localhost:5000/app:3", false},
+		{"redis:7", "nginx", false},
+	}
+	for _, tt := range tests {
+		if got := imageSatisfies(tt.actual, tt.expected); got != tt.want {
+			t.Errorf("imageSatisfies(%q, %q) = %v, want %v", tt.actual, tt.expected, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package servicemanager
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nzions/sharedgolibs/pkg/autoport"
+)
+
+// DefaultCertCheckTimeout bounds the TLS handshake used to inspect a
+// service's certificate
+const DefaultCertCheckTimeout = 2 * time.Second
+
+// certExpiryWarningDays flags certificates that expire soon
+const certExpiryWarningDays = 7
+
+// CertStatus describes the certificate a TLS service presents
+type CertStatus struct {
+	Subject      string    `json:"subject,omitempty"`
+	Issuer       string    `json:"issuer,omitempty"`
+	SANs         []string  `json:"sans,omitempty"`
+	Hostname     string    `json:"hostname"`  // Name checked against the SANs
+	SANMatch     bool      `json:"san_match"` // Hostname is covered by the SANs
+	NotAfter     time.Time `json:"not_after,omitempty"`
+	DaysToExpiry int       `json:"days_to_expiry"`
+	Expired      bool      `json:"expired"`
+
+	// IssuedByCA reports whether the certificate chains to the roots set
+	// with WithCARoots; nil when no roots are configured
+	IssuedByCA *bool `json:"issued_by_ca,omitempty"`
+
+	Error string `json:"error,omitempty"` // TLS handshake failure
+}
+
+// Problem summarizes what is wrong with the certificate, or returns "" when
+// it is fine
+func (c *CertStatus) Problem() string {
+	var problems []string
+	switch {
+	case c.Error != "":
+		return "TLS failed: " + c.Error
+	case c.Expired:
+		problems = append(problems, "expired")
+	case c.DaysToExpiry < certExpiryWarningDays:
+		problems = append(problems, fmt.Sprintf("expires in %d days", c.DaysToExpiry))
+	}
+	if c.IssuedByCA != nil && !*c.IssuedByCA {
+		problems = append(problems, "not issued by the CA")
+	}
+	if !c.SANMatch {
+		problems = append(problems, "no SAN for "+c.Hostname)
+	}
+	return strings.Join(problems, ", ")
+}
+
+// WithCARoots sets the CA certificates that services' certificates are
+// expected to chain to, e.g. the development CA's bundle
+func WithCARoots(roots *x509.CertPool) ManagerOption {
+	return func(sm *ServiceManager) {
+		sm.caRoots = roots
+	}
+}
+
+// isSecureService reports whether a port is expected to serve TLS, from
+// autoport or the known services
+func (sm *ServiceManager) isSecureService(port int) bool {
+	if expected, found := autoport.GetServiceByPort(port); found && expected.IsSecure {
+		return true
+	}
+	return sm.knownServices[port].IsSecure
+}
+
+// CheckCertificate connects to a port on localhost (or the host set with
+// WithDockerHostAddress) with TLS and describes the certificate it
+// presents. The handshake does not verify the certificate, so broken
+// certificates are reported rather than refused.
+func (sm *ServiceManager) CheckCertificate(port int) *CertStatus {
+	return checkCertificate(sm.GetHostAddress(), port, sm.caRoots, time.Now())
+}
+
+// checkCertificate inspects the certificate served on host:port
+func checkCertificate(host string, port int, roots *x509.CertPool, now time.Time) *CertStatus {
+	status := &CertStatus{Hostname: host}
+
+	dialer := &net.Dialer{Timeout: DefaultCertCheckTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, strconv.Itoa(port)), &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true, // Inspect whatever is served
+	})
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	defer conn.Close()
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		status.Error = "no certificate presented"
+		return status
+	}
+	describeCertificate(status, certs[0], certs[1:], roots, now)
+	return status
+}
+
+// describeCertificate fills status from a leaf certificate and the
+// intermediates presented with it
+func describeCertificate(status *CertStatus, leaf *x509.Certificate, intermediates []*x509.Certificate, roots *x509.CertPool, now time.Time) {
+	status.Subject = leaf.Subject.CommonName
+	status.Issuer = leaf.Issuer.CommonName
+	status.SANs = append([]string{}, leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		status.SANs = append(status.SANs, ip.String())
+	}
+	status.SANMatch = leaf.VerifyHostname(status.Hostname) == nil
+	status.NotAfter = leaf.NotAfter
+	status.Expired = now.After(leaf.NotAfter)
+	status.DaysToExpiry = int(leaf.NotAfter.Sub(now).Hours() / 24)
+
+	if roots != nil {
+		pool := x509.NewCertPool()
+		for _, cert := range intermediates {
+			pool.AddCert(cert)
+		}
+		// Check the issuer only; expiry and names are reported separately
+		_, err := leaf.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: pool,
+			CurrentTime:   leaf.NotBefore.Add(time.Second),
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		issued := err == nil
+		status.IssuedByCA = &issued
+	}
+}
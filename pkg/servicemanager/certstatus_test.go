@@ -0,0 +1,92 @@
+package servicemanager
+
+import (
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	_, portStr, _ := net.SplitHostPort(server.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	t.Run("WithoutRoots", func(t *testing.T) {
+		status := checkCertificate("127.0.0.1", port, nil, time.Now())
+		if status.Error != "" {
+			t.Fatalf("Unexpected handshake error: %s", status.Error)
+		}
+		if !status.SANMatch || status.Expired || status.IssuedByCA != nil {
+			t.Errorf("Unexpected status: %+v", status)
+		}
+		if status.Problem() != "" {
+			t.Errorf("Expected no problem, got %q", status.Problem())
+		}
+	})
+
+	t.Run("IssuedByRoots", func(t *testing.T) {
+		roots := x509.NewCertPool()
+		roots.AddCert(server.Certificate())
+		status := checkCertificate("127.0.0.1", port, roots, time.Now())
+		if status.IssuedByCA == nil || !*status.IssuedByCA {
+			t.Errorf("Expected the certificate to chain to the roots: %+v", status)
+		}
+	})
+
+	t.Run("OtherRoots", func(t *testing.T) {
+		status := checkCertificate("127.0.0.1", port, x509.NewCertPool(), time.Now())
+		if status.IssuedByCA == nil || *status.IssuedByCA {
+			t.Errorf("Expected the certificate not to chain to empty roots: %+v", status)
+		}
+		if !strings.Contains(status.Problem(), "not issued by the CA") {
+			t.Errorf("Expected an issuer problem, got %q", status.Problem())
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		status := checkCertificate("127.0.0.1", port, nil, server.Certificate().NotAfter.Add(time.Hour))
+		if !status.Expired || status.Problem() != "expired" {
+			t.Errorf("Expected an expired certificate, got %+v", status)
+		}
+	})
+
+	t.Run("PlainListener", func(t *testing.T) {
+		plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer plain.Close()
+		_, plainPort, _ := net.SplitHostPort(plain.Listener.Addr().String())
+		p, _ := strconv.Atoi(plainPort)
+
+		status := checkCertificate("127.0.0.1", p, nil, time.Now())
+		if status.Error == "" || !strings.HasPrefix(status.Problem(), "TLS failed") {
+			t.Errorf("Expected a handshake failure, got %+v", status)
+		}
+	})
+}
+
+func TestCertStatusProblem(t *testing.T) {
+	no := false
+	tests := []struct {
+		name   string
+		status CertStatus
+		want   string
+	}{
+		{"Healthy", CertStatus{SANMatch: true, DaysToExpiry: 90}, ""},
+		{"ExpiringSoon", CertStatus{SANMatch: true, DaysToExpiry: 3}, "expires in 3 days"},
+		{"WrongHost", CertStatus{Hostname: "localhost", DaysToExpiry: 90}, "no SAN for localhost"},
+		{"Combined", CertStatus{Hostname: "localhost", Expired: true, IssuedByCA: &no}, "expired, not issued by the CA, no SAN for localhost"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.status.Problem(); got != tt.want {
+				t.Errorf("Problem() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+// Package client is a typed client for the servicemanager HTTP API, served
+// by ServiceManager.Handler and `servicemanager -serve`, so dashboards and
+// scripts get servicemanager's Go types instead of parsing CLI output.
+//
+// Example:
+//
+//	c := client.New("http://localhost:9180")
+//	status, err := c.Status(ctx)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	fmt.Printf("%d of %d services listening\n", status.Listening, status.Total)
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/nzions/sharedgolibs/pkg/autoport"
+	"github.com/nzions/sharedgolibs/pkg/servicemanager"
+)
+
+var (
+	// ErrSchemaVersion is returned when the server speaks a schema version
+	// other than servicemanager.SchemaVersion
+	ErrSchemaVersion = errors.New("unsupported servicemanager schema version")
+
+	// ErrNotListening is returned by Service when nothing listens on the port
+	ErrNotListening = errors.New("no service listening on port")
+)
+
+// Error is an error response of the API
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("servicemanager API: %s (status %d)", e.Message, e.StatusCode)
+}
+
+// Client calls a servicemanager HTTP API
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client, e.g. for timeouts or TLS
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// New creates a client for the API at baseURL, e.g. "http://localhost:9180"
+func New(baseURL string, options ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Services returns the discovered services selected by filter
+// (servicemanager.FilterAll for every service)
+func (c *Client) Services(ctx context.Context, filter servicemanager.ServiceFilter) ([]servicemanager.ServiceInfo, error) {
+	path := "/services"
+	if filter != servicemanager.FilterAll {
+		path += "?filter=" + url.QueryEscape(string(filter))
+	}
+	var services []servicemanager.ServiceInfo
+	if err := c.get(ctx, path, &services); err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+// Service returns the service listening on a port, or ErrNotListening
+func (c *Client) Service(ctx context.Context, port int) (*servicemanager.ServiceInfo, error) {
+	var service servicemanager.ServiceInfo
+	err := c.get(ctx, "/services/"+strconv.Itoa(port), &service)
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w %d", ErrNotListening, port)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &service, nil
+}
+
+// Status returns the status of all expected and discovered services
+func (c *Client) Status(ctx context.Context) (*servicemanager.ServiceStatus, error) {
+	var status servicemanager.ServiceStatus
+	if err := c.get(ctx, "/status", &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Missing returns the expected services that aren't running
+func (c *Client) Missing(ctx context.Context) ([]autoport.ServiceConfig, error) {
+	var missing []autoport.ServiceConfig
+	if err := c.get(ctx, "/missing", &missing); err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
+
+// Schema returns the server's JSON Schema of its documents
+func (c *Client) Schema(ctx context.Context) (json.RawMessage, error) {
+	var schema json.RawMessage
+	if err := c.get(ctx, "/schema", &schema); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// get fetches an API path into v, checking the schema version first
+func (c *Client) get(ctx context.Context, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+servicemanager.APIPrefix+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if version := resp.Header.Get(servicemanager.SchemaVersionHeader); version != strconv.Itoa(servicemanager.SchemaVersion) {
+		return fmt.Errorf("%w: server sent %q, client expects %d", ErrSchemaVersion, version, servicemanager.SchemaVersion)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var body servicemanager.APIError
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Error == "" {
+			body.Error = http.StatusText(resp.StatusCode)
+		}
+		return &Error{StatusCode: resp.StatusCode, Message: body.Error}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid servicemanager API response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nzions/sharedgolibs/pkg/servicemanager"
+)
+
+func TestClient(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	server := httptest.NewServer(servicemanager.NewSimple(servicemanager.WithPortRange(port, port)).Handler())
+	defer server.Close()
+	c := New(server.URL + "/")
+	ctx := context.Background()
+
+	service, err := c.Service(ctx, port)
+	if err != nil || service.ExternalPort != port || !service.IsListening {
+		t.Errorf("Expected the listening service, got %+v (%v)", service, err)
+	}
+	if _, err := c.Service(ctx, 65534); !errors.Is(err, ErrNotListening) {
+		t.Errorf("Expected ErrNotListening, got %v", err)
+	}
+
+	services, err := c.Services(ctx, servicemanager.FilterLocal)
+	if err != nil || len(services) != 1 {
+		t.Errorf("Expected one local service, got %+v (%v)", services, err)
+	}
+	var apiErr *Error
+	if _, err := c.Services(ctx, "bogus"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected a 400 API error for an unknown filter, got %v", err)
+	}
+
+	status, err := c.Status(ctx)
+	if err != nil || status.Total != 1 || status.Listening != 1 {
+		t.Errorf("Expected one listening service in the status, got %+v (%v)", status, err)
+	}
+	if schema, err := c.Schema(ctx); err != nil || len(schema) == 0 {
+		t.Errorf("Expected the schema, got %v", err)
+	}
+}
+
+func TestClientSchemaVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(servicemanager.SchemaVersionHeader, "2")
+		w.Write([]byte(`{"running": "a shape this client doesn't know"}`))
+	}))
+	defer server.Close()
+
+	if _, err := New(server.URL).Status(context.Background()); !errors.Is(err, ErrSchemaVersion) {
+		t.Errorf("Expected ErrSchemaVersion, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package servicemanager
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvironmentConfig is a ServiceManager's configuration as shared between
+// machines by ExportConfig and ImportConfig, so a team can keep one canonical
+// environment definition instead of each machine's defaults drifting
+type EnvironmentConfig struct {
+	PortRange      PortRange       `yaml:"port_range" json:"port_range"`
+	MonitoredPorts []MonitoredPort `yaml:"monitored_ports" json:"monitored_ports"`
+	KnownServices  []KnownService  `yaml:"known_services" json:"known_services"`
+	ProtectedPorts []ProtectedPort `yaml:"protected_ports,omitempty" json:"protected_ports,omitempty"`
+}
+
+// MonitoredPort is a port checked and killed by the monitored port commands
+type MonitoredPort struct {
+	Port        int    `yaml:"port" json:"port"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+}
+
+// KnownService names the service expected on a port
+type KnownService struct {
+	Port      int    `yaml:"port" json:"port"`
+	Name      string `yaml:"name" json:"name"`
+	HealthURL string `yaml:"health_url,omitempty" json:"health_url,omitempty"`
+	IsSecure  bool   `yaml:"is_secure,omitempty" json:"is_secure,omitempty"`
+}
+
+// Config returns the monitored ports, known services, port range, and
+// protected ports, sorted by port
+func (sm *ServiceManager) Config() *EnvironmentConfig {
+	config := &EnvironmentConfig{
+		PortRange:      sm.portRange,
+		ProtectedPorts: sm.GetProtectedPorts(),
+	}
+	for _, port := range sm.monitoredPorts {
+		config.MonitoredPorts = append(config.MonitoredPorts, MonitoredPort{Port: port, Description: sm.portDescriptions[port]})
+	}
+	sort.SliceStable(config.MonitoredPorts, func(i, j int) bool { return config.MonitoredPorts[i].Port < config.MonitoredPorts[j].Port })
+	for port, service := range sm.knownServices {
+		config.KnownServices = append(config.KnownServices, KnownService{
+			Port:      port,
+			Name:      service.Name,
+			HealthURL: service.HealthURL,
+			IsSecure:  service.IsSecure,
+		})
+	}
+	sort.Slice(config.KnownServices, func(i, j int) bool { return config.KnownServices[i].Port < config.KnownServices[j].Port })
+	return config
+}
+
+// ExportConfig writes Config as YAML to w, for ImportConfig on another
+// machine
+func (sm *ServiceManager) ExportConfig(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(sm.Config()); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	return encoder.Close()
+}
+
+// ImportConfig reads a YAML config written by ExportConfig from r and applies
+// it with ApplyConfig
+func (sm *ServiceManager) ImportConfig(r io.Reader) error {
+	config, err := ParseConfig(r)
+	if err != nil {
+		return err
+	}
+	sm.ApplyConfig(config)
+	return nil
+}
+
+// ParseConfig decodes and validates a YAML config, rejecting unknown fields
+// so typos don't silently drop settings
+func ParseConfig(r io.Reader) (*EnvironmentConfig, error) {
+	var config EnvironmentConfig
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	if config.PortRange.Start < 0 || config.PortRange.End > 65535 || config.PortRange.Start > config.PortRange.End {
+		return nil, fmt.Errorf("invalid port range %d-%d in config", config.PortRange.Start, config.PortRange.End)
+	}
+	seen := make(map[int]bool)
+	for _, monitored := range config.MonitoredPorts {
+		if err := validConfigPort(monitored.Port, "monitored", seen); err != nil {
+			return nil, err
+		}
+	}
+	clear(seen)
+	for _, known := range config.KnownServices {
+		if err := validConfigPort(known.Port, "known service", seen); err != nil {
+			return nil, err
+		}
+		if known.Name == "" {
+			return nil, fmt.Errorf("known service on port %d has no name", known.Port)
+		}
+	}
+	clear(seen)
+	for _, protected := range config.ProtectedPorts {
+		if err := validConfigPort(protected.Port, "protected", seen); err != nil {
+			return nil, err
+		}
+	}
+	return &config, nil
+}
+
+// validConfigPort checks a port of a config list, and that it isn't listed
+// twice
+func validConfigPort(port int, list string, seen map[int]bool) error {
+	if port <= 0 || port > 65535 {
+		return fmt.Errorf("invalid %s port %d in config", list, port)
+	}
+	if seen[port] {
+		return fmt.Errorf("%s port %d is listed more than once", list, port)
+	}
+	seen[port] = true
+	return nil
+}
+
+// ApplyConfig replaces the monitored ports, known services, and port range
+// with those of config. A zero port range keeps the current one. Protected
+// ports are added to those already protected, so an import never exposes a
+// port to kills.
+func (sm *ServiceManager) ApplyConfig(config *EnvironmentConfig) {
+	if config.PortRange != (PortRange{}) {
+		sm.portRange = config.PortRange
+	}
+
+	sm.monitoredPorts = make([]int, 0, len(config.MonitoredPorts))
+	sm.portDescriptions = make(map[int]string, len(config.MonitoredPorts))
+	for _, monitored := range config.MonitoredPorts {
+		sm.AddMonitoredPort(monitored.Port, monitored.Description)
+	}
+
+	sm.knownServices = make(map[int]ServiceConfig, len(config.KnownServices))
+	for _, known := range config.KnownServices {
+		sm.AddKnownService(known.Port, known.Name, known.HealthURL, known.IsSecure)
+	}
+
+	for _, protected := range config.ProtectedPorts {
+		sm.protectPort(protected.Port, protected.Reason)
+	}
+}
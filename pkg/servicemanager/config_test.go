@@ -0,0 +1,61 @@
+package servicemanager
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExportImportConfig(t *testing.T) {
+	source := NewSimple(
+		WithPortRange(3000, 4000),
+		WithMonitoredPort(3001, "API"),
+		WithMonitoredPort(3000, "Web"),
+		WithKnownService(3001, "API", "http://localhost:3001/health", false),
+		WithProtectedPort(5432, "postgres"),
+	)
+	var exported bytes.Buffer
+	if err := source.ExportConfig(&exported); err != nil {
+		t.Fatalf("ExportConfig failed: %v", err)
+	}
+	if !strings.Contains(exported.String(), "port_range:\n  start: 3000\n  end: 4000\n") {
+		t.Errorf("Unexpected export:\n%s", exported.String())
+	}
+
+	target := New(WithProtectedPort(6379, "redis"))
+	if err := target.ImportConfig(bytes.NewReader(exported.Bytes())); err != nil {
+		t.Fatalf("ImportConfig failed: %v", err)
+	}
+	if got, want := target.GetMonitoredPorts(), []int{3000, 3001}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Monitored ports = %v, want %v", got, want)
+	}
+	if target.GetPortRange() != (PortRange{Start: 3000, End: 4000}) || target.GetPortDescription(3000) != "Web" {
+		t.Errorf("Unexpected config %+v", target.Config())
+	}
+	if target.GetPortDescription(8080) != "Unknown Service" {
+		t.Error("Expected the default known services to be replaced")
+	}
+	// Imports add protected ports without dropping local ones
+	if _, protected := target.IsProtectedPort(6379); !protected {
+		t.Error("Expected port 6379 to stay protected")
+	}
+	if reason, _ := target.IsProtectedPort(5432); reason != "postgres" {
+		t.Errorf("Expected port 5432 to be protected, got %q", reason)
+	}
+
+	for name, content := range map[string]string{
+		"unknown field":  "monitored_ports:\n  - prot: 3000\n",
+		"bad port":       "monitored_ports:\n  - port: 70000\n",
+		"duplicate port": "known_services:\n  - {port: 3000, name: a}\n  - {port: 3000, name: b}\n",
+		"unnamed":        "known_services:\n  - port: 3000\n",
+		"bad range":      "port_range: {start: 4000, end: 3000}\n",
+	} {
+		if err := target.ImportConfig(strings.NewReader(content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if target.GetPortRange().Start != 3000 {
+		t.Error("Expected a failed import to change nothing")
+	}
+}
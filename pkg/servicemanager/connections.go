@@ -0,0 +1,211 @@
+package servicemanager
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Socket states in /proc/net/tcp
+const (
+	tcpEstablished = "01"
+	tcpListen      = "0A"
+)
+
+// TCPConnection is an established TCP connection. PID and Command are
+// empty when the owner is not visible, and for connections sampled inside
+// a container.
+type TCPConnection struct {
+	Local    netip.AddrPort `json:"local"`
+	Remote   netip.AddrPort `json:"remote"`
+	Outbound bool           `json:"outbound"` // The local port is not a listening port
+	PID      string         `json:"pid,omitempty"`
+	Command  string         `json:"command,omitempty"`
+
+	inode string // Socket inode, to find the owning process
+}
+
+// WithConnections samples established TCP connections when building the
+// network topology, adding an EdgeConnection from each service to the
+// services (or outside addresses) it is talking to. Only connections on
+// this machine are visible, and other users' processes only as root.
+func WithConnections() ManagerOption {
+	return func(sm *ServiceManager) {
+		sm.connections = true
+	}
+}
+
+// GetConnectionMap samples established TCP connections, with or without
+// WithConnections, and returns which service talks to which as the
+// EdgeConnection edges of the topology
+func (sm *ServiceManager) GetConnectionMap() ([]TopologyEdge, error) {
+	topology, err := sm.networkTopology(true)
+	if err != nil {
+		return nil, err
+	}
+
+	edges := []TopologyEdge{}
+	for _, edge := range topology.Edges {
+		if edge.Kind == EdgeConnection {
+			edges = append(edges, edge)
+		}
+	}
+	return edges, nil
+}
+
+// containerConnections returns the established connections inside a
+// running container's network namespace, or nil if it can't be read
+func (sm *ServiceManager) containerConnections(containerID string) []TCPConnection {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	inspect, err := sm.dockerConfig.Client.ContainerInspect(ctx, containerID)
+	if err != nil || inspect.ContainerJSONBase == nil || inspect.State == nil || inspect.State.Pid == 0 {
+		return nil
+	}
+	connections, err := namespaceConnections(inspect.State.Pid)
+	if err != nil {
+		return nil
+	}
+	return connections
+}
+
+// procSocket is a line of /proc/net/tcp or tcp6
+type procSocket struct {
+	local, remote netip.AddrPort
+	state         string
+	inode         string
+}
+
+// parseProcNetTCP parses /proc/net/tcp or tcp6
+func parseProcNetTCP(r io.Reader) ([]procSocket, error) {
+	var sockets []procSocket
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // Header
+	for scanner.Scan() {
+		// sl local_address rem_address st tx:rx tr:when retrnsmt uid timeout inode
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		local, okLocal := decodeProcAddress(fields[1])
+		remote, okRemote := decodeProcAddress(fields[2])
+		if !okLocal || !okRemote {
+			continue
+		}
+		sockets = append(sockets, procSocket{local: local, remote: remote, state: fields[3], inode: fields[9]})
+	}
+	return sockets, scanner.Err()
+}
+
+// decodeProcAddress decodes a /proc/net/tcp address such as "0100007F:1F90"
+// (127.0.0.1:8080). The address is hex in 32-bit words of host byte order,
+// which is little-endian on every platform Docker runs on.
+func decodeProcAddress(value string) (netip.AddrPort, bool) {
+	hexIP, hexPort, ok := strings.Cut(value, ":")
+	if !ok {
+		return netip.AddrPort{}, false
+	}
+	port, err := strconv.ParseUint(hexPort, 16, 16)
+	if err != nil {
+		return netip.AddrPort{}, false
+	}
+	raw, err := hex.DecodeString(hexIP)
+	if err != nil || len(raw) != 4 && len(raw) != 16 {
+		return netip.AddrPort{}, false
+	}
+	for i := 0; i < len(raw); i += 4 {
+		raw[i], raw[i+1], raw[i+2], raw[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+	addr, _ := netip.AddrFromSlice(raw)
+	return netip.AddrPortFrom(addr.Unmap(), uint16(port)), true
+}
+
+// establishedConnections returns the established sockets of a network
+// namespace, marking those not on one of its listening ports as outbound
+func establishedConnections(sockets []procSocket) []TCPConnection {
+	listening := make(map[uint16]bool)
+	for _, socket := range sockets {
+		if socket.state == tcpListen {
+			listening[socket.local.Port()] = true
+		}
+	}
+
+	var connections []TCPConnection
+	for _, socket := range sockets {
+		if socket.state != tcpEstablished {
+			continue
+		}
+		connections = append(connections, TCPConnection{
+			Local:    socket.local,
+			Remote:   socket.remote,
+			Outbound: !listening[socket.local.Port()],
+			inode:    socket.inode,
+		})
+	}
+	return connections
+}
+
+// parseLsofConnections parses `lsof -nP -iTCP -sTCP:ESTABLISHED -Fpcn`
+// output, where each n line is "local->remote". Connections from a port in
+// listening are inbound.
+func parseLsofConnections(output string, listening map[int]ListeningProcess) []TCPConnection {
+	var connections []TCPConnection
+	var pid, command string
+	for _, line := range strings.Split(output, "\n") {
+		if line == "" {
+			continue
+		}
+		value := line[1:]
+		switch line[0] {
+		case 'p':
+			pid, command = value, ""
+		case 'c':
+			command = value
+		case 'n':
+			localValue, remoteValue, ok := strings.Cut(value, "->")
+			if !ok {
+				continue
+			}
+			local, errLocal := netip.ParseAddrPort(localValue)
+			remote, errRemote := netip.ParseAddrPort(remoteValue)
+			if errLocal != nil || errRemote != nil {
+				continue
+			}
+			_, inbound := listening[int(local.Port())]
+			connections = append(connections, TCPConnection{
+				Local:    netip.AddrPortFrom(local.Addr().Unmap(), local.Port()),
+				Remote:   netip.AddrPortFrom(remote.Addr().Unmap(), remote.Port()),
+				Outbound: !inbound,
+				PID:      pid,
+				Command:  command,
+			})
+		}
+	}
+	return connections
+}
+
+// localAddresses returns the addresses of this machine's interfaces, which
+// reach published ports just like loopback (e.g. the Docker bridge gateway
+// that host.docker.internal resolves to)
+func localAddresses() map[netip.Addr]bool {
+	addresses := make(map[netip.Addr]bool)
+	interfaceAddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return addresses
+	}
+	for _, interfaceAddr := range interfaceAddrs {
+		if ipNet, ok := interfaceAddr.(*net.IPNet); ok {
+			if addr, ok := netip.AddrFromSlice(ipNet.IP); ok {
+				addresses[addr.Unmap()] = true
+			}
+		}
+	}
+	return addresses
+}
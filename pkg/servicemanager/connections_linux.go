@@ -0,0 +1,75 @@
+//go:build linux
+
+package servicemanager
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// SampleConnections returns the established TCP connections of this
+// machine's network namespace with their owning processes, in one pass
+// over /proc
+func SampleConnections() ([]TCPConnection, error) {
+	connections, err := readNamespaceConnections("/proc/net")
+	if err != nil {
+		return nil, err
+	}
+
+	byInode := make(map[string]int, len(connections))
+	for i, connection := range connections {
+		byInode[connection.inode] = i
+	}
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		pid := entry.Name()
+		if _, err := strconv.Atoi(pid); err != nil {
+			continue
+		}
+		command := ""
+		for _, inode := range processSocketInodes(pid) {
+			i, ok := byInode[inode]
+			if !ok || connections[i].PID != "" {
+				continue
+			}
+			if command == "" {
+				command = processComm(pid)
+			}
+			connections[i].PID, connections[i].Command = pid, command
+		}
+	}
+	return connections, nil
+}
+
+// namespaceConnections returns the established TCP connections of the
+// network namespace pid runs in, such as a container's
+func namespaceConnections(pid int) ([]TCPConnection, error) {
+	return readNamespaceConnections(filepath.Join("/proc", strconv.Itoa(pid), "net"))
+}
+
+// readNamespaceConnections reads the tcp and tcp6 tables of a /proc net
+// directory
+func readNamespaceConnections(dir string) ([]TCPConnection, error) {
+	var sockets []procSocket
+	for _, proto := range []string{"tcp", "tcp6"} {
+		file, err := os.Open(filepath.Join(dir, proto))
+		if os.IsNotExist(err) {
+			continue // IPv6 disabled
+		}
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := parseProcNetTCP(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		sockets = append(sockets, parsed...)
+	}
+	return establishedConnections(sockets), nil
+}
@@ -0,0 +1,31 @@
+//go:build !linux
+
+package servicemanager
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// SampleConnections returns the established TCP connections of this
+// machine with their owning processes, from a single lsof call
+func SampleConnections() ([]TCPConnection, error) {
+	listening, err := ResolveListeningPorts()
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := exec.Command("lsof", "-nP", "-iTCP", "-sTCP:ESTABLISHED", "-Fpcn").Output()
+	// lsof exits 1 when nothing matches or some sockets can't be inspected
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, err
+	}
+	return parseLsofConnections(string(output), listening), nil
+}
+
+// namespaceConnections returns nothing: containers run in a VM (Docker
+// Desktop) whose network namespaces aren't visible from here
+func namespaceConnections(pid int) ([]TCPConnection, error) {
+	return nil, nil
+}
@@ -0,0 +1,149 @@
+package servicemanager
+
+import (
+	"net"
+	"net/netip"
+	"os"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/nzions/sharedgolibs/pkg/autoport"
+)
+
+func TestParseProcNetTCP(t *testing.T) {
+	table := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0100007F:1F9A 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 1111 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:D431 0100007F:1F9A 01 00000000:00000000 00:00000000 00000000  1000        0 2222 1 0000000000000000 20 4 30 10 -1
+   2: 0100007F:1F9A 0100007F:D431 01 00000000:00000000 00:00000000 00000000  1000        0 3333 1 0000000000000000 20 4 30 10 -1
+`
+	sockets, err := parseProcNetTCP(strings.NewReader(table))
+	if err != nil {
+		t.Fatal(err)
+	}
+	connections := establishedConnections(sockets)
+
+	want := []TCPConnection{
+		{Local: netip.MustParseAddrPort("127.0.0.1:54321"), Remote: netip.MustParseAddrPort("127.0.0.1:8090"), Outbound: true, inode: "2222"},
+		{Local: netip.MustParseAddrPort("127.0.0.1:8090"), Remote: netip.MustParseAddrPort("127.0.0.1:54321"), inode: "3333"},
+	}
+	if !reflect.DeepEqual(connections, want) {
+		t.Errorf("Expected %+v, got %+v", want, connections)
+	}
+}
+
+func TestDecodeProcAddress(t *testing.T) {
+	tests := map[string]string{
+		"0100007F:1F90":                         "127.0.0.1:8080",
+		"030012AC:0050":                         "172.18.0.3:80",
+		"00000000000000000000000001000000:1F90": "[::1]:8080",
+		"0000000000000000FFFF00000100007F:1F90": "127.0.0.1:8080",
+	}
+	for value, want := range tests {
+		got, ok := decodeProcAddress(value)
+		if !ok || got.String() != want {
+			t.Errorf("decodeProcAddress(%q) = %v, %v, want %s", value, got, ok, want)
+		}
+	}
+	if _, ok := decodeProcAddress("0100007F"); ok {
+		t.Error("Expected an address without a port to be rejected")
+	}
+}
+
+func TestParseLsofConnections(t *testing.T) {
+	output := "p812\ncnode\nf21\nn127.0.0.1:54321->127.0.0.1:8090\nf22\nn[::1]:3000->[::1]:60000\n"
+	listening := map[int]ListeningProcess{3000: {Port: 3000, PID: "812", Command: "node"}}
+	connections := parseLsofConnections(output, listening)
+
+	want := []TCPConnection{
+		{Local: netip.MustParseAddrPort("127.0.0.1:54321"), Remote: netip.MustParseAddrPort("127.0.0.1:8090"), Outbound: true, PID: "812", Command: "node"},
+		{Local: netip.MustParseAddrPort("[::1]:3000"), Remote: netip.MustParseAddrPort("[::1]:60000"), PID: "812", Command: "node"},
+	}
+	if !reflect.DeepEqual(connections, want) {
+		t.Errorf("Expected %+v, got %+v", want, connections)
+	}
+}
+
+func TestTopologyConnections(t *testing.T) {
+	builder := newTopologyBuilder()
+	builder.addDeclared(autoport.ServiceConfig{Name: "api", ExternalPort: 8080})
+	builder.addDeclared(autoport.ServiceConfig{Name: "ca", ExternalPort: 8090})
+	builder.addDeclared(autoport.ServiceConfig{Name: "gcs", ExternalPort: 4443})
+	gcs := builder.addContainer(container.Summary{
+		ID:     "fedcba9876543210",
+		State:  "running",
+		Labels: map[string]string{composeServiceLabel: "gcs"},
+		NetworkSettings: &container.NetworkSettingsSummary{Networks: map[string]*network.EndpointSettings{
+			"stack_default": {IPAddress: "172.18.0.4"},
+		}},
+	}, nil)
+	builder.addConnections(gcs, []TCPConnection{
+		// To the ca through the Docker bridge gateway, and a reply to the api
+		{Local: netip.MustParseAddrPort("172.18.0.4:41000"), Remote: netip.MustParseAddrPort("172.18.0.1:8090"), Outbound: true},
+		{Local: netip.MustParseAddrPort("172.18.0.4:4443"), Remote: netip.MustParseAddrPort("172.18.0.1:50000")},
+	})
+
+	builder.addHostConnections([]TCPConnection{
+		{Local: netip.MustParseAddrPort("127.0.0.1:50000"), Remote: netip.MustParseAddrPort("127.0.0.1:4443"), Outbound: true, PID: "42"},
+		{Local: netip.MustParseAddrPort("10.0.0.5:50001"), Remote: netip.MustParseAddrPort("142.250.72.16:443"), Outbound: true, PID: "42"},
+		{Local: netip.MustParseAddrPort("127.0.0.1:8080"), Remote: netip.MustParseAddrPort("127.0.0.1:50002"), PID: "42"},
+		{Local: netip.MustParseAddrPort("127.0.0.1:50003"), Remote: netip.MustParseAddrPort("127.0.0.1:8080"), Outbound: true, PID: "7"}, // curl
+	}, map[int]ListeningProcess{8080: {Port: 8080, PID: "42"}}, map[netip.Addr]bool{netip.MustParseAddr("172.18.0.1"): true})
+	topology := builder.build()
+
+	want := []TopologyEdge{
+		{From: "api", To: "142.250.72.16:443", Kind: EdgeConnection},
+		{From: "api", To: "gcs", Kind: EdgeConnection, Detail: "127.0.0.1:4443"},
+		{From: "gcs", To: "ca", Kind: EdgeConnection, Detail: "172.18.0.1:8090"},
+	}
+	if !reflect.DeepEqual(topology.Edges, want) {
+		t.Errorf("Expected edges %v, got %v", want, topology.Edges)
+	}
+	if external := topology.Nodes[0]; external.Name != "142.250.72.16:443" || external.Status != "external" {
+		t.Errorf("Expected an external node, got %+v", external)
+	}
+
+	if dot := topology.DOT(); !strings.Contains(dot, `"gcs" -> "ca" [label="172.18.0.1:8090", style=bold];`) {
+		t.Errorf("DOT output missing the connection:\n%s", dot)
+	}
+	if mermaid := topology.Mermaid(); !strings.Contains(mermaid, "==>|172.18.0.1:8090|") {
+		t.Errorf("Mermaid output missing the connection:\n%s", mermaid)
+	}
+}
+
+func TestSampleConnections(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("lsof is not guaranteed on this platform")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	connections, err := SampleConnections()
+	if err != nil {
+		t.Fatalf("SampleConnections failed: %v", err)
+	}
+	local := netip.MustParseAddrPort(conn.LocalAddr().String())
+	for _, connection := range connections {
+		if connection.Local != local {
+			continue
+		}
+		if !connection.Outbound || connection.Remote.String() != listener.Addr().String() || connection.PID != strconv.Itoa(os.Getpid()) {
+			t.Errorf("Unexpected connection: %+v", connection)
+		}
+		return
+	}
+	t.Errorf("Expected the connection from %s in %+v", local, connections)
+}
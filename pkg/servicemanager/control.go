@@ -0,0 +1,179 @@
+package servicemanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/nzions/sharedgolibs/pkg/autoport"
+)
+
+// HealthState is the result of probing a service's health URL
+type HealthState string
+
+const (
+	HealthHealthy   HealthState = "healthy"
+	HealthUnhealthy HealthState = "unhealthy"
+	HealthUnknown   HealthState = "unknown" // No health URL to probe
+)
+
+// DefaultHealthTimeout bounds a single health probe
+const DefaultHealthTimeout = 3 * time.Second
+
+// HealthResult describes one health probe
+type HealthResult struct {
+	State      HealthState   `json:"state"`
+	URL        string        `json:"url,omitempty"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Latency    time.Duration `json:"latency,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// healthClient probes health URLs. Development services commonly use
+// self-signed or dev CA certificates, so verification is skipped.
+var healthClient = &http.Client{
+	Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	},
+	// Report redirects as-is instead of following them to login pages
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// ResolveHealthURL returns the service's health URL with its external port
+// filled in, as autoport.HealthURL composes it. Configured health paths such
+// as "http://localhost/health" omit the port because the service may be
+// published on any port.
+func ResolveHealthURL(service ServiceInfo) string {
+	return autoport.HealthURL(autoport.ServiceConfig{HealthPath: service.HealthURL, ExternalPort: service.ExternalPort})
+}
+
+// healthFallbacks maps health paths to the older path to probe when a
+// service answers 404, e.g. CA servers before v2.22.0 only serve /health
+var healthFallbacks = map[string]string{
+	"/readyz": "/health",
+}
+
+// CheckHealth probes the service's health URL. Any 2xx or 3xx response is
+// healthy; services without a health URL are HealthUnknown.
+func (sm *ServiceManager) CheckHealth(ctx context.Context, service ServiceInfo) HealthResult {
+	healthURL := ResolveHealthURL(service)
+	if healthURL == "" {
+		return HealthResult{State: HealthUnknown}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultHealthTimeout)
+	defer cancel()
+
+	result := probeHealth(ctx, healthURL)
+	if result.StatusCode == http.StatusNotFound {
+		if u, err := url.Parse(healthURL); err == nil && healthFallbacks[u.Path] != "" {
+			u.Path = healthFallbacks[u.Path]
+			result = probeHealth(ctx, u.String())
+		}
+	}
+	return result
+}
+
+// probeHealth requests a health URL once
+func probeHealth(ctx context.Context, healthURL string) HealthResult {
+	result := HealthResult{State: HealthUnhealthy, URL: healthURL}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthURL, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	start := time.Now()
+	resp, err := healthClient.Do(req)
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 200 && resp.StatusCode < 400 {
+		result.State = HealthHealthy
+	} else {
+		result.Error = resp.Status
+	}
+	return result
+}
+
+// RestartService restarts the Docker container publishing a port, or the
+// process Supervise runs on it. Other local processes can't be restarted
+// because their launch environment is unknown.
+func (sm *ServiceManager) RestartService(port int) error {
+	if s := sm.supervisor(port); s != nil {
+		s.Restart()
+		return nil
+	}
+
+	service, err := sm.CheckPort(port)
+	if err != nil {
+		return err
+	}
+	if service.Type != ServiceTypeDockerContainer {
+		return fmt.Errorf("restart is only supported for Docker containers; port %d is a %s service", port, service.Type)
+	}
+	if !sm.IsDockerAvailable() {
+		return fmt.Errorf("docker is not available")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	return sm.dockerConfig.Client.ContainerRestart(ctx, service.ContainerID, container.StopOptions{})
+}
+
+// ServiceLogs returns the last lines of output from the Docker container
+// publishing a port, with stdout and stderr interleaved
+func (sm *ServiceManager) ServiceLogs(port int, lines int) (string, error) {
+	service, err := sm.CheckPort(port)
+	if err != nil {
+		return "", err
+	}
+	if service.Type != ServiceTypeDockerContainer {
+		return "", fmt.Errorf("logs are only available for Docker containers; port %d is a %s service", port, service.Type)
+	}
+	if !sm.IsDockerAvailable() {
+		return "", fmt.Errorf("docker is not available")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	reader, err := sm.dockerConfig.Client.ContainerLogs(ctx, service.ContainerID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Tail:       strconv.Itoa(lines),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read logs for container %s: %w", service.ContainerID, err)
+	}
+	defer reader.Close()
+
+	// Containers without a TTY multiplex stdout and stderr in one stream
+	var output bytes.Buffer
+	if inspect, inspectErr := sm.dockerConfig.Client.ContainerInspect(ctx, service.ContainerID); inspectErr == nil && inspect.Config != nil && inspect.Config.Tty {
+		_, err = io.Copy(&output, reader)
+	} else {
+		_, err = stdcopy.StdCopy(&output, &output, reader)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read logs for container %s: %w", service.ContainerID, err)
+	}
+	return strings.TrimRight(output.String(), "\n"), nil
+}
@@ -0,0 +1,89 @@
+package servicemanager
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestResolveHealthURL(t *testing.T) {
+	cases := []struct {
+		service ServiceInfo
+		want    string
+	}{
+		{ServiceInfo{HealthURL: "http://localhost/health", ExternalPort: 8081}, "http://localhost:8081/health"},
+		{ServiceInfo{HealthURL: "https://localhost:9443/ready", ExternalPort: 8081}, "https://localhost:9443/ready"},
+		{ServiceInfo{HealthURL: "http://localhost", ExternalPort: 8088}, "http://localhost:8088"},
+		{ServiceInfo{ExternalPort: 8088}, ""},
+	}
+	for _, c := range cases {
+		if got := ResolveHealthURL(c.service); got != c.want {
+			t.Errorf("ResolveHealthURL(%q, %d) = %q, want %q", c.service.HealthURL, c.service.ExternalPort, got, c.want)
+		}
+	}
+}
+
+func TestCheckHealth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.WriteHeader(http.StatusOK)
+		case "/login":
+			http.Redirect(w, r, "/sso", http.StatusFound)
+		case "/readyz":
+			http.NotFound(w, r)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(u.Port())
+	sm := NewSimple()
+
+	cases := map[string]HealthState{
+		"http://127.0.0.1/health": HealthHealthy,
+		"http://127.0.0.1/login":  HealthHealthy,
+		"http://127.0.0.1/broken": HealthUnhealthy,
+		"":                        HealthUnknown,
+	}
+	for healthURL, want := range cases {
+		result := sm.CheckHealth(context.Background(), ServiceInfo{HealthURL: healthURL, ExternalPort: port})
+		if result.State != want {
+			t.Errorf("%q: expected %s, got %+v", healthURL, want, result)
+		}
+	}
+
+	// Servers without /readyz, like CA servers before v2.22.0, fall back to /health
+	result := sm.CheckHealth(context.Background(), ServiceInfo{HealthURL: "http://127.0.0.1/readyz", ExternalPort: port})
+	if result.State != HealthHealthy || !strings.HasSuffix(result.URL, "/health") {
+		t.Errorf("Expected the /health fallback to be healthy, got %+v", result)
+	}
+
+	// Nothing listening
+	server.Close()
+	result = sm.CheckHealth(context.Background(), ServiceInfo{HealthURL: "http://127.0.0.1/health", ExternalPort: port})
+	if result.State != HealthUnhealthy || result.Error == "" {
+		t.Errorf("Expected an unhealthy result with an error, got %+v", result)
+	}
+}
+
+func TestRestartAndLogsRequireDocker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	sm := NewSimple()
+	if err := sm.RestartService(port); err == nil {
+		t.Error("Expected restart of a local process to fail")
+	}
+	if _, err := sm.ServiceLogs(port, 10); err == nil {
+		t.Error("Expected logs of a local process to fail")
+	}
+}
@@ -0,0 +1,148 @@
+package servicemanager
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// DockerHostAuto makes WithDockerHostAddress use the host of a remote
+// DOCKER_HOST (tcp://, ssh://, http://, or https://), or localhost when
+// Docker runs on a local socket
+const DockerHostAuto = "auto"
+
+// WithDockerHostAddress checks ports, health URLs, certificates, and
+// identifier probes on host instead of localhost, for Docker engines on a
+// remote dev box or, from WSL2, on the Windows side (see WSLHostAddress).
+// Pass DockerHostAuto to take the host from DOCKER_HOST. Services found
+// on a remote host are never matched to local processes, so they can't be
+// killed by PID; containers are still managed through the Docker API.
+func WithDockerHostAddress(host string) ManagerOption {
+	return func(sm *ServiceManager) {
+		sm.hostAddress = host
+	}
+}
+
+// DockerHostAddress returns the host name or IP of a remote Docker host URL
+// such as tcp://192.168.1.20:2376 or ssh://dev@devbox, or "" for a local
+// socket (unix:// or npipe://)
+func DockerHostAddress(dockerHost string) string {
+	u, err := url.Parse(dockerHost)
+	if err != nil {
+		return ""
+	}
+	switch u.Scheme {
+	case "tcp", "ssh", "http", "https":
+		return u.Hostname()
+	}
+	return ""
+}
+
+// WSLHostAddress returns the address of the Windows host from inside WSL2:
+// the nameserver WSL writes to /etc/resolv.conf. Ports published by a
+// Docker engine running on Windows are reachable there when WSL's localhost
+// forwarding is off or broken.
+func WSLHostAddress() (string, error) {
+	release, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil || !strings.Contains(strings.ToLower(string(release)), "microsoft") {
+		return "", fmt.Errorf("not running under WSL")
+	}
+	resolvConf, err := os.ReadFile("/etc/resolv.conf")
+	if err != nil {
+		return "", fmt.Errorf("reading resolv.conf: %w", err)
+	}
+	if host := resolvConfNameserver(resolvConf); host != "" {
+		return host, nil
+	}
+	return "", fmt.Errorf("no nameserver in /etc/resolv.conf")
+}
+
+// resolvConfNameserver returns the first nameserver in a resolv.conf
+func resolvConfNameserver(data []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// resolveHostAddress turns DockerHostAuto into the Docker host's address,
+// preferring the engine found by initializeDockerClient over DOCKER_HOST
+func (sm *ServiceManager) resolveHostAddress() {
+	if sm.hostAddress != DockerHostAuto {
+		return
+	}
+	dockerHost := os.Getenv("DOCKER_HOST")
+	if sm.dockerConfig != nil && sm.dockerConfig.Available {
+		dockerHost = sm.dockerConfig.SocketPath
+	}
+	sm.hostAddress = DockerHostAddress(dockerHost)
+}
+
+// GetHostAddress returns the host that ports are checked on
+func (sm *ServiceManager) GetHostAddress() string {
+	if sm.hostAddress == "" || sm.hostAddress == DockerHostAuto {
+		return "localhost"
+	}
+	return sm.hostAddress
+}
+
+// isRemoteHost reports whether ports are checked on another machine, where
+// listening ports can't be matched to local processes
+func (sm *ServiceManager) isRemoteHost() bool {
+	host := sm.GetHostAddress()
+	if host == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip == nil || !ip.IsLoopback()
+}
+
+// onHostAddress points a localhost URL, such as a configured health URL, at
+// the host address
+func (sm *ServiceManager) onHostAddress(rawURL string) string {
+	if !sm.isRemoteHost() {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	if ip := net.ParseIP(u.Hostname()); u.Hostname() != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return rawURL
+	}
+	if port := u.Port(); port != "" {
+		u.Host = net.JoinHostPort(sm.GetHostAddress(), port)
+	} else {
+		u.Host = sm.GetHostAddress()
+		if strings.Contains(u.Host, ":") {
+			u.Host = "[" + u.Host + "]"
+		}
+	}
+	return u.String()
+}
+
+// remoteServiceInfo describes a port listening on a remote host that no
+// container publishes
+func (sm *ServiceManager) remoteServiceInfo(port int) ServiceInfo {
+	service := ServiceInfo{
+		Name:         "Unknown Service",
+		Type:         ServiceTypeUnknown,
+		ExternalPort: port,
+		InternalPort: port,
+		IsListening:  true,
+		Status:       "running",
+		Description:  "listening on " + sm.GetHostAddress(),
+	}
+	if config, exists := sm.knownServices[port]; exists {
+		service.Name = config.Name
+	}
+	return service
+}
@@ -0,0 +1,83 @@
+package servicemanager
+
+import (
+	"net"
+	"testing"
+)
+
+func TestDockerHostAddress(t *testing.T) {
+	tests := map[string]string{
+		"tcp://192.168.1.20:2376":        "192.168.1.20",
+		"ssh://dev@devbox":               "devbox",
+		"https://docker.internal:2376":   "docker.internal",
+		"tcp://[fd00::5]:2375":           "fd00::5",
+		"unix:///var/run/docker.sock":    "",
+		"npipe:////./pipe/docker_engine": "",
+		"":                               "",
+	}
+	for dockerHost, want := range tests {
+		if got := DockerHostAddress(dockerHost); got != want {
+			t.Errorf("DockerHostAddress(%q) = %q, want %q", dockerHost, got, want)
+		}
+	}
+}
+
+func TestWithDockerHostAddress(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "tcp://10.0.0.7:2375")
+	sm := NewSimple(WithDockerHostAddress(DockerHostAuto), WithKnownService(8081, "AMT Backend", "http://localhost/health", false))
+	if sm.GetHostAddress() != "10.0.0.7" || !sm.isRemoteHost() {
+		t.Fatalf("Expected the DOCKER_HOST address, got %q", sm.GetHostAddress())
+	}
+
+	// Remote ports aren't attributed to local processes
+	service := sm.getLocalProcessInfo(8081)
+	if service.Type != ServiceTypeUnknown || service.PID != "" || service.Name != "AMT Backend" {
+		t.Errorf("Unexpected remote service %+v", service)
+	}
+	if got := sm.onHostAddress("http://localhost/health"); got != "http://10.0.0.7/health" {
+		t.Errorf("Expected the health URL on the Docker host, got %q", got)
+	}
+	if got := sm.onHostAddress("https://127.0.0.1:8443/"); got != "https://10.0.0.7:8443/" {
+		t.Errorf("Expected the health URL on the Docker host, got %q", got)
+	}
+	if got := sm.onHostAddress("http://metadata.internal/health"); got != "http://metadata.internal/health" {
+		t.Errorf("Expected other hosts unchanged, got %q", got)
+	}
+
+	// A local socket keeps localhost
+	t.Setenv("DOCKER_HOST", "unix:///var/run/docker.sock")
+	sm = NewSimple(WithDockerHostAddress(DockerHostAuto))
+	if sm.GetHostAddress() != "localhost" || sm.isRemoteHost() {
+		t.Errorf("Expected localhost, got %q", sm.GetHostAddress())
+	}
+	if got := sm.onHostAddress("http://localhost/health"); got != "http://localhost/health" {
+		t.Errorf("Expected the health URL unchanged, got %q", got)
+	}
+}
+
+func TestPortCheckOnHostAddress(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	sm := NewSimple(WithDockerHostAddress("127.0.0.1"))
+	if sm.isRemoteHost() {
+		t.Error("Expected a loopback address to be local")
+	}
+	if !sm.isPortListening(port) {
+		t.Errorf("Expected port %d to be listening on 127.0.0.1", port)
+	}
+}
+
+func TestResolvConfNameserver(t *testing.T) {
+	resolvConf := []byte("# This file was automatically generated by WSL.\n[network]\nnameserver 172.22.80.1\nnameserver 8.8.8.8\n")
+	if got := resolvConfNameserver(resolvConf); got != "172.22.80.1" {
+		t.Errorf("Expected the first nameserver, got %q", got)
+	}
+	if got := resolvConfNameserver([]byte("search lan\n")); got != "" {
+		t.Errorf("Expected no nameserver, got %q", got)
+	}
+}
@@ -0,0 +1,85 @@
+package servicemanager
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/nzions/sharedgolibs/pkg/autoport"
+)
+
+// AutoportDrift lists how a docker-compose.yml differs from the autoport
+// configuration generated from it, so CI can catch a stale autoport.go
+type AutoportDrift struct {
+	ComposeFile string          `json:"compose_file"`
+	Added       []string        `json:"added,omitempty"`   // Services in the compose file but not in autoport
+	Removed     []string        `json:"removed,omitempty"` // Services in autoport but no longer in the compose file
+	Changed     []AutoportField `json:"changed,omitempty"`
+}
+
+// AutoportField is a setting of a service that differs between autoport and
+// the compose file
+type AutoportField struct {
+	Service   string `json:"service"`
+	Field     string `json:"field"` // external_port, internal_port, protocol, or image
+	Generated string `json:"generated"`
+	Compose   string `json:"compose"`
+}
+
+// Stale reports whether autoport.go needs regenerating
+func (d *AutoportDrift) Stale() bool {
+	return len(d.Added) > 0 || len(d.Removed) > 0 || len(d.Changed) > 0
+}
+
+// CheckAutoportDrift compares the generated autoport configuration with the
+// services, ports, and images of the compose file at composePath. Settings
+// autoport derives rather than reads, such as health paths, are not compared.
+func (sm *ServiceManager) CheckAutoportDrift(composePath string) (*AutoportDrift, error) {
+	configs, _, err := parseComposeFile(composePath)
+	if err != nil {
+		return nil, err
+	}
+	drift := autoportDrift(autoport.GetConfiguration().Services, configs)
+	drift.ComposeFile = composePath
+	return drift, nil
+}
+
+// autoportDrift diffs generated services against those of a compose file,
+// sorted by service name
+func autoportDrift(generated map[string]autoport.ServiceConfig, compose map[string]AutoPortConfig) *AutoportDrift {
+	drift := &AutoportDrift{}
+	for name := range compose {
+		if _, exists := generated[name]; !exists {
+			drift.Added = append(drift.Added, name)
+		}
+	}
+	sort.Strings(drift.Added)
+
+	for _, name := range sortedNames(generated) {
+		expected := generated[name]
+		actual, exists := compose[name]
+		if !exists {
+			drift.Removed = append(drift.Removed, name)
+			continue
+		}
+		changed := func(field, generated, compose string) {
+			if generated != compose {
+				drift.Changed = append(drift.Changed, AutoportField{Service: name, Field: field, Generated: generated, Compose: compose})
+			}
+		}
+		changed("external_port", strconv.Itoa(expected.ExternalPort), strconv.Itoa(actual.ExternalPort))
+		changed("internal_port", strconv.Itoa(expected.InternalPort), strconv.Itoa(actual.InternalPort))
+		changed("protocol", expected.Protocol, actual.Protocol)
+		changed("image", expected.Image, actual.Image)
+	}
+	return drift
+}
+
+// sortedNames returns the names of services in order
+func sortedNames(services map[string]autoport.ServiceConfig) []string {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,60 @@
+package servicemanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nzions/sharedgolibs/pkg/autoport"
+)
+
+func TestCheckAutoportDrift(t *testing.T) {
+	// A compose file matching autoport has no drift
+	var compose strings.Builder
+	compose.WriteString("services:\n")
+	for _, name := range sortedNames(autoport.GetConfiguration().Services) {
+		service, _ := autoport.GetServiceByName(name)
+		fmt.Fprintf(&compose, "  %s:\n    image: %s\n    ports: [\"%d:%d/%s\"]\n", name, service.Image, service.ExternalPort, service.InternalPort, service.Protocol)
+	}
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	if err := os.WriteFile(path, []byte(compose.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sm := New()
+	drift, err := sm.CheckAutoportDrift(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if drift.Stale() {
+		t.Fatalf("Expected no drift, got %+v", drift)
+	}
+
+	ca, _ := autoport.GetServiceByName("ca")
+	content := compose.String()
+	content = strings.Replace(content, fmt.Sprintf("\"%d:", ca.ExternalPort), "\"9999:", 1)
+	content = strings.Replace(content, "image: "+ca.Image, "image: ca:v2", 1)
+	content = strings.Replace(content, "  metadata:\n", "  metadata-old:\n", 1)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	drift, err = sm.CheckAutoportDrift(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !drift.Stale() || strings.Join(drift.Added, ",") != "metadata-old" || strings.Join(drift.Removed, ",") != "metadata" {
+		t.Errorf("Unexpected drift %+v", drift)
+	}
+	want := []AutoportField{
+		{Service: "ca", Field: "external_port", Generated: fmt.Sprint(ca.ExternalPort), Compose: "9999"},
+		{Service: "ca", Field: "image", Generated: ca.Image, Compose: "ca:v2"},
+	}
+	if fmt.Sprint(drift.Changed) != fmt.Sprint(want) {
+		t.Errorf("Changed = %+v, want %+v", drift.Changed, want)
+	}
+
+	if _, err := sm.CheckAutoportDrift(filepath.Join(t.TempDir(), "missing.yml")); err == nil {
+		t.Error("Expected an error for a missing compose file")
+	}
+}
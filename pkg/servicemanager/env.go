@@ -0,0 +1,137 @@
+package servicemanager
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultEnvAllowlist selects the environment variables captured from
+// Docker containers: the shared library settings and anything that looks
+// like an address. Secrets such as *_TOKEN or *_PASSWORD are not matched.
+var DefaultEnvAllowlist = []string{"SGL_*", "*_URL", "*_HOST", "*_PORT", "*_ADDR", "*_ENDPOINT"}
+
+// EnvDifference is one allowlisted variable that differs between two services.
+// InA and InB tell an unset variable from one set to an empty string.
+type EnvDifference struct {
+	Name string `json:"name"`
+	A    string `json:"a,omitempty"`
+	B    string `json:"b,omitempty"`
+	InA  bool   `json:"in_a"`
+	InB  bool   `json:"in_b"`
+}
+
+// EnvDiff compares the captured environment of two services
+type EnvDiff struct {
+	ServiceA    string          `json:"service_a"`
+	PortA       int             `json:"port_a"`
+	ServiceB    string          `json:"service_b"`
+	PortB       int             `json:"port_b"`
+	Differences []EnvDifference `json:"differences"`
+	Same        int             `json:"same_count"` // Variables set to the same value in both
+}
+
+// WithEnvAllowlist replaces DefaultEnvAllowlist with shell-style patterns
+// (path.Match syntax) for the container environment variables to capture.
+// No patterns disables capture.
+func WithEnvAllowlist(patterns ...string) ManagerOption {
+	return func(sm *ServiceManager) {
+		sm.envAllowlist = patterns
+	}
+}
+
+// DiffEnv compares the allowlisted environment of the Docker containers
+// publishing two ports, e.g. to find the one service with a stale SGL_CA.
+// Differences are sorted by variable name.
+func (sm *ServiceManager) DiffEnv(portA, portB int) (*EnvDiff, error) {
+	serviceA, err := sm.CheckPort(portA)
+	if err != nil {
+		return nil, err
+	}
+	serviceB, err := sm.CheckPort(portB)
+	if err != nil {
+		return nil, err
+	}
+	for _, service := range []*ServiceInfo{serviceA, serviceB} {
+		if service.Type != ServiceTypeDockerContainer {
+			return nil, fmt.Errorf("environment is only available for Docker containers; port %d is a %s service", service.ExternalPort, service.Type)
+		}
+	}
+
+	diff := diffEnv(serviceA.Env, serviceB.Env)
+	diff.ServiceA, diff.PortA = serviceA.Name, portA
+	diff.ServiceB, diff.PortB = serviceB.Name, portB
+	return diff, nil
+}
+
+// containerEnv returns a container's allowlisted environment, or nil if
+// nothing matches or the container can't be inspected
+func (sm *ServiceManager) containerEnv(containerID string) map[string]string {
+	if len(sm.envAllowlist) == 0 || !sm.IsDockerAvailable() {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	inspect, err := sm.dockerConfig.Client.ContainerInspect(ctx, containerID)
+	if err != nil || inspect.Config == nil {
+		return nil
+	}
+	return filterEnv(inspect.Config.Env, sm.envAllowlist)
+}
+
+// filterEnv turns KEY=value pairs into a map of the variables matching any pattern
+func filterEnv(env []string, patterns []string) map[string]string {
+	var filtered map[string]string
+	for _, pair := range env {
+		name, value, _ := strings.Cut(pair, "=")
+		if !envAllowed(name, patterns) {
+			continue
+		}
+		if filtered == nil {
+			filtered = make(map[string]string)
+		}
+		filtered[name] = value
+	}
+	return filtered
+}
+
+// envAllowed reports whether a variable name matches one of the patterns
+func envAllowed(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// diffEnv compares two environments variable by variable
+func diffEnv(a, b map[string]string) *EnvDiff {
+	names := make(map[string]bool, len(a)+len(b))
+	for name := range a {
+		names[name] = true
+	}
+	for name := range b {
+		names[name] = true
+	}
+
+	diff := &EnvDiff{Differences: []EnvDifference{}}
+	for name := range names {
+		valueA, inA := a[name]
+		valueB, inB := b[name]
+		if inA && inB && valueA == valueB {
+			diff.Same++
+			continue
+		}
+		diff.Differences = append(diff.Differences, EnvDifference{Name: name, A: valueA, B: valueB, InA: inA, InB: inB})
+	}
+	sort.Slice(diff.Differences, func(i, j int) bool {
+		return diff.Differences[i].Name < diff.Differences[j].Name
+	})
+	return diff
+}
@@ -0,0 +1,68 @@
+package servicemanager
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterEnv(t *testing.T) {
+	env := []string{
+		"PATH=/usr/bin",
+		"SGL_CA=http://ca:8090",
+		"DATABASE_URL=postgres://db:5432/app",
+		"API_TOKEN=secret",
+		"EMPTY_HOST=",
+		"NOVALUE",
+	}
+	want := map[string]string{
+		"SGL_CA":       "http://ca:8090",
+		"DATABASE_URL": "postgres://db:5432/app",
+		"EMPTY_HOST":   "",
+	}
+	if got := filterEnv(env, DefaultEnvAllowlist); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	if got := filterEnv(env, nil); got != nil {
+		t.Errorf("Expected no variables without patterns, got %v", got)
+	}
+	if got := filterEnv(env, []string{"API_*"}); !reflect.DeepEqual(got, map[string]string{"API_TOKEN": "secret"}) {
+		t.Errorf("Expected a custom allowlist to select API_TOKEN, got %v", got)
+	}
+}
+
+func TestWithEnvAllowlist(t *testing.T) {
+	if sm := NewSimple(); !reflect.DeepEqual(sm.envAllowlist, DefaultEnvAllowlist) {
+		t.Errorf("Expected the default allowlist, got %v", sm.envAllowlist)
+	}
+	if sm := NewSimple(WithEnvAllowlist()); len(sm.envAllowlist) != 0 || sm.containerEnv("abc") != nil {
+		t.Errorf("Expected an empty allowlist to disable capture, got %v", sm.envAllowlist)
+	}
+}
+
+func TestDiffEnv(t *testing.T) {
+	a := map[string]string{"SGL_CA": "http://ca:8090", "SGL_PORT": "8080", "API_HOST": "api", "EMPTY_URL": ""}
+	b := map[string]string{"SGL_CA": "http://localhost:8090", "SGL_PORT": "8080", "DB_HOST": "db"}
+
+	diff := diffEnv(a, b)
+	want := []EnvDifference{
+		{Name: "API_HOST", A: "api", InA: true},
+		{Name: "DB_HOST", B: "db", InB: true},
+		{Name: "EMPTY_URL", InA: true},
+		{Name: "SGL_CA", A: "http://ca:8090", B: "http://localhost:8090", InA: true, InB: true},
+	}
+	if !reflect.DeepEqual(diff.Differences, want) || diff.Same != 1 {
+		t.Errorf("Unexpected diff: %+v", diff)
+	}
+
+	if diff := diffEnv(a, a); len(diff.Differences) != 0 || diff.Same != len(a) {
+		t.Errorf("Expected identical environments to match, got %+v", diff)
+	}
+}
+
+func TestDiffEnvRequiresDocker(t *testing.T) {
+	sm := NewSimple()
+	if _, err := sm.DiffEnv(1, 2); err == nil {
+		t.Error("Expected an error for ports without Docker containers")
+	}
+}
@@ -0,0 +1,269 @@
+package servicemanager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nzions/sharedgolibs/internal/fileutil"
+)
+
+// Crash loop detection: a service restarting CrashLoopRestarts times within
+// CrashLoopWindow is considered to be crash looping
+const (
+	CrashLoopRestarts = 3
+	CrashLoopWindow   = 10 * time.Minute
+)
+
+// historyFileVersion is the on-disk format version of the history store
+const historyFileVersion = 1
+
+// ServiceHistory is what has been observed on a port across discovery runs
+type ServiceHistory struct {
+	Port         int         `json:"port"`
+	Name         string      `json:"name"`
+	Type         ServiceType `json:"type"`
+	Identity     string      `json:"identity,omitempty"` // Container ID or PID last seen on the port
+	Up           bool        `json:"up"`                 // Listening at the last observation of the port
+	FirstSeen    time.Time   `json:"first_seen"`
+	LastSeen     time.Time   `json:"last_seen"`
+	Observations int         `json:"observations"`
+	Restarts     int         `json:"restarts"`    // Identity changes and down-to-up transitions
+	CrashLoops   int         `json:"crash_loops"` // Number of crash loops detected
+	CrashLooping bool        `json:"crash_looping"`
+
+	// RecentRestarts holds restart times within CrashLoopWindow
+	RecentRestarts []time.Time `json:"recent_restarts,omitempty"`
+}
+
+// observe records that the service was seen listening at now
+func (h *ServiceHistory) observe(service ServiceInfo, now time.Time) {
+	identity := service.ContainerID
+	if identity == "" {
+		identity = service.PID
+	}
+
+	if h.Observations == 0 {
+		h.FirstSeen = now
+	} else if !h.Up || (identity != "" && h.Identity != "" && identity != h.Identity) {
+		h.restarted(now)
+	} else {
+		h.pruneRestarts(now)
+	}
+
+	h.Name = service.Name
+	h.Type = service.Type
+	h.Identity = identity
+	h.Up = true
+	h.LastSeen = now
+	h.Observations++
+}
+
+// observeDown records that nothing was listening on the port at now
+func (h *ServiceHistory) observeDown(now time.Time) {
+	h.Up = false
+	h.pruneRestarts(now)
+}
+
+// restarted counts a restart and updates crash loop detection
+func (h *ServiceHistory) restarted(now time.Time) {
+	h.Restarts++
+	h.RecentRestarts = append(h.RecentRestarts, now)
+	h.pruneRestarts(now)
+
+	if len(h.RecentRestarts) >= CrashLoopRestarts && !h.CrashLooping {
+		h.CrashLooping = true
+		h.CrashLoops++
+	}
+}
+
+// pruneRestarts drops restarts outside the crash loop window and clears the
+// crash looping flag once the service has settled
+func (h *ServiceHistory) pruneRestarts(now time.Time) {
+	recent := h.RecentRestarts[:0]
+	for _, t := range h.RecentRestarts {
+		if now.Sub(t) <= CrashLoopWindow {
+			recent = append(recent, t)
+		}
+	}
+	h.RecentRestarts = recent
+
+	if len(h.RecentRestarts) < CrashLoopRestarts {
+		h.CrashLooping = false
+	}
+}
+
+// historyFile is the JSON document persisted by historyStore
+type historyFile struct {
+	Version  int                        `json:"version"`
+	Services map[string]*ServiceHistory `json:"services"` // Keyed by port
+}
+
+// historyStore persists service history to a local JSON file. Every run of
+// the CLI records history, so record holds a lock file next to it that other
+// processes share.
+type historyStore struct {
+	path    string
+	mutex   sync.Mutex
+	lastErr error // Last failure to record, see HistoryError
+}
+
+// DefaultHistoryPath returns the history file in the user's cache directory
+func DefaultHistoryPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "sharedgolibs", "servicemanager", "history.json")
+}
+
+// WithHistoryFile records the history of discovered services in path
+func WithHistoryFile(path string) ManagerOption {
+	return func(sm *ServiceManager) {
+		sm.history = &historyStore{path: path}
+	}
+}
+
+// load reads the history file; a missing file is an empty history
+func (s *historyStore) load() (*historyFile, error) {
+	file := &historyFile{Version: historyFileVersion, Services: make(map[string]*ServiceHistory)}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return file, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("failed to parse history file %s: %w", s.path, err)
+	}
+	if file.Services == nil {
+		file.Services = make(map[string]*ServiceHistory)
+	}
+	return file, nil
+}
+
+// save writes the history file atomically
+func (s *historyStore) save(file *historyFile) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode history: %w", err)
+	}
+
+	if err := fileutil.WriteAtomic(s.path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	return nil
+}
+
+// record updates the history with the services found by scanning ports. Ports
+// in the scanned range with history but no service are recorded as down.
+func (s *historyStore) record(services []ServiceInfo, scanned PortRange, now time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lastErr = s.update(services, scanned, now)
+	return s.lastErr
+}
+
+// update applies one scan to the history file under its lock
+func (s *historyStore) update(services []ServiceInfo, scanned PortRange, now time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	unlock, err := fileutil.LockPath(s.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	file, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[int]bool, len(services))
+	for _, service := range services {
+		key := strconv.Itoa(service.ExternalPort)
+		h, exists := file.Services[key]
+		if !exists {
+			h = &ServiceHistory{Port: service.ExternalPort}
+			file.Services[key] = h
+		}
+		h.observe(service, now)
+		seen[service.ExternalPort] = true
+	}
+
+	for _, h := range file.Services {
+		if !seen[h.Port] && h.Port >= scanned.Start && h.Port <= scanned.End {
+			h.observeDown(now)
+		}
+	}
+
+	return s.save(file)
+}
+
+// HistoryError returns the error of the last attempt to record history, or
+// nil if it succeeded. Discovery does not fail when history cannot be
+// recorded, so callers check here to report it.
+func (sm *ServiceManager) HistoryError() error {
+	if sm.history == nil {
+		return nil
+	}
+	sm.history.mutex.Lock()
+	defer sm.history.mutex.Unlock()
+	return sm.history.lastErr
+}
+
+// GetServiceHistory returns the observation history of the service on port.
+// History is only recorded when the manager was created WithHistoryFile.
+func (sm *ServiceManager) GetServiceHistory(port int) (*ServiceHistory, error) {
+	if sm.history == nil {
+		return nil, fmt.Errorf("service history is not enabled")
+	}
+
+	sm.history.mutex.Lock()
+	defer sm.history.mutex.Unlock()
+
+	file, err := sm.history.load()
+	if err != nil {
+		return nil, err
+	}
+
+	h, exists := file.Services[strconv.Itoa(port)]
+	if !exists {
+		return nil, fmt.Errorf("no history for port %d", port)
+	}
+	return h, nil
+}
+
+// GetAllServiceHistory returns the observation history of every port a
+// service has been seen on, sorted by port
+func (sm *ServiceManager) GetAllServiceHistory() ([]ServiceHistory, error) {
+	if sm.history == nil {
+		return nil, fmt.Errorf("service history is not enabled")
+	}
+
+	sm.history.mutex.Lock()
+	defer sm.history.mutex.Unlock()
+
+	file, err := sm.history.load()
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]ServiceHistory, 0, len(file.Services))
+	for _, h := range file.Services {
+		history = append(history, *h)
+	}
+	sort.Slice(history, func(i, j int) bool { return history[i].Port < history[j].Port })
+	return history, nil
+}
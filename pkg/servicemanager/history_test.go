@@ -0,0 +1,136 @@
+package servicemanager
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestServiceHistoryRestartsAndCrashLoops(t *testing.T) {
+	sm := NewSimple(WithHistoryFile(filepath.Join(t.TempDir(), "history.json")))
+	scanned := PortRange{Start: 8000, End: 9000}
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	api := ServiceInfo{Name: "api", Type: ServiceTypeDockerContainer, ExternalPort: 8080, ContainerID: "aaa"}
+	observe := func(minutes int, services ...ServiceInfo) {
+		t.Helper()
+		if err := sm.history.record(services, scanned, start.Add(time.Duration(minutes)*time.Minute)); err != nil {
+			t.Fatalf("record failed: %v", err)
+		}
+	}
+
+	observe(0, api)
+	observe(1, api)
+
+	h, err := sm.GetServiceHistory(8080)
+	if err != nil {
+		t.Fatalf("GetServiceHistory failed: %v", err)
+	}
+	if h.Observations != 2 || h.Restarts != 0 || !h.FirstSeen.Equal(start) {
+		t.Errorf("Unexpected history for stable service: %+v", h)
+	}
+
+	// Down then up again, and a new container on the same port
+	observe(2)
+	observe(3, api)
+	api.ContainerID = "bbb"
+	observe(4, api)
+	if h, _ = sm.GetServiceHistory(8080); h.Restarts != 2 || h.CrashLooping {
+		t.Errorf("Expected 2 restarts without crash loop, got %+v", h)
+	}
+
+	api.ContainerID = "ccc"
+	observe(5, api)
+	if h, _ = sm.GetServiceHistory(8080); !h.CrashLooping || h.CrashLoops != 1 {
+		t.Errorf("Expected crash loop after %d restarts in window, got %+v", CrashLoopRestarts, h)
+	}
+
+	// Settling down clears the flag but keeps the count
+	observe(30, api)
+	if h, _ = sm.GetServiceHistory(8080); h.CrashLooping || h.CrashLoops != 1 || h.Restarts != 3 {
+		t.Errorf("Expected crash loop to clear once settled, got %+v", h)
+	}
+
+	// Ports outside the scanned range are left alone
+	other := ServiceInfo{Name: "other", Type: ServiceTypeLocalProcess, ExternalPort: 3000, PID: "42"}
+	if err := sm.history.record([]ServiceInfo{other}, PortRange{Start: 3000, End: 3000}, start.Add(31*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if h, _ = sm.GetServiceHistory(8080); !h.Up {
+		t.Error("Expected port outside scanned range to keep its state")
+	}
+
+	all, err := sm.GetAllServiceHistory()
+	if err != nil {
+		t.Fatalf("GetAllServiceHistory failed: %v", err)
+	}
+	if len(all) != 2 || all[0].Port != 3000 || all[1].Port != 8080 {
+		t.Errorf("Expected history for ports 3000 and 8080, got %+v", all)
+	}
+
+	if _, err := sm.GetServiceHistory(9999); err == nil {
+		t.Error("Expected error for port without history")
+	}
+}
+
+func TestServiceHistoryDisabled(t *testing.T) {
+	sm := NewSimple()
+	if _, err := sm.GetServiceHistory(8080); err == nil {
+		t.Error("Expected error when history is not enabled")
+	}
+}
+
+func TestServiceHistoryConcurrentStores(t *testing.T) {
+	// Separate stores on one file stand in for concurrent CLI runs
+	path := filepath.Join(t.TempDir(), "history.json")
+	scanned := PortRange{Start: 8000, End: 9000}
+	api := ServiceInfo{Name: "api", Type: ServiceTypeDockerContainer, ExternalPort: 8080, ContainerID: "aaa"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store := &historyStore{path: path}
+			for j := 0; j < 10; j++ {
+				if err := store.record([]ServiceInfo{api}, scanned, time.Now()); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	h, err := NewSimple(WithHistoryFile(path)).GetServiceHistory(8080)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Observations != 40 {
+		t.Errorf("Expected 40 observations, got %d", h.Observations)
+	}
+}
+
+func TestServiceHistoryError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	if err := os.WriteFile(path, []byte("not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sm := NewSimple(WithHistoryFile(path))
+	if err := sm.HistoryError(); err != nil {
+		t.Fatalf("Expected no error before recording, got %v", err)
+	}
+	sm.history.record(nil, PortRange{Start: 8000, End: 8000}, time.Now())
+	if err := sm.HistoryError(); err == nil {
+		t.Error("Expected the unreadable history file to be reported")
+	}
+
+	os.Remove(path)
+	sm.history.record(nil, PortRange{Start: 8000, End: 8000}, time.Now())
+	if err := sm.HistoryError(); err != nil {
+		t.Errorf("Expected the error to clear once recording works, got %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package servicemanager
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultProbeTimeout bounds each network probe an identifier makes
+const DefaultProbeTimeout = time.Second
+
+// maxProbeBody caps the bytes of an HTTP response body kept by a probe
+const maxProbeBody = 64 << 10
+
+// Identifier teaches the manager to recognize services the built-in
+// heuristics don't know, such as an organization's internal services. It is
+// called with each discovered service after the built-in identification and
+// returns the service with whatever it recognized filled in, or unchanged.
+// Identifiers run in the order they were registered, each seeing the result
+// of the previous one.
+type Identifier func(service ServiceInfo, probe *ProbeTools) ServiceInfo
+
+// WithIdentifier registers identifiers for discovered services
+func WithIdentifier(identifiers ...Identifier) ManagerOption {
+	return func(sm *ServiceManager) {
+		sm.identifiers = append(sm.identifiers, identifiers...)
+	}
+}
+
+// ProbeTools are the network probes available to identifiers. Each probe
+// connects to the service's port on localhost (or the host set with
+// WithDockerHostAddress) with DefaultProbeTimeout, and results are shared
+// by the identifiers looking at the same service, so several identifiers
+// grabbing the banner connect once.
+type ProbeTools struct {
+	host      string
+	port      int
+	timeout   time.Duration
+	banner    *probeResult[string]
+	responses map[string]*probeResult[*ProbeResponse]
+}
+
+// probeResult is a cached probe outcome
+type probeResult[T any] struct {
+	value T
+	err   error
+}
+
+// ProbeResponse is the part of an HTTP response kept by a probe
+type ProbeResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       string // At most the first 64KiB
+}
+
+// newProbeTools creates the probes for a service listening on host:port
+func newProbeTools(host string, port int) *ProbeTools {
+	return &ProbeTools{
+		host:      host,
+		port:      port,
+		timeout:   DefaultProbeTimeout,
+		responses: make(map[string]*probeResult[*ProbeResponse]),
+	}
+}
+
+// Port returns the port the probes connect to
+func (p *ProbeTools) Port() int {
+	return p.port
+}
+
+// address returns the dial address of the probed port
+func (p *ProbeTools) address() string {
+	return net.JoinHostPort(p.host, strconv.Itoa(p.port))
+}
+
+// Banner returns the first line the service sends after accepting a
+// connection, as SSH, SMTP, FTP, and many in-house protocols do. Services
+// that wait for the client to speak first time out with an error.
+func (p *ProbeTools) Banner() (string, error) {
+	if p.banner == nil {
+		banner, err := p.readBanner()
+		p.banner = &probeResult[string]{banner, err}
+	}
+	return p.banner.value, p.banner.err
+}
+
+// readBanner connects and reads one line
+func (p *ProbeTools) readBanner() (string, error) {
+	conn, err := net.DialTimeout("tcp", p.address(), p.timeout)
+	if err != nil {
+		return "", fmt.Errorf("banner probe: %w", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(p.timeout))
+
+	line, err := bufio.NewReader(io.LimitReader(conn, maxProbeBody)).ReadString('\n')
+	if line == "" && err != nil {
+		return "", fmt.Errorf("banner probe: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// HTTPGet requests path from the service over HTTPS, without verifying the
+// certificate, or over plain HTTP when the service doesn't speak TLS, and
+// returns the response whatever its status code. Redirects aren't followed.
+func (p *ProbeTools) HTTPGet(path string) (*ProbeResponse, error) {
+	if result, ok := p.responses[path]; ok {
+		return result.value, result.err
+	}
+	response, err := p.httpGet(path)
+	p.responses[path] = &probeResult[*ProbeResponse]{response, err}
+	return response, err
+}
+
+// httpGet performs an uncached HTTPGet. TLS is tried first because TLS
+// servers answer plain HTTP with an error page rather than failing.
+func (p *ProbeTools) httpGet(path string) (*ProbeResponse, error) {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	client := &http.Client{
+		Timeout: p.timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // Identify, don't verify
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse // Redirects tell identifiers something too
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Get("https://" + p.address() + path)
+	if err != nil {
+		resp, err = client.Get("http://" + p.address() + path)
+		if err != nil {
+			return nil, fmt.Errorf("HTTP probe: %w", err)
+		}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxProbeBody))
+	if err != nil {
+		return nil, fmt.Errorf("HTTP probe: %w", err)
+	}
+	return &ProbeResponse{StatusCode: resp.StatusCode, Header: resp.Header, Body: string(body)}, nil
+}
+
+// identify runs the registered identifiers on a service
+func (sm *ServiceManager) identify(service ServiceInfo) ServiceInfo {
+	if len(sm.identifiers) == 0 || !service.IsListening {
+		return service
+	}
+	probe := newProbeTools(sm.GetHostAddress(), service.ExternalPort)
+	for _, identifier := range sm.identifiers {
+		service = identifier(service, probe)
+	}
+	return service
+}
+
+// BannerIdentifier names unexpected services whose banner starts with prefix
+func BannerIdentifier(prefix, name string) Identifier {
+	return func(service ServiceInfo, probe *ProbeTools) ServiceInfo {
+		if service.IsExpected {
+			return service
+		}
+		if banner, err := probe.Banner(); err == nil && strings.HasPrefix(banner, prefix) {
+			return identifiedAs(service, name, fmt.Sprintf("banner %q", banner))
+		}
+		return service
+	}
+}
+
+// HeaderIdentifier names unexpected services whose HTTP response to / has
+// header set to a value containing value, case-insensitively, e.g.
+// HeaderIdentifier("Server", "billing-api", "Billing API")
+func HeaderIdentifier(header, value, name string) Identifier {
+	return func(service ServiceInfo, probe *ProbeTools) ServiceInfo {
+		if service.IsExpected {
+			return service
+		}
+		resp, err := probe.HTTPGet("/")
+		if err != nil {
+			return service
+		}
+		for _, got := range resp.Header.Values(header) {
+			if strings.Contains(strings.ToLower(got), strings.ToLower(value)) {
+				return identifiedAs(service, name, fmt.Sprintf("%s: %s", http.CanonicalHeaderKey(header), got))
+			}
+		}
+		return service
+	}
+}
+
+// EndpointIdentifier names unexpected services that answer path with a 2xx
+// status and a body containing contains (any body when empty), e.g. a
+// well-known /.well-known/service-info endpoint. The endpoint becomes the
+// health URL when the service has none.
+func EndpointIdentifier(path, contains, name string) Identifier {
+	return func(service ServiceInfo, probe *ProbeTools) ServiceInfo {
+		if service.IsExpected {
+			return service
+		}
+		resp, err := probe.HTTPGet(path)
+		if err != nil || resp.StatusCode < 200 || resp.StatusCode > 299 || !strings.Contains(resp.Body, contains) {
+			return service
+		}
+		service = identifiedAs(service, name, "endpoint "+path)
+		if service.HealthURL == "" {
+			service.HealthURL = path
+		}
+		return service
+	}
+}
+
+// identifiedAs names a service recognized by an identifier, noting how in
+// the description unless it already has one
+func identifiedAs(service ServiceInfo, name, how string) ServiceInfo {
+	service.Name = name
+	if service.Description == "" {
+		service.Description = "identified by " + how
+	}
+	return service
+}
@@ -0,0 +1,123 @@
+package servicemanager
+
+import (
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listenerPort returns the port of a listener on localhost
+func listenerPort(t *testing.T, addr net.Addr) int {
+	t.Helper()
+	return addr.(*net.TCPAddr).Port
+}
+
+func TestIdentifiers(t *testing.T) {
+	// A line protocol that greets clients
+	banner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer banner.Close()
+	go func() {
+		for {
+			conn, err := banner.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("ACME-QUEUE 2.1 ready\r\n"))
+			conn.Close()
+		}
+	}()
+
+	// An HTTP service with a telltale header and a well-known endpoint
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "Billing-API/3")
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/.well-known/service-info", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"service":"ledger"}`))
+	})
+	web := httptest.NewServer(mux)
+	defer web.Close()
+	webPort := listenerPort(t, web.Listener.Addr())
+
+	// TLS services are probed over HTTPS transparently
+	secure := httptest.NewUnstartedServer(mux)
+	secure.Config.ErrorLog = log.New(io.Discard, "", 0) // Banner probes fail handshakes
+	secure.StartTLS()
+	defer secure.Close()
+
+	sm := NewSimple(WithIdentifier(
+		BannerIdentifier("ACME-QUEUE", "Acme Queue"),
+		HeaderIdentifier("server", "billing-api", "Billing API"),
+		EndpointIdentifier("/.well-known/service-info", `"ledger"`, "Ledger"),
+		func(service ServiceInfo, probe *ProbeTools) ServiceInfo {
+			// Custom identifiers see the earlier identifiers' results
+			if service.Name == "Ledger" && probe.Port() == webPort {
+				service.Description += " (billing team)"
+			}
+			return service
+		},
+	))
+
+	tests := []struct {
+		name       string
+		port       int
+		expected   bool
+		wantName   string
+		wantDesc   string
+		wantHealth string
+	}{
+		{"banner", listenerPort(t, banner.Addr()), false, "Acme Queue", `identified by banner "ACME-QUEUE 2.1 ready"`, ""},
+		{"endpoint wins over header", webPort, false, "Ledger", "identified by Server: Billing-API/3 (billing team)", "/.well-known/service-info"},
+		{"https", listenerPort(t, secure.Listener.Addr()), false, "Ledger", "identified by Server: Billing-API/3", "/.well-known/service-info"},
+		{"expected services are left alone", listenerPort(t, banner.Addr()), true, "queue", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := ServiceInfo{Name: "queue", ExternalPort: tt.port, IsListening: true, IsExpected: tt.expected}
+			start := time.Now()
+			got := sm.identify(service)
+			if got.Name != tt.wantName || got.Description != tt.wantDesc || got.HealthURL != tt.wantHealth {
+				t.Errorf("Got name %q, description %q, health %q; want %q, %q, %q",
+					got.Name, got.Description, got.HealthURL, tt.wantName, tt.wantDesc, tt.wantHealth)
+			}
+			if elapsed := time.Since(start); elapsed > 5*DefaultProbeTimeout {
+				t.Errorf("Identification took %s", elapsed)
+			}
+		})
+	}
+
+	// Services that aren't listening are never probed
+	stopped := ServiceInfo{Name: "Unknown Service", ExternalPort: webPort}
+	if got := sm.identify(stopped); got.Name != "Unknown Service" {
+		t.Errorf("Expected a stopped service to be left alone, got %q", got.Name)
+	}
+}
+
+func TestProbeToolsCache(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Redirect(w, r, "/elsewhere", http.StatusFound)
+	}))
+	defer server.Close()
+
+	probe := newProbeTools("localhost", listenerPort(t, server.Listener.Addr()))
+	for i := 0; i < 2; i++ {
+		resp, err := probe.HTTPGet("status")
+		if err != nil || resp.StatusCode != http.StatusFound || !strings.HasSuffix(resp.Header.Get("Location"), "/elsewhere") {
+			t.Fatalf("Expected the redirect itself, got %+v, %v", resp, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Expected one request for repeated probes, got %d", requests)
+	}
+}
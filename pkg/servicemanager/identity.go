@@ -0,0 +1,220 @@
+package servicemanager
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Docker Compose labels used to identify the project a container belongs to
+const (
+	composeProjectLabel    = "com.docker.compose.project"
+	composeServiceLabel    = "com.docker.compose.service"
+	composeWorkingDirLabel = "com.docker.compose.project.working_dir"
+)
+
+// setContainerLabels records a container's labels and the compose project it belongs to
+func setContainerLabels(service *ServiceInfo, labels map[string]string) {
+	if len(labels) == 0 {
+		return
+	}
+	service.Labels = labels
+	service.ComposeProject = labels[composeProjectLabel]
+	service.ComposeService = labels[composeServiceLabel]
+	service.WorkingDir = labels[composeWorkingDirLabel]
+}
+
+// processDetails is what ps and lsof report about a process
+type processDetails struct {
+	User        string
+	ParentPID   string
+	CommandLine string
+	WorkingDir  string
+}
+
+// getProcessDetails looks up the owner, parent, command line, and working
+// directory of a process. Fields that cannot be determined are left empty.
+func getProcessDetails(pid string) processDetails {
+	var details processDetails
+	if pid == "" {
+		return details
+	}
+
+	output, err := exec.Command("ps", "-o", "user=,ppid=,args=", "-p", pid).Output()
+	if err == nil {
+		details = parsePSOutput(string(output))
+	}
+	details.WorkingDir = processWorkingDir(pid)
+	return details
+}
+
+// parsePSOutput parses a line of `ps -o user=,ppid=,args=`
+func parsePSOutput(output string) processDetails {
+	fields := strings.Fields(output)
+	if len(fields) < 3 {
+		return processDetails{}
+	}
+	return processDetails{
+		User:        fields[0],
+		ParentPID:   fields[1],
+		CommandLine: strings.Join(fields[2:], " "),
+	}
+}
+
+// processWorkingDir returns the current directory of a process, from /proc
+// where available and lsof otherwise
+func processWorkingDir(pid string) string {
+	if dir, err := os.Readlink(filepath.Join("/proc", pid, "cwd")); err == nil {
+		return dir
+	}
+
+	output, err := exec.Command("lsof", "-a", "-p", pid, "-d", "cwd", "-Fn").Output()
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "n") {
+			return strings.TrimPrefix(line, "n")
+		}
+	}
+	return ""
+}
+
+// findGitRoot returns the closest directory at or above dir containing .git,
+// which is a directory for a clone and a file for a linked worktree
+func findGitRoot(dir string) string {
+	for dir != "" {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return ""
+}
+
+// identifyOwner fills in who started an unexpected service and guesses what it is
+func (sm *ServiceManager) identifyOwner(service ServiceInfo) ServiceInfo {
+	if service.Type == ServiceTypeLocalProcess && service.PID != "" {
+		details := getProcessDetails(service.PID)
+		service.User = details.User
+		service.ParentPID = details.ParentPID
+		service.CommandLine = details.CommandLine
+		service.WorkingDir = details.WorkingDir
+
+		if service.ParentPID != "" {
+			parent := getProcessDetails(service.ParentPID)
+			service.ParentCommand = parent.CommandLine
+			// go run execs the binary from a temporary directory; its own
+			// directory is where it was started
+			if service.WorkingDir == "" || isGoRunParent(parent.CommandLine) {
+				if parent.WorkingDir != "" {
+					service.WorkingDir = parent.WorkingDir
+				}
+			}
+		}
+	}
+
+	cwd, _ := os.Getwd()
+	service.ProbableIdentity = probableIdentity(service, cwd)
+	return service
+}
+
+// isGoRunParent reports whether a command line is `go run`
+func isGoRunParent(commandLine string) bool {
+	fields := strings.Fields(commandLine)
+	return len(fields) >= 2 && filepath.Base(fields[0]) == "go" && fields[1] == "run"
+}
+
+// probableIdentity guesses what an unexpected service is from its process or
+// container details, relative to the checkout in cwd
+func probableIdentity(service ServiceInfo, cwd string) string {
+	location := describeLocation(service.WorkingDir, cwd)
+
+	if service.Type == ServiceTypeDockerContainer {
+		if service.ComposeProject == "" {
+			if service.Image != "" {
+				return fmt.Sprintf("standalone container from image %s", service.Image)
+			}
+			return "standalone container"
+		}
+
+		identity := fmt.Sprintf("compose service %q of project %q", service.ComposeService, service.ComposeProject)
+		if location != "" {
+			identity = fmt.Sprintf("%s %s", identity, location)
+		}
+		if inOtherCheckout(service.WorkingDir, cwd) {
+			identity = "stale " + identity
+		}
+		return identity
+	}
+
+	if service.Type != ServiceTypeLocalProcess || service.PID == "" {
+		return ""
+	}
+
+	var identity string
+	switch {
+	case isGoRunParent(service.ParentCommand) || strings.Contains(service.CommandLine, "/go-build"):
+		identity = "`go run`"
+	case strings.HasSuffix(executable(service.CommandLine), ".test"):
+		identity = "`go test` binary"
+	case service.Command != "":
+		identity = fmt.Sprintf("%s process", service.Command)
+	default:
+		identity = "process"
+	}
+
+	if location != "" {
+		identity = fmt.Sprintf("%s %s", identity, location)
+	}
+	if inOtherCheckout(service.WorkingDir, cwd) {
+		identity = "stale " + identity
+	}
+	if service.ParentPID == "1" {
+		identity += " (orphaned, its parent has exited)"
+	}
+	if service.User != "" {
+		identity = fmt.Sprintf("%s, owned by %s", identity, service.User)
+	}
+	return identity
+}
+
+// executable returns the program of a command line
+func executable(commandLine string) string {
+	if fields := strings.Fields(commandLine); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+// inOtherCheckout reports whether dir is inside a git checkout other than the one containing cwd
+func inOtherCheckout(dir, cwd string) bool {
+	if dir == "" {
+		return false
+	}
+	root := findGitRoot(dir)
+	return root != "" && root != findGitRoot(cwd)
+}
+
+// describeLocation says where dir is relative to the checkout in cwd
+func describeLocation(dir, cwd string) string {
+	if dir == "" {
+		return ""
+	}
+
+	root, currentRoot := findGitRoot(dir), findGitRoot(cwd)
+	switch {
+	case root == "":
+		return fmt.Sprintf("from %s", dir)
+	case root == currentRoot:
+		return "from this checkout"
+	default:
+		return fmt.Sprintf("from another worktree (%s)", root)
+	}
+}
@@ -0,0 +1,122 @@
+package servicemanager
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParsePSOutput(t *testing.T) {
+	details := parsePSOutput("  alice   4242 /tmp/go-build123/b001/exe/server -port 8080\n")
+	if details.User != "alice" || details.ParentPID != "4242" || details.CommandLine != "/tmp/go-build123/b001/exe/server -port 8080" {
+		t.Errorf("Unexpected details: %+v", details)
+	}
+
+	if details := parsePSOutput(""); details != (processDetails{}) {
+		t.Errorf("Expected empty details for empty output, got %+v", details)
+	}
+}
+
+func TestGetProcessDetails(t *testing.T) {
+	if _, err := exec.LookPath("ps"); err != nil {
+		t.Skip("ps not available")
+	}
+
+	details := getProcessDetails(strconv.Itoa(os.Getpid()))
+	if details.User == "" || details.ParentPID != strconv.Itoa(os.Getppid()) {
+		t.Errorf("Unexpected details for own process: %+v", details)
+	}
+
+	cwd, _ := os.Getwd()
+	if details.WorkingDir != "" && details.WorkingDir != cwd {
+		t.Errorf("Expected working directory %s, got %s", cwd, details.WorkingDir)
+	}
+}
+
+func TestProbableIdentity(t *testing.T) {
+	// Two checkouts: the current one and another worktree
+	base := t.TempDir()
+	current := filepath.Join(base, "main")
+	other := filepath.Join(base, "feature")
+	os.MkdirAll(filepath.Join(current, ".git"), 0o755)
+	os.MkdirAll(filepath.Join(other, "cmd", "api"), 0o755)
+	os.WriteFile(filepath.Join(other, ".git"), []byte("gitdir: ../main/.git/worktrees/feature\n"), 0o644)
+
+	tests := []struct {
+		name     string
+		service  ServiceInfo
+		contains []string
+		excludes []string
+	}{
+		{
+			name: "go run from another worktree",
+			service: ServiceInfo{
+				Type: ServiceTypeLocalProcess, PID: "100", Command: "server", User: "alice",
+				ParentPID: "99", ParentCommand: "go run ./cmd/api", WorkingDir: filepath.Join(other, "cmd", "api"),
+				CommandLine: "/tmp/go-build1/b001/exe/api",
+			},
+			contains: []string{"stale `go run` from another worktree (" + other + ")", "owned by alice"},
+		},
+		{
+			name: "orphaned go test binary in this checkout",
+			service: ServiceInfo{
+				Type: ServiceTypeLocalProcess, PID: "100", Command: "pkg.test",
+				ParentPID: "1", CommandLine: "./pkg.test -test.run TestX", WorkingDir: current,
+			},
+			contains: []string{"`go test` binary from this checkout (orphaned"},
+			excludes: []string{"stale"},
+		},
+		{
+			name: "plain process",
+			service: ServiceInfo{
+				Type: ServiceTypeLocalProcess, PID: "100", Command: "node",
+				ParentPID: "1", CommandLine: "node server.js", WorkingDir: current,
+			},
+			contains: []string{"node process from this checkout", "orphaned"},
+			excludes: []string{"stale"},
+		},
+		{
+			name: "compose container from another worktree",
+			service: ServiceInfo{
+				Type: ServiceTypeDockerContainer, ComposeProject: "feature", ComposeService: "db", WorkingDir: other,
+			},
+			contains: []string{`stale compose service "db" of project "feature" from another worktree`},
+		},
+		{
+			name:     "standalone container",
+			service:  ServiceInfo{Type: ServiceTypeDockerContainer, Image: "redis:7"},
+			contains: []string{"standalone container from image redis:7"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity := probableIdentity(tt.service, current)
+			for _, want := range tt.contains {
+				if !strings.Contains(identity, want) {
+					t.Errorf("Expected %q in identity %q", want, identity)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(identity, unwanted) {
+					t.Errorf("Did not expect %q in identity %q", unwanted, identity)
+				}
+			}
+		})
+	}
+}
+
+func TestSetContainerLabels(t *testing.T) {
+	var service ServiceInfo
+	setContainerLabels(&service, map[string]string{
+		composeProjectLabel:    "dev",
+		composeServiceLabel:    "api",
+		composeWorkingDirLabel: "/src/dev",
+	})
+	if service.ComposeProject != "dev" || service.ComposeService != "api" || service.WorkingDir != "/src/dev" || len(service.Labels) != 3 {
+		t.Errorf("Unexpected service after labels: %+v", service)
+	}
+}
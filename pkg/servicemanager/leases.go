@@ -0,0 +1,244 @@
+package servicemanager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nzions/sharedgolibs/internal/fileutil"
+)
+
+// DefaultLeaseRange is where ReservePorts looks for free ports: above the
+// service ports autoport assigns and below the Linux ephemeral port range
+var DefaultLeaseRange = PortRange{Start: 20000, End: 29999}
+
+// ErrNoFreePorts is returned when ReservePorts can't find enough ports that
+// are neither leased nor listening in the lease range
+var ErrNoFreePorts = errors.New("not enough free ports")
+
+// leaseFileVersion is the on-disk format version of the lease store
+const leaseFileVersion = 1
+
+// PortLease is a set of ports reserved for one owner, such as a test
+// package, until released. Other ServiceManagers sharing the lease file, in
+// this process or another, don't hand the ports out in the meantime.
+type PortLease struct {
+	Owner    string    `json:"owner"`
+	PID      int       `json:"pid"` // Process holding the lease
+	Ports    []int     `json:"ports"`
+	Acquired time.Time `json:"acquired"`
+
+	store *leaseStore
+}
+
+// ID identifies the lease in the lease file
+func (l *PortLease) ID() string {
+	return strconv.Itoa(l.PID) + ":" + strconv.Itoa(l.Ports[0])
+}
+
+// Release returns the ports to the pool. Releasing twice is harmless.
+func (l *PortLease) Release() error {
+	if l.store == nil {
+		return nil
+	}
+	err := l.store.release(l.ID())
+	l.store = nil
+	return err
+}
+
+// leaseFile is the JSON document persisted by leaseStore
+type leaseFile struct {
+	Version int                   `json:"version"`
+	Leases  map[string]*PortLease `json:"leases"` // Keyed by PortLease.ID
+}
+
+// leaseStore hands out ports from a range, recording leases in a local JSON
+// file locked while it is updated
+type leaseStore struct {
+	path  string
+	ports PortRange
+	mutex sync.Mutex
+}
+
+// DefaultLeasePath returns the port lease file in the user's cache directory
+func DefaultLeasePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "sharedgolibs", "servicemanager", "leases.json")
+}
+
+// WithLeaseFile records port leases in path instead of DefaultLeasePath.
+// Processes reserving ports from the same range must share the file.
+func WithLeaseFile(path string) ManagerOption {
+	return func(sm *ServiceManager) {
+		sm.leases.path = path
+	}
+}
+
+// WithLeaseRange reserves ports between start and end instead of
+// DefaultLeaseRange
+func WithLeaseRange(start, end int) ManagerOption {
+	return func(sm *ServiceManager) {
+		sm.leases.ports = PortRange{Start: start, End: end}
+	}
+}
+
+// newLeaseStore returns the default lease store
+func newLeaseStore() *leaseStore {
+	return &leaseStore{path: DefaultLeasePath(), ports: DefaultLeaseRange}
+}
+
+// ReservePorts leases count ports in the lease range that are free: not
+// leased to anyone else and not listening. Call Release on the lease once
+// the ports are no longer needed; leases of processes that have exited are
+// reclaimed on the next reservation.
+func (sm *ServiceManager) ReservePorts(owner string, count int) (*PortLease, error) {
+	if count < 1 {
+		return nil, fmt.Errorf("invalid port count %d", count)
+	}
+	return sm.leases.reserve(owner, count, time.Now())
+}
+
+// GetPortLeases returns the leases currently held, by first port
+func (sm *ServiceManager) GetPortLeases() ([]PortLease, error) {
+	var leases []PortLease
+	err := sm.leases.update(func(file *leaseFile) bool {
+		for _, lease := range file.Leases {
+			leases = append(leases, *lease)
+		}
+		return false
+	})
+	sort.Slice(leases, func(i, j int) bool { return leases[i].Ports[0] < leases[j].Ports[0] })
+	return leases, err
+}
+
+// reserve picks count free ports and records them as leased
+func (s *leaseStore) reserve(owner string, count int, now time.Time) (*PortLease, error) {
+	var lease *PortLease
+	err := s.update(func(file *leaseFile) bool {
+		leased := make(map[int]bool)
+		for _, l := range file.Leases {
+			for _, port := range l.Ports {
+				leased[port] = true
+			}
+		}
+
+		var ports []int
+		for port := s.ports.Start; port <= s.ports.End && len(ports) < count; port++ {
+			if !leased[port] && portFree(port) {
+				ports = append(ports, port)
+			}
+		}
+		if len(ports) < count {
+			return false
+		}
+
+		lease = &PortLease{Owner: owner, PID: os.Getpid(), Ports: ports, Acquired: now, store: s}
+		file.Leases[lease.ID()] = lease
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if lease == nil {
+		return nil, fmt.Errorf("%w: %d needed in %d-%d", ErrNoFreePorts, count, s.ports.Start, s.ports.End)
+	}
+	return lease, nil
+}
+
+// release drops the lease with the given ID
+func (s *leaseStore) release(id string) error {
+	return s.update(func(file *leaseFile) bool {
+		if _, exists := file.Leases[id]; !exists {
+			return false
+		}
+		delete(file.Leases, id)
+		return true
+	})
+}
+
+// update runs change on the lease file under its lock, with the leases of
+// exited processes dropped, and saves the file when change reports a
+// change (or leases were dropped)
+func (s *leaseStore) update(change func(file *leaseFile) bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create lease directory: %w", err)
+	}
+	unlock, err := fileutil.LockPath(s.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	file, err := s.load()
+	if err != nil {
+		return err
+	}
+	changed := false
+	for id, lease := range file.Leases {
+		if !processAlive(lease.PID) {
+			delete(file.Leases, id)
+			changed = true
+		}
+	}
+	if change(file) || changed {
+		return s.save(file)
+	}
+	return nil
+}
+
+// load reads the lease file; a missing file has no leases
+func (s *leaseStore) load() (*leaseFile, error) {
+	file := &leaseFile{Version: leaseFileVersion, Leases: make(map[string]*PortLease)}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return file, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lease file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("failed to parse lease file %s: %w", s.path, err)
+	}
+	if file.Leases == nil {
+		file.Leases = make(map[string]*PortLease)
+	}
+	return file, nil
+}
+
+// save writes the lease file atomically
+func (s *leaseStore) save(file *leaseFile) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode leases: %w", err)
+	}
+
+	if err := fileutil.WriteAtomic(s.path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write lease file: %w", err)
+	}
+	return nil
+}
+
+// portFree reports whether nothing listens on port, on any address
+func portFree(port int) bool {
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return false
+	}
+	listener.Close()
+	return true
+}
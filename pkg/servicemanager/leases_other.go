@@ -0,0 +1,15 @@
+//go:build !unix
+
+package servicemanager
+
+import "os"
+
+// processAlive reports whether the process with the given PID still exists
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
@@ -0,0 +1,86 @@
+package servicemanager
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func TestReservePorts(t *testing.T) {
+	// Hold one port of the range so it is skipped
+	busy, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	start := busy.Addr().(*net.TCPAddr).Port
+	leaseFile := filepath.Join(t.TempDir(), "leases.json")
+	sm := NewSimple(WithLeaseFile(leaseFile), WithLeaseRange(start, start+20))
+
+	first, err := sm.ReservePorts("pkg/a", 3)
+	if err != nil {
+		t.Fatalf("ReservePorts failed: %v", err)
+	}
+	if len(first.Ports) != 3 || first.Ports[0] == start {
+		t.Errorf("Expected 3 ports other than the busy %d, got %v", start, first.Ports)
+	}
+
+	// Another manager sharing the lease file gets different ports
+	other := NewSimple(WithLeaseFile(leaseFile), WithLeaseRange(start, start+20))
+	second, err := other.ReservePorts("pkg/b", 2)
+	if err != nil {
+		t.Fatalf("ReservePorts failed: %v", err)
+	}
+	for _, port := range second.Ports {
+		for _, taken := range first.Ports {
+			if port == taken {
+				t.Errorf("Port %d leased twice", port)
+			}
+		}
+	}
+
+	leases, err := sm.GetPortLeases()
+	if err != nil || len(leases) != 2 || leases[0].Owner != "pkg/a" || leases[1].Owner != "pkg/b" {
+		t.Errorf("Unexpected leases %+v, %v", leases, err)
+	}
+
+	if err := first.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if err := first.Release(); err != nil {
+		t.Errorf("Expected a second release to be harmless, got %v", err)
+	}
+	third, err := other.ReservePorts("pkg/c", 3)
+	if err != nil {
+		t.Fatalf("ReservePorts failed: %v", err)
+	}
+	if third.Ports[0] != first.Ports[0] {
+		t.Errorf("Expected released port %d to be reused, got %v", first.Ports[0], third.Ports)
+	}
+
+	if _, err := sm.ReservePorts("pkg/d", 50); !errors.Is(err, ErrNoFreePorts) {
+		t.Errorf("Expected ErrNoFreePorts, got %v", err)
+	}
+	if _, err := sm.ReservePorts("pkg/d", 0); err == nil {
+		t.Error("Expected an error for zero ports")
+	}
+}
+
+func TestReservePortsReclaimsExitedProcesses(t *testing.T) {
+	store := &leaseStore{path: filepath.Join(t.TempDir(), "leases.json"), ports: PortRange{Start: 40000, End: 40100}}
+	err := store.update(func(file *leaseFile) bool {
+		// PIDs are far below this limit on every supported platform
+		lease := &PortLease{Owner: "gone", PID: 1 << 30, Ports: []int{40000}}
+		file.Leases[lease.ID()] = lease
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm := NewSimple(WithLeaseFile(store.path))
+	leases, err := sm.GetPortLeases()
+	if err != nil || len(leases) != 0 {
+		t.Errorf("Expected the lease of an exited process to be reclaimed, got %+v, %v", leases, err)
+	}
+}
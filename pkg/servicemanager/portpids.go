@@ -0,0 +1,102 @@
+package servicemanager
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ListeningProcess is the process that owns a listening TCP port. PID and
+// Command are empty when the owner is not visible, e.g. another user's
+// process without root.
+type ListeningProcess struct {
+	Port    int    `json:"port"`
+	PID     string `json:"pid,omitempty"`
+	Command string `json:"command,omitempty"`
+}
+
+// portCacheTTL is how long one ResolveListeningPorts scan answers per-port
+// lookups; discovery passes start with a fresh scan
+const portCacheTTL = 5 * time.Second
+
+// portCache shares one batch port resolution between the per-port lookups
+// of a scan, instead of running a subprocess for every port
+type portCache struct {
+	resolve func() (map[int]ListeningProcess, error) // Default ResolveListeningPorts
+
+	mu       sync.Mutex
+	resolved time.Time
+	ports    map[int]ListeningProcess
+	err      error
+}
+
+// lookup returns the owner of a listening port, resolving all ports if the
+// cached scan is missing or stale
+func (c *portCache) lookup(port int) (ListeningProcess, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.resolved.IsZero() || time.Since(c.resolved) > portCacheTTL {
+		resolve := c.resolve
+		if resolve == nil {
+			resolve = ResolveListeningPorts
+		}
+		c.ports, c.err = resolve()
+		c.resolved = time.Now()
+	}
+	if c.err != nil {
+		return ListeningProcess{}, false, c.err
+	}
+	owner, ok := c.ports[port]
+	return owner, ok, nil
+}
+
+// invalidate forces the next lookup to rescan
+func (c *portCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resolved = time.Time{}
+}
+
+// parseLsofListeners parses `lsof -nP -iTCP -sTCP:LISTEN -Fpcn` output:
+// a p<pid> line starts each process, followed by c<command> and one
+// n<address:port> line per listening socket. The lowest PID wins when
+// several processes share a socket.
+func parseLsofListeners(output string) map[int]ListeningProcess {
+	ports := make(map[int]ListeningProcess)
+	var pid, command string
+	for _, line := range strings.Split(output, "\n") {
+		if line == "" {
+			continue
+		}
+		value := line[1:]
+		switch line[0] {
+		case 'p':
+			pid, command = value, ""
+		case 'c':
+			command = value
+		case 'n':
+			i := strings.LastIndex(value, ":")
+			if i < 0 {
+				continue
+			}
+			port, err := strconv.Atoi(value[i+1:])
+			if err != nil {
+				continue
+			}
+			if existing, ok := ports[port]; ok && lowerPID(existing.PID, pid) {
+				continue
+			}
+			ports[port] = ListeningProcess{Port: port, PID: pid, Command: command}
+		}
+	}
+	return ports
+}
+
+// lowerPID reports whether PID a is numerically lower than b
+func lowerPID(a, b string) bool {
+	x, errA := strconv.Atoi(a)
+	y, errB := strconv.Atoi(b)
+	return errA == nil && errB == nil && x < y
+}
@@ -0,0 +1,105 @@
+//go:build linux
+
+package servicemanager
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ResolveListeningPorts maps every listening TCP port to its owning process
+// in one pass over /proc, without running a subprocess
+func ResolveListeningPorts() (map[int]ListeningProcess, error) {
+	sockets, err := listeningSocketPorts()
+	if err != nil {
+		return nil, err
+	}
+
+	ports := make(map[int]ListeningProcess, len(sockets))
+	for _, port := range sockets {
+		ports[port] = ListeningProcess{Port: port}
+	}
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	// ReadDir sorts by name, not number; track the lowest PID per port
+	for _, entry := range entries {
+		pid := entry.Name()
+		if _, err := strconv.Atoi(pid); err != nil {
+			continue
+		}
+		for _, inode := range processSocketInodes(pid) {
+			port, ok := sockets[inode]
+			if !ok {
+				continue
+			}
+			if owner := ports[port]; owner.PID != "" && !lowerPID(pid, owner.PID) {
+				continue
+			}
+			ports[port] = ListeningProcess{Port: port, PID: pid, Command: processComm(pid)}
+		}
+	}
+	return ports, nil
+}
+
+// listeningSocketPorts reads the listening TCP sockets of the network
+// namespace from /proc/net/tcp and tcp6, as inode to port
+func listeningSocketPorts() (map[string]int, error) {
+	sockets := make(map[string]int)
+	for _, proto := range []string{"tcp", "tcp6"} {
+		file, err := os.Open(filepath.Join("/proc/net", proto))
+		if os.IsNotExist(err) {
+			continue // IPv6 disabled
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		procSockets, err := parseProcNetTCP(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, socket := range procSockets {
+			if socket.state == tcpListen {
+				sockets[socket.inode] = int(socket.local.Port())
+			}
+		}
+	}
+	return sockets, nil
+}
+
+// processSocketInodes returns the inodes of the sockets pid has open; empty
+// for other users' processes unless running as root
+func processSocketInodes(pid string) []string {
+	dir := filepath.Join("/proc", pid, "fd")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var inodes []string
+	for _, entry := range entries {
+		target, err := os.Readlink(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if inode, ok := strings.CutPrefix(target, "socket:["); ok {
+			inodes = append(inodes, strings.TrimSuffix(inode, "]"))
+		}
+	}
+	return inodes
+}
+
+// processComm returns the command name of pid
+func processComm(pid string) string {
+	data, err := os.ReadFile(filepath.Join("/proc", pid, "comm"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package servicemanager
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// ResolveListeningPorts maps every listening TCP port to its owning process
+// with a single lsof call (there is no /proc to read)
+func ResolveListeningPorts() (map[int]ListeningProcess, error) {
+	output, err := exec.Command("lsof", "-nP", "-iTCP", "-sTCP:LISTEN", "-Fpcn").Output()
+	// lsof exits 1 when nothing matches or some sockets can't be inspected
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, err
+	}
+	return parseLsofListeners(string(output)), nil
+}
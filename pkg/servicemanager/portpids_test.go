@@ -0,0 +1,80 @@
+package servicemanager
+
+import (
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestParseLsofListeners(t *testing.T) {
+	output := "p812\ncnode\nf21\nn*:3000\nf22\nn[::1]:3001\np95\ncpostgres\nf7\nn127.0.0.1:5432\np900\ncnode\nf3\nn*:3000\n"
+	ports := parseLsofListeners(output)
+
+	want := map[int]ListeningProcess{
+		3000: {Port: 3000, PID: "812", Command: "node"},
+		3001: {Port: 3001, PID: "812", Command: "node"},
+		5432: {Port: 5432, PID: "95", Command: "postgres"},
+	}
+	if len(ports) != len(want) {
+		t.Fatalf("Expected %d ports, got %v", len(want), ports)
+	}
+	for port, owner := range want {
+		if ports[port] != owner {
+			t.Errorf("Port %d: expected %+v, got %+v", port, owner, ports[port])
+		}
+	}
+}
+
+func TestResolveListeningPorts(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("lsof is not guaranteed on this platform")
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	ports, err := ResolveListeningPorts()
+	if err != nil {
+		t.Fatalf("ResolveListeningPorts failed: %v", err)
+	}
+	owner, ok := ports[port]
+	if !ok {
+		t.Fatalf("Expected port %d to be listed", port)
+	}
+	if owner.PID != strconv.Itoa(os.Getpid()) || owner.Command == "" {
+		t.Errorf("Expected this process to own port %d, got %+v", port, owner)
+	}
+}
+
+func TestPortCache(t *testing.T) {
+	scans := 0
+	sm := NewSimple(WithPortRange(8080, 8082))
+	sm.ports.resolve = func() (map[int]ListeningProcess, error) {
+		scans++
+		return map[int]ListeningProcess{8080: {Port: 8080, PID: "42", Command: "api"}}, nil
+	}
+	for port := 8080; port <= 8082; port++ {
+		service := sm.getLocalProcessInfo(port)
+		if listening := port == 8080; service.IsListening != listening {
+			t.Errorf("Port %d: expected listening=%v, got %+v", port, listening, service)
+		}
+	}
+	if service := sm.getLocalProcessInfo(8080); service.PID != "42" || service.Command != "api" {
+		t.Errorf("Expected the cached owner, got %+v", service)
+	}
+	if scans != 1 {
+		t.Errorf("Expected one scan for the whole range, got %d", scans)
+	}
+
+	sm.ports.invalidate()
+	sm.getLocalProcessInfo(8080)
+	if scans != 2 {
+		t.Errorf("Expected a rescan after invalidate, got %d scans", scans)
+	}
+}
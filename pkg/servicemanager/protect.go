@@ -0,0 +1,145 @@
+package servicemanager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrProtectedPort is returned, wrapped, when a kill targets a protected port
+// without force
+var ErrProtectedPort = errors.New("port is protected")
+
+// ProtectedPort is a port whose service must not be killed by accident, such
+// as SSH or a database holding data that isn't easily recreated
+type ProtectedPort struct {
+	Port   int    `yaml:"port" json:"port"`
+	Reason string `yaml:"reason,omitempty" json:"reason,omitempty"`
+}
+
+// protectedPortsFile is the YAML layout of a protected ports file
+type protectedPortsFile struct {
+	Ports []ProtectedPort `yaml:"ports"`
+}
+
+// DefaultProtectedPorts are protected unless WithoutDefaultProtectedPorts
+// is used
+var DefaultProtectedPorts = []ProtectedPort{
+	{Port: 22, Reason: "SSH"},
+}
+
+// DefaultProtectedPortsPath returns the protected ports file in the user's
+// config directory
+func DefaultProtectedPortsPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "sharedgolibs", "servicemanager", "protected-ports.yaml")
+}
+
+// LoadProtectedPorts reads a protected ports file:
+//
+//	ports:
+//	  - port: 5432
+//	    reason: postgres with local data
+//
+// A missing file protects nothing.
+func LoadProtectedPorts(path string) ([]ProtectedPort, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read protected ports: %w", err)
+	}
+
+	var file protectedPortsFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse protected ports: %w", err)
+	}
+	for _, protected := range file.Ports {
+		if protected.Port <= 0 || protected.Port > 65535 {
+			return nil, fmt.Errorf("invalid protected port %d in %s", protected.Port, path)
+		}
+	}
+	return file.Ports, nil
+}
+
+// WithProtectedPort refuses kills of the service on port unless forced (see
+// ForceKillServiceOnPort); KillAllServices skips it
+func WithProtectedPort(port int, reason string) ManagerOption {
+	return func(sm *ServiceManager) {
+		sm.protectPort(port, reason)
+	}
+}
+
+// WithProtectedPorts protects each of ports, e.g. from LoadProtectedPorts
+func WithProtectedPorts(ports ...ProtectedPort) ManagerOption {
+	return func(sm *ServiceManager) {
+		for _, protected := range ports {
+			sm.protectPort(protected.Port, protected.Reason)
+		}
+	}
+}
+
+// WithoutDefaultProtectedPorts drops DefaultProtectedPorts, keeping ports
+// protected by other options
+func WithoutDefaultProtectedPorts() ManagerOption {
+	return func(sm *ServiceManager) {
+		for _, protected := range DefaultProtectedPorts {
+			if sm.protectedPorts[protected.Port] == protected.Reason {
+				delete(sm.protectedPorts, protected.Port)
+			}
+		}
+	}
+}
+
+// defaultProtectedPorts returns DefaultProtectedPorts as a map for New
+func defaultProtectedPorts() map[int]string {
+	ports := make(map[int]string, len(DefaultProtectedPorts))
+	for _, protected := range DefaultProtectedPorts {
+		ports[protected.Port] = protected.Reason
+	}
+	return ports
+}
+
+// protectPort adds a protected port
+func (sm *ServiceManager) protectPort(port int, reason string) {
+	if reason == "" {
+		reason = "protected"
+	}
+	sm.protectedPorts[port] = reason
+}
+
+// IsProtectedPort reports whether port is protected, and why
+func (sm *ServiceManager) IsProtectedPort(port int) (reason string, protected bool) {
+	reason, protected = sm.protectedPorts[port]
+	return reason, protected
+}
+
+// GetProtectedPorts returns the protected ports, sorted by port
+func (sm *ServiceManager) GetProtectedPorts() []ProtectedPort {
+	ports := make([]ProtectedPort, 0, len(sm.protectedPorts))
+	for port, reason := range sm.protectedPorts {
+		ports = append(ports, ProtectedPort{Port: port, Reason: reason})
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].Port < ports[j].Port })
+	return ports
+}
+
+// checkProtected returns an error wrapping ErrProtectedPort for a protected port
+func (sm *ServiceManager) checkProtected(port int) error {
+	if reason, protected := sm.IsProtectedPort(port); protected {
+		return fmt.Errorf("%w: %d (%s); force the kill to stop it anyway", ErrProtectedPort, port, reason)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package servicemanager
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadProtectedPorts(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "protected-ports.yaml")
+	if err := os.WriteFile(path, []byte("ports:\n  - port: 5432\n    reason: postgres with local data\n  - port: 6379\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ports, err := LoadProtectedPorts(path)
+	if err != nil {
+		t.Fatalf("LoadProtectedPorts failed: %v", err)
+	}
+	want := []ProtectedPort{{Port: 5432, Reason: "postgres with local data"}, {Port: 6379}}
+	if !reflect.DeepEqual(ports, want) {
+		t.Errorf("LoadProtectedPorts() = %v, want %v", ports, want)
+	}
+
+	if ports, err := LoadProtectedPorts(filepath.Join(dir, "missing.yaml")); err != nil || ports != nil {
+		t.Errorf("Expected nothing for a missing file, got %v, %v", ports, err)
+	}
+
+	for name, content := range map[string]string{
+		"bad port":      "ports:\n  - port: 70000\n",
+		"unknown field": "ports:\n  - prot: 22\n",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadProtectedPorts(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestProtectedPorts(t *testing.T) {
+	sm := NewSimple(WithProtectedPort(5432, "postgres"), WithProtectedPorts(ProtectedPort{Port: 6379}))
+	want := []ProtectedPort{{Port: 22, Reason: "SSH"}, {Port: 5432, Reason: "postgres"}, {Port: 6379, Reason: "protected"}}
+	if got := sm.GetProtectedPorts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetProtectedPorts() = %v, want %v", got, want)
+	}
+
+	if err := sm.KillServiceOnPort(22); !errors.Is(err, ErrProtectedPort) {
+		t.Errorf("Expected ErrProtectedPort for SSH, got %v", err)
+	}
+
+	sm = NewSimple(WithoutDefaultProtectedPorts(), WithProtectedPort(5432, "postgres"))
+	if _, protected := sm.IsProtectedPort(22); protected {
+		t.Error("Expected SSH to be unprotected without the defaults")
+	}
+	if reason, protected := sm.IsProtectedPort(5432); !protected || reason != "postgres" {
+		t.Errorf("Expected postgres to stay protected, got %q", reason)
+	}
+}
+
+func TestKillAllServicesSkipsProtectedPorts(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	// The listener belongs to the test process, which must survive
+	sm := NewSimple(WithMonitoredPort(port, "test database"), WithProtectedPort(port, "test database"))
+	errs := sm.KillAllServices()
+	if len(errs) != 1 || !errors.Is(errs[0], ErrProtectedPort) {
+		t.Fatalf("Expected one protected port warning, got %v", errs)
+	}
+	if !sm.isPortListening(port) {
+		t.Error("Expected the protected service to keep running")
+	}
+}
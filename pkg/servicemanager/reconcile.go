@@ -0,0 +1,341 @@
+package servicemanager
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+	"github.com/nzions/sharedgolibs/pkg/autoport"
+	"github.com/nzions/sharedgolibs/pkg/util"
+)
+
+// ReconcilePolicy selects which differences from the autoport configuration
+// Reconcile fixes
+type ReconcilePolicy struct {
+	KillUnexpected    bool // Kill services on expected ports that are not the expected service
+	RestartMismatched bool // Recreate expected containers running the wrong image from the expected image
+	StartMissing      bool // Start expected services that are not running, as containers
+	DryRun            bool // Only report the changes that would be made
+	Force             bool // Also change services on protected ports
+}
+
+// DefaultReconcilePolicy fixes every difference
+func DefaultReconcilePolicy() ReconcilePolicy {
+	return ReconcilePolicy{
+		KillUnexpected:    true,
+		RestartMismatched: true,
+		StartMissing:      true,
+	}
+}
+
+// ReconcileAction is a kind of change made by Reconcile
+type ReconcileAction string
+
+const (
+	ReconcileActionKill    ReconcileAction = "kill"
+	ReconcileActionRestart ReconcileAction = "restart"
+	ReconcileActionStart   ReconcileAction = "start"
+)
+
+// ReconcileChange is a single change planned or made by Reconcile
+type ReconcileChange struct {
+	Action  ReconcileAction `json:"action"`
+	Port    int             `json:"port"`
+	Service string          `json:"service"`           // Expected service on the port
+	Current string          `json:"current,omitempty"` // What was running on the port
+	Image   string          `json:"image,omitempty"`   // Image started for restart and start
+	Reason  string          `json:"reason"`
+	Applied bool            `json:"applied"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// ReconcileReport lists the changes Reconcile planned and, unless DryRun, made
+type ReconcileReport struct {
+	DryRun  bool              `json:"dry_run"`
+	Changes []ReconcileChange `json:"changes"`
+}
+
+// Failed returns the number of changes that could not be applied
+func (r *ReconcileReport) Failed() int {
+	failed := 0
+	for _, change := range r.Changes {
+		if change.Error != "" {
+			failed++
+		}
+	}
+	return failed
+}
+
+// portFreeTimeout is how long Reconcile waits for a killed service to release its port
+const portFreeTimeout = 10 * time.Second
+
+// Reconcile compares the running services with the autoport configuration and
+// brings them in line according to policy: unexpected services on expected
+// ports are killed, expected containers with the wrong image are recreated,
+// and missing services are started. Changes are applied in that order and
+// missing services are started after the services they depend on. Changes
+// on protected ports are refused with an error unless policy.Force is set.
+//
+// Started containers get the expected image, port mapping, and environment
+// on the default network; compose networks, IP addresses, and aliases are
+// not recreated.
+func (sm *ServiceManager) Reconcile(policy ReconcilePolicy) (*ReconcileReport, error) {
+	services, err := sm.DiscoverAllServices()
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover services: %w", err)
+	}
+
+	changes, err := sm.planReconcile(services, sm.GetMissingServices(), policy)
+	if err != nil {
+		return nil, err
+	}
+	report := &ReconcileReport{
+		DryRun:  policy.DryRun,
+		Changes: changes,
+	}
+	if policy.DryRun {
+		return report, nil
+	}
+
+	for i := range report.Changes {
+		change := &report.Changes[i]
+		if change.Error != "" {
+			// Refused while planning, e.g. on a protected port
+			continue
+		}
+		if err := sm.applyChange(*change, policy.Force); err != nil {
+			change.Error = err.Error()
+			continue
+		}
+		change.Applied = true
+	}
+
+	return report, nil
+}
+
+// planReconcile works out the changes needed to match the expected services.
+// Without policy.Force, changes on protected ports are planned with an error
+// and a kill refused that way does not queue a start of the expected service.
+// It returns an error if the services to start depend on each other in a
+// cycle, since there is no order to start them in.
+func (sm *ServiceManager) planReconcile(services []ServiceInfo, missing []autoport.ServiceConfig, policy ReconcilePolicy) ([]ReconcileChange, error) {
+	var kills, restarts []ReconcileChange
+	var toStart []autoport.ServiceConfig
+
+	for _, service := range services {
+		if !service.IsListening {
+			continue
+		}
+		expected, found := autoport.GetServiceByPort(service.ExternalPort)
+		if !found {
+			continue
+		}
+
+		switch {
+		case service.Type == ServiceTypeDockerContainer && service.ImageMatches:
+			continue
+		case service.Type == ServiceTypeDockerContainer && isExpectedContainer(service, expected):
+			if policy.RestartMismatched {
+				restarts = append(restarts, ReconcileChange{
+					Action:  ReconcileActionRestart,
+					Port:    service.ExternalPort,
+					Service: expected.Name,
+					Current: describeCurrent(service),
+					Image:   expected.Image,
+					Reason:  fmt.Sprintf("running image %s, expected %s", service.Image, expected.Image),
+				})
+				sm.refuseProtected(&restarts[len(restarts)-1], policy)
+			}
+		default:
+			if policy.KillUnexpected {
+				kills = append(kills, ReconcileChange{
+					Action:  ReconcileActionKill,
+					Port:    service.ExternalPort,
+					Service: expected.Name,
+					Current: describeCurrent(service),
+					Reason:  fmt.Sprintf("port %d belongs to %s", service.ExternalPort, expected.Name),
+				})
+				if sm.refuseProtected(&kills[len(kills)-1], policy) {
+					continue
+				}
+				// The port is free for the expected service once killed
+				toStart = append(toStart, expected)
+			}
+		}
+	}
+
+	toStart = append(toStart, missing...)
+
+	changes := append(kills, restarts...)
+	if policy.StartMissing {
+		ordered, err := orderByDependencies(toStart)
+		if err != nil {
+			return nil, fmt.Errorf("failed to order services to start: %w", err)
+		}
+		for _, expected := range ordered {
+			change := ReconcileChange{
+				Action:  ReconcileActionStart,
+				Port:    expected.ExternalPort,
+				Service: expected.Name,
+				Image:   expected.Image,
+				Reason:  "not running",
+			}
+			sm.refuseProtected(&change, policy)
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// refuseProtected marks a change on a protected port as failed unless
+// policy.Force is set, and reports whether it did
+func (sm *ServiceManager) refuseProtected(change *ReconcileChange, policy ReconcilePolicy) bool {
+	if policy.Force {
+		return false
+	}
+	if err := sm.checkProtected(change.Port); err != nil {
+		change.Error = err.Error()
+		return true
+	}
+	return false
+}
+
+// isExpectedContainer reports whether a container on an expected port is the
+// expected service itself (running the wrong image) rather than something else
+func isExpectedContainer(service ServiceInfo, expected autoport.ServiceConfig) bool {
+	if service.ComposeService == expected.Name {
+		return true
+	}
+	name := strings.ToLower(service.Name)
+	return name == expected.Name || strings.HasSuffix(name, "-"+expected.Name) ||
+		strings.Contains(name, "-"+expected.Name+"-") || strings.Contains(name, "_"+expected.Name+"_")
+}
+
+// describeCurrent summarizes what is running on a port
+func describeCurrent(service ServiceInfo) string {
+	if service.Type == ServiceTypeDockerContainer {
+		return fmt.Sprintf("container %s (%s, %s)", service.Name, service.ContainerID, service.Image)
+	}
+	if service.PID != "" {
+		return fmt.Sprintf("%s (PID %s)", service.Name, service.PID)
+	}
+	return service.Name
+}
+
+// orderByDependencies sorts services so each comes after the services it
+// depends on, dropping duplicates
+func orderByDependencies(services []autoport.ServiceConfig) ([]autoport.ServiceConfig, error) {
+	byName := make(map[string]autoport.ServiceConfig, len(services))
+	for _, service := range services {
+		byName[service.Name] = service
+	}
+	names, err := autoport.DependencyOrder(byName)
+	if err != nil {
+		return nil, err
+	}
+
+	ordered := make([]autoport.ServiceConfig, 0, len(names))
+	for _, name := range names {
+		ordered = append(ordered, byName[name])
+	}
+	return ordered, nil
+}
+
+// applyChange makes a single planned change; force allows changes on
+// protected ports
+func (sm *ServiceManager) applyChange(change ReconcileChange, force bool) error {
+	switch change.Action {
+	case ReconcileActionKill:
+		kill := sm.KillServiceOnPort
+		if force {
+			kill = sm.ForceKillServiceOnPort
+		}
+		if err := kill(change.Port); err != nil {
+			return err
+		}
+		return sm.waitForPortFree(change.Port)
+	case ReconcileActionRestart, ReconcileActionStart:
+		expected, found := autoport.GetServiceByPort(change.Port)
+		if !found {
+			return fmt.Errorf("no expected service on port %d", change.Port)
+		}
+		return sm.startExpectedContainer(expected, force)
+	default:
+		return fmt.Errorf("unknown reconcile action: %s", change.Action)
+	}
+}
+
+// waitForPortFree waits for a killed service to stop listening
+func (sm *ServiceManager) waitForPortFree(port int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), portFreeTimeout)
+	defer cancel()
+	err := util.WaitFor(ctx, 100*time.Millisecond, func() bool {
+		return !sm.isPortListening(port)
+	})
+	if err != nil {
+		return fmt.Errorf("port %d still in use after %s", port, portFreeTimeout)
+	}
+	return nil
+}
+
+// startExpectedContainer (re)creates the container for an expected service
+// from its expected image and starts it. Whatever holds the port is removed,
+// so a protected port is refused unless force is set.
+func (sm *ServiceManager) startExpectedContainer(expected autoport.ServiceConfig, force bool) error {
+	if !force {
+		if err := sm.checkProtected(expected.ExternalPort); err != nil {
+			return err
+		}
+	}
+	if !sm.IsDockerAvailable() {
+		return fmt.Errorf("docker is not available")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	docker := sm.dockerConfig.Client
+
+	// Remove whatever holds the port or the name, e.g. a container with the wrong image
+	if existing := sm.checkDockerForPort(expected.ExternalPort); existing != nil {
+		if err := docker.ContainerRemove(ctx, existing.ContainerID, container.RemoveOptions{Force: true}); err != nil {
+			return fmt.Errorf("failed to remove container %s: %w", existing.Name, err)
+		}
+	}
+	if err := docker.ContainerRemove(ctx, expected.Name, container.RemoveOptions{Force: true}); err != nil && !client.IsErrNotFound(err) {
+		return fmt.Errorf("failed to remove container %s: %w", expected.Name, err)
+	}
+
+	protocol := expected.Protocol
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	internal, err := nat.NewPort(protocol, strconv.Itoa(expected.InternalPort))
+	if err != nil {
+		return fmt.Errorf("invalid port for %s: %w", expected.Name, err)
+	}
+
+	config := &container.Config{
+		Image:        expected.Image,
+		Env:          expected.Environment,
+		ExposedPorts: nat.PortSet{internal: struct{}{}},
+	}
+	hostConfig := &container.HostConfig{
+		PortBindings: nat.PortMap{
+			internal: []nat.PortBinding{{HostPort: strconv.Itoa(expected.ExternalPort)}},
+		},
+	}
+
+	created, err := docker.ContainerCreate(ctx, config, hostConfig, nil, nil, expected.Name)
+	if err != nil {
+		return fmt.Errorf("failed to create container %s from %s: %w", expected.Name, expected.Image, err)
+	}
+	if err := docker.ContainerStart(ctx, created.ID, container.StartOptions{}); err != nil {
+		return fmt.Errorf("failed to start container %s: %w", expected.Name, err)
+	}
+	return nil
+}
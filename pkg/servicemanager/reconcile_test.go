@@ -0,0 +1,157 @@
+package servicemanager
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nzions/sharedgolibs/pkg/autoport"
+)
+
+func TestPlanReconcile(t *testing.T) {
+	backend, _ := autoport.GetServiceByName("amt-backend")
+	frontend, _ := autoport.GetServiceByName("amt-frontend")
+	ca, _ := autoport.GetServiceByName("ca")
+
+	services := []ServiceInfo{
+		// Expected service with the right image: left alone
+		{Name: "ca", Type: ServiceTypeDockerContainer, ExternalPort: ca.ExternalPort, Image: ca.Image, IsListening: true, ImageMatches: true},
+		// Expected container with the wrong image: recreated
+		{Name: "dev-amt-backend-1", Type: ServiceTypeDockerContainer, ExternalPort: backend.ExternalPort, ContainerID: "abc", Image: "amt-backend:old", IsListening: true},
+		// A local process squatting on the frontend port: killed, then the frontend started
+		{Name: "python3", Type: ServiceTypeLocalProcess, ExternalPort: frontend.ExternalPort, PID: "4242", IsListening: true},
+		// Not an expected port: ignored
+		{Name: "other", Type: ServiceTypeLocalProcess, ExternalPort: 9, PID: "1", IsListening: true},
+	}
+
+	sm := NewSimple()
+	changes, err := sm.planReconcile(services, nil, DefaultReconcilePolicy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("Expected 3 changes, got %+v", changes)
+	}
+
+	if c := changes[0]; c.Action != ReconcileActionKill || c.Port != frontend.ExternalPort || c.Current != "python3 (PID 4242)" {
+		t.Errorf("Expected kill of frontend squatter first, got %+v", c)
+	}
+	if c := changes[1]; c.Action != ReconcileActionRestart || c.Port != backend.ExternalPort || c.Image != backend.Image {
+		t.Errorf("Expected backend restart with expected image, got %+v", c)
+	}
+	if c := changes[2]; c.Action != ReconcileActionStart || c.Service != frontend.Name {
+		t.Errorf("Expected frontend start after kill, got %+v", c)
+	}
+
+	// Policy switches each kind of change off
+	policy := ReconcilePolicy{RestartMismatched: true}
+	if changes, _ := sm.planReconcile(services, nil, policy); len(changes) != 1 || changes[0].Action != ReconcileActionRestart {
+		t.Errorf("Expected only the restart, got %+v", changes)
+	}
+
+	// An unrelated container on an expected port is killed, not recreated
+	services[1].Name = "someone-elses-db"
+	if changes, _ := sm.planReconcile(services[1:2], nil, DefaultReconcilePolicy()); len(changes) != 2 || changes[0].Action != ReconcileActionKill {
+		t.Errorf("Expected kill and start for unrelated container, got %+v", changes)
+	}
+}
+
+func TestPlanReconcileProtectedPorts(t *testing.T) {
+	backend, _ := autoport.GetServiceByName("amt-backend")
+	frontend, _ := autoport.GetServiceByName("amt-frontend")
+	sm := NewSimple(WithProtectedPort(backend.ExternalPort, "backend"), WithProtectedPort(frontend.ExternalPort, "frontend"))
+
+	services := []ServiceInfo{
+		// The expected container with the wrong image, on a protected port
+		{Name: "dev-amt-backend-1", Type: ServiceTypeDockerContainer, ExternalPort: backend.ExternalPort, ContainerID: "abc", Image: "amt-backend:old", IsListening: true},
+		// Something else on a protected port
+		{Name: "python3", Type: ServiceTypeLocalProcess, ExternalPort: frontend.ExternalPort, PID: "4242", IsListening: true},
+	}
+
+	changes, err := sm.planReconcile(services, nil, DefaultReconcilePolicy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("Expected the kill and restart without a start, got %+v", changes)
+	}
+	for _, c := range changes {
+		if c.Action == ReconcileActionStart {
+			t.Errorf("Expected no start on a protected port, got %+v", c)
+		}
+		if !strings.Contains(c.Error, ErrProtectedPort.Error()) {
+			t.Errorf("Expected the %s on port %d to be refused, got %+v", c.Action, c.Port, c)
+		}
+	}
+
+	// Applying refuses the protected port before touching Docker
+	if err := sm.startExpectedContainer(backend, false); !errors.Is(err, ErrProtectedPort) {
+		t.Errorf("Expected ErrProtectedPort starting on a protected port, got %v", err)
+	}
+
+	// Force plans the changes as usual
+	policy := DefaultReconcilePolicy()
+	policy.Force = true
+	changes, err = sm.planReconcile(services, nil, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("Expected kill, restart, and start with Force, got %+v", changes)
+	}
+	for _, c := range changes {
+		if c.Error != "" {
+			t.Errorf("Expected no refusal with Force, got %+v", c)
+		}
+	}
+}
+
+func TestOrderByDependencies(t *testing.T) {
+	services := []autoport.ServiceConfig{
+		{Name: "frontend", DependsOn: []string{"backend"}},
+		{Name: "backend", DependsOn: []string{"ca", "db"}},
+		{Name: "ca"},
+		{Name: "frontend", DependsOn: []string{"backend"}}, // Duplicate
+	}
+
+	ordered, err := orderByDependencies(services)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range ordered {
+		names = append(names, s.Name)
+	}
+
+	// db is not being started, so it is skipped
+	expected := []string{"ca", "backend", "frontend"}
+	if len(names) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, names)
+			break
+		}
+	}
+}
+
+func TestPlanReconcileDependencyCycle(t *testing.T) {
+	missing := []autoport.ServiceConfig{
+		{Name: "a", DependsOn: []string{"b"}},
+		{Name: "b", DependsOn: []string{"a"}},
+	}
+	if _, err := NewSimple().planReconcile(nil, missing, DefaultReconcilePolicy()); err == nil || !strings.Contains(err.Error(), "dependency cycle") {
+		t.Errorf("Expected a dependency cycle error, got %v", err)
+	}
+}
+
+func TestReconcileReportFailed(t *testing.T) {
+	report := &ReconcileReport{Changes: []ReconcileChange{
+		{Action: ReconcileActionKill, Applied: true},
+		{Action: ReconcileActionStart, Error: "docker is not available"},
+	}}
+	if report.Failed() != 1 {
+		t.Errorf("Expected 1 failed change, got %d", report.Failed())
+	}
+}
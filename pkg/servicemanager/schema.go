@@ -0,0 +1,150 @@
+package servicemanager
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/nzions/sharedgolibs/pkg/autoport"
+)
+
+// SchemaVersion is the version of the JSON documents servicemanager emits:
+// ServiceInfo, ServiceStatus, and the missing services, as printed with
+// -json and served by Handler. It changes only when a field is removed,
+// renamed, or changes type; added fields keep the version, so consumers
+// should ignore fields they don't know.
+const SchemaVersion = 1
+
+// SchemaVersionHeader carries SchemaVersion on every Handler response
+const SchemaVersionHeader = "X-Servicemanager-Schema-Version"
+
+// publishedSchemas holds the JSON Schema of every SchemaVersion, generated
+// from the Go types by TestJSONSchema -update
+//
+//go:embed schema/*.json
+var publishedSchemas embed.FS
+
+// JSONSchema returns the published JSON Schema (draft 2020-12) of the
+// current SchemaVersion. Its $defs describe ServiceInfo, ServiceStatus,
+// and autoport.ServiceConfig, e.g. for validating -json output with
+// "$ref": ".../schema/v1.json#/$defs/ServiceStatus".
+func JSONSchema() []byte {
+	data, err := publishedSchemas.ReadFile(fmt.Sprintf("schema/v%d.json", SchemaVersion))
+	if err != nil {
+		panic(err) // Guarded by TestJSONSchema
+	}
+	return data
+}
+
+// generateSchema builds the schema of the current Go types; TestJSONSchema
+// keeps the published one in line with it
+func generateSchema() ([]byte, error) {
+	g := &schemaGenerator{defs: make(map[string]interface{})}
+	for _, t := range []reflect.Type{
+		reflect.TypeOf(ServiceInfo{}),
+		reflect.TypeOf(ServiceStatus{}),
+		reflect.TypeOf(autoport.ServiceConfig{}),
+	} {
+		g.schemaFor(t)
+	}
+
+	data, err := json.MarshalIndent(map[string]interface{}{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id":     fmt.Sprintf("https://github.com/nzions/sharedgolibs/pkg/servicemanager/schema/v%d.json", SchemaVersion),
+		"title":   fmt.Sprintf("servicemanager JSON output, schema version %d", SchemaVersion),
+		"$defs":   g.defs,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// schemaGenerator derives JSON Schema definitions from Go types the way
+// encoding/json marshals them
+type schemaGenerator struct {
+	defs map[string]interface{}
+}
+
+// schemaFor returns the schema of t, adding named structs to the $defs
+func (g *schemaGenerator) schemaFor(t reflect.Type) map[string]interface{} {
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case reflect.TypeOf(ServiceType("")):
+		return map[string]interface{}{"type": "string", "enum": []ServiceType{ServiceTypeDockerContainer, ServiceTypeLocalProcess, ServiceTypeUnknown}}
+	case reflect.TypeOf(SupervisorState("")):
+		return map[string]interface{}{"type": "string", "enum": []SupervisorState{SupervisorRunning, SupervisorBackoff, SupervisorFailed, SupervisorStopped}}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schemaFor(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		// Nil slices and maps marshal as null
+		return map[string]interface{}{"type": []string{"array", "null"}, "items": g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": []string{"object", "null"}, "additionalProperties": g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		return g.structRef(t)
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// structRef defines a struct in the $defs, once, and refers to it
+func (g *schemaGenerator) structRef(t reflect.Type) map[string]interface{} {
+	name := t.Name()
+	if t.PkgPath() != reflect.TypeOf(ServiceInfo{}).PkgPath() {
+		name = path.Base(t.PkgPath()) + "." + name
+	}
+	ref := map[string]interface{}{"$ref": "#/$defs/" + name}
+	if _, ok := g.defs[name]; ok {
+		return ref
+	}
+	g.defs[name] = nil // Placeholder for recursive types
+
+	properties := make(map[string]interface{})
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		key, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if key == "-" {
+			continue
+		}
+		if key == "" {
+			key = field.Name
+		}
+		schema := g.schemaFor(field.Type)
+		if field.Type.Kind() == reflect.Pointer && !strings.Contains(options, "omitempty") {
+			schema = map[string]interface{}{"anyOf": []interface{}{schema, map[string]interface{}{"type": "null"}}}
+		}
+		properties[key] = schema
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, key)
+		}
+	}
+
+	def := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		def["required"] = required
+	}
+	g.defs[name] = def
+	return ref
+}
@@ -0,0 +1,86 @@
+package servicemanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nzions/sharedgolibs/pkg/autoport"
+)
+
+var update = flag.Bool("update", false, "rewrite the published JSON Schema in schema/")
+
+func TestJSONSchema(t *testing.T) {
+	generated, err := generateSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *update {
+		if err := os.WriteFile(fmt.Sprintf("schema/v%d.json", SchemaVersion), generated, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	if !bytes.Equal(JSONSchema(), generated) {
+		t.Errorf("schema/v%d.json is out of date with the Go types. If fields were only added, run "+
+			"go test -run TestJSONSchema -update; if any were removed, renamed, or retyped, bump SchemaVersion and APIPrefix first", SchemaVersion)
+	}
+	if APIPrefix != "/v1" || SchemaVersion != 1 {
+		t.Errorf("APIPrefix %s does not match SchemaVersion %d", APIPrefix, SchemaVersion)
+	}
+}
+
+func TestJSONSchemaCoversOutput(t *testing.T) {
+	var schema struct {
+		Defs map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+			Required   []string                   `json:"required"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(JSONSchema(), &schema); err != nil {
+		t.Fatalf("Invalid schema JSON: %v", err)
+	}
+
+	issuedByCA := true
+	started := time.Now()
+	status := ServiceStatus{
+		Running: []ServiceInfo{{
+			Name: "api", Type: ServiceTypeDockerContainer, ExternalPort: 8080, StartedAt: &started,
+			Labels:     map[string]string{"a": "b"},
+			CertStatus: &CertStatus{Hostname: "localhost", IssuedByCA: &issuedByCA},
+			Stats:      &ContainerStats{CPUPercent: 1.5},
+		}},
+		Missing: []autoport.ServiceConfig{{Name: "ca", ExternalPort: 8090}},
+	}
+	data, _ := json.Marshal(status)
+
+	// Every key of the output is declared, and every required one is present
+	check := func(def string, object map[string]interface{}) {
+		d, ok := schema.Defs[def]
+		if !ok {
+			t.Fatalf("Schema has no definition %s", def)
+		}
+		for key := range object {
+			if _, ok := d.Properties[key]; !ok {
+				t.Errorf("%s.%s is not in the schema", def, key)
+			}
+		}
+		for _, key := range d.Required {
+			if _, ok := object[key]; !ok {
+				t.Errorf("%s.%s is required but missing from the output", def, key)
+			}
+		}
+	}
+	var output map[string]interface{}
+	json.Unmarshal(data, &output)
+	check("ServiceStatus", output)
+	service := output["running"].([]interface{})[0].(map[string]interface{})
+	check("ServiceInfo", service)
+	check("CertStatus", service["cert_status"].(map[string]interface{}))
+	check("ContainerStats", service["stats"].(map[string]interface{}))
+	check("autoport.ServiceConfig", output["missing"].([]interface{})[0].(map[string]interface{}))
+}
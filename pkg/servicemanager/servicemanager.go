@@ -21,8 +21,10 @@ package servicemanager
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
 	"io"
+	"iter"
 	"net"
 	"os"
 	"os/exec"
@@ -35,10 +37,11 @@ import (
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/nzions/sharedgolibs/pkg/autoport"
+	"github.com/nzions/sharedgolibs/pkg/dockerutil"
 	"gopkg.in/yaml.v3"
 )
 
-const Version = "0.3.0"
+const Version = "0.26.0"
 
 // ServiceType represents the type of service discovered
 type ServiceType string
@@ -61,12 +64,36 @@ type ServiceInfo struct {
 	Image         string      `json:"image,omitempty"`
 	Status        string      `json:"status"`
 	Uptime        string      `json:"uptime,omitempty"`
+	StartedAt     *time.Time  `json:"started_at,omitempty"` // Running Docker containers
 	IsListening   bool        `json:"is_listening"`
 	HealthURL     string      `json:"health_url,omitempty"`
 	IsExpected    bool        `json:"is_expected"`
 	ExpectedImage string      `json:"expected_image,omitempty"`
 	ImageMatches  bool        `json:"image_matches"`
 	Description   string      `json:"description,omitempty"`
+
+	// Ownership details, filled in for unexpected services
+	User             string            `json:"user,omitempty"`
+	ParentPID        string            `json:"parent_pid,omitempty"`
+	ParentCommand    string            `json:"parent_command,omitempty"`
+	CommandLine      string            `json:"command_line,omitempty"`
+	WorkingDir       string            `json:"working_dir,omitempty"`
+	Labels           map[string]string `json:"labels,omitempty"`
+	ComposeProject   string            `json:"compose_project,omitempty"`
+	ComposeService   string            `json:"compose_service,omitempty"`
+	ProbableIdentity string            `json:"probable_identity,omitempty"`
+
+	// Certificate served by TLS services (IsSecure in autoport or known services)
+	CertStatus *CertStatus `json:"cert_status,omitempty"`
+
+	// Environment variables of Docker containers matching the env allowlist
+	Env map[string]string `json:"env,omitempty"`
+
+	// Resource usage of running Docker containers, with WithStats
+	Stats *ContainerStats `json:"stats,omitempty"`
+
+	// Local process run by Supervise, also listed while it is down
+	Supervised *SupervisedStatus `json:"supervised,omitempty"`
 }
 
 // ServiceConfig holds configuration for known services
@@ -78,8 +105,8 @@ type ServiceConfig struct {
 
 // PortRange defines the range of ports to scan
 type PortRange struct {
-	Start int `json:"start"`
-	End   int `json:"end"`
+	Start int `yaml:"start" json:"start"`
+	End   int `yaml:"end" json:"end"`
 }
 
 // ServiceStatus represents the overall status of services
@@ -90,6 +117,7 @@ type ServiceStatus struct {
 	Unexpected    int                      `json:"unexpected_count"`
 	ImageMatch    int                      `json:"image_match_count"`
 	ImageMismatch int                      `json:"image_mismatch_count"`
+	CertProblems  int                      `json:"cert_problem_count"` // Expired, non-CA-issued, or mismatched certificates
 	Total         int                      `json:"total_count"`
 	Listening     int                      `json:"listening_count"`
 }
@@ -109,6 +137,17 @@ type ServiceManager struct {
 	knownServices    map[int]ServiceConfig
 	monitoredPorts   []int
 	portDescriptions map[int]string
+	history          *historyStore  // nil unless WithHistoryFile is used
+	caRoots          *x509.CertPool // nil unless WithCARoots is used
+	envAllowlist     []string       // Container environment variables to capture
+	ports            portCache      // Batch port-to-PID resolution shared by a scan
+	identifiers      []Identifier   // Custom identification, see WithIdentifier
+	collectStats     bool           // Container resource usage, see WithStats
+	hostAddress      string         // Checked instead of localhost, see WithDockerHostAddress
+	protectedPorts   map[int]string // Reasons by port, see WithProtectedPort
+	leases           *leaseStore    // Port reservations, see ReservePorts
+	connections      bool           // Connection edges in the topology, see WithConnections
+	supervisors      supervisorSet  // Local processes run by Supervise
 }
 
 // ManagerOption defines a functional option for ServiceManager configuration
@@ -156,6 +195,9 @@ func New(options ...ManagerOption) *ServiceManager {
 		knownServices:    make(map[int]ServiceConfig),
 		monitoredPorts:   make([]int, 0),
 		portDescriptions: make(map[int]string),
+		envAllowlist:     DefaultEnvAllowlist,
+		protectedPorts:   defaultProtectedPorts(),
+		leases:           newLeaseStore(),
 		dockerConfig: &DockerConfig{
 			Timeout: 5 * time.Second,
 		},
@@ -170,6 +212,7 @@ func New(options ...ManagerOption) *ServiceManager {
 	sm.initializeDefaultServices()
 	sm.initializeDefaultMonitoredPorts()
 	sm.initializeDockerClient()
+	sm.resolveHostAddress()
 
 	return sm
 }
@@ -181,6 +224,9 @@ func NewSimple(options ...ManagerOption) *ServiceManager {
 		knownServices:    make(map[int]ServiceConfig),
 		monitoredPorts:   make([]int, 0),
 		portDescriptions: make(map[int]string),
+		envAllowlist:     DefaultEnvAllowlist,
+		protectedPorts:   defaultProtectedPorts(),
+		leases:           newLeaseStore(),
 		dockerConfig:     nil, // No Docker integration
 	}
 
@@ -188,6 +234,7 @@ func NewSimple(options ...ManagerOption) *ServiceManager {
 	for _, option := range options {
 		option(sm)
 	}
+	sm.resolveHostAddress()
 
 	return sm
 }
@@ -205,7 +252,7 @@ func (sm *ServiceManager) initializeDefaultServices() {
 		8086: {Name: "GCR Emulator", HealthURL: "https://localhost:8086", IsSecure: true},
 		8087: {Name: "OpenAI Emulator", HealthURL: "https://localhost:8087", IsSecure: true},
 		8088: {Name: "Metadata Service", HealthURL: "http://localhost:8088"},
-		8089: {Name: "CA Service", HealthURL: "http://localhost:8089/health"},
+		8089: {Name: "CA Service", HealthURL: "http://localhost:8089/readyz"},
 		8090: {Name: "Firebase Emulator", HealthURL: "https://localhost:8090", IsSecure: true},
 
 		// Legacy/standalone ports
@@ -252,42 +299,17 @@ func (sm *ServiceManager) initializeDockerClient() {
 	ctx, cancel := context.WithTimeout(context.Background(), sm.dockerConfig.Timeout)
 	defer cancel()
 
-	// Try standard Docker client from environment first
-	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err == nil {
-		// Test Docker connectivity
-		_, err = dockerClient.Ping(ctx)
-		if err == nil {
-			sm.dockerConfig.Client = dockerClient
-			sm.dockerConfig.Available = true
-			sm.dockerConfig.SocketPath = "docker-env" // Standard Docker from environment
-			return
-		}
-	}
-
-	// If standard Docker isn't available, try Colima's default socket location
-	homeDir, err := os.UserHomeDir()
-	if err == nil {
-		colimaSocketPath := fmt.Sprintf("unix://%s/.colima/default/docker.sock", homeDir)
-		dockerClient, err = client.NewClientWithOpts(
-			client.WithHost(colimaSocketPath),
-			client.WithAPIVersionNegotiation(),
-		)
-		if err == nil {
-			// Test Colima Docker connectivity
-			_, err = dockerClient.Ping(ctx)
-			if err == nil {
-				sm.dockerConfig.Client = dockerClient
-				sm.dockerConfig.Available = true
-				sm.dockerConfig.SocketPath = colimaSocketPath
-				return
-			}
-		}
+	// Probe DOCKER_HOST, Docker Desktop, Colima, Rancher Desktop, and Podman
+	det, err := dockerutil.DetectClient(ctx)
+	if err != nil {
+		sm.dockerConfig.Available = false
+		sm.dockerConfig.SocketPath = ""
+		return
 	}
 
-	// If both fail, Docker is not available
-	sm.dockerConfig.Available = false
-	sm.dockerConfig.SocketPath = ""
+	sm.dockerConfig.Client = det.Client
+	sm.dockerConfig.Available = true
+	sm.dockerConfig.SocketPath = det.Host
 }
 
 // Docker Configuration Methods
@@ -315,17 +337,44 @@ func (sm *ServiceManager) GetDockerConfig() *DockerConfig {
 // DiscoverAllServices discovers all services running on monitored ports
 func (sm *ServiceManager) DiscoverAllServices() ([]ServiceInfo, error) {
 	var services []ServiceInfo
+	sm.discoverServices(context.Background(), func(service ServiceInfo) bool {
+		services = append(services, service)
+		return true
+	})
+
+	// History is best effort; a failure to persist it does not invalidate
+	// discovery and is reported by HistoryError
+	if sm.history != nil {
+		sm.history.record(services, sm.portRange, time.Now())
+	}
+
+	return services, nil
+}
+
+// DiscoverServicesStream yields services in port order as the scan finds
+// them, so callers can show progress or stop early once the service they
+// are looking for appears. The scan ends when ctx is done or the loop
+// breaks. Unlike DiscoverAllServices it does not record history, since a
+// partial scan would look like stopped services.
+func (sm *ServiceManager) DiscoverServicesStream(ctx context.Context) iter.Seq[ServiceInfo] {
+	return func(yield func(ServiceInfo) bool) {
+		sm.discoverServices(ctx, yield)
+	}
+}
+
+// discoverServices scans the port range and passes each listening service
+// to yield until ctx is done or yield returns false
+func (sm *ServiceManager) discoverServices(ctx context.Context, yield func(ServiceInfo) bool) {
+	sm.ports.invalidate()
 
 	// Get Docker containers if available
 	containersByPort := make(map[int]ServiceInfo)
-	dockerPorts := make(map[int]bool)
 	if sm.IsDockerAvailable() {
 		containers, err := sm.getDockerContainers()
 		if err == nil {
 			for _, container := range containers {
 				if container.ExternalPort > 0 {
 					containersByPort[container.ExternalPort] = container
-					dockerPorts[container.ExternalPort] = true
 				}
 			}
 		}
@@ -339,11 +388,17 @@ func (sm *ServiceManager) DiscoverAllServices() ([]ServiceInfo, error) {
 	}
 
 	// Scan port range
+	seen := make(map[int]bool)
 	for port := sm.portRange.Start; port <= sm.portRange.End; port++ {
+		if ctx.Err() != nil {
+			return
+		}
+
 		// Check if port is listening
 		if !sm.isPortListening(port) {
 			continue
 		}
+		seen[port] = true
 
 		var service ServiceInfo
 
@@ -367,10 +422,20 @@ func (sm *ServiceManager) DiscoverAllServices() ([]ServiceInfo, error) {
 		// Enhance with autoport configuration and monitored port descriptions
 		service = sm.enhanceServiceInfo(service, expectedPortMap[port])
 
-		services = append(services, service)
+		if !yield(service) {
+			return
+		}
 	}
 
-	return services, nil
+	// Supervised processes that are down or outside the port range
+	for _, service := range sm.downSupervisedServices(seen) {
+		if ctx.Err() != nil {
+			return
+		}
+		if !yield(sm.enhanceServiceInfo(service, expectedPortMap[service.ExternalPort])) {
+			return
+		}
+	}
 }
 
 // DiscoverExpectedServices returns only services that are expected according to autoport
@@ -419,6 +484,7 @@ func (sm *ServiceManager) DiscoverDockerServices() ([]ServiceInfo, error) {
 // DiscoverLocalServices discovers only local process services
 func (sm *ServiceManager) DiscoverLocalServices() []ServiceInfo {
 	var services []ServiceInfo
+	sm.ports.invalidate()
 
 	for port := sm.portRange.Start; port <= sm.portRange.End; port++ {
 		if sm.isPortListening(port) {
@@ -542,6 +608,9 @@ func (sm *ServiceManager) GetServiceStatus() (*ServiceStatus, error) {
 		} else {
 			status.Unexpected++
 		}
+		if service.CertStatus != nil && service.CertStatus.Problem() != "" {
+			status.CertProblems++
+		}
 	}
 
 	return status, nil
@@ -561,8 +630,18 @@ func (sm *ServiceManager) KillDockerContainer(containerNameOrID string) error {
 	return sm.dockerConfig.Client.ContainerKill(ctx, containerNameOrID, "SIGTERM")
 }
 
-// KillServiceOnPort kills the service (container or process) on a specific port
+// KillServiceOnPort kills the service (container or process) on a specific
+// port. Protected ports are refused with an error wrapping ErrProtectedPort.
 func (sm *ServiceManager) KillServiceOnPort(port int) error {
+	if err := sm.checkProtected(port); err != nil {
+		return err
+	}
+	return sm.ForceKillServiceOnPort(port)
+}
+
+// ForceKillServiceOnPort kills the service on a specific port even if the
+// port is protected
+func (sm *ServiceManager) ForceKillServiceOnPort(port int) error {
 	service, err := sm.CheckPort(port)
 	if err != nil {
 		return err
@@ -581,15 +660,33 @@ func (sm *ServiceManager) KillServiceOnPort(port int) error {
 	}
 }
 
-// KillAllServices kills all services listening on monitored ports
+// KillAllServices kills all services listening on monitored ports. Protected
+// ports are skipped, each reported by an error wrapping ErrProtectedPort;
+// callers can treat those as warnings with errors.Is.
 func (sm *ServiceManager) KillAllServices() []error {
+	return sm.killAllServices(false)
+}
+
+// ForceKillAllServices kills all services listening on monitored ports,
+// protected ones included
+func (sm *ServiceManager) ForceKillAllServices() []error {
+	return sm.killAllServices(true)
+}
+
+// killAllServices kills the services on monitored ports
+func (sm *ServiceManager) killAllServices(force bool) []error {
 	var errors []error
 
 	for _, port := range sm.monitoredPorts {
-		if sm.isPortListening(port) {
-			if err := sm.KillServiceOnPort(port); err != nil {
-				errors = append(errors, fmt.Errorf("failed to kill service on port %d: %w", port, err))
-			}
+		if !sm.isPortListening(port) {
+			continue
+		}
+		if err := sm.checkProtected(port); err != nil && !force {
+			errors = append(errors, fmt.Errorf("skipped service on port %d: %w", port, err))
+			continue
+		}
+		if err := sm.ForceKillServiceOnPort(port); err != nil {
+			errors = append(errors, fmt.Errorf("failed to kill service on port %d: %w", port, err))
 		}
 	}
 
@@ -674,7 +771,7 @@ func (sm *ServiceManager) enhanceServiceInfo(service ServiceInfo, isExpected boo
 
 			// Set health URL from autoport
 			if service.HealthURL == "" {
-				service.HealthURL = expectedService.HealthPath
+				service.HealthURL = autoport.HealthURL(expectedService)
 			}
 
 			// Store expected image for comparison
@@ -696,6 +793,7 @@ func (sm *ServiceManager) enhanceServiceInfo(service ServiceInfo, isExpected boo
 		if service.Type == ServiceTypeDockerContainer {
 			service = sm.identifyUnexpectedService(service)
 		}
+		service = sm.identifyOwner(service)
 
 		// Use known service name if available
 		if config, exists := sm.knownServices[service.ExternalPort]; exists {
@@ -708,6 +806,24 @@ func (sm *ServiceManager) enhanceServiceInfo(service ServiceInfo, isExpected boo
 		}
 	}
 
+	service = sm.supervisedService(service)
+
+	// Configured health URLs name localhost; point them at the Docker host
+	service.HealthURL = sm.onHostAddress(service.HealthURL)
+
+	// Custom identifiers have the last word on what the service is
+	service = sm.identify(service)
+
+	if service.IsListening && sm.isSecureService(service.ExternalPort) {
+		service.CertStatus = sm.CheckCertificate(service.ExternalPort)
+	}
+	if service.Type == ServiceTypeDockerContainer && service.ContainerID != "" {
+		service.Env = sm.containerEnv(service.ContainerID)
+		if service.IsListening {
+			service.Stats = sm.containerStats(service.ContainerID)
+		}
+	}
+
 	return service
 }
 
@@ -747,12 +863,14 @@ func (sm *ServiceManager) getDockerContainers() ([]ServiceInfo, error) {
 			if len(c.Names) > 0 {
 				service.Name = strings.TrimPrefix(c.Names[0], "/")
 			}
+			setContainerLabels(&service, c.Labels)
 
 			// Calculate uptime for running containers
 			if c.State == "running" {
 				created := time.Unix(c.Created, 0)
 				uptime := time.Since(created)
 				service.Uptime = formatUptime(uptime)
+				service.StartedAt = &created
 			}
 
 			services = append(services, service)
@@ -762,10 +880,15 @@ func (sm *ServiceManager) getDockerContainers() ([]ServiceInfo, error) {
 	return services, nil
 }
 
-// getLocalProcessInfo gets information about a local process on a port
+// getLocalProcessInfo gets information about a local process on a port,
+// from a batch resolution of all listening ports
 func (sm *ServiceManager) getLocalProcessInfo(port int) ServiceInfo {
-	cmd := exec.Command("lsof", "-i", fmt.Sprintf(":%d", port))
-	output, err := cmd.CombinedOutput()
+	// Local processes can't own ports on another machine
+	if sm.isRemoteHost() {
+		return sm.remoteServiceInfo(port)
+	}
+
+	owner, listening, err := sm.ports.lookup(port)
 
 	service := ServiceInfo{
 		Type:         ServiceTypeLocalProcess,
@@ -775,20 +898,11 @@ func (sm *ServiceManager) getLocalProcessInfo(port int) ServiceInfo {
 		Status:       "not listening",
 	}
 
-	lines := strings.Split(string(output), "\n")
-	if err == nil && len(lines) > 1 {
+	if err == nil && listening {
 		service.IsListening = true
 		service.Status = "running"
-
-		// Parse first process found (skip header line)
-		for _, line := range lines[1:] {
-			fields := strings.Fields(line)
-			if len(fields) > 1 {
-				service.PID = fields[1]
-				service.Command = fields[0]
-				break
-			}
-		}
+		service.PID = owner.PID
+		service.Command = owner.Command
 	}
 
 	// Check if this is an SSH process that might be Docker port forwarding
@@ -876,12 +990,14 @@ func (sm *ServiceManager) checkDockerForPort(port int) *ServiceInfo {
 				} else {
 					service.Name = "Unknown Container"
 				}
+				setContainerLabels(service, c.Labels)
 
 				// Calculate uptime for running containers
 				if c.State == "running" {
 					created := time.Unix(c.Created, 0)
 					uptime := time.Since(created)
 					service.Uptime = formatUptime(uptime)
+					service.StartedAt = &created
 				} else {
 					service.Uptime = "Not Running"
 				}
@@ -897,7 +1013,7 @@ func (sm *ServiceManager) checkDockerForPort(port int) *ServiceInfo {
 // isPortListening checks if a port is listening using a TCP connection attempt
 func (sm *ServiceManager) isPortListening(port int) bool {
 	timeout := 100 * time.Millisecond
-	conn, err := net.DialTimeout("tcp", fmt.Sprintf("localhost:%d", port), timeout)
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(sm.GetHostAddress(), strconv.Itoa(port)), timeout)
 	if err != nil {
 		return false
 	}
@@ -916,6 +1032,7 @@ func (sm *ServiceManager) killProcess(pid string) error {
 		return fmt.Errorf("invalid PID format: %s", pid)
 	}
 
+	sm.ports.invalidate()
 	cmd := exec.Command("kill", "-9", pid)
 	return cmd.Run()
 }
@@ -1002,22 +1119,34 @@ type AutoPortConfig struct {
 
 // GenerateAutoPortConfig reads docker-compose.yml and generates autoport configuration
 func (sm *ServiceManager) GenerateAutoPortConfig(composeFilePath, outputPath string) error {
+	configs, portMappings, err := parseComposeFile(composeFilePath)
+	if err != nil {
+		return err
+	}
+
+	// Generate Go file
+	return sm.generateAutoPortGoFile(configs, portMappings, outputPath)
+}
+
+// parseComposeFile reads docker-compose.yml into the autoport configuration
+// of each service and the service of each external port
+func parseComposeFile(composeFilePath string) (map[string]AutoPortConfig, map[int]string, error) {
 	// Read and parse docker-compose.yml
 	yamlFile, err := os.Open(composeFilePath)
 	if err != nil {
-		return fmt.Errorf("failed to open docker-compose.yml: %w", err)
+		return nil, nil, fmt.Errorf("failed to open docker-compose.yml: %w", err)
 	}
 	defer yamlFile.Close()
 
 	yamlData, err := io.ReadAll(yamlFile)
 	if err != nil {
-		return fmt.Errorf("failed to read docker-compose.yml: %w", err)
+		return nil, nil, fmt.Errorf("failed to read docker-compose.yml: %w", err)
 	}
 
 	var compose DockerCompose
 	err = yaml.Unmarshal(yamlData, &compose)
 	if err != nil {
-		return fmt.Errorf("failed to parse docker-compose.yml: %w", err)
+		return nil, nil, fmt.Errorf("failed to parse docker-compose.yml: %w", err)
 	}
 
 	// Parse services and extract port configurations
@@ -1058,8 +1187,7 @@ func (sm *ServiceManager) GenerateAutoPortConfig(composeFilePath, outputPath str
 		configs[serviceName] = config
 	}
 
-	// Generate Go file
-	return sm.generateAutoPortGoFile(configs, portMappings, outputPath)
+	return configs, portMappings, nil
 }
 
 // parsePortMapping parses a Docker port mapping string like "8080:80" or "8080:80/tcp"
@@ -1,6 +1,9 @@
 package servicemanager
 
 import (
+	"context"
+	"fmt"
+	"net"
 	"testing"
 	"time"
 )
@@ -394,3 +397,45 @@ func TestPortRange(t *testing.T) {
 		t.Errorf("Expected End 4000, got %d", portRange.End)
 	}
 }
+
+func TestDiscoverServicesStream(t *testing.T) {
+	// Two listeners on adjacent ports keep the scanned range small
+	first, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	port := first.Addr().(*net.TCPAddr).Port
+	second, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port+1))
+	if err != nil {
+		t.Skipf("Port %d is unavailable: %v", port+1, err)
+	}
+	defer second.Close()
+
+	sm := NewSimple()
+	sm.SetPortRange(port, port+1)
+
+	var ports []int
+	for service := range sm.DiscoverServicesStream(context.Background()) {
+		ports = append(ports, service.ExternalPort)
+	}
+	if len(ports) != 2 || ports[0] != port || ports[1] != port+1 {
+		t.Errorf("Expected ports %d and %d in order, got %v", port, port+1, ports)
+	}
+
+	// Breaking out of the loop stops the scan
+	ports = nil
+	for service := range sm.DiscoverServicesStream(context.Background()) {
+		ports = append(ports, service.ExternalPort)
+		break
+	}
+	if len(ports) != 1 {
+		t.Errorf("Expected one service before break, got %v", ports)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for service := range sm.DiscoverServicesStream(ctx) {
+		t.Errorf("Expected no services after cancellation, got port %d", service.ExternalPort)
+	}
+}
@@ -0,0 +1,121 @@
+package servicemanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// statsTimeout bounds collecting one container's stats; Docker waits about
+// a second between the two CPU samples it reports
+const statsTimeout = 5 * time.Second
+
+// ContainerStats is a Docker container's resource usage when it was
+// discovered, as docker stats reports it
+type ContainerStats struct {
+	CPUPercent    float64 `json:"cpu_percent"`        // Percent of one CPU, so 200 is two busy cores
+	MemoryBytes   uint64  `json:"memory_bytes"`       // Usage without the page cache
+	MemoryLimit   uint64  `json:"memory_limit_bytes"` // Container limit, or the host's memory without one
+	MemoryPercent float64 `json:"memory_percent"`
+	RestartCount  int     `json:"restart_count"`
+}
+
+// WithStats collects CPU, memory, and restart counts of Docker services
+// into ServiceInfo.Stats. It adds about a second per running container to
+// discovery, which is why it is off by default.
+func WithStats() ManagerOption {
+	return func(sm *ServiceManager) {
+		sm.collectStats = true
+	}
+}
+
+// containerStats returns a running container's resource usage, or nil if
+// it can't be read
+func (sm *ServiceManager) containerStats(containerID string) *ContainerStats {
+	if !sm.collectStats || !sm.IsDockerAvailable() {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), statsTimeout)
+	defer cancel()
+
+	reader, err := sm.dockerConfig.Client.ContainerStats(ctx, containerID, false)
+	if err != nil {
+		return nil
+	}
+	defer reader.Body.Close()
+
+	var response container.StatsResponse
+	if err := json.NewDecoder(reader.Body).Decode(&response); err != nil {
+		return nil
+	}
+
+	restarts := 0
+	if inspect, err := sm.dockerConfig.Client.ContainerInspect(ctx, containerID); err == nil && inspect.ContainerJSONBase != nil {
+		restarts = inspect.RestartCount
+	}
+	return newContainerStats(response, restarts)
+}
+
+// newContainerStats computes usage the way the docker CLI does: CPU from the
+// change since the previous sample, memory without inactive page cache
+func newContainerStats(response container.StatsResponse, restarts int) *ContainerStats {
+	stats := &ContainerStats{RestartCount: restarts, MemoryLimit: response.MemoryStats.Limit}
+
+	cpuDelta := float64(response.CPUStats.CPUUsage.TotalUsage) - float64(response.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(response.CPUStats.SystemUsage) - float64(response.PreCPUStats.SystemUsage)
+	cpus := float64(response.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(response.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta > 0 && systemDelta > 0 {
+		stats.CPUPercent = cpuDelta / systemDelta * cpus * 100
+	}
+
+	stats.MemoryBytes = response.MemoryStats.Usage
+	// cgroup v2 reports inactive_file, v1 total_inactive_file
+	for _, key := range []string{"inactive_file", "total_inactive_file"} {
+		if cache, ok := response.MemoryStats.Stats[key]; ok && cache < stats.MemoryBytes {
+			stats.MemoryBytes -= cache
+			break
+		}
+	}
+	if stats.MemoryLimit > 0 {
+		stats.MemoryPercent = float64(stats.MemoryBytes) / float64(stats.MemoryLimit) * 100
+	}
+	return stats
+}
+
+// String summarizes the usage on one line, e.g.
+// "CPU 152.3% • Memory 1.2GiB / 2.0GiB (60%) • 3 restarts"
+func (s *ContainerStats) String() string {
+	summary := fmt.Sprintf("CPU %.1f%% • Memory %s", s.CPUPercent, formatBytes(s.MemoryBytes))
+	if s.MemoryLimit > 0 {
+		summary += fmt.Sprintf(" / %s (%.0f%%)", formatBytes(s.MemoryLimit), s.MemoryPercent)
+	}
+	switch s.RestartCount {
+	case 0:
+	case 1:
+		summary += " • 1 restart"
+	default:
+		summary += fmt.Sprintf(" • %d restarts", s.RestartCount)
+	}
+	return summary
+}
+
+// formatBytes formats a byte count with binary units
+func formatBytes(bytes uint64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%dB", bytes)
+	}
+	value, exp := float64(bytes)/unit, 0
+	for value >= unit && exp < 4 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", value, "KMGTP"[exp])
+}
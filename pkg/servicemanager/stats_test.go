@@ -0,0 +1,45 @@
+package servicemanager
+
+import (
+	"math"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestNewContainerStats(t *testing.T) {
+	var response container.StatsResponse
+	response.PreCPUStats.CPUUsage.TotalUsage = 1_000_000
+	response.PreCPUStats.SystemUsage = 10_000_000
+	response.CPUStats.CPUUsage.TotalUsage = 4_000_000
+	response.CPUStats.SystemUsage = 20_000_000
+	response.CPUStats.OnlineCPUs = 4
+	response.MemoryStats.Usage = 600 << 20
+	response.MemoryStats.Limit = 2 << 30
+	response.MemoryStats.Stats = map[string]uint64{"inactive_file": 88 << 20}
+
+	stats := newContainerStats(response, 3)
+	if math.Abs(stats.CPUPercent-120) > 0.001 {
+		t.Errorf("Expected 120%% CPU (30%% of 4 CPUs), got %.3f", stats.CPUPercent)
+	}
+	if stats.MemoryBytes != 512<<20 || stats.MemoryLimit != 2<<30 || stats.MemoryPercent != 25 {
+		t.Errorf("Expected 512MiB of 2GiB without the page cache, got %+v", stats)
+	}
+	if got, want := stats.String(), "CPU 120.0% • Memory 512.0MiB / 2.0GiB (25%) • 3 restarts"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	// cgroup v1 without a previous sample or online CPU count
+	response.PreCPUStats = container.CPUStats{}
+	response.CPUStats.OnlineCPUs = 0
+	response.CPUStats.CPUUsage.PercpuUsage = []uint64{1, 2}
+	response.MemoryStats.Stats = map[string]uint64{"total_inactive_file": 100 << 20}
+	response.MemoryStats.Limit = 0
+	stats = newContainerStats(response, 0)
+	if math.Abs(stats.CPUPercent-40) > 0.001 || stats.MemoryBytes != 500<<20 || stats.MemoryPercent != 0 {
+		t.Errorf("Unexpected cgroup v1 stats %+v", stats)
+	}
+	if got, want := stats.String(), "CPU 40.0% • Memory 500.0MiB"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
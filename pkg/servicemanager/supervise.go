@@ -0,0 +1,406 @@
+package servicemanager
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Supervisor defaults, used for zero ProcessSpec fields
+const (
+	DefaultMinBackoff  = 500 * time.Millisecond
+	DefaultMaxBackoff  = 30 * time.Second
+	DefaultStableAfter = 10 * time.Second
+	DefaultStopTimeout = 5 * time.Second
+)
+
+// ErrAlreadySupervised is returned by Supervise for a port that already
+// has a supervised process
+var ErrAlreadySupervised = errors.New("port is already supervised")
+
+// ProcessSpec describes a local process for Supervise to run, like a
+// Procfile entry that also knows its port
+type ProcessSpec struct {
+	Name      string   // Service name in discovery (default: the command's base name)
+	Command   string   // Executable, looked up in PATH
+	Args      []string // Arguments after the command
+	Dir       string   // Working directory (default: the current one)
+	Env       []string // KEY=value added to the environment; PORT is set to Port
+	Port      int      // Port the process listens on
+	HealthURL string   // For CheckHealth, e.g. http://localhost/health
+
+	// A crashed process is restarted after MinBackoff, doubling with each
+	// consecutive crash up to MaxBackoff. Once a process has run for
+	// StableAfter its next crash starts over at MinBackoff. MaxRestarts
+	// gives up after that many consecutive crashes (0 = never).
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	StableAfter time.Duration
+	MaxRestarts int
+
+	// StopTimeout is how long Stop and Restart wait after SIGTERM before
+	// killing the process (default DefaultStopTimeout)
+	StopTimeout time.Duration
+
+	// Output of the process (nil = discarded)
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// SupervisorState is the lifecycle state of a supervised process
+type SupervisorState string
+
+const (
+	SupervisorRunning SupervisorState = "running"
+	SupervisorBackoff SupervisorState = "backoff" // Exited; waiting to restart
+	SupervisorFailed  SupervisorState = "failed"  // Gave up after MaxRestarts
+	SupervisorStopped SupervisorState = "stopped"
+)
+
+// SupervisedStatus describes a supervised process, as ServiceInfo.Supervised
+// of its port
+type SupervisedStatus struct {
+	State       SupervisorState `json:"state"`
+	PID         int             `json:"pid,omitempty"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	Restarts    int             `json:"restarts"`            // Since Supervise
+	Crashes     int             `json:"crashes"`             // Consecutive, reset once the process is stable
+	LastExit    string          `json:"last_exit,omitempty"` // E.g. "exit status 1"
+	LastExitAt  *time.Time      `json:"last_exit_at,omitempty"`
+	NextRestart *time.Time      `json:"next_restart,omitempty"` // While backing off
+}
+
+// Supervisor runs a local process, restarting it whenever it exits
+// without Stop being called, with exponential backoff between crashes
+type Supervisor struct {
+	spec    ProcessSpec
+	sm      *ServiceManager
+	restart chan struct{} // Restart requests
+	stop    chan struct{} // Closed by Stop
+	done    chan struct{} // Closed when the process is gone for good
+
+	mutex    sync.Mutex
+	status   SupervisedStatus
+	stopOnce sync.Once
+}
+
+// supervisorSet holds the supervisors of a ServiceManager by port
+type supervisorSet struct {
+	mutex sync.Mutex
+	ports map[int]*Supervisor
+}
+
+// Supervise starts a local process and keeps it running: when it exits
+// without Stop being called it is restarted with exponential backoff (see
+// ProcessSpec). Discovery and status name the service on its port after
+// spec.Name and describe the process in ServiceInfo.Supervised, also while
+// it is down, and RestartService restarts it. A minimal foreman:
+//
+//	api, err := sm.Supervise(servicemanager.ProcessSpec{
+//	    Name:    "api",
+//	    Command: "go",
+//	    Args:    []string{"run", "./cmd/api"},
+//	    Port:    8080,
+//	})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer api.Stop()
+//
+// The port must be free; a process that can't start is treated as a crash.
+func (sm *ServiceManager) Supervise(spec ProcessSpec) (*Supervisor, error) {
+	if spec.Command == "" {
+		return nil, fmt.Errorf("supervised process needs a command")
+	}
+	if spec.Port <= 0 || spec.Port > 65535 {
+		return nil, fmt.Errorf("invalid port %d", spec.Port)
+	}
+	if spec.Name == "" {
+		spec.Name = filepath.Base(spec.Command)
+	}
+	if spec.MinBackoff <= 0 {
+		spec.MinBackoff = DefaultMinBackoff
+	}
+	if spec.MaxBackoff <= 0 {
+		spec.MaxBackoff = DefaultMaxBackoff
+	}
+	if spec.MaxBackoff < spec.MinBackoff {
+		spec.MaxBackoff = spec.MinBackoff
+	}
+	if spec.StableAfter <= 0 {
+		spec.StableAfter = DefaultStableAfter
+	}
+	if spec.StopTimeout <= 0 {
+		spec.StopTimeout = DefaultStopTimeout
+	}
+
+	sm.supervisors.mutex.Lock()
+	defer sm.supervisors.mutex.Unlock()
+	if _, taken := sm.supervisors.ports[spec.Port]; taken {
+		return nil, fmt.Errorf("%w: %d", ErrAlreadySupervised, spec.Port)
+	}
+	if sm.isPortListening(spec.Port) {
+		return nil, fmt.Errorf("port %d is already in use", spec.Port)
+	}
+
+	s := &Supervisor{
+		spec:    spec,
+		sm:      sm,
+		restart: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if sm.supervisors.ports == nil {
+		sm.supervisors.ports = make(map[int]*Supervisor)
+	}
+	sm.supervisors.ports[spec.Port] = s
+	go s.run()
+	return s, nil
+}
+
+// Supervisors returns the supervised processes by port
+func (sm *ServiceManager) Supervisors() []*Supervisor {
+	sm.supervisors.mutex.Lock()
+	defer sm.supervisors.mutex.Unlock()
+	supervisors := make([]*Supervisor, 0, len(sm.supervisors.ports))
+	for _, s := range sm.supervisors.ports {
+		supervisors = append(supervisors, s)
+	}
+	sort.Slice(supervisors, func(i, j int) bool { return supervisors[i].spec.Port < supervisors[j].spec.Port })
+	return supervisors
+}
+
+// supervisor returns the supervisor of a port, or nil
+func (sm *ServiceManager) supervisor(port int) *Supervisor {
+	sm.supervisors.mutex.Lock()
+	defer sm.supervisors.mutex.Unlock()
+	return sm.supervisors.ports[port]
+}
+
+// Name returns the service name of the process
+func (s *Supervisor) Name() string {
+	return s.spec.Name
+}
+
+// Port returns the port the process listens on
+func (s *Supervisor) Port() int {
+	return s.spec.Port
+}
+
+// Status returns the current state of the process
+func (s *Supervisor) Status() SupervisedStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.status
+}
+
+// Restart stops the process and starts it again right away, also while it
+// is backing off. It returns without waiting for the new process.
+func (s *Supervisor) Restart() {
+	select {
+	case s.restart <- struct{}{}:
+	default: // A restart is already pending
+	}
+}
+
+// Stop terminates the process (SIGTERM, then SIGKILL after StopTimeout),
+// waits for it to exit, and stops supervising its port. Stopping twice is
+// harmless.
+func (s *Supervisor) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.done
+
+	s.sm.supervisors.mutex.Lock()
+	if s.sm.supervisors.ports[s.spec.Port] == s {
+		delete(s.sm.supervisors.ports, s.spec.Port)
+	}
+	s.sm.supervisors.mutex.Unlock()
+}
+
+// Done is closed once the process is gone for good: after Stop, or when
+// the supervisor gave up after MaxRestarts
+func (s *Supervisor) Done() <-chan struct{} {
+	return s.done
+}
+
+// run starts the process and restarts it until Stop or MaxRestarts
+func (s *Supervisor) run() {
+	defer close(s.done)
+	backoff := s.spec.MinBackoff
+
+	for {
+		started := time.Now()
+		cmd := s.command()
+		exited := make(chan error, 1)
+		err := cmd.Start()
+		if err == nil {
+			s.update(func(status *SupervisedStatus) {
+				status.State = SupervisorRunning
+				status.PID = cmd.Process.Pid
+				status.StartedAt = &started
+				status.NextRestart = nil
+			})
+			go func() { exited <- cmd.Wait() }()
+		} else {
+			exited <- err
+		}
+
+		select {
+		case err = <-exited:
+		case <-s.restart:
+			s.terminate(cmd, exited)
+			s.exited(fmt.Errorf("restarted"), false)
+			s.update(func(status *SupervisedStatus) { status.Restarts++ })
+			backoff = s.spec.MinBackoff
+			continue
+		case <-s.stop:
+			s.terminate(cmd, exited)
+			s.exited(fmt.Errorf("stopped"), false)
+			s.update(func(status *SupervisedStatus) { status.State = SupervisorStopped })
+			return
+		}
+
+		// Exited on its own: a crash, unless it had been running stably
+		if time.Since(started) >= s.spec.StableAfter {
+			backoff = s.spec.MinBackoff
+			s.update(func(status *SupervisedStatus) { status.Crashes = 0 })
+		}
+		if err == nil {
+			err = fmt.Errorf("exit status 0")
+		}
+		crashes := s.exited(err, true)
+		if s.spec.MaxRestarts > 0 && crashes > s.spec.MaxRestarts {
+			s.update(func(status *SupervisedStatus) { status.State = SupervisorFailed })
+			return
+		}
+
+		next := time.Now().Add(backoff)
+		s.update(func(status *SupervisedStatus) {
+			status.State = SupervisorBackoff
+			status.NextRestart = &next
+		})
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+			backoff = min(backoff*2, s.spec.MaxBackoff)
+		case <-s.restart:
+			timer.Stop()
+			backoff = s.spec.MinBackoff
+		case <-s.stop:
+			timer.Stop()
+			s.update(func(status *SupervisedStatus) {
+				status.State = SupervisorStopped
+				status.NextRestart = nil
+			})
+			return
+		}
+		s.update(func(status *SupervisedStatus) { status.Restarts++ })
+	}
+}
+
+// command builds the process to start
+func (s *Supervisor) command() *exec.Cmd {
+	cmd := exec.Command(s.spec.Command, s.spec.Args...)
+	cmd.Dir = s.spec.Dir
+	cmd.Env = append(os.Environ(), s.spec.Env...)
+	cmd.Env = append(cmd.Env, "PORT="+strconv.Itoa(s.spec.Port))
+	cmd.Stdout = s.spec.Stdout
+	cmd.Stderr = s.spec.Stderr
+	setProcessGroup(cmd)
+	return cmd
+}
+
+// terminate stops a running process and its children, killing them if
+// they don't exit within StopTimeout
+func (s *Supervisor) terminate(cmd *exec.Cmd, exited <-chan error) {
+	if cmd.Process == nil {
+		return
+	}
+	signalProcessGroup(cmd, false)
+	timer := time.NewTimer(s.spec.StopTimeout)
+	defer timer.Stop()
+	select {
+	case <-exited:
+	case <-timer.C:
+		signalProcessGroup(cmd, true)
+		<-exited
+	}
+}
+
+// exited records the end of a process, counting a crash if crashed, and
+// returns the consecutive crashes
+func (s *Supervisor) exited(err error, crashed bool) int {
+	now := time.Now()
+	var crashes int
+	s.update(func(status *SupervisedStatus) {
+		status.PID = 0
+		status.StartedAt = nil
+		status.LastExit = err.Error()
+		status.LastExitAt = &now
+		if crashed {
+			status.Crashes++
+		}
+		crashes = status.Crashes
+	})
+	return crashes
+}
+
+// update changes the status under the lock
+func (s *Supervisor) update(change func(status *SupervisedStatus)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	change(&s.status)
+}
+
+// supervisedService names a supervised port's service after its spec and
+// adds the supervisor's status
+func (sm *ServiceManager) supervisedService(service ServiceInfo) ServiceInfo {
+	s := sm.supervisor(service.ExternalPort)
+	if s == nil {
+		return service
+	}
+	status := s.Status()
+	service.Supervised = &status
+	// The port was free when the supervisor started, so a local listener
+	// is the process or a child of it (go run, npm start)
+	if service.Type == ServiceTypeLocalProcess && status.State == SupervisorRunning {
+		service.Name = s.spec.Name
+		if service.HealthURL == "" {
+			service.HealthURL = s.spec.HealthURL
+		}
+	}
+	return service
+}
+
+// downSupervisedServices describes the supervised processes that aren't
+// listening, or listen outside the port range, skipping the ports in seen
+func (sm *ServiceManager) downSupervisedServices(seen map[int]bool) []ServiceInfo {
+	var services []ServiceInfo
+	for _, s := range sm.Supervisors() {
+		port := s.spec.Port
+		if seen[port] {
+			continue
+		}
+		service := ServiceInfo{
+			Name:         s.spec.Name,
+			Type:         ServiceTypeLocalProcess,
+			ExternalPort: port,
+			InternalPort: port,
+			Command:      filepath.Base(s.spec.Command),
+		}
+		if sm.isPortListening(port) {
+			service = sm.getLocalProcessInfo(port)
+		} else {
+			service.Status = string(s.Status().State)
+		}
+		services = append(services, service)
+	}
+	return services
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package servicemanager
+
+import "os/exec"
+
+// setProcessGroup does nothing; this platform lacks process groups
+func setProcessGroup(*exec.Cmd) {}
+
+// signalProcessGroup kills a started process. Without SIGTERM there is no
+// graceful stop, so kill is ignored.
+func signalProcessGroup(cmd *exec.Cmd, kill bool) {
+	cmd.Process.Kill()
+}
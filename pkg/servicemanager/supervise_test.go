@@ -0,0 +1,176 @@
+package servicemanager
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestSupervisedHelper is the process the supervisor tests run: the test
+// binary itself, listening on $PORT or crashing as SUPERVISE_HELPER says
+func TestSupervisedHelper(t *testing.T) {
+	switch os.Getenv("SUPERVISE_HELPER") {
+	case "":
+		t.Skip("helper process for the supervisor tests")
+	case "crash":
+		os.Exit(1)
+	case "listen":
+		http.ListenAndServe("127.0.0.1:"+os.Getenv("PORT"), http.NotFoundHandler())
+		os.Exit(2)
+	}
+}
+
+// helperSpec runs TestSupervisedHelper in the given mode on a free port
+func helperSpec(t *testing.T, mode string) ProcessSpec {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	return ProcessSpec{
+		Name:        "helper",
+		Command:     os.Args[0],
+		Args:        []string{"-test.run=^TestSupervisedHelper$"},
+		Env:         []string{"SUPERVISE_HELPER=" + mode},
+		Port:        port,
+		HealthURL:   "http://localhost/health",
+		MinBackoff:  10 * time.Millisecond,
+		MaxBackoff:  40 * time.Millisecond,
+		StopTimeout: time.Second,
+	}
+}
+
+// waitFor polls condition until it holds or a few seconds have passed
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// findService returns the discovered service on a port
+func findService(t *testing.T, sm *ServiceManager, port int) *ServiceInfo {
+	t.Helper()
+	services, err := sm.DiscoverAllServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, service := range services {
+		if service.ExternalPort == port {
+			return &service
+		}
+	}
+	return nil
+}
+
+func TestSuperviseRestartsWithBackoff(t *testing.T) {
+	spec := helperSpec(t, "crash")
+	spec.MaxRestarts = 3
+	sm := NewSimple(WithPortRange(spec.Port, spec.Port))
+
+	s, err := sm.Supervise(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	select {
+	case <-s.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Expected the supervisor to give up, got %+v", s.Status())
+	}
+	status := s.Status()
+	if status.State != SupervisorFailed || status.Restarts != 3 || status.Crashes != 4 {
+		t.Errorf("Expected failed after 3 restarts and 4 crashes, got %+v", status)
+	}
+	if status.LastExit != "exit status 1" || status.LastExitAt == nil {
+		t.Errorf("Expected the last exit to be recorded, got %+v", status)
+	}
+
+	// Still listed while down
+	service := findService(t, sm, spec.Port)
+	if service == nil {
+		t.Fatal("Expected the failed process in discovery")
+	}
+	if service.Name != "helper" || service.Status != string(SupervisorFailed) || service.Supervised == nil {
+		t.Errorf("Expected a failed helper service, got %+v", service)
+	}
+}
+
+func TestSuperviseDiscoveryRestartAndStop(t *testing.T) {
+	spec := helperSpec(t, "listen")
+	sm := NewSimple(WithPortRange(spec.Port, spec.Port))
+
+	s, err := sm.Supervise(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+	if _, err := sm.Supervise(spec); !errors.Is(err, ErrAlreadySupervised) {
+		t.Errorf("Expected ErrAlreadySupervised, got %v", err)
+	}
+	waitFor(t, "the helper to listen", func() bool { return sm.isPortListening(spec.Port) })
+
+	service := findService(t, sm, spec.Port)
+	if service == nil || service.Name != "helper" || service.Supervised == nil || service.Supervised.State != SupervisorRunning {
+		t.Fatalf("Expected the running helper in discovery, got %+v", service)
+	}
+	if service.HealthURL != "http://localhost/health" {
+		t.Errorf("Expected the spec's health URL, got %q", service.HealthURL)
+	}
+
+	pid := s.Status().PID
+	if err := sm.RestartService(spec.Port); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "a new process", func() bool {
+		status := s.Status()
+		return status.State == SupervisorRunning && status.PID != 0 && status.PID != pid
+	})
+	if status := s.Status(); status.Restarts != 1 || status.Crashes != 0 {
+		t.Errorf("Expected one restart and no crashes, got %+v", status)
+	}
+
+	s.Stop()
+	if state := s.Status().State; state != SupervisorStopped {
+		t.Errorf("Expected stopped, got %s", state)
+	}
+	if len(sm.Supervisors()) != 0 {
+		t.Error("Expected Stop to end supervision of the port")
+	}
+	waitFor(t, "the port to be free", func() bool { return !sm.isPortListening(spec.Port) })
+}
+
+func TestSuperviseValidation(t *testing.T) {
+	sm := NewSimple()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	busy := listener.Addr().(*net.TCPAddr).Port
+
+	for _, spec := range []ProcessSpec{
+		{Port: 8080},
+		{Command: "true"},
+		{Command: "true", Port: 70000},
+		{Command: "true", Port: busy},
+	} {
+		if _, err := sm.Supervise(spec); err == nil {
+			t.Errorf("Expected %+v to be rejected", spec)
+		}
+	}
+	if len(sm.Supervisors()) != 0 {
+		t.Error("Expected no supervisors after rejected specs")
+	}
+}
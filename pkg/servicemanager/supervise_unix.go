@@ -0,0 +1,24 @@
+//go:build unix
+
+package servicemanager
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the process in its own process group, so that
+// stopping it also stops the children it spawned (go run, npm start)
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalProcessGroup sends SIGTERM, or SIGKILL with kill, to the process
+// group of a started process
+func signalProcessGroup(cmd *exec.Cmd, kill bool) {
+	signal := syscall.SIGTERM
+	if kill {
+		signal = syscall.SIGKILL
+	}
+	syscall.Kill(-cmd.Process.Pid, signal)
+}
@@ -0,0 +1,478 @@
+package servicemanager
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/nzions/sharedgolibs/pkg/autoport"
+)
+
+// composeDependsOnLabel lists a compose service's dependencies as
+// "name:condition:restart" entries separated by commas
+const composeDependsOnLabel = "com.docker.compose.depends_on"
+
+// Kinds of TopologyEdge
+const (
+	EdgeDependsOn  = "depends_on" // Declared in autoport or docker compose
+	EdgeEnv        = "env"        // An environment variable addresses the other service
+	EdgeConnection = "connection" // An established TCP connection was sampled, see WithConnections
+)
+
+// TopologyNode is a declared service or a Docker container
+type TopologyNode struct {
+	Name        string   `json:"name"`
+	Image       string   `json:"image,omitempty"`
+	ContainerID string   `json:"container_id,omitempty"`
+	Port        int      `json:"port,omitempty"` // External port
+	Status      string   `json:"status"`         // Container state, "missing" for an expected service without a container, "unknown" for an undeclared dependency, "external" for an address outside the stack
+	IsExpected  bool     `json:"is_expected"`    // Declared in autoport
+	Networks    []string `json:"networks,omitempty"`
+	Aliases     []string `json:"aliases,omitempty"`
+	IPAddress   string   `json:"ip_address,omitempty"`
+}
+
+// TopologyNetwork is a Docker network and the nodes attached to it
+type TopologyNetwork struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+}
+
+// TopologyEdge is a directed "From talks to To" relationship
+type TopologyEdge struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Kind   string `json:"kind"`
+	Detail string `json:"detail,omitempty"` // The environment variable for EdgeEnv, the remote address for EdgeConnection to a service
+}
+
+// NetworkTopology is a graph of the dev stack: which services exist, which
+// networks they share, and which service talks to which
+type NetworkTopology struct {
+	Nodes    []TopologyNode    `json:"nodes"`
+	Networks []TopologyNetwork `json:"networks"`
+	Edges    []TopologyEdge    `json:"edges"`
+}
+
+// GetNetworkTopology builds the topology from the autoport configuration
+// and, when Docker is available, every container's networks, aliases,
+// compose dependencies, and allowlisted environment. Without Docker only
+// the declared services and dependencies are included. WithConnections adds
+// the connections sampled between them.
+func (sm *ServiceManager) GetNetworkTopology() (*NetworkTopology, error) {
+	return sm.networkTopology(sm.connections)
+}
+
+// networkTopology builds the topology, sampling established connections
+// of this machine and its containers if connections is set
+func (sm *ServiceManager) networkTopology(connections bool) (*NetworkTopology, error) {
+	if connections && sm.isRemoteHost() {
+		return nil, fmt.Errorf("connections can only be sampled on this machine, not %s", sm.GetHostAddress())
+	}
+
+	builder := newTopologyBuilder()
+	for _, service := range autoport.GetConfiguration().Services {
+		builder.addDeclared(service)
+	}
+
+	if sm.IsDockerAvailable() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		containers, err := sm.dockerConfig.Client.ContainerList(ctx, container.ListOptions{All: true})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list Docker containers: %w", err)
+		}
+		for _, c := range containers {
+			name := builder.addContainer(c, sm.containerEnv(c.ID))
+			if connections && c.State == "running" {
+				builder.addConnections(name, sm.containerConnections(c.ID))
+			}
+		}
+	}
+
+	if connections {
+		hostConnections, err := SampleConnections()
+		if err != nil {
+			return nil, fmt.Errorf("failed to sample connections: %w", err)
+		}
+		listening, err := ResolveListeningPorts()
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve listening ports: %w", err)
+		}
+		builder.addHostConnections(hostConnections, listening, localAddresses())
+	}
+
+	return builder.build(), nil
+}
+
+// DOT renders the topology as a Graphviz digraph. Networks are ellipses
+// joined to their members by dotted lines; dependencies are solid arrows
+// and environment references dashed arrows labelled with the variable;
+// sampled connections are bold arrows labelled with the remote address.
+func (t *NetworkTopology) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph topology {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	for _, node := range t.Nodes {
+		style := ""
+		if node.Status != "running" && node.Status != "external" {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(&b, "\t%q [label=%q%s];\n", node.Name, node.label(), style)
+	}
+	for _, network := range t.Networks {
+		fmt.Fprintf(&b, "\t%q [label=%q, shape=ellipse, style=dotted];\n", "network:"+network.Name, network.Name)
+		for _, member := range network.Members {
+			fmt.Fprintf(&b, "\t%q -> %q [arrowhead=none, style=dotted];\n", member, "network:"+network.Name)
+		}
+	}
+	for _, edge := range t.Edges {
+		switch {
+		case edge.Kind == EdgeEnv:
+			fmt.Fprintf(&b, "\t%q -> %q [label=%q, style=dashed];\n", edge.From, edge.To, edge.Detail)
+		case edge.Kind == EdgeConnection && edge.Detail != "":
+			fmt.Fprintf(&b, "\t%q -> %q [label=%q, style=bold];\n", edge.From, edge.To, edge.Detail)
+		case edge.Kind == EdgeConnection:
+			fmt.Fprintf(&b, "\t%q -> %q [style=bold];\n", edge.From, edge.To)
+		default:
+			fmt.Fprintf(&b, "\t%q -> %q;\n", edge.From, edge.To)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Mermaid renders the topology as a mermaid flowchart, with the same
+// conventions as DOT
+func (t *NetworkTopology) Mermaid() string {
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	ids := make(map[string]string, len(t.Nodes))
+	for i, node := range t.Nodes {
+		ids[node.Name] = fmt.Sprintf("n%d", i)
+		fmt.Fprintf(&b, "    %s[\"%s\"]\n", ids[node.Name], mermaidEscape(node.label()))
+	}
+	for i, network := range t.Networks {
+		id := fmt.Sprintf("net%d", i)
+		fmt.Fprintf(&b, "    %s((\"%s\"))\n", id, mermaidEscape(network.Name))
+		for _, member := range network.Members {
+			fmt.Fprintf(&b, "    %s -.- %s\n", ids[member], id)
+		}
+	}
+	for _, edge := range t.Edges {
+		switch {
+		case edge.Kind == EdgeEnv:
+			fmt.Fprintf(&b, "    %s -.->|%s| %s\n", ids[edge.From], mermaidEscape(edge.Detail), ids[edge.To])
+		case edge.Kind == EdgeConnection && edge.Detail != "":
+			fmt.Fprintf(&b, "    %s ==>|%s| %s\n", ids[edge.From], mermaidEscape(edge.Detail), ids[edge.To])
+		case edge.Kind == EdgeConnection:
+			fmt.Fprintf(&b, "    %s ==> %s\n", ids[edge.From], ids[edge.To])
+		default:
+			fmt.Fprintf(&b, "    %s --> %s\n", ids[edge.From], ids[edge.To])
+		}
+	}
+	return b.String()
+}
+
+// label is a node's display text: its name, port, and status if not running
+func (n TopologyNode) label() string {
+	label := n.Name
+	if n.Port != 0 {
+		label += fmt.Sprintf(" :%d", n.Port)
+	}
+	if n.Status != "running" && n.Status != "external" {
+		label += " (" + n.Status + ")"
+	}
+	return label
+}
+
+// mermaidEscape makes text safe inside a quoted mermaid label
+func mermaidEscape(text string) string {
+	return strings.NewReplacer(`"`, "#quot;", "|", "#124;").Replace(text)
+}
+
+// topologyBuilder accumulates nodes, networks, and edges from each source
+type topologyBuilder struct {
+	nodes    map[string]*TopologyNode
+	networks map[string]map[string]bool
+	edges    map[TopologyEdge]bool
+	env      map[string]map[string]string
+
+	// Sampled connections, see addConnections and addHostConnections
+	connections     map[string][]TCPConnection
+	hostConnections []TCPConnection
+	listening       map[int]ListeningProcess
+	localAddresses  map[netip.Addr]bool
+}
+
+func newTopologyBuilder() *topologyBuilder {
+	return &topologyBuilder{
+		nodes:    make(map[string]*TopologyNode),
+		networks: make(map[string]map[string]bool),
+		edges:    make(map[TopologyEdge]bool),
+		env:      make(map[string]map[string]string),
+
+		connections: make(map[string][]TCPConnection),
+	}
+}
+
+// node returns the node with a name, creating it if needed
+func (b *topologyBuilder) node(name string) *TopologyNode {
+	if node, exists := b.nodes[name]; exists {
+		return node
+	}
+	node := &TopologyNode{Name: name}
+	b.nodes[name] = node
+	return node
+}
+
+// addDeclared adds an autoport service and its declared dependencies
+func (b *topologyBuilder) addDeclared(service autoport.ServiceConfig) {
+	node := b.node(service.Name)
+	node.IsExpected = true
+	node.Image = service.Image
+	node.Port = service.ExternalPort
+	node.IPAddress = service.IPAddress
+	node.Aliases = append(node.Aliases, service.Aliases...)
+	for _, dep := range service.DependsOn {
+		b.edges[TopologyEdge{From: service.Name, To: dep, Kind: EdgeDependsOn}] = true
+	}
+}
+
+// addContainer adds a container, named after its compose service so it
+// merges with the autoport declaration, along with its networks, aliases,
+// compose dependencies, and environment. It returns the node's name.
+func (b *topologyBuilder) addContainer(c container.Summary, env map[string]string) string {
+	containerName := c.ID
+	if len(containerName) > 12 {
+		containerName = containerName[:12]
+	}
+	if len(c.Names) > 0 {
+		containerName = strings.TrimPrefix(c.Names[0], "/")
+	}
+	name := c.Labels[composeServiceLabel]
+	if existing, exists := b.nodes[name]; name == "" || exists && existing.ContainerID != "" {
+		// Not a compose service, or a second project with the same service
+		name = containerName
+	}
+
+	node := b.node(name)
+	node.ContainerID = c.ID
+	if len(node.ContainerID) > 12 {
+		node.ContainerID = node.ContainerID[:12]
+	}
+	node.Image = c.Image
+	node.Status = string(c.State)
+	for _, port := range c.Ports {
+		if node.Port == 0 && port.PublicPort != 0 {
+			node.Port = int(port.PublicPort)
+		}
+	}
+	if containerName != name {
+		node.Aliases = append(node.Aliases, containerName)
+	}
+
+	if c.NetworkSettings != nil {
+		for networkName, endpoint := range c.NetworkSettings.Networks {
+			if b.networks[networkName] == nil {
+				b.networks[networkName] = make(map[string]bool)
+			}
+			b.networks[networkName][name] = true
+			node.Networks = append(node.Networks, networkName)
+			if endpoint == nil {
+				continue
+			}
+			node.Aliases = append(node.Aliases, endpoint.Aliases...)
+			if node.IPAddress == "" {
+				node.IPAddress = endpoint.IPAddress
+			}
+		}
+	}
+
+	for _, dep := range parseComposeDependsOn(c.Labels[composeDependsOnLabel]) {
+		b.edges[TopologyEdge{From: name, To: dep, Kind: EdgeDependsOn}] = true
+	}
+	if len(env) > 0 {
+		b.env[name] = env
+	}
+	return name
+}
+
+// addConnections adds the connections sampled in a node's own network
+// namespace, such as a container's
+func (b *topologyBuilder) addConnections(name string, connections []TCPConnection) {
+	b.connections[name] = append(b.connections[name], connections...)
+}
+
+// addHostConnections adds the connections sampled on this machine. They
+// belong to the node whose port their process listens on, which is
+// resolved once every node is known.
+func (b *topologyBuilder) addHostConnections(connections []TCPConnection, listening map[int]ListeningProcess, localAddresses map[netip.Addr]bool) {
+	b.hostConnections = append(b.hostConnections, connections...)
+	b.listening = listening
+	b.localAddresses = localAddresses
+}
+
+// build resolves environment references and returns the sorted topology
+func (b *topologyBuilder) build() *NetworkTopology {
+	// Every name and alias a service can be reached by
+	hosts := make(map[string]string)
+	for name, node := range b.nodes {
+		for _, alias := range node.Aliases {
+			hosts[alias] = name
+		}
+	}
+	for name := range b.nodes {
+		hosts[name] = name
+	}
+	for from, env := range b.env {
+		for variable, value := range env {
+			if to, exists := hosts[envHost(value)]; exists && to != from {
+				b.edges[TopologyEdge{From: from, To: to, Kind: EdgeEnv, Detail: variable}] = true
+			}
+		}
+	}
+
+	b.resolveConnections()
+
+	topology := &NetworkTopology{
+		Nodes:    []TopologyNode{},
+		Networks: []TopologyNetwork{},
+		Edges:    []TopologyEdge{},
+	}
+	for edge := range b.edges {
+		b.node(edge.To) // Dependencies that aren't declared or running
+		topology.Edges = append(topology.Edges, edge)
+	}
+	for _, node := range b.nodes {
+		switch {
+		case node.Status != "":
+		case node.IsExpected:
+			node.Status = "missing"
+		default:
+			node.Status = "unknown"
+		}
+		node.Aliases = sortedUnique(node.Aliases, node.Name)
+		node.Networks = sortedUnique(node.Networks, "")
+		topology.Nodes = append(topology.Nodes, *node)
+	}
+	for name, members := range b.networks {
+		network := TopologyNetwork{Name: name}
+		for member := range members {
+			network.Members = append(network.Members, member)
+		}
+		sort.Strings(network.Members)
+		topology.Networks = append(topology.Networks, network)
+	}
+
+	sort.Slice(topology.Nodes, func(i, j int) bool { return topology.Nodes[i].Name < topology.Nodes[j].Name })
+	sort.Slice(topology.Networks, func(i, j int) bool { return topology.Networks[i].Name < topology.Networks[j].Name })
+	sort.Slice(topology.Edges, func(i, j int) bool {
+		a, b := topology.Edges[i], topology.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Detail < b.Detail
+	})
+	return topology
+}
+
+// resolveConnections turns each node's outbound connections into edges to
+// the node at the remote address: a container by its IP address, a
+// published or local port on this machine, or else the address itself as
+// an external node
+func (b *topologyBuilder) resolveConnections() {
+	owners := make(map[string]string) // Node by the PID listening on its port
+	byPort := make(map[uint16]string)
+	byIP := make(map[netip.Addr]string)
+	for name, node := range b.nodes {
+		if node.Port != 0 {
+			byPort[uint16(node.Port)] = name
+			if owner := b.listening[node.Port]; owner.PID != "" {
+				owners[owner.PID] = name
+			}
+		}
+		if ip, err := netip.ParseAddr(node.IPAddress); err == nil {
+			byIP[ip.Unmap()] = name
+		}
+	}
+	for _, connection := range b.hostConnections {
+		if from, exists := owners[connection.PID]; exists && connection.PID != "" {
+			b.connections[from] = append(b.connections[from], connection)
+		}
+	}
+
+	for from, connections := range b.connections {
+		for _, connection := range connections {
+			if !connection.Outbound {
+				continue
+			}
+			remote := connection.Remote
+			to, exists := byIP[remote.Addr()]
+			if !exists && (remote.Addr().IsLoopback() || b.localAddresses[remote.Addr()]) {
+				to, exists = byPort[remote.Port()]
+			}
+			if !exists {
+				// Outside the stack, e.g. production instead of the emulator
+				b.node(remote.String()).Status = "external"
+				b.edges[TopologyEdge{From: from, To: remote.String(), Kind: EdgeConnection}] = true
+				continue
+			}
+			if to != from {
+				b.edges[TopologyEdge{From: from, To: to, Kind: EdgeConnection, Detail: remote.String()}] = true
+			}
+		}
+	}
+}
+
+// parseComposeDependsOn parses the compose depends_on label, e.g.
+// "ca:service_started:false,metadata:service_healthy:true"
+func parseComposeDependsOn(label string) []string {
+	var deps []string
+	for _, entry := range strings.Split(label, ",") {
+		if name, _, _ := strings.Cut(strings.TrimSpace(entry), ":"); name != "" {
+			deps = append(deps, name)
+		}
+	}
+	return deps
+}
+
+// envHost extracts the host from an environment value such as a URL or
+// host:port, or returns the value itself
+func envHost(value string) string {
+	if strings.Contains(value, "://") {
+		if u, err := url.Parse(value); err == nil {
+			return u.Hostname()
+		}
+	}
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		return host
+	}
+	return value
+}
+
+// sortedUnique sorts values and drops duplicates and the excluded value
+func sortedUnique(values []string, exclude string) []string {
+	seen := make(map[string]bool, len(values))
+	var unique []string
+	for _, value := range values {
+		if value != "" && value != exclude && !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
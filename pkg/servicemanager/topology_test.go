@@ -0,0 +1,118 @@
+package servicemanager
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/nzions/sharedgolibs/pkg/autoport"
+)
+
+func testTopology() *NetworkTopology {
+	builder := newTopologyBuilder()
+	builder.addDeclared(autoport.ServiceConfig{Name: "api", Image: "example/api", ExternalPort: 8080, DependsOn: []string{"ca"}})
+	builder.addDeclared(autoport.ServiceConfig{Name: "ca", ExternalPort: 8090, Aliases: []string{"ca.local"}})
+	builder.addDeclared(autoport.ServiceConfig{Name: "gcs", ExternalPort: 4443})
+
+	builder.addContainer(container.Summary{
+		ID:     "0123456789abcdef",
+		Names:  []string{"/stack-api-1"},
+		Image:  "example/api:1.4",
+		State:  "running",
+		Ports:  []container.Port{{PublicPort: 8080, PrivatePort: 80}},
+		Labels: map[string]string{composeServiceLabel: "api", composeDependsOnLabel: "ca:service_started:false,metadata:service_healthy:true"},
+		NetworkSettings: &container.NetworkSettingsSummary{Networks: map[string]*network.EndpointSettings{
+			"stack_default": {IPAddress: "172.18.0.3"},
+		}},
+	}, map[string]string{"SGL_CA": "http://ca.local:8090", "SGL_PORT": "8080"})
+	builder.addContainer(container.Summary{
+		ID:     "fedcba9876543210",
+		Names:  []string{"/stack-ca-1"},
+		State:  "running",
+		Labels: map[string]string{composeServiceLabel: "ca"},
+		NetworkSettings: &container.NetworkSettingsSummary{Networks: map[string]*network.EndpointSettings{
+			"stack_default": {Aliases: []string{"ca", "pki"}},
+		}},
+	}, nil)
+	return builder.build()
+}
+
+func TestGetNetworkTopologyGraph(t *testing.T) {
+	topology := testTopology()
+
+	var names []string
+	for _, node := range topology.Nodes {
+		names = append(names, node.Name+"="+node.Status)
+	}
+	if want := []string{"api=running", "ca=running", "gcs=missing", "metadata=unknown"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Expected nodes %v, got %v", want, names)
+	}
+
+	api, ca := topology.Nodes[0], topology.Nodes[1]
+	if api.ContainerID != "0123456789ab" || api.Image != "example/api:1.4" || api.IPAddress != "172.18.0.3" || !api.IsExpected {
+		t.Errorf("Unexpected api node: %+v", api)
+	}
+	if !reflect.DeepEqual(ca.Aliases, []string{"ca.local", "pki", "stack-ca-1"}) {
+		t.Errorf("Unexpected ca aliases: %v", ca.Aliases)
+	}
+	if want := []TopologyNetwork{{Name: "stack_default", Members: []string{"api", "ca"}}}; !reflect.DeepEqual(topology.Networks, want) {
+		t.Errorf("Expected networks %v, got %v", want, topology.Networks)
+	}
+
+	want := []TopologyEdge{
+		{From: "api", To: "ca", Kind: EdgeDependsOn},
+		{From: "api", To: "ca", Kind: EdgeEnv, Detail: "SGL_CA"},
+		{From: "api", To: "metadata", Kind: EdgeDependsOn},
+	}
+	if !reflect.DeepEqual(topology.Edges, want) {
+		t.Errorf("Expected edges %v, got %v", want, topology.Edges)
+	}
+}
+
+func TestNetworkTopologyRendering(t *testing.T) {
+	topology := testTopology()
+
+	dot := topology.DOT()
+	for _, want := range []string{
+		"digraph topology {",
+		`"api" [label="api :8080"];`,
+		`"gcs" [label="gcs :4443 (missing)", style=dashed];`,
+		`"api" -> "network:stack_default" [arrowhead=none, style=dotted];`,
+		`"api" -> "ca";`,
+		`"api" -> "ca" [label="SGL_CA", style=dashed];`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT output missing %q:\n%s", want, dot)
+		}
+	}
+
+	mermaid := topology.Mermaid()
+	for _, want := range []string{
+		"graph LR\n",
+		`n0["api :8080"]`,
+		`net0(("stack_default"))`,
+		"n0 -.- net0",
+		"n0 --> n1",
+		"n0 -.->|SGL_CA| n1",
+	} {
+		if !strings.Contains(mermaid, want) {
+			t.Errorf("Mermaid output missing %q:\n%s", want, mermaid)
+		}
+	}
+}
+
+func TestEnvHost(t *testing.T) {
+	tests := map[string]string{
+		"http://ca:8090/ca.crt": "ca",
+		"db:5432":               "db",
+		"metadata":              "metadata",
+		"8080":                  "8080",
+	}
+	for value, want := range tests {
+		if got := envHost(value); got != want {
+			t.Errorf("envHost(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
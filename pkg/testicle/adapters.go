@@ -0,0 +1,342 @@
+package testicle
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Built-in suite types for SuiteConfig.Type
+const (
+	SuiteTypeTAP   = "tap"
+	SuiteTypeJUnit = "junit"
+)
+
+// SuiteConfig declares a non-Go test suite in testicle.yaml. The command runs
+// through the shell and its output (or Report file) is parsed by the adapter
+// registered for Type.
+type SuiteConfig struct {
+	Name    string            `yaml:"name" schema:"required"`
+	Type    string            `yaml:"type" schema:"required,enum=suite_type"`
+	Command string            `yaml:"command" schema:"required"`
+	Dir     string            `yaml:"dir"`                       // Working directory, relative to the test directory
+	Report  string            `yaml:"report"`                    // File to parse instead of stdout (e.g. junit.xml), relative to Dir
+	Timeout string            `yaml:"timeout" schema:"duration"` // Go duration, e.g. "5m"
+	Env     map[string]string `yaml:"env"`
+}
+
+// SuiteAdapter runs an external test suite and converts its outcome into
+// testicle results so they merge into the same UI and reports as Go tests
+type SuiteAdapter interface {
+	// Name identifies the suite; it is used as the package of its results
+	Name() string
+
+	// Run executes the suite. Test failures are reported as failed results,
+	// not errors; an error means the suite could not be run or parsed.
+	Run(ctx context.Context) ([]*TestResult, error)
+}
+
+// AdapterFactory creates an adapter for a suite declaration
+type AdapterFactory func(config SuiteConfig) (SuiteAdapter, error)
+
+var (
+	adapterMutex     sync.RWMutex
+	adapterFactories = map[string]AdapterFactory{
+		SuiteTypeTAP:   func(c SuiteConfig) (SuiteAdapter, error) { return newCommandAdapter(c, parseTAP) },
+		SuiteTypeJUnit: func(c SuiteConfig) (SuiteAdapter, error) { return newCommandAdapter(c, parseJUnit) },
+	}
+)
+
+// RegisterAdapter makes a suite type available to testicle.yaml. Registering
+// an existing type replaces it.
+func RegisterAdapter(suiteType string, factory AdapterFactory) {
+	adapterMutex.Lock()
+	defer adapterMutex.Unlock()
+	adapterFactories[suiteType] = factory
+}
+
+// NewSuiteAdapter creates the adapter for config.Type
+func NewSuiteAdapter(config SuiteConfig) (SuiteAdapter, error) {
+	adapterMutex.RLock()
+	factory, ok := adapterFactories[config.Type]
+	adapterMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("suite %q: unknown type %q (available: %s)", config.Name, config.Type, strings.Join(adapterTypes(), ", "))
+	}
+	return factory(config)
+}
+
+// adapterTypes lists the registered suite types
+func adapterTypes() []string {
+	adapterMutex.RLock()
+	defer adapterMutex.RUnlock()
+	types := make([]string, 0, len(adapterFactories))
+	for t := range adapterFactories {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// outputParser converts captured suite output into test results
+type outputParser func(output []byte) ([]*TestResult, error)
+
+// commandAdapter runs a shell command and parses its output
+type commandAdapter struct {
+	config  SuiteConfig
+	timeout time.Duration
+	parse   outputParser
+}
+
+// newCommandAdapter validates config for a command-based suite
+func newCommandAdapter(config SuiteConfig, parse outputParser) (*commandAdapter, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("suite name is required")
+	}
+	if strings.TrimSpace(config.Command) == "" {
+		return nil, fmt.Errorf("suite %q: command is required", config.Name)
+	}
+
+	adapter := &commandAdapter{config: config, parse: parse}
+	if config.Timeout != "" {
+		timeout, err := time.ParseDuration(config.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("suite %q: invalid timeout %q: %w", config.Name, config.Timeout, err)
+		}
+		adapter.timeout = timeout
+	}
+	return adapter, nil
+}
+
+// Name returns the suite name
+func (a *commandAdapter) Name() string {
+	return a.config.Name
+}
+
+// Run executes the command and parses stdout or the report file
+func (a *commandAdapter) Run(ctx context.Context) ([]*TestResult, error) {
+	if a.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", a.config.Command)
+	cmd.Dir = a.config.Dir
+	cmd.Env = os.Environ()
+	for key, value := range a.config.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	// Children of the shell may keep the output pipes open after a timeout
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	runErr := cmd.Run()
+	duration := time.Since(start)
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("suite %q: timed out after %s", a.config.Name, a.timeout)
+	}
+
+	output := stdout.Bytes()
+	if a.config.Report != "" {
+		reportPath := a.config.Report
+		if !filepath.IsAbs(reportPath) {
+			reportPath = filepath.Join(a.config.Dir, reportPath)
+		}
+		report, err := os.ReadFile(reportPath)
+		if err != nil {
+			return nil, fmt.Errorf("suite %q: reading report: %w", a.config.Name, err)
+		}
+		output = report
+	}
+
+	results, err := a.parse(output)
+	if err != nil {
+		return nil, fmt.Errorf("suite %q: parsing output: %w", a.config.Name, err)
+	}
+
+	// A failing command with no failing tests (crash, missing tool) must not
+	// look like a green suite
+	if runErr != nil && !hasFailure(results) {
+		results = append(results, &TestResult{
+			Name:     a.config.Name,
+			Status:   TestStatusFailed,
+			Duration: duration,
+			Output:   strings.TrimSpace(stderr.String()),
+			Error:    fmt.Sprintf("command failed: %v", runErr),
+		})
+	}
+
+	for _, result := range results {
+		result.Package = a.config.Name
+	}
+	return results, nil
+}
+
+// hasFailure reports whether any result failed
+func hasFailure(results []*TestResult) bool {
+	for _, result := range results {
+		if result.Status == TestStatusFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// tapLine matches TAP test points: "ok 1 - description # SKIP reason"
+var tapLine = regexp.MustCompile(`^(not )?ok\b\s*(\d+)?\s*(?:-\s*)?([^#]*)(?:#\s*(\w+)\s*(.*))?$`)
+
+// parseTAP converts Test Anything Protocol output into results. Diagnostic
+// lines ("# ...") following a test point are attached to it as output.
+func parseTAP(output []byte) ([]*TestResult, error) {
+	var results []*TestResult
+	var last *TestResult
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "#") {
+			if last != nil {
+				last.Output += strings.TrimSpace(strings.TrimPrefix(trimmed, "#")) + "\n"
+			}
+			continue
+		}
+
+		match := tapLine.FindStringSubmatch(trimmed)
+		if match == nil {
+			continue
+		}
+
+		name := strings.TrimSpace(match[3])
+		if name == "" {
+			name = "test " + match[2]
+		}
+		result := &TestResult{Name: name, Status: TestStatusPassed}
+
+		switch strings.ToUpper(match[4]) {
+		case "SKIP":
+			result.Status = TestStatusSkipped
+		case "TODO":
+			// TODO points never fail the suite
+			if match[1] != "" {
+				result.Status = TestStatusSkipped
+			}
+		default:
+			if match[1] != "" {
+				result.Status = TestStatusFailed
+				result.Error = trimmed
+			}
+		}
+
+		results = append(results, result)
+		last = result
+	}
+
+	return results, scanner.Err()
+}
+
+// junitReport covers both <testsuites> and bare <testsuite> roots
+type junitReport struct {
+	XMLName xml.Name         `xml:""`
+	Suites  []junitTestSuite `xml:"testsuite"`
+	Cases   []junitTestCase  `xml:"testcase"`
+}
+
+type junitTestSuite struct {
+	Name   string           `xml:"name,attr"`
+	Suites []junitTestSuite `xml:"testsuite"`
+	Cases  []junitTestCase  `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure"`
+	Error     *junitMessage `xml:"error"`
+	Skipped   *junitMessage `xml:"skipped"`
+	SystemOut string        `xml:"system-out"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// parseJUnit converts a JUnit XML report into results
+func parseJUnit(output []byte) ([]*TestResult, error) {
+	var report junitReport
+	if err := xml.Unmarshal(output, &report); err != nil {
+		return nil, err
+	}
+
+	var results []*TestResult
+	var collect func(cases []junitTestCase, suites []junitTestSuite)
+	collect = func(cases []junitTestCase, suites []junitTestSuite) {
+		for _, tc := range cases {
+			results = append(results, junitResult(tc))
+		}
+		for _, suite := range suites {
+			collect(suite.Cases, suite.Suites)
+		}
+	}
+	collect(report.Cases, report.Suites)
+
+	return results, nil
+}
+
+// junitResult converts a single JUnit test case
+func junitResult(tc junitTestCase) *TestResult {
+	name := tc.Name
+	if tc.ClassName != "" && tc.ClassName != tc.Name {
+		name = tc.ClassName + "." + tc.Name
+	}
+
+	result := &TestResult{
+		Name:   name,
+		Status: TestStatusPassed,
+		Output: strings.TrimSpace(tc.SystemOut),
+	}
+	if seconds, err := strconv.ParseFloat(tc.Time, 64); err == nil {
+		result.Duration = time.Duration(seconds * float64(time.Second))
+	}
+
+	switch {
+	case tc.Failure != nil:
+		result.Status = TestStatusFailed
+		result.Error = junitMessageText(tc.Failure)
+	case tc.Error != nil:
+		result.Status = TestStatusFailed
+		result.Error = junitMessageText(tc.Error)
+	case tc.Skipped != nil:
+		result.Status = TestStatusSkipped
+	}
+	return result
+}
+
+// junitMessageText prefers the message attribute, falling back to the body
+func junitMessageText(m *junitMessage) string {
+	if m.Message != "" {
+		return m.Message
+	}
+	return strings.TrimSpace(m.Body)
+}
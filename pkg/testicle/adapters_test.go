@@ -0,0 +1,229 @@
+package testicle
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseTAP(t *testing.T) {
+	output := `TAP version 13
+1..5
+ok 1 - adds numbers
+not ok 2 - divides by zero
+# expected error, got 0
+ok 3 - network # SKIP offline
+not ok 4 - future feature # TODO not implemented
+ok 5
+`
+	results, err := parseTAP([]byte(output))
+	if err != nil {
+		t.Fatalf("parseTAP failed: %v", err)
+	}
+
+	expected := []struct {
+		name   string
+		status TestStatus
+	}{
+		{"adds numbers", TestStatusPassed},
+		{"divides by zero", TestStatusFailed},
+		{"network", TestStatusSkipped},
+		{"future feature", TestStatusSkipped},
+		{"test 5", TestStatusPassed},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(results))
+	}
+	for i, want := range expected {
+		if results[i].Name != want.name || results[i].Status != want.status {
+			t.Errorf("Result %d: expected %s/%s, got %s/%s", i, want.name, want.status, results[i].Name, results[i].Status)
+		}
+	}
+	if results[1].Output != "expected error, got 0\n" {
+		t.Errorf("Expected diagnostic attached to failing test, got %q", results[1].Output)
+	}
+}
+
+func TestParseJUnit(t *testing.T) {
+	report := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="math">
+    <testcase classname="math" name="adds" time="0.25"/>
+    <testcase classname="math" name="divides" time="0.01">
+      <failure message="expected 2, got 3">stack trace</failure>
+    </testcase>
+  </testsuite>
+  <testsuite name="net">
+    <testcase classname="net.http" name="fetch"><skipped/></testcase>
+    <testcase name="broken"><error>boom</error></testcase>
+  </testsuite>
+</testsuites>`
+
+	results, err := parseJUnit([]byte(report))
+	if err != nil {
+		t.Fatalf("parseJUnit failed: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 results, got %d", len(results))
+	}
+
+	if results[0].Name != "math.adds" || results[0].Status != TestStatusPassed || results[0].Duration != 250*time.Millisecond {
+		t.Errorf("Unexpected first result: %+v", results[0])
+	}
+	if results[1].Status != TestStatusFailed || results[1].Error != "expected 2, got 3" {
+		t.Errorf("Unexpected failure result: %+v", results[1])
+	}
+	if results[2].Name != "net.http.fetch" || results[2].Status != TestStatusSkipped {
+		t.Errorf("Unexpected skipped result: %+v", results[2])
+	}
+	if results[3].Status != TestStatusFailed || results[3].Error != "boom" {
+		t.Errorf("Unexpected error result: %+v", results[3])
+	}
+
+	// A bare <testsuite> root is also accepted
+	results, err = parseJUnit([]byte(`<testsuite name="x"><testcase name="only"/></testsuite>`))
+	if err != nil || len(results) != 1 || results[0].Name != "only" {
+		t.Errorf("Expected single result from bare testsuite, got %v (%v)", results, err)
+	}
+}
+
+func TestCommandAdapter(t *testing.T) {
+	dir := t.TempDir()
+
+	adapter, err := NewSuiteAdapter(SuiteConfig{
+		Name:    "shell",
+		Type:    SuiteTypeTAP,
+		Command: `printf 'ok 1 - first\nnot ok 2 - second\n'; exit 1`,
+		Dir:     dir,
+	})
+	if err != nil {
+		t.Fatalf("NewSuiteAdapter failed: %v", err)
+	}
+	results, err := adapter.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Package != "shell" || results[1].Status != TestStatusFailed {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+
+	t.Run("CommandFailureWithoutFailingTests", func(t *testing.T) {
+		adapter, _ := NewSuiteAdapter(SuiteConfig{Name: "crash", Type: SuiteTypeTAP, Command: "echo 'ok 1 - a'; exit 3", Dir: dir})
+		results, err := adapter.Run(context.Background())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if !hasFailure(results) {
+			t.Error("Expected a synthetic failure for a failing command")
+		}
+	})
+
+	t.Run("ReportFile", func(t *testing.T) {
+		adapter, _ := NewSuiteAdapter(SuiteConfig{
+			Name:    "junit",
+			Type:    SuiteTypeJUnit,
+			Command: `echo '<testsuite><testcase name="t"/></testsuite>' > report.xml`,
+			Dir:     dir,
+			Report:  "report.xml",
+			Env:     map[string]string{"CI": "true"},
+		})
+		results, err := adapter.Run(context.Background())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if len(results) != 1 || results[0].Name != "t" || results[0].Status != TestStatusPassed {
+			t.Errorf("Unexpected results: %+v", results)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		adapter, _ := NewSuiteAdapter(SuiteConfig{Name: "slow", Type: SuiteTypeTAP, Command: "sleep 5", Dir: dir, Timeout: "100ms"})
+		if _, err := adapter.Run(context.Background()); err == nil {
+			t.Error("Expected timeout error")
+		}
+	})
+
+	t.Run("InvalidConfig", func(t *testing.T) {
+		if _, err := NewSuiteAdapter(SuiteConfig{Name: "x", Type: "cucumber", Command: "true"}); err == nil {
+			t.Error("Expected error for unknown type")
+		}
+		if _, err := NewSuiteAdapter(SuiteConfig{Name: "x", Type: SuiteTypeTAP}); err == nil {
+			t.Error("Expected error for missing command")
+		}
+		if _, err := NewSuiteAdapter(SuiteConfig{Name: "x", Type: SuiteTypeTAP, Command: "true", Timeout: "soon"}); err == nil {
+			t.Error("Expected error for bad timeout")
+		}
+	})
+}
+
+func TestExecuteSuitesMergesResults(t *testing.T) {
+	adapter, err := NewSuiteAdapter(SuiteConfig{
+		Name:    "ext",
+		Type:    SuiteTypeTAP,
+		Command: `printf 'ok 1 - a\nok 2 - b # SKIP\nnot ok 3 - c\n'`,
+		Dir:     t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reported []string
+	executor := NewExecutor(NewLogger(false))
+	executor.SetResultCallback(func(result *TestResult) {
+		reported = append(reported, result.Name)
+	})
+
+	results := &TestResults{Passed: 1}
+	executor.ExecuteSuites(context.Background(), []SuiteAdapter{adapter}, results)
+
+	if results.Passed != 2 || results.Skipped != 1 || results.Failed != 1 || len(results.Tests) != 3 {
+		t.Errorf("Unexpected merged counts: %+v", results)
+	}
+	if len(reported) != 3 {
+		t.Errorf("Expected 3 results reported to the callback, got %v", reported)
+	}
+}
+
+func TestApplyConfigFileSuites(t *testing.T) {
+	dir := t.TempDir()
+	config := `suites:
+  - name: frontend
+    type: junit
+    command: npm test
+    dir: web
+    report: junit.xml
+    timeout: 5m
+    env:
+      CI: "true"
+`
+	if err := os.WriteFile(filepath.Join(dir, DefaultConfigFile), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runnerConfig := &Config{Dir: dir, ConfigFile: DefaultConfigFile}
+	if err := applyConfigFile(runnerConfig); err != nil {
+		t.Fatalf("applyConfigFile failed: %v", err)
+	}
+	if len(runnerConfig.Suites) != 1 {
+		t.Fatalf("Expected 1 suite, got %d", len(runnerConfig.Suites))
+	}
+	suite := runnerConfig.Suites[0]
+	if suite.Name != "frontend" || suite.Type != SuiteTypeJUnit || suite.Env["CI"] != "true" {
+		t.Errorf("Unexpected suite: %+v", suite)
+	}
+
+	adapters, err := newSuiteAdapters(runnerConfig)
+	if err != nil {
+		t.Fatalf("newSuiteAdapters failed: %v", err)
+	}
+	if got := adapters[0].(*commandAdapter).config.Dir; got != filepath.Join(dir, "web") {
+		t.Errorf("Expected suite dir resolved against test dir, got %s", got)
+	}
+
+	// A missing default config file is not an error
+	if err := applyConfigFile(&Config{Dir: t.TempDir(), ConfigFile: DefaultConfigFile}); err != nil {
+		t.Errorf("Expected missing default config to be ignored, got %v", err)
+	}
+}
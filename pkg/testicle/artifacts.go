@@ -0,0 +1,323 @@
+package testicle
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nzions/sharedgolibs/pkg/testicle/report"
+)
+
+// DefaultArtifactsDir is the conventional location for run artifacts,
+// next to the skip list
+const DefaultArtifactsDir = ".testicle/runs"
+
+// ArtifactReportFile is the json-stream report saved for each run
+const ArtifactReportFile = "report.ndjson"
+
+// Retention defaults, used for limits left unset in RetentionConfig
+const (
+	DefaultRetentionMaxRuns = 20
+	DefaultRetentionMaxAge  = 7 * 24 * time.Hour
+	DefaultRetentionMaxDisk = 256 << 20
+)
+
+// artifactRunFormat names each run directory after its start time, so
+// names sort chronologically
+const artifactRunFormat = "20060102-150405.000"
+
+// RetentionConfig limits the run artifacts kept in the artifacts directory.
+// Runs beyond any limit are removed oldest first, but the newest run is
+// always kept.
+type RetentionConfig struct {
+	MaxRuns int    `yaml:"max_runs"`                   // Default DefaultRetentionMaxRuns
+	MaxAge  string `yaml:"max_age" schema:"duration"`  // Go duration (default 168h)
+	MaxDisk string `yaml:"max_disk" schema:"bytesize"` // e.g. 500MB (default 256MB)
+}
+
+// ArtifactRun is one run's directory in the artifacts directory
+type ArtifactRun struct {
+	ID   string    `json:"id"` // Directory name, the run's start time
+	Path string    `json:"path"`
+	Time time.Time `json:"time"`
+	Size int64     `json:"size_bytes"`
+}
+
+// ArtifactStore saves a report of each run and enforces the retention policy
+type ArtifactStore struct {
+	dir     string
+	maxRuns int
+	maxAge  time.Duration
+	maxDisk int64
+
+	// renderer writes each run's ArtifactHTMLReportFile
+	renderer report.Renderer
+}
+
+// NewArtifactStore creates a store for run artifacts in dir
+func NewArtifactStore(dir string, retention RetentionConfig) (*ArtifactStore, error) {
+	if dir == "" {
+		dir = DefaultArtifactsDir
+	}
+	store := &ArtifactStore{
+		dir:     dir,
+		maxRuns: DefaultRetentionMaxRuns,
+		maxAge:  DefaultRetentionMaxAge,
+		maxDisk: DefaultRetentionMaxDisk,
+	}
+	renderer, err := report.NewHTML()
+	if err != nil {
+		return nil, err
+	}
+	store.renderer = renderer
+
+	if retention.MaxRuns < 0 {
+		return nil, fmt.Errorf("invalid retention max_runs %d", retention.MaxRuns)
+	}
+	if retention.MaxRuns > 0 {
+		store.maxRuns = retention.MaxRuns
+	}
+	if retention.MaxAge != "" {
+		maxAge, err := time.ParseDuration(retention.MaxAge)
+		if err != nil || maxAge <= 0 {
+			return nil, fmt.Errorf("invalid retention max_age %q", retention.MaxAge)
+		}
+		store.maxAge = maxAge
+	}
+	if retention.MaxDisk != "" {
+		maxDisk, err := ParseByteSize(retention.MaxDisk)
+		if err != nil || maxDisk <= 0 {
+			return nil, fmt.Errorf("invalid retention max_disk %q", retention.MaxDisk)
+		}
+		store.maxDisk = maxDisk
+	}
+	return store, nil
+}
+
+// OpenArtifactStore opens the artifacts directory of config, reading
+// ArtifactsDir and Retention from the config file when unset. Unlike a run,
+// it falls back to DefaultArtifactsDir, so `testicle clean` works without a
+// config file.
+func OpenArtifactStore(config *Config) (*ArtifactStore, error) {
+	if err := applyConfigFile(config); err != nil {
+		return nil, err
+	}
+	if config.ArtifactsDir == "" {
+		config.ArtifactsDir = DefaultArtifactsDir
+	}
+	return NewArtifactStore(artifactsPath(config), config.Retention)
+}
+
+// artifactsPath resolves Config.ArtifactsDir against the test directory
+func artifactsPath(config *Config) string {
+	if filepath.IsAbs(config.ArtifactsDir) {
+		return config.ArtifactsDir
+	}
+	return filepath.Join(config.Dir, config.ArtifactsDir)
+}
+
+// Dir returns the artifacts directory
+func (s *ArtifactStore) Dir() string {
+	return s.dir
+}
+
+// SetRenderer replaces the renderer of each run's HTML report, e.g. with a
+// report.HTML using a team's theme and templates
+func (s *ArtifactStore) SetRenderer(renderer report.Renderer) {
+	s.renderer = renderer
+}
+
+// MaxDisk returns the disk usage limit in bytes
+func (s *ArtifactStore) MaxDisk() int64 {
+	return s.maxDisk
+}
+
+// Save writes a run's results as a json-stream report (run metadata, test
+// results, resource profiles, and leaks), an HTML report, and its timeline
+// as a Chrome trace (see WriteChromeTrace) in a new run directory
+func (s *ArtifactStore) Save(results *TestResults, started time.Time) (*ArtifactRun, error) {
+	id := started.UTC().Format(artifactRunFormat)
+	path := filepath.Join(s.dir, id)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, fmt.Errorf("creating run artifacts: %w", err)
+	}
+
+	file, err := os.Create(filepath.Join(path, ArtifactReportFile))
+	if err != nil {
+		return nil, fmt.Errorf("creating run report: %w", err)
+	}
+	reporter := newJSONStreamReporter(file)
+	reporter.RunStart(s.dir, results.Metadata)
+	for _, result := range results.Tests {
+		reporter.TestResult(result)
+	}
+	for _, profile := range results.Resources {
+		reporter.Resources(profile)
+	}
+	for _, leak := range results.Leaks {
+		reporter.Leak(leak)
+	}
+	reporter.RunEnd(results)
+	if err := file.Close(); err != nil {
+		return nil, fmt.Errorf("writing run report: %w", err)
+	}
+	title := "Test run " + started.Format("2006-01-02 15:04:05")
+	if err := writeHTMLReport(filepath.Join(path, ArtifactHTMLReportFile), s.renderer, results, title); err != nil {
+		return nil, err
+	}
+	if err := writeChromeTraceFile(filepath.Join(path, ArtifactTraceFile), results); err != nil {
+		return nil, err
+	}
+
+	size, _ := dirSize(path)
+	return &ArtifactRun{ID: id, Path: path, Time: started.UTC().Truncate(time.Millisecond), Size: size}, nil
+}
+
+// Runs lists the saved runs, newest first. Entries that aren't run
+// directories are ignored and never removed.
+func (s *ArtifactStore) Runs() ([]ArtifactRun, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading artifacts directory: %w", err)
+	}
+
+	var runs []ArtifactRun
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		started, err := time.Parse(artifactRunFormat, entry.Name())
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(s.dir, entry.Name())
+		size, err := dirSize(path)
+		if err != nil {
+			return nil, fmt.Errorf("measuring %s: %w", path, err)
+		}
+		runs = append(runs, ArtifactRun{ID: entry.Name(), Path: path, Time: started, Size: size})
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].ID > runs[j].ID })
+	return runs, nil
+}
+
+// Usage returns the number of saved runs and their total size in bytes
+func (s *ArtifactStore) Usage() (int, int64, error) {
+	runs, err := s.Runs()
+	if err != nil {
+		return 0, 0, err
+	}
+	var total int64
+	for _, run := range runs {
+		total += run.Size
+	}
+	return len(runs), total, nil
+}
+
+// Prune removes the runs beyond the retention limits and returns them
+func (s *ArtifactStore) Prune(now time.Time) ([]ArtifactRun, error) {
+	runs, err := s.Runs()
+	if err != nil {
+		return nil, err
+	}
+
+	// Runs are newest first, so everything from the first run over a
+	// limit onwards expires
+	var size int64
+	for i, run := range runs {
+		size += run.Size
+		if i > 0 && (i >= s.maxRuns || now.Sub(run.Time) > s.maxAge || size > s.maxDisk) {
+			return runs[i:], removeRuns(runs[i:])
+		}
+	}
+	return nil, nil
+}
+
+// Clean removes every saved run and returns them
+func (s *ArtifactStore) Clean() ([]ArtifactRun, error) {
+	runs, err := s.Runs()
+	if err != nil {
+		return nil, err
+	}
+	return runs, removeRuns(runs)
+}
+
+// removeRuns deletes run directories, stopping at the first failure
+func removeRuns(runs []ArtifactRun) error {
+	for _, run := range runs {
+		if err := os.RemoveAll(run.Path); err != nil {
+			return fmt.Errorf("removing %s: %w", run.Path, err)
+		}
+	}
+	return nil
+}
+
+// dirSize returns the total size of the regular files under dir
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type().IsRegular() {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// byteUnits are the suffixes ParseByteSize accepts, largest first so GB
+// is tried before B
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"GB", 1 << 30}, {"G", 1 << 30},
+	{"MB", 1 << 20}, {"M", 1 << 20},
+	{"KB", 1 << 10}, {"K", 1 << 10},
+	{"B", 1},
+}
+
+// ParseByteSize parses a size such as 512KB, 500MB, or 2G. Units are
+// powers of 1024; a plain number is bytes.
+func ParseByteSize(value string) (int64, error) {
+	number, multiplier := strings.ToUpper(strings.TrimSpace(value)), int64(1)
+	for _, unit := range byteUnits {
+		if trimmed, ok := strings.CutSuffix(number, unit.suffix); ok {
+			number, multiplier = strings.TrimSpace(trimmed), unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (use a number with an optional KB, MB, or GB unit)", value)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// FormatByteSize formats a size in bytes with the largest whole unit,
+// e.g. 1.5 MB
+func FormatByteSize(size int64) string {
+	for _, unit := range []struct {
+		name string
+		size int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}} {
+		if size >= unit.size {
+			return fmt.Sprintf("%.1f %s", float64(size)/float64(unit.size), unit.name)
+		}
+	}
+	return fmt.Sprintf("%d B", size)
+}
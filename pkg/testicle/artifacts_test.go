@@ -0,0 +1,164 @@
+package testicle
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func saveTestRuns(t *testing.T, store *ArtifactStore, start time.Time, count int) {
+	t.Helper()
+	results := &TestResults{
+		Passed: 1,
+		Failed: 1,
+		Tests: []*TestResult{
+			{Name: "TestA", Package: "example.com/a", Status: TestStatusPassed},
+			{Name: "TestB", Package: "example.com/a", Status: TestStatusFailed, Output: strings.Repeat("x", 1000)},
+		},
+		Resources: []*ResourceProfile{{Package: "example.com/a", PeakRSSBytes: 1 << 20}},
+	}
+	for i := 0; i < count; i++ {
+		if _, err := store.Save(results, start.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+}
+
+func TestArtifactStoreSave(t *testing.T) {
+	store, err := NewArtifactStore(t.TempDir(), RetentionConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	saveTestRuns(t, store, time.Now(), 1)
+
+	runs, err := store.Runs()
+	if err != nil || len(runs) != 1 || runs[0].Size == 0 {
+		t.Fatalf("Expected one saved run, got %+v, %v", runs, err)
+	}
+	file, err := os.Open(filepath.Join(runs[0].Path, ArtifactReportFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var events []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var header streamHeader
+		if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+			t.Fatalf("Invalid report line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, header.Event)
+	}
+	want := []string{EventRunStart, EventTestResult, EventTestResult, EventResources, EventRunEnd}
+	if strings.Join(events, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected events %v, got %v", want, events)
+	}
+
+	trace, err := os.ReadFile(filepath.Join(runs[0].Path, ArtifactTraceFile))
+	if err != nil || !json.Valid(trace) {
+		t.Errorf("Expected a valid trace alongside the report, got %v", err)
+	}
+
+	html, err := os.ReadFile(filepath.Join(runs[0].Path, ArtifactHTMLReportFile))
+	if err != nil || !strings.Contains(string(html), "TestB") {
+		t.Errorf("Expected an HTML report alongside the report, got %v", err)
+	}
+}
+
+func TestArtifactStorePrune(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		retention RetentionConfig
+		kept      int
+	}{
+		{"max runs", RetentionConfig{MaxRuns: 3}, 3},
+		{"max age", RetentionConfig{MaxAge: "2h30m"}, 3}, // Runs at -4h .. now
+		{"max disk", RetentionConfig{MaxDisk: "2KB"}, 1},
+		{"defaults", RetentionConfig{}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewArtifactStore(t.TempDir(), tt.retention)
+			if err != nil {
+				t.Fatal(err)
+			}
+			saveTestRuns(t, store, now.Add(-4*time.Hour), 5)
+			os.WriteFile(filepath.Join(store.Dir(), "notes.txt"), []byte("keep me"), 0644)
+
+			removed, err := store.Prune(now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			runs, _ := store.Runs()
+			if len(runs) != tt.kept || len(removed) != 5-tt.kept {
+				t.Fatalf("Expected %d runs kept, got %d kept and %d removed", tt.kept, len(runs), len(removed))
+			}
+			if len(runs) > 0 && !runs[0].Time.Equal(now) {
+				t.Errorf("Expected the newest run to be kept, got %s", runs[0].Time)
+			}
+			if _, err := os.Stat(filepath.Join(store.Dir(), "notes.txt")); err != nil {
+				t.Error("Prune removed a file that isn't a run")
+			}
+		})
+	}
+}
+
+func TestArtifactStoreClean(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, DefaultConfigFile), []byte("artifacts_dir: runs\nretention:\n  max_runs: 2\n"), 0644)
+
+	config := &Config{Dir: dir, ConfigFile: filepath.Join(dir, DefaultConfigFile)}
+	store, err := OpenArtifactStore(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.Dir() != filepath.Join(dir, "runs") {
+		t.Errorf("Expected the artifacts dir relative to the test dir, got %s", store.Dir())
+	}
+	saveTestRuns(t, store, time.Now(), 3)
+
+	removed, err := store.Clean()
+	if runs, size, _ := store.Usage(); err != nil || len(removed) != 3 || runs != 0 || size != 0 {
+		t.Errorf("Expected every run removed, got %d removed, %d left (%v)", len(removed), runs, err)
+	}
+}
+
+func TestRetentionValidation(t *testing.T) {
+	for _, retention := range []RetentionConfig{{MaxRuns: -1}, {MaxAge: "a week"}, {MaxDisk: "lots"}, {MaxDisk: "0"}} {
+		if _, err := NewArtifactStore(t.TempDir(), retention); err == nil {
+			t.Errorf("Expected %+v to be rejected", retention)
+		}
+	}
+
+	_, err := ParseConfig("testicle.yaml", []byte("retention:\n  max_disk: 5 gigs\n"))
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) || !strings.Contains(err.Error(), `retention.max_disk: invalid size "5 gigs"`) {
+		t.Errorf("Expected a schema error for max_disk, got %v", err)
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := map[string]int64{
+		"512":    512,
+		"2KB":    2048,
+		"1.5 MB": 3 << 19,
+		"256mb":  256 << 20,
+		"2G":     2 << 30,
+	}
+	for value, want := range tests {
+		if got, err := ParseByteSize(value); err != nil || got != want {
+			t.Errorf("ParseByteSize(%q) = %d, %v; want %d", value, got, err, want)
+		}
+	}
+	if got := FormatByteSize(3 << 19); got != "1.5 MB" {
+		t.Errorf("FormatByteSize = %q, want 1.5 MB", got)
+	}
+}
@@ -0,0 +1,236 @@
+package testicle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/nzions/sharedgolibs/internal/fileutil"
+)
+
+// NotRunBudgetExceeded is the Error of tests cut off by the time budget
+const NotRunBudgetExceeded = "not run (budget exceeded)"
+
+// errBudgetExceeded is the cancellation cause of a package stopped when the
+// time budget ran out
+var errBudgetExceeded = errors.New("time budget exceeded")
+
+// budgetWaitDelay bounds waiting for a stopped test process's output, since
+// the test binary go test started may outlive it
+const budgetWaitDelay = 2 * time.Second
+
+// ProfileConfig is a named set of run settings in testicle.yaml, selected
+// with Config.Profile (--profile), e.g. a tight budget for pre-commit hooks:
+//
+//	profiles:
+//	  pre-commit:
+//	    budget: 30s
+type ProfileConfig struct {
+	// Budget caps the total time of a run. When it runs out, the package
+	// running is stopped and the remaining ones are not run; their tests are
+	// reported as NotRunBudgetExceeded. Later runs order packages by their
+	// recorded durations so the most valuable tests fit first (see
+	// DurationHistory.Order).
+	Budget string `yaml:"budget" schema:"duration"`
+}
+
+// DefaultDurationHistoryFile is where budgeted runs and runs checked by the
+// slow test gate record package durations between runs
+func DefaultDurationHistoryFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "testicle", "durations.json")
+}
+
+// DurationHistory records how long each package's tests took and whether
+// they failed in the most recent run they completed in
+type DurationHistory struct {
+	path     string
+	Packages map[string]*PackageHistory `json:"packages"` // By absolute package directory
+}
+
+// PackageHistory is the record of one package in a DurationHistory
+type PackageHistory struct {
+	Duration time.Duration `json:"duration"`
+	Failed   bool          `json:"failed"`
+
+	// Partial is set when the budget stopped the package, so Duration is
+	// only a lower bound
+	Partial bool `json:"partial,omitempty"`
+
+	// Samples are the durations of the package's most recent complete
+	// runs, oldest first, for the slow test gate's percentiles (see P95)
+	Samples []time.Duration `json:"samples,omitempty"`
+}
+
+// LoadDurationHistory reads the history at path. A missing file is an
+// empty history.
+func LoadDurationHistory(path string) (*DurationHistory, error) {
+	history := &DurationHistory{path: path, Packages: make(map[string]*PackageHistory)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading duration history: %w", err)
+	}
+	if err := json.Unmarshal(data, history); err != nil {
+		return nil, fmt.Errorf("parsing duration history %s: %w", path, err)
+	}
+	if history.Packages == nil {
+		history.Packages = make(map[string]*PackageHistory)
+	}
+	return history, nil
+}
+
+// Save writes the history back to the file it was loaded from
+func (h *DurationHistory) Save() error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return fmt.Errorf("saving duration history: %w", err)
+	}
+	if err := fileutil.WriteAtomic(h.path, data, 0644); err != nil {
+		return fmt.Errorf("saving duration history: %w", err)
+	}
+	return nil
+}
+
+// Record updates the history with the packages of a run. Packages stopped
+// by the budget keep their longer recorded duration, since their elapsed
+// time is only a lower bound, and add no sample; packages that never
+// started are unchanged.
+func (h *DurationHistory) Record(results *TestResults) {
+	stopped := make(map[string]bool)
+	for _, result := range results.NotRun {
+		stopped[absPath(filepath.Dir(result.File))] = true
+	}
+
+	for _, timing := range results.Packages {
+		if timing.Started.IsZero() || timing.Finished.IsZero() {
+			continue
+		}
+		key := absPath(timing.Package)
+		entry := &PackageHistory{Duration: timing.Finished.Sub(timing.Started), Partial: stopped[key]}
+		for _, test := range timing.Tests {
+			entry.Failed = entry.Failed || test.Status == TestStatusFailed
+		}
+		previous, ok := h.Packages[key]
+		if ok {
+			entry.Samples = previous.Samples
+		}
+		if ok && entry.Partial {
+			entry.Duration = max(entry.Duration, previous.Duration)
+			entry.Failed = entry.Failed || previous.Failed
+		}
+		if !entry.Partial {
+			entry.Samples = append(entry.Samples, entry.Duration)
+			if n := len(entry.Samples); n > maxDurationSamples {
+				entry.Samples = entry.Samples[n-maxDurationSamples:]
+			}
+		}
+		h.Packages[key] = entry
+	}
+}
+
+// Order sorts package directories for a budgeted run so the tests most
+// worth running come first: packages that failed last time, then packages
+// without a complete record (new, or stopped by the budget before), then
+// the rest from fastest to slowest, fitting as many as possible. Ties keep
+// directory order.
+func (h *DurationHistory) Order(packages []string) []string {
+	rank := func(pkg string) (int, time.Duration) {
+		entry, ok := h.Packages[absPath(pkg)]
+		switch {
+		case ok && entry.Failed:
+			return 0, entry.Duration
+		case !ok || entry.Partial:
+			return 1, 0
+		default:
+			return 2, entry.Duration
+		}
+	}
+
+	ordered := append([]string(nil), packages...)
+	sort.Strings(ordered)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		rankI, durationI := rank(ordered[i])
+		rankJ, durationJ := rank(ordered[j])
+		if rankI != rankJ {
+			return rankI < rankJ
+		}
+		return durationI < durationJ
+	})
+	return ordered
+}
+
+// notRunResults returns the results of tests cut off by the budget
+func notRunResults(tests []*TestInfo) []*TestResult {
+	results := make([]*TestResult, 0, len(tests))
+	for _, test := range tests {
+		results = append(results, &TestResult{
+			Name:    test.Name,
+			Package: test.Package,
+			File:    test.File,
+			Line:    test.Line,
+			Status:  TestStatusSkipped,
+			Error:   NotRunBudgetExceeded,
+		})
+	}
+	return results
+}
+
+// resolveProfile applies the profile selected by config.Profile from the
+// config file's profiles
+func resolveProfile(config *Config, profiles map[string]ProfileConfig) error {
+	if config.Profile == "" {
+		return nil
+	}
+	profile, ok := profiles[config.Profile]
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for name := range profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return fmt.Errorf("unknown profile %q: no profiles are defined in the config file", config.Profile)
+		}
+		return fmt.Errorf("unknown profile %q%s", config.Profile, suggest(config.Profile, names, "defined profiles"))
+	}
+	if profile.Budget != "" && config.Budget == 0 {
+		budget, err := time.ParseDuration(profile.Budget)
+		if err != nil {
+			return fmt.Errorf("profile %q: invalid budget %q", config.Profile, profile.Budget)
+		}
+		config.Budget = budget
+	}
+	return nil
+}
+
+// notRunPackage counts the tests of one package cut off by the budget
+type notRunPackage struct {
+	name  string
+	count int
+}
+
+// notRunPackages groups not-run tests by package, in run order
+func notRunPackages(results []*TestResult) []notRunPackage {
+	var packages []notRunPackage
+	for _, result := range results {
+		if n := len(packages); n > 0 && packages[n-1].name == result.Package {
+			packages[n-1].count++
+			continue
+		}
+		packages = append(packages, notRunPackage{name: result.Package, count: 1})
+	}
+	return packages
+}
@@ -0,0 +1,158 @@
+package testicle
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDurationHistoryOrder(t *testing.T) {
+	history := &DurationHistory{Packages: map[string]*PackageHistory{
+		absPath("/repo/slow"):    {Duration: 40 * time.Second},
+		absPath("/repo/fast"):    {Duration: time.Second},
+		absPath("/repo/broken"):  {Duration: 20 * time.Second, Failed: true},
+		absPath("/repo/stopped"): {Duration: 60 * time.Second, Partial: true},
+	}}
+
+	got := history.Order([]string{"/repo/slow", "/repo/new", "/repo/fast", "/repo/stopped", "/repo/broken"})
+	want := []string{"/repo/broken", "/repo/new", "/repo/stopped", "/repo/fast", "/repo/slow"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Order() = %v, want %v", got, want)
+	}
+}
+
+func TestDurationHistoryRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "testicle", "durations.json")
+	history, err := LoadDurationHistory(path)
+	if err != nil || len(history.Packages) != 0 {
+		t.Fatalf("Expected an empty history for a missing file, got %v, %v", history, err)
+	}
+	history.Packages[absPath("/repo/stopped")] = &PackageHistory{Duration: time.Minute}
+
+	start := time.Now()
+	history.Record(&TestResults{
+		Packages: []*PackageTiming{
+			{Package: "/repo/ok", Started: start, Finished: start.Add(2 * time.Second),
+				Tests: []*TestResult{{Name: "TestOK", Status: TestStatusPassed}}},
+			{Package: "/repo/broken", Started: start, Finished: start.Add(time.Second),
+				Tests: []*TestResult{{Name: "TestBroken", Status: TestStatusFailed}}},
+			{Package: "/repo/stopped", Started: start, Finished: start.Add(5 * time.Second)},
+			{Package: "/repo/unstarted"},
+		},
+		NotRun: []*TestResult{{Name: "TestSlow", File: "/repo/stopped/slow_test.go"}},
+	})
+	if err := history.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := LoadDurationHistory(path)
+	if err != nil {
+		t.Fatalf("LoadDurationHistory failed: %v", err)
+	}
+	want := map[string]*PackageHistory{
+		absPath("/repo/ok"):      {Duration: 2 * time.Second, Samples: []time.Duration{2 * time.Second}},
+		absPath("/repo/broken"):  {Duration: time.Second, Failed: true, Samples: []time.Duration{time.Second}},
+		absPath("/repo/stopped"): {Duration: time.Minute, Partial: true},
+	}
+	if !reflect.DeepEqual(loaded.Packages, want) {
+		t.Errorf("Unexpected history: %+v", loaded.Packages)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadDurationHistory(path); err == nil {
+		t.Error("Expected an error for a corrupt history")
+	}
+}
+
+func TestResolveProfile(t *testing.T) {
+	profiles := map[string]ProfileConfig{
+		"pre-commit": {Budget: "30s"},
+		"nightly":    {},
+	}
+
+	config := &Config{Profile: "pre-commit"}
+	if err := resolveProfile(config, profiles); err != nil || config.Budget != 30*time.Second {
+		t.Errorf("Expected the profile's budget, got %s, %v", config.Budget, err)
+	}
+
+	config = &Config{Profile: "pre-commit", Budget: time.Minute}
+	if err := resolveProfile(config, profiles); err != nil || config.Budget != time.Minute {
+		t.Errorf("Expected --budget to override the profile, got %s, %v", config.Budget, err)
+	}
+
+	err := resolveProfile(&Config{Profile: "precommit"}, profiles)
+	if err == nil || !strings.Contains(err.Error(), `did you mean "pre-commit"?`) {
+		t.Errorf("Expected a suggestion for an unknown profile, got %v", err)
+	}
+	err = resolveProfile(&Config{Profile: "pre-commit"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "no profiles") {
+		t.Errorf("Expected an error without profiles, got %v", err)
+	}
+}
+
+func TestExecutorBudget(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test on generated packages")
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":              "module example.com/budget\n\ngo 1.21\n",
+		"fast/fast_test.go":   "package fast\n\nimport \"testing\"\n\nfunc TestFast(t *testing.T) {}\n",
+		"slow/slow_test.go":   "package slow\n\nimport (\n\t\"testing\"\n\t\"time\"\n)\n\nfunc TestQuick(t *testing.T) {}\n\nfunc TestSlow(t *testing.T) {\n\ttime.Sleep(time.Minute)\n}\n",
+		"later/later_test.go": "package later\n\nimport \"testing\"\n\nfunc TestLater(t *testing.T) {}\n",
+	}
+	writeFiles(t, dir, files)
+
+	tests, err := NewDiscovery(dir, NewLogger(false)).DiscoverTests(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverTests failed: %v", err)
+	}
+
+	// The history puts the slow package between the other two
+	history := &DurationHistory{Packages: map[string]*PackageHistory{
+		filepath.Join(dir, "fast"):  {Duration: time.Millisecond},
+		filepath.Join(dir, "slow"):  {Duration: time.Second},
+		filepath.Join(dir, "later"): {Duration: time.Minute},
+	}}
+	executor := NewExecutor(NewLogger(false))
+	executor.SetResultCallback(func(*TestResult) {})
+	executor.SetBudget(5*time.Second, history)
+
+	start := time.Now()
+	results, err := executor.ExecuteTests(context.Background(), tests)
+	if err != nil {
+		t.Fatalf("ExecuteTests failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second+budgetWaitDelay+time.Second {
+		t.Errorf("Expected the run stopped near the budget, took %s", elapsed)
+	}
+
+	status := make(map[string]TestStatus)
+	for _, result := range results.Tests {
+		status[result.Name] = result.Status
+	}
+	if status["TestFast"] != TestStatusPassed || status["TestQuick"] != TestStatusPassed {
+		t.Errorf("Expected the tests within the budget to pass, got %v", status)
+	}
+	if results.Failed != 0 {
+		t.Errorf("Expected no failures from the stopped package, got %d", results.Failed)
+	}
+
+	var notRun []string
+	for _, result := range results.NotRun {
+		if result.Error != NotRunBudgetExceeded {
+			t.Errorf("Unexpected not-run error for %s: %q", result.Name, result.Error)
+		}
+		notRun = append(notRun, result.Name)
+	}
+	if !reflect.DeepEqual(notRun, []string{"TestSlow", "TestLater"}) || results.Budget != 5*time.Second {
+		t.Errorf("Expected TestSlow and TestLater not run, got %v", notRun)
+	}
+}
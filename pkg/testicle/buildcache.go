@@ -0,0 +1,257 @@
+package testicle
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/nzions/sharedgolibs/internal/fileutil"
+)
+
+// BuildCache keeps compiled test binaries (go test -c) keyed by a hash of
+// the sources they were built from, so an unchanged package re-runs its
+// tests without compiling or linking
+type BuildCache struct {
+	dir    string
+	logger *Logger
+
+	mu        sync.Mutex
+	hits      int
+	misses    int
+	toolchain string // go env output, part of every key
+}
+
+// DefaultBuildCacheDir returns the directory used when Config.CacheDir is
+// empty: testicle/bin under the user cache directory
+func DefaultBuildCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "testicle", "bin")
+}
+
+// NewBuildCache creates a build cache storing binaries in dir
+func NewBuildCache(dir string, logger *Logger) *BuildCache {
+	if dir == "" {
+		dir = DefaultBuildCacheDir()
+	}
+	return &BuildCache{dir: dir, logger: logger}
+}
+
+// Stats returns the number of cache hits and misses so far
+func (c *BuildCache) Stats() (hits, misses int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// Binary returns the path of a test binary for the package in packageDir,
+// building it on a cache miss. Binaries for older sources of the same
+// package are removed when a new one is built.
+func (c *BuildCache) Binary(ctx context.Context, packageDir string) (path string, hit bool, err error) {
+	key, importPath, err := c.key(ctx, packageDir)
+	if err != nil {
+		return "", false, err
+	}
+
+	prefix := strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(importPath) + "-"
+	path = filepath.Join(c.dir, prefix+key[:16]+".test")
+	if _, err := os.Stat(path); err == nil {
+		c.record(true)
+		c.logger.Debug("♻️  Build cache hit: %s", importPath)
+		return path, true, nil
+	}
+
+	c.record(false)
+	c.logger.Debug("🔨 Build cache miss, building: %s", importPath)
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return "", false, fmt.Errorf("creating build cache: %w", err)
+	}
+
+	// Build to a temporary name so a concurrent run never executes a
+	// partially written binary
+	tmpFile, err := fileutil.CreateTemp(path)
+	if err != nil {
+		return "", false, fmt.Errorf("creating test binary: %w", err)
+	}
+	tmpFile.Close()
+	tmp := tmpFile.Name()
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, "go", "test", "-c", "-o", tmp, ".")
+	cmd.Dir = packageDir
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		os.Remove(tmp)
+		return "", false, fmt.Errorf("go test -c: %v: %s", err, strings.TrimSpace(output.String()))
+	}
+	if info, err := os.Stat(tmp); err != nil || info.Size() == 0 {
+		os.Remove(tmp)
+		return "", false, fmt.Errorf("go test -c produced no binary for %s", importPath)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", false, fmt.Errorf("caching test binary: %w", err)
+	}
+
+	stale, _ := filepath.Glob(filepath.Join(c.dir, prefix+"*.test"))
+	for _, old := range stale {
+		if old != path {
+			os.Remove(old)
+		}
+	}
+	return path, false, nil
+}
+
+// record counts a hit or miss
+func (c *BuildCache) record(hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if hit {
+		c.hits++
+	} else {
+		c.misses++
+	}
+}
+
+// listedPackage holds the go list -json fields that determine a test binary
+type listedPackage struct {
+	ImportPath   string
+	Dir          string
+	Standard     bool
+	GoFiles      []string
+	CgoFiles     []string
+	CFiles       []string
+	HFiles       []string
+	SFiles       []string
+	EmbedFiles   []string
+	TestGoFiles  []string
+	XTestGoFiles []string
+	Module       *struct {
+		Path    string
+		Version string
+		Main    bool
+		Replace *struct{ Path string }
+	}
+}
+
+// key hashes everything the package's test binary is built from: the
+// toolchain and environment, then for every non-standard dependency either
+// its module version (for immutable modules) or the content of its files
+func (c *BuildCache) key(ctx context.Context, packageDir string) (key, importPath string, err error) {
+	toolchain, err := c.goEnv(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, "go", "list", "-deps", "-test", "-json", ".")
+	cmd.Dir = packageDir
+	cmd.Stdout = &output
+	cmd.Stderr = io.Discard
+	if err := cmd.Run(); err != nil {
+		return "", "", fmt.Errorf("go list %s: %w", packageDir, err)
+	}
+
+	hash := sha256.New()
+	fmt.Fprintln(hash, toolchain)
+
+	var packages []listedPackage
+	decoder := json.NewDecoder(&output)
+	for decoder.More() {
+		var pkg listedPackage
+		if err := decoder.Decode(&pkg); err != nil {
+			return "", "", fmt.Errorf("go list %s: %w", packageDir, err)
+		}
+		if !pkg.Standard {
+			packages = append(packages, pkg)
+		}
+	}
+	sort.Slice(packages, func(i, j int) bool { return packages[i].ImportPath < packages[j].ImportPath })
+
+	absDir, _ := filepath.Abs(packageDir)
+	for _, pkg := range packages {
+		fmt.Fprintln(hash, pkg.ImportPath)
+		if strings.HasSuffix(pkg.ImportPath, ".test") {
+			continue // The generated test main, derived from the test files
+		}
+		if pkg.Dir == absDir && importPath == "" && !strings.Contains(pkg.ImportPath, " ") {
+			importPath = pkg.ImportPath
+		}
+
+		if pkg.Module != nil && !pkg.Module.Main && pkg.Module.Replace == nil && pkg.Module.Version != "" {
+			fmt.Fprintln(hash, pkg.Module.Path, pkg.Module.Version)
+			continue
+		}
+
+		var files []string
+		for _, group := range [][]string{pkg.GoFiles, pkg.CgoFiles, pkg.CFiles, pkg.HFiles, pkg.SFiles, pkg.EmbedFiles, pkg.TestGoFiles, pkg.XTestGoFiles} {
+			files = append(files, group...)
+		}
+		sort.Strings(files)
+		for _, name := range files {
+			data, err := os.ReadFile(filepath.Join(pkg.Dir, name))
+			if err != nil {
+				return "", "", fmt.Errorf("hashing %s: %w", name, err)
+			}
+			fmt.Fprintln(hash, name, len(data))
+			hash.Write(data)
+		}
+	}
+
+	// go.mod and go.sum, and a workspace's go.work, decide dependency
+	// versions and build settings
+	for _, name := range []string{"go.mod", "go.sum", "go.work", "go.work.sum"} {
+		if path := findUp(absDir, name); path != "" {
+			if data, err := os.ReadFile(path); err == nil {
+				hash.Write(data)
+			}
+		}
+	}
+
+	if importPath == "" {
+		importPath = filepath.Base(absDir)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), importPath, nil
+}
+
+// goEnv returns the toolchain settings that affect compiled output, read once
+func (c *BuildCache) goEnv(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.toolchain != "" {
+		return c.toolchain, nil
+	}
+	output, err := exec.CommandContext(ctx, "go", "env", "GOVERSION", "GOOS", "GOARCH", "GOFLAGS", "CGO_ENABLED", "GOEXPERIMENT").Output()
+	if err != nil {
+		return "", fmt.Errorf("go env: %w", err)
+	}
+	c.toolchain = string(output)
+	return c.toolchain, nil
+}
+
+// findUp returns the path of name in dir or its closest ancestor, or ""
+func findUp(dir, name string) string {
+	for {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,87 @@
+package testicle
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuildCache(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds test binaries with go test -c")
+	}
+
+	module := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(module, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module example.com/warm\n\ngo 1.21\n")
+	write("warm.go", "package warm\n\nfunc Answer() int { return 42 }\n")
+	write("warm_test.go", "package warm\n\nimport \"testing\"\n\nfunc TestAnswer(t *testing.T) {\n\tif Answer() != 42 {\n\t\tt.Fatal(\"wrong answer\")\n\t}\n}\n")
+
+	cache := NewBuildCache(t.TempDir(), NewLogger(false))
+	ctx := context.Background()
+
+	first, hit, err := cache.Binary(ctx, module)
+	if err != nil {
+		t.Fatalf("Binary failed: %v", err)
+	}
+	if hit {
+		t.Error("Expected a miss on the first build")
+	}
+
+	second, hit, err := cache.Binary(ctx, module)
+	if err != nil || !hit || second != first {
+		t.Errorf("Expected a hit for unchanged sources, got %s hit=%v err=%v", second, hit, err)
+	}
+
+	write("warm.go", "package warm\n\nfunc Answer() int { return 6 * 7 }\n")
+	third, hit, err := cache.Binary(ctx, module)
+	if err != nil || hit || third == first {
+		t.Errorf("Expected a rebuild after a change, got %s hit=%v err=%v", third, hit, err)
+	}
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Error("Expected the stale binary to be removed")
+	}
+
+	if hits, misses := cache.Stats(); hits != 1 || misses != 2 {
+		t.Errorf("Expected 1 hit and 2 misses, got %d and %d", hits, misses)
+	}
+}
+
+func TestExecutorWarmBuild(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs a cached test binary on a fixture package")
+	}
+
+	dir, err := filepath.Abs(filepath.Join("testdata", "leaky"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests, err := NewDiscovery(dir, NewLogger(false)).DiscoverTests(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverTests failed: %v", err)
+	}
+
+	cache := NewBuildCache(t.TempDir(), NewLogger(false))
+	executor := NewExecutor(NewLogger(false))
+	executor.SetResultCallback(func(*TestResult) {})
+	executor.EnableBuildCache(cache)
+
+	for run := 0; run < 2; run++ {
+		results, err := executor.ExecuteTests(context.Background(), tests)
+		if err != nil {
+			t.Fatalf("ExecuteTests failed: %v", err)
+		}
+		if results.Passed == 0 || results.Failed == 0 {
+			t.Errorf("Run %d: expected the fixture's passing and leaking tests, got %d passed, %d failed", run, results.Passed, results.Failed)
+		}
+	}
+	if hits, misses := cache.Stats(); hits != 1 || misses != 1 {
+		t.Errorf("Expected the second run to reuse the binary, got %d hits and %d misses", hits, misses)
+	}
+}
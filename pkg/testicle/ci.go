@@ -0,0 +1,214 @@
+package testicle
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Exit codes of the testicle command, so CI can tell why a run failed
+const (
+	ExitCodeOK          = 0
+	ExitCodeTestsFailed = 1 // One or more tests failed
+	ExitCodeBuildFailed = 2 // Tests did not compile
+	ExitCodeVetFailed   = 3 // go vet reported issues
+	ExitCodeError       = 4 // testicle itself could not run (configuration, discovery)
+	ExitCodeSlowTests   = 5 // Tests passed, but the slow test gate failed the run
+)
+
+// Errors returned by Runner.Run for failed runs; see ExitCode
+var (
+	ErrTestsFailed = errors.New("tests failed")
+	ErrBuildFailed = errors.New("compilation failed")
+	ErrVetFailed   = errors.New("go vet found issues")
+)
+
+// ExitCode maps an error returned by Runner.Run to the process exit code
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitCodeOK
+	case errors.Is(err, ErrTestsFailed):
+		return ExitCodeTestsFailed
+	case errors.Is(err, ErrBuildFailed):
+		return ExitCodeBuildFailed
+	case errors.Is(err, ErrVetFailed):
+		return ExitCodeVetFailed
+	case errors.Is(err, ErrSlowTests):
+		return ExitCodeSlowTests
+	default:
+		return ExitCodeError
+	}
+}
+
+// outputLocation matches a t.Error/t.Log location in go test -v output,
+// e.g. "    parser_test.go:42: got 1, want 2"
+var outputLocation = regexp.MustCompile(`^(\s*)([^\s:]+\.go):(\d+): ?(.*)$`)
+
+// annotation is a GitHub Actions workflow command that attaches a message
+// to a file and line, e.g. "::error file=a_test.go,line=3,title=TestA::boom"
+type annotation struct {
+	file    string // Relative to the workspace; empty for run-level problems
+	line    int
+	column  int
+	title   string
+	message string
+}
+
+// String renders the annotation as an ::error workflow command
+func (a annotation) String() string {
+	var properties []string
+	if a.file != "" {
+		properties = append(properties, "file="+escapeProperty(a.file))
+		if a.line > 0 {
+			properties = append(properties, "line="+strconv.Itoa(a.line))
+		}
+		if a.column > 0 {
+			properties = append(properties, "col="+strconv.Itoa(a.column))
+		}
+	}
+	if a.title != "" {
+		properties = append(properties, "title="+escapeProperty(a.title))
+	}
+
+	command := "::error"
+	if len(properties) > 0 {
+		command += " " + strings.Join(properties, ",")
+	}
+	return command + "::" + escapeData(a.message)
+}
+
+// escapeData escapes an annotation message
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes an annotation property value
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// ciReporter writes GitHub Actions annotations for vet issues, compilation
+// errors, and failed tests. Paths are made relative to the workspace
+// (GITHUB_WORKSPACE, or the working directory) so they link to the source.
+type ciReporter struct {
+	w         io.Writer
+	workspace string
+}
+
+// newCIReporter creates a reporter writing annotations to w
+func newCIReporter(w io.Writer) *ciReporter {
+	workspace := os.Getenv("GITHUB_WORKSPACE")
+	if workspace == "" {
+		workspace, _ = os.Getwd()
+	}
+	return &ciReporter{w: w, workspace: workspace}
+}
+
+// emit writes one annotation per line
+func (c *ciReporter) emit(a annotation) {
+	a.file = c.relative(a.file)
+	fmt.Fprintln(c.w, a)
+}
+
+// relative returns path relative to the workspace, leaving paths outside it
+// absolute
+func (c *ciReporter) relative(path string) string {
+	if path == "" || c.workspace == "" {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(c.workspace, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return abs
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Validation annotates go vet issues and compilation errors
+func (c *ciReporter) Validation(result *ValidationResult) {
+	if result.VetResult != nil {
+		for _, issue := range result.VetResult.Errors {
+			if issue.Line == 0 {
+				continue // Not a location, e.g. a package header
+			}
+			message := issue.Message
+			if issue.Category != "" {
+				message = issue.Category + ": " + message
+			}
+			c.emit(annotation{file: issue.File, line: issue.Line, column: issue.Column, title: "go vet", message: message})
+		}
+	}
+	if result.CompileResult != nil {
+		for _, compileErr := range result.CompileResult.Errors {
+			if compileErr.Line == 0 {
+				continue
+			}
+			c.emit(annotation{file: compileErr.File, line: compileErr.Line, column: compileErr.Column, title: "build failed", message: compileErr.Message})
+		}
+	}
+}
+
+// RunEnd annotates every failed test
+func (c *ciReporter) RunEnd(results *TestResults) {
+	for _, result := range results.Tests {
+		if result.Status != TestStatusFailed {
+			continue
+		}
+		for _, a := range failureAnnotations(result) {
+			c.emit(a)
+		}
+	}
+}
+
+// failureAnnotations locates a failed test's messages in its output. Each
+// "file.go:line: message" line becomes an annotation, with more deeply
+// indented lines after it as continuation. Without any location the test is
+// annotated at its declaration.
+func failureAnnotations(result *TestResult) []annotation {
+	title := result.Name + " failed"
+	if result.Package != "" && result.File == "" {
+		title = result.Package + ": " + title // Non-Go suites and package-level results
+	}
+
+	var annotations []annotation
+	indent := -1 // Indentation of the current location line
+	for _, line := range strings.Split(result.Output, "\n") {
+		if match := outputLocation.FindStringSubmatch(line); match != nil {
+			file := match[2]
+			if !filepath.IsAbs(file) && result.File != "" {
+				file = filepath.Join(filepath.Dir(result.File), file)
+			}
+			lineNumber, _ := strconv.Atoi(match[3])
+			annotations = append(annotations, annotation{file: file, line: lineNumber, title: title, message: match[4]})
+			indent = len(match[1])
+			continue
+		}
+
+		trimmed := strings.TrimLeft(line, " \t")
+		if indent >= 0 && trimmed != "" && len(line)-len(trimmed) > indent {
+			last := &annotations[len(annotations)-1]
+			last.message += "\n" + trimmed
+			continue
+		}
+		indent = -1
+	}
+
+	if len(annotations) > 0 {
+		return annotations
+	}
+
+	message := result.Error
+	if message == "" {
+		message = "Test failed"
+	}
+	return []annotation{{file: result.File, line: result.Line, title: title, message: message}}
+}
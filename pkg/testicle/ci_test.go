@@ -0,0 +1,102 @@
+package testicle
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	cases := map[error]int{
+		nil:                                      ExitCodeOK,
+		fmt.Errorf("%w: 1 of 3", ErrTestsFailed): ExitCodeTestsFailed,
+		ErrBuildFailed:                           ExitCodeBuildFailed,
+		ErrVetFailed:                             ExitCodeVetFailed,
+		fmt.Errorf("test discovery failed"):      ExitCodeError,
+	}
+	for err, want := range cases {
+		if got := ExitCode(err); got != want {
+			t.Errorf("ExitCode(%v) = %d, want %d", err, got, want)
+		}
+	}
+}
+
+func TestAnnotationString(t *testing.T) {
+	a := annotation{file: "pkg/a_test.go", line: 12, column: 3, title: "TestA: case 1, x", message: "got 100%\nwant 0"}
+	want := "::error file=pkg/a_test.go,line=12,col=3,title=TestA%3A case 1%2C x::got 100%25%0Awant 0"
+	if got := a.String(); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	if got := (annotation{message: "boom"}).String(); got != "::error::boom" {
+		t.Errorf("Expected a bare annotation, got %q", got)
+	}
+}
+
+func TestParseGoTestOutputCapturesOutput(t *testing.T) {
+	output := `=== RUN   TestA
+    a_test.go:10: first
+=== RUN   TestA/sub
+    a_test.go:14: got 1
+        want 2
+--- FAIL: TestA (0.00s)
+    --- FAIL: TestA/sub (0.00s)
+=== RUN   TestB
+--- PASS: TestB (0.00s)
+FAIL
+`
+	tests := []*TestInfo{{Name: "TestA", File: "/src/pkg/a_test.go", Line: 8}, {Name: "TestB", File: "/src/pkg/a_test.go", Line: 20}}
+	results := NewExecutor(NewLoggerWithWriter(false, io.Discard)).parseGoTestOutput(output, tests, nil, nil, false)
+
+	if results.Failed != 1 || results.Passed != 1 {
+		t.Fatalf("Expected 1 failed and 1 passed, got %+v", results)
+	}
+	want := "    a_test.go:10: first\n    a_test.go:14: got 1\n        want 2\n"
+	if got := results.Tests[0].Output; got != want {
+		t.Errorf("Expected TestA output %q, got %q", want, got)
+	}
+	if results.Tests[1].Output != "" {
+		t.Errorf("Expected no TestB output, got %q", results.Tests[1].Output)
+	}
+}
+
+func TestCIReporter(t *testing.T) {
+	var buf bytes.Buffer
+	reporter := &ciReporter{w: &buf, workspace: "/src"}
+
+	reporter.Validation(&ValidationResult{
+		VetResult: &VetResult{Errors: []VetIssue{
+			{File: "/src/pkg/a.go", Line: 5, Column: 2, Message: "unreachable code"},
+			{File: "# example.com/pkg"},
+		}},
+		CompileResult: &CompileResult{Errors: []CompileError{{File: "/src/pkg/b_test.go", Line: 7, Column: 9, Message: "undefined: x"}}},
+	})
+	reporter.RunEnd(&TestResults{Tests: []*TestResult{
+		{Name: "TestA", File: "/src/pkg/a_test.go", Line: 8, Status: TestStatusFailed, Output: "    a_test.go:10: first\n        more\n    a_test.go:14: second\n"},
+		{Name: "TestB", File: "/src/pkg/a_test.go", Line: 20, Status: TestStatusFailed, Error: "--- FAIL: TestB (0.00s)"},
+		{Name: "TestC", File: "/src/pkg/a_test.go", Line: 30, Status: TestStatusPassed},
+		{Name: "should render", Package: "web", Status: TestStatusFailed, Error: "expected true"},
+		{Name: "TestOutside", File: "/elsewhere/c_test.go", Line: 1, Status: TestStatusFailed},
+	}})
+
+	want := []string{
+		"::error file=pkg/a.go,line=5,col=2,title=go vet::unreachable code",
+		"::error file=pkg/b_test.go,line=7,col=9,title=build failed::undefined: x",
+		"::error file=pkg/a_test.go,line=10,title=TestA failed::first%0Amore",
+		"::error file=pkg/a_test.go,line=14,title=TestA failed::second",
+		"::error file=pkg/a_test.go,line=20,title=TestB failed::--- FAIL: TestB (0.00s)",
+		"::error title=web%3A should render failed::expected true",
+		"::error file=/elsewhere/c_test.go,line=1,title=TestOutside failed::Test failed",
+	}
+	got := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d annotations, got %d:\n%s", len(want), len(got), buf.String())
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Annotation %d:\n  want %s\n  got  %s", i, want[i], got[i])
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package testicle
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// minClusterSize is the number of failures sharing a signature before they
+// are shown as a cluster
+const minClusterSize = 2
+
+// FailureCluster is a group of failed tests sharing a normalized error
+// signature, such as a dozen tests all refused by the same missing service
+type FailureCluster struct {
+	// Signature is the error with addresses, timestamps, durations, and
+	// similar run-specific values replaced, or for unreachable services the
+	// problem and the service, e.g. "connection refused to :8083"
+	Signature string `json:"signature"`
+
+	// Example is the first test's error as reported
+	Example string `json:"example"`
+
+	// Dependency is the address or host the tests could not reach, e.g.
+	// ":8083" for a port on this machine; empty for other failures
+	Dependency string `json:"dependency,omitempty"`
+
+	// Tests are the failed tests, as package.Test
+	Tests []string `json:"tests"`
+
+	// Runs counts the consecutive runs the cluster failed in, in daemon mode
+	Runs int `json:"runs"`
+}
+
+// String describes the cluster for the summary
+func (c FailureCluster) String() string {
+	s := fmt.Sprintf("%d tests failing with %s", len(c.Tests), c.Signature)
+	if c.Runs > 1 {
+		s += fmt.Sprintf(" (%d runs in a row)", c.Runs)
+	}
+	return s
+}
+
+// DependencyHint suggests how to check the cluster's likely missing
+// dependency, or returns "" for clusters without one
+func (c FailureCluster) DependencyHint() string {
+	if c.Dependency == "" {
+		return ""
+	}
+	if port, ok := strings.CutPrefix(c.Dependency, ":"); ok {
+		return fmt.Sprintf("Likely missing dependency: nothing is serving port %s (check with servicemanager -port=%s)", port, port)
+	}
+	return fmt.Sprintf("Likely missing dependency: %s is unreachable", c.Dependency)
+}
+
+// Failures of unreachable services, whose target is the likely missing
+// dependency. The first group is the address or host.
+var dependencyFailures = []struct {
+	pattern *regexp.Regexp
+	problem string // Prefixes the dependency in the signature
+}{
+	{regexp.MustCompile(`dial (?:tcp|tcp4|tcp6|udp|unix) (\S+?): connect: connection refused`), "connection refused to"},
+	{regexp.MustCompile(`dial (?:tcp|tcp4|tcp6) (\S+?): i/o timeout`), "timeout dialing"},
+	{regexp.MustCompile(`lookup (\S+?)(?: on \S+)?: no such host`), "no such host"},
+}
+
+// Run-specific values replaced in failure signatures, in order
+var signatureReplacements = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:?\d{2})?(?: [A-Z]{3,4})?`), "<time>"},
+	{regexp.MustCompile(`\b\d{2}:\d{2}:\d{2}(?:\.\d+)?\b`), "<time>"},
+	{regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`), "<uuid>"},
+	{regexp.MustCompile(`\b0x[0-9a-fA-F]+\b`), "0x?"},
+	{regexp.MustCompile(`(?:/tmp|/var/folders)/\S*`), "<tmp>"},
+	{regexp.MustCompile(`\[[\w:.%]+\](?::\d+)?`), "<addr>"},
+	{regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}(?::\d+)?\b`), "<addr>"},
+	{regexp.MustCompile(`\blocalhost:\d+\b`), "<addr>"},
+	{regexp.MustCompile(`\b(?:\d+(?:\.\d+)?(?:ns|µs|us|ms|s|m|h))+\b`), "<duration>"},
+}
+
+// hostPort matches a host:port outside file locations, for stripping ports
+var hostPort = regexp.MustCompile(`\b([\w.-]+):\d{2,5}\b`)
+
+// clusterFailures groups the failed tests of results by error signature.
+// Only signatures shared by at least minClusterSize tests form clusters,
+// largest first.
+func clusterFailures(results *TestResults) []FailureCluster {
+	clusters := make(map[string]*FailureCluster)
+	var order []string
+	for _, result := range results.Tests {
+		if result.Status != TestStatusFailed || strings.Contains(result.Name, "/") {
+			continue
+		}
+		message := clusterMessage(result)
+		if message == "" {
+			continue
+		}
+		signature, dependency := failureSignature(message)
+		cluster, ok := clusters[signature]
+		if !ok {
+			cluster = &FailureCluster{Signature: signature, Example: message, Dependency: dependency}
+			clusters[signature] = cluster
+			order = append(order, signature)
+		}
+		name := result.Name
+		if result.Package != "" {
+			name = result.Package + "." + result.Name
+		}
+		cluster.Tests = append(cluster.Tests, name)
+	}
+
+	var found []FailureCluster
+	for _, signature := range order {
+		if cluster := clusters[signature]; len(cluster.Tests) >= minClusterSize {
+			found = append(found, *cluster)
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return len(found[i].Tests) > len(found[j].Tests) })
+	return found
+}
+
+// clusterMessage returns the first message of a failed test: its first
+// "file.go:line: message", or panic, falling back to its error
+func clusterMessage(result *TestResult) string {
+	for _, line := range strings.Split(result.Output, "\n") {
+		if match := outputLocation.FindStringSubmatch(line); match != nil && strings.TrimSpace(match[4]) != "" {
+			return strings.TrimSpace(match[4])
+		}
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "panic: ") {
+			return trimmed
+		}
+	}
+	if strings.HasPrefix(strings.TrimSpace(result.Error), "--- FAIL") {
+		return "" // Only the result line, which names the test
+	}
+	return strings.TrimSpace(result.Error)
+}
+
+// failureSignature normalizes a failure message. Messages about an
+// unreachable service keep the service, as the likely missing dependency,
+// since that is what their tests have in common.
+func failureSignature(message string) (signature, dependency string) {
+	for _, failure := range dependencyFailures {
+		if match := failure.pattern.FindStringSubmatch(message); match != nil {
+			dependency = dependencyName(match[1])
+			return failure.problem + " " + dependency, dependency
+		}
+	}
+
+	signature = message
+	for _, r := range signatureReplacements {
+		signature = r.pattern.ReplaceAllString(signature, r.replacement)
+	}
+	signature = hostPort.ReplaceAllStringFunc(signature, func(s string) string {
+		host, _, _ := strings.Cut(s, ":")
+		if strings.HasSuffix(host, ".go") {
+			return s // A file location, not an address
+		}
+		return host + ":<port>"
+	})
+	return strings.Join(strings.Fields(signature), " "), ""
+}
+
+// dependencyName shortens an address on this machine to its port, as in
+// ":8083", since the host differs between loopback forms
+func dependencyName(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	if ip := net.ParseIP(host); host == "" || host == "localhost" || (ip != nil && (ip.IsLoopback() || ip.IsUnspecified())) {
+		return ":" + port
+	}
+	return address
+}
+
+// clusterTestList names the first few tests of a cluster for the summary
+func clusterTestList(tests []string) string {
+	const shown = 3
+	if len(tests) <= shown {
+		return strings.Join(tests, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(tests[:shown], ", "), len(tests)-shown)
+}
+
+// trackClusters counts the consecutive runs each cluster has failed in,
+// forgetting clusters that no longer fail
+func (r *Runner) trackClusters(clusters []FailureCluster) {
+	runs := make(map[string]int, len(clusters))
+	for i := range clusters {
+		clusters[i].Runs = r.clusterRuns[clusters[i].Signature] + 1
+		runs[clusters[i].Signature] = clusters[i].Runs
+	}
+	r.clusterRuns = runs
+}
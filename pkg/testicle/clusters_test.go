@@ -0,0 +1,73 @@
+package testicle
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFailureSignature(t *testing.T) {
+	tests := []struct {
+		message    string
+		signature  string
+		dependency string
+	}{
+		{`Get "http://127.0.0.1:8083/users": dial tcp 127.0.0.1:8083: connect: connection refused`, "connection refused to :8083", ":8083"},
+		{`dial tcp [::1]:5432: connect: connection refused`, "connection refused to :5432", ":5432"},
+		{`dial tcp 10.0.0.7:6379: i/o timeout`, "timeout dialing 10.0.0.7:6379", "10.0.0.7:6379"},
+		{`lookup postgres on 127.0.0.11:53: no such host`, "no such host postgres", "postgres"},
+		{`request at 2026-10-16T09:26:53.123Z to 10.1.2.3:41234 took 1.5s`, "request at <time> to <addr> took <duration>", ""},
+		{`object 0xc000123456 in /tmp/TestCache123/001/db not found`, "object 0x? in <tmp> not found", ""},
+		{`redis:6379 rejected id 0b2f1a4e-5c3d-4e6f-8a9b-1c2d3e4f5a6b`, "redis:<port> rejected id <uuid>", ""},
+		{`see cache.go:120 for details`, "see cache.go:120 for details", ""},
+	}
+	for _, tt := range tests {
+		signature, dependency := failureSignature(tt.message)
+		if signature != tt.signature || dependency != tt.dependency {
+			t.Errorf("failureSignature(%q) = %q, %q, want %q, %q", tt.message, signature, dependency, tt.signature, tt.dependency)
+		}
+	}
+}
+
+func TestClusterFailures(t *testing.T) {
+	refused := func(port string) string {
+		return "    api_test.go:21: dial tcp 127.0.0.1:" + port + ": connect: connection refused\n"
+	}
+	results := &TestResults{Tests: []*TestResult{
+		{Name: "TestUsers", Package: "api", Status: TestStatusFailed, Output: refused("8083")},
+		{Name: "TestOrders", Package: "api", Status: TestStatusFailed, Output: refused("8083")},
+		{Name: "TestOrders/refund", Package: "api", Status: TestStatusFailed, Output: refused("8083")},
+		{Name: "TestCart", Package: "shop", Status: TestStatusFailed, Output: "    cart_test.go:9: dial tcp localhost:8083: connect: connection refused\n"},
+		{Name: "TestTimeoutA", Package: "shop", Status: TestStatusFailed, Output: "    a_test.go:5: deadline after 1.2s at 10:00:01\n"},
+		{Name: "TestTimeoutB", Package: "shop", Status: TestStatusFailed, Output: "    b_test.go:8: deadline after 800ms at 10:00:02\n"},
+		{Name: "TestLone", Package: "shop", Status: TestStatusFailed, Output: "    c_test.go:3: got 1, want 2\n"},
+		{Name: "TestBare", Package: "shop", Status: TestStatusFailed, Error: "--- FAIL: TestBare (0.00s)"},
+		{Name: "TestBare2", Package: "shop", Status: TestStatusFailed, Error: "--- FAIL: TestBare2 (0.00s)"},
+		{Name: "TestPassing", Package: "api", Status: TestStatusPassed, Output: refused("8083")},
+	}}
+
+	clusters := clusterFailures(results)
+	if len(clusters) != 2 {
+		t.Fatalf("Expected 2 clusters, got %+v", clusters)
+	}
+	if got := clusters[0].String(); got != "3 tests failing with connection refused to :8083" {
+		t.Errorf("Unexpected cluster %q", got)
+	}
+	if strings.Join(clusters[0].Tests, ",") != "api.TestUsers,api.TestOrders,shop.TestCart" || !strings.Contains(clusters[0].DependencyHint(), "servicemanager -port=8083") {
+		t.Errorf("Unexpected cluster %+v", clusters[0])
+	}
+	if clusters[1].Signature != "deadline after <duration> at <time>" || clusters[1].DependencyHint() != "" || clusters[1].Example != "deadline after 1.2s at 10:00:01" {
+		t.Errorf("Unexpected cluster %+v", clusters[1])
+	}
+
+	// Clusters that keep failing count their runs; fixed ones are forgotten
+	r := &Runner{}
+	r.trackClusters(clusters)
+	again := clusterFailures(results)
+	r.trackClusters(again[:1])
+	if again[0].Runs != 2 || !strings.HasSuffix(again[0].String(), "(2 runs in a row)") || len(r.clusterRuns) != 1 {
+		t.Errorf("Expected the cluster to count 2 runs, got %+v", again[0])
+	}
+	if got := clusterTestList([]string{"a", "b", "c", "d", "e"}); got != "a, b, c and 2 more" {
+		t.Errorf("clusterTestList = %q", got)
+	}
+}
@@ -0,0 +1,381 @@
+package testicle
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nzions/sharedgolibs/pkg/util"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigFile is the configuration file looked up when none is given
+const DefaultConfigFile = "testicle.yaml"
+
+// FileConfig is the content of testicle.yaml. The yaml tags are the schema:
+// unknown keys are rejected, and the schema tag marks required keys,
+// durations, sizes, percentages, and values restricted to a set (see
+// schemaEnums).
+type FileConfig struct {
+	Reporter         string          `yaml:"reporter" schema:"enum=reporter"` // default or json-stream
+	Diff             string          `yaml:"diff" schema:"enum=diff"`         // inline or side-by-side
+	NoVet            bool            `yaml:"no_vet"`
+	NoBuildCheck     bool            `yaml:"no_build_check"`
+	MonitorResources bool            `yaml:"monitor_resources"`
+	LeakCheck        bool            `yaml:"leak_check"`
+	WarmBuild        bool            `yaml:"warm_build"`
+	CacheDir         string          `yaml:"cache_dir"`
+	SkipList         string          `yaml:"skip_list"`
+	ArtifactsDir     string          `yaml:"artifacts_dir"`
+	Retention        RetentionConfig `yaml:"retention"`
+	MetadataEnv      []string        `yaml:"metadata_env"`
+	Watch            WatchConfig     `yaml:"watch"`
+	Suites           []SuiteConfig   `yaml:"suites"`
+	SlowTests        SlowTestConfig  `yaml:"slow_tests"`
+
+	// GoTestArgs are passed to every go test run, followed by those of the
+	// matching Packages entries (see PackageConfig)
+	GoTestArgs []string                 `yaml:"go_test_args"`
+	Packages   map[string]PackageConfig `yaml:"packages"`
+
+	// OTel exports runs as OpenTelemetry spans (see OTelConfig)
+	OTel OTelConfig `yaml:"otel"`
+
+	// Profiles are named settings selected with --profile
+	Profiles map[string]ProfileConfig `yaml:"profiles"`
+}
+
+// schemaEnums lists the allowed values for schema:"enum=<name>" fields
+var schemaEnums = map[string]func() []string{
+	"reporter":         func() []string { return []string{ReporterDefault, ReporterJSONStream} },
+	"diff":             func() []string { return []string{DiffInline, DiffSideBySide} },
+	"suite_type":       adapterTypes,
+	"slow_test_action": func() []string { return []string{SlowTestActionWarn, SlowTestActionFail} },
+}
+
+// ConfigProblem is a single schema violation in a config file
+type ConfigProblem struct {
+	Line    int
+	Column  int
+	Path    string // Key path, e.g. suites[0].timeout
+	Message string
+}
+
+// ConfigError lists every schema violation found in a config file
+type ConfigError struct {
+	File     string
+	Problems []ConfigProblem
+}
+
+// Error lists the problems one per line as file:line:column: path: message
+func (e *ConfigError) Error() string {
+	lines := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		lines[i] = fmt.Sprintf("%s:%d:%d: %s: %s", e.File, p.Line, p.Column, p.Path, p.Message)
+	}
+	return "invalid config file:\n  " + strings.Join(lines, "\n  ")
+}
+
+// LoadConfigFile reads and validates a testicle.yaml file
+func LoadConfigFile(path string) (*FileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+	return ParseConfig(path, data)
+}
+
+// ParseConfig validates data against the FileConfig schema and decodes it.
+// Schema violations are returned together as a *ConfigError; name is used
+// in its messages.
+func ParseConfig(name string, data []byte) (*FileConfig, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", name, err)
+	}
+
+	var fileConfig FileConfig
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 || root.Content[0].ShortTag() == "!!null" {
+		return &fileConfig, nil // Empty file
+	}
+
+	checker := &schemaChecker{}
+	checker.check(root.Content[0], reflect.TypeOf(fileConfig), "", "")
+	if len(checker.problems) > 0 {
+		return nil, &ConfigError{File: name, Problems: checker.problems}
+	}
+
+	if err := root.Decode(&fileConfig); err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", name, err)
+	}
+	return &fileConfig, nil
+}
+
+// schemaChecker walks a YAML node tree against a Go type
+type schemaChecker struct {
+	problems []ConfigProblem
+}
+
+// add records a problem at node
+func (c *schemaChecker) add(node *yaml.Node, path, format string, args ...interface{}) {
+	if path == "" {
+		path = "(root)"
+	}
+	c.problems = append(c.problems, ConfigProblem{
+		Line:    node.Line,
+		Column:  node.Column,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// check validates node against t; schema is the field's schema tag
+func (c *schemaChecker) check(node *yaml.Node, t reflect.Type, path, schema string) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		c.checkStruct(node, t, path)
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			c.add(node, path, "expected a list")
+			return
+		}
+		for i, item := range node.Content {
+			c.check(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), "")
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			c.add(node, path, "expected a mapping of keys to values")
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			c.check(node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value), "")
+		}
+	case reflect.Bool:
+		if node.Kind != yaml.ScalarNode || node.ShortTag() != "!!bool" {
+			c.add(node, path, "expected true or false, got %q", node.Value)
+		}
+	case reflect.String:
+		if node.Kind != yaml.ScalarNode {
+			c.add(node, path, "expected a single value")
+			return
+		}
+		c.checkString(node, path, schema)
+	}
+}
+
+// checkStruct validates a mapping against the yaml fields of a struct
+func (c *schemaChecker) checkStruct(node *yaml.Node, t reflect.Type, path string) {
+	if node.Kind != yaml.MappingNode {
+		c.add(node, path, "expected a mapping of keys to values")
+		return
+	}
+
+	fields := make(map[string]reflect.StructField)
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		fields[name] = field
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	seen := make(map[string]bool)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		field, ok := fields[key.Value]
+		if !ok {
+			c.add(key, joinPath(path, key.Value), "unknown key%s", suggest(key.Value, names, "valid keys"))
+			continue
+		}
+		if seen[key.Value] {
+			c.add(key, joinPath(path, key.Value), "duplicate key")
+		}
+		seen[key.Value] = true
+		c.check(value, field.Type, joinPath(path, key.Value), field.Tag.Get("schema"))
+	}
+
+	for _, name := range names {
+		if hasSchemaOption(fields[name].Tag.Get("schema"), "required") && !seen[name] {
+			c.add(node, path, "missing required key %q", name)
+		}
+	}
+}
+
+// checkString validates a string value against its schema options
+func (c *schemaChecker) checkString(node *yaml.Node, path, schema string) {
+	value := node.Value
+	if hasSchemaOption(schema, "required") && strings.TrimSpace(value) == "" {
+		c.add(node, path, "must not be empty")
+		return
+	}
+	if value == "" {
+		return
+	}
+
+	if hasSchemaOption(schema, "duration") {
+		if _, err := time.ParseDuration(value); err != nil {
+			c.add(node, path, "invalid duration %q (use a Go duration such as 30s, 5m, or 1h30m)", value)
+		}
+	}
+
+	if hasSchemaOption(schema, "bytesize") {
+		if _, err := ParseByteSize(value); err != nil {
+			c.add(node, path, "invalid size %q (use a size such as 512KB, 500MB, or 2GB)", value)
+		}
+	}
+
+	if hasSchemaOption(schema, "percent") {
+		if _, err := parsePercent(value); err != nil {
+			c.add(node, path, "%v", err)
+		}
+	}
+
+	for _, option := range strings.Split(schema, ",") {
+		enum, ok := strings.CutPrefix(option, "enum=")
+		if !ok {
+			continue
+		}
+		allowed := schemaEnums[enum]()
+		found := false
+		for _, a := range allowed {
+			found = found || a == value
+		}
+		if !found {
+			c.add(node, path, "unknown value %q%s", value, suggest(value, allowed, "expected one of"))
+		}
+	}
+}
+
+// hasSchemaOption reports whether a schema tag contains option
+func hasSchemaOption(schema, option string) bool {
+	for _, o := range strings.Split(schema, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// joinPath appends a key to a key path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// suggest returns a hint for a misspelled value: the closest candidate when
+// it is within two edits, otherwise the full list of candidates
+func suggest(value string, candidates []string, listLabel string) string {
+	best, bestDistance := "", 3
+	for _, candidate := range candidates {
+		if d := util.EditDistance(strings.ToLower(value), candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	if best != "" {
+		return fmt.Sprintf(" (did you mean %q?)", best)
+	}
+	return fmt.Sprintf(" (%s: %s)", listLabel, strings.Join(candidates, ", "))
+}
+
+// findConfigFile resolves config.ConfigFile. The default name is optional and
+// is looked up in the working directory and then the test directory.
+func findConfigFile(config *Config) (string, bool) {
+	if config.ConfigFile == "" {
+		return "", false
+	}
+	if config.ConfigFile != DefaultConfigFile {
+		return config.ConfigFile, true
+	}
+
+	for _, candidate := range []string{DefaultConfigFile, filepath.Join(config.Dir, DefaultConfigFile)} {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// applyConfigFile merges testicle.yaml into config. Settings already present
+// on config take precedence: flags that are switched on stay on, and a
+// reporter other than the default is kept.
+func applyConfigFile(config *Config) error {
+	path, ok := findConfigFile(config)
+	if !ok {
+		return resolveProfile(config, nil)
+	}
+
+	fileConfig, err := LoadConfigFile(path)
+	if err != nil {
+		return err
+	}
+	if err := resolveProfile(config, fileConfig.Profiles); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if fileConfig.Reporter != "" && (config.Reporter == "" || config.Reporter == ReporterDefault) {
+		config.Reporter = fileConfig.Reporter
+	}
+	if fileConfig.Diff != "" && (config.Diff == "" || config.Diff == DiffInline) {
+		config.Diff = fileConfig.Diff
+	}
+	config.NoVet = config.NoVet || fileConfig.NoVet
+	config.NoBuildCheck = config.NoBuildCheck || fileConfig.NoBuildCheck
+	config.MonitorResources = config.MonitorResources || fileConfig.MonitorResources
+	config.LeakCheck = config.LeakCheck || fileConfig.LeakCheck
+	config.WarmBuild = config.WarmBuild || fileConfig.WarmBuild
+	if config.CacheDir == "" {
+		config.CacheDir = fileConfig.CacheDir
+	}
+	if config.SkipListFile == "" {
+		config.SkipListFile = fileConfig.SkipList
+	}
+	if config.ArtifactsDir == "" {
+		config.ArtifactsDir = fileConfig.ArtifactsDir
+	}
+	if config.Retention == (RetentionConfig{}) {
+		config.Retention = fileConfig.Retention
+	}
+	if len(config.MetadataEnv) == 0 {
+		config.MetadataEnv = fileConfig.MetadataEnv
+	}
+	if len(config.Watch.Include) == 0 {
+		config.Watch.Include = fileConfig.Watch.Include
+	}
+	if len(config.Watch.Exclude) == 0 {
+		config.Watch.Exclude = fileConfig.Watch.Exclude
+	}
+	if config.Watch.Debounce == "" {
+		config.Watch.Debounce = fileConfig.Watch.Debounce
+	}
+
+	if len(config.Suites) == 0 {
+		config.Suites = fileConfig.Suites
+	}
+	if config.SlowTests == (SlowTestConfig{}) {
+		config.SlowTests = fileConfig.SlowTests
+	}
+	if len(config.GoTestArgs) == 0 {
+		config.GoTestArgs = fileConfig.GoTestArgs
+	}
+	if len(config.Packages) == 0 {
+		config.Packages = fileConfig.Packages
+	}
+	if config.OTel.Endpoint == "" {
+		config.OTel = fileConfig.OTel
+	}
+	return nil
+}
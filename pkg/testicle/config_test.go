@@ -0,0 +1,131 @@
+package testicle
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseConfigValid(t *testing.T) {
+	config, err := ParseConfig("testicle.yaml", []byte(`reporter: json-stream
+no_vet: true
+leak_check: true
+suites:
+  - name: shell
+    type: tap
+    command: bats --tap scripts
+    timeout: 1m30s
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	if config.Reporter != ReporterJSONStream || !config.NoVet || !config.LeakCheck || len(config.Suites) != 1 {
+		t.Errorf("Unexpected config: %+v", config)
+	}
+
+	if config, err := ParseConfig("empty.yaml", nil); err != nil || config == nil {
+		t.Errorf("Expected empty config to be valid, got %v", err)
+	}
+}
+
+func TestParseConfigProblems(t *testing.T) {
+	_, err := ParseConfig("testicle.yaml", []byte(`reportr: default
+no_vet: maybe
+monitor: true
+suites:
+  - name: web
+    type: junitt
+    timeout: 5 minutes
+    env: [CI]
+`))
+
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("Expected *ConfigError, got %v", err)
+	}
+
+	expected := []string{
+		`testicle.yaml:1:1: reportr: unknown key (did you mean "reporter"?)`,
+		`testicle.yaml:2:9: no_vet: expected true or false, got "maybe"`,
+		`testicle.yaml:3:1: monitor: unknown key (valid keys: artifacts_dir, cache_dir, diff, go_test_args, leak_check, metadata_env, monitor_resources, no_build_check, no_vet, otel, packages, profiles, reporter, retention, skip_list, slow_tests, suites, warm_build, watch)`,
+		`testicle.yaml:6:11: suites[0].type: unknown value "junitt" (did you mean "junit"?)`,
+		`testicle.yaml:7:14: suites[0].timeout: invalid duration "5 minutes" (use a Go duration such as 30s, 5m, or 1h30m)`,
+		`testicle.yaml:8:10: suites[0].env: expected a mapping of keys to values`,
+		`testicle.yaml:5:5: suites[0]: missing required key "command"`,
+	}
+	message := err.Error()
+	for _, want := range expected {
+		if !strings.Contains(message, want) {
+			t.Errorf("Expected %q in:\n%s", want, message)
+		}
+	}
+	if len(configErr.Problems) != len(expected) {
+		t.Errorf("Expected %d problems, got %d:\n%s", len(expected), len(configErr.Problems), message)
+	}
+}
+
+func TestApplyConfigFileSettings(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, DefaultConfigFile), []byte("reporter: json-stream\nno_vet: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Config{Dir: dir, ConfigFile: DefaultConfigFile, Reporter: ReporterDefault, LeakCheck: true}
+	if err := applyConfigFile(config); err != nil {
+		t.Fatalf("applyConfigFile failed: %v", err)
+	}
+	if config.Reporter != ReporterJSONStream || !config.NoVet || !config.LeakCheck {
+		t.Errorf("Expected file settings merged with flags, got %+v", config)
+	}
+}
+
+func TestStarterConfig(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":                "module example.com/app\n\ngo 1.21\n",
+		"pkg/a/a_test.go":       "package a\n\nimport \"testing\"\n\nfunc TestOne(t *testing.T) {}\nfunc TestTwo(t *testing.T) {}\n",
+		"web/package.json":      `{"scripts": {"test": "jest"}}`,
+		"scripts/tests/x.bats":  "@test \"x\" { true; }\n",
+		"node_modules/p/x.bats": "",
+	}
+	writeFiles(t, dir, files)
+
+	path, err := InitConfig(context.Background(), dir, false)
+	if err != nil {
+		t.Fatalf("InitConfig failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	content := string(data)
+
+	for _, want := range []string{
+		"# Module example.com/app: 1 package(s) with 2 test(s)",
+		"#   example.com/app/pkg/a (2)",
+		"# Found: web/package.json has a test script",
+		"#    dir: web",
+		"# Found: scripts/tests has bats tests",
+		"#    command: bats --tap scripts/tests",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Expected %q in starter config:\n%s", want, content)
+		}
+	}
+	if strings.Contains(content, "node_modules") {
+		t.Error("Starter config should skip node_modules")
+	}
+
+	// The starter config passes its own schema
+	if _, err := ParseConfig(path, data); err != nil {
+		t.Errorf("Starter config is invalid: %v", err)
+	}
+
+	// Existing files are kept unless forced
+	if _, err := InitConfig(context.Background(), dir, false); err == nil {
+		t.Error("Expected an error for an existing config file")
+	}
+	if _, err := InitConfig(context.Background(), dir, true); err != nil {
+		t.Errorf("Expected --force to overwrite, got %v", err)
+	}
+}
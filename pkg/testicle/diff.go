@@ -0,0 +1,553 @@
+package testicle
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// Diff layouts for Config.Diff
+const (
+	DiffInline     = "inline"
+	DiffSideBySide = "side-by-side"
+)
+
+// Built-in diff matcher names, in the order they are tried
+const (
+	MatcherTestify = "testify"
+	MatcherCmp     = "cmp"
+	MatcherWantGot = "want-got"
+)
+
+// DiffOp marks a diff line as common to both sides or only in one
+type DiffOp string
+
+const (
+	DiffEqual  DiffOp = "equal"
+	DiffDelete DiffOp = "delete" // Only expected
+	DiffInsert DiffOp = "insert" // Only actual
+)
+
+// DiffLine is one line of a diff between expected and actual values
+type DiffLine struct {
+	Op   DiffOp `json:"op"`
+	Text string `json:"text"`
+}
+
+// Diff is an expected/actual pair found in a failed test's output
+type Diff struct {
+	Matcher  string     `json:"matcher"`           // Name of the matcher that found it
+	Message  string     `json:"message,omitempty"` // Assertion message, e.g. "Add(1, 2)"
+	Expected string     `json:"expected"`
+	Actual   string     `json:"actual"`
+	Lines    []DiffLine `json:"lines"`
+}
+
+// DiffMatcher finds expected/actual pairs of one failure format in test
+// output. It returns nil when the format isn't present. Lines may be left
+// empty; ParseDiffs computes them from Expected and Actual.
+type DiffMatcher func(output string) []*Diff
+
+type namedMatcher struct {
+	name  string
+	match DiffMatcher
+}
+
+var (
+	diffMatcherMutex sync.RWMutex
+	diffMatchers     = []namedMatcher{
+		{MatcherTestify, matchTestify},
+		{MatcherCmp, matchCmpDiff},
+		{MatcherWantGot, matchWantGot},
+	}
+)
+
+// RegisterDiffMatcher adds a failure format for custom assertion libraries.
+// Registered matchers are tried before the built-in ones, most recent first;
+// registering an existing name replaces it.
+func RegisterDiffMatcher(name string, matcher DiffMatcher) {
+	diffMatcherMutex.Lock()
+	defer diffMatcherMutex.Unlock()
+	for i, m := range diffMatchers {
+		if m.name == name {
+			diffMatchers = append(diffMatchers[:i], diffMatchers[i+1:]...)
+			break
+		}
+	}
+	diffMatchers = append([]namedMatcher{{name, matcher}}, diffMatchers...)
+}
+
+// ParseDiffs returns the expected/actual pairs in a failed test's output,
+// from the first matcher that recognises its format
+func ParseDiffs(output string) []*Diff {
+	if output == "" {
+		return nil
+	}
+	diffMatcherMutex.RLock()
+	matchers := append([]namedMatcher(nil), diffMatchers...)
+	diffMatcherMutex.RUnlock()
+
+	for _, m := range matchers {
+		diffs := m.match(output)
+		if len(diffs) == 0 {
+			continue
+		}
+		for _, d := range diffs {
+			d.Matcher = m.name
+			if len(d.Lines) == 0 {
+				d.Lines = lineDiff(splitLines(d.Expected), splitLines(d.Actual))
+			}
+		}
+		return diffs
+	}
+	return nil
+}
+
+// logLocation matches the "file_test.go:12: " prefix of t.Log output
+var logLocation = regexp.MustCompile(`^\s*[\w.\-]+\.go:\d+:\s?`)
+
+// logMessage strips indentation and the source location from a log line
+func logMessage(line string) string {
+	return strings.TrimSpace(logLocation.ReplaceAllString(line, ""))
+}
+
+// indentOf returns the width of a line's leading whitespace
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// wantGotPatterns match one-line failures; each captures the message and
+// the expected and actual values at the given groups
+var wantGotPatterns = []struct {
+	re               *regexp.Regexp
+	expected, actual int
+}{
+	{regexp.MustCompile(`^(.*?)\bgot:?\s+(.+?)[,;]?\s+want(?:ed)?:?\s+(.+)$`), 3, 2},
+	{regexp.MustCompile(`^(.*?)\bwant(?:ed)?:?\s+(.+?)[,;]?\s+got:?\s+(.+)$`), 2, 3},
+	{regexp.MustCompile(`(?i)^(.*?)\bexpected:?\s+(.+?)[,;]?\s+(?:but\s+)?(?:got|was|actual:?)\s+(.+)$`), 2, 3},
+	{regexp.MustCompile(`^(.*?)\s=\s(.+?),\s+want\s+(.+)$`), 3, 2},
+}
+
+// blockLabel matches a "got:" or "want:" line introducing a value on the
+// same line or the lines below
+var blockLabel = regexp.MustCompile(`(?i)^(got|want|expected|actual)\s*:\s*(.*)$`)
+
+// matchWantGot finds the Go idioms "f() = got, want want", "got X, want Y",
+// and "expected X, got Y", and multi-line "got:" / "want:" blocks
+func matchWantGot(output string) []*Diff {
+	var diffs []*Diff
+	lines := splitLines(output)
+	message := ""
+	for i := 0; i < len(lines); i++ {
+		text := logMessage(lines[i])
+		if blockLabel.MatchString(text) {
+			if d, next := wantGotBlocks(lines, i); d != nil {
+				d.Message = message
+				diffs = append(diffs, d)
+				i = next - 1
+				continue
+			}
+		}
+		if logLocation.MatchString(lines[i]) {
+			message = strings.TrimSuffix(text, ":")
+		}
+
+		for _, p := range wantGotPatterns {
+			if m := p.re.FindStringSubmatch(text); m != nil {
+				diffs = append(diffs, &Diff{
+					Message:  strings.TrimRight(m[1], " :,;"),
+					Expected: strings.TrimSpace(m[p.expected]),
+					Actual:   strings.TrimSpace(m[p.actual]),
+				})
+				break
+			}
+		}
+	}
+	return diffs
+}
+
+// wantGotBlocks collects labelled blocks starting at lines[start] until a
+// less indented line, the next log message, or a repeated label. It returns
+// nil unless both an expected and an actual block were found, and the index
+// after the blocks.
+func wantGotBlocks(lines []string, start int) (*Diff, int) {
+	indent := indentOf(lines[start])
+	blocks := make(map[string][]string)
+	label := ""
+	i := start
+	for ; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) != "" && indentOf(lines[i]) < indent {
+			break
+		}
+		text := logMessage(lines[i])
+		if m := blockLabel.FindStringSubmatch(text); m != nil {
+			label = strings.ToLower(m[1])
+			if _, seen := blocks[label]; seen {
+				break // A second assertion starts
+			}
+			blocks[label] = []string{}
+			if m[2] != "" {
+				blocks[label] = append(blocks[label], m[2])
+			}
+			continue
+		}
+		if i > start && logLocation.MatchString(lines[i]) {
+			break // The next log message
+		}
+		blocks[label] = append(blocks[label], strings.TrimSpace(lines[i]))
+	}
+
+	expected, hasExpected := blocks["want"]
+	if !hasExpected {
+		expected, hasExpected = blocks["expected"]
+	}
+	actual, hasActual := blocks["got"]
+	if !hasActual {
+		actual, hasActual = blocks["actual"]
+	}
+	if !hasExpected || !hasActual {
+		return nil, start + 1
+	}
+	return &Diff{Expected: strings.Join(trimBlank(expected), "\n"), Actual: strings.Join(trimBlank(actual), "\n")}, i
+}
+
+// cmpHeader matches the "(-want +got)" legend printed before cmp.Diff output
+var cmpHeader = regexp.MustCompile(`\(-(want|expected|exp|got|actual|act) \+(want|expected|exp|got|actual|act)\)`)
+
+// matchCmpDiff finds go-cmp diffs: a legend such as "(-want +got):" followed
+// by more deeply indented lines marked "-", "+", or unmarked
+func matchCmpDiff(output string) []*Diff {
+	var diffs []*Diff
+	lines := splitLines(output)
+	for i := 0; i < len(lines); i++ {
+		legend := cmpHeader.FindStringSubmatch(lines[i])
+		if legend == nil {
+			continue
+		}
+		// "-" marks the first side of the legend
+		minusIsActual := legend[1] == "got" || legend[1] == "actual" || legend[1] == "act"
+
+		d := &Diff{Message: strings.TrimRight(cmpHeader.ReplaceAllString(logMessage(lines[i]), ""), " :")}
+		indent := indentOf(lines[i])
+		var expected, actual []string
+		j := i + 1
+		for ; j < len(lines); j++ {
+			line := lines[j]
+			if strings.TrimSpace(line) == "" || indentOf(line) <= indent || strings.HasPrefix(strings.TrimSpace(line), "--- ") {
+				break
+			}
+			text := strings.TrimLeft(line, " \t")
+			op := DiffEqual
+			switch {
+			case strings.HasPrefix(text, "-"):
+				op = DiffDelete
+			case strings.HasPrefix(text, "+"):
+				op = DiffInsert
+			}
+			if op != DiffEqual {
+				text = text[1:]
+			}
+			// cmp randomly pads with non-breaking spaces to discourage parsing
+			text = strings.TrimLeft(strings.ReplaceAll(text, "\u00a0", " "), " ")
+			if minusIsActual && op != DiffEqual {
+				op = map[DiffOp]DiffOp{DiffDelete: DiffInsert, DiffInsert: DiffDelete}[op]
+			}
+
+			d.Lines = append(d.Lines, DiffLine{Op: op, Text: text})
+			if op != DiffInsert {
+				expected = append(expected, text)
+			}
+			if op != DiffDelete {
+				actual = append(actual, text)
+			}
+		}
+		if len(d.Lines) > 0 {
+			d.Expected = strings.Join(expected, "\n")
+			d.Actual = strings.Join(actual, "\n")
+			diffs = append(diffs, d)
+		}
+		i = j - 1
+	}
+	return diffs
+}
+
+// matchTestify finds testify assertion blocks ("Error Trace:", "Error:",
+// "expected:", "actual  :", and an optional unified "Diff:")
+func matchTestify(output string) []*Diff {
+	var diffs []*Diff
+	var current *Diff
+	field := ""
+	inDiff := false
+
+	for _, line := range splitLines(output) {
+		// Fields are "<indent>\t<Label:>\t<value>"; continuations have a blank label
+		text := strings.TrimLeft(line, " ")
+		if !strings.HasPrefix(text, "\t") {
+			current, field = nil, ""
+			continue
+		}
+		label, value, ok := strings.Cut(text[1:], "\t")
+		if !ok {
+			continue
+		}
+		label = strings.TrimSpace(label)
+		if label != "" {
+			field = strings.TrimSuffix(label, ":")
+			inDiff = false
+		}
+
+		switch {
+		case field == "Error Trace":
+			current = nil
+		case field == "Error" && label != "":
+			current = &Diff{Message: strings.TrimRight(strings.TrimSpace(value), " :")}
+			diffs = append(diffs, current)
+		case field == "Error" && current != nil:
+			trimmed := strings.TrimSpace(value)
+			switch {
+			case inDiff:
+				current.Lines = appendUnifiedLine(current.Lines, value)
+			case trimmed == "Diff:":
+				inDiff = true
+				current.Lines = nil
+			case strings.HasPrefix(trimmed, "expected:"):
+				current.Expected = strings.TrimSpace(strings.TrimPrefix(trimmed, "expected:"))
+			case strings.HasPrefix(trimmed, "actual"):
+				if _, v, ok := strings.Cut(trimmed, ":"); ok {
+					current.Actual = strings.TrimSpace(v)
+				}
+			}
+		case field == "Messages" && current != nil:
+			current.Message += ": " + strings.TrimSpace(value)
+		}
+	}
+
+	// Only assertions that compared two values
+	var found []*Diff
+	for _, d := range diffs {
+		if d.Expected != "" || d.Actual != "" || len(d.Lines) > 0 {
+			found = append(found, d)
+		}
+	}
+	return found
+}
+
+// appendUnifiedLine adds a line of a unified diff, skipping its headers
+func appendUnifiedLine(lines []DiffLine, line string) []DiffLine {
+	switch {
+	case strings.HasPrefix(line, "--- "), strings.HasPrefix(line, "+++ "), strings.HasPrefix(line, "@@"):
+		return lines
+	case strings.HasPrefix(line, "-"):
+		return append(lines, DiffLine{Op: DiffDelete, Text: line[1:]})
+	case strings.HasPrefix(line, "+"):
+		return append(lines, DiffLine{Op: DiffInsert, Text: line[1:]})
+	case line == "":
+		return lines
+	default:
+		return append(lines, DiffLine{Op: DiffEqual, Text: strings.TrimPrefix(line, " ")})
+	}
+}
+
+// maxLineDiffCells bounds the LCS table; larger inputs are shown as a
+// whole-value replacement
+const maxLineDiffCells = 1 << 20
+
+// lineDiff computes a line diff of expected and actual with a longest
+// common subsequence
+func lineDiff(expected, actual []string) []DiffLine {
+	n, m := len(expected), len(actual)
+	if n*m > maxLineDiffCells {
+		var lines []DiffLine
+		for _, text := range expected {
+			lines = append(lines, DiffLine{Op: DiffDelete, Text: text})
+		}
+		for _, text := range actual {
+			lines = append(lines, DiffLine{Op: DiffInsert, Text: text})
+		}
+		return lines
+	}
+
+	// lcs[i][j] is the LCS length of expected[i:] and actual[j:]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if expected[i] == actual[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []DiffLine
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && expected[i] == actual[j]:
+			lines = append(lines, DiffLine{Op: DiffEqual, Text: expected[i]})
+			i++
+			j++
+		case j < m && (i == n || lcs[i][j+1] > lcs[i+1][j]):
+			lines = append(lines, DiffLine{Op: DiffInsert, Text: actual[j]})
+			j++
+		default:
+			lines = append(lines, DiffLine{Op: DiffDelete, Text: expected[i]})
+			i++
+		}
+	}
+	return lines
+}
+
+// splitLines splits text into lines without a trailing empty line
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimRight(text, "\n"), "\n")
+}
+
+// trimBlank drops leading and trailing blank lines
+func trimBlank(lines []string) []string {
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// DiffStyle selects how RenderDiff lays out a diff
+type DiffStyle struct {
+	Layout string // DiffInline (default) or DiffSideBySide
+	Width  int    // Total width for side-by-side; default 100
+	Color  bool   // ANSI colors: expected red, actual green
+}
+
+// RenderDiff renders a diff as lines for the console. Inline diffs mark
+// expected lines "-" and actual lines "+", highlighting the changed part of
+// single changed lines; side-by-side puts expected left and actual right.
+func RenderDiff(d *Diff, style DiffStyle) []string {
+	header := "Diff (-want +got)"
+	if d.Message != "" {
+		header = d.Message + " " + header
+	}
+	if style.Color {
+		header = colorDim() + header + colorReset()
+	}
+
+	if style.Layout == DiffSideBySide {
+		return append([]string{header}, renderSideBySide(d.Lines, style)...)
+	}
+	return append([]string{header}, renderInline(d.Lines, style.Color)...)
+}
+
+// renderInline renders "-" and "+" lines, highlighting within a lone
+// changed line
+func renderInline(lines []DiffLine, color bool) []string {
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		// A single delete followed by a single insert is a changed line
+		if line.Op == DiffDelete && i+1 < len(lines) && lines[i+1].Op == DiffInsert &&
+			(i == 0 || lines[i-1].Op != DiffDelete) && (i+2 == len(lines) || lines[i+2].Op != DiffInsert) {
+			want, got := highlightChange(line.Text, lines[i+1].Text, color)
+			out = append(out, paint("- ", color, colorRed())+want, paint("+ ", color, colorGreen())+got)
+			i++
+			continue
+		}
+
+		switch line.Op {
+		case DiffDelete:
+			out = append(out, paint("- "+line.Text, color, colorRed()))
+		case DiffInsert:
+			out = append(out, paint("+ "+line.Text, color, colorGreen()))
+		default:
+			out = append(out, "  "+line.Text)
+		}
+	}
+	return out
+}
+
+// highlightChange marks the differing middle of two lines, after their
+// common prefix and suffix
+func highlightChange(want, got string, color bool) (string, string) {
+	a, b := []rune(want), []rune(got)
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	mark := func(r []rune, highlight string) string {
+		if !color {
+			return string(r)
+		}
+		return highlight + string(r[:prefix]) + colorReverse() + string(r[prefix:len(r)-suffix]) +
+			colorReset() + highlight + string(r[len(r)-suffix:]) + colorReset()
+	}
+	return mark(a, colorRed()), mark(b, colorGreen())
+}
+
+// renderSideBySide pairs runs of deleted and inserted lines in two columns
+// separated by a marker: "|" changed, "<" only expected, ">" only actual
+func renderSideBySide(lines []DiffLine, style DiffStyle) []string {
+	width := style.Width
+	if width <= 0 {
+		width = 100
+	}
+	column := (width - 3) / 2
+	if column < 10 {
+		column = 10
+	}
+	cell := func(text string) string {
+		return runewidth.FillRight(runewidth.Truncate(text, column, "…"), column)
+	}
+
+	out := []string{cell("want") + "   " + cell("got")}
+	for i := 0; i < len(lines); {
+		if lines[i].Op == DiffEqual {
+			out = append(out, cell(lines[i].Text)+"   "+cell(lines[i].Text))
+			i++
+			continue
+		}
+
+		var deleted, inserted []string
+		for ; i < len(lines) && lines[i].Op == DiffDelete; i++ {
+			deleted = append(deleted, lines[i].Text)
+		}
+		for ; i < len(lines) && lines[i].Op == DiffInsert; i++ {
+			inserted = append(inserted, lines[i].Text)
+		}
+		for k := 0; k < len(deleted) || k < len(inserted); k++ {
+			left, right, marker := "", "", " | "
+			switch {
+			case k >= len(inserted):
+				left, marker = deleted[k], " < "
+			case k >= len(deleted):
+				right, marker = inserted[k], " > "
+			default:
+				left, right = deleted[k], inserted[k]
+			}
+			out = append(out, paint(cell(left), style.Color && left != "", colorRed())+marker+paint(cell(right), style.Color && right != "", colorGreen()))
+		}
+	}
+	return out
+}
+
+// paint wraps text in an ANSI color when enabled
+func paint(text string, enabled bool, color string) string {
+	if !enabled {
+		return text
+	}
+	return fmt.Sprintf("%s%s%s", color, text, colorReset())
+}
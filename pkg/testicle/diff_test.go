@@ -0,0 +1,167 @@
+package testicle
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDiffsWantGot(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		message  string
+		expected string
+		actual   string
+	}{
+		{"go idiom", "    add_test.go:12: Add(1, 2) = 4, want 3\n", "Add(1, 2)", "3", "4"},
+		{"got want", "    add_test.go:12: got 4, want 3\n", "", "3", "4"},
+		{"want got labels", "    add_test.go:12: sum: want: 3; got: 4\n", "sum", "3", "4"},
+		{"expected got", "    add_test.go:12: Expected status 200, got 404\n", "", "status 200", "404"},
+		{"blocks", "    render_test.go:20: Render mismatch\n        got:\n        a\n        c\n        want:\n        a\n        b\n", "Render mismatch", "a\nb", "a\nc"},
+		{"inline blocks", "    render_test.go:20: Render mismatch\n        got:  4\n        want: 3\n", "Render mismatch", "3", "4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs := ParseDiffs(tt.output)
+			if len(diffs) != 1 {
+				t.Fatalf("Expected 1 diff, got %d", len(diffs))
+			}
+			d := diffs[0]
+			if d.Matcher != MatcherWantGot || d.Message != tt.message || d.Expected != tt.expected || d.Actual != tt.actual {
+				t.Errorf("Unexpected diff: %+v", d)
+			}
+		})
+	}
+
+	if diffs := ParseDiffs("    x_test.go:3: connection refused\n"); diffs != nil {
+		t.Errorf("Expected no diffs, got %+v", diffs[0])
+	}
+}
+
+func TestParseDiffsCmp(t *testing.T) {
+	// go-cmp pads some lines with non-breaking spaces
+	output := "    user_test.go:30: GetUser() mismatch (-want +got):\n" +
+		"          main.User{\n" +
+		"        - \tName: \"ana\",\n" +
+		"        + \tName: \"ben\",\n" +
+		"          \tAge:  30,\n" +
+		"          }\n" +
+		"    user_test.go:31: other log\n"
+
+	diffs := ParseDiffs(output)
+	if len(diffs) != 1 {
+		t.Fatalf("Expected 1 diff, got %d", len(diffs))
+	}
+	d := diffs[0]
+	if d.Matcher != MatcherCmp || d.Message != "GetUser() mismatch" {
+		t.Errorf("Unexpected diff: %+v", d)
+	}
+	wantOps := []DiffOp{DiffEqual, DiffDelete, DiffInsert, DiffEqual, DiffEqual}
+	if len(d.Lines) != len(wantOps) {
+		t.Fatalf("Expected %d lines, got %+v", len(wantOps), d.Lines)
+	}
+	for i, op := range wantOps {
+		if d.Lines[i].Op != op {
+			t.Errorf("Line %d: expected %s, got %s (%q)", i, op, d.Lines[i].Op, d.Lines[i].Text)
+		}
+	}
+	if d.Lines[1].Text != "\tName: \"ana\"," || !strings.Contains(d.Actual, "ben") || strings.Contains(d.Actual, "ana") {
+		t.Errorf("Unexpected sides: %q / %q", d.Expected, d.Actual)
+	}
+
+	// With the legend reversed, "-" lines are the actual value
+	reversed := ParseDiffs("    x_test.go:3: (-got +want):\n        - 4\n        + 3\n")
+	if len(reversed) != 1 || reversed[0].Expected != "3" || reversed[0].Actual != "4" {
+		t.Errorf("Unexpected reversed diff: %+v", reversed)
+	}
+}
+
+func TestParseDiffsTestify(t *testing.T) {
+	output := "    calc_test.go:15: \n" +
+		"        \tError Trace:\t/src/calc_test.go:15\n" +
+		"        \tError:      \tNot equal: \n" +
+		"        \t            \texpected: []int{1, 2}\n" +
+		"        \t            \tactual  : []int{1, 3}\n" +
+		"        \t            \t\n" +
+		"        \t            \tDiff:\n" +
+		"        \t            \t--- Expected\n" +
+		"        \t            \t+++ Actual\n" +
+		"        \t            \t@@ -2,3 +2,3 @@\n" +
+		"        \t            \t  1,\n" +
+		"        \t            \t- 2\n" +
+		"        \t            \t+ 3\n" +
+		"        \tTest:       \tTestSum\n" +
+		"        \tMessages:   \tsum of evens\n"
+
+	diffs := ParseDiffs(output)
+	if len(diffs) != 1 {
+		t.Fatalf("Expected 1 diff, got %d", len(diffs))
+	}
+	d := diffs[0]
+	if d.Matcher != MatcherTestify || d.Message != "Not equal: sum of evens" || d.Expected != "[]int{1, 2}" || d.Actual != "[]int{1, 3}" {
+		t.Errorf("Unexpected diff: %+v", d)
+	}
+	if len(d.Lines) != 3 || d.Lines[1].Op != DiffDelete || d.Lines[2].Text != " 3" {
+		t.Errorf("Expected the unified diff lines, got %+v", d.Lines)
+	}
+}
+
+func TestRegisterDiffMatcher(t *testing.T) {
+	defer func(saved []namedMatcher) { diffMatchers = saved }(diffMatchers)
+
+	RegisterDiffMatcher("gomega", func(output string) []*Diff {
+		if !strings.Contains(output, "Expected\n") {
+			return nil
+		}
+		return []*Diff{{Expected: "b", Actual: "a"}}
+	})
+	diffs := ParseDiffs("Expected\n    <string>: a\nto equal\n    <string>: b\n")
+	if len(diffs) != 1 || diffs[0].Matcher != "gomega" || len(diffs[0].Lines) != 2 {
+		t.Errorf("Expected the custom matcher's diff, got %+v", diffs)
+	}
+
+	// Built-in formats still work when the custom matcher declines
+	if diffs := ParseDiffs("    a_test.go:1: got 1, want 2\n"); len(diffs) != 1 || diffs[0].Matcher != MatcherWantGot {
+		t.Errorf("Expected the want-got matcher, got %+v", diffs)
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	lines := lineDiff([]string{"a", "b", "c"}, []string{"a", "x", "c", "d"})
+	var got []string
+	for _, line := range lines {
+		got = append(got, string(line.Op[0])+line.Text)
+	}
+	want := "ea db ix ec id"
+	if strings.Join(got, " ") != want {
+		t.Errorf("lineDiff = %q, want %q", strings.Join(got, " "), want)
+	}
+}
+
+func TestRenderDiff(t *testing.T) {
+	d := &Diff{Message: "Add(1, 2)", Lines: lineDiff([]string{"sum=3"}, []string{"sum=4"})}
+
+	inline := RenderDiff(d, DiffStyle{})
+	if strings.Join(inline, "\n") != "Add(1, 2) Diff (-want +got)\n- sum=3\n+ sum=4" {
+		t.Errorf("Unexpected inline diff:\n%s", strings.Join(inline, "\n"))
+	}
+
+	colored := RenderDiff(d, DiffStyle{Color: true})
+	if !strings.Contains(colored[1], "sum="+colorReverse()+"3") {
+		t.Errorf("Expected the changed part highlighted, got %q", colored[1])
+	}
+
+	d.Lines = lineDiff([]string{"a", "b", "c"}, []string{"a", "x", "c", "d"})
+	side := RenderDiff(d, DiffStyle{Layout: DiffSideBySide, Width: 23})
+	want := []string{
+		"Add(1, 2) Diff (-want +got)",
+		"want         got       ",
+		"a            a         ",
+		"b          | x         ",
+		"c            c         ",
+		"           > d         ",
+	}
+	if strings.Join(side, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected side-by-side diff:\n%s\nwant:\n%s", strings.Join(side, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -35,13 +35,43 @@ func NewDiscovery(dir string, logger *Logger) *Discovery {
 	}
 }
 
-// DiscoverTests discovers all test functions in the specified directory
+// DiscoverTests discovers all test functions in the specified directory, or
+// in every module of the workspace the directory belongs to
 func (d *Discovery) DiscoverTests(ctx context.Context) ([]*TestInfo, error) {
 	d.logger.Debug("🔍 Starting test discovery in %s", d.dir)
 
+	roots := []string{d.dir}
+	workspace, err := FindWorkspace(d.dir)
+	if err != nil {
+		d.logger.Warn("Ignoring workspace: %v", err)
+	} else if workspace != nil {
+		d.logger.Debug("🗂️  Workspace %s: %d module(s)", workspace.File, len(workspace.Modules))
+		roots = roots[:0]
+		for _, module := range workspace.Modules {
+			roots = append(roots, module.Dir)
+		}
+	}
+
 	var tests []*TestInfo
+	for _, root := range roots {
+		rootTests, err := d.discoverIn(ctx, root, workspace != nil)
+		if err != nil {
+			return nil, err
+		}
+		tests = append(tests, rootTests...)
+	}
 
-	err := filepath.Walk(d.dir, func(path string, info os.FileInfo, err error) error {
+	d.logger.Debug("🔍 Discovery complete: found %d test(s)", len(tests))
+	return tests, nil
+}
+
+// discoverIn parses the test files below root. In a workspace, nested
+// modules are skipped: they are either discovered as modules of their own or
+// not part of the workspace, in which case go can't run their tests.
+func (d *Discovery) discoverIn(ctx context.Context, root string, skipNestedModules bool) ([]*TestInfo, error) {
+	var tests []*TestInfo
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -53,6 +83,12 @@ func (d *Discovery) DiscoverTests(ctx context.Context) ([]*TestInfo, error) {
 		default:
 		}
 
+		if info.IsDir() && skipNestedModules && path != root {
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+		}
+
 		// Only process Go test files
 		if !strings.HasSuffix(path, "_test.go") {
 			return nil
@@ -78,8 +114,6 @@ func (d *Discovery) DiscoverTests(ctx context.Context) ([]*TestInfo, error) {
 	if err != nil {
 		return nil, err
 	}
-
-	d.logger.Debug("🔍 Discovery complete: found %d test(s)", len(tests))
 	return tests, nil
 }
 
@@ -164,5 +198,6 @@ func (d *Discovery) isTestFunction(fn *ast.FuncDecl) bool {
 		return false
 	}
 
-	return pkgIdent.Name == "testing"
+	// TestMain(m *testing.M) sets up the package rather than being a test
+	return pkgIdent.Name == "testing" && selectorExpr.Sel.Name != "M"
 }
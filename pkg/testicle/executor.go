@@ -1,11 +1,17 @@
 package testicle
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"io"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/nzions/sharedgolibs/pkg/testicle/leakcheck"
 )
 
 // TestResults holds the results of test execution
@@ -15,16 +21,79 @@ type TestResults struct {
 	Skipped  int
 	Duration time.Duration
 	Tests    []*TestResult
+
+	// Resources holds per-package usage when resource monitoring is enabled
+	Resources []*ResourceProfile
+
+	// Leaks holds goroutines and ports left behind by packages or tests
+	Leaks []*leakcheck.Report
+
+	// SkippedByPolicy lists tests excluded by the skip list; they are not
+	// counted in Skipped. StaleSkips are entries naming tests that no
+	// longer exist.
+	SkippedByPolicy []SkipEntry
+	StaleSkips      []SkipEntry
+
+	// Metadata describes the environment the run happened in
+	Metadata *RunMetadata
+
+	// Packages records when each package (or external suite) ran, for
+	// timeline exports such as WriteChromeTrace
+	Packages []*PackageTiming
+
+	// Budget is the run's time budget, zero without one. NotRun lists the
+	// tests it cut off, with the Error NotRunBudgetExceeded: those of
+	// packages that never started and those the stopped package didn't
+	// finish. They are not counted in Skipped.
+	Budget time.Duration
+	NotRun []*TestResult
+
+	// SlowTests lists the tests and packages flagged by the slow test gate
+	SlowTests []SlowTest
+
+	// Clusters groups failed tests sharing an error signature, largest
+	// first (see FailureCluster)
+	Clusters []FailureCluster
+}
+
+// PackageTiming is the wall-clock span of one package's test process
+type PackageTiming struct {
+	Package  string // Package directory, or suite name
+	Started  time.Time
+	Finished time.Time
+
+	// FirstTest is when the first test started; the time before it is
+	// spent building the test binary and starting it. Zero for suites and
+	// packages that failed to build.
+	FirstTest time.Time
+
+	// Tests are the package's results
+	Tests []*TestResult
 }
 
 // TestResult holds the result of a single test
 type TestResult struct {
 	Name     string
 	Package  string
+	File     string
+	Line     int
 	Status   TestStatus
 	Duration time.Duration
 	Output   string
 	Error    string
+
+	// Set when resource monitoring is enabled
+	PeakRSSBytes uint64
+	MemorySpike  bool // A memory spike occurred while the test ran
+
+	// Diffs are the expected/actual pairs found in a failed test's output
+	Diffs []*Diff
+
+	// Started and Finished bound the test from its "=== RUN" line to its
+	// result line. Zero for external suites; Finished is zero if the test
+	// never reported a result.
+	Started  time.Time
+	Finished time.Time
 }
 
 // TestStatus represents the status of a test
@@ -36,13 +105,69 @@ const (
 	TestStatusSkipped
 )
 
+// String returns the lowercase status name used in reports
+func (s TestStatus) String() string {
+	switch s {
+	case TestStatusPassed:
+		return "passed"
+	case TestStatusFailed:
+		return "failed"
+	case TestStatusSkipped:
+		return "skipped"
+	default:
+		return "unknown"
+	}
+}
+
 // TestResultCallback is called for each individual test result
 type TestResultCallback func(result *TestResult)
 
+// ResourceCallback is called with each package's resource profile
+type ResourceCallback func(profile *ResourceProfile)
+
+// LeakCallback is called with each leak report
+type LeakCallback func(report *leakcheck.Report)
+
+// leakCheckResultName names the result recording package-level leaks
+const leakCheckResultName = "LeakCheck"
+
 // Executor handles test execution
 type Executor struct {
-	logger         *Logger
-	resultCallback TestResultCallback
+	logger           *Logger
+	resultCallback   TestResultCallback
+	resourceCallback ResourceCallback
+	leakCallback     LeakCallback
+
+	// Resource monitoring is disabled while monitorInterval is zero
+	monitorInterval time.Duration
+	spikeThreshold  uint64
+
+	// hostLeakCheck snapshots host listening ports around each package
+	hostLeakCheck bool
+
+	// buildCache, when set, runs cached test binaries instead of go test
+	buildCache *BuildCache
+
+	// skipPatterns maps package directories to a -skip pattern
+	skipPatterns map[string]string
+
+	// runPatterns maps package directories to a -run pattern
+	runPatterns map[string]string
+
+	// diffStyle lays out diffs of failed assertions in the console
+	diffStyle DiffStyle
+
+	// budget caps the total time of ExecuteTests; history orders packages
+	// to fit it
+	budget  time.Duration
+	history *DurationHistory
+
+	// goTestArgs maps package directories to extra go test arguments
+	goTestArgs map[string][]string
+
+	// Isolation is disabled while isolationPorts is zero
+	isolationPorts int
+	leaser         PortLeaser
 }
 
 // NewExecutor creates a new test executor
@@ -58,6 +183,77 @@ func (e *Executor) SetResultCallback(callback TestResultCallback) {
 	e.resultCallback = callback
 }
 
+// EnableResourceMonitoring samples CPU, memory, and open files of each go test
+// process tree every interval, flagging tests that overlap an RSS increase of
+// at least spikeThreshold bytes between samples
+func (e *Executor) EnableResourceMonitoring(interval time.Duration, spikeThreshold uint64) {
+	if interval <= 0 {
+		interval = DefaultResourceSampleInterval
+	}
+	if spikeThreshold == 0 {
+		spikeThreshold = DefaultMemorySpikeThreshold
+	}
+	e.monitorInterval = interval
+	e.spikeThreshold = spikeThreshold
+}
+
+// SetResourceCallback sets a callback function to be called for each package's resource profile
+func (e *Executor) SetResourceCallback(callback ResourceCallback) {
+	e.resourceCallback = callback
+}
+
+// EnableLeakCheck reports ports left listening by processes a package's
+// tests started. Goroutine and in-process port leaks are reported whenever
+// a package uses the leakcheck harness.
+func (e *Executor) EnableLeakCheck() {
+	e.hostLeakCheck = true
+}
+
+// EnableBuildCache runs each package's tests from a test binary cached in
+// cache, rebuilding it only when the package or its dependencies change
+func (e *Executor) EnableBuildCache(cache *BuildCache) {
+	e.buildCache = cache
+}
+
+// SetSkipPatterns excludes tests from runs: patterns maps a package
+// directory to a go test -skip regular expression
+func (e *Executor) SetSkipPatterns(patterns map[string]string) {
+	e.skipPatterns = patterns
+}
+
+// SetRunPatterns limits runs to selected tests: patterns maps a package
+// directory to a go test -run regular expression
+func (e *Executor) SetRunPatterns(patterns map[string]string) {
+	e.runPatterns = patterns
+}
+
+// SetGoTestArgs adds arguments to go test: args maps a package directory
+// to the arguments appended after testicle's own flags. Packages with
+// arguments run with go test, not a cached test binary, since build flags
+// such as -tags change the binary.
+func (e *Executor) SetGoTestArgs(args map[string][]string) {
+	e.goTestArgs = args
+}
+
+// SetDiffStyle sets how diffs of failed assertions are rendered when
+// results are logged to the console
+func (e *Executor) SetDiffStyle(style DiffStyle) {
+	e.diffStyle = style
+}
+
+// SetBudget caps the total time of ExecuteTests at budget, running
+// packages in the order history suggests (see DurationHistory.Order). A
+// zero budget removes the cap; a nil history keeps directory order.
+func (e *Executor) SetBudget(budget time.Duration, history *DurationHistory) {
+	e.budget = budget
+	e.history = history
+}
+
+// SetLeakCallback sets a callback function to be called for each leak report
+func (e *Executor) SetLeakCallback(callback LeakCallback) {
+	e.leakCallback = callback
+}
+
 // ExecuteTests executes the discovered tests
 func (e *Executor) ExecuteTests(ctx context.Context, tests []*TestInfo) (*TestResults, error) {
 	e.logger.Info("🚀 Executing %d test(s)...", len(tests))
@@ -69,11 +265,32 @@ func (e *Executor) ExecuteTests(ctx context.Context, tests []*TestInfo) (*TestRe
 
 	// Group tests by package for efficient execution
 	packageTests := e.groupTestsByPackage(tests)
+	packages := make([]string, 0, len(packageTests))
+	for packagePath := range packageTests {
+		packages = append(packages, packagePath)
+	}
 
-	for packagePath, packageTestList := range packageTests {
+	// A budget stops the package running when it runs out
+	runCtx := ctx
+	if e.budget > 0 {
+		results.Budget = e.budget
+		if e.history != nil {
+			packages = e.history.Order(packages)
+		}
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithDeadlineCause(ctx, startTime.Add(e.budget), errBudgetExceeded)
+		defer cancel()
+	}
+
+	for _, packagePath := range packages {
+		packageTestList := packageTests[packagePath]
+		if context.Cause(runCtx) == errBudgetExceeded {
+			results.NotRun = append(results.NotRun, notRunResults(packageTestList)...)
+			continue
+		}
 		e.logger.Debug("📦 Running tests in package: %s", packagePath)
 
-		packageResults, err := e.executePackageTests(ctx, packagePath, packageTestList)
+		packageResults, err := e.executePackageTests(runCtx, packagePath, packageTestList)
 		if err != nil {
 			e.logger.Error("Failed to execute tests in package %s: %v", packagePath, err)
 			continue
@@ -84,9 +301,20 @@ func (e *Executor) ExecuteTests(ctx context.Context, tests []*TestInfo) (*TestRe
 		results.Passed += packageResults.Passed
 		results.Failed += packageResults.Failed
 		results.Skipped += packageResults.Skipped
+		results.Resources = append(results.Resources, packageResults.Resources...)
+		results.Leaks = append(results.Leaks, packageResults.Leaks...)
+		results.Packages = append(results.Packages, packageResults.Packages...)
+		results.NotRun = append(results.NotRun, packageResults.NotRun...)
 	}
 
 	results.Duration = time.Since(startTime)
+	if len(results.NotRun) > 0 {
+		e.logger.Warn("⏱️  Budget of %s exceeded: %d test(s) not run", e.budget, len(results.NotRun))
+	}
+	if e.buildCache != nil {
+		hits, misses := e.buildCache.Stats()
+		e.logger.Debug("📦 Build cache: %d hit(s), %d miss(es) so far", hits, misses)
+	}
 	return results, nil
 }
 
@@ -104,20 +332,89 @@ func (e *Executor) groupTestsByPackage(tests []*TestInfo) map[string][]*TestInfo
 
 // executePackageTests executes all tests in a specific package
 func (e *Executor) executePackageTests(ctx context.Context, packagePath string, tests []*TestInfo) (*TestResults, error) {
-	// For now, we'll run `go test` on the package
-	// In the future, we could implement more sophisticated test selection
-
-	cmd := exec.CommandContext(ctx, "go", "test", "-v", packagePath)
+	timing := &PackageTiming{Package: packagePath, Started: time.Now()}
+	cmd := e.testCommand(ctx, packagePath)
+	if e.isolationPorts > 0 {
+		env, err := e.isolate(packagePath)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err := env.release(); err != nil {
+				e.logger.Warn("Failed to clean up isolation of %s: %v", packagePath, err)
+			}
+		}()
+		cmd.Env = append(os.Environ(), env.environ()...)
+		e.logger.Debug("🔒 Isolated %s: %s, ports %v", packagePath, env.dir, env.lease.Ports)
+	}
 
 	e.logger.Debug("🔧 Executing: %s", cmd.String())
 
-	output, err := cmd.CombinedOutput()
-	outputStr := string(output)
+	// Timestamp test start/end lines as they stream so resource samples can
+	// be attributed to the tests running at the time
+	var output bytes.Buffer
+	timeline := newTestTimeline()
+	writer := io.MultiWriter(&output, timeline)
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	if e.budget > 0 {
+		cmd.WaitDelay = budgetWaitDelay
+	}
+
+	var hostBefore *leakcheck.HostSnapshot
+	if e.hostLeakCheck {
+		var snapshotErr error
+		if hostBefore, snapshotErr = leakcheck.TakeHost(); snapshotErr != nil {
+			e.logger.Debug("Port leak check unavailable: %v", snapshotErr)
+		}
+	}
+
+	var monitor *ResourceMonitor
+	err := cmd.Start()
+	if err == nil {
+		if e.monitorInterval > 0 {
+			monitor = StartResourceMonitor(cmd.Process.Pid, e.monitorInterval)
+		}
+		err = cmd.Wait()
+	}
+	timing.Finished = time.Now()
+
+	var profile *ResourceProfile
+	if monitor != nil {
+		samples, monitorErr := monitor.Stop()
+		if monitorErr != nil {
+			e.logger.Debug("Resource monitoring unavailable: %v", monitorErr)
+		}
+		if len(samples) > 0 {
+			profile = newResourceProfile(packagePath, samples, timeline.Windows(), e.spikeThreshold)
+		}
+	}
 
-	// Parse the go test output to extract individual test results
-	results := e.parseGoTestOutput(outputStr, tests)
+	// Parse the go test output to extract individual test results. Tests
+	// unfinished when the budget stopped the package were not run.
+	windows := timeline.Windows()
+	stopped := context.Cause(ctx) == errBudgetExceeded
+	results := e.parseGoTestOutput(output.String(), tests, profile, windows, stopped)
+	for _, window := range windows {
+		if timing.FirstTest.IsZero() || window.start.Before(timing.FirstTest) {
+			timing.FirstTest = window.start
+		}
+	}
+	timing.Tests = results.Tests
+	results.Packages = []*PackageTiming{timing}
 
-	if err != nil {
+	if hostBefore != nil {
+		if hostAfter, snapshotErr := leakcheck.TakeHost(); snapshotErr == nil {
+			if ports := hostBefore.LeakedPorts(hostAfter); len(ports) > 0 {
+				results.Leaks = append(results.Leaks, &leakcheck.Report{Ports: ports})
+			}
+		}
+	}
+
+	// When the harness fails the package for leaks, or a test failed, that
+	// explains the non-zero exit and passing tests stay passed
+	packageLeaked := e.recordLeaks(packagePath, results)
+	if err != nil && !packageLeaked && results.Failed == 0 && !stopped {
 		// Mark tests as failed if the command failed
 		for _, result := range results.Tests {
 			if result.Status == TestStatusPassed {
@@ -134,20 +431,69 @@ func (e *Executor) executePackageTests(ctx context.Context, packagePath string,
 	return results, nil
 }
 
-// parseGoTestOutput parses the output from `go test -v` and extracts test results
-func (e *Executor) parseGoTestOutput(output string, tests []*TestInfo) *TestResults {
+// testCommand returns the command running a package's tests: its cached
+// test binary in warm build mode, otherwise go test. A package that fails to
+// build is run with go test, which reports the compile errors.
+func (e *Executor) testCommand(ctx context.Context, packagePath string) *exec.Cmd {
+	run, skip := e.runPatterns[packagePath], e.skipPatterns[packagePath]
+	extra := e.goTestArgs[packagePath]
+	if e.buildCache != nil && len(extra) == 0 {
+		binary, _, err := e.buildCache.Binary(ctx, packagePath)
+		if err == nil {
+			// go test runs test binaries in the package directory
+			args := []string{"-test.v"}
+			if run != "" {
+				args = append(args, "-test.run", run)
+			}
+			if skip != "" {
+				args = append(args, "-test.skip", skip)
+			}
+			cmd := exec.CommandContext(ctx, binary, args...)
+			cmd.Dir = packagePath
+			return cmd
+		}
+		e.logger.Debug("Build cache unavailable for %s: %v", packagePath, err)
+	}
+	args := []string{"test", "-v"}
+	if run != "" {
+		args = append(args, "-run", run)
+	}
+	if skip != "" {
+		args = append(args, "-skip", skip)
+	}
+	args = append(args, extra...)
+	// Run in the package directory so go picks the package's own module, or
+	// the workspace it belongs to, whatever the working directory
+	cmd := exec.CommandContext(ctx, "go", append(args, ".")...)
+	cmd.Dir = packagePath
+	return cmd
+}
+
+// parseGoTestOutput parses the output from `go test -v` and extracts test
+// results, annotating them with resource usage when profile is non-nil.
+// When stopped, tests without a result are moved to NotRun.
+func (e *Executor) parseGoTestOutput(output string, tests []*TestInfo, profile *ResourceProfile, windows map[string]testWindow, stopped bool) *TestResults {
 	results := &TestResults{
 		Tests: make([]*TestResult, 0, len(tests)),
 	}
+	if profile != nil {
+		results.Resources = append(results.Resources, profile)
+		if e.resourceCallback != nil {
+			e.resourceCallback(profile)
+		}
+	}
 
 	lines := strings.Split(output, "\n")
 	testMap := make(map[string]*TestResult)
+	reported := make(map[*TestResult]bool)
 
 	// Initialize results for all tests
 	for _, test := range tests {
 		result := &TestResult{
 			Name:     test.Name,
 			Package:  test.Package,
+			File:     test.File,
+			Line:     test.Line,
 			Status:   TestStatusFailed, // Default to failed, mark as passed if we see success
 			Duration: 0,
 			Output:   "",
@@ -157,31 +503,82 @@ func (e *Executor) parseGoTestOutput(output string, tests []*TestInfo) *TestResu
 		results.Tests = append(results.Tests, result)
 	}
 
-	// Parse output lines
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
+	// Parse output lines. Indented lines are the log output of the test most
+	// recently announced by an "=== RUN" (or CONT) line.
+	var current *TestResult
+	for _, raw := range lines {
+		line := strings.TrimSpace(raw)
 		if line == "" {
 			continue
 		}
 
+		if marker, ok := strings.CutPrefix(line, "=== "); ok {
+			if fields := strings.Fields(marker); len(fields) == 2 {
+				current = testMap[strings.SplitN(fields[1], "/", 2)[0]]
+			}
+			continue
+		}
+
+		if report, ok := leakcheck.ParseMarker(line); ok {
+			results.Leaks = append(results.Leaks, report)
+			continue
+		}
+
 		// Look for test result patterns
 		if strings.Contains(line, "PASS:") || strings.Contains(line, "--- PASS:") {
 			testName := e.extractTestName(line)
 			if result, exists := testMap[testName]; exists {
+				reported[result] = true
 				result.Status = TestStatusPassed
 				result.Duration = e.extractDuration(line)
 			}
 		} else if strings.Contains(line, "FAIL:") || strings.Contains(line, "--- FAIL:") {
 			testName := e.extractTestName(line)
 			if result, exists := testMap[testName]; exists {
+				reported[result] = true
 				result.Status = TestStatusFailed
 				result.Error = line
 			}
 		} else if strings.Contains(line, "SKIP:") || strings.Contains(line, "--- SKIP:") {
 			testName := e.extractTestName(line)
 			if result, exists := testMap[testName]; exists {
+				reported[result] = true
 				result.Status = TestStatusSkipped
 			}
+		} else if current != nil && strings.HasPrefix(raw, " ") {
+			current.Output += strings.TrimRight(raw, "\r") + "\n"
+		}
+	}
+
+	if stopped {
+		finished := results.Tests[:0]
+		for _, result := range results.Tests {
+			if reported[result] {
+				finished = append(finished, result)
+				continue
+			}
+			result.Status, result.Error = TestStatusSkipped, NotRunBudgetExceeded
+			results.NotRun = append(results.NotRun, result)
+		}
+		results.Tests = finished
+	}
+
+	for _, result := range results.Tests {
+		if window, ok := windows[result.Name]; ok {
+			result.Started, result.Finished = window.start, window.end
+		}
+	}
+
+	if profile != nil {
+		spiked := make(map[string]bool, len(profile.SpikeTests))
+		for _, name := range profile.SpikeTests {
+			spiked[name] = true
+		}
+		for _, result := range results.Tests {
+			if window, ok := windows[result.Name]; ok {
+				result.PeakRSSBytes = peakRSSDuring(profile.Samples, window)
+			}
+			result.MemorySpike = spiked[result.Name]
 		}
 	}
 
@@ -196,17 +593,109 @@ func (e *Executor) parseGoTestOutput(output string, tests []*TestInfo) *TestResu
 			results.Skipped++
 		}
 
-		// Use callback if available, otherwise log
-		if e.resultCallback != nil {
-			e.resultCallback(result)
-		} else {
-			e.logTestResult(result)
-		}
+		e.reportResult(result)
 	}
 
 	return results
 }
 
+// recordLeaks attaches leak reports to results: test-level leaks to the
+// failing test, package-level leaks to a failed LeakCheck result. It reports
+// whether there were package-level leaks.
+func (e *Executor) recordLeaks(packagePath string, results *TestResults) bool {
+	var packageLeaks []string
+	for _, report := range results.Leaks {
+		report.Package = packagePath
+		if e.leakCallback != nil {
+			e.leakCallback(report)
+		}
+
+		if report.Test != "" {
+			for _, result := range results.Tests {
+				if result.Name == report.Test || strings.HasPrefix(report.Test, result.Name+"/") {
+					result.Output += report.String() + "\n"
+				}
+			}
+			continue
+		}
+		packageLeaks = append(packageLeaks, report.String())
+	}
+
+	if len(packageLeaks) == 0 {
+		return false
+	}
+
+	result := &TestResult{
+		Name:    leakCheckResultName,
+		Package: packagePath,
+		Status:  TestStatusFailed,
+		Error:   packageLeaks[0],
+		Output:  strings.Join(packageLeaks, "\n"),
+	}
+	if len(packageLeaks) > 1 {
+		result.Error = fmt.Sprintf("%d leak reports", len(packageLeaks))
+	}
+	results.Tests = append(results.Tests, result)
+	results.Failed++
+	e.reportResult(result)
+	return true
+}
+
+// ExecuteSuites runs non-Go suites through their adapters and merges their
+// results into results. A suite that cannot run is recorded as a failed
+// result named after the suite so it is visible in every report.
+func (e *Executor) ExecuteSuites(ctx context.Context, adapters []SuiteAdapter, results *TestResults) {
+	for _, adapter := range adapters {
+		e.logger.Debug("🧩 Running suite: %s", adapter.Name())
+
+		start := time.Now()
+		suiteResults, err := adapter.Run(ctx)
+		if err != nil {
+			e.logger.Error("Suite %s failed to run: %v", adapter.Name(), err)
+			suiteResults = []*TestResult{{
+				Name:     adapter.Name(),
+				Package:  adapter.Name(),
+				Status:   TestStatusFailed,
+				Duration: time.Since(start),
+				Error:    err.Error(),
+			}}
+		}
+		results.Duration += time.Since(start)
+		results.Packages = append(results.Packages, &PackageTiming{
+			Package:  adapter.Name(),
+			Started:  start,
+			Finished: time.Now(),
+			Tests:    suiteResults,
+		})
+
+		for _, result := range suiteResults {
+			switch result.Status {
+			case TestStatusPassed:
+				results.Passed++
+			case TestStatusFailed:
+				results.Failed++
+			case TestStatusSkipped:
+				results.Skipped++
+			}
+			results.Tests = append(results.Tests, result)
+			e.reportResult(result)
+		}
+	}
+}
+
+// reportResult hands a result to the callback if set, otherwise logs it.
+// Failed results get the diffs found in their output first.
+func (e *Executor) reportResult(result *TestResult) {
+	if result.Status == TestStatusFailed && result.Diffs == nil {
+		result.Diffs = ParseDiffs(result.Output)
+	}
+	if e.resultCallback != nil {
+		e.resultCallback(result)
+	} else {
+		e.logTestResult(result)
+	}
+}
+
 // extractTestName extracts the test name from a go test output line
 func (e *Executor) extractTestName(line string) string {
 	// Look for patterns like "--- PASS: TestName" or "PASS: TestName"
@@ -250,7 +739,15 @@ func (e *Executor) logTestResult(result *TestResult) {
 		if result.Error != "" {
 			e.logger.Info("   %s", result.Error)
 		}
+		for _, diff := range result.Diffs {
+			for _, line := range RenderDiff(diff, e.diffStyle) {
+				e.logger.Info("   %s", line)
+			}
+		}
 	case TestStatusSkipped:
 		e.logger.Info("⏭️  %s (skipped)", result.Name)
 	}
+	if result.MemorySpike {
+		e.logger.Info("   ⚠️  memory spike while running (peak RSS %s)", formatBytes(result.PeakRSSBytes))
+	}
 }
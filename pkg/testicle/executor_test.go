@@ -0,0 +1,73 @@
+package testicle
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nzions/sharedgolibs/pkg/testicle/leakcheck"
+)
+
+func TestExecutorReportsLeaks(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test on a fixture package")
+	}
+
+	dir, err := filepath.Abs(filepath.Join("testdata", "leaky"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests, err := NewDiscovery(dir, NewLogger(false)).DiscoverTests(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverTests failed: %v", err)
+	}
+
+	var reports []*leakcheck.Report
+	executor := NewExecutor(NewLogger(false))
+	executor.SetResultCallback(func(*TestResult) {})
+	executor.SetLeakCallback(func(report *leakcheck.Report) {
+		reports = append(reports, report)
+	})
+
+	results, err := executor.ExecuteTests(context.Background(), tests)
+	if err != nil {
+		t.Fatalf("ExecuteTests failed: %v", err)
+	}
+
+	// The test-level leak is reported by Check and again by VerifyTestMain
+	if len(reports) != 2 || len(results.Leaks) != 2 {
+		t.Fatalf("Expected 2 leak reports, got %d", len(reports))
+	}
+	for _, report := range reports {
+		if report.Package != dir || len(report.Goroutines) == 0 {
+			t.Errorf("Unexpected report: %+v", report)
+		}
+	}
+
+	status := make(map[string]*TestResult)
+	for _, result := range results.Tests {
+		status[result.Name] = result
+	}
+	if status["TestClean"] == nil || status["TestClean"].Status != TestStatusPassed {
+		t.Errorf("Expected TestClean to stay passed despite the package exit code, got %+v", status["TestClean"])
+	}
+	if leaky := status["TestLeakyListener"]; leaky == nil || leaky.Status != TestStatusFailed || !strings.Contains(leaky.Output, "leaked") {
+		t.Errorf("Expected TestLeakyListener failed with leak output, got %+v", leaky)
+	}
+	if status[leakCheckResultName] == nil || status[leakCheckResultName].Status != TestStatusFailed {
+		t.Errorf("Expected a failed %s result", leakCheckResultName)
+	}
+
+	// Timings feed timeline exports
+	if len(results.Packages) != 1 {
+		t.Fatalf("Expected one package timing, got %d", len(results.Packages))
+	}
+	timing := results.Packages[0]
+	if timing.Package != dir || timing.FirstTest.Before(timing.Started) || timing.Finished.Before(timing.FirstTest) {
+		t.Errorf("Unexpected package timing: %+v", timing)
+	}
+	if clean := status["TestClean"]; clean.Started.Before(timing.FirstTest) || clean.Finished.Before(clean.Started) {
+		t.Errorf("Expected TestClean's span inside the package, got %v - %v", clean.Started, clean.Finished)
+	}
+}
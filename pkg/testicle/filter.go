@@ -0,0 +1,187 @@
+package testicle
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Filter selects a subset of the discovered tests from an expression such
+// as `pkg:./pkg/ca test:~Transport -test:~Legacy`. Terms are separated by
+// spaces:
+//
+//   - pkg:<path> matches a package by directory relative to the test
+//     directory (./pkg/ca) or by import path; a /... suffix includes
+//     subpackages
+//   - test:<name> matches a top-level test by exact name
+//   - a ~ after the colon matches a regular expression instead
+//     (pkg:~ca$, test:~Transport)
+//   - a leading - excludes the matches (-test:~Legacy)
+//   - a bare word is short for test:~<word>
+//
+// Terms on the same field are alternatives; terms on different fields must
+// all match.
+type Filter struct {
+	expr  string
+	terms []filterTerm
+}
+
+// filterTerm is one term of a filter expression
+type filterTerm struct {
+	field   string // "pkg" or "test"
+	value   string
+	pattern *regexp.Regexp // Set for ~ terms
+	negate  bool
+}
+
+// ParseFilter parses a filter expression. An empty expression returns a
+// nil Filter, which selects every test.
+func ParseFilter(expr string) (*Filter, error) {
+	fields := strings.Fields(expr)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	filter := &Filter{expr: strings.Join(fields, " ")}
+	for _, field := range fields {
+		term, err := parseFilterTerm(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter term %q: %w", field, err)
+		}
+		filter.terms = append(filter.terms, term)
+	}
+	return filter, nil
+}
+
+// parseFilterTerm parses a single [-]field:[~]value term
+func parseFilterTerm(text string) (filterTerm, error) {
+	var term filterTerm
+	text, term.negate = strings.CutPrefix(text, "-")
+
+	field, value, ok := strings.Cut(text, ":")
+	if !ok {
+		field, value = "test", "~"+text
+	}
+	switch field {
+	case "pkg", "test":
+		term.field = field
+	default:
+		return term, fmt.Errorf("unknown field %q (expected pkg or test)", field)
+	}
+
+	if pattern, ok := strings.CutPrefix(value, "~"); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return term, err
+		}
+		term.pattern = re
+		value = pattern
+	}
+	if value == "" {
+		return term, fmt.Errorf("missing %s value", field)
+	}
+	term.value = value
+	return term, nil
+}
+
+// String returns the normalized filter expression
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.expr
+}
+
+// Apply returns a copy of tree with only the selected top-level tests and
+// a go test -run pattern for each package directory where only some of
+// the tests were selected. Packages left without tests are dropped. A nil
+// Filter returns the tree unchanged.
+func (f *Filter) Apply(tree *TestTree) (*TestTree, map[string]string) {
+	if f == nil {
+		return tree, nil
+	}
+
+	filtered := &TestTree{Module: tree.Module, Root: tree.Root, Workspace: tree.Workspace}
+	patterns := make(map[string]string)
+	for _, pkg := range tree.Packages {
+		if !f.selects("pkg", func(term filterTerm) bool { return term.matchPackage(tree.Root, pkg) }) {
+			continue
+		}
+
+		var tests []*TestNode
+		var names []string
+		for _, test := range pkg.Tests {
+			if f.selects("test", func(term filterTerm) bool { return term.matchTest(test) }) {
+				tests = append(tests, test)
+				names = append(names, regexp.QuoteMeta(test.Name))
+			}
+		}
+		if len(tests) == 0 {
+			continue
+		}
+		if len(tests) < len(pkg.Tests) {
+			patterns[pkg.Dir] = "^(" + strings.Join(names, "|") + ")$"
+		}
+		pkgCopy := *pkg
+		pkgCopy.Tests = tests
+		filtered.Packages = append(filtered.Packages, &pkgCopy)
+	}
+	return filtered, patterns
+}
+
+// selects reports whether the terms on field accept an item: it must match
+// one of the including terms, if there are any, and none of the excluding ones
+func (f *Filter) selects(field string, match func(filterTerm) bool) bool {
+	included, hasInclude := false, false
+	for _, term := range f.terms {
+		if term.field != field {
+			continue
+		}
+		if term.negate {
+			if match(term) {
+				return false
+			}
+			continue
+		}
+		hasInclude = true
+		included = included || match(term)
+	}
+	return included || !hasInclude
+}
+
+// matchPackage matches pkg by its directory relative to root or its import path
+func (t filterTerm) matchPackage(root string, pkg *PackageNode) bool {
+	rel := "."
+	if r, err := filepath.Rel(root, pkg.Dir); err == nil {
+		rel = filepath.ToSlash(r)
+	}
+	if t.pattern != nil {
+		return t.pattern.MatchString(pkg.ImportPath) || t.pattern.MatchString("./"+rel)
+	}
+
+	value, recursive := strings.CutSuffix(t.value, "/...")
+	if value == "." || strings.HasPrefix(value, "./") || strings.HasPrefix(value, "../") {
+		return matchPath(rel, filepath.ToSlash(filepath.Clean(value)), recursive)
+	}
+	return matchPath(pkg.ImportPath, value, recursive)
+}
+
+// matchTest matches a top-level test by name
+func (t filterTerm) matchTest(test *TestNode) bool {
+	if t.pattern != nil {
+		return t.pattern.MatchString(test.Name)
+	}
+	return test.Name == t.value
+}
+
+// matchPath reports whether path is want, or below it when recursive
+func matchPath(path, want string, recursive bool) bool {
+	if path == want {
+		return true
+	}
+	if !recursive {
+		return false
+	}
+	return want == "." || strings.HasPrefix(path, want+"/")
+}
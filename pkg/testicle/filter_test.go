@@ -0,0 +1,102 @@
+package testicle
+
+import (
+	"reflect"
+	"testing"
+)
+
+func filterTestTree() *TestTree {
+	node := func(name string) *TestNode { return &TestNode{Name: name, FullName: name} }
+	return &TestTree{
+		Module: "example.com/m",
+		Root:   "/src/m",
+		Packages: []*PackageNode{
+			{ImportPath: "example.com/m/pkg/ca", Dir: "/src/m/pkg/ca", Tests: []*TestNode{
+				node("TestTransport"), node("TestTransportLegacy"), node("TestIssue"),
+			}},
+			{ImportPath: "example.com/m/pkg/ca/store", Dir: "/src/m/pkg/ca/store", Tests: []*TestNode{
+				node("TestTransportStore"),
+			}},
+			{ImportPath: "example.com/m/pkg/gflag", Dir: "/src/m/pkg/gflag", Tests: []*TestNode{
+				node("TestParse"),
+			}},
+		},
+	}
+}
+
+// selectedTests lists package:test for every test left in tree
+func selectedTests(tree *TestTree) []string {
+	var names []string
+	for _, pkg := range tree.Packages {
+		for _, test := range pkg.Tests {
+			names = append(names, pkg.ImportPath[len("example.com/m/"):]+":"+test.Name)
+		}
+	}
+	return names
+}
+
+func TestFilterApply(t *testing.T) {
+	tests := []struct {
+		expr     string
+		want     []string
+		patterns map[string]string
+	}{
+		{
+			expr:     "pkg:./pkg/ca test:~Transport -test:~Legacy",
+			want:     []string{"pkg/ca:TestTransport"},
+			patterns: map[string]string{"/src/m/pkg/ca": "^(TestTransport)$"},
+		},
+		{
+			expr:     "pkg:example.com/m/pkg/ca/...",
+			want:     []string{"pkg/ca:TestTransport", "pkg/ca:TestTransportLegacy", "pkg/ca:TestIssue", "pkg/ca/store:TestTransportStore"},
+			patterns: map[string]string{},
+		},
+		{
+			expr:     "-pkg:~ca Parse",
+			want:     []string{"pkg/gflag:TestParse"},
+			patterns: map[string]string{},
+		},
+		{
+			expr:     "test:TestIssue test:TestParse",
+			want:     []string{"pkg/ca:TestIssue", "pkg/gflag:TestParse"},
+			patterns: map[string]string{"/src/m/pkg/ca": "^(TestIssue)$"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			filter, err := ParseFilter(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tree, patterns := filter.Apply(filterTestTree())
+			if got := selectedTests(tree); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+			if !reflect.DeepEqual(patterns, tt.patterns) {
+				t.Errorf("Expected -run patterns %v, got %v", tt.patterns, patterns)
+			}
+		})
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	filter, err := ParseFilter("  ")
+	if err != nil || filter != nil {
+		t.Errorf("Expected no filter for a blank expression, got %v, %v", filter, err)
+	}
+	tree := filterTestTree()
+	if got, patterns := filter.Apply(tree); got != tree || patterns != nil {
+		t.Error("Expected a nil filter to return the tree unchanged")
+	}
+
+	filter, _ = ParseFilter("pkg:./pkg/ca   test:~Transport")
+	if filter.String() != "pkg:./pkg/ca test:~Transport" {
+		t.Errorf("Unexpected normalized expression %q", filter)
+	}
+
+	for _, expr := range []string{"name:TestA", "test:", "test:~(", "pkg:~"} {
+		if _, err := ParseFilter(expr); err == nil {
+			t.Errorf("Expected %q to be rejected", expr)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package testicle
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nzions/sharedgolibs/pkg/testicle/report"
+)
+
+// ArtifactHTMLReportFile is the HTML report saved alongside each run's
+// json-stream report
+const ArtifactHTMLReportFile = "report.html"
+
+// NewReport converts the results of a run into the data of an HTML report
+// (see the report package). Tests are grouped by package directory, or by
+// suite name for external suites, in the order the packages ran.
+func NewReport(results *TestResults, title string) *report.Report {
+	rep := &report.Report{
+		Title:    title,
+		Duration: results.Duration,
+		Passed:   results.Passed,
+		Failed:   results.Failed,
+		Skipped:  results.Skipped,
+		NotRun:   len(results.NotRun),
+		Metadata: metadataFields(results.Metadata),
+	}
+	for _, cluster := range results.Clusters {
+		rep.Clusters = append(rep.Clusters, report.Cluster{Summary: cluster.String(), Hint: cluster.DependencyHint(), Tests: cluster.Tests})
+	}
+
+	packages := make(map[string]*report.Package)
+	add := func(key string) *report.Package {
+		pkg, ok := packages[key]
+		if !ok {
+			pkg = &report.Package{Name: key}
+			packages[key] = pkg
+			rep.Packages = append(rep.Packages, pkg)
+		}
+		return pkg
+	}
+	for _, timing := range results.Packages {
+		if rep.Started.IsZero() || (!timing.Started.IsZero() && timing.Started.Before(rep.Started)) {
+			rep.Started = timing.Started
+		}
+		if !timing.Finished.IsZero() {
+			add(timing.Package).Duration = timing.Finished.Sub(timing.Started)
+		}
+	}
+	for _, result := range results.Tests {
+		pkg := add(reportPackage(result))
+		pkg.Tests = append(pkg.Tests, reportTest(result, result.Status.String()))
+	}
+	for _, result := range results.NotRun {
+		pkg := add(reportPackage(result))
+		pkg.Tests = append(pkg.Tests, reportTest(result, report.StatusNotRun))
+	}
+	return rep
+}
+
+// NewSingleFileReport is NewReport with the run's timeline drawn in the
+// report and its Chrome trace (see WriteChromeTrace) embedded for download,
+// so one HTML file carries everything the artifacts directory would: a CI
+// artifact or an email attachment
+func NewSingleFileReport(results *TestResults, title string) (*report.Report, error) {
+	rep := NewReport(results, title)
+	var trace bytes.Buffer
+	if err := WriteChromeTrace(&trace, results); err != nil {
+		return nil, err
+	}
+	rep.Timeline = reportTimeline(results)
+	rep.Timeline.Trace = trace.Bytes()
+	return rep, nil
+}
+
+// reportTimeline lays out a run's packages and tests like WriteChromeTrace
+func reportTimeline(results *TestResults) *report.Timeline {
+	timeline := &report.Timeline{}
+	packages := sortedPackages(results)
+	if len(packages) == 0 {
+		return timeline
+	}
+	origin := packages[0].Started
+	span := func(name, kind, status string, start, end time.Time) report.Span {
+		return report.Span{Name: name, Kind: kind, Status: status, Start: start.Sub(origin), Length: end.Sub(start)}
+	}
+
+	for _, timing := range packages {
+		timeline.Duration = max(timeline.Duration, timing.Finished.Sub(origin))
+		track := &report.Track{Name: timing.Package}
+		track.Spans = append(track.Spans, span(timing.Package, report.SpanPackage, "", timing.Started, timing.Finished))
+		if !timing.FirstTest.IsZero() {
+			track.Spans = append(track.Spans, span("build", report.SpanBuild, "", timing.Started, timing.FirstTest))
+		}
+		timeline.Tracks = append(timeline.Tracks, track)
+
+		for lane, tests := range testLanes(timing) {
+			laneTrack := track
+			if lane > 0 {
+				laneTrack = &report.Track{Name: fmt.Sprintf("%s #%d", timing.Package, lane+1)}
+				timeline.Tracks = append(timeline.Tracks, laneTrack)
+			}
+			for _, result := range tests {
+				laneTrack.Spans = append(laneTrack.Spans, span(result.Name, report.SpanTest, result.Status.String(), result.Started, testFinished(result, timing)))
+			}
+		}
+	}
+	return timeline
+}
+
+// reportPackage returns the package directory of a test, or the suite name
+// of an external suite's result
+func reportPackage(result *TestResult) string {
+	if result.File == "" {
+		return result.Package
+	}
+	return filepath.Dir(result.File)
+}
+
+func reportTest(result *TestResult, status string) *report.Test {
+	return &report.Test{
+		Name:     result.Name,
+		Status:   status,
+		Duration: result.Duration,
+		File:     result.File,
+		Line:     result.Line,
+		Error:    result.Error,
+		Output:   result.Output,
+	}
+}
+
+// metadataFields lists the run metadata in the order shown in reports
+func metadataFields(metadata *RunMetadata) []report.Field {
+	if metadata == nil {
+		return nil
+	}
+	var fields []report.Field
+	add := func(label, value string) {
+		if value != "" {
+			fields = append(fields, report.Field{Label: label, Value: value})
+		}
+	}
+	commit := metadata.GitCommit
+	if commit != "" && metadata.GitDirty {
+		commit += " (dirty)"
+	}
+	add("Commit", commit)
+	add("Branch", metadata.GitBranch)
+	add("Go", metadata.GoVersion)
+	add("Platform", metadata.OS+"/"+metadata.Arch)
+	add("Host", metadata.Hostname)
+
+	names := make([]string, 0, len(metadata.Env))
+	for name := range metadata.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		add(name, metadata.Env[name])
+	}
+
+	add("go test args", strings.Join(metadata.GoTestArgs, " "))
+	keys := make([]string, 0, len(metadata.PackageGoTestArgs))
+	for key := range metadata.PackageGoTestArgs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		add("go test args "+key, strings.Join(metadata.PackageGoTestArgs[key], " "))
+	}
+	return fields
+}
+
+// writeHTMLReport renders the report of a run to path
+func writeHTMLReport(path string, renderer report.Renderer, results *TestResults, title string) error {
+	return renderHTMLReport(path, renderer, NewReport(results, title))
+}
+
+// writeSingleFileReport renders the NewSingleFileReport of a run to path
+func writeSingleFileReport(path string, renderer report.Renderer, results *TestResults, title string) error {
+	rep, err := NewSingleFileReport(results, title)
+	if err != nil {
+		return err
+	}
+	return renderHTMLReport(path, renderer, rep)
+}
+
+// renderHTMLReport writes rep to path
+func renderHTMLReport(path string, renderer report.Renderer, rep *report.Report) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating HTML report: %w", err)
+	}
+	if err := renderer.Render(file, rep); err != nil {
+		file.Close()
+		return fmt.Errorf("rendering HTML report: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("writing HTML report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package testicle
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nzions/sharedgolibs/pkg/testicle/report"
+)
+
+func TestNewReport(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	results := &TestResults{
+		Passed:   1,
+		Failed:   1,
+		Duration: 3 * time.Second,
+		Tests: []*TestResult{
+			{Name: "TestA", Package: "api", File: "/src/api/api_test.go", Status: TestStatusPassed},
+			{Name: "TestB", Package: "store", File: "/src/store/store_test.go", Status: TestStatusFailed, Output: "boom"},
+			{Name: "web", Package: "web", Status: TestStatusPassed},
+		},
+		NotRun: []*TestResult{{Name: "TestC", Package: "e2e", File: "/src/e2e/e2e_test.go"}},
+		Packages: []*PackageTiming{
+			{Package: "/src/store", Started: start, Finished: start.Add(2 * time.Second)},
+			{Package: "/src/api", Started: start.Add(-time.Second), Finished: start},
+		},
+		Metadata: &RunMetadata{GitCommit: "abc123", GitDirty: true, GoVersion: "go1.24.3", OS: "linux", Arch: "amd64",
+			Env: map[string]string{"CI": "true"}},
+	}
+
+	rep := NewReport(results, "run")
+	if !rep.Started.Equal(start.Add(-time.Second)) || rep.NotRun != 1 || rep.Status() != report.StatusFailed {
+		t.Errorf("Unexpected report totals: %+v", rep)
+	}
+
+	var names []string
+	for _, pkg := range rep.Packages {
+		names = append(names, pkg.Name)
+	}
+	if want := []string{"/src/store", "/src/api", "web", "/src/e2e"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Expected packages %v in run order, got %v", want, names)
+	}
+	if rep.Packages[0].Duration != 2*time.Second || rep.Packages[0].Tests[0].Output != "boom" {
+		t.Errorf("Unexpected package: %+v", rep.Packages[0])
+	}
+	if test := rep.Packages[3].Tests[0]; test.Status != report.StatusNotRun {
+		t.Errorf("Expected TestC not run, got %q", test.Status)
+	}
+
+	want := []report.Field{
+		{Label: "Commit", Value: "abc123 (dirty)"},
+		{Label: "Go", Value: "go1.24.3"},
+		{Label: "Platform", Value: "linux/amd64"},
+		{Label: "CI", Value: "true"},
+	}
+	if !reflect.DeepEqual(rep.Metadata, want) {
+		t.Errorf("Unexpected metadata: %+v", rep.Metadata)
+	}
+}
+
+func TestNewSingleFileReport(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []*TestResult{
+		{Name: "TestA", Package: "api", Status: TestStatusPassed, Started: start.Add(time.Second), Finished: start.Add(2 * time.Second)},
+		{Name: "TestB", Package: "api", Status: TestStatusFailed, Started: start.Add(time.Second), Finished: start.Add(2 * time.Second)},
+	}
+	results := &TestResults{
+		Tests: tests,
+		Packages: []*PackageTiming{
+			{Package: "api", Started: start, FirstTest: start.Add(time.Second), Finished: start.Add(3 * time.Second), Tests: tests},
+		},
+	}
+
+	rep, err := NewSingleFileReport(results, "run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeline := rep.Timeline
+	if timeline.Duration != 3*time.Second || len(timeline.Tracks) != 2 || len(timeline.Trace) == 0 {
+		t.Fatalf("Unexpected timeline %+v", timeline)
+	}
+	// The package and build spans, then the parallel tests on two tracks
+	if spans := timeline.Tracks[0].Spans; len(spans) != 3 || spans[1].Kind != report.SpanBuild || spans[1].Length != time.Second {
+		t.Errorf("Unexpected spans %+v", spans)
+	}
+	if span := timeline.Tracks[1].Spans[0]; timeline.Tracks[1].Name != "api #2" || span.Start != time.Second || span.Status != "failed" {
+		t.Errorf("Unexpected track %+v", timeline.Tracks[1])
+	}
+}
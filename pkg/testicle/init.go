@@ -0,0 +1,258 @@
+package testicle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxInitPackages caps the packages listed in a starter config
+const maxInitPackages = 40
+
+// suiteCandidate is a non-Go test suite found while scanning for init
+type suiteCandidate struct {
+	config SuiteConfig
+	reason string // What was found, e.g. "web/package.json has a test script"
+}
+
+// InitConfig writes a commented starter testicle.yaml into dir after
+// scanning it for Go packages and non-Go test suites. An existing file is
+// only replaced when force is set. It returns the path written.
+func InitConfig(ctx context.Context, dir string, force bool) (string, error) {
+	path := filepath.Join(dir, DefaultConfigFile)
+	if _, err := os.Stat(path); err == nil && !force {
+		return "", fmt.Errorf("%s already exists (use --force to overwrite)", path)
+	}
+
+	data, err := StarterConfig(ctx, dir)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("writing config file: %w", err)
+	}
+	return path, nil
+}
+
+// StarterConfig returns a commented testicle.yaml for the Go packages and
+// non-Go test suites under dir. Every setting is written with its default
+// value and detected suites are included commented out, so the result is
+// valid and changes nothing until edited.
+func StarterConfig(ctx context.Context, dir string) ([]byte, error) {
+	tree, err := NewDiscovery(dir, NewLoggerWithWriter(false, io.Discard)).DiscoverTree(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("scanning packages: %w", err)
+	}
+	candidates, err := findSuiteCandidates(dir)
+	if err != nil {
+		return nil, fmt.Errorf("scanning for test suites: %w", err)
+	}
+
+	var b strings.Builder
+	b.WriteString("# testicle.yaml - generated by `testicle init`\n#\n")
+	if tree.Module != "" {
+		fmt.Fprintf(&b, "# Module %s: ", tree.Module)
+	} else {
+		b.WriteString("# ")
+	}
+	fmt.Fprintf(&b, "%d package(s) with %d test(s)\n", len(tree.Packages), tree.TestCount())
+	for i, pkg := range tree.Packages {
+		if i == maxInitPackages {
+			fmt.Fprintf(&b, "#   ... and %d more\n", len(tree.Packages)-maxInitPackages)
+			break
+		}
+		fmt.Fprintf(&b, "#   %s (%d)\n", pkg.ImportPath, len(pkg.Tests))
+	}
+	b.WriteString(`#
+# Go packages are discovered automatically; this file only tunes the run.
+# Command-line flags take precedence over these settings.
+
+# Output format: default (interactive) or json-stream (NDJSON for editors)
+reporter: default
+
+# Diffs of failed assertions (want/got, go-cmp, testify): inline or side-by-side
+diff: inline
+
+# Skip the go vet and test compilation checks that run before the tests
+no_vet: false
+no_build_check: false
+
+# Sample CPU, memory, and open files of test processes and flag memory spikes (Linux)
+monitor_resources: false
+
+# Report ports left listening by processes the tests started (Linux)
+leak_check: false
+
+# Cache compiled test binaries and re-run them while sources are unchanged
+warm_build: false
+
+# Tests skipped by policy, with a reason and owner each ([x] in daemon mode
+# adds the last failed test). Relative to the test directory.
+skip_list: .testicle/skiplist.yaml
+
+# Keep a json-stream report of every run (results, resource profiles, and
+# leaks) in a directory per run, e.g. .testicle/runs; empty keeps nothing.
+# Old runs are pruned after each run and by 'testicle clean'.
+artifacts_dir: ""
+retention:
+  max_runs: 20
+  max_age: 168h
+  max_disk: 256MB
+
+# Watch mode: files that trigger a re-run and how long changes must settle.
+# Hidden files, vendored dependencies, testdata, generated code, and editor
+# temporary files are always excluded; exclude adds to that list.
+watch:
+  include: ["*.go", "go.mod", "go.sum", "go.work", "go.work.sum"]
+  exclude: []
+  debounce: 200ms
+
+# Slow test gate: flag top-level tests slower than max_test_duration and
+# packages slower than the p95 of their recent runs plus p95_regression
+# (e.g. 25%). action is warn (list them in the summary) or fail (exit 5).
+# Empty values disable each check.
+slow_tests:
+  max_test_duration: ""
+  p95_regression: ""
+  action: warn
+
+# Extra go test arguments for every package, then per package path (as in
+# a pkg: filter term), e.g. -count=5 for a flaky package or -tags for another.
+# Recorded in each run's metadata.
+go_test_args: []
+packages: {}
+#  ./pkg/cache:
+#    go_test_args: [-count=5]
+
+# Export each run as OpenTelemetry spans (run, packages, tests) to an
+# OTLP/HTTP collector, e.g. http://localhost:4318; empty disables it.
+# Attribute values expand environment variables.
+otel:
+  endpoint: ""
+  service_name: testicle
+  timeout: 10s
+  attributes: {}
+#    shard: ${SHARD_INDEX}
+
+# Non-Go test suites, run after the Go tests with their results merged in.
+# type is junit (JUnit XML) or tap (Test Anything Protocol), dir and report
+# are relative to the test directory, and timeout is a Go duration (e.g. 5m).
+suites: []
+`)
+
+	if len(candidates) == 0 {
+		b.WriteString(`#  - name: web
+#    type: junit
+#    command: npx jest --ci --reporters=jest-junit
+#    dir: web
+#    report: junit.xml
+#    timeout: 5m
+`)
+	}
+	for _, candidate := range candidates {
+		suite := candidate.config
+		fmt.Fprintf(&b, "# Found: %s\n", candidate.reason)
+		fmt.Fprintf(&b, "#  - name: %s\n#    type: %s\n#    command: %s\n", suite.Name, suite.Type, suite.Command)
+		if suite.Dir != "" {
+			fmt.Fprintf(&b, "#    dir: %s\n", suite.Dir)
+		}
+		if suite.Report != "" {
+			fmt.Fprintf(&b, "#    report: %s\n", suite.Report)
+		}
+		if suite.Timeout != "" {
+			fmt.Fprintf(&b, "#    timeout: %s\n", suite.Timeout)
+		}
+		if len(suite.Env) > 0 {
+			b.WriteString("#    env:\n")
+			for key, value := range suite.Env {
+				fmt.Fprintf(&b, "#      %s: %s\n", key, value)
+			}
+		}
+	}
+
+	return []byte(b.String()), nil
+}
+
+// findSuiteCandidates looks for npm test scripts and bats tests under dir
+func findSuiteCandidates(dir string) ([]suiteCandidate, error) {
+	var candidates []suiteCandidate
+	batsDirs := make(map[string]bool)
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			switch entry.Name() {
+			case ".git", "vendor", "node_modules", "testdata":
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		switch {
+		case entry.Name() == "package.json":
+			if hasNPMTestScript(path) {
+				candidates = append(candidates, npmSuite(rel))
+			}
+		case strings.HasSuffix(entry.Name(), ".bats") && !batsDirs[rel]:
+			batsDirs[rel] = true
+			candidates = append(candidates, suiteCandidate{
+				config: SuiteConfig{Name: suiteName(rel, "bats"), Type: SuiteTypeTAP, Command: "bats --tap " + rel, Timeout: "5m"},
+				reason: rel + " has bats tests",
+			})
+		}
+		return nil
+	})
+	return candidates, err
+}
+
+// hasNPMTestScript reports whether a package.json defines a test script
+func hasNPMTestScript(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return false
+	}
+	return pkg.Scripts["test"] != ""
+}
+
+// npmSuite suggests a JUnit suite for an npm package in dir
+func npmSuite(dir string) suiteCandidate {
+	suite := SuiteConfig{
+		Name:    suiteName(dir, "npm"),
+		Type:    SuiteTypeJUnit,
+		Command: "npm test -- --ci --reporters=jest-junit",
+		Report:  "junit.xml",
+		Timeout: "5m",
+		Env:     map[string]string{"JEST_JUNIT_OUTPUT_FILE": "junit.xml"},
+	}
+	if dir != "." {
+		suite.Dir = dir
+	}
+	return suiteCandidate{config: suite, reason: filepath.ToSlash(filepath.Join(dir, "package.json")) + " has a test script"}
+}
+
+// suiteName names a suite after its directory, or fallback at the root
+func suiteName(dir, fallback string) string {
+	if dir == "." {
+		return fallback
+	}
+	return strings.ReplaceAll(dir, "/", "-")
+}
@@ -0,0 +1,79 @@
+package testicle
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/nzions/sharedgolibs/pkg/servicemanager"
+)
+
+// Environment variables giving each package's tests their own resources
+// when isolation is enabled: TESTICLE_TMP is a fresh directory (also set as
+// TMPDIR) and TESTICLE_PORT_0 to TESTICLE_PORT_<n-1> are leased free ports
+const (
+	IsolationTmpEnv        = "TESTICLE_TMP"
+	IsolationPortEnvPrefix = "TESTICLE_PORT_"
+)
+
+// DefaultIsolationPorts is the number of ports leased per package
+const DefaultIsolationPorts = 4
+
+// PortLeaser reserves ports for a package run; servicemanager.ServiceManager
+// implements it with leases shared across processes
+type PortLeaser interface {
+	ReservePorts(owner string, count int) (*servicemanager.PortLease, error)
+}
+
+// packageEnv is the temp directory and ports of one package run
+type packageEnv struct {
+	dir   string
+	lease *servicemanager.PortLease
+}
+
+// EnableIsolation runs each package with its own temp directory and ports
+// leased from leaser (servicemanager's lease file when nil), removed and
+// released once the package finishes, so packages run by parallel testicle
+// processes don't collide on fixed ports or fixture paths
+func (e *Executor) EnableIsolation(ports int, leaser PortLeaser) {
+	if ports <= 0 {
+		ports = DefaultIsolationPorts
+	}
+	if leaser == nil {
+		leaser = servicemanager.NewSimple()
+	}
+	e.isolationPorts = ports
+	e.leaser = leaser
+}
+
+// isolate allocates the temp directory and ports of a package run
+func (e *Executor) isolate(packagePath string) (*packageEnv, error) {
+	dir, err := os.MkdirTemp("", "testicle-pkg-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create package temp dir: %w", err)
+	}
+	lease, err := e.leaser.ReservePorts("testicle "+packagePath, e.isolationPorts)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to lease ports: %w", err)
+	}
+	return &packageEnv{dir: dir, lease: lease}, nil
+}
+
+// environ returns the variables exposing the package's resources
+func (p *packageEnv) environ() []string {
+	env := []string{IsolationTmpEnv + "=" + p.dir, "TMPDIR=" + p.dir}
+	for i, port := range p.lease.Ports {
+		env = append(env, IsolationPortEnvPrefix+strconv.Itoa(i)+"="+strconv.Itoa(port))
+	}
+	return env
+}
+
+// release removes the temp directory and returns the ports
+func (p *packageEnv) release() error {
+	leaseErr := p.lease.Release()
+	if err := os.RemoveAll(p.dir); err != nil {
+		return fmt.Errorf("failed to remove package temp dir: %w", err)
+	}
+	return leaseErr
+}
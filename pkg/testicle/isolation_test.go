@@ -0,0 +1,71 @@
+package testicle
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nzions/sharedgolibs/pkg/servicemanager"
+)
+
+func TestExecutorIsolation(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test on generated packages")
+	}
+
+	dir := t.TempDir()
+	test := `package PKG
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEnv(t *testing.T) {
+	tmp := os.Getenv("TESTICLE_TMP")
+	if tmp == "" || os.TempDir() != tmp || os.Getenv("TESTICLE_PORT_0") == "" || os.Getenv("TESTICLE_PORT_1") == "" || os.Getenv("TESTICLE_PORT_2") != "" {
+		t.Fatal("missing isolation")
+	}
+	t.Logf("env %s %s %s", tmp, os.Getenv("TESTICLE_PORT_0"), os.Getenv("TESTICLE_PORT_1"))
+}
+`
+	files := map[string]string{
+		"go.mod":      "module example.com/isolated\n\ngo 1.21\n",
+		"a/a_test.go": strings.Replace(test, "PKG", "a", 1),
+		"b/b_test.go": strings.Replace(test, "PKG", "b", 1),
+	}
+	writeFiles(t, dir, files)
+
+	tests, err := NewDiscovery(dir, NewLogger(false)).DiscoverTests(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverTests failed: %v", err)
+	}
+	leases := servicemanager.NewSimple(servicemanager.WithLeaseFile(filepath.Join(t.TempDir(), "leases.json")))
+	executor := NewExecutor(NewLogger(false))
+	executor.SetResultCallback(func(*TestResult) {})
+	executor.EnableIsolation(2, leases)
+
+	results, err := executor.ExecuteTests(context.Background(), tests)
+	if err != nil {
+		t.Fatalf("ExecuteTests failed: %v", err)
+	}
+	if results.Passed != 2 {
+		t.Fatalf("Expected both packages to see their isolation, got %+v", results.Tests)
+	}
+
+	for _, result := range results.Tests {
+		_, env, _ := strings.Cut(result.Output, "env ")
+		fields := strings.Fields(env)
+		if len(fields) < 3 {
+			t.Fatalf("Expected the isolation in the output, got %q", result.Output)
+		}
+		if _, err := os.Stat(fields[0]); !os.IsNotExist(err) {
+			t.Errorf("Expected %s removed after the package, got %v", fields[0], err)
+		}
+	}
+	if held, err := leases.GetPortLeases(); err != nil || len(held) != 0 {
+		t.Errorf("Expected the ports released, got %+v, %v", held, err)
+	}
+}
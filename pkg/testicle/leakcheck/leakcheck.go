@@ -0,0 +1,349 @@
+// Package leakcheck detects goroutines and listening ports that outlive a
+// test package or a single test.
+//
+// Enable it for a whole package from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		leakcheck.VerifyTestMain(m)
+//	}
+//
+// or for a single test with leakcheck.Check(t). Leaks fail the test run and
+// are also written as a marker line that the testicle runner picks up and
+// shows in its results view.
+package leakcheck
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// MarkerPrefix starts the line carrying a JSON encoded Report in test output
+const MarkerPrefix = "testicle-leakcheck: "
+
+// DefaultMaxWait is how long goroutines are given to exit before they are
+// reported as leaked
+const DefaultMaxWait = time.Second
+
+// defaultIgnores are functions of goroutines owned by the testing framework
+// and the runtime rather than by the code under test
+var defaultIgnores = []string{
+	"testing.tRunner",
+	"testing.(*T).Run",
+	"testing.(*M).",
+	"testing.runTests",
+	"testing.(*F).",
+	"os/signal.signal_recv",
+	"os/signal.loop",
+	"runtime.ensureSigM",
+}
+
+// Goroutine is a single goroutine from a stack dump
+type Goroutine struct {
+	ID          int    `json:"id"`
+	State       string `json:"state"`                // e.g. "chan receive", "IO wait, 2 minutes"
+	TopFunction string `json:"top_function"`         // Function the goroutine is currently in
+	CreatedBy   string `json:"created_by,omitempty"` // Function that started it
+	Stack       string `json:"stack"`
+}
+
+// String renders a one-line description of the goroutine
+func (g Goroutine) String() string {
+	s := fmt.Sprintf("goroutine %d [%s] %s", g.ID, g.State, g.TopFunction)
+	if g.CreatedBy != "" {
+		s += ", created by " + g.CreatedBy
+	}
+	return s
+}
+
+// hasFunction reports whether any frame of the stack is in a function starting with prefix
+func (g Goroutine) hasFunction(prefix string) bool {
+	for _, line := range strings.Split(g.Stack, "\n") {
+		if strings.HasPrefix(line, prefix) || strings.HasPrefix(line, "created by "+prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Snapshot is the set of goroutines and listening ports of this process at one instant
+type Snapshot struct {
+	Goroutines []Goroutine
+	Ports      []Port
+}
+
+// Take captures the goroutines and listening ports of the current process.
+// The calling goroutine is excluded. Ports are empty where listing them is
+// not supported.
+func Take() Snapshot {
+	self := currentGoroutineID()
+
+	var snapshot Snapshot
+	for _, g := range parseGoroutines(stackDump()) {
+		if g.ID != self {
+			snapshot.Goroutines = append(snapshot.Goroutines, g)
+		}
+	}
+	snapshot.Ports, _ = ProcessPorts(os.Getpid())
+	return snapshot
+}
+
+// Report lists what leaked. Package is filled in by the testicle runner;
+// Test is empty for package-level checks.
+type Report struct {
+	Package    string      `json:"package,omitempty"`
+	Test       string      `json:"test,omitempty"`
+	Goroutines []Goroutine `json:"goroutines,omitempty"`
+	Ports      []Port      `json:"ports,omitempty"`
+}
+
+// Empty reports whether nothing leaked
+func (r *Report) Empty() bool {
+	return len(r.Goroutines) == 0 && len(r.Ports) == 0
+}
+
+// Summary returns a short count of the leaks, e.g. "2 goroutine(s), 1 port(s)"
+func (r *Report) Summary() string {
+	var parts []string
+	if len(r.Goroutines) > 0 {
+		parts = append(parts, fmt.Sprintf("%d goroutine(s)", len(r.Goroutines)))
+	}
+	if len(r.Ports) > 0 {
+		parts = append(parts, fmt.Sprintf("%d port(s)", len(r.Ports)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// String renders the report with one line per leak
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "leaked %s", r.Summary())
+	for _, g := range r.Goroutines {
+		fmt.Fprintf(&b, "\n  %s", g)
+	}
+	for _, p := range r.Ports {
+		fmt.Fprintf(&b, "\n  %s", p)
+	}
+	return b.String()
+}
+
+// Diff returns what is in after but not in before, skipping goroutines
+// with a frame in one of the ignored functions
+func Diff(before, after Snapshot, ignore ...string) *Report {
+	known := make(map[int]bool, len(before.Goroutines))
+	for _, g := range before.Goroutines {
+		known[g.ID] = true
+	}
+
+	report := &Report{}
+	for _, g := range after.Goroutines {
+		if known[g.ID] || ignored(g, ignore) {
+			continue
+		}
+		report.Goroutines = append(report.Goroutines, g)
+	}
+	report.Ports = newPorts(before.Ports, after.Ports)
+	return report
+}
+
+// ignored reports whether g belongs to the testing framework or matches ignore
+func ignored(g Goroutine, ignore []string) bool {
+	for _, list := range [][]string{defaultIgnores, ignore} {
+		for _, prefix := range list {
+			if g.hasFunction(prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Option configures a leak check
+type Option func(*options)
+
+type options struct {
+	maxWait time.Duration
+	ignore  []string
+}
+
+// WithMaxWait sets how long goroutines and ports may take to go away
+// before they count as leaked (default DefaultMaxWait)
+func WithMaxWait(d time.Duration) Option {
+	return func(o *options) {
+		o.maxWait = d
+	}
+}
+
+// WithIgnoreFunction ignores goroutines with a stack frame in a function whose
+// fully qualified name starts with prefix, e.g. "go.opencensus.io/stats/view.(*worker).start"
+func WithIgnoreFunction(prefix string) Option {
+	return func(o *options) {
+		o.ignore = append(o.ignore, prefix)
+	}
+}
+
+// Find compares the current state against before, waiting up to the
+// configured maximum for goroutines and ports to go away
+func Find(before Snapshot, opts ...Option) *Report {
+	o := options{maxWait: DefaultMaxWait}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	deadline := time.Now().Add(o.maxWait)
+	delay := time.Millisecond
+	for {
+		report := Diff(before, Take(), o.ignore...)
+		if report.Empty() || time.Now().After(deadline) {
+			return report
+		}
+
+		runtime.Gosched()
+		time.Sleep(delay)
+		if delay < 100*time.Millisecond {
+			delay *= 2
+		}
+	}
+}
+
+// VerifyTestMain runs the package's tests and exits, failing the run when
+// goroutines or listening ports are left over once all tests have finished
+func VerifyTestMain(m *testing.M, opts ...Option) {
+	before := Take()
+	code := m.Run()
+
+	report := Find(before, opts...)
+	if !report.Empty() {
+		fmt.Fprintf(os.Stderr, "leakcheck: %s\n", report)
+		writeMarker(os.Stdout, report)
+		if code == 0 {
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+// Check fails t when goroutines or listening ports started during the test
+// are still present once it and its subtests have finished
+func Check(t testing.TB, opts ...Option) {
+	t.Helper()
+	before := Take()
+
+	t.Cleanup(func() {
+		report := Find(before, opts...)
+		if report.Empty() {
+			return
+		}
+		report.Test = t.Name()
+		t.Errorf("leakcheck: %s", report)
+		writeMarker(os.Stdout, report)
+	})
+}
+
+// writeMarker writes report as a single marker line
+func writeMarker(w io.Writer, report *Report) {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "\n%s%s\n", MarkerPrefix, data)
+}
+
+// ParseMarker decodes a marker line written by VerifyTestMain or Check.
+// It returns false for any other line.
+func ParseMarker(line string) (*Report, bool) {
+	idx := strings.Index(line, MarkerPrefix)
+	if idx < 0 {
+		return nil, false
+	}
+
+	var report Report
+	if err := json.Unmarshal([]byte(line[idx+len(MarkerPrefix):]), &report); err != nil {
+		return nil, false
+	}
+	return &report, true
+}
+
+// stackDump returns the stacks of all goroutines
+func stackDump() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+}
+
+// currentGoroutineID parses the ID of the calling goroutine
+func currentGoroutineID() int {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	id, _, _ := parseHeader(string(bytes.SplitN(buf, []byte("\n"), 2)[0]))
+	return id
+}
+
+// parseGoroutines splits a runtime.Stack dump into goroutines, sorted by ID
+func parseGoroutines(dump []byte) []Goroutine {
+	var goroutines []Goroutine
+	for _, block := range strings.Split(strings.TrimSpace(string(dump)), "\n\n") {
+		lines := strings.SplitN(block, "\n", 3)
+		id, state, ok := parseHeader(lines[0])
+		if !ok {
+			continue
+		}
+
+		g := Goroutine{ID: id, State: state}
+		if len(lines) > 1 {
+			g.TopFunction = functionName(lines[1])
+			g.Stack = strings.Join(lines[1:], "\n")
+		}
+		for _, line := range strings.Split(g.Stack, "\n") {
+			if creator, ok := strings.CutPrefix(line, "created by "); ok {
+				g.CreatedBy, _, _ = strings.Cut(creator, " in goroutine ")
+			}
+		}
+		goroutines = append(goroutines, g)
+	}
+
+	sort.Slice(goroutines, func(i, j int) bool { return goroutines[i].ID < goroutines[j].ID })
+	return goroutines
+}
+
+// parseHeader parses "goroutine 12 [chan receive, 3 minutes]:"
+func parseHeader(line string) (int, string, bool) {
+	if !strings.HasPrefix(line, "goroutine ") {
+		return 0, "", false
+	}
+	rest := strings.TrimPrefix(line, "goroutine ")
+
+	space := strings.IndexByte(rest, ' ')
+	if space < 0 {
+		return 0, "", false
+	}
+	id, err := strconv.Atoi(rest[:space])
+	if err != nil {
+		return 0, "", false
+	}
+
+	state := rest[space+1:]
+	state = strings.TrimSuffix(strings.TrimPrefix(state, "["), "]:")
+	return id, state, true
+}
+
+// functionName strips the argument list from a stack frame line
+func functionName(frame string) string {
+	if idx := strings.LastIndex(frame, "("); idx > 0 {
+		return frame[:idx]
+	}
+	return frame
+}
@@ -0,0 +1,154 @@
+package leakcheck
+
+import (
+	"bytes"
+	"net"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGoroutines(t *testing.T) {
+	dump := `goroutine 7 [chan receive, 2 minutes]:
+example.com/pkg.(*Pool).worker(0xc000010000)
+	/src/pkg/pool.go:42 +0x45
+created by example.com/pkg.NewPool in goroutine 1
+	/src/pkg/pool.go:20 +0x85
+
+goroutine 1 [running]:
+main.main()
+	/src/main.go:10 +0x1d
+`
+	goroutines := parseGoroutines([]byte(dump))
+	if len(goroutines) != 2 {
+		t.Fatalf("Expected 2 goroutines, got %d", len(goroutines))
+	}
+
+	g := goroutines[1]
+	if g.ID != 7 || g.State != "chan receive, 2 minutes" || g.TopFunction != "example.com/pkg.(*Pool).worker" {
+		t.Errorf("Unexpected goroutine: %+v", g)
+	}
+	if g.CreatedBy != "example.com/pkg.NewPool" {
+		t.Errorf("Expected creator example.com/pkg.NewPool, got %q", g.CreatedBy)
+	}
+	if !g.hasFunction("example.com/pkg.NewPool") {
+		t.Error("Expected creator frame to match")
+	}
+}
+
+func TestFindGoroutineLeak(t *testing.T) {
+	before := Take()
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-stop
+	}()
+
+	report := Find(before, WithMaxWait(20*time.Millisecond))
+	if len(report.Goroutines) != 1 {
+		t.Fatalf("Expected 1 leaked goroutine, got %v", report.Goroutines)
+	}
+	if !strings.Contains(report.Goroutines[0].TopFunction, "TestFindGoroutineLeak") {
+		t.Errorf("Expected leak attributed to the test, got %s", report.Goroutines[0].TopFunction)
+	}
+
+	if report := Find(before, WithMaxWait(20*time.Millisecond), WithIgnoreFunction("github.com/nzions/sharedgolibs/pkg/testicle/leakcheck.TestFindGoroutineLeak")); !report.Empty() {
+		t.Errorf("Expected ignored goroutine to be skipped, got %v", report)
+	}
+
+	// A goroutine that exits within the wait is not a leak
+	time.AfterFunc(10*time.Millisecond, func() { close(stop) })
+	if report := Find(before); !report.Empty() {
+		t.Errorf("Expected no leak after goroutine exit, got %v", report)
+	}
+	<-done
+}
+
+func TestFindPortLeak(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("listing ports requires /proc")
+	}
+
+	before := Take()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	report := Find(before, WithMaxWait(0))
+	if len(report.Ports) != 1 || report.Ports[0].Port != port || report.Ports[0].Address != "127.0.0.1" {
+		t.Fatalf("Expected listener on port %d reported, got %v", port, report.Ports)
+	}
+
+	listener.Close()
+	if report := Find(before); len(report.Ports) != 0 {
+		t.Errorf("Expected no port leak after close, got %v", report.Ports)
+	}
+}
+
+func TestParseSocketAddress(t *testing.T) {
+	tests := []struct {
+		input   string
+		address string
+		port    int
+	}{
+		{"0100007F:1F90", "127.0.0.1", 8080},
+		{"00000000000000000000000001000000:0050", "::1", 80},
+	}
+	for _, tt := range tests {
+		address, port, ok := parseSocketAddress(tt.input)
+		if !ok || address != tt.address || port != tt.port {
+			t.Errorf("parseSocketAddress(%s) = %s, %d, %v; want %s, %d", tt.input, address, port, ok, tt.address, tt.port)
+		}
+	}
+}
+
+func TestLeakedPortsOnlyFromNewProcesses(t *testing.T) {
+	before := &HostSnapshot{
+		Ports: []Port{{Proto: "tcp", Address: "0.0.0.0", Port: 5432, PID: 10}},
+		pids:  map[int]bool{1: true, 10: true},
+	}
+	after := &HostSnapshot{Ports: []Port{
+		{Proto: "tcp", Address: "0.0.0.0", Port: 5432, PID: 10},
+		{Proto: "tcp", Address: "127.0.0.1", Port: 6000, PID: 10},  // Existing process
+		{Proto: "tcp", Address: "127.0.0.1", Port: 7000, PID: 200}, // Started during the run
+		{Proto: "tcp", Address: "127.0.0.1", Port: 8000},           // Owner unknown
+	}}
+
+	leaked := before.LeakedPorts(after)
+	if len(leaked) != 1 || leaked[0].Port != 7000 {
+		t.Errorf("Expected only port 7000 leaked, got %v", leaked)
+	}
+}
+
+func TestMarkerRoundTrip(t *testing.T) {
+	report := &Report{
+		Test:       "TestServer",
+		Goroutines: []Goroutine{{ID: 9, State: "IO wait", TopFunction: "net.(*netFD).accept"}},
+		Ports:      []Port{{Proto: "tcp", Address: "127.0.0.1", Port: 9000}},
+	}
+
+	var buf bytes.Buffer
+	writeMarker(&buf, report)
+
+	var parsed *Report
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if r, ok := ParseMarker(line); ok {
+			parsed = r
+		}
+	}
+	if parsed == nil || parsed.Test != "TestServer" || len(parsed.Goroutines) != 1 || parsed.Ports[0].Port != 9000 {
+		t.Fatalf("Unexpected parsed report: %+v", parsed)
+	}
+	if parsed.Summary() != "1 goroutine(s), 1 port(s)" {
+		t.Errorf("Unexpected summary %q", parsed.Summary())
+	}
+
+	if _, ok := ParseMarker("--- PASS: TestServer (0.00s)"); ok {
+		t.Error("Expected ordinary output not to parse as a marker")
+	}
+}
@@ -0,0 +1,92 @@
+package leakcheck
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrUnsupported is returned where listening ports cannot be listed
+var ErrUnsupported = errors.New("listing ports is not supported on this platform")
+
+// Port is a listening TCP socket
+type Port struct {
+	Proto   string `json:"proto"` // tcp or tcp6
+	Address string `json:"address"`
+	Port    int    `json:"port"`
+	PID     int    `json:"pid,omitempty"`     // Owning process, when it can be determined
+	Command string `json:"command,omitempty"` // Owning process name
+}
+
+// String renders the port as "tcp 127.0.0.1:8080" with its owner if known
+func (p Port) String() string {
+	s := fmt.Sprintf("%s %s:%d", p.Proto, p.Address, p.Port)
+	if p.PID != 0 {
+		s += fmt.Sprintf(" (pid %d %s)", p.PID, p.Command)
+	}
+	return s
+}
+
+// key identifies the socket independent of its owner
+func (p Port) key() string {
+	return fmt.Sprintf("%s/%s/%d", p.Proto, p.Address, p.Port)
+}
+
+// newPorts returns ports in after that are not in before
+func newPorts(before, after []Port) []Port {
+	known := make(map[string]bool, len(before))
+	for _, p := range before {
+		known[p.key()] = true
+	}
+
+	var ports []Port
+	for _, p := range after {
+		if !known[p.key()] {
+			ports = append(ports, p)
+		}
+	}
+	sortPorts(ports)
+	return ports
+}
+
+// sortPorts orders ports by number, then protocol and address
+func sortPorts(ports []Port) {
+	sort.Slice(ports, func(i, j int) bool {
+		if ports[i].Port != ports[j].Port {
+			return ports[i].Port < ports[j].Port
+		}
+		return ports[i].key() < ports[j].key()
+	})
+}
+
+// HostSnapshot records the listening ports and running processes of the
+// host. The testicle runner takes one before and after each package to find
+// ports left open by processes the tests started, which in-process checks
+// cannot see.
+type HostSnapshot struct {
+	Ports []Port
+	pids  map[int]bool
+}
+
+// TakeHost captures all listening ports visible to this user and the
+// processes running now
+func TakeHost() (*HostSnapshot, error) {
+	ports, pids, err := hostPorts()
+	if err != nil {
+		return nil, err
+	}
+	return &HostSnapshot{Ports: ports, pids: pids}, nil
+}
+
+// LeakedPorts returns the ports in after that were not listening in h and are
+// owned by a process that did not exist when h was taken. Ports opened by
+// long-running processes (databases, dev servers) are never reported.
+func (h *HostSnapshot) LeakedPorts(after *HostSnapshot) []Port {
+	var leaked []Port
+	for _, p := range newPorts(h.Ports, after.Ports) {
+		if p.PID != 0 && !h.pids[p.PID] {
+			leaked = append(leaked, p)
+		}
+	}
+	return leaked
+}
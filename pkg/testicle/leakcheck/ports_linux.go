@@ -0,0 +1,163 @@
+//go:build linux
+
+package leakcheck
+
+import (
+	"bufio"
+	"encoding/hex"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// tcpListen is the TCP_LISTEN state in /proc/net/tcp
+const tcpListen = "0A"
+
+// ProcessPorts returns the listening ports owned by pid
+func ProcessPorts(pid int) ([]Port, error) {
+	sockets, err := listeningSockets()
+	if err != nil {
+		return nil, err
+	}
+
+	var ports []Port
+	for _, inode := range socketInodes(pid) {
+		if p, ok := sockets[inode]; ok {
+			p.PID = pid
+			p.Command = processName(pid)
+			ports = append(ports, p)
+		}
+	}
+	sortPorts(ports)
+	return ports, nil
+}
+
+// hostPorts returns all listening ports with their owners and the set of running processes
+func hostPorts() ([]Port, map[int]bool, error) {
+	sockets, err := listeningSockets()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pids := make(map[int]bool)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		pids[pid] = true
+
+		// Sockets of other users' processes stay unowned
+		for _, inode := range socketInodes(pid) {
+			if p, ok := sockets[inode]; ok && p.PID == 0 {
+				p.PID = pid
+				p.Command = processName(pid)
+				sockets[inode] = p
+			}
+		}
+	}
+
+	ports := make([]Port, 0, len(sockets))
+	for _, p := range sockets {
+		ports = append(ports, p)
+	}
+	sortPorts(ports)
+	return ports, pids, nil
+}
+
+// listeningSockets reads the listening TCP sockets of the network namespace, keyed by inode
+func listeningSockets() (map[string]Port, error) {
+	sockets := make(map[string]Port)
+	for _, proto := range []string{"tcp", "tcp6"} {
+		file, err := os.Open(filepath.Join("/proc/net", proto))
+		if os.IsNotExist(err) {
+			continue // IPv6 disabled
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(file)
+		scanner.Scan() // Header
+		for scanner.Scan() {
+			// sl local_address rem_address st tx:rx tr:when retrnsmt uid timeout inode
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 10 || fields[3] != tcpListen {
+				continue
+			}
+			address, port, ok := parseSocketAddress(fields[1])
+			if !ok {
+				continue
+			}
+			sockets[fields[9]] = Port{Proto: proto, Address: address, Port: port}
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sockets, nil
+}
+
+// parseSocketAddress decodes "0100007F:1F90" into 127.0.0.1 and 8080. The
+// address is stored as 32-bit words in host (little-endian) byte order.
+func parseSocketAddress(s string) (string, int, bool) {
+	hostHex, portHex, ok := strings.Cut(s, ":")
+	if !ok {
+		return "", 0, false
+	}
+	raw, err := hex.DecodeString(hostHex)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return "", 0, false
+	}
+	port, err := strconv.ParseUint(portHex, 16, 16)
+	if err != nil {
+		return "", 0, false
+	}
+
+	ip := make(net.IP, len(raw))
+	for word := 0; word < len(raw); word += 4 {
+		for i := 0; i < 4; i++ {
+			ip[word+i] = raw[word+3-i]
+		}
+	}
+	return ip.String(), int(port), true
+}
+
+// socketInodes returns the inodes of the sockets pid has open
+func socketInodes(pid int) []string {
+	dir := filepath.Join("/proc", strconv.Itoa(pid), "fd")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var inodes []string
+	for _, entry := range entries {
+		target, err := os.Readlink(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if strings.HasPrefix(target, "socket:[") {
+			inodes = append(inodes, strings.TrimSuffix(strings.TrimPrefix(target, "socket:["), "]"))
+		}
+	}
+	return inodes
+}
+
+// processName returns the command name of pid
+func processName(pid int) string {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "comm"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package leakcheck
+
+// ProcessPorts returns the listening ports owned by pid
+func ProcessPorts(pid int) ([]Port, error) {
+	return nil, ErrUnsupported
+}
+
+// hostPorts returns all listening ports with their owners and the set of running processes
+func hostPorts() ([]Port, map[int]bool, error) {
+	return nil, nil, ErrUnsupported
+}
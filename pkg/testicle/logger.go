@@ -2,6 +2,7 @@ package testicle
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"time"
@@ -13,11 +14,16 @@ type Logger struct {
 	logger *log.Logger
 }
 
-// NewLogger creates a new logger instance
+// NewLogger creates a new logger instance writing to stdout
 func NewLogger(debug bool) *Logger {
+	return NewLoggerWithWriter(debug, os.Stdout)
+}
+
+// NewLoggerWithWriter creates a new logger instance writing to w
+func NewLoggerWithWriter(debug bool, w io.Writer) *Logger {
 	return &Logger{
 		debug:  debug,
-		logger: log.New(os.Stdout, "", 0),
+		logger: log.New(w, "", 0),
 	}
 }
 
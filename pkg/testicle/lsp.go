@@ -0,0 +1,669 @@
+package testicle
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LSP method names. Editors send the requests and textDocument/didSave;
+// the server sends testicle/publishStatus after every run.
+const (
+	LSPMethodInitialize    = "initialize"
+	LSPMethodShutdown      = "shutdown"
+	LSPMethodExit          = "exit"
+	LSPMethodDidSave       = "textDocument/didSave"
+	LSPMethodFileStatus    = "testicle/fileStatus"
+	LSPMethodCodeLens      = "testicle/codeLens"
+	LSPMethodLastFailure   = "testicle/lastFailure"
+	LSPMethodRun           = "testicle/run"
+	LSPMethodPublishStatus = "testicle/publishStatus"
+
+	// LSPRunCommand is the command of code lenses, to be sent back as a
+	// testicle/run request with the lens's arguments
+	LSPRunCommand = "testicle.run"
+)
+
+// Test statuses reported over the editor protocol besides
+// TestStatus.String()
+const (
+	LSPStatusUnknown = "unknown" // Not run since the server started
+	LSPStatusRunning = "running"
+)
+
+// JSON-RPC error codes
+const (
+	lspParseError     = -32700
+	lspInvalidParams  = -32602
+	lspMethodNotFound = -32601
+	lspInternalError  = -32603
+)
+
+// lspMessage is a JSON-RPC 2.0 request, notification, or response
+type lspMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  any              `json:"result,omitempty"`
+	Error   *lspError        `json:"error,omitempty"`
+}
+
+// lspError is a JSON-RPC error object
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// LSPFileParams names a file, absolute or relative to the test directory
+type LSPFileParams struct {
+	File string `json:"file"`
+}
+
+// LSPRunParams selects the tests of a testicle/run request: the package of
+// File, or only Test in it
+type LSPRunParams struct {
+	File string `json:"file"`
+	Test string `json:"test,omitempty"`
+}
+
+// LSPTestStatus is the state of one top-level test in a file. Lines are
+// 1-based.
+type LSPTestStatus struct {
+	Name       string `json:"name"`
+	Line       int    `json:"line"`
+	Kind       string `json:"kind"`
+	Status     string `json:"status"`
+	DurationMS int64  `json:"duration_ms,omitempty"`
+	Message    string `json:"message,omitempty"` // First line of a failure
+}
+
+// LSPFileStatus is the result of testicle/fileStatus and the params of
+// testicle/publishStatus
+type LSPFileStatus struct {
+	File  string          `json:"file"`
+	Tests []LSPTestStatus `json:"tests"`
+}
+
+// LSPCodeLens is a run target shown above a test
+type LSPCodeLens struct {
+	Line      int          `json:"line"`
+	Title     string       `json:"title"`
+	Command   string       `json:"command"`
+	Arguments LSPRunParams `json:"arguments"`
+}
+
+// LSPFailure is the last failure of a test, for testicle/lastFailure
+type LSPFailure struct {
+	Name   string `json:"name"`
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Error  string `json:"error"`
+	Output string `json:"output,omitempty"`
+}
+
+// LSPRunSummary is the result of testicle/run
+type LSPRunSummary struct {
+	Packages   []string `json:"packages"`
+	Passed     int      `json:"passed"`
+	Failed     int      `json:"failed"`
+	Skipped    int      `json:"skipped"`
+	DurationMS int64    `json:"duration_ms"`
+}
+
+// LSPServer answers editor queries about test status for `testicle lsp`.
+// It speaks JSON-RPC 2.0 with the Content-Length framing of the Language
+// Server Protocol, so LSP client libraries can talk to it, but only
+// implements the methods above. Saving a file re-runs the tests of its
+// package and of the packages importing it.
+type LSPServer struct {
+	dir       string
+	discovery *Discovery
+	executor  *Executor
+	logger    *Logger
+
+	runMu sync.Mutex // Serializes runs; the executor keeps per-run state
+
+	mu      sync.Mutex
+	tree    *TestTree
+	results map[string]*TestResult // By statusKey
+	running map[string]bool        // Package directories being run
+
+	writeMu sync.Mutex
+	out     *bufio.Writer
+	wg      sync.WaitGroup
+}
+
+// NewLSPServer creates an editor protocol server for the tests below
+// config.Dir. Logs go to logOutput, since the protocol owns stdout.
+func NewLSPServer(config *Config, logOutput io.Writer) (*LSPServer, error) {
+	if config == nil {
+		return nil, fmt.Errorf("config cannot be nil")
+	}
+	dir, err := filepath.Abs(config.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid test directory: %w", err)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("test directory does not exist: %s", dir)
+	}
+
+	logger := NewLoggerWithWriter(config.Debug, logOutput)
+	server := &LSPServer{
+		dir:       dir,
+		discovery: NewDiscovery(dir, logger),
+		executor:  NewExecutor(logger),
+		logger:    logger,
+		results:   make(map[string]*TestResult),
+		running:   make(map[string]bool),
+	}
+	server.executor.SetResultCallback(server.recordResult)
+	return server, nil
+}
+
+// Serve reads requests from r and writes responses and notifications to w
+// until the exit notification, the end of r, or ctx is done. Runs in
+// progress are waited for.
+func (s *LSPServer) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.out = bufio.NewWriter(w)
+	defer s.wg.Wait()
+
+	if _, err := s.discover(ctx); err != nil {
+		s.logger.Warn("Initial discovery failed: %v", err)
+	}
+
+	in := bufio.NewReader(r)
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		body, err := readLSPMessage(in)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var msg lspMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			s.reply(nil, nil, &lspError{Code: lspParseError, Message: err.Error()})
+			continue
+		}
+		if msg.Method == LSPMethodExit {
+			return nil
+		}
+		s.handle(ctx, &msg)
+	}
+}
+
+// handle dispatches one message. Runs answer from a goroutine so status
+// queries are served while tests execute.
+func (s *LSPServer) handle(ctx context.Context, msg *lspMessage) {
+	switch msg.Method {
+	case LSPMethodInitialize:
+		s.reply(msg.ID, map[string]any{
+			"serverInfo": map[string]string{"name": "testicle", "version": Version},
+			"capabilities": map[string]bool{
+				"fileStatus":  true,
+				"codeLens":    true,
+				"lastFailure": true,
+				"runOnSave":   true,
+			},
+		}, nil)
+
+	case LSPMethodShutdown:
+		s.reply(msg.ID, nil, nil)
+
+	case LSPMethodFileStatus:
+		var params LSPFileParams
+		if s.decode(msg, &params) {
+			s.reply(msg.ID, s.FileStatus(params.File), nil)
+		}
+
+	case LSPMethodCodeLens:
+		var params LSPFileParams
+		if s.decode(msg, &params) {
+			s.reply(msg.ID, s.CodeLenses(params.File), nil)
+		}
+
+	case LSPMethodLastFailure:
+		var params LSPRunParams
+		if s.decode(msg, &params) {
+			failure := s.LastFailure(params.File, params.Test)
+			if failure == nil {
+				s.reply(msg.ID, nil, nil)
+			} else {
+				s.reply(msg.ID, failure, nil)
+			}
+		}
+
+	case LSPMethodRun:
+		var params LSPRunParams
+		if !s.decode(msg, &params) {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			summary, err := s.Run(ctx, params)
+			if err != nil {
+				s.reply(msg.ID, nil, &lspError{Code: lspInvalidParams, Message: err.Error()})
+				return
+			}
+			s.reply(msg.ID, summary, nil)
+		}()
+
+	case LSPMethodDidSave:
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil || params.TextDocument.URI == "" {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			if _, err := s.RunImpacted(ctx, uriPath(params.TextDocument.URI)); err != nil {
+				s.logger.Error("Running tests for %s failed: %v", params.TextDocument.URI, err)
+			}
+		}()
+
+	default:
+		// Unknown notifications are ignored, as LSP requires
+		if msg.ID != nil {
+			s.reply(msg.ID, nil, &lspError{Code: lspMethodNotFound, Message: "method not found: " + msg.Method})
+		}
+	}
+}
+
+// decode unmarshals the params of msg, replying with an error if they are invalid
+func (s *LSPServer) decode(msg *lspMessage, params any) bool {
+	if err := json.Unmarshal(msg.Params, params); err != nil {
+		s.reply(msg.ID, nil, &lspError{Code: lspInvalidParams, Message: err.Error()})
+		return false
+	}
+	return true
+}
+
+// reply sends the response to a request; notifications get none
+func (s *LSPServer) reply(id *json.RawMessage, result any, rpcErr *lspError) {
+	if id == nil && rpcErr == nil {
+		return
+	}
+	msg := &lspMessage{JSONRPC: "2.0", ID: id, Error: rpcErr}
+	if rpcErr == nil {
+		// A null result must still be present in a response
+		if result == nil {
+			result = json.RawMessage("null")
+		}
+		msg.Result = result
+	}
+	s.send(msg)
+}
+
+// notify sends a notification to the editor
+func (s *LSPServer) notify(method string, params any) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		s.logger.Error("Encoding %s failed: %v", method, err)
+		return
+	}
+	s.send(&lspMessage{JSONRPC: "2.0", Method: method, Params: data})
+}
+
+// send writes one framed message
+func (s *LSPServer) send(msg *lspMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		s.logger.Error("Encoding a response failed: %v", err)
+		return
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n", len(data))
+	s.out.Write(data)
+	if err := s.out.Flush(); err != nil {
+		s.logger.Error("Writing a response failed: %v", err)
+	}
+}
+
+// readLSPMessage reads the body of one Content-Length framed message
+func readLSPMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) && line == "" && length < 0 {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("reading message header: %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil || length < 0 {
+				return nil, fmt.Errorf("invalid Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("message without Content-Length")
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("reading message body: %w", err)
+	}
+	return body, nil
+}
+
+// uriPath converts a file:// URI to a path; other text is taken as a path
+func uriPath(uri string) string {
+	if u, err := url.Parse(uri); err == nil && u.Scheme == "file" {
+		return filepath.FromSlash(u.Path)
+	}
+	return uri
+}
+
+// discover refreshes the test tree
+func (s *LSPServer) discover(ctx context.Context) (*TestTree, error) {
+	tree, err := s.discovery.DiscoverTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.tree = tree
+	s.mu.Unlock()
+	return tree, nil
+}
+
+// path resolves a file relative to the test directory
+func (s *LSPServer) path(file string) string {
+	file = uriPath(file)
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(s.dir, file)
+	}
+	return filepath.Clean(file)
+}
+
+// statusKey identifies a test result across runs
+func statusKey(file, name string) string {
+	return file + "\x00" + name
+}
+
+// recordResult keeps the latest result of each test
+func (s *LSPServer) recordResult(result *TestResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[statusKey(result.File, result.Name)] = result
+}
+
+// FileStatus returns the status of the top-level tests declared in file
+func (s *LSPServer) FileStatus(file string) *LSPFileStatus {
+	file = s.path(file)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := &LSPFileStatus{File: file, Tests: []LSPTestStatus{}}
+	if s.tree == nil {
+		return status
+	}
+	for _, pkg := range s.tree.Packages {
+		for _, test := range pkg.Tests {
+			if test.File != file {
+				continue
+			}
+			entry := LSPTestStatus{Name: test.Name, Line: test.Line, Kind: string(test.Kind), Status: LSPStatusUnknown}
+			if result, ok := s.results[statusKey(test.File, test.Name)]; ok {
+				entry.Status = result.Status.String()
+				entry.DurationMS = result.Duration.Milliseconds()
+				if result.Status == TestStatusFailed {
+					entry.Message = failureMessage(result)
+				}
+			}
+			if s.running[pkg.Dir] {
+				entry.Status = LSPStatusRunning
+			}
+			status.Tests = append(status.Tests, entry)
+		}
+	}
+	return status
+}
+
+// CodeLenses returns a run target above each test in file, titled with its
+// last status
+func (s *LSPServer) CodeLenses(file string) []LSPCodeLens {
+	status := s.FileStatus(file)
+	lenses := []LSPCodeLens{}
+	for _, test := range status.Tests {
+		title := "▶ run test"
+		switch test.Status {
+		case TestStatusPassed.String():
+			title = fmt.Sprintf("✅ passed (%dms) · run again", test.DurationMS)
+		case TestStatusFailed.String():
+			title = "❌ failed · run again"
+		case TestStatusSkipped.String():
+			title = "⏭️ skipped · run again"
+		case LSPStatusRunning:
+			title = "⏳ running"
+		}
+		lenses = append(lenses, LSPCodeLens{
+			Line:      test.Line,
+			Title:     title,
+			Command:   LSPRunCommand,
+			Arguments: LSPRunParams{File: status.File, Test: test.Name},
+		})
+	}
+	return lenses
+}
+
+// LastFailure returns the last failure of a test declared in file, or nil
+// if its last result wasn't a failure. With an empty test name, the first
+// failed test of the file is returned.
+func (s *LSPServer) LastFailure(file, test string) *LSPFailure {
+	file = s.path(file)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var names []string
+	for key, result := range s.results {
+		if result.File == file && result.Status == TestStatusFailed && (test == "" || result.Name == test) {
+			names = append(names, key)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return s.results[names[i]].Line < s.results[names[j]].Line
+	})
+	result := s.results[names[0]]
+	return &LSPFailure{
+		Name:   result.Name,
+		File:   result.File,
+		Line:   result.Line,
+		Error:  result.Error,
+		Output: result.Output,
+	}
+}
+
+// failureMessage returns the first line of a failed test's output, or its
+// error line
+func failureMessage(result *TestResult) string {
+	for _, line := range strings.Split(result.Output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return result.Error
+}
+
+// Run runs the tests of the package of params.File, or only params.Test,
+// and publishes the new status of the files involved
+func (s *LSPServer) Run(ctx context.Context, params LSPRunParams) (*LSPRunSummary, error) {
+	if params.File == "" {
+		return nil, fmt.Errorf("file is required")
+	}
+	tree, err := s.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(s.path(params.File))
+	for _, pkg := range tree.Packages {
+		if pkg.Dir != dir {
+			continue
+		}
+		tests := pkg.Tests
+		if params.Test != "" {
+			tests = nil
+			for _, test := range pkg.Tests {
+				if test.Name == params.Test {
+					tests = append(tests, test)
+				}
+			}
+			if len(tests) == 0 {
+				return nil, fmt.Errorf("no test %s in %s", params.Test, pkg.ImportPath)
+			}
+		}
+		selection := *pkg
+		selection.Tests = tests
+		return s.execute(ctx, []*PackageNode{&selection}, params.Test != "")
+	}
+	return nil, fmt.Errorf("no tests in %s", dir)
+}
+
+// RunImpacted runs the tests affected by a change to file (see
+// ImpactedPackages) and publishes their status
+func (s *LSPServer) RunImpacted(ctx context.Context, file string) (*LSPRunSummary, error) {
+	tree, err := s.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	packages := ImpactedPackages(tree, s.path(file))
+	if len(packages) == 0 {
+		s.logger.Debug("No tests affected by %s", file)
+		return &LSPRunSummary{Packages: []string{}}, nil
+	}
+	return s.execute(ctx, packages, false)
+}
+
+// execute runs whole packages, or with selected only the tests listed in
+// each package node
+func (s *LSPServer) execute(ctx context.Context, packages []*PackageNode, selected bool) (*LSPRunSummary, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	var tests []*TestInfo
+	patterns := make(map[string]string)
+	summary := &LSPRunSummary{}
+	s.mu.Lock()
+	for _, pkg := range packages {
+		summary.Packages = append(summary.Packages, pkg.ImportPath)
+		s.running[pkg.Dir] = true
+		var names []string
+		for _, test := range pkg.Tests {
+			if test.info != nil {
+				tests = append(tests, test.info)
+				names = append(names, regexp.QuoteMeta(test.Name))
+			}
+		}
+		if selected {
+			patterns[pkg.Dir] = "^(" + strings.Join(names, "|") + ")$"
+		}
+	}
+	s.mu.Unlock()
+	s.publish(packages)
+
+	s.executor.SetRunPatterns(patterns)
+	start := time.Now()
+	results, err := s.executor.ExecuteTests(ctx, tests)
+
+	s.mu.Lock()
+	for _, pkg := range packages {
+		delete(s.running, pkg.Dir)
+	}
+	s.mu.Unlock()
+	s.publish(packages)
+	if err != nil {
+		return nil, err
+	}
+
+	summary.Passed, summary.Failed, summary.Skipped = results.Passed, results.Failed, results.Skipped
+	summary.DurationMS = time.Since(start).Milliseconds()
+	return summary, nil
+}
+
+// publish sends the status of every test file of packages
+func (s *LSPServer) publish(packages []*PackageNode) {
+	seen := make(map[string]bool)
+	var files []string
+	for _, pkg := range packages {
+		for _, test := range pkg.Tests {
+			if !seen[test.File] {
+				seen[test.File] = true
+				files = append(files, test.File)
+			}
+		}
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		s.notify(LSPMethodPublishStatus, s.FileStatus(file))
+	}
+}
+
+// ImpactedPackages returns the packages of tree whose tests a change to
+// file can affect: the file's own package and the packages importing it
+// directly. Changes outside the tree's packages, such as to a package
+// without tests, still reach the tests importing it.
+func ImpactedPackages(tree *TestTree, file string) []*PackageNode {
+	dir := filepath.Dir(file)
+	modRoot, modPath := findModule(dir)
+	importPath := importPathFor(dir, modRoot, modPath, tree.Root)
+
+	var impacted []*PackageNode
+	for _, pkg := range tree.Packages {
+		if pkg.Dir == dir || packageImports(pkg.Dir)[importPath] {
+			impacted = append(impacted, pkg)
+		}
+	}
+	return impacted
+}
+
+// packageImports returns the import paths used by the Go files of dir,
+// tests included
+func packageImports(dir string) map[string]bool {
+	imports := make(map[string]bool)
+	files, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	fset := token.NewFileSet()
+	for _, file := range files {
+		parsed, err := parser.ParseFile(fset, file, nil, parser.ImportsOnly)
+		if err != nil {
+			continue
+		}
+		for _, spec := range parsed.Imports {
+			if path, err := strconv.Unquote(spec.Path.Value); err == nil {
+				imports[path] = true
+			}
+		}
+	}
+	return imports
+}
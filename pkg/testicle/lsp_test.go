@@ -0,0 +1,221 @@
+package testicle
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeLSPModule writes a module where api imports core and docs has no
+// connection to either
+func writeLSPModule(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":            "module example.com/lsp\n\ngo 1.21\n",
+		"core/core.go":      "package core\n\nfunc Add(a, b int) int { return a + b }\n",
+		"core/core_test.go": "package core\n\nimport \"testing\"\n\nfunc TestAdd(t *testing.T) {\n\tif Add(1, 2) != 3 {\n\t\tt.Fatal(\"bad sum\")\n\t}\n}\n\nfunc TestBroken(t *testing.T) {\n\tt.Fatal(\"broken on purpose\")\n}\n",
+		"api/api_test.go":   "package api\n\nimport (\n\t\"testing\"\n\n\t\"example.com/lsp/core\"\n)\n\nfunc TestAPI(t *testing.T) {\n\t_ = core.Add(1, 1)\n}\n",
+		"docs/docs_test.go": "package docs\n\nimport \"testing\"\n\nfunc TestDocs(t *testing.T) {}\n",
+	}
+	writeFiles(t, dir, files)
+	return dir
+}
+
+func TestReadLSPMessage(t *testing.T) {
+	input := "Content-Length: 2\r\nContent-Type: application/vscode-jsonrpc\r\n\r\n{}content-length: 4\r\n\r\nnull"
+	r := bufio.NewReader(strings.NewReader(input))
+	for _, want := range []string{"{}", "null"} {
+		body, err := readLSPMessage(r)
+		if err != nil || string(body) != want {
+			t.Fatalf("readLSPMessage() = %q, %v, want %q", body, err, want)
+		}
+	}
+	if _, err := readLSPMessage(r); err != io.EOF {
+		t.Errorf("Expected io.EOF at the end, got %v", err)
+	}
+
+	if _, err := readLSPMessage(bufio.NewReader(strings.NewReader("X-Other: 1\r\n\r\n"))); err == nil {
+		t.Error("Expected an error without Content-Length")
+	}
+}
+
+func TestImpactedPackages(t *testing.T) {
+	dir := writeLSPModule(t)
+	tree, err := NewDiscovery(dir, NewLogger(false)).DiscoverTree(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverTree failed: %v", err)
+	}
+
+	importPaths := func(file string) []string {
+		var paths []string
+		for _, pkg := range ImpactedPackages(tree, filepath.Join(dir, file)) {
+			paths = append(paths, pkg.ImportPath)
+		}
+		return paths
+	}
+	if got := strings.Join(importPaths("core/core.go"), ","); got != "example.com/lsp/api,example.com/lsp/core" {
+		t.Errorf("Expected core and its importer, got %s", got)
+	}
+	if got := strings.Join(importPaths("docs/docs_test.go"), ","); got != "example.com/lsp/docs" {
+		t.Errorf("Expected docs only, got %s", got)
+	}
+	if got := importPaths("README.md"); len(got) != 0 {
+		t.Errorf("Expected nothing for a file outside the packages, got %v", got)
+	}
+}
+
+// lspClient drives an LSPServer over pipes
+type lspClient struct {
+	t      *testing.T
+	in     *io.PipeWriter
+	out    *bufio.Reader
+	nextID int
+}
+
+func (c *lspClient) send(method string, id int, params any) {
+	c.t.Helper()
+	msg := map[string]any{"jsonrpc": "2.0", "method": method, "params": params}
+	if id > 0 {
+		msg["id"] = id
+	}
+	data, _ := json.Marshal(msg)
+	if _, err := fmt.Fprintf(c.in, "Content-Length: %d\r\n\r\n%s", len(data), data); err != nil {
+		c.t.Fatalf("Sending %s failed: %v", method, err)
+	}
+}
+
+// call sends a request and returns its result, collecting the
+// notifications received meanwhile
+func (c *lspClient) call(method string, params any, result any) []json.RawMessage {
+	c.t.Helper()
+	c.nextID++
+	c.send(method, c.nextID, params)
+	var published []json.RawMessage
+	for {
+		body, err := readLSPMessage(c.out)
+		if err != nil {
+			c.t.Fatalf("Reading the %s response failed: %v", method, err)
+		}
+		var msg struct {
+			ID     int             `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+			Result json.RawMessage `json:"result"`
+			Error  *lspError       `json:"error"`
+		}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			c.t.Fatalf("Invalid message %s: %v", body, err)
+		}
+		if msg.Method == LSPMethodPublishStatus {
+			published = append(published, msg.Params)
+			continue
+		}
+		if msg.ID != c.nextID {
+			c.t.Fatalf("Unexpected message %s", body)
+		}
+		if msg.Error != nil {
+			c.t.Fatalf("%s failed: %s", method, msg.Error.Message)
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("Invalid %s result %s: %v", method, msg.Result, err)
+			}
+		}
+		return published
+	}
+}
+
+func TestLSPServer(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test on generated packages")
+	}
+
+	dir := writeLSPModule(t)
+	server, err := NewLSPServer(&Config{Dir: dir}, io.Discard)
+	if err != nil {
+		t.Fatalf("NewLSPServer failed: %v", err)
+	}
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Serve(context.Background(), inR, outW)
+		outW.Close()
+	}()
+	client := &lspClient{t: t, in: inW, out: bufio.NewReader(outR)}
+
+	var info struct {
+		ServerInfo struct{ Version string } `json:"serverInfo"`
+	}
+	client.call(LSPMethodInitialize, map[string]any{}, &info)
+	if info.ServerInfo.Version != Version {
+		t.Errorf("Expected version %s, got %+v", Version, info)
+	}
+
+	coreTest := filepath.Join(dir, "core", "core_test.go")
+	var status LSPFileStatus
+	client.call(LSPMethodFileStatus, LSPFileParams{File: "core/core_test.go"}, &status)
+	if status.File != coreTest || len(status.Tests) != 2 || status.Tests[0].Status != LSPStatusUnknown || status.Tests[0].Line != 5 {
+		t.Fatalf("Unexpected status before a run: %+v", status)
+	}
+
+	// A run answers with a summary after publishing the running and final status
+	var summary LSPRunSummary
+	published := client.call(LSPMethodRun, LSPRunParams{File: coreTest}, &summary)
+	if summary.Passed != 1 || summary.Failed != 1 || len(published) != 2 {
+		t.Fatalf("Unexpected run summary %+v with %d notifications", summary, len(published))
+	}
+	json.Unmarshal(published[0], &status)
+	if status.Tests[0].Status != LSPStatusRunning {
+		t.Errorf("Expected the first notification to show running tests, got %+v", status)
+	}
+
+	var lenses []LSPCodeLens
+	client.call(LSPMethodCodeLens, LSPFileParams{File: coreTest}, &lenses)
+	if len(lenses) != 2 || !strings.HasPrefix(lenses[0].Title, "✅ passed") || !strings.HasPrefix(lenses[1].Title, "❌ failed") ||
+		lenses[1].Command != LSPRunCommand || lenses[1].Arguments.Test != "TestBroken" {
+		t.Errorf("Unexpected code lenses %+v", lenses)
+	}
+
+	var failure *LSPFailure
+	client.call(LSPMethodLastFailure, LSPRunParams{File: coreTest}, &failure)
+	if failure == nil || failure.Name != "TestBroken" || !strings.Contains(failure.Output, "broken on purpose") {
+		t.Errorf("Unexpected last failure %+v", failure)
+	}
+	failure = nil
+	client.call(LSPMethodLastFailure, LSPRunParams{File: coreTest, Test: "TestAdd"}, &failure)
+	if failure != nil {
+		t.Errorf("Expected no failure for a passing test, got %+v", failure)
+	}
+
+	// Saving core re-runs it and its importers in the background
+	client.send(LSPMethodDidSave, 0, map[string]any{"textDocument": map[string]string{"uri": "file://" + filepath.ToSlash(filepath.Join(dir, "core", "core.go"))}})
+	deadline := time.Now().Add(time.Minute)
+	for {
+		client.call(LSPMethodFileStatus, LSPFileParams{File: "api/api_test.go"}, &status)
+		if len(status.Tests) == 1 && status.Tests[0].Status == TestStatusPassed.String() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("The saved file's importers never ran: %+v", status)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	client.call(LSPMethodFileStatus, LSPFileParams{File: "docs/docs_test.go"}, &status)
+	if status.Tests[0].Status != LSPStatusUnknown {
+		t.Errorf("Expected unrelated packages not to run, got %+v", status)
+	}
+
+	client.call(LSPMethodShutdown, nil, nil)
+	client.send(LSPMethodExit, 0, nil)
+	if err := <-done; err != nil {
+		t.Errorf("Serve returned %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package testicle
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DefaultMetadataEnv lists the environment variables recorded in every
+// run's metadata when set: Go toolchain settings that change test behavior
+// and CI run identifiers. Config.MetadataEnv adds to it.
+var DefaultMetadataEnv = []string{
+	"GOFLAGS", "GOEXPERIMENT", "GODEBUG", "GOMAXPROCS", "GOGC", "GOMEMLIMIT",
+	"CGO_ENABLED", "GOAMD64", "GOARM64", "GOTOOLCHAIN",
+	"CI", "GITHUB_RUN_ID", "GITHUB_WORKFLOW", "GITHUB_REF",
+}
+
+// metadataTimeout bounds the git and go commands run to collect metadata
+const metadataTimeout = 5 * time.Second
+
+// RunMetadata describes the environment a run happened in, so runs from
+// different machines or CI jobs can be compared. Git fields are empty
+// outside a git work tree.
+type RunMetadata struct {
+	GitCommit string            `json:"git_commit,omitempty"`
+	GitBranch string            `json:"git_branch,omitempty"` // Empty on a detached HEAD
+	GitDirty  bool              `json:"git_dirty"`            // Uncommitted changes, including untracked files
+	GoVersion string            `json:"go_version"`           // Toolchain running the tests
+	OS        string            `json:"os"`
+	Arch      string            `json:"arch"`
+	Hostname  string            `json:"hostname,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+
+	// Extra go test arguments from the configuration, for every package
+	// and by package path (see PackageConfig)
+	GoTestArgs        []string            `json:"go_test_args,omitempty"`
+	PackageGoTestArgs map[string][]string `json:"package_go_test_args,omitempty"`
+}
+
+// CollectRunMetadata captures the environment of a run in dir, recording
+// DefaultMetadataEnv and the extra environment variables in env that are
+// set. It never fails; whatever can't be determined is left empty.
+func CollectRunMetadata(ctx context.Context, dir string, env []string) *RunMetadata {
+	ctx, cancel := context.WithTimeout(ctx, metadataTimeout)
+	defer cancel()
+
+	metadata := &RunMetadata{
+		GitCommit: commandOutput(ctx, dir, "git", "rev-parse", "HEAD"),
+		GoVersion: commandOutput(ctx, dir, "go", "env", "GOVERSION"),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+	if metadata.GitCommit != "" {
+		if branch := commandOutput(ctx, dir, "git", "rev-parse", "--abbrev-ref", "HEAD"); branch != "HEAD" {
+			metadata.GitBranch = branch
+		}
+		metadata.GitDirty = commandOutput(ctx, dir, "git", "status", "--porcelain") != ""
+	}
+	if metadata.GoVersion == "" {
+		metadata.GoVersion = runtime.Version() // No go command; testicle's own toolchain is the best guess
+	}
+	metadata.Hostname, _ = os.Hostname()
+
+	for _, name := range append(append([]string(nil), DefaultMetadataEnv...), env...) {
+		if value, ok := os.LookupEnv(name); ok {
+			if metadata.Env == nil {
+				metadata.Env = make(map[string]string)
+			}
+			metadata.Env[name] = value
+		}
+	}
+	return metadata
+}
+
+// commandOutput runs a command in dir and returns its trimmed output, or
+// "" if it fails
+func commandOutput(ctx context.Context, dir, name string, args ...string) string {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// String summarizes the metadata on one line, e.g.
+// "go1.23.4 linux/amd64 • main@1a2b3c4 (dirty) • go test -race"
+func (m *RunMetadata) String() string {
+	summary := fmt.Sprintf("%s %s/%s", m.GoVersion, m.OS, m.Arch)
+	if m.GitCommit != "" {
+		summary += " • " + m.commit()
+	}
+	if len(m.GoTestArgs) > 0 {
+		summary += " • go test " + strings.Join(m.GoTestArgs, " ")
+	}
+	if len(m.PackageGoTestArgs) > 0 {
+		summary += fmt.Sprintf(" • own go test args for %d package path(s)", len(m.PackageGoTestArgs))
+	}
+	return summary
+}
+
+// commit names the commit, with its branch and whether it was dirty
+func (m *RunMetadata) commit() string {
+	commit := m.GitCommit
+	if len(commit) > 7 {
+		commit = commit[:7]
+	}
+	if m.GitBranch != "" {
+		commit = m.GitBranch + "@" + commit
+	}
+	if m.GitDirty {
+		commit += " (dirty)"
+	}
+	return commit
+}
@@ -0,0 +1,72 @@
+package testicle
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCollectRunMetadata(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+	git("init", "-q", "-b", "main")
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/m\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "go.mod")
+	git("commit", "-q", "-m", "initial")
+
+	t.Setenv("GOFLAGS", "-count=1")
+	t.Setenv("TESTICLE_METADATA_EXTRA", "yes")
+	metadata := CollectRunMetadata(context.Background(), dir, []string{"TESTICLE_METADATA_EXTRA", "TESTICLE_METADATA_UNSET"})
+
+	if len(metadata.GitCommit) != 40 || metadata.GitBranch != "main" || metadata.GitDirty {
+		t.Errorf("Unexpected git metadata: %+v", metadata)
+	}
+	if metadata.GoVersion == "" || metadata.OS != runtime.GOOS || metadata.Arch != runtime.GOARCH {
+		t.Errorf("Unexpected toolchain metadata: %+v", metadata)
+	}
+	if metadata.Env["GOFLAGS"] != "-count=1" || metadata.Env["TESTICLE_METADATA_EXTRA"] != "yes" {
+		t.Errorf("Expected default and extra env vars, got %v", metadata.Env)
+	}
+	if _, ok := metadata.Env["TESTICLE_METADATA_UNSET"]; ok {
+		t.Error("Expected unset env vars to be left out")
+	}
+
+	// Untracked files make the tree dirty
+	if err := os.WriteFile(filepath.Join(dir, "new.go"), []byte("package m\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !CollectRunMetadata(context.Background(), dir, nil).GitDirty {
+		t.Error("Expected an untracked file to mark the tree dirty")
+	}
+
+	// Outside a work tree only the toolchain is known
+	if outside := CollectRunMetadata(context.Background(), t.TempDir(), nil); outside.GitCommit != "" || outside.GitDirty {
+		t.Errorf("Expected no git metadata outside a repository, got %+v", outside)
+	}
+}
+
+func TestRunMetadataString(t *testing.T) {
+	metadata := &RunMetadata{GoVersion: "go1.23.4", OS: "linux", Arch: "amd64"}
+	if got := metadata.String(); got != "go1.23.4 linux/amd64" {
+		t.Errorf("Unexpected summary %q", got)
+	}
+	metadata.GitCommit, metadata.GitBranch, metadata.GitDirty = "1a2b3c4d5e6f", "main", true
+	if got := metadata.String(); got != "go1.23.4 linux/amd64 • main@1a2b3c4 (dirty)" {
+		t.Errorf("Unexpected summary %q", got)
+	}
+}
@@ -0,0 +1,369 @@
+package testicle
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultOTelTimeout bounds an export without its own timeout
+const defaultOTelTimeout = 10 * time.Second
+
+// otlpBatchSize caps the spans sent in one request, well under collectors'
+// default request size limits
+const otlpBatchSize = 1000
+
+// OTLP span kinds and status codes
+const (
+	otlpSpanKindInternal = 1
+	otlpStatusOK         = 1
+	otlpStatusError      = 2
+)
+
+// OTelConfig exports each run as OpenTelemetry spans over OTLP/HTTP (JSON
+// encoding) to a collector, e.g. a local one at http://localhost:4318, so
+// test runs show up alongside the traces of the services they exercise:
+//
+//	otel:
+//	  endpoint: http://localhost:4318
+//	  attributes:
+//	    shard: ${SHARD_INDEX}
+//
+// A run is a root span with a child per package (or external suite), which
+// has a child for building its test binary and one per test; subtests
+// nest under their parents. Failed tests and their packages and run get an
+// error status.
+type OTelConfig struct {
+	// Endpoint is the collector's OTLP/HTTP base URL; /v1/traces is
+	// appended unless it is already there. Empty disables the export.
+	Endpoint string            `yaml:"endpoint"`
+	Headers  map[string]string `yaml:"headers"`      // E.g. authorization for a hosted collector
+	Service  string            `yaml:"service_name"` // Default: testicle
+	Timeout  string            `yaml:"timeout" schema:"duration"`
+
+	// Attributes are added to the resource of every span, with $VAR and
+	// ${VAR} expanded from the environment, e.g. a CI shard or job name
+	Attributes map[string]string `yaml:"attributes"`
+}
+
+// validate checks the endpoint and timeout of an enabled export
+func (c OTelConfig) validate() error {
+	if c.Endpoint == "" {
+		return nil
+	}
+	u, err := url.Parse(c.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("otel.endpoint %q must be an http or https URL", c.Endpoint)
+	}
+	if c.Timeout != "" {
+		if _, err := time.ParseDuration(c.Timeout); err != nil {
+			return fmt.Errorf("otel.timeout: invalid duration %q", c.Timeout)
+		}
+	}
+	return nil
+}
+
+// tracesURL returns the endpoint spans are posted to
+func (c OTelConfig) tracesURL() string {
+	endpoint := strings.TrimSuffix(c.Endpoint, "/")
+	if strings.HasSuffix(endpoint, "/v1/traces") {
+		return endpoint
+	}
+	return endpoint + "/v1/traces"
+}
+
+// The OTLP/HTTP JSON request body, per the protobuf JSON mapping of
+// ExportTraceServiceRequest: IDs are hex and 64-bit integers are strings
+
+type otlpTraceRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+// otlpString, otlpInt, and otlpBool build attributes
+func otlpString(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: &value}}
+}
+
+func otlpInt(key string, value int64) otlpAttribute {
+	s := strconv.FormatInt(value, 10)
+	return otlpAttribute{Key: key, Value: otlpValue{IntValue: &s}}
+}
+
+func otlpBool(key string, value bool) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{BoolValue: &value}}
+}
+
+// otlpTrace builds the spans of a run that started at started and finished
+// at finished. IDs come from random.
+type otlpTrace struct {
+	random  io.Reader
+	traceID string
+	spans   []otlpSpan
+}
+
+// newID returns a random hex ID of n bytes
+func (t *otlpTrace) newID(n int) string {
+	id := make([]byte, n)
+	io.ReadFull(t.random, id)
+	return hex.EncodeToString(id)
+}
+
+// add appends a span and returns its ID
+func (t *otlpTrace) add(parent, name string, start, end time.Time, attributes []otlpAttribute, status otlpStatus) string {
+	if end.Before(start) {
+		end = start
+	}
+	id := t.newID(8)
+	t.spans = append(t.spans, otlpSpan{
+		TraceID:           t.traceID,
+		SpanID:            id,
+		ParentSpanID:      parent,
+		Name:              name,
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: strconv.FormatInt(start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(end.UnixNano(), 10),
+		Attributes:        attributes,
+		Status:            status,
+	})
+	return id
+}
+
+// buildOTLPTrace lays a run out as spans: the run, its packages, their
+// builds, and their tests, with subtests under their parents
+func buildOTLPTrace(results *TestResults, profile string, started, finished time.Time, random io.Reader) *otlpTrace {
+	trace := &otlpTrace{random: random}
+	trace.traceID = trace.newID(16)
+
+	attributes := []otlpAttribute{
+		otlpInt("testicle.tests.passed", int64(results.Passed)),
+		otlpInt("testicle.tests.failed", int64(results.Failed)),
+		otlpInt("testicle.tests.skipped", int64(results.Skipped)),
+	}
+	if profile != "" {
+		attributes = append(attributes, otlpString("testicle.profile", profile))
+	}
+	if m := results.Metadata; m != nil {
+		if m.GitCommit != "" {
+			attributes = append(attributes, otlpString("vcs.ref.head.revision", m.GitCommit), otlpBool("testicle.git.dirty", m.GitDirty))
+		}
+		if m.GitBranch != "" {
+			attributes = append(attributes, otlpString("vcs.ref.head.name", m.GitBranch))
+		}
+		attributes = append(attributes, otlpString("testicle.go.version", m.GoVersion))
+		if len(m.GoTestArgs) > 0 {
+			attributes = append(attributes, otlpString("testicle.go_test_args", strings.Join(m.GoTestArgs, " ")))
+		}
+	}
+	runID := trace.add("", "testicle run", started, finished, attributes, failureStatus(results.Failed, "test(s) failed"))
+
+	for _, timing := range sortedPackages(results) {
+		failed := 0
+		for _, result := range timing.Tests {
+			if result.Status == TestStatusFailed {
+				failed++
+			}
+		}
+		packageID := trace.add(runID, timing.Package, timing.Started, timing.Finished,
+			[]otlpAttribute{otlpString("test.suite.name", timing.Package), otlpInt("testicle.tests", int64(len(timing.Tests)))},
+			failureStatus(failed, "test(s) failed"))
+		if !timing.FirstTest.IsZero() {
+			trace.add(packageID, "build", timing.Started, timing.FirstTest, nil, otlpStatus{})
+		}
+
+		// Parents are added before their subtests, which start after them
+		tests := append([]*TestResult(nil), timing.Tests...)
+		sort.SliceStable(tests, func(i, j int) bool {
+			return strings.Count(tests[i].Name, "/") < strings.Count(tests[j].Name, "/")
+		})
+		ids := make(map[string]string, len(tests))
+		for _, result := range tests {
+			parent := packageID
+			if i := strings.LastIndex(result.Name, "/"); i >= 0 && ids[result.Name[:i]] != "" {
+				parent = ids[result.Name[:i]]
+			}
+			start, end := result.Started, testFinished(result, timing)
+			if start.IsZero() {
+				// External suites report durations only
+				start, end = timing.Started, timing.Started.Add(result.Duration)
+			}
+			ids[result.Name] = trace.add(parent, result.Name, start, end, testAttributes(result, timing.Package), testStatus(result))
+		}
+	}
+	return trace
+}
+
+// testAttributes describes a test with the OpenTelemetry test conventions
+func testAttributes(result *TestResult, suite string) []otlpAttribute {
+	status := map[TestStatus]string{TestStatusPassed: "pass", TestStatusFailed: "fail", TestStatusSkipped: "skip"}[result.Status]
+	attributes := []otlpAttribute{
+		otlpString("test.case.name", result.Name),
+		otlpString("test.suite.name", suite),
+		otlpString("test.case.result.status", status),
+	}
+	if result.File != "" {
+		attributes = append(attributes, otlpString("code.file.path", result.File), otlpInt("code.line.number", int64(result.Line)))
+	}
+	return attributes
+}
+
+// testStatus is an error status with the test's error for failed tests
+func testStatus(result *TestResult) otlpStatus {
+	switch result.Status {
+	case TestStatusFailed:
+		return otlpStatus{Code: otlpStatusError, Message: result.Error}
+	case TestStatusPassed:
+		return otlpStatus{Code: otlpStatusOK}
+	default:
+		return otlpStatus{}
+	}
+}
+
+// failureStatus is an error status when failed is non-zero
+func failureStatus(failed int, message string) otlpStatus {
+	if failed > 0 {
+		return otlpStatus{Code: otlpStatusError, Message: fmt.Sprintf("%d %s", failed, message)}
+	}
+	return otlpStatus{Code: otlpStatusOK}
+}
+
+// otlpResourceAttributes describes the process that ran the tests
+func otlpResourceAttributes(config OTelConfig) []otlpAttribute {
+	service := config.Service
+	if service == "" {
+		service = "testicle"
+	}
+	attributes := []otlpAttribute{
+		otlpString("service.name", service),
+		otlpString("service.version", Version),
+		otlpString("os.type", runtime.GOOS),
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		attributes = append(attributes, otlpString("host.name", hostname))
+	}
+
+	keys := make([]string, 0, len(config.Attributes))
+	for key := range config.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		attributes = append(attributes, otlpString(key, os.ExpandEnv(config.Attributes[key])))
+	}
+	return attributes
+}
+
+// ExportOTLPTrace sends a run that started at started as OpenTelemetry
+// spans to the collector of config (see OTelConfig). It returns the trace
+// ID, to find the run in a tracing UI.
+func ExportOTLPTrace(ctx context.Context, config OTelConfig, results *TestResults, profile string, started time.Time) (string, error) {
+	if err := config.validate(); err != nil {
+		return "", err
+	}
+	timeout := defaultOTelTimeout
+	if config.Timeout != "" {
+		timeout, _ = time.ParseDuration(config.Timeout)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	trace := buildOTLPTrace(results, profile, started, time.Now(), rand.Reader)
+	resource := otlpResource{Attributes: otlpResourceAttributes(config)}
+	for len(trace.spans) > 0 {
+		batch := trace.spans[:min(len(trace.spans), otlpBatchSize)]
+		trace.spans = trace.spans[len(batch):]
+		err := postOTLP(ctx, config, otlpTraceRequest{ResourceSpans: []otlpResourceSpans{{
+			Resource:   resource,
+			ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "github.com/nzions/sharedgolibs/pkg/testicle", Version: Version}, Spans: batch}},
+		}}})
+		if err != nil {
+			return "", err
+		}
+	}
+	return trace.traceID, nil
+}
+
+// postOTLP sends one export request
+func postOTLP(ctx context.Context, config OTelConfig, body otlpTraceRequest) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("encoding spans: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.tracesURL(), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("exporting spans: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range config.Headers {
+		req.Header.Set(key, os.ExpandEnv(value))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("exporting spans: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("exporting spans: collector returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
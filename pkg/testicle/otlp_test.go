@@ -0,0 +1,156 @@
+package testicle
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportOTLPTrace(t *testing.T) {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	tests := []*TestResult{
+		{Name: "TestA/sub", Status: TestStatusFailed, Error: "boom", Started: at(150), Finished: at(180)},
+		{Name: "TestA", File: "a/a_test.go", Line: 10, Status: TestStatusFailed, Started: at(100), Finished: at(200)},
+		{Name: "TestB", Status: TestStatusSkipped, Started: at(200), Finished: at(210)},
+	}
+	results := &TestResults{
+		Failed:   2,
+		Skipped:  1,
+		Tests:    tests,
+		Metadata: &RunMetadata{GitCommit: "1a2b3c4", GitBranch: "main", GoVersion: "go1.23.4"},
+		Packages: []*PackageTiming{{Package: "a", Started: start, FirstTest: at(100), Finished: at(300), Tests: tests}},
+	}
+
+	var request otlpTraceRequest
+	var header http.Header
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			http.NotFound(w, r)
+			return
+		}
+		header = r.Header
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Invalid export request: %v", err)
+		}
+	}))
+	defer collector.Close()
+
+	t.Setenv("TESTICLE_SHARD", "3")
+	config := OTelConfig{
+		Endpoint:   collector.URL,
+		Headers:    map[string]string{"Authorization": "Bearer ${TESTICLE_SHARD}"},
+		Attributes: map[string]string{"shard": "${TESTICLE_SHARD}"},
+	}
+	traceID, err := ExportOTLPTrace(context.Background(), config, results, "ci", start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := header.Get("Authorization"); got != "Bearer 3" {
+		t.Errorf("Expected expanded header, got %q", got)
+	}
+
+	if len(request.ResourceSpans) != 1 || len(request.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("Expected one resource and scope, got %+v", request)
+	}
+	resource := attributeMap(request.ResourceSpans[0].Resource.Attributes)
+	if resource["service.name"] != "testicle" || resource["shard"] != "3" {
+		t.Errorf("Expected service.name and shard resource attributes, got %v", resource)
+	}
+
+	spans := make(map[string]otlpSpan)
+	for _, span := range request.ResourceSpans[0].ScopeSpans[0].Spans {
+		if span.TraceID != traceID {
+			t.Errorf("Span %s has trace ID %s, want %s", span.Name, span.TraceID, traceID)
+		}
+		spans[span.Name] = span
+	}
+	if len(spans) != 6 {
+		t.Fatalf("Expected run, package, build, and 3 test spans, got %d: %v", len(spans), spans)
+	}
+
+	checks := []struct {
+		name, parent string
+		status       int
+		attributes   map[string]string
+	}{
+		{"testicle run", "", otlpStatusError, map[string]string{"testicle.profile": "ci", "vcs.ref.head.revision": "1a2b3c4", "testicle.tests.failed": "2"}},
+		{"a", "testicle run", otlpStatusError, map[string]string{"test.suite.name": "a"}},
+		{"build", "a", 0, nil},
+		{"TestA", "a", otlpStatusError, map[string]string{"test.case.result.status": "fail", "code.file.path": "a/a_test.go", "code.line.number": "10"}},
+		{"TestA/sub", "TestA", otlpStatusError, map[string]string{"test.case.name": "TestA/sub"}},
+		{"TestB", "a", 0, map[string]string{"test.case.result.status": "skip"}},
+	}
+	for _, check := range checks {
+		span := spans[check.name]
+		if check.parent != "" && span.ParentSpanID != spans[check.parent].SpanID {
+			t.Errorf("Expected %s under %s", check.name, check.parent)
+		}
+		if check.parent == "" && span.ParentSpanID != "" {
+			t.Errorf("Expected %s to be the root span", check.name)
+		}
+		if span.Status.Code != check.status {
+			t.Errorf("%s: expected status %d, got %+v", check.name, check.status, span.Status)
+		}
+		attributes := attributeMap(span.Attributes)
+		for key, want := range check.attributes {
+			if attributes[key] != want {
+				t.Errorf("%s: expected %s=%q, got %q", check.name, key, want, attributes[key])
+			}
+		}
+	}
+	if got := spans["TestA/sub"].Status.Message; got != "boom" {
+		t.Errorf("Expected the failure as status message, got %q", got)
+	}
+	if got, want := spans["build"].EndTimeUnixNano, spans["TestA"].StartTimeUnixNano; got != want {
+		t.Errorf("Expected the build to end when the first test started, got %s, want %s", got, want)
+	}
+}
+
+func TestExportOTLPTraceCollectorError(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+	}))
+	defer collector.Close()
+
+	_, err := ExportOTLPTrace(context.Background(), OTelConfig{Endpoint: collector.URL + "/v1/traces"}, &TestResults{}, "", time.Now())
+	if err == nil || !strings.Contains(err.Error(), "quota exceeded") {
+		t.Errorf("Expected the collector's error, got %v", err)
+	}
+}
+
+func TestOTelConfigValidate(t *testing.T) {
+	for _, config := range []OTelConfig{
+		{Endpoint: "localhost:4318"},
+		{Endpoint: "ftp://collector"},
+		{Endpoint: "http://localhost:4318", Timeout: "soon"},
+	} {
+		if err := config.validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", config)
+		}
+	}
+	if err := (OTelConfig{}).validate(); err != nil {
+		t.Errorf("Expected a disabled export to be valid, got %v", err)
+	}
+}
+
+// attributeMap flattens attributes to their values as strings
+func attributeMap(attributes []otlpAttribute) map[string]string {
+	m := make(map[string]string, len(attributes))
+	for _, a := range attributes {
+		switch {
+		case a.Value.StringValue != nil:
+			m[a.Key] = *a.Value.StringValue
+		case a.Value.IntValue != nil:
+			m[a.Key] = *a.Value.IntValue
+		case a.Value.BoolValue != nil:
+			m[a.Key] = map[bool]string{true: "true", false: "false"}[*a.Value.BoolValue]
+		}
+	}
+	return m
+}
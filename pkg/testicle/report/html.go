@@ -0,0 +1,287 @@
+package report
+
+import (
+	"bytes"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
+//go:embed templates/*.html
+var defaultTemplates embed.FS
+
+// logoFiles are the Embed files shown in the default header, in order of
+// preference
+var logoFiles = []string{"logo.svg", "logo.png", "logo.jpg", "logo.gif"}
+
+// Theme sets the colors of the default templates. Empty fields keep the
+// DefaultTheme color.
+type Theme struct {
+	Accent     string // Header bar and links
+	Background string
+	Text       string
+	Passed     string
+	Failed     string
+	Skipped    string // Also used for tests not run
+}
+
+// DefaultTheme is the theme of reports without WithTheme
+var DefaultTheme = Theme{
+	Accent:     "#2f6feb",
+	Background: "#ffffff",
+	Text:       "#1f2328",
+	Passed:     "#1a7f37",
+	Failed:     "#cf222e",
+	Skipped:    "#9a6700",
+}
+
+// Option configures an HTML renderer
+type Option func(*options)
+
+type options struct {
+	theme     Theme
+	overrides []templateSource
+	assets    fs.FS
+}
+
+type templateSource struct {
+	fsys     fs.FS
+	patterns []string
+}
+
+// WithTheme sets the colors of the report and widget
+func WithTheme(theme Theme) Option {
+	return func(o *options) {
+		o.theme = theme
+	}
+}
+
+// WithTemplates parses the files of fsys matching patterns (default
+// "*.html") after the default templates. Templates they define replace the
+// defaults of the same name; see the package documentation for the names.
+// Later options win over earlier ones.
+func WithTemplates(fsys fs.FS, patterns ...string) Option {
+	if len(patterns) == 0 {
+		patterns = []string{"*.html"}
+	}
+	return func(o *options) {
+		o.overrides = append(o.overrides, templateSource{fsys: fsys, patterns: patterns})
+	}
+}
+
+// Embed inlines files of fsys into reports: every *.css file at its root is
+// added after the default styles, a logo.svg (or .png, .jpg, .gif) is shown
+// in the header, and templates can inline any file as a data URL with
+// {{asset "name"}}. Reports stay a single file that can be mailed or
+// attached to a CI run.
+func Embed(fsys fs.FS) Option {
+	return func(o *options) {
+		o.assets = fsys
+	}
+}
+
+// HTML renders reports as a self-contained HTML page
+type HTML struct {
+	templates *template.Template
+	theme     Theme
+	assets    fs.FS
+}
+
+// NewHTML creates an HTML renderer, returning an error if a template fails
+// to parse
+func NewHTML(opts ...Option) (*HTML, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	h := &HTML{theme: o.theme, assets: o.assets}
+	h.theme.fillDefaults()
+
+	templates, err := template.New("report").Funcs(h.funcs()).ParseFS(defaultTemplates, "templates/*.html")
+	if err != nil {
+		return nil, fmt.Errorf("parsing default report templates: %w", err)
+	}
+	for _, source := range o.overrides {
+		if templates, err = templates.ParseFS(source.fsys, source.patterns...); err != nil {
+			return nil, fmt.Errorf("parsing report templates: %w", err)
+		}
+	}
+	h.templates = templates
+	return h, nil
+}
+
+// Render writes report as a complete HTML page
+func (h *HTML) Render(w io.Writer, report *Report) error {
+	return h.templates.ExecuteTemplate(w, "report", report)
+}
+
+// Widget returns a renderer of the compact status widget: the run's status,
+// counts, and failed tests, as an HTML fragment with its own scoped styles
+// for inlining in another page
+func (h *HTML) Widget() Renderer {
+	return widgetRenderer{h}
+}
+
+// WidgetHTML renders the status widget for inlining in an html/template
+// page, such as a service dashboard
+func (h *HTML) WidgetHTML(report *Report) (template.HTML, error) {
+	var buffer bytes.Buffer
+	if err := h.Widget().Render(&buffer, report); err != nil {
+		return "", err
+	}
+	return template.HTML(buffer.String()), nil
+}
+
+type widgetRenderer struct {
+	html *HTML
+}
+
+func (r widgetRenderer) Render(w io.Writer, report *Report) error {
+	return r.html.templates.ExecuteTemplate(w, "widget", report)
+}
+
+// funcs are the template functions available to default and overriding
+// templates
+func (h *HTML) funcs() template.FuncMap {
+	return template.FuncMap{
+		"duration":  formatDuration,
+		"themeCSS":  h.themeCSS,
+		"assetCSS":  h.assetCSS,
+		"logo":      h.logo,
+		"asset":     h.asset,
+		"spanStyle": spanStyle,
+		"traceURL":  traceURL,
+	}
+}
+
+// spanStyle positions a span on its timeline track
+func spanStyle(timeline *Timeline, span Span) template.CSS {
+	if timeline.Duration <= 0 {
+		return ""
+	}
+	total := float64(timeline.Duration)
+	return template.CSS(fmt.Sprintf("left: %.3f%%; width: %.3f%%", float64(span.Start)/total*100, max(float64(span.Length)/total*100, 0.1)))
+}
+
+// traceURL inlines a Chrome trace as a data URL for downloading
+func traceURL(trace []byte) template.URL {
+	return template.URL("data:application/json;base64," + base64.StdEncoding.EncodeToString(trace))
+}
+
+// themeCSS declares the theme as CSS custom properties
+func (h *HTML) themeCSS() template.CSS {
+	var css strings.Builder
+	for _, property := range []struct{ name, value string }{
+		{"accent", h.theme.Accent},
+		{"background", h.theme.Background},
+		{"text", h.theme.Text},
+		{"passed", h.theme.Passed},
+		{"failed", h.theme.Failed},
+		{"skipped", h.theme.Skipped},
+	} {
+		fmt.Fprintf(&css, "--testicle-%s: %s; ", property.name, cssValue(property.value))
+	}
+	return template.CSS(strings.TrimSpace(css.String()))
+}
+
+// assetCSS returns the *.css files at the root of the Embed files, in name
+// order
+func (h *HTML) assetCSS() (template.CSS, error) {
+	if h.assets == nil {
+		return "", nil
+	}
+	names, err := fs.Glob(h.assets, "*.css")
+	if err != nil {
+		return "", err
+	}
+	var css strings.Builder
+	for _, name := range names {
+		data, err := fs.ReadFile(h.assets, name)
+		if err != nil {
+			return "", fmt.Errorf("reading report asset: %w", err)
+		}
+		fmt.Fprintf(&css, "/* %s */\n%s\n", name, data)
+	}
+	return template.CSS(css.String()), nil
+}
+
+// logo returns the Embed logo as a data URL, or "" without one
+func (h *HTML) logo() (template.URL, error) {
+	if h.assets == nil {
+		return "", nil
+	}
+	for _, name := range logoFiles {
+		if _, err := fs.Stat(h.assets, name); err == nil {
+			return h.asset(name)
+		}
+	}
+	return "", nil
+}
+
+// asset returns a file of the Embed files as a data URL
+func (h *HTML) asset(name string) (template.URL, error) {
+	if h.assets == nil {
+		return "", fmt.Errorf("report asset %s: no embedded files", name)
+	}
+	data, err := fs.ReadFile(h.assets, name)
+	if err != nil {
+		return "", fmt.Errorf("reading report asset: %w", err)
+	}
+	mediaType := mime.TypeByExtension(path.Ext(name))
+	if mediaType == "" {
+		mediaType = "application/octet-stream"
+	}
+	return template.URL("data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data)), nil
+}
+
+// fillDefaults sets empty colors from DefaultTheme
+func (t *Theme) fillDefaults() {
+	for _, color := range []struct {
+		value    *string
+		fallback string
+	}{
+		{&t.Accent, DefaultTheme.Accent},
+		{&t.Background, DefaultTheme.Background},
+		{&t.Text, DefaultTheme.Text},
+		{&t.Passed, DefaultTheme.Passed},
+		{&t.Failed, DefaultTheme.Failed},
+		{&t.Skipped, DefaultTheme.Skipped},
+	} {
+		if *color.value == "" {
+			*color.value = color.fallback
+		}
+	}
+}
+
+// cssValue drops characters that would end a CSS declaration, so a theme
+// color can't inject rules
+func cssValue(value string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(";{}<>\"'\\", r) {
+			return -1
+		}
+		return r
+	}, value)
+}
+
+// formatDuration shows durations at a precision that suits their size
+func formatDuration(d time.Duration) string {
+	switch {
+	case d == 0:
+		return "0s"
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	case d < time.Minute:
+		return d.Round(10 * time.Millisecond).String()
+	default:
+		return d.Round(time.Second).String()
+	}
+}
@@ -0,0 +1,212 @@
+package report
+
+import (
+	"bytes"
+	"flag"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// sampleReport has a package of each status and output needing escaping
+func sampleReport() *Report {
+	return &Report{
+		Title:    "example.com/app",
+		Started:  time.Date(2026, 3, 14, 9, 26, 53, 0, time.UTC),
+		Duration: 4*time.Second + 250*time.Millisecond,
+		Passed:   2,
+		Failed:   1,
+		Skipped:  1,
+		NotRun:   1,
+		Metadata: []Field{
+			{Label: "Commit", Value: "0123456789abcdef"},
+			{Label: "Go", Value: "go1.24.3"},
+		},
+		Clusters: []Cluster{
+			{Summary: "2 tests failing with connection refused to :8083", Hint: "Likely missing dependency: nothing is serving port 8083",
+				Tests: []string{"example.com/app/api.TestDelete", "example.com/app/api.TestList"}},
+		},
+		Timeline: &Timeline{
+			Duration: 2 * time.Second,
+			Tracks: []*Track{
+				{Name: "example.com/app/api", Spans: []Span{
+					{Name: "example.com/app/api", Kind: SpanPackage, Length: 1500 * time.Millisecond},
+					{Name: "build", Kind: SpanBuild, Length: 500 * time.Millisecond},
+					{Name: "TestDelete", Kind: SpanTest, Status: StatusFailed, Start: time.Second, Length: 3 * time.Millisecond},
+				}},
+			},
+			Trace: []byte(`{"traceEvents":[]}`),
+		},
+		Packages: []*Package{
+			{
+				Name:     "example.com/app/api",
+				Duration: 1500 * time.Millisecond,
+				Tests: []*Test{
+					{Name: "TestCreate", Status: StatusPassed, Duration: 12 * time.Millisecond, File: "/src/app/api/api_test.go", Line: 12},
+					{Name: "TestDelete", Status: StatusFailed, Duration: 3 * time.Millisecond, Error: "--- FAIL: TestDelete (0.00s)",
+						Output: "api_test.go:40: got <nil>, want \"not found\""},
+				},
+			},
+			{
+				Name:     "example.com/app/store",
+				Duration: 2 * time.Second,
+				Tests: []*Test{
+					{Name: "TestMigrate", Status: StatusPassed, Duration: 1100 * time.Millisecond, Output: "    store_test.go:18: applied 3 migrations\n"},
+					{Name: "TestEviction", Status: StatusSkipped},
+					{Name: "TestCompaction", Status: StatusNotRun},
+				},
+			},
+		},
+	}
+}
+
+// checkGolden compares got with testdata/name, rewriting it with -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Reading golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file; run with -update and review the diff\n%s", name, got)
+	}
+}
+
+func TestHTMLGolden(t *testing.T) {
+	renderer, err := NewHTML()
+	if err != nil {
+		t.Fatalf("NewHTML failed: %v", err)
+	}
+
+	var page bytes.Buffer
+	if err := renderer.Render(&page, sampleReport()); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	checkGolden(t, "report.html", page.Bytes())
+
+	var widget bytes.Buffer
+	if err := renderer.Widget().Render(&widget, sampleReport()); err != nil {
+		t.Fatalf("Widget render failed: %v", err)
+	}
+	checkGolden(t, "widget.html", widget.Bytes())
+}
+
+func TestHTMLEscapesOutput(t *testing.T) {
+	renderer, err := NewHTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := sampleReport()
+	report.Title = "<script>alert(1)</script>"
+
+	var page bytes.Buffer
+	if err := renderer.Render(&page, report); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(page.String(), "<script>") || !strings.Contains(page.String(), "got &lt;nil&gt;") {
+		t.Error("Expected the title and test output escaped")
+	}
+}
+
+func TestHTMLTheming(t *testing.T) {
+	templates := fstest.MapFS{
+		"header.html": {Data: []byte(`{{define "header"}}<header class="acme">ACME {{.Title}} {{asset "badge.txt"}}</header>{{end}}`)},
+	}
+	assets := fstest.MapFS{
+		"brand.css": {Data: []byte(".acme { font-weight: bold; }")},
+		"logo.svg":  {Data: []byte("<svg/>")},
+		"badge.txt": {Data: []byte("ok")},
+	}
+	renderer, err := NewHTML(
+		WithTheme(Theme{Accent: "#ff6600; } body { display: none"}),
+		WithTemplates(templates),
+		Embed(assets),
+	)
+	if err != nil {
+		t.Fatalf("NewHTML failed: %v", err)
+	}
+
+	var page bytes.Buffer
+	if err := renderer.Render(&page, sampleReport()); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	html := page.String()
+	for _, want := range []string{
+		`<header class="acme">ACME example.com/app data:text/plain; charset=utf-8;base64,b2s=</header>`,
+		"--testicle-accent: #ff6600  body  display: none;",
+		"--testicle-failed: #cf222e;",
+		".acme { font-weight: bold; }",
+		`<details class="package failed" open>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Expected the page to contain %q", want)
+		}
+	}
+
+	// The default header showed the logo; the override doesn't
+	if strings.Contains(html, "data:image/svg") {
+		t.Error("Expected the overridden header without the logo")
+	}
+	renderer, err = NewHTML(Embed(assets))
+	if err != nil {
+		t.Fatal(err)
+	}
+	page.Reset()
+	if err := renderer.Render(&page, sampleReport()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(page.String(), `<img src="data:image/svg&#43;xml;base64,PHN2Zy8&#43;" alt="">`) {
+		t.Error("Expected the default header to inline the logo")
+	}
+}
+
+func TestHTMLTemplateErrors(t *testing.T) {
+	broken := fstest.MapFS{"broken.html": {Data: []byte(`{{define "header"}}{{.Title}`)}}
+	if _, err := NewHTML(WithTemplates(broken)); err == nil {
+		t.Error("Expected an error for a template that doesn't parse")
+	}
+
+	// A missing asset fails the render rather than producing a broken page
+	missing := fstest.MapFS{"header.html": {Data: []byte(`{{define "header"}}{{asset "logo.svg"}}{{end}}`)}}
+	renderer, err := NewHTML(WithTemplates(missing))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := renderer.Render(&bytes.Buffer{}, sampleReport()); err == nil {
+		t.Error("Expected an error for an asset without Embed")
+	}
+}
+
+func TestWidgetHTML(t *testing.T) {
+	renderer, err := NewHTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	widget, err := renderer.WidgetHTML(sampleReport())
+	if err != nil {
+		t.Fatalf("WidgetHTML failed: %v", err)
+	}
+
+	// Inlined in another page without being escaped again
+	dashboard := template.Must(template.New("dashboard").Parse(`<section>{{.}}</section>`))
+	var page bytes.Buffer
+	if err := dashboard.Execute(&page, widget); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(page.String(), `<div class="testicle-widget testicle-widget-failed">`) ||
+		!strings.Contains(page.String(), "<li>TestDelete</li>") {
+		t.Errorf("Unexpected dashboard:\n%s", page.String())
+	}
+}
@@ -0,0 +1,160 @@
+// Package report renders testicle run results as a self-contained HTML
+// page, or as a compact status widget for inlining in other dashboards.
+//
+// A Report is plain data, built by testicle.NewReport from a run or by hand:
+//
+//	renderer, err := report.NewHTML(
+//		report.WithTheme(report.Theme{Accent: "#ff6600"}),
+//		report.WithTemplates(os.DirFS("branding"), "*.html"),
+//		report.Embed(os.DirFS("branding/assets")),
+//	)
+//	err = renderer.Render(w, rep)
+//
+// Templates named like the defaults ("report", "style", "header",
+// "summary", "clusters", "timeline", "package", "test", "widget") replace them, so a team can brand
+// the header without copying the rest. Files from Embed are inlined into the
+// page, which never references external resources.
+package report
+
+import (
+	"io"
+	"time"
+)
+
+// Test statuses, as used in Test.Status
+const (
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+	StatusNotRun  = "not run" // Cut off by the run's time budget
+)
+
+// Renderer writes a report in some format
+type Renderer interface {
+	Render(w io.Writer, report *Report) error
+}
+
+// Report is the data of one test run
+type Report struct {
+	Title    string
+	Started  time.Time
+	Duration time.Duration
+
+	Passed  int
+	Failed  int
+	Skipped int
+	NotRun  int
+
+	// Metadata describes the environment of the run, in display order
+	Metadata []Field
+
+	// Clusters group failed tests sharing an error signature, largest first
+	Clusters []Cluster
+
+	// Timeline is drawn above the packages when set
+	Timeline *Timeline
+
+	Packages []*Package
+}
+
+// Timeline is when each package built and ran its tests, for reports that
+// must stand alone without a separate trace file
+type Timeline struct {
+	Duration time.Duration // Of the whole timeline, which starts at zero
+	Tracks   []*Track
+
+	// Trace is the same timeline as Chrome trace-event JSON, offered as a
+	// download for Perfetto or chrome://tracing; nil for none
+	Trace []byte
+}
+
+// Track is one row of the timeline: a package, or one of the extra rows of
+// a package's overlapping parallel tests
+type Track struct {
+	Name  string
+	Spans []Span
+}
+
+// Span is one bar of a track
+type Span struct {
+	Name   string
+	Kind   string // SpanBuild, SpanTest, or SpanPackage
+	Status string // Test status for SpanTest
+	Start  time.Duration
+	Length time.Duration
+}
+
+// Span kinds, as used in Span.Kind
+const (
+	SpanPackage = "package"
+	SpanBuild   = "build" // Building and starting the test binary
+	SpanTest    = "test"
+)
+
+// Cluster is a group of failed tests sharing an error signature
+type Cluster struct {
+	Summary string // e.g. "12 tests failing with connection refused to :8083"
+	Hint    string // How to check the likely missing dependency, if any
+	Tests   []string
+}
+
+// Field is a labelled value shown with the report, e.g. the git commit
+type Field struct {
+	Label string
+	Value string
+}
+
+// Package is the results of one package or external suite
+type Package struct {
+	Name     string
+	Duration time.Duration
+	Tests    []*Test
+}
+
+// Test is the result of one test
+type Test struct {
+	Name     string
+	Status   string // StatusPassed, StatusFailed, StatusSkipped, or StatusNotRun
+	Duration time.Duration
+	File     string
+	Line     int
+	Error    string
+	Output   string
+}
+
+// Status returns StatusFailed if any test failed, otherwise StatusPassed
+func (r *Report) Status() string {
+	if r.Failed > 0 {
+		return StatusFailed
+	}
+	return StatusPassed
+}
+
+// Total returns the number of tests in the report
+func (r *Report) Total() int {
+	return r.Passed + r.Failed + r.Skipped + r.NotRun
+}
+
+// FailedTests returns the failed tests of all packages
+func (r *Report) FailedTests() []*Test {
+	var failed []*Test
+	for _, pkg := range r.Packages {
+		for _, test := range pkg.Tests {
+			if test.Status == StatusFailed {
+				failed = append(failed, test)
+			}
+		}
+	}
+	return failed
+}
+
+// Status returns StatusFailed if any of the package's tests failed,
+// otherwise StatusPassed
+func (p *Package) Status() string {
+	for _, test := range p.Tests {
+		if test.Status == StatusFailed {
+			return StatusFailed
+		}
+	}
+	return StatusPassed
+}
@@ -0,0 +1,292 @@
+package testicle
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/nzions/sharedgolibs/pkg/testicle/leakcheck"
+)
+
+// Reporter names accepted by Config.Reporter
+const (
+	ReporterDefault    = "default"
+	ReporterJSONStream = "json-stream"
+)
+
+// JSONStreamSchema is the json-stream format version carried by every event.
+// It only changes on incompatible changes; new fields may be added at any time.
+const JSONStreamSchema = 1
+
+// Event types emitted by the json-stream reporter
+const (
+	EventRunStart   = "run_start"
+	EventValidation = "validation"
+	EventDiscovery  = "discovery"
+	EventTestResult = "test_result"
+	EventResources  = "resources"
+	EventLeak       = "leak"
+	EventRunEnd     = "run_end"
+	EventError      = "error"
+)
+
+// streamHeader is common to every json-stream event
+type streamHeader struct {
+	Schema int       `json:"schema"`
+	Event  string    `json:"event"`
+	Time   time.Time `json:"time"`
+	RunID  int       `json:"run_id"`
+}
+
+type runStartEvent struct {
+	streamHeader
+	Version  string       `json:"version"`
+	Dir      string       `json:"dir"`
+	Metadata *RunMetadata `json:"metadata,omitempty"`
+}
+
+type validationEvent struct {
+	streamHeader
+	Success       bool           `json:"success"`
+	DurationMs    int64          `json:"duration_ms"`
+	VetErrors     []VetIssue     `json:"vet_errors,omitempty"`
+	CompileErrors []CompileError `json:"compile_errors,omitempty"`
+}
+
+type discoveryEvent struct {
+	streamHeader
+	Packages int       `json:"packages"`
+	Tests    int       `json:"tests"`
+	Subtests int       `json:"subtests"`
+	Tree     *TestTree `json:"tree"`
+}
+
+type testResultEvent struct {
+	streamHeader
+	Package    string `json:"package"`
+	Test       string `json:"test"`
+	Status     string `json:"status"` // passed, failed, skipped
+	DurationMs int64  `json:"duration_ms"`
+	File       string `json:"file,omitempty"`
+	Line       int    `json:"line,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Output     string `json:"output,omitempty"`
+
+	PeakRSSBytes uint64 `json:"peak_rss_bytes,omitempty"`
+	MemorySpike  bool   `json:"memory_spike,omitempty"`
+
+	// Diffs of failed assertions, for rendering by the client
+	Diffs []*Diff `json:"diffs,omitempty"`
+}
+
+type resourcesEvent struct {
+	streamHeader
+	*ResourceProfile
+}
+
+type leakEvent struct {
+	streamHeader
+	*leakcheck.Report
+}
+
+type runEndEvent struct {
+	streamHeader
+	Status     string `json:"status"` // passed, failed
+	Passed     int    `json:"passed"`
+	Failed     int    `json:"failed"`
+	Skipped    int    `json:"skipped"`
+	DurationMs int64  `json:"duration_ms"`
+
+	// Tests excluded by the skip list, not counted in Skipped
+	SkippedByPolicy int         `json:"skipped_by_policy"`
+	PolicySkips     []SkipEntry `json:"policy_skips,omitempty"`
+	StaleSkips      []SkipEntry `json:"stale_skips,omitempty"`
+
+	// Tests cut off by the time budget, not counted in Skipped
+	BudgetMs int64    `json:"budget_ms,omitempty"`
+	NotRun   []string `json:"not_run,omitempty"` // package/test
+
+	// Tests and packages flagged by the slow test gate
+	SlowTests []slowTestEvent `json:"slow_tests,omitempty"`
+
+	// Failed tests grouped by error signature
+	Clusters []FailureCluster `json:"clusters,omitempty"`
+}
+
+// slowTestEvent is a SlowTest in the run_end event
+type slowTestEvent struct {
+	Package    string `json:"package"`
+	Test       string `json:"test,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	LimitMs    int64  `json:"limit_ms"`
+	P95Ms      int64  `json:"p95_ms,omitempty"`
+}
+
+type errorEvent struct {
+	streamHeader
+	Message string `json:"message"`
+}
+
+// jsonStreamReporter writes one JSON object per line describing the run
+// lifecycle, for editor integrations that should not scrape go test output
+type jsonStreamReporter struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+	runID   int
+}
+
+// newJSONStreamReporter creates a reporter writing newline-delimited JSON to w
+func newJSONStreamReporter(w io.Writer) *jsonStreamReporter {
+	return &jsonStreamReporter{encoder: json.NewEncoder(w)}
+}
+
+// header builds the common event header for the current run
+func (j *jsonStreamReporter) header(event string) streamHeader {
+	return streamHeader{
+		Schema: JSONStreamSchema,
+		Event:  event,
+		Time:   time.Now().UTC(),
+		RunID:  j.runID,
+	}
+}
+
+// emit writes a single event line
+func (j *jsonStreamReporter) emit(event interface{}) {
+	// Encoding our own event structs cannot fail; write errors (closed
+	// stdout) leave nothing useful to report to
+	_ = j.encoder.Encode(event)
+}
+
+// RunStart begins a new run; run IDs increase with each re-run in daemon mode
+func (j *jsonStreamReporter) RunStart(dir string, metadata *RunMetadata) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.runID++
+	j.emit(runStartEvent{streamHeader: j.header(EventRunStart), Version: Version, Dir: dir, Metadata: metadata})
+}
+
+// Validation reports go vet and compile check results
+func (j *jsonStreamReporter) Validation(result *ValidationResult) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	event := validationEvent{
+		streamHeader: j.header(EventValidation),
+		Success:      result.Success,
+		DurationMs:   result.Duration.Milliseconds(),
+	}
+	if result.VetResult != nil {
+		event.VetErrors = result.VetResult.Errors
+	}
+	if result.CompileResult != nil {
+		event.CompileErrors = result.CompileResult.Errors
+	}
+	j.emit(event)
+}
+
+// Discovery reports the discovered test tree
+func (j *jsonStreamReporter) Discovery(tree *TestTree) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.emit(discoveryEvent{
+		streamHeader: j.header(EventDiscovery),
+		Packages:     len(tree.Packages),
+		Tests:        tree.TestCount(),
+		Subtests:     tree.SubtestCount(),
+		Tree:         tree,
+	})
+}
+
+// TestResult reports a single test outcome
+func (j *jsonStreamReporter) TestResult(result *TestResult) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.emit(testResultEvent{
+		streamHeader: j.header(EventTestResult),
+		Package:      result.Package,
+		Test:         result.Name,
+		Status:       result.Status.String(),
+		DurationMs:   result.Duration.Milliseconds(),
+		File:         result.File,
+		Line:         result.Line,
+		Error:        result.Error,
+		Output:       result.Output,
+		PeakRSSBytes: result.PeakRSSBytes,
+		MemorySpike:  result.MemorySpike,
+		Diffs:        result.Diffs,
+	})
+}
+
+// Resources reports a package's sampled resource usage
+func (j *jsonStreamReporter) Resources(profile *ResourceProfile) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.emit(resourcesEvent{streamHeader: j.header(EventResources), ResourceProfile: profile})
+}
+
+// Leak reports goroutines and ports left behind by a package or test
+func (j *jsonStreamReporter) Leak(report *leakcheck.Report) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.emit(leakEvent{streamHeader: j.header(EventLeak), Report: report})
+}
+
+// RunEnd reports the aggregate outcome of the run
+func (j *jsonStreamReporter) RunEnd(results *TestResults) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	status := TestStatusPassed
+	if results.Failed > 0 {
+		status = TestStatusFailed
+	}
+	j.emit(runEndEvent{
+		streamHeader: j.header(EventRunEnd),
+		Status:       status.String(),
+		Passed:       results.Passed,
+		Failed:       results.Failed,
+		Skipped:      results.Skipped,
+		DurationMs:   results.Duration.Milliseconds(),
+
+		SkippedByPolicy: len(results.SkippedByPolicy),
+		PolicySkips:     results.SkippedByPolicy,
+		StaleSkips:      results.StaleSkips,
+
+		BudgetMs: results.Budget.Milliseconds(),
+		NotRun:   notRunNames(results.NotRun),
+
+		SlowTests: slowTestEvents(results.SlowTests),
+		Clusters:  results.Clusters,
+	})
+}
+
+// slowTestEvents converts slow tests to their run_end form
+func slowTestEvents(slow []SlowTest) []slowTestEvent {
+	var events []slowTestEvent
+	for _, s := range slow {
+		events = append(events, slowTestEvent{
+			Package:    s.Package,
+			Test:       s.Test,
+			DurationMs: s.Duration.Milliseconds(),
+			LimitMs:    s.Limit.Milliseconds(),
+			P95Ms:      s.P95.Milliseconds(),
+		})
+	}
+	return events
+}
+
+// notRunNames names tests cut off by the budget as package/test
+func notRunNames(results []*TestResult) []string {
+	var names []string
+	for _, result := range results {
+		names = append(names, result.Package+"/"+result.Name)
+	}
+	return names
+}
+
+// Error reports a failure that ended the run early
+func (j *jsonStreamReporter) Error(format string, args ...interface{}) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.emit(errorEvent{streamHeader: j.header(EventError), Message: fmt.Sprintf(format, args...)})
+}
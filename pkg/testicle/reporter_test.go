@@ -0,0 +1,101 @@
+package testicle
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestJSONStreamReporter(t *testing.T) {
+	var buf bytes.Buffer
+	reporter := newJSONStreamReporter(&buf)
+
+	reporter.RunStart("/src", &RunMetadata{GoVersion: "go1.23.4", OS: "linux", Arch: "amd64", GitCommit: "1a2b3c4d"})
+	reporter.Discovery(&TestTree{Packages: []*PackageNode{{
+		ImportPath: "example.com/a",
+		Tests:      []*TestNode{{Name: "TestA", FullName: "TestA", Subtests: []*TestNode{{Name: "sub"}}}},
+	}}})
+	reporter.TestResult(&TestResult{
+		Name:     "TestA",
+		Package:  "a",
+		File:     "/src/a_test.go",
+		Line:     12,
+		Status:   TestStatusPassed,
+		Duration: 1500 * time.Millisecond,
+	})
+	reporter.TestResult(&TestResult{Name: "TestB", Package: "a", Status: TestStatusFailed, Error: "--- FAIL: TestB"})
+	reporter.RunEnd(&TestResults{Passed: 1, Failed: 1, Duration: 2 * time.Second})
+	reporter.RunStart("/src", nil)
+
+	var events []map[string]interface{}
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var event map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("Line is not valid JSON: %q: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+
+	wantEvents := []string{EventRunStart, EventDiscovery, EventTestResult, EventTestResult, EventRunEnd, EventRunStart}
+	if len(events) != len(wantEvents) {
+		t.Fatalf("Expected %d events, got %d", len(wantEvents), len(events))
+	}
+	for i, want := range wantEvents {
+		if events[i]["event"] != want {
+			t.Errorf("Event %d: expected %s, got %v", i, want, events[i]["event"])
+		}
+		if events[i]["schema"] != float64(JSONStreamSchema) {
+			t.Errorf("Event %d: expected schema %d, got %v", i, JSONStreamSchema, events[i]["schema"])
+		}
+	}
+
+	if events[0]["version"] != Version || events[0]["run_id"] != float64(1) {
+		t.Errorf("Unexpected run_start: %v", events[0])
+	}
+	if metadata, _ := events[0]["metadata"].(map[string]interface{}); metadata["go_version"] != "go1.23.4" || metadata["git_commit"] != "1a2b3c4d" {
+		t.Errorf("Unexpected run_start metadata: %v", events[0]["metadata"])
+	}
+	if _, ok := events[5]["metadata"]; ok {
+		t.Errorf("Expected no metadata without a collected environment, got %v", events[5]["metadata"])
+	}
+	if events[1]["tests"] != float64(1) || events[1]["subtests"] != float64(1) {
+		t.Errorf("Unexpected discovery counts: %v", events[1])
+	}
+
+	passed := events[2]
+	if passed["test"] != "TestA" || passed["status"] != "passed" || passed["duration_ms"] != float64(1500) ||
+		passed["file"] != "/src/a_test.go" || passed["line"] != float64(12) {
+		t.Errorf("Unexpected passed test_result: %v", passed)
+	}
+	if failed := events[3]; failed["status"] != "failed" || failed["error"] != "--- FAIL: TestB" {
+		t.Errorf("Unexpected failed test_result: %v", failed)
+	}
+
+	end := events[4]
+	if end["status"] != "failed" || end["passed"] != float64(1) || end["failed"] != float64(1) || end["skipped"] != float64(0) {
+		t.Errorf("Unexpected run_end: %v", end)
+	}
+
+	// Each re-run gets a new run ID
+	if events[5]["run_id"] != float64(2) {
+		t.Errorf("Expected second run_id 2, got %v", events[5]["run_id"])
+	}
+}
+
+func TestValidateConfigReporter(t *testing.T) {
+	config := &Config{Dir: t.TempDir()}
+	if err := validateConfig(config); err != nil {
+		t.Fatalf("validateConfig failed: %v", err)
+	}
+	if config.Reporter != ReporterDefault {
+		t.Errorf("Expected default reporter, got %q", config.Reporter)
+	}
+
+	config = &Config{Dir: t.TempDir(), Reporter: "xml"}
+	if err := validateConfig(config); err == nil {
+		t.Error("Expected error for unknown reporter")
+	}
+}
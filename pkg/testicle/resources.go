@@ -0,0 +1,354 @@
+package testicle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultResourceSampleInterval is how often the test process tree is sampled
+	DefaultResourceSampleInterval = 250 * time.Millisecond
+
+	// DefaultMemorySpikeThreshold is the RSS increase between two samples
+	// that counts as a memory spike
+	DefaultMemorySpikeThreshold = 64 << 20
+
+	// clockTicksPerSecond is USER_HZ, the unit of process CPU times in /proc
+	clockTicksPerSecond = 100
+)
+
+// errResourcesUnsupported is returned where process sampling is not implemented
+var errResourcesUnsupported = errors.New("resource monitoring is not supported on this platform")
+
+// ResourceSample is the combined usage of a test process tree at one instant
+type ResourceSample struct {
+	Time       time.Time `json:"time"`
+	CPUPercent float64   `json:"cpu_percent"` // 100 = one core fully busy
+	RSSBytes   uint64    `json:"rss_bytes"`
+	OpenFDs    int       `json:"open_fds"`
+	Processes  int       `json:"processes"`
+}
+
+// ResourceProfile summarizes resource usage while one package's tests ran
+type ResourceProfile struct {
+	Package      string           `json:"package"`
+	Samples      []ResourceSample `json:"samples"`
+	PeakRSSBytes uint64           `json:"peak_rss_bytes"`
+	MaxCPU       float64          `json:"max_cpu_percent"`
+	MaxOpenFDs   int              `json:"max_open_fds"`
+	SpikeTests   []string         `json:"spike_tests,omitempty"` // Tests running during a memory spike
+}
+
+// processUsage is a raw per-process reading from the platform sampler
+type processUsage struct {
+	cpuTicks uint64 // user+system time in clock ticks
+	rssBytes uint64
+	openFDs  int
+}
+
+// ResourceMonitor periodically samples a process and all of its descendants
+type ResourceMonitor struct {
+	pid      int
+	interval time.Duration
+
+	mutex    sync.Mutex
+	samples  []ResourceSample
+	err      error
+	prev     map[int]uint64
+	prevTime time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// StartResourceMonitor begins sampling the process tree rooted at pid
+func StartResourceMonitor(pid int, interval time.Duration) *ResourceMonitor {
+	if interval <= 0 {
+		interval = DefaultResourceSampleInterval
+	}
+
+	m := &ResourceMonitor{
+		pid:      pid,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go m.run()
+	return m
+}
+
+// run samples until stopped or the platform sampler fails
+func (m *ResourceMonitor) run() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.sample(); err != nil {
+			m.mutex.Lock()
+			m.err = err
+			m.mutex.Unlock()
+			return
+		}
+
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sample records one reading of the process tree
+func (m *ResourceMonitor) sample() error {
+	usage, err := sampleProcessTree(m.pid)
+	if err != nil {
+		return err
+	}
+	if len(usage) == 0 {
+		return nil // Root exited and awaits reaping
+	}
+	now := time.Now()
+
+	sample := ResourceSample{Time: now, Processes: len(usage)}
+	ticks := make(map[int]uint64, len(usage))
+	var deltaTicks uint64
+	for pid, u := range usage {
+		sample.RSSBytes += u.rssBytes
+		sample.OpenFDs += u.openFDs
+		ticks[pid] = u.cpuTicks
+
+		// Processes that exited since the last sample take their CPU time with them
+		if prev, ok := m.prev[pid]; ok && u.cpuTicks >= prev {
+			deltaTicks += u.cpuTicks - prev
+		} else if !ok && m.prev != nil {
+			deltaTicks += u.cpuTicks
+		}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.prev != nil {
+		if elapsed := now.Sub(m.prevTime).Seconds(); elapsed > 0 {
+			sample.CPUPercent = float64(deltaTicks) / clockTicksPerSecond / elapsed * 100
+		}
+	}
+	m.prev = ticks
+	m.prevTime = now
+	m.samples = append(m.samples, sample)
+	return nil
+}
+
+// Stop ends sampling and returns the samples collected. The error is set
+// when the platform does not support sampling.
+func (m *ResourceMonitor) Stop() ([]ResourceSample, error) {
+	select {
+	case <-m.stop:
+	default:
+		close(m.stop)
+	}
+	<-m.done
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.samples, m.err
+}
+
+// newResourceProfile summarizes samples for a package and flags the tests
+// whose execution window contains a memory spike
+func newResourceProfile(pkg string, samples []ResourceSample, windows map[string]testWindow, threshold uint64) *ResourceProfile {
+	profile := &ResourceProfile{Package: pkg, Samples: samples}
+	for _, s := range samples {
+		if s.RSSBytes > profile.PeakRSSBytes {
+			profile.PeakRSSBytes = s.RSSBytes
+		}
+		if s.CPUPercent > profile.MaxCPU {
+			profile.MaxCPU = s.CPUPercent
+		}
+		if s.OpenFDs > profile.MaxOpenFDs {
+			profile.MaxOpenFDs = s.OpenFDs
+		}
+	}
+
+	spikes := detectMemorySpikes(samples, threshold)
+	for name, window := range windows {
+		for _, spike := range spikes {
+			if window.contains(spike.Time) {
+				profile.SpikeTests = append(profile.SpikeTests, name)
+				break
+			}
+		}
+	}
+	sort.Strings(profile.SpikeTests)
+
+	return profile
+}
+
+// detectMemorySpikes returns samples whose RSS rose by at least threshold
+// since the previous sample
+func detectMemorySpikes(samples []ResourceSample, threshold uint64) []ResourceSample {
+	var spikes []ResourceSample
+	for i := 1; i < len(samples); i++ {
+		if samples[i].RSSBytes >= samples[i-1].RSSBytes+threshold {
+			spikes = append(spikes, samples[i])
+		}
+	}
+	return spikes
+}
+
+// peakRSSDuring returns the highest RSS sampled within window
+func peakRSSDuring(samples []ResourceSample, window testWindow) uint64 {
+	var peak uint64
+	for _, s := range samples {
+		if window.contains(s.Time) && s.RSSBytes > peak {
+			peak = s.RSSBytes
+		}
+	}
+	return peak
+}
+
+// testWindow is the wall-clock span between a test's "=== RUN" and its result line
+type testWindow struct {
+	start time.Time
+	end   time.Time
+}
+
+// contains reports whether t falls inside the window; an unfinished window
+// (test still running or crashed) is open-ended
+func (w testWindow) contains(t time.Time) bool {
+	if t.Before(w.start) {
+		return false
+	}
+	return w.end.IsZero() || !t.After(w.end)
+}
+
+// testTimeline is an io.Writer that timestamps top-level test start and end
+// lines as go test -v output streams through it
+type testTimeline struct {
+	mutex   sync.Mutex
+	partial []byte
+	windows map[string]testWindow
+}
+
+// newTestTimeline creates an empty timeline
+func newTestTimeline() *testTimeline {
+	return &testTimeline{windows: make(map[string]testWindow)}
+}
+
+// Write records timestamps for complete lines in p
+func (tl *testTimeline) Write(p []byte) (int, error) {
+	tl.mutex.Lock()
+	defer tl.mutex.Unlock()
+
+	now := time.Now()
+	tl.partial = append(tl.partial, p...)
+	for {
+		idx := bytes.IndexByte(tl.partial, '\n')
+		if idx < 0 {
+			break
+		}
+		tl.record(string(tl.partial[:idx]), now)
+		tl.partial = tl.partial[idx+1:]
+	}
+	return len(p), nil
+}
+
+// record updates the window of a top-level test named on line
+func (tl *testTimeline) record(line string, now time.Time) {
+	switch {
+	case strings.HasPrefix(line, "=== RUN"):
+		name := strings.TrimSpace(strings.TrimPrefix(line, "=== RUN"))
+		if name != "" && !strings.Contains(name, "/") {
+			if _, seen := tl.windows[name]; !seen {
+				tl.windows[name] = testWindow{start: now}
+			}
+		}
+	case strings.HasPrefix(line, "--- PASS:"), strings.HasPrefix(line, "--- FAIL:"), strings.HasPrefix(line, "--- SKIP:"):
+		fields := strings.Fields(line)
+		if len(fields) >= 3 {
+			if window, ok := tl.windows[fields[2]]; ok {
+				window.end = now
+				tl.windows[fields[2]] = window
+			}
+		}
+	}
+}
+
+// Windows returns a copy of the recorded test windows
+func (tl *testTimeline) Windows() map[string]testWindow {
+	tl.mutex.Lock()
+	defer tl.mutex.Unlock()
+	windows := make(map[string]testWindow, len(tl.windows))
+	for name, window := range tl.windows {
+		windows[name] = window
+	}
+	return windows
+}
+
+// sparkline renders values as a row of block characters scaled to the maximum,
+// keeping at most width points
+func sparkline(values []float64, width int) string {
+	if len(values) == 0 || width <= 0 {
+		return ""
+	}
+
+	// Downsample by taking the maximum of each bucket so spikes stay visible
+	if len(values) > width {
+		buckets := make([]float64, width)
+		for i, v := range values {
+			b := i * width / len(values)
+			if v > buckets[b] {
+				buckets[b] = v
+			}
+		}
+		values = buckets
+	}
+
+	max := 0.0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+
+	blocks := []rune("▁▂▃▄▅▆▇█")
+	var b strings.Builder
+	for _, v := range values {
+		idx := 0
+		if max > 0 {
+			idx = int(v / max * float64(len(blocks)-1))
+		}
+		b.WriteRune(blocks[idx])
+	}
+	return b.String()
+}
+
+// formatBytes renders a byte count with a binary unit
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// String renders a one-line summary with a memory sparkline
+func (p *ResourceProfile) String() string {
+	rss := make([]float64, len(p.Samples))
+	for i, s := range p.Samples {
+		rss[i] = float64(s.RSSBytes)
+	}
+	return fmt.Sprintf("mem %s peak %s • cpu max %.0f%% • fds max %d",
+		sparkline(rss, 24), formatBytes(p.PeakRSSBytes), p.MaxCPU, p.MaxOpenFDs)
+}
@@ -0,0 +1,102 @@
+//go:build linux
+
+package testicle
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// sampleProcessTree reads /proc for root and all of its descendants
+func sampleProcessTree(root int) (map[int]processUsage, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	// Build the parent -> children map from every process's stat line
+	children := make(map[int][]int)
+	ticks := make(map[int]uint64)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		ppid, cpu, ok := readProcStat(pid)
+		if !ok {
+			continue // Exited while scanning
+		}
+		children[ppid] = append(children[ppid], pid)
+		ticks[pid] = cpu
+	}
+
+	usage := make(map[int]processUsage)
+	queue := []int{root}
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+
+		cpu, ok := ticks[pid]
+		if !ok {
+			continue
+		}
+		usage[pid] = processUsage{
+			cpuTicks: cpu,
+			rssBytes: readProcRSS(pid),
+			openFDs:  countProcFDs(pid),
+		}
+		queue = append(queue, children[pid]...)
+	}
+
+	return usage, nil
+}
+
+// readProcStat returns the parent PID and user+system CPU ticks of pid
+func readProcStat(pid int) (int, uint64, bool) {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, 0, false
+	}
+
+	// The command name is parenthesized and may contain spaces
+	end := bytes.LastIndexByte(data, ')')
+	if end < 0 {
+		return 0, 0, false
+	}
+	// Fields after the name start at field 3 (state)
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 13 {
+		return 0, 0, false
+	}
+
+	ppid, _ := strconv.Atoi(fields[1])
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	return ppid, utime + stime, true
+}
+
+// readProcRSS returns the resident set size of pid in bytes
+func readProcRSS(pid int) uint64 {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "statm"))
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0
+	}
+	pages, _ := strconv.ParseUint(fields[1], 10, 64)
+	return pages * uint64(os.Getpagesize())
+}
+
+// countProcFDs returns the number of open file descriptors of pid
+func countProcFDs(pid int) int {
+	entries, err := os.ReadDir(filepath.Join("/proc", strconv.Itoa(pid), "fd"))
+	if err != nil {
+		return 0
+	}
+	return len(entries)
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package testicle
+
+// sampleProcessTree is not implemented outside Linux
+func sampleProcessTree(root int) (map[int]processUsage, error) {
+	return nil, errResourcesUnsupported
+}
@@ -0,0 +1,98 @@
+package testicle
+
+import (
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestResourceProfileFlagsSpikeTests(t *testing.T) {
+	base := time.Now()
+	at := func(ms int) time.Time { return base.Add(time.Duration(ms) * time.Millisecond) }
+
+	samples := []ResourceSample{
+		{Time: at(0), RSSBytes: 10 << 20, CPUPercent: 50, OpenFDs: 8},
+		{Time: at(250), RSSBytes: 12 << 20, CPUPercent: 120, OpenFDs: 9},
+		{Time: at(500), RSSBytes: 100 << 20, CPUPercent: 90, OpenFDs: 12},
+		{Time: at(750), RSSBytes: 20 << 20, CPUPercent: 10, OpenFDs: 7},
+	}
+	windows := map[string]testWindow{
+		"TestSmall":   {start: at(0), end: at(300)},
+		"TestLarge":   {start: at(300), end: at(600)},
+		"TestCrashed": {start: at(450)}, // No result line: open-ended
+		"TestLater":   {start: at(700), end: at(800)},
+	}
+
+	profile := newResourceProfile("example.com/pkg", samples, windows, DefaultMemorySpikeThreshold)
+	if profile.PeakRSSBytes != 100<<20 || profile.MaxCPU != 120 || profile.MaxOpenFDs != 12 {
+		t.Errorf("Unexpected peaks: %+v", profile)
+	}
+	if len(profile.SpikeTests) != 2 || profile.SpikeTests[0] != "TestCrashed" || profile.SpikeTests[1] != "TestLarge" {
+		t.Errorf("Expected TestCrashed and TestLarge flagged, got %v", profile.SpikeTests)
+	}
+
+	if peak := peakRSSDuring(samples, windows["TestSmall"]); peak != 12<<20 {
+		t.Errorf("Expected TestSmall peak 12MiB, got %s", formatBytes(peak))
+	}
+}
+
+func TestTestTimeline(t *testing.T) {
+	timeline := newTestTimeline()
+	timeline.Write([]byte("=== RUN   TestA\n=== RUN   TestA/sub\n--- PASS: Test"))
+	timeline.Write([]byte("A (0.01s)\n=== RUN   TestB\n"))
+
+	windows := timeline.Windows()
+	if len(windows) != 2 {
+		t.Fatalf("Expected 2 top-level windows, got %v", windows)
+	}
+	if windows["TestA"].end.IsZero() {
+		t.Error("Expected TestA to be finished across split writes")
+	}
+	if !windows["TestB"].end.IsZero() {
+		t.Error("Expected TestB to still be running")
+	}
+}
+
+func TestSparklineAndFormatBytes(t *testing.T) {
+	if got := sparkline([]float64{0, 1, 2, 4}, 10); got != "▁▂▄█" {
+		t.Errorf("Unexpected sparkline %q", got)
+	}
+	// Downsampling keeps the maximum of each bucket
+	if got := sparkline([]float64{0, 0, 0, 8, 0, 0}, 3); got != "▁█▁" {
+		t.Errorf("Unexpected downsampled sparkline %q", got)
+	}
+	if sparkline(nil, 10) != "" {
+		t.Error("Expected empty sparkline for no values")
+	}
+
+	for n, want := range map[uint64]string{512: "512B", 1536: "1.5KiB", 64 << 20: "64.0MiB"} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
+
+func TestResourceMonitorSamplesSelf(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("resource sampling requires /proc")
+	}
+
+	monitor := StartResourceMonitor(os.Getpid(), 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	samples, err := monitor.Stop()
+	if err != nil {
+		t.Fatalf("Stop returned error: %v", err)
+	}
+	if len(samples) < 2 {
+		t.Fatalf("Expected several samples, got %d", len(samples))
+	}
+	if samples[0].RSSBytes == 0 || samples[0].OpenFDs == 0 || samples[0].Processes < 1 {
+		t.Errorf("Expected non-zero usage, got %+v", samples[0])
+	}
+
+	// Stopping twice is harmless
+	if _, err := monitor.Stop(); err != nil {
+		t.Errorf("Second Stop returned error: %v", err)
+	}
+}
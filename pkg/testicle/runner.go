@@ -2,6 +2,7 @@ package testicle
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,6 +10,10 @@ import (
 	"time"
 
 	"github.com/mattn/go-runewidth"
+	"golang.org/x/term"
+
+	"github.com/nzions/sharedgolibs/pkg/testicle/leakcheck"
+	"github.com/nzions/sharedgolibs/pkg/testicle/report"
 )
 
 // Config holds the configuration for the testicle runner
@@ -22,6 +27,106 @@ type Config struct {
 	NoVet        bool `yaml:"no_vet"`
 	NoBuildCheck bool `yaml:"no_build_check"`
 	Validate     bool `yaml:"validate"`
+
+	// Reporter selects the output format: ReporterDefault or ReporterJSONStream
+	Reporter string `yaml:"reporter"`
+
+	// Diff lays out expected/actual diffs of failed assertions in the
+	// console: DiffInline (default) or DiffSideBySide
+	Diff string `yaml:"diff"`
+
+	// Suites are non-Go test suites run after the Go tests. When empty they
+	// are read from the config file.
+	Suites []SuiteConfig `yaml:"suites"`
+
+	// MonitorResources samples CPU, memory, and open files of each test
+	// process tree and flags tests that coincide with memory spikes (Linux)
+	MonitorResources bool `yaml:"monitor_resources"`
+
+	// LeakCheck reports ports left listening by processes each package's
+	// tests started (Linux). Packages using the leakcheck harness report
+	// goroutine leaks regardless.
+	LeakCheck bool `yaml:"leak_check"`
+
+	// Isolate runs each package with its own temp directory and
+	// IsolatePorts ports (default: DefaultIsolationPorts) leased through
+	// servicemanager, exposed as TESTICLE_TMP and TESTICLE_PORT_0..n-1
+	Isolate      bool `yaml:"isolate"`
+	IsolatePorts int  `yaml:"isolate_ports"`
+
+	// CI runs once without the interactive UI, validates with go vet and a
+	// test build first (unless NoVet/NoBuildCheck), and writes GitHub Actions
+	// annotations for failures to stdout. Run's error maps to a distinct
+	// exit code with ExitCode.
+	CI bool `yaml:"ci"`
+
+	// WarmBuild pre-builds each package's test binary (go test -c), caches
+	// it keyed by a hash of its sources in CacheDir, and re-executes it
+	// while the sources are unchanged, cutting re-run latency in watch mode
+	WarmBuild bool   `yaml:"warm_build"`
+	CacheDir  string `yaml:"cache_dir"` // Default: DefaultBuildCacheDir()
+
+	// SkipListFile lists tests skipped by policy, excluded from every run
+	// and reported separately. Default: DefaultSkipListFile.
+	SkipListFile string `yaml:"skip_list"`
+
+	// Watch selects the file changes that re-run tests in daemon mode
+	Watch WatchConfig `yaml:"watch"`
+
+	// ArtifactsDir keeps a json-stream report of every run (results,
+	// resource profiles, and leaks) in a directory per run, pruned after
+	// each run by Retention. Empty disables run artifacts.
+	ArtifactsDir string          `yaml:"artifacts_dir"`
+	Retention    RetentionConfig `yaml:"retention"`
+
+	// ReportRenderer renders the HTML report saved with each run's
+	// artifacts and HTMLReportFile, for Go programs embedding testicle to
+	// brand it. Default: a report.HTML with the default theme.
+	ReportRenderer report.Renderer `yaml:"-"`
+
+	// Filter selects the tests to run with a filter expression, e.g.
+	// `pkg:./pkg/ca test:~Transport -test:~Legacy` (see Filter)
+	Filter string `yaml:"filter"`
+
+	// MetadataEnv names environment variables recorded in each run's
+	// metadata in addition to DefaultMetadataEnv (see RunMetadata)
+	MetadataEnv []string `yaml:"metadata_env"`
+
+	// TraceFile writes each run's timeline (package builds and test spans)
+	// as Chrome trace-event JSON, for Perfetto or chrome://tracing.
+	// Relative paths are relative to the working directory.
+	TraceFile string `yaml:"trace_file"`
+
+	// HTMLReportFile writes each run's HTML report as a single
+	// self-contained file with its timeline and trace embedded (see
+	// NewSingleFileReport), for a CI artifact or an email. Relative paths
+	// are relative to the working directory.
+	HTMLReportFile string `yaml:"html_report"`
+
+	// Profile selects a profile from the config file's profiles (see
+	// ProfileConfig). Budget caps the total time of each run, overriding
+	// the profile's budget; packages are then ordered by the durations
+	// recorded in DurationHistoryFile (default: DefaultDurationHistoryFile).
+	Profile             string        `yaml:"profile"`
+	Budget              time.Duration `yaml:"budget"`
+	DurationHistoryFile string        `yaml:"duration_history_file"`
+
+	// SlowTests flags tests over a duration threshold and packages whose
+	// duration regressed against DurationHistoryFile. When empty it is read
+	// from the config file.
+	SlowTests SlowTestConfig `yaml:"slow_tests"`
+
+	// GoTestArgs are extra go test arguments for every package, such as
+	// -race or -tags=integration; Packages adds arguments per package (see
+	// PackageConfig). Both are recorded in the run metadata. When empty
+	// they are read from the config file.
+	GoTestArgs []string                 `yaml:"go_test_args"`
+	Packages   map[string]PackageConfig `yaml:"packages"`
+
+	// OTel exports each run as OpenTelemetry spans to a collector (see
+	// OTelConfig). When its endpoint is empty it is read from the config
+	// file.
+	OTel OTelConfig `yaml:"otel"`
 }
 
 // Runner is the main testicle test runner
@@ -33,6 +138,18 @@ type Runner struct {
 	logger       *Logger
 	uiController *UIController
 	validator    *ValidationPipeline
+	tree         *TestTree    // Most recent discovery result
+	lastResults  *TestResults // Most recent run, for marking failures skipped
+	reporter     *jsonStreamReporter
+	ci           *ciReporter
+	adapters     []SuiteAdapter
+	artifacts    *ArtifactStore   // nil unless ArtifactsDir is set
+	filter       *Filter          // nil selects every test
+	metadata     *RunMetadata     // Environment of the current run
+	history      *DurationHistory // nil unless budgeted or checked for slow tests
+	slowTests    *slowTestGate    // nil without slow test checks
+	clusterRuns  map[string]int   // Consecutive failing runs by cluster signature
+	htmlReport   report.Renderer  // nil unless HTMLReportFile is set
 }
 
 // NewRunner creates a new testicle runner with the given configuration
@@ -46,8 +163,25 @@ func NewRunner(config *Config) (*Runner, error) {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
-	// Initialize logger
+	if err := applyConfigFile(config); err != nil {
+		return nil, err
+	}
+	if err := validateCIConfig(config); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	adapters, err := newSuiteAdapters(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid suite configuration: %w", err)
+	}
+
+	// Initialize logger; machine-readable output owns stdout
+	var reporter *jsonStreamReporter
 	logger := NewLogger(config.Debug)
+	if config.Reporter == ReporterJSONStream {
+		reporter = newJSONStreamReporter(os.Stdout)
+		logger = NewLoggerWithWriter(config.Debug, os.Stderr)
+	}
 
 	// Initialize components
 	discovery := NewDiscovery(config.Dir, logger)
@@ -55,7 +189,7 @@ func NewRunner(config *Config) (*Runner, error) {
 
 	// Initialize validation pipeline if needed
 	var validator *ValidationPipeline
-	if config.Validate {
+	if config.Validate || config.CI {
 		validationConfig := &ValidationConfig{
 			RunVet:                   !config.NoVet,
 			CompileCheck:             !config.NoBuildCheck,
@@ -63,7 +197,7 @@ func NewRunner(config *Config) (*Runner, error) {
 			CompileTimeout:           "30s",
 			ContinueOnVetErrors:      false,
 			ContinueOnCompileErrors:  false,
-			InteractiveErrorHandling: true, // Enable interactive mode for validation errors
+			InteractiveErrorHandling: !config.CI, // Enable interactive mode for validation errors
 		}
 		validator = NewValidationPipeline(validationConfig, logger)
 	}
@@ -72,7 +206,19 @@ func NewRunner(config *Config) (*Runner, error) {
 	var uiController *UIController
 	if config.Daemon {
 		watcher = NewWatcher(config.Dir, logger)
-		uiController = NewUIController(nil, logger) // Will set runner reference after creation
+		if err := watcher.Configure(config.Watch); err != nil {
+			return nil, fmt.Errorf("invalid configuration: %w", err)
+		}
+		// Edits to go.work and sibling modules re-run tests too
+		if workspace, err := FindWorkspace(config.Dir); err == nil && workspace != nil {
+			watcher.AddRoots(workspace.Dir())
+			for _, module := range workspace.Modules {
+				watcher.AddRoots(module.Dir)
+			}
+		}
+		if reporter == nil {
+			uiController = NewUIController(nil, logger) // Will set runner reference after creation
+		}
 	}
 
 	runner := &Runner{
@@ -83,6 +229,77 @@ func NewRunner(config *Config) (*Runner, error) {
 		watcher:      watcher,
 		logger:       logger,
 		uiController: uiController,
+		reporter:     reporter,
+		adapters:     adapters,
+	}
+
+	if config.CI {
+		runner.ci = newCIReporter(os.Stdout)
+	}
+
+	if config.ArtifactsDir != "" {
+		runner.artifacts, err = NewArtifactStore(artifactsPath(config), config.Retention)
+		if err != nil {
+			return nil, fmt.Errorf("invalid configuration: %w", err)
+		}
+		if config.ReportRenderer != nil {
+			runner.artifacts.SetRenderer(config.ReportRenderer)
+		}
+	}
+
+	if config.HTMLReportFile != "" {
+		runner.htmlReport = config.ReportRenderer
+		if runner.htmlReport == nil {
+			if runner.htmlReport, err = report.NewHTML(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	runner.filter, err = ParseFilter(config.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	if err := validateTestArgs(config); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	if err := config.OTel.validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	if config.MonitorResources {
+		runner.executor.EnableResourceMonitoring(DefaultResourceSampleInterval, DefaultMemorySpikeThreshold)
+		runner.executor.SetResourceCallback(runner.reportResources)
+	}
+
+	if config.LeakCheck {
+		runner.executor.EnableLeakCheck()
+	}
+
+	if config.Isolate {
+		runner.executor.EnableIsolation(config.IsolatePorts, nil)
+	}
+
+	runner.slowTests, err = newSlowTestGate(config.SlowTests)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	if config.Budget > 0 || (runner.slowTests != nil && runner.slowTests.p95Regression > 0) {
+		runner.history = runner.loadDurationHistory()
+	}
+	if config.Budget > 0 {
+		runner.executor.SetBudget(config.Budget, runner.history)
+	}
+
+	runner.executor.SetDiffStyle(consoleDiffStyle(config))
+
+	if config.WarmBuild {
+		runner.executor.EnableBuildCache(NewBuildCache(config.CacheDir, logger))
+	}
+	runner.executor.SetLeakCallback(runner.reportLeak)
+
+	if reporter != nil {
+		runner.executor.SetResultCallback(reporter.TestResult)
 	}
 
 	// Set the runner reference in UI controller
@@ -129,8 +346,34 @@ func (r *Runner) Run(ctx context.Context) error {
 	}
 }
 
-// runOnce executes all tests once
+// runOnce executes all tests once, reporting the run lifecycle when a
+// machine-readable reporter is configured
 func (r *Runner) runOnce(ctx context.Context) error {
+	// The environment is captured per run; the git state changes between
+	// re-runs in daemon mode
+	r.metadata = CollectRunMetadata(ctx, r.config.Dir, r.config.MetadataEnv)
+	r.metadata.GoTestArgs = r.config.GoTestArgs
+	r.metadata.PackageGoTestArgs = packageGoTestArgs(r.config.Packages)
+	if r.uiController != nil {
+		r.uiController.status.Metadata = r.metadata
+	}
+	if r.reporter == nil {
+		if r.uiController == nil || !r.uiController.isActive {
+			r.logger.Info("🧬 Environment: %s", r.metadata)
+		}
+		return r.runTests(ctx)
+	}
+
+	r.reporter.RunStart(r.config.Dir, r.metadata)
+	err := r.runTests(ctx)
+	if err != nil && !errors.Is(err, ErrTestsFailed) {
+		r.reporter.Error("%v", err)
+	}
+	return err
+}
+
+// runTests validates, discovers, and executes all tests
+func (r *Runner) runTests(ctx context.Context) error {
 	// Run validation if enabled
 	if r.validator != nil {
 		if r.uiController != nil && r.uiController.isActive {
@@ -141,7 +384,13 @@ func (r *Runner) runOnce(ctx context.Context) error {
 			r.logger.Info("Running validation...")
 		}
 
-		validationResult, err := r.validator.Validate(ctx, []string{r.config.Dir})
+		validationResult, err := r.validator.Validate(ctx, r.validationPackages(ctx))
+		if err == nil && r.reporter != nil {
+			r.reporter.Validation(validationResult)
+		}
+		if err == nil && r.ci != nil {
+			r.ci.Validation(validationResult)
+		}
 		if err != nil {
 			if r.uiController != nil && r.uiController.isActive {
 				r.uiController.AddLiveOutput("❌ Validation failed: " + err.Error())
@@ -188,10 +437,10 @@ func (r *Runner) runOnce(ctx context.Context) error {
 
 			// If we're configured to stop on validation errors, return early
 			if !r.validator.config.ContinueOnVetErrors && validationResult.VetResult != nil && len(validationResult.VetResult.Errors) > 0 {
-				return fmt.Errorf("go vet found issues")
+				return ErrVetFailed
 			}
 			if !r.validator.config.ContinueOnCompileErrors && validationResult.CompileResult != nil && len(validationResult.CompileResult.Errors) > 0 {
-				return fmt.Errorf("compilation failed")
+				return ErrBuildFailed
 			}
 		} else {
 			if r.uiController != nil && r.uiController.isActive {
@@ -217,34 +466,247 @@ func (r *Runner) runOnce(ctx context.Context) error {
 	}
 
 	// Discover tests
-	tests, err := r.discovery.DiscoverTests(ctx)
+	tree, err := r.Discover(ctx)
 	if err != nil {
 		return fmt.Errorf("test discovery failed: %w", err)
 	}
+	r.tree = tree
+	if r.reporter != nil {
+		r.reporter.Discovery(tree)
+	}
+
+	// Tests skipped by policy are left out of the run entirely
+	runTree, policySkipped, staleSkips, err := r.applySkipList(tree)
+	if err != nil {
+		return err
+	}
+	runTree = r.applyFilter(runTree)
+	r.executor.SetGoTestArgs(goTestArgs(tree, r.config.GoTestArgs, r.config.Packages))
+	tests := runTree.Tests()
+	found := fmt.Sprintf("%d test(s)", len(tests))
+	if len(policySkipped) > 0 {
+		found += fmt.Sprintf(" (%d skipped by policy)", len(policySkipped))
+	}
+	if r.filter != nil {
+		found += fmt.Sprintf(" matching %q", r.filter)
+	}
 
 	if r.uiController != nil && r.uiController.isActive {
-		r.uiController.AddLiveOutput(fmt.Sprintf("🔍 Found %d test(s)", len(tests)))
+		r.uiController.AddLiveOutput(fmt.Sprintf("🔍 Found %s in %d package(s)", found, len(tree.Packages)))
 		// Clear previous test results and set total count
 		r.uiController.testResults = make([]*TestResultLine, 0)
 		r.uiController.status.TestCount = len(tests)
 		r.uiController.status.State = "running"
 		r.uiController.renderFullScreen()
 	} else {
-		r.logger.Info("🔍 Found %d test(s) in %s", len(tests), r.config.Dir)
+		r.logger.Info("🔍 Found %s in %d package(s) under %s", found, len(tree.Packages), r.config.Dir)
 	}
 
 	// Execute tests
+	started := time.Now()
 	results, err := r.executor.ExecuteTests(ctx, tests)
 	if err != nil {
 		return fmt.Errorf("test execution failed: %w", err)
 	}
+	results.SkippedByPolicy = policySkipped
+	results.StaleSkips = staleSkips
+	results.Metadata = r.metadata
+
+	// Regressions are judged against the history before this run joins it
+	if r.slowTests != nil {
+		results.SlowTests = r.slowTests.Check(results, r.history)
+	}
+	r.recordDurations(results)
+
+	// Non-Go suites from testicle.yaml share the same results
+	if len(r.adapters) > 0 && len(results.NotRun) > 0 {
+		r.logger.Warn("⏱️  %d external suite(s) not run (budget exceeded)", len(r.adapters))
+	} else if len(r.adapters) > 0 {
+		if r.uiController != nil && r.uiController.isActive {
+			r.uiController.AddLiveOutput(fmt.Sprintf("🧩 Running %d external suite(s)...", len(r.adapters)))
+		} else {
+			r.logger.Info("🧩 Running %d external suite(s)...", len(r.adapters))
+		}
+		r.executor.ExecuteSuites(ctx, r.adapters, results)
+	}
+
+	results.Clusters = clusterFailures(results)
+	r.trackClusters(results.Clusters)
 
 	// Print results summary
+	r.lastResults = results
+	r.saveArtifacts(results, started)
+	if r.config.TraceFile != "" {
+		if err := writeChromeTraceFile(r.config.TraceFile, results); err != nil {
+			r.logger.Warn("Saving run trace failed: %v", err)
+		}
+	}
+	if r.config.OTel.Endpoint != "" {
+		if traceID, err := ExportOTLPTrace(ctx, r.config.OTel, results, r.config.Profile, started); err != nil {
+			r.logger.Warn("Exporting OpenTelemetry trace failed: %v", err)
+		} else {
+			r.logger.Debug("Exported OpenTelemetry trace %s", traceID)
+		}
+	}
+	if r.htmlReport != nil {
+		title := "Test run " + started.Format("2006-01-02 15:04:05")
+		if err := writeSingleFileReport(r.config.HTMLReportFile, r.htmlReport, results, title); err != nil {
+			r.logger.Warn("Saving HTML report failed: %v", err)
+		}
+	}
 	r.printSummary(results)
+	if r.ci != nil {
+		r.ci.RunEnd(results)
+	}
 
+	if results.Failed > 0 && !r.config.Daemon {
+		return fmt.Errorf("%w: %d of %d", ErrTestsFailed, results.Failed, results.Passed+results.Failed+results.Skipped)
+	}
+	if len(results.SlowTests) > 0 && r.slowTests.fail && !r.config.Daemon {
+		return fmt.Errorf("%w: %d over the limit", ErrSlowTests, len(results.SlowTests))
+	}
 	return nil
 }
 
+// saveArtifacts keeps the run's report and prunes old runs. Failures are
+// logged rather than failing the run.
+func (r *Runner) saveArtifacts(results *TestResults, started time.Time) {
+	if r.artifacts == nil {
+		return
+	}
+	if _, err := r.artifacts.Save(results, started); err != nil {
+		r.logger.Warn("Saving run artifacts failed: %v", err)
+		return
+	}
+	removed, err := r.artifacts.Prune(time.Now())
+	if err != nil {
+		r.logger.Warn("Pruning run artifacts failed: %v", err)
+	} else if len(removed) > 0 {
+		r.logger.Debug("🧹 Removed %d run(s) past the retention policy", len(removed))
+	}
+
+	if r.uiController != nil {
+		runs, size, _ := r.artifacts.Usage()
+		r.uiController.status.ArtifactRuns = runs
+		r.uiController.status.ArtifactBytes = size
+	}
+}
+
+// loadDurationHistory loads the package durations ordering budgeted runs
+// and judging slow test regressions. Without a readable history, packages
+// run in directory order and no package has regressed.
+func (r *Runner) loadDurationHistory() *DurationHistory {
+	path := r.config.DurationHistoryFile
+	if path == "" {
+		path = DefaultDurationHistoryFile()
+	}
+	history, err := LoadDurationHistory(path)
+	if err != nil {
+		r.logger.Warn("Ignoring duration history: %v", err)
+		history = &DurationHistory{path: path, Packages: make(map[string]*PackageHistory)}
+	}
+	return history
+}
+
+// recordDurations saves the package durations of a run for ordering and
+// checking the next one. Failures are logged rather than failing the run.
+func (r *Runner) recordDurations(results *TestResults) {
+	history := r.history
+	if history == nil {
+		return
+	}
+	history.Record(results)
+	if err := history.Save(); err != nil {
+		r.logger.Warn("Saving duration history failed: %v", err)
+	}
+}
+
+// applySkipList loads the skip list and removes its tests from tree. The
+// executor is told to skip them too, since packages run as a whole.
+func (r *Runner) applySkipList(tree *TestTree) (*TestTree, []SkipEntry, []SkipEntry, error) {
+	list, err := LoadSkipList(skipListPath(r.config))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	filtered, skipped, stale := list.Apply(tree)
+	r.executor.SetSkipPatterns(skipPatterns(tree, skipped))
+	return filtered, skipped, stale, nil
+}
+
+// applyFilter narrows tree to the tests selected by the filter and tells
+// the executor to run only those, since packages run as a whole
+func (r *Runner) applyFilter(tree *TestTree) *TestTree {
+	filtered, patterns := r.filter.Apply(tree)
+	r.executor.SetRunPatterns(patterns)
+	return filtered
+}
+
+// validationPackages returns the directories of the packages with tests, so
+// vet and the build check cover the same packages the tests run in. It falls
+// back to the test directory itself.
+func (r *Runner) validationPackages(ctx context.Context) []string {
+	tree, err := r.Discover(ctx)
+	if err != nil || len(tree.Packages) == 0 {
+		return []string{r.config.Dir}
+	}
+
+	dirs := make([]string, len(tree.Packages))
+	for i, pkg := range tree.Packages {
+		dirs[i] = pkg.Dir
+	}
+	return dirs
+}
+
+// reportResources shows a package's resource usage and the tests that
+// coincided with memory spikes
+func (r *Runner) reportResources(profile *ResourceProfile) {
+	if r.reporter != nil {
+		r.reporter.Resources(profile)
+		return
+	}
+
+	lines := []string{fmt.Sprintf("📈 %s: %s", filepath.Base(profile.Package), profile.String())}
+	for _, name := range profile.SpikeTests {
+		lines = append(lines, fmt.Sprintf("⚠️  %s coincided with a memory spike", name))
+	}
+
+	for _, line := range lines {
+		if r.uiController != nil && r.uiController.isActive {
+			r.uiController.AddLiveOutput(line)
+		} else {
+			r.logger.Info("%s", line)
+		}
+	}
+}
+
+// reportLeak shows goroutines and ports a package or test left behind
+func (r *Runner) reportLeak(report *leakcheck.Report) {
+	if r.reporter != nil {
+		r.reporter.Leak(report)
+		return
+	}
+
+	where := filepath.Base(report.Package)
+	if report.Test != "" {
+		where += " " + report.Test
+	}
+	lines := []string{fmt.Sprintf("🚰 %s: leaked %s", where, report.Summary())}
+	for _, g := range report.Goroutines {
+		lines = append(lines, "   "+g.String())
+	}
+	for _, p := range report.Ports {
+		lines = append(lines, "   "+p.String())
+	}
+
+	for _, line := range lines {
+		if r.uiController != nil && r.uiController.isActive {
+			r.uiController.AddLiveOutput(line)
+		} else {
+			r.logger.Warn("%s", line)
+		}
+	}
+}
+
 // runDaemon runs in watch mode, re-executing tests on file changes
 func (r *Runner) runDaemon(ctx context.Context) error {
 	// Initialize the UI controller
@@ -299,7 +761,7 @@ func (r *Runner) runDaemon(ctx context.Context) error {
 			case event := <-eventChan:
 				// In UI mode, don't log debug messages
 				if r.uiController == nil || !r.uiController.isActive {
-					r.logger.Debug("📁 File change detected: %s", event.Path)
+					r.logger.Debug("📁 File change detected: %s (%d file(s))", event.Path, len(event.Paths))
 				}
 
 				// Notify UI of file change
@@ -307,9 +769,6 @@ func (r *Runner) runDaemon(ctx context.Context) error {
 					r.uiController.OnFileChange(event.Path)
 				}
 
-				// Debounce rapid file changes
-				time.Sleep(100 * time.Millisecond)
-
 				if r.uiController != nil && r.uiController.isActive {
 					r.uiController.AddLiveOutput("🔄 Re-running tests due to file change...")
 				} else {
@@ -334,10 +793,7 @@ func (r *Runner) runDaemon(ctx context.Context) error {
 				return ctx.Err()
 
 			case event := <-eventChan:
-				r.logger.Debug("📁 File change detected: %s", event.Path)
-
-				// Debounce rapid file changes
-				time.Sleep(100 * time.Millisecond)
+				r.logger.Debug("📁 File change detected: %s (%d file(s))", event.Path, len(event.Paths))
 
 				r.logger.Info("🔄 Re-running tests due to file change...")
 				if err := r.runOnce(ctx); err != nil {
@@ -353,6 +809,24 @@ func (r *Runner) printSummary(results *TestResults) {
 	// Update UI if available
 	if r.uiController != nil {
 		r.uiController.UpdateTestResults(results)
+		for _, entry := range results.StaleSkips {
+			r.uiController.AddLiveOutput(fmt.Sprintf("⚠️  Stale skip list entry: %s.%s no longer exists", entry.Package, entry.Test))
+		}
+		for _, slow := range results.SlowTests {
+			r.uiController.AddLiveOutput(fmt.Sprintf("🐢 %s", slow))
+		}
+		for _, cluster := range results.Clusters {
+			r.uiController.AddLiveOutput(fmt.Sprintf("🧩 %s", cluster))
+			if hint := cluster.DependencyHint(); hint != "" {
+				r.uiController.AddLiveOutput("   🔌 " + hint)
+			}
+		}
+		return
+	}
+
+	// Machine-readable output replaces the console summary
+	if r.reporter != nil {
+		r.reporter.RunEnd(results)
 		return
 	}
 
@@ -395,8 +869,20 @@ func (r *Runner) printSummary(results *TestResults) {
 	if results.Skipped > 0 {
 		r.logger.Info("│%s│", pad(fmt.Sprintf("  ⏭️  Skipped: %d", results.Skipped)))
 	}
+	if len(results.SkippedByPolicy) > 0 {
+		r.logger.Info("│%s│", pad(fmt.Sprintf("  🚫 Skipped by policy: %d", len(results.SkippedByPolicy))))
+	}
+	if len(results.NotRun) > 0 {
+		r.logger.Info("│%s│", pad(fmt.Sprintf("  ⌛ Not run (budget exceeded): %d", len(results.NotRun))))
+	}
 	r.logger.Info("│%s│", pad(""))
 	r.logger.Info("│%s│", pad(fmt.Sprintf("  ⏱️  Runtime: %s", results.Duration.String())))
+	if results.Budget > 0 {
+		r.logger.Info("│%s│", pad(fmt.Sprintf("  ⌛ Budget: %s", results.Budget)))
+	}
+	if len(results.SlowTests) > 0 {
+		r.logger.Info("│%s│", pad(fmt.Sprintf("  🐢 Slow: %d", len(results.SlowTests))))
+	}
 	r.logger.Info("│%s│", pad(""))
 	successRate := float64(results.Passed) / float64(total) * 100
 	successRateStr := fmt.Sprintf("%.1f%%", successRate)
@@ -410,12 +896,49 @@ func (r *Runner) printSummary(results *TestResults) {
 	r.logger.Info("│%s│", pad(""))
 	r.logger.Info("╰─────────────────────────────────────────────────╯") // 49 chars
 
+	// Policy skips are listed every run so they are followed up
+	if len(results.SkippedByPolicy) > 0 || len(results.StaleSkips) > 0 {
+		r.logger.Info("")
+		for _, entry := range results.SkippedByPolicy {
+			r.logger.Info("🚫 %s", entry)
+		}
+		for _, entry := range results.StaleSkips {
+			r.logger.Warn("Stale skip list entry: %s.%s no longer exists", entry.Package, entry.Test)
+		}
+	}
+
+	// Tests cut off by the budget, by package, so the gap is visible
+	if len(results.NotRun) > 0 {
+		r.logger.Info("")
+		for _, pkg := range notRunPackages(results.NotRun) {
+			r.logger.Info("⌛ %s: %d test(s) %s", pkg.name, pkg.count, NotRunBudgetExceeded)
+		}
+	}
+
+	// Slow tests are listed every run so creep is caught in review
+	if len(results.SlowTests) > 0 {
+		r.logger.Info("")
+		for _, slow := range results.SlowTests {
+			r.logger.Warn("🐢 %s", slow)
+		}
+	}
+
+	// Failures sharing a cause are grouped, so one missing service doesn't
+	// bury the summary under hundreds of identical failures
+	if len(results.Clusters) > 0 {
+		r.logger.Info("")
+		for _, cluster := range results.Clusters {
+			r.logger.Warn("🧩 %s", cluster)
+			r.logger.Info("   %s", clusterTestList(cluster.Tests))
+			if hint := cluster.DependencyHint(); hint != "" {
+				r.logger.Info("   🔌 %s", hint)
+			}
+		}
+	}
+
 	if results.Failed > 0 {
 		r.logger.Info("")
 		r.logger.Info("❌ %d test(s) failed", results.Failed)
-		if !r.config.Daemon {
-			os.Exit(1)
-		}
 	} else {
 		r.logger.Info("")
 		r.logger.Info("✅ All tests passed!")
@@ -424,6 +947,11 @@ func (r *Runner) printSummary(results *TestResults) {
 
 // handleKeyInput processes keyboard input for interactive daemon mode
 func (r *Runner) handleKeyInput(ctx context.Context, key rune) error {
+	// A prompt in progress takes all keys until it is answered or cancelled
+	if r.uiController != nil && r.uiController.HandlePromptKey(key) {
+		return nil
+	}
+
 	switch key {
 	case 'r', 'R':
 		// Re-run tests
@@ -450,6 +978,20 @@ func (r *Runner) handleKeyInput(ctx context.Context, key rune) error {
 		r.logger = NewLogger(r.config.Debug)
 		r.logger.Info("🔧 Debug mode: %t", r.config.Debug)
 
+	case 't', 'T':
+		// Show the discovered test tree
+		if r.uiController != nil && r.tree != nil {
+			r.uiController.ShowTree(r.tree)
+		}
+
+	case 'x', 'X':
+		// Skip the most recent failed test by policy
+		r.promptSkipLastFailure()
+
+	case 'f', 'F':
+		// Change the test filter and re-run
+		r.promptFilter(ctx)
+
 	case 's', 'S':
 		// Show detailed stats (placeholder)
 		r.logger.Info("📈 Detailed statistics coming soon...")
@@ -469,6 +1011,109 @@ func (r *Runner) handleKeyInput(ctx context.Context, key rune) error {
 	return nil
 }
 
+// promptSkipLastFailure asks for a reason and adds the most recent failed
+// Go test to the skip list, excluding it from the next run
+func (r *Runner) promptSkipLastFailure() {
+	if r.uiController == nil {
+		return
+	}
+	entry, ok := r.lastFailedTest()
+	if !ok {
+		r.uiController.AddLiveOutput("🚫 No failed test to skip")
+		return
+	}
+
+	r.uiController.Prompt(fmt.Sprintf("🚫 Reason for skipping %s (Enter to save, Esc to cancel): ", entry.Test), func(reason string) {
+		entry.Reason = strings.TrimSpace(reason)
+		entry.Owner = currentOwner()
+		path := skipListPath(r.config)
+		if err := r.addSkip(path, entry); err != nil {
+			r.uiController.AddLiveOutput(fmt.Sprintf("❌ Could not skip %s: %v", entry.Test, err))
+			return
+		}
+		r.uiController.AddLiveOutput(fmt.Sprintf("🚫 %s skipped by policy from the next run (%s)", entry.Test, path))
+	})
+}
+
+// promptFilter asks for a new filter expression and re-runs the selected
+// tests. An empty expression clears the filter.
+func (r *Runner) promptFilter(ctx context.Context) {
+	if r.uiController == nil {
+		return
+	}
+
+	r.uiController.Prompt(fmt.Sprintf("🔎 Filter [%s] (e.g. pkg:./pkg/ca test:~Transport, Enter to run, Esc to cancel): ", r.filter), func(expr string) {
+		filter, err := ParseFilter(expr)
+		if err != nil {
+			r.uiController.AddLiveOutput(fmt.Sprintf("❌ %v", err))
+			return
+		}
+		r.filter = filter
+		r.config.Filter = filter.String()
+		if filter == nil {
+			r.uiController.AddLiveOutput("🔎 Filter cleared")
+		} else {
+			r.uiController.AddLiveOutput(fmt.Sprintf("🔎 Filter: %s", filter))
+		}
+		if err := r.runOnce(ctx); err != nil {
+			r.logger.Error("Test execution failed: %v", err)
+		}
+	})
+}
+
+// addSkip adds entry to the skip list at path
+func (r *Runner) addSkip(path string, entry SkipEntry) error {
+	list, err := LoadSkipList(path)
+	if err != nil {
+		return err
+	}
+	if err := list.Add(entry); err != nil {
+		return err
+	}
+	return list.Save(path)
+}
+
+// lastFailedTest returns a skip entry for the last failed Go test of the
+// most recent run. Suite results and leak reports can't be skipped.
+func (r *Runner) lastFailedTest() (SkipEntry, bool) {
+	if r.lastResults == nil || r.tree == nil {
+		return SkipEntry{}, false
+	}
+	for i := len(r.lastResults.Tests) - 1; i >= 0; i-- {
+		result := r.lastResults.Tests[i]
+		if result.Status != TestStatusFailed || result.File == "" {
+			continue
+		}
+		dir := filepath.Dir(result.File)
+		for _, pkg := range r.tree.Packages {
+			if pkg.Dir == dir {
+				return SkipEntry{Package: pkg.ImportPath, Test: result.Name}, true
+			}
+		}
+	}
+	return SkipEntry{}, false
+}
+
+// newSuiteAdapters creates adapters for the configured suites, resolving
+// their working directories against the test directory
+func newSuiteAdapters(config *Config) ([]SuiteAdapter, error) {
+	var adapters []SuiteAdapter
+	for _, suite := range config.Suites {
+		if suite.Dir == "" {
+			suite.Dir = config.Dir
+		} else if !filepath.IsAbs(suite.Dir) {
+			suite.Dir = filepath.Join(config.Dir, suite.Dir)
+		}
+
+		adapter, err := NewSuiteAdapter(suite)
+		if err != nil {
+			return nil, err
+		}
+		adapters = append(adapters, adapter)
+	}
+	return adapters, nil
+}
+
 // validateConfig validates the runner configuration
 func validateConfig(config *Config) error {
 	// Validate test directory
@@ -488,8 +1133,24 @@ func validateConfig(config *Config) error {
 	}
 	config.Dir = absDir
 
+	switch config.Reporter {
+	case "":
+		config.Reporter = ReporterDefault
+	case ReporterDefault, ReporterJSONStream:
+	default:
+		return fmt.Errorf("unknown reporter %q (expected %s or %s)", config.Reporter, ReporterDefault, ReporterJSONStream)
+	}
+
+	switch config.Diff {
+	case "":
+		config.Diff = DiffInline
+	case DiffInline, DiffSideBySide:
+	default:
+		return fmt.Errorf("unknown diff layout %q (expected %s or %s)", config.Diff, DiffInline, DiffSideBySide)
+	}
+
 	// Validate config file if specified
-	if config.ConfigFile != "" && config.ConfigFile != "testicle.yaml" {
+	if config.ConfigFile != "" && config.ConfigFile != DefaultConfigFile {
 		if _, err := os.Stat(config.ConfigFile); os.IsNotExist(err) {
 			return fmt.Errorf("configuration file does not exist: %s", config.ConfigFile)
 		}
@@ -497,3 +1158,32 @@ func validateConfig(config *Config) error {
 
 	return nil
 }
+
+// consoleDiffStyle renders diffs to fit the terminal, in color only when
+// stdout is one
+func consoleDiffStyle(config *Config) DiffStyle {
+	style := DiffStyle{Layout: config.Diff}
+	fd := int(os.Stdout.Fd())
+	if term.IsTerminal(fd) && !config.CI {
+		style.Color = true
+		if width, _, err := term.GetSize(fd); err == nil {
+			style.Width = width - 14 // Log timestamp and indentation
+		}
+	}
+	return style
+}
+
+// validateCIConfig rejects settings that conflict with CI mode. It runs after
+// the config file is applied, which can also set the reporter.
+func validateCIConfig(config *Config) error {
+	if !config.CI {
+		return nil
+	}
+	if config.Daemon {
+		return fmt.Errorf("--ci cannot be combined with --daemon")
+	}
+	if config.Reporter == ReporterJSONStream {
+		return fmt.Errorf("--ci cannot be combined with --reporter=%s (both write to stdout)", ReporterJSONStream)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package testicle
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nzions/sharedgolibs/internal/fileutil"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultSkipListFile is the skip list used when Config.SkipListFile is
+// empty, relative to the test directory
+const DefaultSkipListFile = ".testicle/skiplist.yaml"
+
+// skipListHeader is written at the top of every saved skip list
+const skipListHeader = `# Tests skipped by policy. testicle excludes these from every run and
+# reports them separately; remove an entry to run the test again.
+`
+
+// SkipEntry is a test skipped by policy rather than by t.Skip. It stays
+// excluded from runs until the entry is removed from the skip list.
+type SkipEntry struct {
+	Package string    `yaml:"package" json:"package"` // Import path
+	Test    string    `yaml:"test" json:"test"`       // Top-level test name
+	Reason  string    `yaml:"reason" json:"reason"`
+	Owner   string    `yaml:"owner" json:"owner"`
+	Added   time.Time `yaml:"added" json:"added"`
+}
+
+// String describes the entry as "package.Test (owner: reason)"
+func (e SkipEntry) String() string {
+	return fmt.Sprintf("%s.%s (%s: %s)", e.Package, e.Test, e.Owner, e.Reason)
+}
+
+// SkipList is the content of .testicle/skiplist.yaml
+type SkipList struct {
+	Tests []SkipEntry `yaml:"tests"`
+}
+
+// LoadSkipList reads a skip list. A missing file is an empty list.
+func LoadSkipList(path string) (*SkipList, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &SkipList{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading skip list: %w", err)
+	}
+
+	var list SkipList
+	if err := yaml.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("parsing skip list %s: %w", path, err)
+	}
+	for i, entry := range list.Tests {
+		if entry.Package == "" || entry.Test == "" {
+			return nil, fmt.Errorf("parsing skip list %s: tests[%d]: package and test are required", path, i)
+		}
+	}
+	return &list, nil
+}
+
+// Save writes the skip list sorted by package and test, creating its
+// directory. The file is replaced atomically so a concurrent run never
+// reads a partial list.
+func (l *SkipList) Save(path string) error {
+	sort.SliceStable(l.Tests, func(i, j int) bool {
+		if l.Tests[i].Package != l.Tests[j].Package {
+			return l.Tests[i].Package < l.Tests[j].Package
+		}
+		return l.Tests[i].Test < l.Tests[j].Test
+	})
+
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("encoding skip list: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating skip list directory: %w", err)
+	}
+
+	if err := fileutil.WriteAtomic(path, append([]byte(skipListHeader), data...), 0644); err != nil {
+		return fmt.Errorf("writing skip list: %w", err)
+	}
+	return nil
+}
+
+// Add skips a test by policy, replacing any existing entry for it. A reason
+// and an owner are required so the entry can be followed up.
+func (l *SkipList) Add(entry SkipEntry) error {
+	if entry.Package == "" || entry.Test == "" {
+		return fmt.Errorf("package and test are required")
+	}
+	if strings.TrimSpace(entry.Reason) == "" {
+		return fmt.Errorf("a reason is required to skip %s", entry.Test)
+	}
+	if strings.TrimSpace(entry.Owner) == "" {
+		return fmt.Errorf("an owner is required to skip %s", entry.Test)
+	}
+	if entry.Added.IsZero() {
+		entry.Added = time.Now().UTC().Truncate(time.Second)
+	}
+
+	l.Remove(entry.Package, entry.Test)
+	l.Tests = append(l.Tests, entry)
+	return nil
+}
+
+// Remove deletes the entry for a test and reports whether there was one
+func (l *SkipList) Remove(pkg, test string) bool {
+	for i, entry := range l.Tests {
+		if entry.Package == pkg && entry.Test == test {
+			l.Tests = append(l.Tests[:i], l.Tests[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Apply returns a copy of the tree without the tests on the list, the
+// entries that matched a discovered test, and stale entries naming tests
+// that no longer exist. Packages left without tests are dropped.
+func (l *SkipList) Apply(tree *TestTree) (filtered *TestTree, skipped, stale []SkipEntry) {
+	if len(l.Tests) == 0 {
+		return tree, nil, nil
+	}
+
+	byTest := make(map[string]SkipEntry, len(l.Tests))
+	for _, entry := range l.Tests {
+		byTest[entry.Package+"."+entry.Test] = entry
+	}
+
+	filtered = &TestTree{Module: tree.Module, Root: tree.Root, Workspace: tree.Workspace}
+	for _, pkg := range tree.Packages {
+		var tests []*TestNode
+		for _, test := range pkg.Tests {
+			key := pkg.ImportPath + "." + test.Name
+			if entry, ok := byTest[key]; ok {
+				skipped = append(skipped, entry)
+				delete(byTest, key)
+				continue
+			}
+			tests = append(tests, test)
+		}
+		if len(tests) > 0 {
+			pkgCopy := *pkg
+			pkgCopy.Tests = tests
+			filtered.Packages = append(filtered.Packages, &pkgCopy)
+		}
+	}
+
+	for _, entry := range l.Tests {
+		if _, unmatched := byTest[entry.Package+"."+entry.Test]; unmatched {
+			stale = append(stale, entry)
+		}
+	}
+	return filtered, skipped, stale
+}
+
+// skipPatterns maps each package directory in the tree to a go test -skip
+// pattern for its policy-skipped tests. Packages run as a whole, so the
+// pattern keeps skipped tests from running alongside the rest.
+func skipPatterns(tree *TestTree, skipped []SkipEntry) map[string]string {
+	names := make(map[string][]string)
+	for _, entry := range skipped {
+		names[entry.Package] = append(names[entry.Package], regexp.QuoteMeta(entry.Test))
+	}
+
+	patterns := make(map[string]string)
+	for _, pkg := range tree.Packages {
+		if tests := names[pkg.ImportPath]; len(tests) > 0 {
+			patterns[pkg.Dir] = "^(" + strings.Join(tests, "|") + ")$"
+		}
+	}
+	return patterns
+}
+
+// skipListPath resolves the configured skip list against the test directory
+func skipListPath(config *Config) string {
+	path := config.SkipListFile
+	if path == "" {
+		path = DefaultSkipListFile
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(config.Dir, path)
+	}
+	return path
+}
+
+// currentOwner names the user adding a skip entry from the UI
+func currentOwner() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
@@ -0,0 +1,142 @@
+package testicle
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSkipListSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".testicle", "skiplist.yaml")
+
+	list, err := LoadSkipList(path)
+	if err != nil || len(list.Tests) != 0 {
+		t.Fatalf("Expected an empty list for a missing file, got %v, %v", list, err)
+	}
+
+	if err := list.Add(SkipEntry{Package: "example.com/b", Test: "TestFlaky", Reason: "races on CI", Owner: "ana"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := list.Add(SkipEntry{Package: "example.com/a", Test: "TestSlow", Reason: "needs network", Owner: "ben"}); err != nil {
+		t.Fatal(err)
+	}
+	// Adding a test again replaces its entry
+	if err := list.Add(SkipEntry{Package: "example.com/b", Test: "TestFlaky", Reason: "tracked in #12", Owner: "ana"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := list.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "# Tests skipped by policy") {
+		t.Errorf("Expected the header comment, got:\n%s", data)
+	}
+
+	loaded, err := LoadSkipList(path)
+	if err != nil {
+		t.Fatalf("LoadSkipList failed: %v", err)
+	}
+	if len(loaded.Tests) != 2 || loaded.Tests[0].Test != "TestSlow" || loaded.Tests[1].Reason != "tracked in #12" {
+		t.Errorf("Unexpected entries: %+v", loaded.Tests)
+	}
+	if loaded.Tests[0].Added.IsZero() {
+		t.Error("Expected Add to record when the entry was added")
+	}
+
+	if !loaded.Remove("example.com/a", "TestSlow") || loaded.Remove("example.com/a", "TestSlow") {
+		t.Error("Expected Remove to delete the entry once")
+	}
+}
+
+func TestSkipListAddRequiresReasonAndOwner(t *testing.T) {
+	list := &SkipList{}
+	for _, entry := range []SkipEntry{
+		{Package: "p", Test: "TestA", Owner: "ana"},
+		{Package: "p", Test: "TestA", Reason: "  ", Owner: "ana"},
+		{Package: "p", Test: "TestA", Reason: "flaky"},
+		{Test: "TestA", Reason: "flaky", Owner: "ana"},
+	} {
+		if err := list.Add(entry); err == nil {
+			t.Errorf("Expected Add(%+v) to fail", entry)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "skiplist.yaml")
+	os.WriteFile(path, []byte("tests:\n  - test: TestA\n"), 0644)
+	if _, err := LoadSkipList(path); err == nil {
+		t.Error("Expected an entry without a package to be rejected")
+	}
+}
+
+func TestSkipListApply(t *testing.T) {
+	tree := &TestTree{Packages: []*PackageNode{
+		{ImportPath: "example.com/a", Dir: "/src/a", Tests: []*TestNode{{Name: "TestA1"}, {Name: "TestA2"}}},
+		{ImportPath: "example.com/b", Dir: "/src/b", Tests: []*TestNode{{Name: "TestB"}}},
+	}}
+	list := &SkipList{Tests: []SkipEntry{
+		{Package: "example.com/a", Test: "TestA2"},
+		{Package: "example.com/b", Test: "TestB"},
+		{Package: "example.com/a", Test: "TestGone"},
+	}}
+
+	filtered, skipped, stale := list.Apply(tree)
+	if len(filtered.Packages) != 1 || len(filtered.Packages[0].Tests) != 1 || filtered.Packages[0].Tests[0].Name != "TestA1" {
+		t.Errorf("Unexpected filtered tree: %+v", filtered.Packages)
+	}
+	if len(tree.Packages[0].Tests) != 2 {
+		t.Error("Apply modified the original tree")
+	}
+	if len(skipped) != 2 || len(stale) != 1 || stale[0].Test != "TestGone" {
+		t.Errorf("Unexpected skipped %v and stale %v", skipped, stale)
+	}
+
+	patterns := skipPatterns(tree, skipped)
+	if patterns["/src/a"] != "^(TestA2)$" || patterns["/src/b"] != "^(TestB)$" {
+		t.Errorf("Unexpected skip patterns: %v", patterns)
+	}
+}
+
+func TestRunnerSkipsByPolicy(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test on a generated package")
+	}
+
+	dir, err := filepath.Abs(filepath.Join("testdata", "policy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := "github.com/nzions/sharedgolibs/pkg/testicle/testdata/policy"
+	skipList := filepath.Join(t.TempDir(), "skiplist.yaml")
+	list := &SkipList{}
+	list.Add(SkipEntry{Package: pkg, Test: "TestBroken", Reason: "broken upstream", Owner: "ana"})
+	list.Add(SkipEntry{Package: pkg, Test: "TestRemoved", Reason: "gone", Owner: "ben"})
+	if err := list.Save(skipList); err != nil {
+		t.Fatal(err)
+	}
+
+	runner, err := NewRunner(&Config{Dir: dir, SkipListFile: skipList})
+	if err != nil {
+		t.Fatalf("NewRunner failed: %v", err)
+	}
+	var results []*TestResult
+	runner.executor.SetResultCallback(func(result *TestResult) {
+		results = append(results, result)
+	})
+
+	if err := runner.Run(context.Background()); err != nil {
+		t.Fatalf("Expected the policy-skipped failure not to fail the run: %v", err)
+	}
+	if len(results) != 1 || results[0].Name != "TestPasses" {
+		t.Errorf("Expected only TestPasses to run, got %d result(s)", len(results))
+	}
+	last := runner.lastResults
+	if len(last.SkippedByPolicy) != 1 || last.Skipped != 0 || len(last.StaleSkips) != 1 || last.StaleSkips[0].Test != "TestRemoved" {
+		t.Errorf("Unexpected policy counts: skipped %d, by policy %v, stale %v", last.Skipped, last.SkippedByPolicy, last.StaleSkips)
+	}
+}
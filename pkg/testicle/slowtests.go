@@ -0,0 +1,177 @@
+package testicle
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Actions of the slow test gate
+const (
+	SlowTestActionWarn = "warn" // List slow tests in the summary (default)
+	SlowTestActionFail = "fail" // Also fail the run with ErrSlowTests
+)
+
+// ErrSlowTests is returned by Runner.Run when the slow test gate fails a
+// run whose tests passed; see ExitCode
+var ErrSlowTests = errors.New("slow tests")
+
+// Package durations kept per package for percentiles, and the samples
+// needed before a package's p95 is trusted
+const (
+	maxDurationSamples = 20
+	minDurationSamples = 5
+)
+
+// SlowTestConfig is the slow test gate in testicle.yaml. It catches slow
+// test creep by flagging tests slower than a threshold and packages whose
+// duration regressed against their recent runs:
+//
+//	slow_tests:
+//	  max_test_duration: 10s  # Any top-level test slower than this
+//	  p95_regression: 25%     # A package over its p95 duration plus 25%
+//	  action: fail            # warn (default) or fail
+//
+// Package durations are recorded in the duration history (see
+// DurationHistory) after every run the gate checks.
+type SlowTestConfig struct {
+	MaxTestDuration string `yaml:"max_test_duration" schema:"duration"`
+	P95Regression   string `yaml:"p95_regression" schema:"percent"`
+	Action          string `yaml:"action" schema:"enum=slow_test_action"`
+}
+
+// SlowTest is a test, or a package, flagged by the slow test gate
+type SlowTest struct {
+	Package  string        `json:"package"`
+	Test     string        `json:"test,omitempty"` // Empty for a package regression
+	Duration time.Duration `json:"duration"`
+	Limit    time.Duration `json:"limit"`         // The duration allowed
+	P95      time.Duration `json:"p95,omitempty"` // Recorded p95 of a regressed package
+}
+
+// String describes the slow test for the summary
+func (s SlowTest) String() string {
+	if s.Test == "" {
+		return fmt.Sprintf("%s took %s, over its p95 of %s (limit %s)", s.Package, roundDuration(s.Duration), roundDuration(s.P95), roundDuration(s.Limit))
+	}
+	return fmt.Sprintf("%s.%s took %s (limit %s)", s.Package, s.Test, roundDuration(s.Duration), roundDuration(s.Limit))
+}
+
+// slowTestGate checks a run against a SlowTestConfig
+type slowTestGate struct {
+	maxTestDuration time.Duration // Zero disables the test threshold
+	p95Regression   float64       // Zero disables the package check
+	fail            bool
+}
+
+// newSlowTestGate parses config, returning nil when neither check is set
+func newSlowTestGate(config SlowTestConfig) (*slowTestGate, error) {
+	gate := &slowTestGate{}
+	if config.MaxTestDuration != "" {
+		d, err := time.ParseDuration(config.MaxTestDuration)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid slow_tests.max_test_duration %q", config.MaxTestDuration)
+		}
+		gate.maxTestDuration = d
+	}
+	if config.P95Regression != "" {
+		fraction, err := parsePercent(config.P95Regression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid slow_tests.p95_regression: %w", err)
+		}
+		gate.p95Regression = fraction
+	}
+	switch config.Action {
+	case "", SlowTestActionWarn:
+	case SlowTestActionFail:
+		gate.fail = true
+	default:
+		return nil, fmt.Errorf("unknown slow_tests.action %q (expected %s or %s)", config.Action, SlowTestActionWarn, SlowTestActionFail)
+	}
+
+	if gate.maxTestDuration == 0 && gate.p95Regression == 0 {
+		return nil, nil
+	}
+	return gate, nil
+}
+
+// Check returns the top-level tests over the duration threshold, slowest
+// first, followed by the packages that regressed against history. Packages
+// stopped by the budget and packages with fewer than minDurationSamples
+// recorded runs are not checked for regressions.
+func (g *slowTestGate) Check(results *TestResults, history *DurationHistory) []SlowTest {
+	var slow []SlowTest
+	if g.maxTestDuration > 0 {
+		for _, result := range results.Tests {
+			if strings.Contains(result.Name, "/") || result.Status == TestStatusSkipped || result.Duration <= g.maxTestDuration {
+				continue
+			}
+			slow = append(slow, SlowTest{Package: result.Package, Test: result.Name, Duration: result.Duration, Limit: g.maxTestDuration})
+		}
+		sort.SliceStable(slow, func(i, j int) bool { return slow[i].Duration > slow[j].Duration })
+	}
+
+	if g.p95Regression == 0 || history == nil {
+		return slow
+	}
+	stopped := make(map[string]bool)
+	for _, result := range results.NotRun {
+		stopped[absPath(filepath.Dir(result.File))] = true
+	}
+	for _, timing := range results.Packages {
+		if timing.Started.IsZero() || timing.Finished.IsZero() || stopped[absPath(timing.Package)] {
+			continue
+		}
+		entry, ok := history.Packages[absPath(timing.Package)]
+		if !ok {
+			continue
+		}
+		p95, ok := entry.P95()
+		if !ok {
+			continue
+		}
+		limit := time.Duration(float64(p95) * (1 + g.p95Regression))
+		if duration := timing.Finished.Sub(timing.Started); duration > limit {
+			name := timing.Package
+			if len(timing.Tests) > 0 && timing.Tests[0].Package != "" {
+				name = timing.Tests[0].Package
+			}
+			slow = append(slow, SlowTest{Package: name, Duration: duration, Limit: limit, P95: p95})
+		}
+	}
+	return slow
+}
+
+// P95 returns the 95th percentile of the package's recorded durations, or
+// false with fewer than minDurationSamples of them
+func (p *PackageHistory) P95() (time.Duration, bool) {
+	if len(p.Samples) < minDurationSamples {
+		return 0, false
+	}
+	sorted := append([]time.Duration(nil), p.Samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(0.95*float64(len(sorted)))) - 1
+	return sorted[rank], true
+}
+
+// parsePercent parses a percentage such as 25% or 12.5% into a fraction
+func parsePercent(s string) (float64, error) {
+	value, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, "%")), 64)
+	if err != nil || !(value > 0) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("invalid percentage %q (use a percentage such as 25%%)", s)
+	}
+	return value / 100, nil
+}
+
+// roundDuration rounds a duration for display
+func roundDuration(d time.Duration) time.Duration {
+	if d >= time.Second {
+		return d.Round(10 * time.Millisecond)
+	}
+	return d.Round(time.Millisecond)
+}
@@ -0,0 +1,108 @@
+package testicle
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSlowTestGate(t *testing.T) {
+	if gate, err := newSlowTestGate(SlowTestConfig{Action: SlowTestActionFail}); gate != nil || err != nil {
+		t.Errorf("Expected no gate without checks, got %+v, %v", gate, err)
+	}
+
+	gate, err := newSlowTestGate(SlowTestConfig{MaxTestDuration: "2s", P95Regression: "12.5%", Action: SlowTestActionFail})
+	if err != nil {
+		t.Fatalf("newSlowTestGate failed: %v", err)
+	}
+	if gate.maxTestDuration != 2*time.Second || gate.p95Regression != 0.125 || !gate.fail {
+		t.Errorf("Unexpected gate: %+v", gate)
+	}
+
+	for _, config := range []SlowTestConfig{
+		{MaxTestDuration: "soon"},
+		{P95Regression: "-5%"},
+		{P95Regression: "NaN"},
+		{MaxTestDuration: "1s", Action: "block"},
+	} {
+		if _, err := newSlowTestGate(config); err == nil {
+			t.Errorf("Expected an error for %+v", config)
+		}
+	}
+
+	_, err = ParseConfig("testicle.yaml", []byte("slow_tests:\n  p95_regression: lots\n  action: warnn\n"))
+	for _, want := range []string{
+		`slow_tests.p95_regression: invalid percentage "lots"`,
+		`slow_tests.action: unknown value "warnn" (did you mean "warn"?)`,
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestSlowTestGateCheck(t *testing.T) {
+	gate := &slowTestGate{maxTestDuration: time.Second, p95Regression: 0.5}
+	samples := []time.Duration{10 * time.Second, 9 * time.Second, 11 * time.Second, 10 * time.Second, 12 * time.Second}
+	history := &DurationHistory{Packages: map[string]*PackageHistory{
+		absPath("/repo/regressed"): {Samples: samples},
+		absPath("/repo/steady"):    {Samples: samples},
+		absPath("/repo/new"):       {Samples: samples[:2]},
+		absPath("/repo/stopped"):   {Samples: samples},
+	}}
+
+	start := time.Now()
+	results := &TestResults{
+		Tests: []*TestResult{
+			{Name: "TestFast", Package: "app", Status: TestStatusPassed, Duration: 100 * time.Millisecond},
+			{Name: "TestSlow", Package: "app", Status: TestStatusPassed, Duration: 2 * time.Second},
+			{Name: "TestSlower", Package: "app", Status: TestStatusFailed, Duration: 5 * time.Second},
+			{Name: "TestSlower/case", Package: "app", Status: TestStatusFailed, Duration: 5 * time.Second},
+			{Name: "TestSkipped", Package: "app", Status: TestStatusSkipped, Duration: 5 * time.Second},
+		},
+		Packages: []*PackageTiming{
+			{Package: "/repo/regressed", Started: start, Finished: start.Add(20 * time.Second),
+				Tests: []*TestResult{{Name: "TestA", Package: "example.com/regressed"}}},
+			{Package: "/repo/steady", Started: start, Finished: start.Add(15 * time.Second)},
+			{Package: "/repo/new", Started: start, Finished: start.Add(time.Minute)},
+			{Package: "/repo/stopped", Started: start, Finished: start.Add(time.Minute)},
+			{Package: "/repo/unknown", Started: start, Finished: start.Add(time.Minute)},
+		},
+		NotRun: []*TestResult{{Name: "TestLate", File: "/repo/stopped/late_test.go"}},
+	}
+
+	slow := gate.Check(results, history)
+	var got []string
+	for _, s := range slow {
+		got = append(got, s.String())
+	}
+	want := []string{
+		"app.TestSlower took 5s (limit 1s)",
+		"app.TestSlow took 2s (limit 1s)",
+		"example.com/regressed took 20s, over its p95 of 12s (limit 18s)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Check() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDurationHistorySamples(t *testing.T) {
+	history := &DurationHistory{Packages: make(map[string]*PackageHistory)}
+	start := time.Now()
+	for i := 1; i <= maxDurationSamples+5; i++ {
+		history.Record(&TestResults{Packages: []*PackageTiming{
+			{Package: "/repo/pkg", Started: start, Finished: start.Add(time.Duration(i) * time.Second)},
+		}})
+	}
+
+	entry := history.Packages[absPath("/repo/pkg")]
+	if len(entry.Samples) != maxDurationSamples || entry.Samples[0] != 6*time.Second {
+		t.Fatalf("Expected the last %d samples, got %v", maxDurationSamples, entry.Samples)
+	}
+	if p95, ok := entry.P95(); !ok || p95 != 24*time.Second {
+		t.Errorf("P95() = %v, %v, want 24s", p95, ok)
+	}
+	if _, ok := (&PackageHistory{Samples: entry.Samples[:minDurationSamples-1]}).P95(); ok {
+		t.Error("Expected no p95 with too few samples")
+	}
+}
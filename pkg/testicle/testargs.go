@@ -0,0 +1,103 @@
+package testicle
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PackageConfig is the per-package section of testicle.yaml, keyed by a
+// package path as in a pkg: filter term (./pkg/cache, an import path, or
+// either with a /... suffix for subpackages):
+//
+//	go_test_args: [-race]
+//	packages:
+//	  ./pkg/cache:
+//	    go_test_args: [-count=5]          # Flaky hunting
+//	  ./integration/...:
+//	    go_test_args: [-tags=integration]
+type PackageConfig struct {
+	GoTestArgs []string `yaml:"go_test_args"`
+}
+
+// reservedGoTestFlags are set by testicle itself: it parses -v output and
+// selects tests with -run and -skip
+var reservedGoTestFlags = map[string]bool{
+	"v": true, "run": true, "skip": true, "json": true, "c": true, "o": true,
+}
+
+// validateGoTestArgs rejects arguments that aren't flags, or are flags
+// testicle sets itself. A value may follow its flag as a separate argument.
+func validateGoTestArgs(args []string) error {
+	for i, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			if i == 0 || strings.Contains(args[i-1], "=") || !strings.HasPrefix(args[i-1], "-") {
+				return fmt.Errorf("go test argument %q is not a flag (packages are chosen by testicle)", arg)
+			}
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if reservedGoTestFlags[name] {
+			return fmt.Errorf("go test flag %q is set by testicle", arg)
+		}
+	}
+	return nil
+}
+
+// validateTestArgs checks the global and per-package go test arguments
+func validateTestArgs(config *Config) error {
+	if err := validateGoTestArgs(config.GoTestArgs); err != nil {
+		return fmt.Errorf("go_test_args: %w", err)
+	}
+	for key, pkg := range config.Packages {
+		if _, err := parseFilterTerm("pkg:" + key); err != nil {
+			return fmt.Errorf("packages.%s: invalid package path: %w", key, err)
+		}
+		if err := validateGoTestArgs(pkg.GoTestArgs); err != nil {
+			return fmt.Errorf("packages.%s.go_test_args: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// goTestArgs maps each package directory in the tree to its extra go test
+// arguments: the global ones followed by those of every matching packages
+// entry, in key order, so a later -count=5 overrides an earlier -count=1
+func goTestArgs(tree *TestTree, global []string, packages map[string]PackageConfig) map[string][]string {
+	keys := make([]string, 0, len(packages))
+	for key := range packages {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	args := make(map[string][]string)
+	for _, pkg := range tree.Packages {
+		merged := append([]string(nil), global...)
+		for _, key := range keys {
+			term, err := parseFilterTerm("pkg:" + key)
+			if err == nil && term.matchPackage(tree.Root, pkg) {
+				merged = append(merged, packages[key].GoTestArgs...)
+			}
+		}
+		if len(merged) > 0 {
+			args[pkg.Dir] = merged
+		}
+	}
+	return args
+}
+
+// packageGoTestArgs returns the per-package arguments of the config by key,
+// for run metadata
+func packageGoTestArgs(packages map[string]PackageConfig) map[string][]string {
+	var args map[string][]string
+	for key, pkg := range packages {
+		if len(pkg.GoTestArgs) == 0 {
+			continue
+		}
+		if args == nil {
+			args = make(map[string][]string)
+		}
+		args[key] = pkg.GoTestArgs
+	}
+	return args
+}
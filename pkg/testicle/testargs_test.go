@@ -0,0 +1,84 @@
+package testicle
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGoTestArgs(t *testing.T) {
+	args := goTestArgs(filterTestTree(), []string{"-race"}, map[string]PackageConfig{
+		"./pkg/ca/...":            {GoTestArgs: []string{"-count=1"}},
+		"example.com/m/pkg/ca":    {GoTestArgs: []string{"-count=5"}},
+		"./pkg/gflag":             {GoTestArgs: []string{"-tags", "integration"}},
+		"example.com/m/pkg/other": {GoTestArgs: []string{"-short"}},
+	})
+
+	want := map[string][]string{
+		"/src/m/pkg/ca":       {"-race", "-count=1", "-count=5"},
+		"/src/m/pkg/ca/store": {"-race", "-count=1"},
+		"/src/m/pkg/gflag":    {"-race", "-tags", "integration"},
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("Expected %v, got %v", want, args)
+	}
+
+	if args := goTestArgs(filterTestTree(), nil, nil); len(args) != 0 {
+		t.Errorf("Expected no arguments without configuration, got %v", args)
+	}
+}
+
+func TestValidateTestArgs(t *testing.T) {
+	valid := &Config{
+		GoTestArgs: []string{"-race", "-tags", "integration"},
+		Packages:   map[string]PackageConfig{"./pkg/...": {GoTestArgs: []string{"-count=5", "-timeout=2m"}}},
+	}
+	if err := validateTestArgs(valid); err != nil {
+		t.Errorf("Expected valid arguments, got %v", err)
+	}
+
+	for _, config := range []*Config{
+		{GoTestArgs: []string{"./..."}},
+		{GoTestArgs: []string{"-count=5", "extra"}},
+		{GoTestArgs: []string{"-run=TestX"}},
+		{Packages: map[string]PackageConfig{"./pkg": {GoTestArgs: []string{"--json"}}}},
+		{Packages: map[string]PackageConfig{"~(": {GoTestArgs: []string{"-short"}}}},
+	} {
+		if err := validateTestArgs(config); err == nil {
+			t.Errorf("Expected %+v to be rejected", config)
+		}
+	}
+}
+
+func TestTestCommandGoTestArgs(t *testing.T) {
+	executor := NewExecutor(NewLogger(false))
+	executor.SetRunPatterns(map[string]string{"/src/m/pkg/ca": "^TestIssue$"})
+	executor.SetGoTestArgs(map[string][]string{"/src/m/pkg/ca": {"-count=5", "-tags=integration"}})
+	executor.EnableBuildCache(NewBuildCache(t.TempDir(), NewLogger(false)))
+
+	cmd := executor.testCommand(context.Background(), "/src/m/pkg/ca")
+	if got := strings.Join(cmd.Args[1:], " "); got != "test -v -run ^TestIssue$ -count=5 -tags=integration ." {
+		t.Errorf("Unexpected go test arguments %q", got)
+	}
+}
+
+func TestParseConfigGoTestArgs(t *testing.T) {
+	fileConfig, err := ParseConfig("testicle.yaml", []byte(`
+go_test_args: [-race]
+packages:
+  ./pkg/cache:
+    go_test_args: [-count=5]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fileConfig.GoTestArgs, []string{"-race"}) || !reflect.DeepEqual(fileConfig.Packages["./pkg/cache"].GoTestArgs, []string{"-count=5"}) {
+		t.Errorf("Unexpected config %+v", fileConfig)
+	}
+
+	metadata := &RunMetadata{GoVersion: "go1.23.4", OS: "linux", Arch: "amd64", GoTestArgs: fileConfig.GoTestArgs, PackageGoTestArgs: packageGoTestArgs(fileConfig.Packages)}
+	if got := metadata.String(); got != "go1.23.4 linux/amd64 • go test -race • own go test args for 1 package path(s)" {
+		t.Errorf("Unexpected summary %q", got)
+	}
+}
@@ -0,0 +1,33 @@
+package leaky
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nzions/sharedgolibs/pkg/testicle/leakcheck"
+)
+
+func TestMain(m *testing.M) {
+	leakcheck.VerifyTestMain(m, leakcheck.WithMaxWait(50*time.Millisecond))
+}
+
+func TestClean(t *testing.T) {}
+
+func TestLeakyListener(t *testing.T) {
+	leakcheck.Check(t, leakcheck.WithMaxWait(50*time.Millisecond))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+}
@@ -0,0 +1,11 @@
+// Package policy is a fixture for skip list tests: TestBroken always fails
+// and is expected to be skipped by policy.
+package policy
+
+import "testing"
+
+func TestPasses(t *testing.T) {}
+
+func TestBroken(t *testing.T) {
+	t.Fatal("broken until skipped by policy")
+}
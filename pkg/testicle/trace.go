@@ -0,0 +1,187 @@
+package testicle
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// ArtifactTraceFile is the Chrome trace saved alongside each run's report
+const ArtifactTraceFile = "trace.json"
+
+// traceProcessID is the single process all tracks of a run belong to
+const traceProcessID = 1
+
+// chromeTrace is the JSON object format of the Chrome trace-event format
+// understood by Perfetto (ui.perfetto.dev) and chrome://tracing
+type chromeTrace struct {
+	TraceEvents     []traceEvent `json:"traceEvents"`
+	DisplayTimeUnit string       `json:"displayTimeUnit"`
+	OtherData       *RunMetadata `json:"otherData,omitempty"`
+}
+
+// traceEvent is one trace event: a complete span ("X") or track metadata
+// ("M"). Timestamps and durations are in microseconds.
+type traceEvent struct {
+	Name  string         `json:"name"`
+	Cat   string         `json:"cat,omitempty"`
+	Phase string         `json:"ph"`
+	TS    int64          `json:"ts"`
+	Dur   int64          `json:"dur,omitempty"`
+	PID   int            `json:"pid"`
+	TID   int            `json:"tid"`
+	Color string         `json:"cname,omitempty"`
+	Args  map[string]any `json:"args,omitempty"`
+}
+
+// WriteChromeTrace writes a run's timeline as Chrome trace-event JSON. Each
+// package gets a track with a span for the whole package, one for building
+// and starting its test binary, and one per top-level test. Parallel tests
+// overlap, so they spill onto extra tracks named after the package.
+// Timestamps are relative to the start of the first package.
+func WriteChromeTrace(w io.Writer, results *TestResults) error {
+	packages := sortedPackages(results)
+	trace := chromeTrace{
+		TraceEvents:     []traceEvent{metadataEvent("process_name", 0, "testicle")},
+		DisplayTimeUnit: "ms",
+		OtherData:       results.Metadata,
+	}
+	var origin time.Time
+	if len(packages) > 0 {
+		origin = packages[0].Started
+	}
+	span := func(name, cat string, tid int, start, end time.Time) traceEvent {
+		return traceEvent{
+			Name:  name,
+			Cat:   cat,
+			Phase: "X",
+			TS:    start.Sub(origin).Microseconds(),
+			Dur:   max(end.Sub(start).Microseconds(), 1),
+			PID:   traceProcessID,
+			TID:   tid,
+		}
+	}
+
+	tid := 0
+	for _, timing := range packages {
+		tid++
+		trace.TraceEvents = append(trace.TraceEvents,
+			metadataEvent("thread_name", tid, timing.Package),
+			metadataEvent("thread_sort_index", tid, tid),
+			span(timing.Package, "package", tid, timing.Started, timing.Finished))
+		if !timing.FirstTest.IsZero() {
+			trace.TraceEvents = append(trace.TraceEvents, span("build", "build", tid, timing.Started, timing.FirstTest))
+		}
+
+		for lane, tests := range testLanes(timing) {
+			laneTID := tid
+			if lane > 0 {
+				tid++
+				laneTID = tid
+				trace.TraceEvents = append(trace.TraceEvents,
+					metadataEvent("thread_name", tid, fmt.Sprintf("%s #%d", timing.Package, lane+1)),
+					metadataEvent("thread_sort_index", tid, tid))
+			}
+			for _, result := range tests {
+				event := span(result.Name, "test", laneTID, result.Started, testFinished(result, timing))
+				event.Args = map[string]any{"status": result.Status.String()}
+				if result.File != "" {
+					event.Args["file"] = fmt.Sprintf("%s:%d", result.File, result.Line)
+				}
+				if result.Error != "" {
+					event.Args["error"] = result.Error
+				}
+				switch result.Status {
+				case TestStatusFailed:
+					event.Color = "terrible"
+				case TestStatusSkipped:
+					event.Color = "grey"
+				}
+				trace.TraceEvents = append(trace.TraceEvents, event)
+			}
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(trace); err != nil {
+		return fmt.Errorf("writing trace: %w", err)
+	}
+	return nil
+}
+
+// sortedPackages returns the package timings of a run in start order
+func sortedPackages(results *TestResults) []*PackageTiming {
+	packages := append([]*PackageTiming(nil), results.Packages...)
+	sort.SliceStable(packages, func(i, j int) bool {
+		return packages[i].Started.Before(packages[j].Started)
+	})
+	return packages
+}
+
+// testLanes lays the started top-level tests of a package out in lanes, in
+// start order, so tests in a lane never overlap; lane 0 is the package's
+// own track
+func testLanes(timing *PackageTiming) [][]*TestResult {
+	tests := make([]*TestResult, 0, len(timing.Tests))
+	for _, result := range timing.Tests {
+		if !result.Started.IsZero() {
+			tests = append(tests, result)
+		}
+	}
+	sort.SliceStable(tests, func(i, j int) bool {
+		return tests[i].Started.Before(tests[j].Started)
+	})
+
+	var lanes [][]*TestResult
+	var laneEnds []time.Time
+	for _, result := range tests {
+		lane := 0
+		for lane < len(laneEnds) && laneEnds[lane].After(result.Started) {
+			lane++
+		}
+		if lane == len(lanes) {
+			lanes = append(lanes, nil)
+			laneEnds = append(laneEnds, time.Time{})
+		}
+		lanes[lane] = append(lanes[lane], result)
+		laneEnds[lane] = testFinished(result, timing)
+	}
+	return lanes
+}
+
+// testFinished returns when a test finished, or its package did for tests
+// that crashed or timed out
+func testFinished(result *TestResult, timing *PackageTiming) time.Time {
+	if result.Finished.IsZero() {
+		return timing.Finished
+	}
+	return result.Finished
+}
+
+// metadataEvent names or orders the process (tid 0) or a track
+func metadataEvent(name string, tid int, value any) traceEvent {
+	key := "name"
+	if name == "thread_sort_index" {
+		key = "sort_index"
+	}
+	return traceEvent{Name: name, Phase: "M", PID: traceProcessID, TID: tid, Args: map[string]any{key: value}}
+}
+
+// writeChromeTraceFile writes a run's Chrome trace to path
+func writeChromeTraceFile(path string, results *TestResults) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating trace: %w", err)
+	}
+	if err := WriteChromeTrace(file, results); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("writing trace: %w", err)
+	}
+	return nil
+}
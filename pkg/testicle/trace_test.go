@@ -0,0 +1,88 @@
+package testicle
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestWriteChromeTrace(t *testing.T) {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	// TestB and TestC run in parallel; TestD never reported a result
+	tests := []*TestResult{
+		{Name: "TestA", File: "a/a_test.go", Line: 10, Status: TestStatusPassed, Started: at(100), Finished: at(200)},
+		{Name: "TestB", Status: TestStatusPassed, Started: at(200), Finished: at(400)},
+		{Name: "TestC", Status: TestStatusFailed, Error: "--- FAIL: TestC", Started: at(250), Finished: at(300)},
+		{Name: "TestD", Status: TestStatusFailed, Started: at(400)},
+		{Name: "TestNeverRan", Status: TestStatusFailed},
+	}
+	results := &TestResults{
+		Tests:    tests,
+		Metadata: &RunMetadata{GoVersion: "go1.23.4"},
+		Packages: []*PackageTiming{
+			{Package: "lint", Started: at(600), Finished: at(700)},
+			{Package: "a", Started: start, FirstTest: at(100), Finished: at(500), Tests: tests},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteChromeTrace(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	var trace chromeTrace
+	if err := json.Unmarshal(buf.Bytes(), &trace); err != nil {
+		t.Fatalf("Invalid trace JSON: %v\n%s", err, buf.String())
+	}
+	if trace.OtherData == nil || trace.OtherData.GoVersion != "go1.23.4" {
+		t.Errorf("Expected run metadata in otherData, got %+v", trace.OtherData)
+	}
+
+	spans := make(map[string]traceEvent)
+	tracks := make(map[int]string)
+	for _, event := range trace.TraceEvents {
+		switch {
+		case event.Phase == "X":
+			spans[event.Name] = event
+		case event.Name == "thread_name":
+			tracks[event.TID] = event.Args["name"].(string)
+		}
+	}
+	if len(spans) != 7 {
+		t.Errorf("Expected 2 package, 1 build, and 4 test spans, got %d: %v", len(spans), spans)
+	}
+
+	checks := []struct {
+		name      string
+		ts, dur   int64
+		track     string
+		wantColor string
+	}{
+		{"a", 0, 500_000, "a", ""},
+		{"build", 0, 100_000, "a", ""},
+		{"TestA", 100_000, 100_000, "a", ""},
+		{"TestB", 200_000, 200_000, "a", ""},
+		{"TestC", 250_000, 50_000, "a #2", "terrible"},
+		{"TestD", 400_000, 100_000, "a", "terrible"},
+		{"lint", 600_000, 100_000, "lint", ""},
+	}
+	for _, check := range checks {
+		span, ok := spans[check.name]
+		if !ok {
+			t.Errorf("Missing span %s", check.name)
+			continue
+		}
+		if span.TS != check.ts || span.Dur != check.dur || tracks[span.TID] != check.track || span.Color != check.wantColor {
+			t.Errorf("Span %s: got ts %d dur %d track %q color %q, want %d %d %q %q",
+				check.name, span.TS, span.Dur, tracks[span.TID], span.Color, check.ts, check.dur, check.track, check.wantColor)
+		}
+	}
+	if args := spans["TestA"].Args; args["status"] != "passed" || args["file"] != "a/a_test.go:10" {
+		t.Errorf("Unexpected TestA args: %v", args)
+	}
+	if args := spans["TestC"].Args; args["error"] != "--- FAIL: TestC" {
+		t.Errorf("Unexpected TestC args: %v", args)
+	}
+}
@@ -0,0 +1,592 @@
+package testicle
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// TestKind classifies a discovered test function by its testing parameter
+type TestKind string
+
+const (
+	TestKindTest      TestKind = "test"
+	TestKindBenchmark TestKind = "benchmark"
+	TestKindFuzz      TestKind = "fuzz"
+)
+
+// TestTree is the hierarchical discovery model: module -> package -> test -> subtests.
+// It is produced by Runner.Discover and consumed by execution, the UI, and tag selection.
+type TestTree struct {
+	Module   string         `json:"module,omitempty"` // Module path from go.mod, if found
+	Root     string         `json:"root"`             // Directory that was scanned
+	Packages []*PackageNode `json:"packages"`
+
+	// Workspace is the go.work file when Root is in a workspace. Packages
+	// then come from every module in it, grouped by module.
+	Workspace string `json:"workspace,omitempty"`
+}
+
+// PackageNode groups the tests of one package directory
+type PackageNode struct {
+	ImportPath string      `json:"import_path"`
+	Name       string      `json:"name"`
+	Dir        string      `json:"dir"`
+	Module     string      `json:"module,omitempty"` // Path of the module the package belongs to
+	Tests      []*TestNode `json:"tests"`
+}
+
+// TestNode is a top-level test function or a subtest found via t.Run
+type TestNode struct {
+	Name     string      `json:"name"`      // Own name segment as reported by go test
+	FullName string      `json:"full_name"` // Slash-separated path usable with go test -run
+	Kind     TestKind    `json:"kind"`
+	File     string      `json:"file"`
+	Line     int         `json:"line"`
+	Tags     []string    `json:"tags,omitempty"`
+	Subtests []*TestNode `json:"subtests,omitempty"`
+
+	// Dynamic is set when some subtest names are computed at runtime
+	// (e.g. fmt.Sprintf) and could not be listed statically
+	Dynamic bool `json:"dynamic,omitempty"`
+
+	info *TestInfo // Source test, top-level nodes only
+}
+
+// Tests returns the top-level tests of the tree in package order
+func (t *TestTree) Tests() []*TestInfo {
+	var tests []*TestInfo
+	for _, pkg := range t.Packages {
+		for _, test := range pkg.Tests {
+			if test.info != nil {
+				tests = append(tests, test.info)
+			}
+		}
+	}
+	return tests
+}
+
+// Modules returns the module paths of the tree's packages in package order
+func (t *TestTree) Modules() []string {
+	var modules []string
+	for _, pkg := range t.Packages {
+		if len(modules) == 0 || modules[len(modules)-1] != pkg.Module {
+			modules = append(modules, pkg.Module)
+		}
+	}
+	return modules
+}
+
+// TestCount returns the number of top-level tests
+func (t *TestTree) TestCount() int {
+	count := 0
+	for _, pkg := range t.Packages {
+		count += len(pkg.Tests)
+	}
+	return count
+}
+
+// SubtestCount returns the number of statically discovered subtests at any depth
+func (t *TestTree) SubtestCount() int {
+	count := 0
+	for _, pkg := range t.Packages {
+		count += pkg.SubtestCount()
+	}
+	return count
+}
+
+// SubtestCount returns the number of statically discovered subtests in the package
+func (p *PackageNode) SubtestCount() int {
+	return countSubtests(p.Tests)
+}
+
+// countSubtests counts the subtests below nodes at any depth
+func countSubtests(nodes []*TestNode) int {
+	count := 0
+	for _, node := range nodes {
+		count += len(node.Subtests) + countSubtests(node.Subtests)
+	}
+	return count
+}
+
+// FilterTags returns a copy of the tree containing only top-level tests that
+// carry at least one of tags. Packages left without tests are dropped. With
+// no tags the tree is returned unchanged.
+func (t *TestTree) FilterTags(tags ...string) *TestTree {
+	if len(tags) == 0 {
+		return t
+	}
+
+	wanted := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		wanted[tag] = true
+	}
+
+	filtered := &TestTree{Module: t.Module, Root: t.Root, Workspace: t.Workspace}
+	for _, pkg := range t.Packages {
+		var tests []*TestNode
+		for _, test := range pkg.Tests {
+			for _, tag := range test.Tags {
+				if wanted[tag] {
+					tests = append(tests, test)
+					break
+				}
+			}
+		}
+		if len(tests) > 0 {
+			pkgCopy := *pkg
+			pkgCopy.Tests = tests
+			filtered.Packages = append(filtered.Packages, &pkgCopy)
+		}
+	}
+	return filtered
+}
+
+// Discover builds the test tree for the configured directory without running anything
+func (r *Runner) Discover(ctx context.Context) (*TestTree, error) {
+	return r.discovery.DiscoverTree(ctx)
+}
+
+// DiscoverTree discovers tests and arranges them by package, including
+// subtest names that can be determined statically from t.Run calls
+func (d *Discovery) DiscoverTree(ctx context.Context) (*TestTree, error) {
+	tests, err := d.DiscoverTests(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := &TestTree{Root: d.dir}
+	modRoot, modPath := findModule(d.dir)
+	tree.Module = modPath
+	workspace, _ := FindWorkspace(d.dir) // DiscoverTests already warned
+	if workspace != nil {
+		tree.Workspace = workspace.File
+	}
+
+	packages := make(map[string]*PackageNode)
+	for _, test := range tests {
+		dir := filepath.Dir(test.File)
+		pkg, ok := packages[dir]
+		if !ok {
+			pkg = &PackageNode{
+				ImportPath: importPathFor(dir, modRoot, modPath, d.dir),
+				Name:       strings.TrimSuffix(test.Package, "_test"),
+				Dir:        dir,
+				Module:     modPath,
+			}
+			if workspace != nil {
+				// In a workspace, tests are found below the module directories
+				if module := workspace.ModuleFor(dir); module != nil {
+					pkg.ImportPath = importPathFor(dir, module.Dir, module.Path, module.Dir)
+					pkg.Module = module.Path
+				}
+			}
+			packages[dir] = pkg
+			tree.Packages = append(tree.Packages, pkg)
+		}
+		pkg.Tests = append(pkg.Tests, d.buildTestNode(test))
+	}
+
+	// Packages of a module stay together, so the module can head them
+	sort.SliceStable(tree.Packages, func(i, j int) bool {
+		a, b := tree.Packages[i], tree.Packages[j]
+		if a.Module != b.Module {
+			return a.Module < b.Module
+		}
+		return a.ImportPath < b.ImportPath
+	})
+
+	d.logger.Debug("🌳 Discovery tree: %d package(s), %d test(s), %d subtest(s)",
+		len(tree.Packages), tree.TestCount(), tree.SubtestCount())
+	return tree, nil
+}
+
+// buildTestNode converts a discovered test into a tree node with its subtests
+func (d *Discovery) buildTestNode(test *TestInfo) *TestNode {
+	node := &TestNode{
+		Name:     test.Name,
+		FullName: test.Name,
+		Kind:     testKind(test.Function),
+		File:     test.File,
+		Line:     test.Line,
+		Tags:     extractTestTags(test.Function.Doc),
+		info:     test,
+	}
+
+	if tParam := firstParamName(test.Function.Type); tParam != "" && test.Function.Body != nil {
+		scanner := &subtestScanner{discovery: d, file: test.File, tables: make(map[string]*subtestTable)}
+		scanner.scan(test.Function.Body, tParam, node, nil)
+	}
+	assignFullNames(node)
+
+	return node
+}
+
+// findModule walks up from dir to the nearest go.mod and returns its
+// directory and module path
+func findModule(dir string) (string, string) {
+	for current := dir; ; {
+		if data, err := os.ReadFile(filepath.Join(current, "go.mod")); err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				line = strings.TrimSpace(line)
+				if strings.HasPrefix(line, "module ") {
+					return current, strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module")), `"`)
+				}
+			}
+			return current, ""
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			return "", ""
+		}
+		current = parent
+	}
+}
+
+// importPathFor derives a package's import path from the module root, or a
+// ./relative path when no module is found
+func importPathFor(dir, modRoot, modPath, scanRoot string) string {
+	if modRoot != "" && modPath != "" {
+		if rel, err := filepath.Rel(modRoot, dir); err == nil && !strings.HasPrefix(rel, "..") {
+			if rel == "." {
+				return modPath
+			}
+			return modPath + "/" + filepath.ToSlash(rel)
+		}
+	}
+	if rel, err := filepath.Rel(scanRoot, dir); err == nil {
+		return "./" + filepath.ToSlash(rel)
+	}
+	return dir
+}
+
+// testKind classifies fn by its *testing.T/B/F parameter
+func testKind(fn *ast.FuncDecl) TestKind {
+	if fn.Type.Params != nil && len(fn.Type.Params.List) == 1 {
+		if star, ok := fn.Type.Params.List[0].Type.(*ast.StarExpr); ok {
+			if sel, ok := star.X.(*ast.SelectorExpr); ok {
+				switch sel.Sel.Name {
+				case "B":
+					return TestKindBenchmark
+				case "F":
+					return TestKindFuzz
+				}
+			}
+		}
+	}
+	return TestKindTest
+}
+
+// extractTestTags parses "// @tag" lines from a test's doc comment
+func extractTestTags(doc *ast.CommentGroup) []string {
+	if doc == nil {
+		return nil
+	}
+
+	var tags []string
+	for _, comment := range doc.List {
+		text := strings.TrimSpace(comment.Text)
+		if strings.HasPrefix(text, "// @") {
+			if tag := strings.TrimSpace(strings.TrimPrefix(text, "// @")); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// firstParamName returns the name of a function's single parameter, if any
+func firstParamName(fn *ast.FuncType) string {
+	if fn.Params == nil || len(fn.Params.List) != 1 || len(fn.Params.List[0].Names) != 1 {
+		return ""
+	}
+	name := fn.Params.List[0].Names[0].Name
+	if name == "_" {
+		return ""
+	}
+	return name
+}
+
+// subtestTable is a statically known test table: the per-row names of a
+// slice of structs (from nameField), or the string keys of a map
+type subtestTable struct {
+	names     []string // "" marks a row whose name is not a literal
+	nameField string
+	isMap     bool
+}
+
+// rangeBinding links range loop variables to the table being iterated
+type rangeBinding struct {
+	table    *subtestTable
+	keyVar   string
+	valueVar string
+}
+
+// subtestScanner walks a test body collecting t.Run calls. Table variables
+// are tracked by name per test function, which covers the usual
+// table-driven patterns without type checking.
+type subtestScanner struct {
+	discovery *Discovery
+	file      string
+	tables    map[string]*subtestTable
+}
+
+// scan records subtests of parent found in body, where tParam is the
+// *testing.T variable in scope and bindings are the enclosing range loops
+func (s *subtestScanner) scan(body ast.Node, tParam string, parent *TestNode, bindings []rangeBinding) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for i, lhs := range n.Lhs {
+				if ident, ok := lhs.(*ast.Ident); ok && i < len(n.Rhs) {
+					if table := tableFromExpr(n.Rhs[i]); table != nil {
+						s.tables[ident.Name] = table
+					}
+				}
+			}
+
+		case *ast.ValueSpec:
+			for i, name := range n.Names {
+				if i < len(n.Values) {
+					if table := tableFromExpr(n.Values[i]); table != nil {
+						s.tables[name.Name] = table
+					}
+				}
+			}
+
+		case *ast.RangeStmt:
+			table := tableFromExpr(n.X)
+			if ident, ok := n.X.(*ast.Ident); ok && table == nil {
+				table = s.tables[ident.Name]
+			}
+			if table == nil {
+				return true
+			}
+			binding := rangeBinding{table: table}
+			if key, ok := n.Key.(*ast.Ident); ok {
+				binding.keyVar = key.Name
+			}
+			if value, ok := n.Value.(*ast.Ident); ok {
+				binding.valueVar = value.Name
+			}
+			s.scan(n.Body, tParam, parent, append(bindings, binding))
+			return false
+
+		case *ast.CallExpr:
+			if !isRunCall(n, tParam) {
+				return true
+			}
+			s.addSubtests(n, parent, bindings)
+			return false
+		}
+		return true
+	})
+}
+
+// addSubtests adds the subtests created by a t.Run call to parent
+func (s *subtestScanner) addSubtests(call *ast.CallExpr, parent *TestNode, bindings []rangeBinding) {
+	names, ok := subtestNames(call.Args[0], bindings)
+	if !ok {
+		parent.Dynamic = true
+		return
+	}
+
+	line := s.discovery.fset.Position(call.Pos()).Line
+	for _, name := range names {
+		child := &TestNode{
+			Name: name,
+			Kind: parent.Kind,
+			File: s.file,
+			Line: line,
+		}
+
+		// Scan the subtest body once per expanded name so each copy gets its own nodes
+		if fn, ok := call.Args[1].(*ast.FuncLit); ok {
+			if tParam := firstParamName(fn.Type); tParam != "" {
+				s.scan(fn.Body, tParam, child, bindings)
+			}
+		}
+		parent.Subtests = append(parent.Subtests, child)
+	}
+}
+
+// isRunCall reports whether call is tParam.Run(name, fn)
+func isRunCall(call *ast.CallExpr, tParam string) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Run" || len(call.Args) != 2 {
+		return false
+	}
+	ident, ok := sel.X.(*ast.Ident)
+	return ok && ident.Name == tParam
+}
+
+// subtestNames resolves a t.Run name argument to one or more literal names
+func subtestNames(expr ast.Expr, bindings []rangeBinding) ([]string, bool) {
+	if name, ok := stringLiteral(expr); ok {
+		return []string{name}, true
+	}
+
+	// Innermost loop first, matching Go scoping
+	for i := len(bindings) - 1; i >= 0; i-- {
+		b := bindings[i]
+		var matched bool
+		switch e := expr.(type) {
+		case *ast.Ident:
+			matched = b.table.isMap && e.Name == b.keyVar
+		case *ast.SelectorExpr:
+			x, ok := e.X.(*ast.Ident)
+			matched = ok && !b.table.isMap && x.Name == b.valueVar && e.Sel.Name == b.table.nameField
+		}
+		if !matched {
+			continue
+		}
+		for _, name := range b.table.names {
+			if name == "" {
+				return nil, false
+			}
+		}
+		return b.table.names, true
+	}
+
+	return nil, false
+}
+
+// tableFromExpr extracts subtest names from a composite literal table:
+// a slice of structs with a name-like string field, or a map with string keys
+func tableFromExpr(expr ast.Expr) *subtestTable {
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return nil
+	}
+
+	switch typ := lit.Type.(type) {
+	case *ast.MapType:
+		table := &subtestTable{isMap: true}
+		for _, elt := range lit.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				return nil
+			}
+			name, _ := stringLiteral(kv.Key)
+			table.names = append(table.names, name)
+		}
+		return table
+
+	case *ast.ArrayType:
+		// Positional rows need the inline struct definition to locate the field
+		field, index := "", -1
+		if st, ok := typ.Elt.(*ast.StructType); ok {
+			field, index = nameFieldOf(st)
+		}
+
+		table := &subtestTable{nameField: field}
+		for _, elt := range lit.Elts {
+			row, ok := elt.(*ast.CompositeLit)
+			if !ok {
+				return nil
+			}
+			name := ""
+			for i, value := range row.Elts {
+				if kv, ok := value.(*ast.KeyValueExpr); ok {
+					if key, ok := kv.Key.(*ast.Ident); ok && isNameField(key.Name) && (field == "" || key.Name == field) {
+						table.nameField = key.Name
+						name, _ = stringLiteral(kv.Value)
+					}
+				} else if i == index {
+					name, _ = stringLiteral(value)
+				}
+			}
+			table.names = append(table.names, name)
+		}
+		if table.nameField == "" {
+			return nil
+		}
+		return table
+	}
+
+	return nil
+}
+
+// nameFieldOf returns the first name-like string field of an inline struct
+// and its positional index
+func nameFieldOf(st *ast.StructType) (string, int) {
+	index := 0
+	for _, field := range st.Fields.List {
+		ident, isIdent := field.Type.(*ast.Ident)
+		for _, name := range field.Names {
+			if isIdent && ident.Name == "string" && isNameField(name.Name) {
+				return name.Name, index
+			}
+			index++
+		}
+		if len(field.Names) == 0 {
+			index++
+		}
+	}
+	return "", -1
+}
+
+// isNameField reports whether a struct field conventionally names a test case
+func isNameField(name string) bool {
+	switch strings.ToLower(name) {
+	case "name", "testname", "desc", "description", "title", "scenario":
+		return true
+	}
+	return false
+}
+
+// stringLiteral returns the value of a string literal expression
+func stringLiteral(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok {
+		return "", false
+	}
+	value, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return "", false
+	}
+	return value, true
+}
+
+// assignFullNames rewrites subtest names the way the testing package does
+// (spaces to underscores, duplicate names suffixed #01, #02, ...) and fills
+// in FullName for the whole subtree
+func assignFullNames(node *TestNode) {
+	seen := make(map[string]int)
+	for _, child := range node.Subtests {
+		name := rewriteSubtestName(child.Name)
+		if count, ok := seen[name]; ok {
+			seen[name] = count + 1
+			name = fmt.Sprintf("%s#%02d", name, count+1)
+		} else {
+			seen[name] = 0
+		}
+		child.Name = name
+		child.FullName = node.FullName + "/" + name
+		assignFullNames(child)
+	}
+}
+
+// rewriteSubtestName mirrors the testing package's subtest name rewriting
+func rewriteSubtestName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case unicode.IsSpace(r):
+			b.WriteRune('_')
+		case !strconv.IsPrint(r):
+			s := strconv.QuoteRune(r)
+			b.WriteString(s[1 : len(s)-1])
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
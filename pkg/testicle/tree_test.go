@@ -0,0 +1,164 @@
+package testicle
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// findNode returns the node with the given full name, searching depth-first
+func findNode(nodes []*TestNode, fullName string) *TestNode {
+	for _, node := range nodes {
+		if node.FullName == fullName {
+			return node
+		}
+		if found := findNode(node.Subtests, fullName); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func TestDiscoverTree_Fixtures(t *testing.T) {
+	dir, err := filepath.Abs("tests/subtests")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree, err := NewDiscovery(dir, NewLogger(false)).DiscoverTree(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverTree failed: %v", err)
+	}
+
+	if tree.Module != "github.com/nzions/sharedgolibs" {
+		t.Errorf("Expected module github.com/nzions/sharedgolibs, got %q", tree.Module)
+	}
+	if len(tree.Packages) != 1 {
+		t.Fatalf("Expected 1 package, got %d", len(tree.Packages))
+	}
+	pkg := tree.Packages[0]
+	if pkg.ImportPath != "github.com/nzions/sharedgolibs/pkg/testicle/tests/subtests" {
+		t.Errorf("Unexpected import path %q", pkg.ImportPath)
+	}
+
+	for _, name := range []string{
+		"TestTableDriven/Empty_string",
+		"TestTableDriven/Unicode",
+		"TestNestedSubtests/StringOperations/ToUpper",
+		"TestNestedSubtests/NumberOperations/Addition",
+		"TestParallelSubtests/Large",
+	} {
+		if findNode(pkg.Tests, name) == nil {
+			t.Errorf("Expected subtest %s to be discovered", name)
+		}
+	}
+
+	// fmt.Sprintf names can only be known at runtime
+	if node := findNode(pkg.Tests, "TestDynamicSubtests"); node == nil || !node.Dynamic || len(node.Subtests) != 0 {
+		t.Errorf("Expected TestDynamicSubtests to be marked dynamic with no static subtests, got %+v", node)
+	}
+
+	if got := len(tree.Tests()); got != tree.TestCount() {
+		t.Errorf("Tests() returned %d, TestCount() %d", got, tree.TestCount())
+	}
+}
+
+func TestDiscoverTree_TablesAndTags(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod": "module example.com/demo\n\ngo 1.23\n",
+		"pkg/a/a_test.go": `package a_test
+
+import "testing"
+
+// TestMap uses a map table
+// @integration
+func TestMap(t *testing.T) {
+	cases := map[string]int{"one": 1, "two": 2}
+	for name, want := range cases {
+		t.Run(name, func(t *testing.T) { _ = want })
+	}
+}
+
+// @unit
+func TestKeyed(t *testing.T) {
+	tests := []struct {
+		input string
+		name  string
+	}{
+		{input: "x", name: "dup"},
+		{name: "dup", input: "y"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(sub *testing.T) {
+			sub.Run("inner", func(*testing.T) {})
+		})
+	}
+}
+
+func BenchmarkThing(b *testing.B) {
+	b.Run("fast path", func(b *testing.B) {})
+}
+`,
+	})
+
+	tree, err := NewDiscovery(dir, NewLogger(false)).DiscoverTree(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverTree failed: %v", err)
+	}
+
+	if tree.Module != "example.com/demo" || len(tree.Packages) != 1 {
+		t.Fatalf("Unexpected tree: module=%q packages=%d", tree.Module, len(tree.Packages))
+	}
+	pkg := tree.Packages[0]
+	if pkg.ImportPath != "example.com/demo/pkg/a" || pkg.Name != "a" {
+		t.Errorf("Unexpected package %q (%s)", pkg.ImportPath, pkg.Name)
+	}
+
+	for _, name := range []string{
+		"TestMap/one",
+		"TestMap/two",
+		"TestKeyed/dup",
+		"TestKeyed/dup#01",
+		"TestKeyed/dup#01/inner",
+		"BenchmarkThing/fast_path",
+	} {
+		if findNode(pkg.Tests, name) == nil {
+			t.Errorf("Expected %s to be discovered", name)
+		}
+	}
+
+	if node := findNode(pkg.Tests, "BenchmarkThing"); node == nil || node.Kind != TestKindBenchmark {
+		t.Errorf("Expected BenchmarkThing to be a benchmark, got %+v", node)
+	}
+
+	filtered := tree.FilterTags("integration")
+	if filtered.TestCount() != 1 || filtered.Packages[0].Tests[0].Name != "TestMap" {
+		t.Errorf("Expected only TestMap for @integration, got %d test(s)", filtered.TestCount())
+	}
+	if tree.FilterTags("missing").TestCount() != 0 {
+		t.Error("Expected no tests for an unknown tag")
+	}
+	if tree.FilterTags() != tree {
+		t.Error("Expected FilterTags with no tags to return the tree unchanged")
+	}
+}
+
+func TestRewriteSubtestName(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"plain", "plain"},
+		{"with spaces", "with_spaces"},
+		{"tab\there", "tab_here"},
+		{"bell\a", `bell\a`},
+		{"unicode ü", "unicode_ü"},
+	}
+
+	for _, tt := range tests {
+		if got := rewriteSubtestName(tt.input); got != tt.expected {
+			t.Errorf("rewriteSubtestName(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
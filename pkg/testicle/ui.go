@@ -25,6 +25,14 @@ type UIController struct {
 	testResults  []*TestResultLine
 	liveOutput   []string
 	maxOutput    int
+	prompt       *linePrompt // Line input replacing the controls while active
+}
+
+// linePrompt collects a line of text from raw-mode key presses
+type linePrompt struct {
+	label string
+	input []rune
+	done  func(string)
 }
 
 // TestResultLine represents a single test result for display
@@ -43,6 +51,7 @@ type DaemonStatus struct {
 	PassedCount  int       `json:"passed_count"`
 	FailedCount  int       `json:"failed_count"`
 	SkippedCount int       `json:"skipped_count"`
+	PolicyCount  int       `json:"policy_skipped_count"` // Skipped by the skip list
 	RunningCount int       `json:"running_count"`
 	QueuedCount  int       `json:"queued_count"`
 	Duration     string    `json:"duration"`
@@ -50,6 +59,13 @@ type DaemonStatus struct {
 	WatchedFiles int       `json:"watched_files"`
 	FileChanges  int       `json:"file_changes"`
 	ProgressPct  int       `json:"progress_pct"`
+
+	// Saved run artifacts, when Config.ArtifactsDir is set
+	ArtifactRuns  int   `json:"artifact_runs"`
+	ArtifactBytes int64 `json:"artifact_bytes"`
+
+	// Environment of the most recent run
+	Metadata *RunMetadata `json:"metadata,omitempty"`
 }
 
 // KeyHandler manages keyboard input for interactive controls
@@ -154,6 +170,7 @@ func (ui *UIController) UpdateTestResults(results *TestResults) {
 	ui.status.PassedCount = results.Passed
 	ui.status.FailedCount = results.Failed
 	ui.status.SkippedCount = results.Skipped
+	ui.status.PolicyCount = len(results.SkippedByPolicy)
 	ui.status.Duration = results.Duration.String()
 	ui.status.LastRun = time.Now()
 
@@ -210,6 +227,73 @@ func (ui *UIController) AddLiveOutput(message string) {
 	ui.renderFullScreen()
 }
 
+// ShowTree lists the discovered packages with their test and subtest counts
+// in the live output section
+func (ui *UIController) ShowTree(tree *TestTree) {
+	summary := fmt.Sprintf("%d package(s), %d test(s), %d subtest(s)", len(tree.Packages), tree.TestCount(), tree.SubtestCount())
+	if tree.Workspace != "" {
+		summary = fmt.Sprintf("%d module(s), %s", len(tree.Modules()), summary)
+	}
+	ui.AddLiveOutput("🌳 " + summary)
+
+	// In a workspace each module heads its packages
+	indent := "  "
+	if tree.Workspace != "" {
+		indent = "    "
+	}
+	for i, pkg := range tree.Packages {
+		if tree.Workspace != "" && (i == 0 || tree.Packages[i-1].Module != pkg.Module) {
+			ui.AddLiveOutput(fmt.Sprintf("  🌳 %s", moduleLabel(pkg.Module)))
+		}
+		ui.AddLiveOutput(fmt.Sprintf("%s📦 %s %s(%d tests, %d subtests)%s",
+			indent, pkg.ImportPath, colorDim(), len(pkg.Tests), pkg.SubtestCount(), colorReset()))
+	}
+}
+
+// moduleLabel names a module in listings; modules without a go.mod module
+// line have no path
+func moduleLabel(path string) string {
+	if path == "" {
+		return "(no module)"
+	}
+	return path
+}
+
+// Prompt asks for a line of input in place of the controls. Keys go to the
+// prompt until Enter calls done with the text, or Esc or Ctrl+C cancels.
+func (ui *UIController) Prompt(label string, done func(string)) {
+	ui.prompt = &linePrompt{label: label, done: done}
+	ui.renderFullScreen()
+}
+
+// HandlePromptKey feeds a key to the active prompt and reports whether
+// there was one
+func (ui *UIController) HandlePromptKey(key rune) bool {
+	prompt := ui.prompt
+	if prompt == nil {
+		return false
+	}
+
+	switch key {
+	case '\r', '\n':
+		ui.prompt = nil
+		prompt.done(string(prompt.input))
+	case '\x1b', '\x03': // Esc, Ctrl+C
+		ui.prompt = nil
+		ui.AddLiveOutput("Cancelled")
+	case '\x7f', '\b': // Backspace
+		if len(prompt.input) > 0 {
+			prompt.input = prompt.input[:len(prompt.input)-1]
+		}
+	default:
+		if key >= ' ' && key < '\x7f' { // Keys arrive as single bytes
+			prompt.input = append(prompt.input, key)
+		}
+	}
+	ui.renderFullScreen()
+	return true
+}
+
 // OnFileChange notifies the UI of a file change event
 func (ui *UIController) OnFileChange(filePath string) {
 	ui.status.FileChanges++
@@ -454,6 +538,9 @@ func (ui *UIController) renderStatus() {
 			if ui.status.SkippedCount > 0 {
 				fmt.Printf(" • %s%d skipped%s", colorYellow(), ui.status.SkippedCount, colorReset())
 			}
+			if ui.status.PolicyCount > 0 {
+				fmt.Printf(" • %s%d skipped by policy%s", colorMagenta(), ui.status.PolicyCount, colorReset())
+			}
 			if ui.status.Duration != "" {
 				fmt.Printf(" • %s%s%s", colorDim(), ui.status.Duration, colorReset())
 			}
@@ -467,7 +554,20 @@ func (ui *UIController) renderStatus() {
 		if ui.status.FileChanges > 0 {
 			fmt.Printf(" • %s%d changes detected%s", colorYellow(), ui.status.FileChanges, colorReset())
 		}
-		fmt.Print("\033[K\n\n")
+		fmt.Print("\033[K\n")
+
+		if filter := ui.runner.filter; filter != nil {
+			fmt.Printf("🔎 Filter: %s%s%s", colorCyan(), filter, colorReset())
+			fmt.Print("\033[K\n")
+		}
+
+		// Disk used by saved runs against the retention limit
+		if artifacts := ui.runner.artifacts; artifacts != nil {
+			fmt.Printf("💾 Artifacts: %s%s%s • %d run(s) • %s of %s", colorCyan(), artifacts.Dir(), colorReset(),
+				ui.status.ArtifactRuns, FormatByteSize(ui.status.ArtifactBytes), FormatByteSize(artifacts.MaxDisk()))
+			fmt.Print("\033[K\n")
+		}
+		fmt.Print("\033[K\n")
 	}
 }
 
@@ -551,7 +651,12 @@ func (ui *UIController) renderLiveOutput() {
 func (ui *UIController) renderControls() {
 	ui.moveCursor(24, 1)
 
-	fmt.Printf("%s[r] Run Now | [s] Stop | [p] Pause | [c] Clear | [q] Quit%s",
+	if ui.prompt != nil {
+		fmt.Printf("%s%s", ui.prompt.label, string(ui.prompt.input))
+		fmt.Print("\033[K")
+		return
+	}
+	fmt.Printf("%s[r] Run Now | [s] Stop | [p] Pause | [t] Tree | [x] Skip Failed | [f] Filter | [c] Clear | [q] Quit%s",
 		colorDim(), colorReset())
 	fmt.Print("\033[K")
 }
@@ -603,6 +708,7 @@ func (ui *UIController) renderMiniProgressBar(percent int) string {
 func colorReset() string   { return "\033[0m" }
 func colorBold() string    { return "\033[1m" }
 func colorDim() string     { return "\033[2m" }
+func colorReverse() string { return "\033[7m" }
 func colorRed() string     { return "\033[31m" }
 func colorGreen() string   { return "\033[32m" }
 func colorYellow() string  { return "\033[33m" }
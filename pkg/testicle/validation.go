@@ -261,8 +261,9 @@ func (vr *VetRunner) parseVetOutput(output, pkg string) []VetIssue {
 
 // parseVetLine parses a single line of go vet output
 func (vr *VetRunner) parseVetLine(line, pkg string) *VetIssue {
-	// Look for pattern: file.go:line:col: category: message
-	parts := strings.Split(line, ":")
+	// Look for pattern: file.go:line:col: category: message. Type errors
+	// are reported as "vet: file.go:line:col: message".
+	parts := strings.Split(strings.TrimPrefix(line, "vet: "), ":")
 	if len(parts) < 4 {
 		return nil
 	}
@@ -1,4 +1,4 @@
 package testicle
 
 // Version is the current version of the testicle package
-const Version = "v1.0.0"
+const Version = "v1.25.0"
@@ -2,35 +2,99 @@ package testicle
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
 
-// FileEvent represents a file system event
+// DefaultWatchDebounce is how long changes must settle before a re-run
+const DefaultWatchDebounce = 200 * time.Millisecond
+
+// DefaultWatchInclude are the files whose changes trigger a re-run
+var DefaultWatchInclude = []string{"*.go", "go.mod", "go.sum", "go.work", "go.work.sum"}
+
+// DefaultWatchExclude are always ignored: hidden files and directories such
+// as .git, dependency trees, test fixtures and outputs, generated code, and
+// editor temporary files
+var DefaultWatchExclude = []string{
+	".*", "vendor", "node_modules", "testdata",
+	"*.gen.go", "*_gen.go", "*.pb.go",
+	"*~", "*.swp", "*.swx", "*.tmp", "#*#",
+}
+
+// WatchConfig tunes which file changes re-run tests in watch mode. Patterns
+// without a slash match any path element (like .gitignore); patterns with a
+// slash match the path relative to the test directory, where ** matches any
+// number of directories.
+type WatchConfig struct {
+	Include  []string `yaml:"include"`                    // Replaces DefaultWatchInclude
+	Exclude  []string `yaml:"exclude"`                    // Added to DefaultWatchExclude
+	Debounce string   `yaml:"debounce" schema:"duration"` // Go duration (default 200ms)
+}
+
+// FileEvent represents a batch of file system changes
 type FileEvent struct {
-	Path string
-	Op   string
+	Path  string // First changed file
+	Op    string
+	Paths []string // Every file changed in the batch
 }
 
 // Watcher handles file system watching for daemon mode
 type Watcher struct {
-	dir     string
-	logger  *Logger
-	watcher *fsnotify.Watcher
+	dir      string
+	roots    []string // Watched along with dir, e.g. the rest of a workspace
+	logger   *Logger
+	watcher  *fsnotify.Watcher
+	include  []string
+	exclude  []string
+	debounce time.Duration
 }
 
-// NewWatcher creates a new file watcher
+// NewWatcher creates a new file watcher with the default patterns
 func NewWatcher(dir string, logger *Logger) *Watcher {
 	return &Watcher{
-		dir:    dir,
-		logger: logger,
+		dir:      dir,
+		logger:   logger,
+		include:  DefaultWatchInclude,
+		exclude:  DefaultWatchExclude,
+		debounce: DefaultWatchDebounce,
 	}
 }
 
+// Configure applies include and exclude patterns and the debounce period
+func (w *Watcher) Configure(config WatchConfig) error {
+	for _, pattern := range append(append([]string{}, config.Include...), config.Exclude...) {
+		if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+			return fmt.Errorf("invalid watch pattern %q: %w", pattern, err)
+		}
+	}
+	if config.Debounce != "" {
+		debounce, err := time.ParseDuration(config.Debounce)
+		if err != nil || debounce < 0 {
+			return fmt.Errorf("invalid watch debounce %q", config.Debounce)
+		}
+		w.debounce = debounce
+	}
+	if len(config.Include) > 0 {
+		w.include = config.Include
+	}
+	w.exclude = append(append([]string{}, DefaultWatchExclude...), config.Exclude...)
+	return nil
+}
+
+// AddRoots watches dirs along with the test directory, such as the other
+// modules of a workspace. Directories inside the test directory or another
+// root are watched once.
+func (w *Watcher) AddRoots(dirs ...string) {
+	w.roots = append(w.roots, dirs...)
+}
+
 // Start begins watching for file changes
 func (w *Watcher) Start(ctx context.Context) (<-chan FileEvent, error) {
 	var err error
@@ -39,37 +103,26 @@ func (w *Watcher) Start(ctx context.Context) (<-chan FileEvent, error) {
 		return nil, err
 	}
 
-	// Walk the directory tree and add all directories to the watcher
-	err = filepath.Walk(w.dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Skip hidden directories and files
-		if strings.HasPrefix(filepath.Base(path), ".") {
-			if info.IsDir() {
+	// Walk the directory trees and add all directories that are not excluded
+	for _, root := range w.watchRoots() {
+		err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				return nil
+			}
+			if path != root && w.isExcluded(path) {
 				return filepath.SkipDir
 			}
-			return nil
-		}
 
-		// Skip vendor directories
-		if strings.Contains(path, "/vendor/") || strings.Contains(path, "\\vendor\\") {
-			return filepath.SkipDir
-		}
-
-		// Add directories to watcher
-		if info.IsDir() {
 			w.logger.Debug("👀 Watching directory: %s", path)
 			return w.watcher.Add(path)
+		})
+		if err != nil {
+			w.watcher.Close()
+			return nil, err
 		}
-
-		return nil
-	})
-
-	if err != nil {
-		w.watcher.Close()
-		return nil, err
 	}
 
 	eventChan := make(chan FileEvent, 10)
@@ -77,19 +130,20 @@ func (w *Watcher) Start(ctx context.Context) (<-chan FileEvent, error) {
 	// Start the event processing goroutine
 	go w.processEvents(ctx, eventChan)
 
-	w.logger.Debug("👀 File watcher started for %s", w.dir)
+	w.logger.Debug("👀 File watcher started for %s (debounce %s)", w.dir, w.debounce)
 	return eventChan, nil
 }
 
-// processEvents processes file system events and filters relevant ones
+// processEvents filters file system events and sends each burst of changes
+// as one event once no change has arrived for the debounce period
 func (w *Watcher) processEvents(ctx context.Context, eventChan chan<- FileEvent) {
 	defer close(eventChan)
 	defer w.watcher.Close()
 
-	// Debouncing mechanism
-	eventMap := make(map[string]time.Time)
-	ticker := time.NewTicker(200 * time.Millisecond)
-	defer ticker.Stop()
+	pending := make(map[string]bool)
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+	defer timer.Stop()
 
 	for {
 		select {
@@ -102,11 +156,20 @@ func (w *Watcher) processEvents(ctx context.Context, eventChan chan<- FileEvent)
 				return
 			}
 
-			// Filter relevant file events
-			if w.isRelevantFile(event.Name) {
-				w.logger.Debug("📁 File event: %s %s", event.Op, event.Name)
-				eventMap[event.Name] = time.Now()
+			// Watch directories created after startup
+			if event.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() && !w.isExcluded(event.Name) {
+					w.watcher.Add(event.Name)
+					continue
+				}
+			}
+
+			if !w.isRelevantFile(event.Name) {
+				continue
 			}
+			w.logger.Debug("📁 File event: %s %s", event.Op, event.Name)
+			pending[event.Name] = true
+			timer.Reset(w.debounce)
 
 		case err, ok := <-w.watcher.Errors:
 			if !ok {
@@ -114,18 +177,21 @@ func (w *Watcher) processEvents(ctx context.Context, eventChan chan<- FileEvent)
 			}
 			w.logger.Error("File watcher error: %v", err)
 
-		case <-ticker.C:
-			// Process debounced events
-			now := time.Now()
-			for path, eventTime := range eventMap {
-				if now.Sub(eventTime) > 100*time.Millisecond {
-					select {
-					case eventChan <- FileEvent{Path: path, Op: "modified"}:
-					case <-ctx.Done():
-						return
-					}
-					delete(eventMap, path)
-				}
+		case <-timer.C:
+			paths := make([]string, 0, len(pending))
+			for path := range pending {
+				paths = append(paths, path)
+			}
+			if len(paths) == 0 {
+				continue
+			}
+			sort.Strings(paths)
+			clear(pending)
+
+			select {
+			case eventChan <- FileEvent{Path: paths[0], Op: "modified", Paths: paths}:
+			case <-ctx.Done():
+				return
 			}
 		}
 	}
@@ -133,29 +199,102 @@ func (w *Watcher) processEvents(ctx context.Context, eventChan chan<- FileEvent)
 
 // isRelevantFile checks if a file change should trigger test re-run
 func (w *Watcher) isRelevantFile(filename string) bool {
-	// Only watch Go files
-	if !strings.HasSuffix(filename, ".go") {
+	if w.isExcluded(filename) {
 		return false
 	}
+	rel := w.relative(filename)
+	for _, pattern := range w.include {
+		if matchWatchPattern(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
 
-	// Skip hidden files
-	if strings.HasPrefix(filepath.Base(filename), ".") {
-		return false
+// isExcluded reports whether a file or directory matches an exclude pattern
+func (w *Watcher) isExcluded(filename string) bool {
+	rel := w.relative(filename)
+	for _, pattern := range w.exclude {
+		if matchWatchPattern(pattern, rel) {
+			return true
+		}
 	}
+	return false
+}
 
-	// Skip vendor directories
-	if strings.Contains(filename, "/vendor/") || strings.Contains(filename, "\\vendor\\") {
-		return false
+// watchRoots returns the test directory and the added roots, leaving out
+// duplicates and directories inside another root
+func (w *Watcher) watchRoots() []string {
+	all := append([]string{w.dir}, w.roots...)
+	var roots []string
+	for i, root := range all {
+		keep := true
+		for j, other := range all {
+			if i == j || !isWithin(absPath(root), absPath(other)) {
+				continue
+			}
+			// Of two equal roots the first is kept
+			if absPath(root) != absPath(other) || j < i {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+// relative returns filename relative to the test directory, or to the root
+// containing it, with slashes
+func (w *Watcher) relative(filename string) string {
+	for _, root := range append([]string{w.dir}, w.roots...) {
+		if rel, err := filepath.Rel(absPath(root), absPath(filename)); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
 	}
+	return filepath.ToSlash(filename)
+}
 
-	// Skip generated files (common patterns)
-	if strings.Contains(filename, ".gen.go") ||
-		strings.Contains(filename, "_gen.go") ||
-		strings.Contains(filename, ".pb.go") {
+// matchWatchPattern matches a slash-separated relative path. A pattern
+// without a slash matches any element of the path, so "vendor" covers
+// everything below a vendor directory; otherwise the pattern is matched
+// element by element from the root, with ** matching any number of elements.
+func matchWatchPattern(pattern, rel string) bool {
+	elements := strings.Split(rel, "/")
+	if !strings.Contains(pattern, "/") {
+		for _, element := range elements {
+			if ok, _ := path.Match(pattern, element); ok {
+				return true
+			}
+		}
 		return false
 	}
+	return matchElements(strings.Split(strings.Trim(pattern, "/"), "/"), elements)
+}
 
-	return true
+// matchElements matches pattern elements against path elements. A match of
+// a leading part of the path counts, so a pattern can name a directory.
+func matchElements(pattern, elements []string) bool {
+	if len(pattern) == 0 {
+		return true
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(elements); i++ {
+			if matchElements(pattern[1:], elements[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(elements) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], elements[0]); !ok {
+		return false
+	}
+	return matchElements(pattern[1:], elements[1:])
 }
 
 // Stop stops the file watcher
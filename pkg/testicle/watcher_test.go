@@ -0,0 +1,82 @@
+package testicle
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcherPatterns(t *testing.T) {
+	w := NewWatcher("/repo", NewLogger(false))
+	if err := w.Configure(WatchConfig{Exclude: []string{"internal/**/mocks", "*_mock.go"}}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		relevant bool
+	}{
+		{"/repo/pkg/a/a.go", true},
+		{"/repo/go.mod", true},
+		{"/repo/README.md", false},
+		{"/repo/.git/index", false},
+		{"/repo/pkg/.a.go.swp", false},
+		{"/repo/pkg/a/a.go~", false},
+		{"/repo/vendor/x/x.go", false},
+		{"/repo/pkg/testdata/fixture.go", false},
+		{"/repo/api/api.pb.go", false},
+		{"/repo/internal/store/mocks/store.go", false},
+		{"/repo/internal/store/store.go", true},
+		{"/repo/pkg/a/client_mock.go", false},
+	}
+	for _, tt := range tests {
+		if got := w.isRelevantFile(tt.path); got != tt.relevant {
+			t.Errorf("isRelevantFile(%s) = %v, want %v", tt.path, got, tt.relevant)
+		}
+	}
+
+	if err := w.Configure(WatchConfig{Include: []string{"[bad"}}); err == nil {
+		t.Error("Expected an invalid pattern to fail")
+	}
+	if err := w.Configure(WatchConfig{Debounce: "soon"}); err == nil {
+		t.Error("Expected an invalid debounce to fail")
+	}
+}
+
+func TestWatcherDebounce(t *testing.T) {
+	dir := t.TempDir()
+	w := NewWatcher(dir, NewLogger(false))
+	if err := w.Configure(WatchConfig{Debounce: "100ms"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := w.Start(ctx)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	// A burst of writes, including an editor swap file, is one event
+	for _, name := range []string{"a.go", "b.go", ".a.go.swp", "a.go"} {
+		os.WriteFile(filepath.Join(dir, name), []byte("package x\n"), 0644)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case event := <-events:
+		if len(event.Paths) != 2 || event.Path != filepath.Join(dir, "a.go") {
+			t.Errorf("Expected one event for a.go and b.go, got %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a file event")
+	}
+
+	select {
+	case event := <-events:
+		t.Errorf("Expected no further events, got %+v", event)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
@@ -0,0 +1,137 @@
+package testicle
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Workspace is a go.work file and the modules it uses. When the test
+// directory is inside a workspace, discovery and watch mode cover every
+// module in it rather than only the directory tree below the test directory.
+type Workspace struct {
+	File    string    // Path of the go.work file
+	Modules []*Module // Sorted by directory
+}
+
+// Module is a Go module found by discovery
+type Module struct {
+	Path string `json:"path"` // Module path from go.mod; empty if it has none
+	Dir  string `json:"dir"`
+}
+
+// FindWorkspace returns the workspace dir belongs to, found the way the go
+// command finds it: $GOWORK if set ("off" disables workspaces), otherwise
+// the nearest go.work in dir or a parent. It returns nil without a
+// workspace.
+func FindWorkspace(dir string) (*Workspace, error) {
+	file := os.Getenv("GOWORK")
+	switch file {
+	case "off":
+		return nil, nil
+	case "":
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		file = findUp(dir, "go.work")
+		if file == "" {
+			return nil, nil
+		}
+	}
+	return loadWorkspace(file)
+}
+
+// loadWorkspace reads the use directives of a go.work file. Modules that no
+// longer exist are left out, as go would fail on them anyway.
+func loadWorkspace(file string) (*Workspace, error) {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading workspace: %w", err)
+	}
+
+	workspace := &Workspace{File: file}
+	seen := make(map[string]bool)
+	for _, use := range parseWorkUses(string(data)) {
+		dir := filepath.FromSlash(use)
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(filepath.Dir(file), dir)
+		}
+		dir = filepath.Clean(dir)
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+
+		root, path := findModule(dir)
+		if root != dir {
+			continue // No go.mod in dir
+		}
+		workspace.Modules = append(workspace.Modules, &Module{Path: path, Dir: dir})
+	}
+	sort.Slice(workspace.Modules, func(i, j int) bool {
+		return workspace.Modules[i].Dir < workspace.Modules[j].Dir
+	})
+	return workspace, nil
+}
+
+// parseWorkUses returns the directories of the use directives in a go.work
+// file, in both the single-line and the block form
+func parseWorkUses(data string) []string {
+	var uses []string
+	inBlock := false
+	for _, line := range strings.Split(data, "\n") {
+		if idx := strings.Index(line, "//"); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+
+		switch {
+		case inBlock && line == ")":
+			inBlock = false
+		case inBlock && line != "":
+			uses = append(uses, strings.Trim(line, "\"`"))
+		case line == "use (" || line == "use(":
+			inBlock = true
+		case strings.HasPrefix(line, "use "):
+			uses = append(uses, strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "use ")), "\"`"))
+		}
+	}
+	return uses
+}
+
+// Dir returns the directory of the go.work file
+func (w *Workspace) Dir() string {
+	return filepath.Dir(w.File)
+}
+
+// ModuleFor returns the innermost workspace module containing dir, or nil
+func (w *Workspace) ModuleFor(dir string) *Module {
+	var found *Module
+	for _, module := range w.Modules {
+		if isWithin(dir, module.Dir) && (found == nil || len(module.Dir) > len(found.Dir)) {
+			found = module
+		}
+	}
+	return found
+}
+
+// isWithin reports whether path is dir or below it
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// absPath returns the absolute form of path, or path itself if that fails
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
@@ -0,0 +1,148 @@
+package testicle
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeFiles creates files, by slash-separated path below dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// writeWorkspace creates a workspace of modules api and tools/lint, with a
+// nested module api/examples that is not part of it
+func writeWorkspace(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.work":                           "go 1.21\n\n// Local modules\nuse (\n\t./api\n\t\"./tools/lint\" // linters\n)\n",
+		"api/go.mod":                        "module example.com/api\n\ngo 1.21\n",
+		"api/api.go":                        "package api\n\nfunc Version() string { return \"v1\" }\n",
+		"api/api_test.go":                   "package api\n\nimport \"testing\"\n\nfunc TestVersion(t *testing.T) {\n\tif Version() != \"v1\" {\n\t\tt.Fail()\n\t}\n}\n",
+		"api/examples/go.mod":               "module example.com/examples\n\ngo 1.21\n",
+		"api/examples/example_test.go":      "package examples\n\nimport \"testing\"\n\nfunc TestExample(t *testing.T) {}\n",
+		"tools/lint/go.mod":                 "module example.com/lint\n\ngo 1.21\n\nrequire example.com/api v0.0.0\n",
+		"tools/lint/rules/rules_test.go":    "package rules\n\nimport (\n\t\"testing\"\n\n\t\"example.com/api\"\n)\n\nfunc TestUsesAPI(t *testing.T) {\n\tif api.Version() == \"\" {\n\t\tt.Fail()\n\t}\n}\n",
+		"tools/lint/rules/rules_helper.go":  "package rules\n",
+		"notamodule/stray_test.go":          "package stray\n\nimport \"testing\"\n\nfunc TestStray(t *testing.T) {}\n",
+		"tools/lint/rules/testdata/keep.go": "package testdata\n",
+	}
+	writeFiles(t, dir, files)
+	return dir
+}
+
+func TestFindWorkspace(t *testing.T) {
+	t.Setenv("GOWORK", "")
+	dir := writeWorkspace(t)
+	file, err := os.OpenFile(filepath.Join(dir, "go.work"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("use ./missing\n") // Left out: it has no go.mod
+	file.Close()
+
+	workspace, err := FindWorkspace(filepath.Join(dir, "api"))
+	if err != nil || workspace == nil {
+		t.Fatalf("Expected the workspace to be found from a module, got %v, %v", workspace, err)
+	}
+	want := []*Module{
+		{Path: "example.com/api", Dir: filepath.Join(dir, "api")},
+		{Path: "example.com/lint", Dir: filepath.Join(dir, "tools", "lint")},
+	}
+	if workspace.File != filepath.Join(dir, "go.work") || !reflect.DeepEqual(workspace.Modules, want) {
+		t.Errorf("Unexpected workspace %s: %+v", workspace.File, workspace.Modules)
+	}
+	if module := workspace.ModuleFor(filepath.Join(dir, "tools", "lint", "rules")); module == nil || module.Path != "example.com/lint" {
+		t.Errorf("Expected rules to belong to example.com/lint, got %+v", module)
+	}
+	if module := workspace.ModuleFor(filepath.Join(dir, "tools")); module != nil {
+		t.Errorf("Expected tools to belong to no module, got %+v", module)
+	}
+
+	t.Setenv("GOWORK", "off")
+	if workspace, err := FindWorkspace(dir); workspace != nil || err != nil {
+		t.Errorf("Expected GOWORK=off to disable the workspace, got %+v, %v", workspace, err)
+	}
+}
+
+func TestDiscoverWorkspace(t *testing.T) {
+	t.Setenv("GOWORK", "")
+	dir := writeWorkspace(t)
+
+	// Discovering from one module covers its sibling too
+	tree, err := NewDiscovery(filepath.Join(dir, "api"), NewLogger(false)).DiscoverTree(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverTree failed: %v", err)
+	}
+	if tree.Workspace != filepath.Join(dir, "go.work") {
+		t.Errorf("Expected the workspace in the tree, got %q", tree.Workspace)
+	}
+	var got []string
+	for _, pkg := range tree.Packages {
+		got = append(got, pkg.Module+" "+pkg.ImportPath)
+	}
+	want := []string{"example.com/api example.com/api", "example.com/lint example.com/lint/rules"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected packages %v, got %v", want, got)
+	}
+	if modules := tree.Modules(); !reflect.DeepEqual(modules, []string{"example.com/api", "example.com/lint"}) {
+		t.Errorf("Unexpected modules %v", modules)
+	}
+
+	if testing.Short() {
+		return
+	}
+
+	// Each package runs in its module, resolving example.com/api through
+	// the workspace. Workspaces reject -mod=mod, so a GOFLAGS default
+	// from go env -w must not leak in.
+	t.Setenv("GOFLAGS", "-mod=readonly")
+	executor := NewExecutor(NewLogger(false))
+	executor.SetResultCallback(func(*TestResult) {})
+	results, err := executor.ExecuteTests(context.Background(), tree.Tests())
+	if err != nil {
+		t.Fatalf("ExecuteTests failed: %v", err)
+	}
+	if results.Passed != 2 || results.Failed != 0 {
+		for _, result := range results.Tests {
+			t.Logf("%s: %s %s", result.Name, result.Status, result.Error)
+		}
+		t.Errorf("Expected both workspace tests to pass, got %d passed, %d failed", results.Passed, results.Failed)
+	}
+}
+
+func TestWatcherWorkspaceRoots(t *testing.T) {
+	w := NewWatcher("/work/api", NewLogger(false))
+	w.AddRoots("/work", "/work/api", "/work/tools/lint", "/elsewhere/mod", "/elsewhere/mod")
+	if roots := w.watchRoots(); !reflect.DeepEqual(roots, []string{"/work", "/elsewhere/mod"}) {
+		t.Errorf("Unexpected watch roots %v", roots)
+	}
+
+	// Slash patterns stay relative to the test directory where they apply
+	if err := w.Configure(WatchConfig{Exclude: []string{"internal/**"}}); err != nil {
+		t.Fatal(err)
+	}
+	for path, relevant := range map[string]bool{
+		"/work/api/internal/x.go":  false,
+		"/work/go.work":            true,
+		"/work/tools/lint/lint.go": true,
+		"/elsewhere/mod/go.mod":    true,
+	} {
+		if got := w.isRelevantFile(path); got != relevant {
+			t.Errorf("isRelevantFile(%s) = %v, want %v", path, got, relevant)
+		}
+	}
+}
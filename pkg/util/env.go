@@ -5,7 +5,7 @@ package util
 import "os"
 
 // Version is the current version of the util package
-const Version = "0.1.0"
+const Version = "0.4.0"
 
 // MustGetEnv returns the value of the environment variable named by key.
 // If the variable is not set or empty, returns the fallback value.
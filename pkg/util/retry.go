@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls how Retry spaces and limits attempts. Unset delays
+// and Multiplier take the values from DefaultRetryPolicy; a zero
+// MaxAttempts retries until the context is done and a zero Jitter waits
+// exactly the backoff delay.
+type RetryPolicy struct {
+	MaxAttempts  int                  // Attempts including the first; 0 is unlimited
+	InitialDelay time.Duration        // Delay after the first failure
+	MaxDelay     time.Duration        // Upper bound for any delay
+	Multiplier   float64              // Delay growth per failed attempt
+	Jitter       float64              // Fraction of each delay that is randomized, 0 to 1
+	RetryIf      func(err error) bool // Errors worth retrying; nil retries every error
+}
+
+// DefaultRetryPolicy makes up to 5 attempts, doubling the delay from 100ms
+// up to 5s with 20% jitter
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:  5,
+		InitialDelay: 100 * time.Millisecond,
+		MaxDelay:     5 * time.Second,
+		Multiplier:   2,
+		Jitter:       0.2,
+	}
+}
+
+// Retry calls fn until it succeeds, returns an error RetryIf rejects, the
+// attempts run out, or ctx is done, waiting with exponential backoff
+// between attempts. The returned error wraps fn's last error. Example usage:
+//
+//	err := util.Retry(ctx, util.DefaultRetryPolicy(), func(ctx context.Context) error {
+//		return client.Ping(ctx)
+//	})
+func Retry(ctx context.Context, policy RetryPolicy, fn func(ctx context.Context) error) error {
+	policy = policy.withDefaults()
+
+	delay := min(policy.InitialDelay, policy.MaxDelay)
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		if policy.RetryIf != nil && !policy.RetryIf(err) {
+			return err
+		}
+		if policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		timer := time.NewTimer(policy.jitter(delay))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w after %d attempts: %w", ctx.Err(), attempt, err)
+		case <-timer.C:
+		}
+		delay = min(time.Duration(float64(delay)*policy.Multiplier), policy.MaxDelay)
+	}
+}
+
+// DefaultWaitInterval is the WaitFor interval used for a non-positive one
+const DefaultWaitInterval = 100 * time.Millisecond
+
+// WaitFor calls cond immediately and then every interval until it returns
+// true, returning ctx.Err() if ctx is done first. Example usage:
+//
+//	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+//	defer cancel()
+//	err := util.WaitFor(ctx, 100*time.Millisecond, func() bool { return ready(port) })
+func WaitFor(ctx context.Context, interval time.Duration, cond func() bool) error {
+	if cond() {
+		return nil
+	}
+
+	if interval <= 0 {
+		interval = DefaultWaitInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if cond() {
+				return nil
+			}
+		}
+	}
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	defaults := DefaultRetryPolicy()
+	if p.InitialDelay <= 0 {
+		p.InitialDelay = defaults.InitialDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = max(defaults.MaxDelay, p.InitialDelay)
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = defaults.Multiplier
+	}
+	p.Jitter = min(max(p.Jitter, 0), 1)
+	return p
+}
+
+// jitter randomizes the policy's fraction of delay, keeping the mean delay
+func (p RetryPolicy) jitter(delay time.Duration) time.Duration {
+	if p.Jitter == 0 {
+		return delay
+	}
+	spread := float64(delay) * p.Jitter
+	return time.Duration(float64(delay) - spread + rand.Float64()*2*spread)
+}
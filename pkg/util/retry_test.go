@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package util
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errTransient = errors.New("transient")
+
+func fastPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 4, InitialDelay: time.Millisecond, MaxDelay: 4 * time.Millisecond}
+}
+
+func TestRetry(t *testing.T) {
+	t.Run("succeeds after failures", func(t *testing.T) {
+		calls := 0
+		err := Retry(context.Background(), fastPolicy(), func(ctx context.Context) error {
+			calls++
+			if calls < 3 {
+				return errTransient
+			}
+			return nil
+		})
+		if err != nil || calls != 3 {
+			t.Errorf("Retry = %v after %d calls, want nil after 3", err, calls)
+		}
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		calls := 0
+		err := Retry(context.Background(), fastPolicy(), func(ctx context.Context) error {
+			calls++
+			return errTransient
+		})
+		if calls != 4 || !errors.Is(err, errTransient) {
+			t.Errorf("Retry = %v after %d calls, want errTransient after 4", err, calls)
+		}
+	})
+
+	t.Run("stops on errors RetryIf rejects", func(t *testing.T) {
+		errFatal := errors.New("fatal")
+		policy := fastPolicy()
+		policy.RetryIf = func(err error) bool { return errors.Is(err, errTransient) }
+		calls := 0
+		err := Retry(context.Background(), policy, func(ctx context.Context) error {
+			calls++
+			if calls == 2 {
+				return errFatal
+			}
+			return errTransient
+		})
+		if calls != 2 || err != errFatal {
+			t.Errorf("Retry = %v after %d calls, want errFatal after 2", err, calls)
+		}
+	})
+
+	t.Run("MaxDelay bounds the first delay", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		policy := RetryPolicy{MaxAttempts: 2, InitialDelay: 10 * time.Second, MaxDelay: time.Millisecond}
+		err := Retry(ctx, policy, func(ctx context.Context) error { return errTransient })
+		if ctx.Err() != nil || !errors.Is(err, errTransient) {
+			t.Errorf("Expected to give up after a 1ms delay, got %v", err)
+		}
+	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		policy := RetryPolicy{InitialDelay: 5 * time.Millisecond}
+		err := Retry(ctx, policy, func(ctx context.Context) error { return errTransient })
+		if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, errTransient) {
+			t.Errorf("Expected the deadline and last error, got %v", err)
+		}
+	})
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialDelay: 10 * time.Millisecond, MaxDelay: 15 * time.Millisecond, Jitter: 0.5}.withDefaults()
+	if policy.Multiplier != 2 {
+		t.Errorf("Expected the default multiplier, got %v", policy.Multiplier)
+	}
+	for i := 0; i < 100; i++ {
+		if d := policy.jitter(10 * time.Millisecond); d < 5*time.Millisecond || d > 15*time.Millisecond {
+			t.Fatalf("jitter(10ms) = %s, want within 50%%", d)
+		}
+	}
+	if d := (RetryPolicy{}).jitter(time.Second); d != time.Second {
+		t.Errorf("Expected no jitter by default, got %s", d)
+	}
+	if p := (RetryPolicy{InitialDelay: time.Minute}).withDefaults(); p.MaxDelay != time.Minute {
+		t.Errorf("Expected MaxDelay to be at least InitialDelay, got %s", p.MaxDelay)
+	}
+}
+
+func TestWaitFor(t *testing.T) {
+	checks := 0
+	err := WaitFor(context.Background(), time.Millisecond, func() bool {
+		checks++
+		return checks == 3
+	})
+	if err != nil || checks != 3 {
+		t.Errorf("WaitFor = %v after %d checks, want nil after 3", err, checks)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := WaitFor(ctx, time.Millisecond, func() bool { return false }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+
+	// A non-positive interval polls at DefaultWaitInterval
+	checks = 0
+	if err := WaitFor(context.Background(), 0, func() bool { checks++; return checks == 2 }); err != nil || checks != 2 {
+		t.Errorf("WaitFor with no interval = %v after %d checks, want nil after 2", err, checks)
+	}
+}
@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package semver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Constraint is a set of version ranges, such as ">= 1.4, < 2" or
+// "^1.2 || ~2.0.3". Comma-separated terms must all hold, and any of the
+// "||"-separated alternatives may. Terms are a version with an optional
+// operator:
+//   - =, != (or none for =): equal or not equal
+//   - >, >=, <, <=: ordered comparisons
+//   - ~1.4.2: same minor version, at least the patch (>= 1.4.2, < 1.5.0);
+//     ~1 allows any 1.x
+//   - ^1.4.2: no breaking change (>= 1.4.2, < 2.0.0); below 1.0.0 the
+//     first non-zero number can't change
+//   - *: any version
+type Constraint struct {
+	text         string
+	alternatives [][]term
+}
+
+// term is a single comparison of a constraint
+type term struct {
+	op      string
+	version Version
+}
+
+// ParseConstraint parses a constraint such as ">= 1.4, < 2"
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{text: strings.TrimSpace(s)}
+	for _, alternative := range strings.Split(s, "||") {
+		var terms []term
+		for _, text := range strings.Split(alternative, ",") {
+			parsed, err := parseTerm(strings.TrimSpace(text))
+			if err != nil {
+				return nil, fmt.Errorf("%w %q: %v", ErrInvalidConstraint, s, err)
+			}
+			terms = append(terms, parsed...)
+		}
+		c.alternatives = append(c.alternatives, terms)
+	}
+	return c, nil
+}
+
+// MustParseConstraint is like ParseConstraint but panics on an invalid
+// constraint, for constants
+func MustParseConstraint(s string) *Constraint {
+	c, err := ParseConstraint(s)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// parseTerm parses one term, expanding ~ and ^ into a range
+func parseTerm(text string) ([]term, error) {
+	if text == "" {
+		return nil, fmt.Errorf("empty term")
+	}
+	if text == "*" {
+		return nil, nil
+	}
+
+	op := ""
+	for _, candidate := range []string{">=", "<=", "!=", "==", ">", "<", "=", "~", "^"} {
+		if rest, ok := strings.CutPrefix(text, candidate); ok {
+			op, text = candidate, strings.TrimSpace(rest)
+			break
+		}
+	}
+	v, err := Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "", "==":
+		op = "="
+	case "~":
+		upper := Version{Major: v.Major + 1}
+		if specifiedParts(text) > 1 {
+			upper = Version{Major: v.Major, Minor: v.Minor + 1}
+		}
+		return []term{{">=", v}, {"<", upper}}, nil
+	case "^":
+		var upper Version
+		switch {
+		case v.Major > 0:
+			upper = Version{Major: v.Major + 1}
+		case v.Minor > 0:
+			upper = Version{Minor: v.Minor + 1}
+		default:
+			upper = Version{Patch: v.Patch + 1}
+		}
+		return []term{{">=", v}, {"<", upper}}, nil
+	}
+	return []term{{op, v}}, nil
+}
+
+// specifiedParts counts the numbers given in a version, so ~1 and ~1.0 can
+// differ
+func specifiedParts(text string) int {
+	numbers, _, _ := strings.Cut(text, "-")
+	numbers, _, _ = strings.Cut(numbers, "+")
+	return strings.Count(numbers, ".") + 1
+}
+
+// Check reports whether v satisfies the constraint
+func (c *Constraint) Check(v Version) bool {
+	for _, terms := range c.alternatives {
+		if allHold(terms, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func allHold(terms []term, v Version) bool {
+	for _, t := range terms {
+		cmp := Compare(v, t.version)
+		var ok bool
+		switch t.op {
+		case "=":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the constraint as it was parsed
+func (c *Constraint) String() string {
+	return c.text
+}
+
+// Satisfies parses a version and a constraint and reports whether the
+// version satisfies it
+func Satisfies(version, constraint string) (bool, error) {
+	v, err := Parse(version)
+	if err != nil {
+		return false, err
+	}
+	c, err := ParseConstraint(constraint)
+	if err != nil {
+		return false, err
+	}
+	return c.Check(v), nil
+}
@@ -0,0 +1,213 @@
+// SPDX-License-Identifier: CC0-1.0
+
+// Package semver parses and compares semantic versions, such as the
+// Version constants of sharedgolibs packages ("1.5.0", "v1.16.0") and the
+// versions envinfo scrapes from containers, and matches them against
+// constraints:
+//
+//	v, err := semver.Parse("v1.4.2-rc.1")
+//	c, err := semver.ParseConstraint(">= 1.4, < 2")
+//	ok := c.Check(v)
+//
+//	// Fail early when a container runs an emulator that's too old
+//	err := semver.MinimumVersionCheck("emulator", scraped, "1.4")
+package semver
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidVersion is returned for text that isn't a version
+var ErrInvalidVersion = errors.New("invalid version")
+
+// ErrInvalidConstraint is returned for text that isn't a constraint
+var ErrInvalidConstraint = errors.New("invalid version constraint")
+
+// ErrVersionTooOld is returned by MinimumVersionCheck for a version below
+// the minimum
+var ErrVersionTooOld = errors.New("version too old")
+
+// Version is a semantic version. Missing minor and patch numbers parse as
+// zero, so "1.4" is 1.4.0.
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string // e.g. "rc.1", without the leading "-"
+	Build      string // e.g. "abc123", without the leading "+"; ignored by Compare
+}
+
+// versionPattern matches a version with an optional "v" prefix, up to three
+// numbers, and optional prerelease and build suffixes
+var versionPattern = regexp.MustCompile(`^[vV]?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:-([0-9A-Za-z.-]+))?(?:\+([0-9A-Za-z.-]+))?$`)
+
+// embeddedPattern finds a version inside other text, such as the output of
+// `tool --version`. At least major.minor is required there so counts and
+// dates aren't taken for versions.
+var embeddedPattern = regexp.MustCompile(`[vV]?\d+\.\d+(?:\.\d+)?(?:-[0-9A-Za-z.-]+)?(?:\+[0-9A-Za-z.-]+)?`)
+
+// Parse parses a version such as "1.4", "v1.16.0", or "2.0.0-rc.1+abc"
+func Parse(s string) (Version, error) {
+	match := versionPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return Version{}, fmt.Errorf("%w: %q", ErrInvalidVersion, s)
+	}
+
+	var numbers [3]int
+	for i, text := range match[1:4] {
+		if text == "" {
+			continue
+		}
+		n, err := strconv.Atoi(text)
+		if err != nil {
+			return Version{}, fmt.Errorf("%w: %q", ErrInvalidVersion, s)
+		}
+		numbers[i] = n
+	}
+	return Version{
+		Major:      numbers[0],
+		Minor:      numbers[1],
+		Patch:      numbers[2],
+		Prerelease: match[4],
+		Build:      match[5],
+	}, nil
+}
+
+// MustParse is like Parse but panics on an invalid version, for constants
+func MustParse(s string) Version {
+	v, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// Extract finds the first version in text such as "emulator version 1.4.2
+// (linux/amd64)", reporting whether there was one
+func Extract(text string) (Version, bool) {
+	for _, candidate := range embeddedPattern.FindAllString(text, -1) {
+		if v, err := Parse(strings.TrimRight(candidate, ".-")); err == nil {
+			return v, true
+		}
+	}
+	return Version{}, false
+}
+
+// String formats the version without a "v" prefix, e.g. "1.4.0-rc.1"
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Compare returns -1, 0, or +1 as v is lower than, equal to, or higher than
+// other, by semantic version precedence: a prerelease is lower than its
+// release, and build metadata is ignored
+func (v Version) Compare(other Version) int {
+	return Compare(v, other)
+}
+
+// LessThan reports whether v is lower than other
+func (v Version) LessThan(other Version) bool {
+	return Compare(v, other) < 0
+}
+
+// Compare returns -1, 0, or +1 as a is lower than, equal to, or higher than
+// b (see Version.Compare)
+func Compare(a, b Version) int {
+	for _, pair := range [][2]int{{a.Major, b.Major}, {a.Minor, b.Minor}, {a.Patch, b.Patch}} {
+		if c := compareInts(pair[0], pair[1]); c != 0 {
+			return c
+		}
+	}
+	return comparePrerelease(a.Prerelease, b.Prerelease)
+}
+
+// CompareStrings parses and compares two versions
+func CompareStrings(a, b string) (int, error) {
+	va, err := Parse(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := Parse(b)
+	if err != nil {
+		return 0, err
+	}
+	return Compare(va, vb), nil
+}
+
+// comparePrerelease orders prerelease identifiers: none is highest, numeric
+// identifiers compare numerically and below alphanumeric ones, and a longer
+// list wins when one is a prefix of the other
+func comparePrerelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+
+	partsA, partsB := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(partsA) && i < len(partsB); i++ {
+		numA, errA := strconv.Atoi(partsA[i])
+		numB, errB := strconv.Atoi(partsB[i])
+		var c int
+		switch {
+		case errA == nil && errB == nil:
+			c = compareInts(numA, numB)
+		case errA == nil:
+			c = -1
+		case errB == nil:
+			c = 1
+		default:
+			c = strings.Compare(partsA[i], partsB[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(partsA), len(partsB))
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// MinimumVersionCheck returns an error wrapping ErrVersionTooOld if actual
+// is lower than minimum, naming the component, e.g. "emulator 1.3.2 is
+// older than the required 1.4.0". actual may be the output of a --version
+// command; the first version in it is used (see Extract).
+func MinimumVersionCheck(name, actual, minimum string) error {
+	required, err := Parse(minimum)
+	if err != nil {
+		return err
+	}
+	v, err := Parse(actual)
+	if err != nil {
+		var ok bool
+		if v, ok = Extract(actual); !ok {
+			return fmt.Errorf("%s: no version in %q: %w", name, actual, ErrInvalidVersion)
+		}
+	}
+	if v.LessThan(required) {
+		return fmt.Errorf("%w: %s %s is older than the required %s", ErrVersionTooOld, name, v, required)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package semver
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Version
+	}{
+		{"1.5.0", Version{Major: 1, Minor: 5}},
+		{"v1.16.0", Version{Major: 1, Minor: 16}},
+		{"1.4", Version{Major: 1, Minor: 4}},
+		{"2", Version{Major: 2}},
+		{" 2.0.0-rc.1+abc123 ", Version{Major: 2, Prerelease: "rc.1", Build: "abc123"}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.input)
+		if err != nil || got != tt.want {
+			t.Errorf("Parse(%q) = %+v, %v, want %+v", tt.input, got, err, tt.want)
+		}
+	}
+
+	for _, input := range []string{"", "v", "1.2.3.4", "1.x", "version 1.2", "1.2.3-"} {
+		if _, err := Parse(input); !errors.Is(err, ErrInvalidVersion) {
+			t.Errorf("Parse(%q) = %v, want ErrInvalidVersion", input, err)
+		}
+	}
+
+	if s := MustParse("v1.4-beta.2+build.7").String(); s != "1.4.0-beta.2+build.7" {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{"emulator version 1.4.2 (linux/amd64)", "1.4.2", true},
+		{"ca v2.29.0 (commit abc123, built 2026-10-01)", "2.29.0", true},
+		{"Version: 3.11.", "3.11.0", true},
+		{"tool 7 started", "", false},
+		{"version not detected", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := Extract(tt.input)
+		if ok != tt.ok || (ok && got.String() != tt.want) {
+			t.Errorf("Extract(%q) = %s, %t, want %s, %t", tt.input, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// In ascending precedence order
+	ordered := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta",
+		"1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.4", "1.16.0", "2.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			got, err := CompareStrings(ordered[i], ordered[j])
+			want := compareInts(i, j)
+			if err != nil || got != want {
+				t.Errorf("CompareStrings(%s, %s) = %d, %v, want %d", ordered[i], ordered[j], got, err, want)
+			}
+		}
+	}
+
+	if Compare(MustParse("1.2.3+a"), MustParse("1.2.3+b")) != 0 {
+		t.Error("expected build metadata to be ignored")
+	}
+	if !MustParse("1.9.0").LessThan(MustParse("1.10.0")) {
+		t.Error("expected numeric comparison of minor versions")
+	}
+	if _, err := CompareStrings("1.0", "latest"); err == nil {
+		t.Error("expected an error comparing an invalid version")
+	}
+}
+
+func TestConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		matches    []string
+		rejects    []string
+	}{
+		{">= 1.4", []string{"1.4.0", "1.4.1", "2.0.0"}, []string{"1.3.9", "1.4.0-rc.1"}},
+		{">= 1.4, < 2", []string{"1.4.0", "1.99.0"}, []string{"1.3.0", "2.0.0"}},
+		{"1.2.3", []string{"1.2.3", "v1.2.3+build"}, []string{"1.2.4"}},
+		{"!= 1.2.3", []string{"1.2.4"}, []string{"1.2.3"}},
+		{"> 1.2, <= 1.3", []string{"1.2.1", "1.3.0"}, []string{"1.2.0", "1.3.1"}},
+		{"~1.4.2", []string{"1.4.2", "1.4.9"}, []string{"1.4.1", "1.5.0"}},
+		{"~1", []string{"1.0.0", "1.9.0"}, []string{"2.0.0"}},
+		{"^1.4.2", []string{"1.4.2", "1.9.0"}, []string{"1.4.1", "2.0.0"}},
+		{"^0.4.2", []string{"0.4.2", "0.4.9"}, []string{"0.5.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"^1.2 || ~2.0.3", []string{"1.5.0", "2.0.4"}, []string{"2.1.0", "1.1.0"}},
+		{"*", []string{"0.0.1", "9.9.9"}, nil},
+	}
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Fatalf("ParseConstraint(%q) failed: %v", tt.constraint, err)
+		}
+		for _, v := range tt.matches {
+			if !c.Check(MustParse(v)) {
+				t.Errorf("expected %s to satisfy %q", v, c)
+			}
+		}
+		for _, v := range tt.rejects {
+			if c.Check(MustParse(v)) {
+				t.Errorf("expected %s not to satisfy %q", v, c)
+			}
+		}
+	}
+
+	for _, input := range []string{"", ">= 1.4,", ">= latest", "=> 1.4", "1.4 ||"} {
+		if _, err := ParseConstraint(input); !errors.Is(err, ErrInvalidConstraint) {
+			t.Errorf("ParseConstraint(%q) = %v, want ErrInvalidConstraint", input, err)
+		}
+	}
+
+	if ok, err := Satisfies("v1.16.0", "^1.10"); !ok || err != nil {
+		t.Errorf("Satisfies = %t, %v, want true", ok, err)
+	}
+}
+
+func TestMinimumVersionCheck(t *testing.T) {
+	if err := MinimumVersionCheck("emulator", "emulator version 1.4.2", "1.4"); err != nil {
+		t.Errorf("expected 1.4.2 to meet 1.4, got %v", err)
+	}
+
+	err := MinimumVersionCheck("emulator", "1.3.9", "1.4")
+	if !errors.Is(err, ErrVersionTooOld) || err.Error() != "version too old: emulator 1.3.9 is older than the required 1.4.0" {
+		t.Errorf("unexpected error for an old version: %v", err)
+	}
+
+	if err := MinimumVersionCheck("emulator", "version not detected", "1.4"); !errors.Is(err, ErrInvalidVersion) {
+		t.Errorf("expected ErrInvalidVersion without a version, got %v", err)
+	}
+	if err := MinimumVersionCheck("emulator", "1.4.0", "latest"); !errors.Is(err, ErrInvalidVersion) {
+		t.Errorf("expected ErrInvalidVersion for an invalid minimum, got %v", err)
+	}
+}
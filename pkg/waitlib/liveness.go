@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package waitlib
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Option sets a default for Run; command-line flags take precedence
+type Option func(*WaitConfig)
+
+// WithHealthAddr serves GET /healthz on addr (e.g. ":8080") while waiting
+func WithHealthAddr(addr string) Option {
+	return func(c *WaitConfig) {
+		c.HealthAddr = addr
+	}
+}
+
+// WithExitCode sets the exit code used after SIGTERM or SIGINT
+func WithExitCode(code int) Option {
+	return func(c *WaitConfig) {
+		c.ExitCode = code
+	}
+}
+
+// HealthStatus is the JSON body served by /healthz
+type HealthStatus struct {
+	Status         string    `json:"status"`
+	Version        string    `json:"version"`
+	LibraryVersion string    `json:"library_version"`
+	StartedAt      time.Time `json:"started_at"`
+	Uptime         string    `json:"uptime"`
+	UptimeSeconds  int64     `json:"uptime_seconds"`
+}
+
+// healthHandler answers liveness checks with the version and uptime
+func healthHandler(version string, startTime time.Time) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		uptime := time.Since(startTime)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(HealthStatus{
+			Status:         "ok",
+			Version:        version,
+			LibraryVersion: Version,
+			StartedAt:      startTime.UTC(),
+			Uptime:         formatUptime(uptime),
+			UptimeSeconds:  int64(uptime.Seconds()),
+		})
+	})
+	return mux
+}
+
+// startHealthServer listens on addr and serves the liveness endpoint in the
+// background. The returned server's Addr is the address actually bound.
+func startHealthServer(addr, version string, startTime time.Time) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for health checks: %w", err)
+	}
+
+	server := &http.Server{
+		Addr:              listener.Addr().String(),
+		Handler:           healthHandler(version, startTime),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go server.Serve(listener)
+	return server, nil
+}
@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package waitlib
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestHealthServer(t *testing.T) {
+	startTime := time.Now().Add(-90 * time.Minute)
+	server, err := startHealthServer("127.0.0.1:0", "v9.9.9", startTime)
+	if err != nil {
+		t.Fatalf("startHealthServer failed: %v", err)
+	}
+	defer server.Close()
+
+	resp, err := http.Get("http://" + server.Addr + "/healthz")
+	if err != nil {
+		t.Fatalf("GET /healthz failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+	var status HealthStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode health status: %v", err)
+	}
+	if status.Status != "ok" || status.Version != "v9.9.9" || status.LibraryVersion != Version || status.Uptime != "1h30m" {
+		t.Errorf("Unexpected health status: %+v", status)
+	}
+}
+
+func TestWaitExitsOnSignal(t *testing.T) {
+	signals := make(chan os.Signal, 1)
+	signals <- syscall.SIGTERM
+
+	config := WaitConfig{}
+	WithExitCode(143)(&config)
+	WithHealthAddr("127.0.0.1:0")(&config)
+
+	done := make(chan int, 1)
+	go func() { done <- wait("test", config, signals) }()
+
+	select {
+	case code := <-done:
+		if code != 143 {
+			t.Errorf("Expected exit code 143, got %d", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait did not return after SIGTERM")
+	}
+}
+
+func TestWaitHealthAddrInUse(t *testing.T) {
+	server, err := startHealthServer("127.0.0.1:0", "test", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	if code := wait("test", WaitConfig{HealthAddr: server.Addr}, make(chan os.Signal)); code != 1 {
+		t.Errorf("Expected exit code 1 when the health address is in use, got %d", code)
+	}
+}
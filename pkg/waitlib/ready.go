@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package waitlib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrTimeout is returned by RunUntil when dependencies are still not ready
+// once the timeout has passed
+var ErrTimeout = errors.New("timed out waiting for dependencies")
+
+// Defaults for ReadyConfig
+const (
+	DefaultInterval    = 500 * time.Millisecond
+	DefaultMaxInterval = 10 * time.Second
+	checkTimeout       = 2 * time.Second
+)
+
+// ReadyConfig configures RunUntil: which dependencies to wait for, how long,
+// and what to run once they are all ready
+type ReadyConfig struct {
+	Version string // Shown in the process title while waiting
+
+	TCP   []string // host:port addresses that must accept connections
+	HTTP  []string // URLs that must answer GET with a 2xx status
+	Files []string // Paths that must exist
+
+	Timeout     time.Duration // Give up after this long (0 = wait forever)
+	Interval    time.Duration // Delay before the first retry (default DefaultInterval)
+	MaxInterval time.Duration // Retry delays double up to this (default DefaultMaxInterval)
+
+	// Command replaces the current process once all dependencies are ready,
+	// keeping its PID (e.g. PID 1 in a container). Where exec is not
+	// supported it is run as a child and waitlib exits with its exit code.
+	// When empty, RunUntil returns nil once ready.
+	Command []string
+}
+
+// dependency is a single condition RunUntil waits for
+type dependency struct {
+	name  string // e.g. "tcp db:5432"
+	ready func(ctx context.Context) bool
+}
+
+// dependencies builds the checks for every configured dependency
+func (c ReadyConfig) dependencies() []dependency {
+	var deps []dependency
+	for _, address := range c.TCP {
+		address := address
+		deps = append(deps, dependency{name: "tcp " + address, ready: func(ctx context.Context) bool {
+			return tcpReady(ctx, address)
+		}})
+	}
+	for _, url := range c.HTTP {
+		url := url
+		deps = append(deps, dependency{name: "http " + url, ready: func(ctx context.Context) bool {
+			return httpReady(ctx, url)
+		}})
+	}
+	for _, path := range c.Files {
+		path := path
+		deps = append(deps, dependency{name: "file " + path, ready: func(context.Context) bool {
+			_, err := os.Stat(path)
+			return err == nil
+		}})
+	}
+	return deps
+}
+
+// tcpReady reports whether address accepts a TCP connection
+func tcpReady(ctx context.Context, address string) bool {
+	dialer := net.Dialer{Timeout: checkTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// httpReady reports whether url answers GET with a 2xx status
+func httpReady(ctx context.Context, url string) bool {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
+
+// RunUntil waits until every configured dependency is ready, retrying with
+// exponential backoff and showing progress in the process title, then execs
+// cfg.Command. It returns an error wrapping ErrTimeout if cfg.Timeout passes
+// first, or the error from starting the command.
+//
+// Example usage:
+//
+//	err := waitlib.RunUntil(waitlib.ReadyConfig{
+//		Version: "v1.0.0",
+//		TCP:     []string{"db:5432"},
+//		HTTP:    []string{"http://ca:8090/health"},
+//		Timeout: 2 * time.Minute,
+//		Command: []string{"/app/server", "--port", "8080"},
+//	})
+func RunUntil(cfg ReadyConfig) error {
+	ctx := context.Background()
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	if err := waitReady(ctx, cfg); err != nil {
+		return err
+	}
+
+	if len(cfg.Command) == 0 {
+		return nil
+	}
+	fmt.Printf("waitlib: starting %s\n", strings.Join(cfg.Command, " "))
+	return execCommand(cfg.Command)
+}
+
+// waitReady polls the dependencies until all are ready or ctx is done
+func waitReady(ctx context.Context, cfg ReadyConfig) error {
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	maxInterval := cfg.MaxInterval
+	if maxInterval <= 0 {
+		maxInterval = DefaultMaxInterval
+	}
+
+	startTime := time.Now()
+	pending := cfg.dependencies()
+	total := len(pending)
+
+	for {
+		// Dependencies stay ready once seen ready
+		var still []dependency
+		for _, dep := range pending {
+			if dep.ready(ctx) {
+				fmt.Printf("waitlib: %s ready after %s\n", dep.name, time.Since(startTime).Round(time.Millisecond))
+			} else {
+				still = append(still, dep)
+			}
+		}
+		pending = still
+
+		if len(pending) == 0 {
+			updateWaitingTitle(cfg.Version, startTime, 0, total)
+			return nil
+		}
+		updateWaitingTitle(cfg.Version, startTime, len(pending), total)
+
+		select {
+		case <-ctx.Done():
+			names := make([]string, len(pending))
+			for i, dep := range pending {
+				names[i] = dep.name
+			}
+			return fmt.Errorf("%w after %s: still waiting for %s", ErrTimeout, time.Since(startTime).Round(time.Millisecond), strings.Join(names, ", "))
+		case <-time.After(interval):
+		}
+
+		interval *= 2
+		if interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
+
+// updateWaitingTitle shows readiness progress in the process title, e.g. "wait v1.0.0 ready 1/3 2m"
+func updateWaitingTitle(version string, startTime time.Time, pending, total int) {
+	title := fmt.Sprintf("wait %s ready %d/%d %s", version, total-pending, total, formatUptime(time.Since(startTime)))
+	_ = setProcessTitle(title) // Best effort, as in updateProcessTitle
+}
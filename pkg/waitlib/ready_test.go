@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: CC0-1.0
+
+package waitlib
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunUntilReady(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// Health check fails twice before passing
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	// File appears shortly after waiting starts
+	path := filepath.Join(t.TempDir(), "ready")
+	time.AfterFunc(50*time.Millisecond, func() { os.WriteFile(path, nil, 0o644) })
+
+	start := time.Now()
+	err = RunUntil(ReadyConfig{
+		Version:     "test",
+		TCP:         []string{listener.Addr().String()},
+		HTTP:        []string{server.URL},
+		Files:       []string{path},
+		Timeout:     5 * time.Second,
+		Interval:    10 * time.Millisecond,
+		MaxInterval: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("RunUntil failed: %v", err)
+	}
+	if calls.Load() < 3 {
+		t.Errorf("Expected health check to be retried until it passed, got %d calls", calls.Load())
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("Expected backoff capped at MaxInterval, took %s", time.Since(start))
+	}
+}
+
+func TestRunUntilTimeout(t *testing.T) {
+	// A port that was just released refuses connections
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	err = RunUntil(ReadyConfig{
+		TCP:      []string{address},
+		Files:    []string{filepath.Join(t.TempDir(), "never")},
+		Timeout:  100 * time.Millisecond,
+		Interval: 10 * time.Millisecond,
+	})
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Expected ErrTimeout, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "tcp "+address) || !strings.Contains(err.Error(), "file ") {
+		t.Errorf("Expected pending dependencies in error, got %v", err)
+	}
+}
+
+func TestRunUntilNoDependencies(t *testing.T) {
+	if err := RunUntil(ReadyConfig{}); err != nil {
+		t.Errorf("Expected immediate success without dependencies, got %v", err)
+	}
+}
@@ -7,50 +7,98 @@ package waitlib
 import (
 	"flag"
 	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
+
+	"github.com/nzions/sharedgolibs/pkg/buildinfo"
 )
 
 // Version is the current version of the waitlib package
-const Version = "v0.1.0"
+const Version = "v0.4.0"
 
 // WaitConfig holds configuration for the wait functionality
 type WaitConfig struct {
 	Version     string
 	ShowHelp    bool
 	ShowVersion bool
+	ShowKeys    bool
+	Ready       ReadyConfig // Readiness probe mode, used when it has dependencies or a command
+	HealthAddr  string      // Serve GET /healthz on this address while waiting (empty = disabled)
+	ExitCode    int         // Exit code after SIGTERM or SIGINT
 }
 
+// stringList is a flag that can be repeated
+type stringList []string
+
+func (s *stringList) String() string     { return strings.Join(*s, ",") }
+func (s *stringList) Set(v string) error { *s = append(*s, v); return nil }
+
 // Run executes the waitlib functionality with the given version string.
 // This is the main entry point that handles command-line arguments and starts the wait process.
+// Options set defaults that command-line flags can override.
 //
 // Example usage:
 //
 //	waitlib.Run("v1.0.0")
-func Run(version string) {
-	config := parseFlags()
+//	waitlib.Run("v1.0.0", waitlib.WithHealthAddr(":8080"), waitlib.WithExitCode(0))
+func Run(version string, opts ...Option) {
+	defaults := WaitConfig{}
+	for _, opt := range opts {
+		opt(&defaults)
+	}
+	config := parseFlags(defaults)
 
 	if config.ShowHelp {
 		showHelp()
 		return
 	}
 
+	if config.ShowKeys {
+		fmt.Print(buildInfo(version).Keys())
+		return
+	}
+
 	if config.ShowVersion {
 		showVersion(version)
 		return
 	}
 
+	// Readiness probe mode: wait for dependencies, then exec the command
+	ready := config.Ready
+	if len(ready.TCP)+len(ready.HTTP)+len(ready.Files)+len(ready.Command) > 0 {
+		ready.Version = version
+		if err := RunUntil(ready); err != nil {
+			fmt.Fprintf(os.Stderr, "waitlib: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Start the wait process
-	startWait(version)
+	startWait(version, config)
 }
 
-// parseFlags parses command-line flags and returns a WaitConfig
-func parseFlags() WaitConfig {
-	config := WaitConfig{}
+// parseFlags parses command-line flags over defaults and returns a WaitConfig
+func parseFlags(defaults WaitConfig) WaitConfig {
+	config := defaults
 
 	flag.BoolVar(&config.ShowHelp, "help", false, "Show help information")
 	flag.BoolVar(&config.ShowVersion, "version", false, "Show version information")
+	flag.BoolVar(&config.ShowKeys, "keys", false, "Show build information as key=value lines")
+	flag.Var((*stringList)(&config.Ready.TCP), "tcp", "Wait for host:port to accept connections (repeatable)")
+	flag.Var((*stringList)(&config.Ready.HTTP), "http", "Wait for URL to answer with a 2xx status (repeatable)")
+	flag.Var((*stringList)(&config.Ready.Files), "file", "Wait for path to exist (repeatable)")
+	flag.DurationVar(&config.Ready.Timeout, "timeout", 0, "Give up waiting after this long (0 = forever)")
+	flag.StringVar(&config.HealthAddr, "health-addr", defaults.HealthAddr, "Serve GET /healthz on this address, e.g. :8080")
+	flag.IntVar(&config.ExitCode, "exit-code", defaults.ExitCode, "Exit code after SIGTERM or SIGINT")
 	flag.Parse()
 
+	// Anything after the flags (or after --) is the command to exec once ready
+	config.Ready.Command = flag.Args()
+
 	return config
 }
 
@@ -60,10 +108,18 @@ func showHelp() {
 
 Usage:
   waitlib [options]
+  waitlib [--tcp host:port] [--http url] [--file path] [--timeout d] [--] command [args...]
 
 Options:
-  --help     Show this help message
-  --version  Show version information
+  --help       Show this help message
+  --version    Show version information
+  --keys       Show build information as key=value lines
+  --tcp        Wait for host:port to accept connections (repeatable)
+  --http       Wait for URL to answer with a 2xx status (repeatable)
+  --file       Wait for path to exist (repeatable)
+  --timeout    Give up waiting after this long, e.g. 2m (default: forever)
+  --health-addr  Serve GET /healthz (version and uptime) on this address, e.g. :8080
+  --exit-code    Exit code after SIGTERM or SIGINT (default: 0)
 
 Description:
   waitlib is a utility that runs indefinitely, updating its process name
@@ -73,26 +129,61 @@ Description:
   When running, the process will appear in 'docker ps' as:
     wait <version> <uptime>
 
+  SIGTERM and SIGINT stop waitlib cleanly with --exit-code. With
+  --health-addr, GET /healthz answers 200 with the version and uptime for
+  orchestrator liveness checks.
+
+  With --tcp, --http, or --file, waitlib instead waits until every
+  dependency is ready, retrying with backoff, then execs the command (or
+  exits 0 if none is given). It exits 1 if --timeout passes first.
+
 Examples:
   waitlib --help
   waitlib --version
   waitlib
+  waitlib --health-addr :8080
+  waitlib --tcp db:5432 --http http://ca:8090/health --timeout 2m -- /app/server
 
 `)
 }
 
+// buildInfo describes the binary for --version and --keys
+func buildInfo(version string) buildinfo.Info {
+	return buildinfo.New("waitlib", version).WithPackage("waitlib", Version)
+}
+
 // showVersion displays version information
 func showVersion(version string) {
-	fmt.Printf("waitlib %s (library version: %s)\n", version, Version)
+	fmt.Println(buildInfo(version))
 }
 
-// startWait begins the main wait loop, updating the process title periodically
-func startWait(version string) {
+// startWait begins the main wait loop, updating the process title periodically,
+// and exits once a termination signal arrives
+func startWait(version string, config WaitConfig) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+
+	os.Exit(wait(version, config, signals))
+}
+
+// wait runs the wait loop until a signal arrives and returns the exit code
+func wait(version string, config WaitConfig, signals <-chan os.Signal) int {
 	startTime := time.Now()
 
 	// Update process title immediately
 	updateProcessTitle(version, startTime)
 
+	// Serve the liveness endpoint
+	if config.HealthAddr != "" {
+		server, err := startHealthServer(config.HealthAddr, version, startTime)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "waitlib: %v\n", err)
+			return 1
+		}
+		defer server.Close()
+		fmt.Printf("Liveness endpoint: http://%s/healthz\n", server.Addr)
+	}
+
 	// Create a ticker to update the process title every minute
 	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
@@ -101,8 +192,14 @@ func startWait(version string) {
 	fmt.Printf("Process will show as: wait %s <uptime>\n", version)
 
 	// Main wait loop
-	for range ticker.C {
-		updateProcessTitle(version, startTime)
+	for {
+		select {
+		case <-ticker.C:
+			updateProcessTitle(version, startTime)
+		case sig := <-signals:
+			fmt.Printf("waitlib %s received %s after %s, exiting with code %d\n", version, sig, formatUptime(time.Since(startTime)), config.ExitCode)
+			return config.ExitCode
+		}
 	}
 }
 
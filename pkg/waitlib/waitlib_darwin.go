@@ -7,6 +7,8 @@ package waitlib
 import (
 	"fmt"
 	"os"
+	"os/exec"
+	"syscall"
 )
 
 // setProcessTitle sets the process title on macOS
@@ -54,3 +56,12 @@ func setProcessNameArgv(title string) error {
 
 	return nil
 }
+
+// execCommand replaces the current process with argv
+func execCommand(argv []string) error {
+	path, err := exec.LookPath(argv[0])
+	if err != nil {
+		return fmt.Errorf("failed to find command: %w", err)
+	}
+	return syscall.Exec(path, argv, os.Environ())
+}
@@ -7,6 +7,7 @@ package waitlib
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"syscall"
 	"unsafe"
 )
@@ -101,3 +102,12 @@ func setProcessNameArgv(title string) error {
 
 	return nil
 }
+
+// execCommand replaces the current process with argv
+func execCommand(argv []string) error {
+	path, err := exec.LookPath(argv[0])
+	if err != nil {
+		return fmt.Errorf("failed to find command: %w", err)
+	}
+	return syscall.Exec(path, argv, os.Environ())
+}
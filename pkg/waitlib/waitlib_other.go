@@ -4,9 +4,33 @@
 
 package waitlib
 
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
 // setProcessTitle is a no-op on unsupported platforms
 func setProcessTitle(title string) error {
 	// For other platforms, just silently succeed
 	// Process title setting is not supported
 	return nil
 }
+
+// execCommand runs argv as a child, since exec is not available, and exits
+// with its exit code
+func execCommand(argv []string) error {
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.ExitCode())
+	}
+	if err != nil {
+		return err
+	}
+	os.Exit(0)
+	return nil
+}
@@ -12,8 +12,8 @@ func TestVersion(t *testing.T) {
 		t.Error("Version constant should not be empty")
 	}
 
-	if Version != "v0.1.0" {
-		t.Errorf("Expected version v0.1.0, got %s", Version)
+	if Version != "v0.4.0" {
+		t.Errorf("Expected version v0.4.0, got %s", Version)
 	}
 }
 